@@ -0,0 +1,405 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"fin_bot/audit"
+	"fin_bot/marketdata"
+	"fin_bot/metrics"
+	"fin_bot/quote"
+	"fin_bot/service"
+	"fin_bot/storage"
+)
+
+// ErrTooMany 用户的提醒数量达到上限
+var ErrTooMany = errors.New("提醒数量已达上限")
+
+// rsiLookbackDays 计算 RSI 时查询的日线天数（自然日），需要远多于 RSI 周期让 Wilder 平滑收敛
+const rsiLookbackDays = 365
+
+// Source 提醒使用的行情来源，*marketdata.Cache 等 marketdata.Provider 都可以使用
+type Source interface {
+	Quote(ctx context.Context, sym marketdata.Symbol) (*marketdata.Quote, error)
+	History(ctx context.Context, sym marketdata.Symbol, from, to time.Time) ([]*marketdata.Bar, error)
+}
+
+// Settings 可以在运行中修改的提醒设置
+type Settings struct {
+	Interval          time.Duration // 检查提醒的间隔
+	HysteresisPercent float64       // 价格提醒的回差（阈值的百分比）
+	HysteresisPoints  float64       // 涨跌幅和 RSI 提醒的回差（点）
+	MaxRules          int           // 每个用户最多的生效中提醒数
+	Timezone          string        // 提醒记录中时间使用的时区
+}
+
+// Service 价格和指标提醒：用户用命令创建提醒规则，后台按间隔检查所有生效中的规则，
+// 条件满足时通过私聊通知创建者
+// 重复提醒触发后需要值回到阈值另一侧超过回差才会再次生效，避免在阈值附近反复提醒
+type Service struct {
+	store  *storage.Storage
+	apps   *service.AppRegistry // 发送提醒
+	source Source
+	quotes *quote.Service // 按名称查找证券和格式化数据时间
+	now    func() time.Time
+
+	settingsMu      sync.RWMutex // 保护 settings 和 loc，配置热加载时会被修改
+	settings        Settings
+	loc             *time.Location
+	intervalChanged chan struct{}
+
+	runMu  sync.Mutex // 串行化提醒检查，避免同一次触发重复发送
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New 创建提醒服务，source 为行情来源（通常是带缓存的 *marketdata.Cache）
+func New(store *storage.Storage, apps *service.AppRegistry, source Source, quotes *quote.Service, settings Settings) *Service {
+	s := &Service{
+		store:           store,
+		apps:            apps,
+		source:          source,
+		quotes:          quotes,
+		now:             time.Now,
+		intervalChanged: make(chan struct{}, 1),
+	}
+	s.setSettings(settings)
+	return s
+}
+
+// SetSettings 修改提醒设置，检查间隔变化时运行中的检查循环立即按新间隔重置
+func (s *Service) SetSettings(settings Settings) {
+	old, _ := s.currentSettings()
+	s.setSettings(settings)
+
+	if old.Interval != settings.Interval {
+		select {
+		case s.intervalChanged <- struct{}{}:
+		default:
+		}
+		log.Printf("[alert] 检查间隔已更新: interval=%s", settings.Interval)
+	}
+}
+
+// setSettings 保存设置，时区无效时使用 UTC，间隔无效时使用 1 分钟（配置校验保证两者有效）
+func (s *Service) setSettings(settings Settings) {
+	loc, err := time.LoadLocation(settings.Timezone)
+	if err != nil {
+		log.Printf("[alert] 无效的时区 %q，使用 UTC: %v", settings.Timezone, err)
+		loc = time.UTC
+	}
+	if settings.Interval <= 0 {
+		settings.Interval = time.Minute
+	}
+	s.settingsMu.Lock()
+	s.settings = settings
+	s.loc = loc
+	s.settingsMu.Unlock()
+}
+
+// currentSettings 获取当前的提醒设置和时区
+func (s *Service) currentSettings() (Settings, *time.Location) {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return s.settings, s.loc
+}
+
+// Reading 提醒指标的一次读数
+type Reading struct {
+	Value float64
+	Quote *marketdata.Quote
+}
+
+// Create 解析提醒原文并为用户创建提醒规则，同时返回当前的读数（查询失败时为 nil）
+func (s *Service) Create(ctx context.Context, scope storage.Scope, userID, chatID, text string) (*storage.AlertRule, *Reading, error) {
+	cond, err := Parse(text)
+	if err != nil {
+		return nil, nil, err
+	}
+	sym, err := s.quotes.Resolve(ctx, cond.Query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rules, err := s.store.ListAlertRules(ctx, scope, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if settings, _ := s.currentSettings(); len(rules) >= settings.MaxRules {
+		return nil, nil, fmt.Errorf("%w（最多 %d 个），可以用 /alert remove <编号> 删除不需要的提醒", ErrTooMany, settings.MaxRules)
+	}
+
+	rule := &storage.AlertRule{
+		AppID: scope.AppID, TenantKey: scope.TenantKey, UserID: userID, ChatID: chatID, Symbol: sym.String(),
+		Metric: cond.Metric, Period: cond.Period, Op: cond.Op, Threshold: cond.Threshold, Repeat: cond.Repeat, Text: text,
+	}
+	if err := s.store.CreateAlertRule(ctx, rule); err != nil {
+		return nil, nil, err
+	}
+	log.Printf("[alert] 已创建提醒: id=%d, user=%s, symbol=%s, condition=%s, repeat=%v",
+		rule.ID, userID, rule.Symbol, Describe(rule.Metric, rule.Period, rule.Op, rule.Threshold), rule.Repeat)
+
+	reading, err := s.read(ctx, rule, newSnapshot())
+	if err != nil {
+		return rule, nil, nil
+	}
+	return rule, reading, nil
+}
+
+// List 获取用户生效中的提醒规则
+func (s *Service) List(ctx context.Context, scope storage.Scope, userID string) ([]*storage.AlertRule, error) {
+	return s.store.ListAlertRules(ctx, scope, userID)
+}
+
+// Remove 删除用户生效中的提醒规则，不存在时返回 storage.ErrNotFound
+func (s *Service) Remove(ctx context.Context, scope storage.Scope, userID string, id int64) error {
+	return s.store.CancelAlertRule(ctx, scope, userID, id)
+}
+
+// History 获取用户最近的提醒记录，ruleID 不为 0 时只返回该规则的记录
+func (s *Service) History(ctx context.Context, scope storage.Scope, userID string, ruleID int64, limit int) ([]*storage.AlertEvent, error) {
+	return s.store.ListAlertEvents(ctx, scope, userID, ruleID, limit)
+}
+
+// Start 在后台启动提醒检查循环
+func (s *Service) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	runCtx, cancel := context.WithCancel(ctx)
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	go s.loop(runCtx)
+	settings, _ := s.currentSettings()
+	log.Printf("[alert] 提醒检查已启动: interval=%s", settings.Interval)
+	return nil
+}
+
+// Stop 停止提醒检查循环，等待正在进行的检查完成
+func (s *Service) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.mu.Unlock()
+	if cancel == nil {
+		return nil
+	}
+
+	cancel()
+	select {
+	case <-done:
+		log.Println("[alert] 提醒检查已停止")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// loop 启动时立即检查一次，之后按间隔检查
+func (s *Service) loop(ctx context.Context) {
+	defer close(s.done)
+
+	s.Evaluate(ctx)
+
+	settings, _ := s.currentSettings()
+	ticker := time.NewTicker(settings.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.intervalChanged:
+			settings, _ := s.currentSettings()
+			ticker.Reset(settings.Interval)
+		case <-ticker.C:
+			s.Evaluate(ctx)
+		}
+	}
+}
+
+// Evaluate 检查所有生效中的提醒规则，发送满足条件的提醒，返回触发的规则数
+func (s *Service) Evaluate(ctx context.Context) int {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	rules, err := s.store.ListActiveAlertRules(ctx)
+	if err != nil {
+		log.Printf("[alert] 加载提醒规则失败: %v", err)
+		return 0
+	}
+
+	snap := newSnapshot()
+	triggered := 0
+	for _, rule := range rules {
+		if ctx.Err() != nil {
+			break
+		}
+		reading, err := s.read(ctx, rule, snap)
+		if err != nil {
+			if !errors.Is(err, marketdata.ErrNotFound) && !errors.Is(err, marketdata.ErrNoData) {
+				log.Printf("[alert] 读取提醒指标失败: id=%d, symbol=%s, error=%v", rule.ID, rule.Symbol, err)
+			}
+			continue
+		}
+
+		fire, armed := check(rule, reading.Value, s.band(rule))
+		switch {
+		case fire:
+			if s.trigger(ctx, rule, reading) {
+				triggered++
+			}
+		case armed != rule.Armed:
+			if err := s.store.SetAlertArmed(ctx, rule.ID, armed); err != nil && !errors.Is(err, storage.ErrNotFound) {
+				log.Printf("[alert] 更新提醒状态失败: id=%d, error=%v", rule.ID, err)
+			}
+		}
+	}
+	if triggered > 0 {
+		log.Printf("[alert] 提醒检查完成: rules=%d, triggered=%d", len(rules), triggered)
+	}
+	return triggered
+}
+
+// check 按当前值判断规则是否触发，返回是否触发和规则之后是否仍可触发
+// 已触发的重复提醒在值回到阈值另一侧超过回差 band 后才重新生效
+func check(rule *storage.AlertRule, value, band float64) (fire, armed bool) {
+	above := rule.Op == OpAbove
+	if rule.Armed {
+		met := (above && value >= rule.Threshold) || (!above && value <= rule.Threshold)
+		return met, !met
+	}
+	reset := (above && value < rule.Threshold-band) || (!above && value > rule.Threshold+band)
+	return false, reset
+}
+
+// band 规则的回差：价格按阈值的百分比，涨跌幅和 RSI 按点数
+func (s *Service) band(rule *storage.AlertRule) float64 {
+	settings, _ := s.currentSettings()
+	if rule.Metric == MetricPrice {
+		return rule.Threshold * settings.HysteresisPercent / 100
+	}
+	return settings.HysteresisPoints
+}
+
+// trigger 记录并发送一次提醒，返回是否记录成功（规则在检查期间被删除时跳过）
+func (s *Service) trigger(ctx context.Context, rule *storage.AlertRule, reading *Reading) bool {
+	event := &storage.AlertEvent{
+		RuleID: rule.ID, AppID: rule.AppID, TenantKey: rule.TenantKey, UserID: rule.UserID, Symbol: rule.Symbol,
+		Metric: rule.Metric, Condition: Describe(rule.Metric, rule.Period, rule.Op, rule.Threshold), Value: reading.Value, Message: s.message(rule, reading), TriggeredAt: s.now(),
+	}
+	if err := s.store.RecordAlertTrigger(ctx, event, !rule.Repeat); err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			log.Printf("[alert] 记录提醒失败: id=%d, error=%v", rule.ID, err)
+		}
+		return false
+	}
+
+	result, errMsg := "delivered", ""
+	if err := s.deliver(ctx, rule, event.Message); err != nil {
+		log.Printf("[alert] 发送提醒失败: id=%d, app=%s, user=%s, error=%v", rule.ID, rule.AppID, rule.UserID, err)
+		result, errMsg = "failed", err.Error()
+	}
+	metrics.AlertsTriggered.WithLabelValues(rule.Metric, result).Inc()
+	if err := s.store.MarkAlertDelivered(ctx, event.ID, errMsg); err != nil {
+		log.Printf("[alert] 更新提醒记录失败: event=%d, error=%v", event.ID, err)
+	}
+	return true
+}
+
+// deliver 通过规则所属应用私聊发送提醒给创建者
+func (s *Service) deliver(ctx context.Context, rule *storage.AlertRule, text string) error {
+	larkService, err := s.apps.LarkService(rule.AppID)
+	if err != nil {
+		return err
+	}
+	ctx = audit.WithActor(ctx, audit.Actor{ID: "alert:trigger", Via: audit.ViaSystem, TenantKey: rule.TenantKey})
+	return larkService.SendTextMessage(ctx, rule.UserID, "open_id", text)
+}
+
+// snapshot 一次检查中的行情，多个规则使用同一证券时只查询一次
+type snapshot struct {
+	quotes map[marketdata.Symbol]quoteResult
+	closes map[marketdata.Symbol]closesResult
+}
+
+type quoteResult struct {
+	quote *marketdata.Quote
+	err   error
+}
+
+type closesResult struct {
+	closes []float64
+	err    error
+}
+
+func newSnapshot() *snapshot {
+	return &snapshot{
+		quotes: make(map[marketdata.Symbol]quoteResult),
+		closes: make(map[marketdata.Symbol]closesResult),
+	}
+}
+
+// read 读取规则的指标当前值
+func (s *Service) read(ctx context.Context, rule *storage.AlertRule, snap *snapshot) (*Reading, error) {
+	sym, err := marketdata.ParseSymbol(rule.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	qr, ok := snap.quotes[sym]
+	if !ok {
+		qr.quote, qr.err = s.source.Quote(ctx, sym)
+		snap.quotes[sym] = qr
+	}
+	if qr.err != nil {
+		return nil, qr.err
+	}
+	q := qr.quote
+
+	switch rule.Metric {
+	case MetricPrice:
+		return &Reading{Value: q.Price, Quote: q}, nil
+	case MetricChange:
+		if q.PrevClose <= 0 {
+			return nil, marketdata.ErrNoData
+		}
+		return &Reading{Value: q.ChangePercent(), Quote: q}, nil
+	case MetricRSI:
+		cr, ok := snap.closes[sym]
+		if !ok {
+			cr.closes, cr.err = s.closes(ctx, sym, q)
+			snap.closes[sym] = cr
+		}
+		if cr.err != nil {
+			return nil, cr.err
+		}
+		rsi, ok := RSI(cr.closes, rule.Period)
+		if !ok {
+			return nil, marketdata.ErrNoData
+		}
+		return &Reading{Value: rsi, Quote: q}, nil
+	}
+	return nil, fmt.Errorf("未知的提醒指标: %s", rule.Metric)
+}
+
+// closes 证券的日线收盘价，最新报价比最后一根日线新时用最新价作为当日收盘价
+func (s *Service) closes(ctx context.Context, sym marketdata.Symbol, q *marketdata.Quote) ([]float64, error) {
+	to := marketdata.Day(q.Time)
+	bars, err := s.source.History(ctx, sym, to.AddDate(0, 0, -rsiLookbackDays), to)
+	if err != nil {
+		return nil, err
+	}
+	closes := make([]float64, 0, len(bars)+1)
+	for _, b := range bars {
+		closes = append(closes, b.Close)
+	}
+	if len(bars) > 0 && bars[len(bars)-1].Date.Equal(to) {
+		closes[len(closes)-1] = q.Price
+	} else {
+		closes = append(closes, q.Price)
+	}
+	return closes, nil
+}
@@ -0,0 +1,62 @@
+package alert
+
+import (
+	"testing"
+
+	"fin_bot/storage"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name      string
+		op        string
+		armed     bool
+		value     float64
+		band      float64
+		wantFire  bool
+		wantArmed bool
+	}{
+		{"向上未达到", OpAbove, true, 99, 2, false, true},
+		{"向上刚好达到", OpAbove, true, 100, 2, true, false},
+		{"向上超过", OpAbove, true, 105, 2, true, false},
+		{"向上触发后在回差内不重新生效", OpAbove, false, 99, 2, false, false},
+		{"向上回差边界不重新生效", OpAbove, false, 98, 2, false, false},
+		{"向上超出回差重新生效", OpAbove, false, 97.9, 2, false, true},
+		{"向上触发后仍在阈值上方不再触发", OpAbove, false, 105, 2, false, false},
+		{"向下未达到", OpBelow, true, 101, 2, false, true},
+		{"向下刚好达到", OpBelow, true, 100, 2, true, false},
+		{"向下触发后在回差内不重新生效", OpBelow, false, 101.5, 2, false, false},
+		{"向下超出回差重新生效", OpBelow, false, 102.1, 2, false, true},
+		{"没有回差时离开阈值即重新生效", OpAbove, false, 99.99, 0, false, true},
+		{"没有回差时停在阈值不重新生效", OpAbove, false, 100, 0, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := &storage.AlertRule{Op: tt.op, Threshold: 100, Armed: tt.armed}
+			fire, armed := check(rule, tt.value, tt.band)
+			if fire != tt.wantFire || armed != tt.wantArmed {
+				t.Errorf("check(%v) = %t, %t, want %t, %t", tt.value, fire, armed, tt.wantFire, tt.wantArmed)
+			}
+		})
+	}
+}
+
+// TestCheckSequence 重复提醒在价格来回波动时只在重新生效后再次触发
+func TestCheckSequence(t *testing.T) {
+	rule := &storage.AlertRule{Op: OpAbove, Threshold: 100, Armed: true, Repeat: true}
+	values := []float64{95, 101, 103, 99, 100.5, 97, 102, 96}
+	var fired []int
+	for i, v := range values {
+		fire, armed := check(rule, v, 2)
+		if fire {
+			fired = append(fired, i)
+		}
+		rule.Armed = armed
+	}
+	if len(fired) != 2 || fired[0] != 1 || fired[1] != 6 {
+		t.Errorf("触发位置 = %v, want [1 6]", fired)
+	}
+	if !rule.Armed {
+		t.Error("最后回落到回差之外后应重新生效")
+	}
+}
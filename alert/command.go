@@ -0,0 +1,166 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"fin_bot/command"
+	"fin_bot/marketdata"
+	"fin_bot/quote"
+	"fin_bot/storage"
+)
+
+// historyLimit /alert history 显示的记录数
+const historyLimit = 10
+
+// Commands 返回提醒的聊天命令
+func (s *Service) Commands() []*command.Command {
+	return []*command.Command{
+		{
+			Name:         "alert",
+			Usage:        "/alert [add] <条件> | list | remove <编号> | history [编号]",
+			Description:  "创建价格和指标提醒，条件满足时私聊通知你，例如 /alert 茅台跌破1500、/alert 腾讯涨幅超过5%、/alert RSI(14) > 70 on AAPL 重复（默认只提醒一次）",
+			ReplyHandler: s.commandAlert,
+		},
+	}
+}
+
+// commandAlert 处理 /alert 命令，第一个参数不是子命令时整段当作提醒条件
+func (s *Service) commandAlert(ctx context.Context, req *command.Request) (*command.Reply, error) {
+	if len(req.Args) == 0 {
+		return nil, errors.New("缺少提醒条件，例如 /alert 茅台跌破1500、/alert 腾讯涨幅超过5%、/alert RSI(14) > 70 on AAPL")
+	}
+	scope := storage.Scope{AppID: req.AppID, TenantKey: req.TenantKey}
+	rest := strings.TrimSpace(strings.TrimPrefix(req.RawArgs, req.Args[0]))
+
+	switch strings.ToLower(req.Args[0]) {
+	case "add":
+		if rest == "" {
+			return nil, errors.New("缺少提醒条件，例如 /alert add 茅台跌破1500")
+		}
+		return s.add(ctx, req, scope, rest)
+	case "list", "ls":
+		return s.list(ctx, req, scope)
+	case "remove", "rm", "del":
+		id, err := ruleID(req.Args[1:], true)
+		if err != nil {
+			return nil, err
+		}
+		err = s.Remove(ctx, scope, req.SenderID, id)
+		if errors.Is(err, storage.ErrNotFound) {
+			return command.TextReply(fmt.Sprintf("没有找到你的提醒 #%d（已触发的一次性提醒会自动结束）", id)), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return command.TextReply(fmt.Sprintf("已删除提醒 #%d", id)), nil
+	case "history":
+		id, err := ruleID(req.Args[1:], false)
+		if err != nil {
+			return nil, err
+		}
+		return s.history(ctx, req, scope, id)
+	}
+	return s.add(ctx, req, scope, req.RawArgs)
+}
+
+// add 创建提醒并回复当前的读数
+func (s *Service) add(ctx context.Context, req *command.Request, scope storage.Scope, text string) (*command.Reply, error) {
+	rule, reading, err := s.Create(ctx, scope, req.SenderID, req.ChatID, text)
+	var unknown *quote.UnknownError
+	switch {
+	case errors.As(err, &unknown) && len(unknown.Suggestions) > 0:
+		names := make([]string, len(unknown.Suggestions))
+		for i, inst := range unknown.Suggestions {
+			names[i] = fmt.Sprintf("%s %s", inst.Name, inst.Symbol)
+		}
+		return command.TextReply(fmt.Sprintf("没有找到「%s」，你要找的是不是: %s", unknown.Query, strings.Join(names, "、"))), nil
+	case errors.As(err, &unknown):
+		return command.TextReply(fmt.Sprintf("没有找到证券「%s」，请检查代码或名称（例如 600519、00700.HK、AAPL）", unknown.Query)), nil
+	case errors.Is(err, marketdata.ErrNotFound):
+		return command.TextReply("没有找到该证券，请检查代码或名称（例如 600519、00700.HK、AAPL）"), nil
+	case errors.Is(err, ErrTooMany):
+		return command.TextReply(err.Error()), nil
+	case err != nil:
+		return nil, err
+	}
+
+	kind := "一次性提醒，触发后自动结束"
+	if rule.Repeat {
+		kind = fmt.Sprintf("重复提醒，触发后回到%s会再次生效", s.rearmAt(rule))
+	}
+	lines := []string{
+		fmt.Sprintf("已创建提醒 #%d：%s %s（%s）", rule.ID, rule.Symbol, Describe(rule.Metric, rule.Period, rule.Op, rule.Threshold), kind),
+	}
+	if reading != nil {
+		line := fmt.Sprintf("%s %s %s", reading.Quote.Name, current(rule.Metric, rule.Period), formatValue(rule.Metric, reading.Value))
+		if fire, _ := check(rule, reading.Value, s.band(rule)); fire {
+			line += "，已经满足条件，将在下次检查时提醒"
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, "条件满足时会私聊通知你，发送 /alert list 查看所有提醒")
+	return command.TextReply(strings.Join(lines, "\n")), nil
+}
+
+// list 处理 /alert list
+func (s *Service) list(ctx context.Context, req *command.Request, scope storage.Scope) (*command.Reply, error) {
+	rules, err := s.List(ctx, scope, req.SenderID)
+	if err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return command.TextReply("你还没有生效中的提醒，发送 /alert <条件> 创建，例如 /alert 茅台跌破1500"), nil
+	}
+	settings, _ := s.currentSettings()
+	lines := []string{fmt.Sprintf("你的提醒（%d/%d）:", len(rules), settings.MaxRules)}
+	for _, rule := range rules {
+		lines = append(lines, s.ruleLine(rule))
+	}
+	lines = append(lines, "发送 /alert remove <编号> 删除，/alert history 查看触发记录")
+	return command.TextReply(strings.Join(lines, "\n")), nil
+}
+
+// history 处理 /alert history
+func (s *Service) history(ctx context.Context, req *command.Request, scope storage.Scope, id int64) (*command.Reply, error) {
+	events, err := s.History(ctx, scope, req.SenderID, id, historyLimit)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		if id != 0 {
+			return command.TextReply(fmt.Sprintf("提醒 #%d 还没有触发过", id)), nil
+		}
+		return command.TextReply("你的提醒还没有触发过"), nil
+	}
+	title := fmt.Sprintf("最近 %d 次提醒:", len(events))
+	if id != 0 {
+		title = fmt.Sprintf("提醒 #%d 最近 %d 次触发:", id, len(events))
+	}
+	lines := []string{title}
+	for _, e := range events {
+		lines = append(lines, s.eventLine(e))
+	}
+	return command.TextReply(strings.Join(lines, "\n")), nil
+}
+
+// ruleID 解析提醒编号参数（可以带 #），required 为 false 时允许省略（返回 0）
+func ruleID(args []string, required bool) (int64, error) {
+	if len(args) == 0 {
+		if required {
+			return 0, errors.New("缺少提醒编号，发送 /alert list 查看")
+		}
+		return 0, nil
+	}
+	if len(args) > 1 {
+		return 0, errors.New("参数数量不正确")
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(args[0], "#"), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("无效的提醒编号: %s", args[0])
+	}
+	return id, nil
+}
@@ -0,0 +1,31 @@
+package alert
+
+// RSI 按 Wilder 平滑计算最后一个收盘价的相对强弱指数（0-100），收盘价不足 period+1 个时返回 false
+func RSI(closes []float64, period int) (float64, bool) {
+	if period <= 0 || len(closes) <= period {
+		return 0, false
+	}
+	p := float64(period)
+	var gain, loss float64
+	for i := 1; i <= period; i++ {
+		if d := closes[i] - closes[i-1]; d > 0 {
+			gain += d
+		} else {
+			loss -= d
+		}
+	}
+	gain, loss = gain/p, loss/p
+	for i := period + 1; i < len(closes); i++ {
+		d := closes[i] - closes[i-1]
+		gain = (gain*(p-1) + max(d, 0)) / p
+		loss = (loss*(p-1) + max(-d, 0)) / p
+	}
+
+	switch {
+	case loss == 0 && gain == 0:
+		return 50, true
+	case loss == 0:
+		return 100, true
+	}
+	return 100 - 100/(1+gain/loss), true
+}
@@ -0,0 +1,43 @@
+package alert
+
+import (
+	"math"
+	"testing"
+)
+
+// wilderCloses 常用的 14 日 RSI 示例数据（StockCharts「Relative Strength Index」一文中的收盘价）
+var wilderCloses = []float64{
+	44.34, 44.09, 44.15, 43.61, 44.33, 44.83, 45.10, 45.42, 45.84, 46.08, 45.89, 46.03, 45.61, 46.28, 46.28,
+	46.00, 46.03, 46.41, 46.22, 45.64, 46.21, 46.25, 45.71, 46.45, 45.78, 45.35, 44.03, 44.18, 44.22, 44.57,
+	43.42, 42.66, 43.13,
+}
+
+func TestRSI(t *testing.T) {
+	tests := []struct {
+		name   string
+		closes []float64
+		period int
+		want   float64
+		ok     bool
+	}{
+		// 示例中的数值按四舍五入后的平均涨跌计算，与精确计算相差不超过 0.1
+		{"第一个值", wilderCloses[:15], 14, 70.53, true},
+		{"平滑一次", wilderCloses[:16], 14, 66.32, true},
+		{"平滑五次", wilderCloses[:20], 14, 57.97, true},
+		{"完整序列", wilderCloses, 14, 37.77, true},
+		{"只涨不跌", []float64{1, 2, 3, 4}, 3, 100, true},
+		{"只跌不涨", []float64{4, 3, 2, 1}, 3, 0, true},
+		{"没有变化", []float64{5, 5, 5, 5}, 3, 50, true},
+		{"涨跌相同", []float64{10, 11, 10}, 2, 50, true},
+		{"收盘价不足", wilderCloses[:14], 14, 0, false},
+		{"无效周期", wilderCloses, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := RSI(tt.closes, tt.period)
+			if ok != tt.ok || math.Abs(got-tt.want) > 0.1 {
+				t.Errorf("RSI = %.2f, %t, want %.2f, %t", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package alert
+
+import (
+	"fmt"
+	"strings"
+
+	"fin_bot/storage"
+)
+
+// message 提醒触发时发送给用户的文本
+func (s *Service) message(rule *storage.AlertRule, reading *Reading) string {
+	q := reading.Quote
+	lines := []string{
+		fmt.Sprintf("🔔 提醒 #%d 已触发：%s %s", rule.ID, q.Name, q.Symbol),
+		fmt.Sprintf("%s %s，条件 %s", current(rule.Metric, rule.Period), formatValue(rule.Metric, reading.Value),
+			Describe(rule.Metric, rule.Period, rule.Op, rule.Threshold)),
+	}
+	if rule.Text != "" {
+		lines = append(lines, "提醒原文: "+rule.Text)
+	}
+	if rule.Repeat {
+		lines = append(lines, fmt.Sprintf("这是重复提醒，回到%s后会再次生效，发送 /alert remove %d 删除", s.rearmAt(rule), rule.ID))
+	} else {
+		lines = append(lines, "这是一次性提醒，已结束")
+	}
+	lines = append(lines, "数据时间 "+s.quotes.AsOf(q.Time))
+	return strings.Join(lines, "\n")
+}
+
+// rearmAt 重复提醒重新生效的条件，例如「价格 1485 以下」
+func (s *Service) rearmAt(rule *storage.AlertRule) string {
+	band := s.band(rule)
+	if rule.Op == OpAbove {
+		return fmt.Sprintf("%s %s 以下", metricName(rule.Metric, rule.Period), formatValue(rule.Metric, rule.Threshold-band))
+	}
+	return fmt.Sprintf("%s %s 以上", metricName(rule.Metric, rule.Period), formatValue(rule.Metric, rule.Threshold+band))
+}
+
+// current 「当前价格」「当前 RSI(14)」等
+func current(metric string, period int) string {
+	if metric == MetricRSI {
+		return "当前 " + metricName(metric, period)
+	}
+	return "当前" + metricName(metric, period)
+}
+
+// ruleLine 提醒列表中的一行
+func (s *Service) ruleLine(rule *storage.AlertRule) string {
+	line := fmt.Sprintf("#%d %s %s", rule.ID, rule.Symbol, Describe(rule.Metric, rule.Period, rule.Op, rule.Threshold))
+	switch {
+	case !rule.Repeat:
+		line += " · 一次性"
+	case rule.Armed:
+		line += fmt.Sprintf(" · 重复（已触发 %d 次）", rule.TriggerCount)
+	default:
+		line += fmt.Sprintf(" · 重复（已触发 %d 次，回到%s后再次生效）", rule.TriggerCount, s.rearmAt(rule))
+	}
+	if rule.Text != "" {
+		line += "\n　原文: " + rule.Text
+	}
+	return line
+}
+
+// eventLine 提醒记录中的一行
+func (s *Service) eventLine(e *storage.AlertEvent) string {
+	_, loc := s.currentSettings()
+	status := "已送达"
+	if !e.Delivered {
+		status = "发送失败"
+		if e.Error != "" {
+			status += ": " + e.Error
+		}
+	}
+	return fmt.Sprintf("%s · 提醒 #%d · %s 触发值 %s（%s）· %s", e.TriggeredAt.In(loc).Format("2006-01-02 15:04"), e.RuleID,
+		e.Symbol, formatValue(e.Metric, e.Value), e.Condition, status)
+}
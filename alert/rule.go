@@ -0,0 +1,200 @@
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 提醒的指标
+const (
+	MetricPrice  = "price"  // 最新价
+	MetricChange = "change" // 当日涨跌幅（%）
+	MetricRSI    = "rsi"    // 日线 RSI
+)
+
+// 比较方向
+const (
+	OpAbove = ">=" // 涨到或突破阈值
+	OpBelow = "<=" // 跌到或跌破阈值
+)
+
+// RSI 周期的默认值和范围
+const (
+	defaultRSIPeriod = 14
+	minRSIPeriod     = 2
+	maxRSIPeriod     = 100
+)
+
+var (
+	// repeatWords 重复提醒的说法
+	repeatWords = regexp.MustCompile(`(?i)重复提醒|每次都?提醒|重复|每次|\brepeat(?:ing)?\b`)
+	// onceWords 一次性提醒的说法（默认）
+	onceWords = regexp.MustCompile(`(?i)只提醒一次|一次性|\bonce\b`)
+	// fillerPrefix 条件前面的语气词
+	fillerPrefix = regexp.MustCompile(`^(?:请在|请|当|如果|若)\s*`)
+	// fillerSuffix 条件后面的「时提醒我」等
+	fillerSuffix = regexp.MustCompile(`\s*(?:的时候|时)?\s*(?:请?(?:提醒|通知|告诉)我?一?下?)?\s*$`)
+	// onSymbol 英文写法「RSI(14) > 70 on QQQ」
+	onSymbol = regexp.MustCompile(`(?i)^(.+?)\s+on\s+(\S.*)$`)
+	// condition 「<证券><指标><比较><数值><单位>」
+	condition = regexp.MustCompile(`^(.*?)\s*(跌破|跌穿|跌到|跌至|低于|小于|少于|不到|不足|<=|<|突破|涨破|涨到|涨至|高于|大于|超过|多于|>=|>)\s*([+-]?\d+(?:\.\d+)?)\s*(%|元|块|美元|港元|港币)?$`)
+	// metricSuffix 证券后面的指标
+	metricSuffix = regexp.MustCompile(`(?i)\s*的?\s*(RSI\s*(?:\(\s*(\d+)\s*\)|(\d+))?|涨跌幅|涨幅|跌幅|股价|现价|价格|价)$`)
+)
+
+// belowWords 表示向下比较的说法，其他为向上比较
+var belowWords = map[string]bool{
+	"跌破": true, "跌穿": true, "跌到": true, "跌至": true, "低于": true, "小于": true,
+	"少于": true, "不到": true, "不足": true, "<=": true, "<": true,
+}
+
+// Condition 从提醒原文中解析出的条件
+type Condition struct {
+	Query     string // 证券代码或名称（原文）
+	Metric    string
+	Period    int // RSI 的周期
+	Op        string
+	Threshold float64
+	Repeat    bool // 重复提醒，否则触发一次后结束
+}
+
+// Parse 解析提醒条件，支持中文和简单的英文写法，例如：
+//   - 茅台跌破1500、600519 突破 1800 重复、AAPL > 200
+//   - 腾讯涨幅超过5%、茅台跌幅超过 3%
+//   - 茅台 RSI 低于 30、QQQ RSI(14) > 70、RSI(14) > 70 on QQQ
+//
+// 默认为一次性提醒，包含「重复」「每次」或 repeat 时为重复提醒
+func Parse(text string) (*Condition, error) {
+	s := normalize(text)
+	cond := &Condition{}
+	if repeatWords.MatchString(s) {
+		cond.Repeat = true
+		s = repeatWords.ReplaceAllString(s, " ")
+	}
+	s = onceWords.ReplaceAllString(s, " ")
+	s = strings.TrimSpace(s)
+	s = fillerPrefix.ReplaceAllString(s, "")
+	s = fillerSuffix.ReplaceAllString(s, "")
+
+	if m := onSymbol.FindStringSubmatch(s); m != nil {
+		s, cond.Query = m[1], strings.TrimSpace(m[2])
+	}
+	m := condition.FindStringSubmatch(s)
+	if m == nil {
+		return nil, errors.New("无法识别提醒条件，例如: 茅台跌破1500、腾讯涨幅超过5%、QQQ RSI(14) > 70")
+	}
+	left, word, unit := strings.TrimSpace(m[1]), m[2], m[4]
+	threshold, err := strconv.ParseFloat(m[3], 64)
+	if err != nil {
+		return nil, fmt.Errorf("无效的数值: %s", m[3])
+	}
+	cond.Op = OpAbove
+	if belowWords[word] {
+		cond.Op = OpBelow
+	}
+
+	cond.Metric = MetricPrice
+	if mm := metricSuffix.FindStringSubmatchIndex(left); mm != nil {
+		name := strings.ToUpper(left[mm[2]:mm[3]])
+		switch {
+		case strings.HasPrefix(name, "RSI"):
+			cond.Metric, cond.Period = MetricRSI, defaultRSIPeriod
+			for _, g := range [][2]int{{mm[4], mm[5]}, {mm[6], mm[7]}} {
+				if g[0] >= 0 {
+					cond.Period, _ = strconv.Atoi(left[g[0]:g[1]])
+				}
+			}
+		case name == "涨跌幅" || name == "涨幅":
+			cond.Metric = MetricChange
+		case name == "跌幅":
+			// 跌幅超过 3% 即涨跌幅不高于 -3%
+			cond.Metric, threshold = MetricChange, -math.Abs(threshold)
+			if cond.Op == OpAbove {
+				cond.Op = OpBelow
+			} else {
+				cond.Op = OpAbove
+			}
+		}
+		left = left[:mm[0]]
+	}
+	left = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(left), "的"))
+	switch {
+	case cond.Query == "":
+		cond.Query = left
+	case left != "":
+		return nil, fmt.Errorf("无法识别提醒条件中的「%s」", left)
+	}
+	if cond.Query == "" {
+		return nil, errors.New("缺少证券代码或名称，例如: 茅台跌破1500")
+	}
+	cond.Threshold = threshold
+
+	switch cond.Metric {
+	case MetricPrice:
+		if unit == "%" {
+			return nil, errors.New("价格提醒不能使用百分比，按涨跌幅提醒请写成「茅台涨幅超过5%」")
+		}
+		if threshold <= 0 {
+			return nil, errors.New("价格必须大于 0")
+		}
+	case MetricRSI:
+		if cond.Period < minRSIPeriod || cond.Period > maxRSIPeriod {
+			return nil, fmt.Errorf("RSI 周期必须在 %d 到 %d 之间", minRSIPeriod, maxRSIPeriod)
+		}
+		if threshold <= 0 || threshold >= 100 {
+			return nil, errors.New("RSI 的阈值必须在 0 到 100 之间")
+		}
+	}
+	return cond, nil
+}
+
+// normalize 全角字符转为半角，统一比较符号
+func normalize(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r == '　':
+			return ' '
+		case r >= '！' && r <= '～': // 全角字符
+			return r - ('！' - '!')
+		}
+		return r
+	}, s)
+	return strings.NewReplacer("≥", ">=", "≤", "<=", "＞", ">", "＜", "<").Replace(s)
+}
+
+// Describe 条件的中文描述，例如「价格 ≤ 1500」「涨跌幅 ≥ +5%」「RSI(14) ≥ 70」
+func Describe(metric string, period int, op string, threshold float64) string {
+	sign := "≥"
+	if op == OpBelow {
+		sign = "≤"
+	}
+	return fmt.Sprintf("%s %s %s", metricName(metric, period), sign, formatValue(metric, threshold))
+}
+
+// metricName 指标的名称
+func metricName(metric string, period int) string {
+	switch metric {
+	case MetricChange:
+		return "涨跌幅"
+	case MetricRSI:
+		return fmt.Sprintf("RSI(%d)", period)
+	default:
+		return "价格"
+	}
+}
+
+// formatValue 按指标格式化数值
+func formatValue(metric string, v float64) string {
+	switch metric {
+	case MetricChange:
+		return strconv.FormatFloat(v, 'f', -1, 64) + "%"
+	case MetricRSI:
+		return strconv.FormatFloat(math.Round(v*10)/10, 'f', -1, 64)
+	default:
+		return strconv.FormatFloat(math.Round(v*1000)/1000, 'f', -1, 64)
+	}
+}
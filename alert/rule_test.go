@@ -0,0 +1,62 @@
+package alert
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want Condition
+	}{
+		{"中文跌破", "茅台跌破1500", Condition{Query: "茅台", Metric: MetricPrice, Op: OpBelow, Threshold: 1500}},
+		{"代码突破并重复", "600519 突破 1800 重复", Condition{Query: "600519", Metric: MetricPrice, Op: OpAbove, Threshold: 1800, Repeat: true}},
+		{"英文比较符", "AAPL > 200", Condition{Query: "AAPL", Metric: MetricPrice, Op: OpAbove, Threshold: 200}},
+		{"全角符号和 repeat", "AAPL ≥ 200.5 repeat", Condition{Query: "AAPL", Metric: MetricPrice, Op: OpAbove, Threshold: 200.5, Repeat: true}},
+		{"语气词、单位和全角数字", "请在茅台股价跌到１５００元时提醒我", Condition{Query: "茅台", Metric: MetricPrice, Op: OpBelow, Threshold: 1500}},
+		{"只提醒一次", "茅台跌破1500 只提醒一次", Condition{Query: "茅台", Metric: MetricPrice, Op: OpBelow, Threshold: 1500}},
+		{"涨幅", "腾讯涨幅超过5%", Condition{Query: "腾讯", Metric: MetricChange, Op: OpAbove, Threshold: 5}},
+		{"跌幅转换为负的涨跌幅", "茅台跌幅超过 3%", Condition{Query: "茅台", Metric: MetricChange, Op: OpBelow, Threshold: -3}},
+		{"涨跌幅为负数", "茅台的涨跌幅低于-2%", Condition{Query: "茅台", Metric: MetricChange, Op: OpBelow, Threshold: -2}},
+		{"RSI 默认周期", "茅台 RSI 低于 30", Condition{Query: "茅台", Metric: MetricRSI, Period: 14, Op: OpBelow, Threshold: 30}},
+		{"RSI 括号周期", "QQQ RSI(14) > 70", Condition{Query: "QQQ", Metric: MetricRSI, Period: 14, Op: OpAbove, Threshold: 70}},
+		{"RSI 紧跟周期并每次提醒", "QQQ rsi6 < 20 每次提醒", Condition{Query: "QQQ", Metric: MetricRSI, Period: 6, Op: OpBelow, Threshold: 20, Repeat: true}},
+		{"英文 on 写法", "RSI(6) > 80 on QQQ", Condition{Query: "QQQ", Metric: MetricRSI, Period: 6, Op: OpAbove, Threshold: 80}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.text)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.text, err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.text, *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+	}{
+		{"不是提醒条件", "你好"},
+		{"缺少证券", "跌破1500"},
+		{"价格使用百分比", "茅台跌破5%"},
+		{"价格不大于 0", "茅台跌破0"},
+		{"RSI 周期太小", "QQQ RSI(1) > 70"},
+		{"RSI 周期太大", "QQQ RSI(101) > 70"},
+		{"RSI 阈值超出范围", "QQQ RSI > 100"},
+		{"on 写法前面还有证券", "茅台 RSI > 70 on QQQ"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := Parse(tt.text); err == nil {
+				t.Errorf("Parse(%q) = %+v, want error", tt.text, *got)
+			}
+		})
+	}
+}
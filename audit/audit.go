@@ -0,0 +1,140 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os/user"
+
+	"fin_bot/storage"
+)
+
+// 审计日志的操作类型
+const (
+	ActionMessageSend  = "message.send"  // 发送消息
+	ActionMessageReply = "message.reply" // 回复消息
+	ActionMessagePatch = "message.patch" // 更新已发送的消息卡片
+	ActionCommand      = "command."      // 管理命令，后接命令名，例如 command.ban
+	ActionHTTP         = "http."         // 修改数据的 HTTP 管理接口，后接方法，例如 http.POST
+	ActionConfigReload = "config.reload" // 配置热加载
+	ActionDataExport   = "data.export"   // 导出数据（消息、审计日志、数据库备份）
+	ActionDataRestore  = "data.restore"  // 从备份恢复数据库
+	ActionDataDelete   = "data.delete"   // 删除数据
+)
+
+// 触发操作的渠道
+const (
+	ViaCommand  = "command"  // 聊天命令或对用户消息的回复
+	ViaHTTP     = "http"     // HTTP 管理接口
+	ViaSchedule = "schedule" // 定时任务
+	ViaCLI      = "cli"      // 命令行子命令
+	ViaSystem   = "system"   // 后台任务
+)
+
+// Actor 触发操作的人和渠道，通过 context 传递给实际执行操作的组件
+type Actor struct {
+	ID        string // 用户 open_id、http:<IP>、cli:<用户名>、schedule:<任务ID> 或 system
+	Via       string // Via* 常量
+	TenantKey string // 操作所属租户（发送消息时用于确定审计日志的归属）
+}
+
+type actorKey struct{}
+
+// WithActor 在 context 中记录操作人
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// WithTenant 设置 context 中操作人所属的租户
+func WithTenant(ctx context.Context, tenantKey string) context.Context {
+	actor := ActorFrom(ctx)
+	actor.TenantKey = tenantKey
+	return WithActor(ctx, actor)
+}
+
+// ActorFrom 获取 context 中的操作人，未设置时为 system
+func ActorFrom(ctx context.Context) Actor {
+	if actor, ok := ctx.Value(actorKey{}).(Actor); ok {
+		return actor
+	}
+	return Actor{ID: "system", Via: ViaSystem}
+}
+
+// CLIActor 命令行子命令的操作人（当前系统用户）
+func CLIActor() Actor {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	return Actor{ID: "cli:" + name, Via: ViaCLI}
+}
+
+// Logger 写入审计日志，写入失败只记录运行日志，不影响被审计的操作
+// 为 nil 时所有方法都不做任何事，便于在回放等场景中关闭审计
+type Logger struct {
+	store *storage.Storage
+}
+
+// NewLogger 创建审计日志
+func NewLogger(store *storage.Storage) *Logger {
+	return &Logger{store: store}
+}
+
+// Record 以 context 中的操作人写入一条审计日志
+func (l *Logger) Record(ctx context.Context, scope storage.Scope, action, target, detail string) {
+	if l == nil {
+		return
+	}
+	actor := ActorFrom(ctx)
+	entry := &storage.AuditEntry{
+		AppID:     scope.AppID,
+		TenantKey: scope.TenantKey,
+		Actor:     actor.ID,
+		Action:    action,
+		Target:    target,
+		Detail:    detail,
+	}
+	// 使用独立的 context，调用方取消（例如 HTTP 请求结束）时审计日志仍然写入
+	if err := l.store.AppendAudit(context.WithoutCancel(ctx), entry); err != nil {
+		log.Printf("[audit] 写入审计日志失败: action=%s, actor=%s, target=%s, error=%v", action, actor.ID, target, err)
+	}
+}
+
+// Send 记录一次发送或回复：操作人、渠道、目标和内容的 SHA-256（不保存内容本身）
+// replyTo 不为空时为回复消息，否则发送到 receiveIDType:receiveID；租户取 context 中操作人的租户
+func (l *Logger) Send(ctx context.Context, appID, receiveIDType, receiveID, replyTo, msgType, content string, err error) {
+	if l == nil {
+		return
+	}
+	action, target := ActionMessageSend, receiveIDType+":"+receiveID
+	if replyTo != "" {
+		action, target = ActionMessageReply, "message_id:"+replyTo
+	}
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	detail := fmt.Sprintf("via=%s msg_type=%s sha256=%s result=%s", ActorFrom(ctx).Via, msgType, ContentHash(content), result)
+	l.Record(ctx, storage.Scope{AppID: appID, TenantKey: ActorFrom(ctx).TenantKey}, action, target, detail)
+}
+
+// Patch 记录一次消息卡片更新：操作人、渠道、被更新的消息和新内容的 SHA-256
+func (l *Logger) Patch(ctx context.Context, appID, messageID, content string, err error) {
+	if l == nil {
+		return
+	}
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	detail := fmt.Sprintf("via=%s msg_type=interactive sha256=%s result=%s", ActorFrom(ctx).Via, ContentHash(content), result)
+	l.Record(ctx, storage.Scope{AppID: appID, TenantKey: ActorFrom(ctx).TenantKey}, ActionMessagePatch, "message_id:"+messageID, detail)
+}
+
+// ContentHash 消息内容的 SHA-256（十六进制），用于核对发送的内容而不在审计日志中保存原文
+func ContentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"fin_bot/audit"
+	"fin_bot/storage"
+)
+
+// auditCSVHeader csv 格式的表头，与 storage.AuditEntry 的 JSON 字段一致
+var auditCSVHeader = []string{"id", "app_id", "tenant_key", "actor", "action", "target", "detail", "created_at", "prev_hash", "hash"}
+
+// runAuditExportCommand 按应用、操作类型、操作人和时间导出审计日志（按时间顺序）
+func runAuditExportCommand(configPath string, args []string) int {
+	fs := newCLIFlags("audit export", "[-app 应用 [-tenant 租户]] [-action 前缀] [-actor 操作人] [-since 时间] [-until 时间] [-format jsonl|csv] [-o 文件] [-db 数据库]")
+	appName := fs.String("app", "", "只导出指定应用的记录（应用名称或 App ID，默认导出所有记录）")
+	tenant := fs.String("tenant", "", "租户 tenant_key（默认使用应用配置的 tenant_key，需要同时指定 -app）")
+	action := fs.String("action", "", "操作类型前缀，例如 role. 或 message.send")
+	actor := fs.String("actor", "", "操作人，例如 ou_xxx、cli:root")
+	since := fs.String("since", "", "起始时间: RFC3339、2006-01-02 或相对时长（例如 72h）")
+	until := fs.String("until", "", "截止时间（不含）: RFC3339、2006-01-02 或相对时长")
+	format := fs.String("format", "jsonl", "输出格式: jsonl 或 csv")
+	output := fs.String("o", "", "输出文件（默认输出到标准输出）")
+	dbPath := fs.String("db", "", "数据库文件路径（默认使用配置中的 database.path）")
+	fs.logFlag()
+	if code := fs.parse(args); code != exitOK {
+		return code
+	}
+	if *format != "jsonl" && *format != "csv" {
+		return fs.usageError("无效的输出格式: %s", *format)
+	}
+	if *tenant != "" && *appName == "" {
+		return fs.usageError("-tenant 需要同时指定 -app")
+	}
+
+	filter := storage.AuditFilter{Action: *action, Actor: *actor}
+	now := time.Now()
+	for _, f := range []struct {
+		value string
+		dest  *time.Time
+	}{{*since, &filter.Since}, {*until, &filter.Until}} {
+		if f.value == "" {
+			continue
+		}
+		t, err := parseSince(f.value, now)
+		if err != nil {
+			return fs.usageError("%v", err)
+		}
+		*f.dest = t
+	}
+
+	cfg := loadConfig(configPath, false)
+	if cfg == nil {
+		return exitError
+	}
+	var scope storage.Scope
+	if *appName != "" {
+		app, err := selectApp(cfg, *appName)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitUsage
+		}
+		scope = storage.Scope{AppID: app.AppID, TenantKey: app.TenantKey}
+		if *tenant != "" {
+			scope.TenantKey = *tenant
+		}
+		filter.Scope = &scope
+	}
+
+	store, err := openStorage(cfg, *dbPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "打开数据库失败: %v\n", err)
+		return exitError
+	}
+	defer store.Close()
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.OpenFile(*output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "创建输出文件失败: %v\n", err)
+			return exitError
+		}
+		defer f.Close()
+		out = f
+	}
+
+	ctx := audit.WithActor(context.Background(), audit.CLIActor())
+	count, err := exportAudit(ctx, store, filter, *format, out)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "导出审计日志失败: %v\n", err)
+		return exitError
+	}
+	// 导出审计日志本身也是一次数据导出，在导出完成后记录，不会出现在本次导出的结果中
+	audit.NewLogger(store).Record(ctx, scope, audit.ActionDataExport, "audit_log",
+		fmt.Sprintf("action=%q actor=%q since=%q until=%q format=%s output=%q count=%d",
+			*action, *actor, *since, *until, *format, *output, count))
+	fmt.Fprintf(os.Stderr, "共导出 %d 条审计日志\n", count)
+	return exitOK
+}
+
+// runAuditVerifyCommand 校验审计日志的哈希链，输出记录数和链尾哈希
+func runAuditVerifyCommand(configPath string, args []string) int {
+	fs := newCLIFlags("audit verify", "[-db 数据库]")
+	dbPath := fs.String("db", "", "数据库文件路径（默认使用配置中的 database.path）")
+	fs.logFlag()
+	if code := fs.parse(args); code != exitOK {
+		return code
+	}
+
+	cfg := loadConfig(configPath, false)
+	if cfg == nil {
+		return exitError
+	}
+	store, err := openStorage(cfg, *dbPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "打开数据库失败: %v\n", err)
+		return exitError
+	}
+	defer store.Close()
+
+	count, head, err := store.VerifyAudit(context.Background())
+	var chainErr *storage.AuditChainError
+	if errors.As(err, &chainErr) {
+		fmt.Fprintf(os.Stderr, "%v（此前 %d 条记录校验通过）\n", err, count)
+		return exitError
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "校验审计日志失败: %v\n", err)
+		return exitError
+	}
+	fmt.Printf("审计日志哈希链校验通过: %d 条记录\n链尾哈希: %s\n", count, head)
+	return exitOK
+}
+
+// exportAudit 将审计日志按指定格式写入 out，返回导出条数
+func exportAudit(ctx context.Context, store *storage.Storage, filter storage.AuditFilter, format string, out io.Writer) (int, error) {
+	w := bufio.NewWriter(out)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	cw := csv.NewWriter(w)
+	if format == "csv" {
+		if err := cw.Write(auditCSVHeader); err != nil {
+			return 0, err
+		}
+	}
+
+	count := 0
+	err := store.ExportAudit(ctx, filter, func(e *storage.AuditEntry) error {
+		count++
+		if format == "csv" {
+			return cw.Write([]string{
+				strconv.FormatInt(e.ID, 10), e.AppID, e.TenantKey, e.Actor, e.Action, e.Target, e.Detail,
+				e.CreatedAt.Format(time.RFC3339Nano), e.PrevHash, e.Hash,
+			})
+		}
+		return enc.Encode(e)
+	})
+	if err != nil {
+		return count, err
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return count, err
+	}
+	return count, w.Flush()
+}
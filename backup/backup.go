@@ -0,0 +1,581 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"fin_bot/audit"
+	"fin_bot/metrics"
+	"fin_bot/storage"
+
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	// filePrefix 备份文件名前缀，文件名格式为 fin_bot-20060102T150405.000Z.db[.gz][.enc]
+	filePrefix = "fin_bot-"
+	timeLayout = "20060102T150405.000Z"
+	// legacyTimeLayout 只精确到秒的旧文件名，仍然可以列出、校验和清理
+	legacyTimeLayout = "20060102T150405Z"
+)
+
+// sqliteMagic SQLite 数据库文件头
+var sqliteMagic = []byte("SQLite format 3\x00")
+
+// ErrNotFound 备份不存在
+var ErrNotFound = errors.New("备份不存在")
+
+// Settings 可以在运行中修改的备份设置
+type Settings struct {
+	Schedule string // cron 表达式，为空表示不定时备份
+	Keep     int    // 备份目录中保留的数量，0 表示不清理
+	Compress bool
+	Encrypt  bool
+}
+
+// Info 备份文件信息
+type Info struct {
+	Name       string    `json:"name"`
+	Size       int64     `json:"size"`
+	CreatedAt  time.Time `json:"created_at"`
+	Compressed bool      `json:"compressed"`
+	Encrypted  bool      `json:"encrypted"`
+	path       string
+}
+
+// Manager 数据库备份管理
+// 备份使用 VACUUM INTO 生成一致性快照，服务运行中也可以执行；快照按设置压缩和加密后写入备份目录，
+// 定时备份完成后按保留数量清理最旧的备份
+type Manager struct {
+	storage *storage.Storage
+	dir     string
+	secret  []byte
+	audit   *audit.Logger // 备份视为数据导出，生成和清理都写入审计日志
+
+	settingsMu sync.RWMutex // 保护 settings，配置热加载时会被修改
+	settings   Settings
+
+	runMu sync.Mutex // 串行化备份，避免同时生成多个快照
+
+	cronMu  sync.Mutex
+	cron    *cron.Cron
+	entryID cron.EntryID
+}
+
+// NewManager 创建备份管理器
+// secret: 加密备份使用的主密钥，为空时不能开启加密
+func NewManager(store *storage.Storage, dir string, secret []byte, settings Settings) *Manager {
+	return &Manager{
+		storage:  store,
+		dir:      dir,
+		secret:   secret,
+		audit:    audit.NewLogger(store),
+		settings: settings,
+	}
+}
+
+// Dir 获取备份目录
+func (m *Manager) Dir() string {
+	return m.dir
+}
+
+// SetSettings 修改备份设置，定时备份运行中时按新的 cron 表达式重新调度
+func (m *Manager) SetSettings(settings Settings) {
+	m.settingsMu.Lock()
+	changed := m.settings.Schedule != settings.Schedule
+	m.settings = settings
+	m.settingsMu.Unlock()
+
+	if changed {
+		m.cronMu.Lock()
+		defer m.cronMu.Unlock()
+		if m.cron != nil {
+			if err := m.reschedule(settings.Schedule); err != nil {
+				log.Printf("[backup] 更新定时备份失败: %v", err)
+			}
+		}
+	}
+}
+
+// currentSettings 获取当前的备份设置
+func (m *Manager) currentSettings() Settings {
+	m.settingsMu.RLock()
+	defer m.settingsMu.RUnlock()
+	return m.settings
+}
+
+// Start 启动定时备份
+func (m *Manager) Start(ctx context.Context) error {
+	m.cronMu.Lock()
+	defer m.cronMu.Unlock()
+
+	m.cron = cron.New()
+	if err := m.reschedule(m.currentSettings().Schedule); err != nil {
+		return err
+	}
+	m.cron.Start()
+	return nil
+}
+
+// Stop 停止定时备份，等待进行中的备份完成
+func (m *Manager) Stop(ctx context.Context) error {
+	m.cronMu.Lock()
+	c := m.cron
+	m.cron = nil
+	m.cronMu.Unlock()
+	if c == nil {
+		return nil
+	}
+
+	select {
+	case <-c.Stop().Done():
+		log.Println("[backup] 定时备份已停止")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reschedule 替换定时备份任务，调用方需持有 cronMu
+func (m *Manager) reschedule(schedule string) error {
+	if m.entryID != 0 {
+		m.cron.Remove(m.entryID)
+		m.entryID = 0
+	}
+	if schedule == "" {
+		log.Println("[backup] 未配置定时备份")
+		return nil
+	}
+
+	id, err := m.cron.AddFunc(schedule, m.scheduled)
+	if err != nil {
+		return fmt.Errorf("无效的定时备份表达式 %q: %w", schedule, err)
+	}
+	m.entryID = id
+	log.Printf("[backup] 定时备份已启用: schedule=%q, dir=%s", schedule, m.dir)
+	return nil
+}
+
+// scheduled 定时备份任务
+func (m *Manager) scheduled() {
+	info, err := m.Create(context.Background())
+	if err != nil {
+		log.Printf("[backup] 定时备份失败: %v", err)
+		return
+	}
+	log.Printf("[backup] 定时备份完成: %s (%d 字节)", info.Name, info.Size)
+}
+
+// Create 在备份目录中生成一个备份，并按保留数量清理旧备份
+// 文件名精确到毫秒，同一毫秒内已有备份时顺延，保证 API 触发和定时备份不会重名
+func (m *Manager) Create(ctx context.Context) (*Info, error) {
+	m.runMu.Lock()
+	defer m.runMu.Unlock()
+
+	settings := m.currentSettings()
+	t := time.Now()
+	path := filepath.Join(m.dir, fileName(t, settings))
+	for exists(path) {
+		t = t.Add(time.Millisecond)
+		path = filepath.Join(m.dir, fileName(t, settings))
+	}
+	info, err := m.createFile(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if settings.Keep > 0 {
+		if err := m.rotate(ctx, settings.Keep); err != nil {
+			log.Printf("[backup] 清理旧备份失败: %v", err)
+		}
+	}
+	return info, nil
+}
+
+// CreateFile 按当前设置压缩和加密，将备份写入指定路径（不会覆盖已存在的文件，也不参与清理）
+func (m *Manager) CreateFile(ctx context.Context, path string) (*Info, error) {
+	m.runMu.Lock()
+	defer m.runMu.Unlock()
+	return m.createFile(ctx, path)
+}
+
+// createFile 生成备份，调用方需要持有 runMu
+func (m *Manager) createFile(ctx context.Context, path string) (info *Info, err error) {
+	start := time.Now()
+	defer func() {
+		if err != nil {
+			metrics.Backups.WithLabelValues("error").Inc()
+			return
+		}
+		metrics.Backups.WithLabelValues("success").Inc()
+		metrics.BackupLastSuccess.SetToCurrentTime()
+		log.Printf("[backup] 已生成备份: %s, 耗时 %s", path, time.Since(start).Round(time.Millisecond))
+		m.audit.Record(ctx, storage.Scope{}, audit.ActionDataExport, "backup:"+path,
+			fmt.Sprintf("size=%d compressed=%t encrypted=%t", info.Size, info.Compressed, info.Encrypted))
+	}()
+
+	settings := m.currentSettings()
+	var secret []byte
+	if settings.Encrypt {
+		if len(m.secret) == 0 {
+			return nil, errors.New("加密备份需要配置 security.secret_key")
+		}
+		secret = m.secret
+	}
+	if exists(path) {
+		return nil, fmt.Errorf("备份文件已存在: %s", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("创建备份目录失败: %w", err)
+	}
+
+	// 先生成未压缩的快照，再编码为最终文件；写入过程中使用临时文件名，避免留下不完整的备份
+	snapshot := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".snapshot")
+	os.Remove(snapshot)
+	if err := m.storage.BackupTo(ctx, snapshot); err != nil {
+		return nil, err
+	}
+	defer os.Remove(snapshot)
+
+	if !settings.Compress && secret == nil {
+		if err := os.Rename(snapshot, path); err != nil {
+			return nil, fmt.Errorf("保存备份失败: %w", err)
+		}
+		if err := os.Chmod(path, 0600); err != nil {
+			return nil, err
+		}
+	} else if err := encodeFile(snapshot, path, settings.Compress, secret); err != nil {
+		return nil, err
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Info{
+		Name:       filepath.Base(path),
+		Size:       stat.Size(),
+		CreatedAt:  start.UTC(),
+		Compressed: settings.Compress,
+		Encrypted:  secret != nil,
+		path:       path,
+	}, nil
+}
+
+// List 列出备份目录中的备份（按时间倒序）
+func (m *Manager) List() ([]*Info, error) {
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []*Info{}, nil
+		}
+		return nil, fmt.Errorf("读取备份目录失败: %w", err)
+	}
+
+	backups := []*Info{}
+	for _, entry := range entries {
+		info, ok := parseFileName(entry.Name())
+		if !ok || entry.IsDir() {
+			continue
+		}
+		stat, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		info.Size = stat.Size()
+		info.path = filepath.Join(m.dir, entry.Name())
+		backups = append(backups, info)
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+	return backups, nil
+}
+
+// Get 按文件名获取备份目录中的备份
+func (m *Manager) Get(name string) (*Info, error) {
+	backups, err := m.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range backups {
+		if info.Name == name {
+			return info, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Verify 校验备份目录中的备份能否解密、解压并通过完整性检查，返回备份的数据库结构版本
+func (m *Manager) Verify(ctx context.Context, name string) (int, error) {
+	info, err := m.Get(name)
+	if err != nil {
+		return 0, err
+	}
+	return Verify(ctx, info.path, m.secret)
+}
+
+// rotate 只保留最新的 keep 个备份
+func (m *Manager) rotate(ctx context.Context, keep int) error {
+	backups, err := m.List()
+	if err != nil {
+		return err
+	}
+	for i := keep; i < len(backups); i++ {
+		if err := os.Remove(backups[i].path); err != nil {
+			return err
+		}
+		log.Printf("[backup] 已删除旧备份: %s", backups[i].Name)
+		m.audit.Record(ctx, storage.Scope{}, audit.ActionDataDelete, "backup:"+backups[i].path, "reason=rotate")
+	}
+	return nil
+}
+
+// Verify 校验备份文件（可以是任意路径）能否解密、解压并通过完整性检查，返回备份的数据库结构版本
+func Verify(ctx context.Context, path string, secret []byte) (int, error) {
+	tmp, err := os.CreateTemp("", "fin_bot_verify_*.db")
+	if err != nil {
+		return 0, err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	return restoreTo(ctx, path, tmp.Name(), secret)
+}
+
+// Restore 将备份恢复到 dest：先解码到临时文件并通过完整性检查，再替换目标文件
+// dest 已存在时重命名保留，返回保留的文件路径（不存在时为空）。恢复前必须停止使用该数据库的服务
+func Restore(ctx context.Context, src, dest string, secret []byte) (previous string, err error) {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return "", fmt.Errorf("创建数据库目录失败: %w", err)
+	}
+	tmp := dest + ".restoring"
+	defer os.Remove(tmp)
+	if _, err := restoreTo(ctx, src, tmp, secret); err != nil {
+		return "", err
+	}
+
+	if _, err := os.Stat(dest); err == nil {
+		previous = dest + ".pre-restore-" + time.Now().UTC().Format(timeLayout)
+		if err := os.Rename(dest, previous); err != nil {
+			return "", fmt.Errorf("保留当前数据库失败: %w", err)
+		}
+	}
+	// 服务异常退出时会留下 WAL 文件，留在原处会被应用到恢复后的数据库上，和原数据库一起保留
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if _, err := os.Stat(dest + suffix); err != nil {
+			continue
+		}
+		if previous != "" {
+			err = os.Rename(dest+suffix, previous+suffix)
+		} else {
+			err = os.Remove(dest + suffix)
+		}
+		if err != nil {
+			return previous, fmt.Errorf("移走数据库的 %s 文件失败: %w", suffix, err)
+		}
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		return previous, fmt.Errorf("替换数据库失败: %w", err)
+	}
+	return previous, nil
+}
+
+// restoreTo 将备份解码到 dest 并检查完整性和结构版本
+func restoreTo(ctx context.Context, src, dest string, secret []byte) (int, error) {
+	if err := decodeFile(src, dest, secret); err != nil {
+		return 0, err
+	}
+
+	store, err := storage.Open(dest)
+	if err != nil {
+		return 0, err
+	}
+	defer store.Close()
+
+	if err := store.IntegrityCheck(ctx); err != nil {
+		return 0, err
+	}
+	version, err := store.SchemaVersion(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if version > storage.LatestSchemaVersion() {
+		return 0, fmt.Errorf("备份的数据库版本 %d 高于当前程序支持的版本 %d", version, storage.LatestSchemaVersion())
+	}
+	return version, nil
+}
+
+// encodeFile 将快照压缩（可选）后加密（可选）写入 dest
+func encodeFile(src, dest string, compress bool, secret []byte) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := dest + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("创建备份文件失败: %w", err)
+	}
+	defer os.Remove(tmp)
+	defer out.Close()
+
+	var w io.Writer = out
+	var closers []io.Closer // 按写入链从外到内关闭
+	if secret != nil {
+		ew, err := newEncryptWriter(w, secret)
+		if err != nil {
+			return err
+		}
+		w = ew
+		closers = append([]io.Closer{ew}, closers...)
+	}
+	if compress {
+		gz := gzip.NewWriter(w)
+		w = gz
+		closers = append([]io.Closer{gz}, closers...)
+	}
+
+	if _, err := io.Copy(w, in); err != nil {
+		return fmt.Errorf("写入备份失败: %w", err)
+	}
+	for _, c := range closers {
+		if err := c.Close(); err != nil {
+			return fmt.Errorf("写入备份失败: %w", err)
+		}
+	}
+	if err := out.Sync(); err != nil {
+		return fmt.Errorf("写入备份失败: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("写入备份失败: %w", err)
+	}
+	return os.Rename(tmp, dest)
+}
+
+// decodeFile 根据文件头识别加密和压缩格式，将备份还原为数据库文件写入 dest
+func decodeFile(src, dest string, secret []byte) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("打开备份失败: %w", err)
+	}
+	defer in.Close()
+
+	r := bufio.NewReader(in)
+	header, err := peek(r, len(encryptMagic))
+	if err != nil {
+		return err
+	}
+	if isEncrypted(header) {
+		if len(secret) == 0 {
+			return errors.New("备份已加密，需要配置 security.secret_key")
+		}
+		dr, err := newDecryptReader(r, secret)
+		if err != nil {
+			return err
+		}
+		r = bufio.NewReader(dr)
+	}
+	var plain io.Reader = r
+	if header, err = peek(r, 2); err != nil {
+		return err
+	}
+	if bytes.Equal(header, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("解压备份失败: %w", err)
+		}
+		defer gz.Close()
+		plain = gz
+	}
+
+	pr := bufio.NewReader(plain)
+	if header, err = peek(pr, len(sqliteMagic)); err != nil {
+		return fmt.Errorf("解压备份失败: %w", err)
+	}
+	if !bytes.Equal(header, sqliteMagic) {
+		return errors.New("不是有效的数据库备份")
+	}
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("创建数据库文件失败: %w", err)
+	}
+	defer out.Close()
+	if _, err := io.Copy(out, pr); err != nil {
+		return fmt.Errorf("还原备份失败: %w", err)
+	}
+	return out.Close()
+}
+
+// peek 读取文件头，文件长度不足时返回已有的内容
+func peek(r *bufio.Reader, n int) ([]byte, error) {
+	header, err := r.Peek(n)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return header, nil
+}
+
+// fileName 生成备份文件名
+func fileName(t time.Time, settings Settings) string {
+	name := filePrefix + t.UTC().Format(timeLayout) + ".db"
+	if settings.Compress {
+		name += ".gz"
+	}
+	if settings.Encrypt {
+		name += ".enc"
+	}
+	return name
+}
+
+// exists 判断文件是否存在
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// parseFileName 解析备份文件名，不是备份文件时返回 false
+func parseFileName(name string) (*Info, bool) {
+	rest, ok := strings.CutPrefix(name, filePrefix)
+	if !ok {
+		return nil, false
+	}
+	stamp, ext, ok := strings.Cut(rest, "Z.")
+	if !ok {
+		return nil, false
+	}
+	t, err := time.Parse(timeLayout, stamp+"Z")
+	if err != nil {
+		if t, err = time.Parse(legacyTimeLayout, stamp+"Z"); err != nil {
+			return nil, false
+		}
+	}
+
+	info := &Info{Name: name, CreatedAt: t}
+	switch ext {
+	case "db":
+	case "db.gz":
+		info.Compressed = true
+	case "db.enc":
+		info.Encrypted = true
+	case "db.gz.enc":
+		info.Compressed, info.Encrypted = true, true
+	default:
+		return nil, false
+	}
+	return info, true
+}
@@ -0,0 +1,197 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"fin_bot/storage"
+)
+
+func TestFileNameRoundTrip(t *testing.T) {
+	at := time.Date(2026, 3, 1, 12, 30, 45, 123456789, time.UTC)
+	tests := []struct {
+		settings Settings
+		want     string
+	}{
+		{Settings{}, "fin_bot-20260301T123045.123Z.db"},
+		{Settings{Compress: true}, "fin_bot-20260301T123045.123Z.db.gz"},
+		{Settings{Encrypt: true}, "fin_bot-20260301T123045.123Z.db.enc"},
+		{Settings{Compress: true, Encrypt: true}, "fin_bot-20260301T123045.123Z.db.gz.enc"},
+	}
+	for _, tt := range tests {
+		name := fileName(at, tt.settings)
+		if name != tt.want {
+			t.Errorf("fileName = %q, want %q", name, tt.want)
+		}
+		info, ok := parseFileName(name)
+		if !ok {
+			t.Fatalf("parseFileName(%q) failed", name)
+		}
+		if !info.CreatedAt.Equal(at.Truncate(time.Millisecond)) || info.Compressed != tt.settings.Compress || info.Encrypted != tt.settings.Encrypt {
+			t.Errorf("parseFileName(%q) = %+v", name, info)
+		}
+	}
+}
+
+func TestParseFileName(t *testing.T) {
+	tests := []struct {
+		name   string
+		ok     bool
+		wantAt time.Time
+	}{
+		{"fin_bot-20260301T123045Z.db.gz", true, time.Date(2026, 3, 1, 12, 30, 45, 0, time.UTC)},
+		{"fin_bot-20260301T123045.007Z.db", true, time.Date(2026, 3, 1, 12, 30, 45, 7e6, time.UTC)},
+		{"fin_bot-20260301T123045.007Z.txt", false, time.Time{}},
+		{".fin_bot-20260301T123045.007Z.db.snapshot", false, time.Time{}},
+		{"other-20260301T123045Z.db", false, time.Time{}},
+		{"fin_bot-latest.db", false, time.Time{}},
+	}
+	for _, tt := range tests {
+		info, ok := parseFileName(tt.name)
+		if ok != tt.ok {
+			t.Errorf("parseFileName(%q) ok = %v, want %v", tt.name, ok, tt.ok)
+			continue
+		}
+		if ok && !info.CreatedAt.Equal(tt.wantAt) {
+			t.Errorf("parseFileName(%q) created_at = %s, want %s", tt.name, info.CreatedAt, tt.wantAt)
+		}
+	}
+}
+
+// newTestManager 创建使用临时数据库和备份目录的备份管理器
+func newTestManager(t *testing.T, settings Settings) *Manager {
+	t.Helper()
+	dir := t.TempDir()
+	store, err := storage.NewStorage(filepath.Join(dir, "bot.db"))
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return NewManager(store, filepath.Join(dir, "backups"), []byte("test-secret"), settings)
+}
+
+func TestCreateUniqueNamesAndRotate(t *testing.T) {
+	m := newTestManager(t, Settings{Keep: 2, Compress: true, Encrypt: true})
+	ctx := context.Background()
+
+	// 连续备份不会因为文件名相同而失败，超出保留数量时删除最旧的
+	var names []string
+	for i := 0; i < 4; i++ {
+		info, err := m.Create(ctx)
+		if err != nil {
+			t.Fatalf("Create #%d: %v", i, err)
+		}
+		names = append(names, info.Name)
+	}
+
+	backups, err := m.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(backups) != 2 || backups[0].Name != names[3] || backups[1].Name != names[2] {
+		t.Fatalf("backups after rotate = %v, want newest two of %v", backupNames(backups), names)
+	}
+
+	version, err := m.Verify(ctx, names[3])
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if version != storage.LatestSchemaVersion() {
+		t.Errorf("schema version = %d, want %d", version, storage.LatestSchemaVersion())
+	}
+}
+
+func TestVerifyDamagedBackup(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings Settings
+		damage   func([]byte) []byte
+	}{
+		{"压缩后截断", Settings{Compress: true}, func(b []byte) []byte { return b[:len(b)/2] }},
+		{"加密后截断", Settings{Compress: true, Encrypt: true}, func(b []byte) []byte { return b[:len(b)-10] }},
+		{"加密后篡改", Settings{Encrypt: true}, func(b []byte) []byte { b[len(b)/2] ^= 0xff; return b }},
+		{"不是数据库", Settings{}, func(b []byte) []byte { return []byte("not a database") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t, tt.settings)
+			ctx := context.Background()
+			info, err := m.Create(ctx)
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			path := filepath.Join(m.Dir(), info.Name)
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, tt.damage(data), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := m.Verify(ctx, info.Name); err == nil {
+				t.Error("Verify succeeded on damaged backup")
+			}
+			if tt.settings.Encrypt {
+				if _, err := m.Verify(ctx, info.Name); !errors.Is(err, ErrDecrypt) {
+					t.Errorf("err = %v, want ErrDecrypt", err)
+				}
+			}
+		})
+	}
+}
+
+// TestRestoreMovesWAL 恢复时把原数据库留下的 -wal、-shm 文件和原数据库一起移走，不应用到恢复后的数据库
+func TestRestoreMovesWAL(t *testing.T) {
+	m := newTestManager(t, Settings{Compress: true})
+	ctx := context.Background()
+	info, err := m.Create(ctx)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	dest := filepath.Join(t.TempDir(), "restored.db")
+	for _, name := range []string{dest, dest + "-wal", dest + "-shm"} {
+		if err := os.WriteFile(name, []byte("stale "+filepath.Base(name)), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	previous, err := Restore(ctx, filepath.Join(m.Dir(), info.Name), dest, []byte("test-secret"))
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		data, err := os.ReadFile(previous + suffix)
+		if err != nil || string(data) != "stale restored.db"+suffix {
+			t.Errorf("保留的 %s = %q, %v", previous+suffix, data, err)
+		}
+		if suffix != "" {
+			if _, err := os.Stat(dest + suffix); !os.IsNotExist(err) {
+				t.Errorf("%s 应该被移走: %v", dest+suffix, err)
+			}
+		}
+	}
+
+	store, err := storage.NewStorage(dest)
+	if err != nil {
+		t.Fatalf("打开恢复后的数据库: %v", err)
+	}
+	defer store.Close()
+	if err := store.CheckWritable(ctx); err != nil {
+		t.Errorf("CheckWritable: %v", err)
+	}
+}
+
+func backupNames(backups []*Info) []string {
+	names := make([]string, len(backups))
+	for i, b := range backups {
+		names[i] = b.Name
+	}
+	return names
+}
@@ -0,0 +1,209 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// 加密备份的文件格式：
+//
+//	magic(8) | salt(16) | 分块...
+//	分块: 密文长度(4, 大端) | AES-256-GCM 密文
+//
+// 每个文件使用随机 salt 从主密钥派生独立的密钥，因此 nonce 可以直接使用分块序号；
+// nonce 的最后一个字节标记最后一个分块，防止文件被截断后仍然能通过校验
+const (
+	encryptMagic = "FBBACKUP"
+	saltSize     = 16
+	chunkSize    = 64 * 1024
+	hkdfInfo     = "fin_bot backup v1"
+)
+
+// ErrDecrypt 备份解密失败（密钥错误或文件被篡改、截断）
+var ErrDecrypt = errors.New("备份解密失败，密钥错误或文件已损坏")
+
+// deriveKey 从主密钥和 salt 派生文件密钥
+func deriveKey(secret, salt []byte) (cipher.AEAD, error) {
+	key, err := hkdf.Key(sha256.New, secret, salt, hkdfInfo, 32)
+	if err != nil {
+		return nil, fmt.Errorf("派生备份密钥失败: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce 第 n 个分块的 nonce
+func chunkNonce(n uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce, n)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// encryptWriter 分块加密写入，Close 时写入最后一个分块
+type encryptWriter struct {
+	w    io.Writer
+	aead cipher.AEAD
+	buf  []byte
+	n    uint64
+}
+
+// newEncryptWriter 写入文件头并返回加密写入器，调用方必须 Close 才能得到完整的文件
+func newEncryptWriter(w io.Writer, secret []byte) (io.WriteCloser, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := deriveKey(secret, salt)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(w, encryptMagic); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(salt); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{w: w, aead: aead, buf: make([]byte, 0, chunkSize)}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		// 缓冲区满且还有后续数据时才写出，保证最后一个分块在 Close 时写出
+		if len(e.buf) == chunkSize {
+			if err := e.flush(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(e.buf[len(e.buf):chunkSize], p)
+		e.buf = e.buf[:len(e.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (e *encryptWriter) Close() error {
+	return e.flush(true)
+}
+
+// flush 加密并写出当前缓冲区
+func (e *encryptWriter) flush(last bool) error {
+	sealed := e.aead.Seal(nil, chunkNonce(e.n, last), e.buf, nil)
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(sealed)))
+	if _, err := e.w.Write(size[:]); err != nil {
+		return err
+	}
+	if _, err := e.w.Write(sealed); err != nil {
+		return err
+	}
+	e.n++
+	e.buf = e.buf[:0]
+	return nil
+}
+
+// decryptReader 分块解密读取
+type decryptReader struct {
+	r    io.Reader
+	aead cipher.AEAD
+	n    uint64
+	next []byte // 已读取但尚未解密的下一个分块（用于判断当前分块是否为最后一个）
+	buf  []byte // 已解密尚未读取的数据
+	done bool
+}
+
+// newDecryptReader 校验文件头并返回解密读取器
+func newDecryptReader(r io.Reader, secret []byte) (io.Reader, error) {
+	header := make([]byte, len(encryptMagic)+saltSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("读取加密文件头失败: %w", err)
+	}
+	if string(header[:len(encryptMagic)]) != encryptMagic {
+		return nil, errors.New("不是加密的备份文件")
+	}
+	aead, err := deriveKey(secret, header[len(encryptMagic):])
+	if err != nil {
+		return nil, err
+	}
+	d := &decryptReader{r: r, aead: aead}
+	if d.next, err = d.readChunk(); err != nil {
+		return nil, err
+	}
+	if d.next == nil {
+		return nil, ErrDecrypt
+	}
+	return d, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.advance(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+// advance 解密下一个分块
+func (d *decryptReader) advance() error {
+	current := d.next
+	next, err := d.readChunk()
+	if err != nil {
+		return err
+	}
+	last := next == nil
+	plain, err := d.aead.Open(nil, chunkNonce(d.n, last), current, nil)
+	if err != nil {
+		return ErrDecrypt
+	}
+	d.n++
+	d.next = next
+	d.buf = plain
+	d.done = last
+	return nil
+}
+
+// readChunk 读取一个分块的密文，文件结束时返回 nil
+func (d *decryptReader) readChunk() ([]byte, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(d.r, size[:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, ErrDecrypt
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n > chunkSize+uint32(d.aead.Overhead()) {
+		return nil, ErrDecrypt
+	}
+	chunk := make([]byte, n)
+	if _, err := io.ReadFull(d.r, chunk); err != nil {
+		return nil, ErrDecrypt
+	}
+	return chunk, nil
+}
+
+// isEncrypted 判断文件头是否为加密备份
+func isEncrypted(header []byte) bool {
+	return bytes.HasPrefix(header, []byte(encryptMagic))
+}
@@ -0,0 +1,105 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+)
+
+// encryptBytes 用 encryptWriter 加密 plain
+func encryptBytes(t *testing.T, plain, secret []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := newEncryptWriter(&buf, secret)
+	if err != nil {
+		t.Fatalf("newEncryptWriter: %v", err)
+	}
+	if _, err := w.Write(plain); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// decryptBytes 用 decryptReader 解密 data
+func decryptBytes(data, secret []byte) ([]byte, error) {
+	r, err := newDecryptReader(bytes.NewReader(data), secret)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	secret := []byte("test-secret")
+	random := make([]byte, 3*chunkSize+123)
+	rand.Read(random)
+
+	tests := []struct {
+		name  string
+		plain []byte
+	}{
+		{"空文件", []byte{}},
+		{"小于一个分块", []byte("SQLite format 3\x00 hello")},
+		{"正好一个分块", bytes.Repeat([]byte{'a'}, chunkSize)},
+		{"多个分块", random},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := encryptBytes(t, tt.plain, secret)
+			if !isEncrypted(data) {
+				t.Fatal("encrypted data missing magic header")
+			}
+			got, err := decryptBytes(data, secret)
+			if err != nil {
+				t.Fatalf("decrypt: %v", err)
+			}
+			if !bytes.Equal(got, tt.plain) {
+				t.Errorf("round trip mismatch: got %d bytes, want %d", len(got), len(tt.plain))
+			}
+		})
+	}
+}
+
+func TestDecryptFailures(t *testing.T) {
+	secret := []byte("test-secret")
+	plain := make([]byte, 2*chunkSize+10)
+	rand.Read(plain)
+	data := encryptBytes(t, plain, secret)
+	header := len(encryptMagic) + saltSize
+	firstChunk := header + 4 + chunkSize + 16 // 长度 + 密文 + GCM tag
+
+	flip := func(i int) []byte {
+		out := append([]byte(nil), data...)
+		out[i] ^= 0x01
+		return out
+	}
+
+	tests := []struct {
+		name   string
+		data   []byte
+		secret []byte
+	}{
+		{"密钥错误", data, []byte("wrong-secret")},
+		{"篡改密文", flip(header + 100), secret},
+		{"篡改 salt", flip(len(encryptMagic)), secret},
+		{"截断到整块边界", data[:firstChunk], secret},
+		{"截断在分块中间", data[:len(data)-5], secret},
+		{"只有文件头", data[:header], secret},
+		{"丢弃中间分块", append(append([]byte(nil), data[:firstChunk]...), data[2*firstChunk-header:]...), secret},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decryptBytes(tt.data, tt.secret)
+			if !errors.Is(err, ErrDecrypt) {
+				t.Errorf("err = %v (got %d bytes), want ErrDecrypt", err, len(got))
+			}
+		})
+	}
+}
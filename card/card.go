@@ -0,0 +1,126 @@
+package card
+
+// 飞书消息卡片（JSON 1.0 结构）的构建工具，生成的卡片通过 command.CardReply 或 LarkService.SendCardMessage 发送
+// https://open.feishu.cn/document/common-capabilities/message-card/message-cards-content/card-structure/card-content
+
+// 标题栏颜色
+const (
+	ColorBlue      = "blue"
+	ColorTurquoise = "turquoise"
+	ColorGreen     = "green"
+	ColorOrange    = "orange"
+	ColorRed       = "red"
+	ColorGrey      = "grey"
+)
+
+// Card 消息卡片
+type Card struct {
+	Config   Config        `json:"config"`
+	Header   *Header       `json:"header,omitempty"`
+	Elements []interface{} `json:"elements"`
+}
+
+// Config 卡片配置
+type Config struct {
+	WideScreenMode bool `json:"wide_screen_mode"`
+	// UpdateMulti 共享卡片：通过 LarkService.PatchCard 更新后群里所有成员看到的都是最新内容
+	UpdateMulti bool `json:"update_multi,omitempty"`
+}
+
+// Header 卡片标题栏
+type Header struct {
+	Template string `json:"template,omitempty"`
+	Title    Text   `json:"title"`
+}
+
+// Text 文本对象
+type Text struct {
+	Tag     string `json:"tag"` // plain_text 或 lark_md
+	Content string `json:"content"`
+}
+
+// New 创建带标题的卡片，color 为 Color* 常量
+func New(title, color string) *Card {
+	return &Card{
+		Config: Config{WideScreenMode: true},
+		Header: &Header{Template: color, Title: PlainText(title)},
+	}
+}
+
+// Add 追加元素，返回卡片本身便于链式调用
+func (c *Card) Add(elements ...interface{}) *Card {
+	c.Elements = append(c.Elements, elements...)
+	return c
+}
+
+// PlainText 纯文本
+func PlainText(content string) Text {
+	return Text{Tag: "plain_text", Content: content}
+}
+
+// Markdown Markdown 元素（支持加粗、斜体、删除线、链接和列表，不支持标题和外链图片）
+func Markdown(content string) interface{} {
+	return map[string]string{"tag": "markdown", "content": content}
+}
+
+// Field 字段模块中的一个字段（lark_md 文本），Short 为 true 时两个字段并排显示
+type Field struct {
+	Short   bool
+	Content string
+}
+
+// Fields 字段模块，用于并排展示多组「名称: 值」
+func Fields(fields ...Field) interface{} {
+	items := make([]map[string]interface{}, len(fields))
+	for i, f := range fields {
+		items[i] = map[string]interface{}{
+			"is_short": f.Short,
+			"text":     Text{Tag: "lark_md", Content: f.Content},
+		}
+	}
+	return map[string]interface{}{"tag": "div", "fields": items}
+}
+
+// Colored 带颜色的 Markdown 文本，color 为 red、green 或 grey
+func Colored(content, color string) string {
+	return "<font color='" + color + "'>" + content + "</font>"
+}
+
+// Divider 分割线
+func Divider() interface{} {
+	return map[string]string{"tag": "hr"}
+}
+
+// Note 备注（卡片底部的灰色小字）
+func Note(content string) interface{} {
+	return map[string]interface{}{
+		"tag":      "note",
+		"elements": []Text{PlainText(content)},
+	}
+}
+
+// 按钮样式
+const (
+	ButtonDefault = "default"
+	ButtonPrimary = "primary"
+	ButtonDanger  = "danger"
+)
+
+// Button 回传交互按钮，点击后 value 随 card.action.trigger 回调发送给机器人
+// 回调需要在开发者后台的「事件与回调」中订阅，并选择长连接接收
+func Button(text, style string, value map[string]string) interface{} {
+	return map[string]interface{}{
+		"tag":   "button",
+		"text":  PlainText(text),
+		"type":  style,
+		"value": value,
+	}
+}
+
+// Actions 交互模块，包含一组按钮
+func Actions(buttons ...interface{}) interface{} {
+	return map[string]interface{}{
+		"tag":     "action",
+		"actions": buttons,
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"fin_bot/config"
+	"fin_bot/service"
+	"fin_bot/storage"
+)
+
+// 进程退出码，所有子命令保持一致
+const (
+	exitOK    = 0 // 执行成功
+	exitError = 1 // 执行失败（配置无效、数据库或开放平台调用出错等）
+	exitUsage = 2 // 子命令或参数错误
+)
+
+// cliCommand 一个子命令，name 可以包含两级（例如 "chats list"）
+type cliCommand struct {
+	name string
+	desc string
+	run  func(configPath string, args []string) int
+}
+
+// cliCommands 所有子命令（按用法中的展示顺序）
+var cliCommands = []cliCommand{
+	{"serve", "启动机器人服务（默认）", runServeCommand},
+	{"migrate", "执行数据库迁移（-status 只查看迁移状态）", runMigrateCommand},
+	{"send", "通过指定应用向会话或用户发送文本或消息卡片", runSendCommand},
+	{"chats list", "列出机器人已加入的群聊", runChatsListCommand},
+	{"messages export", "导出保存的消息（jsonl 或 csv，已解密）", runMessagesExportCommand},
+	{"messages reindex", "按 search 配置重建消息搜索索引", runMessagesReindexCommand},
+	{"courses import", "导入课程目录中的 Markdown 文件（-dry-run 只校验）", runCoursesImportCommand},
+	{"quizzes import", "导入题库目录中的 JSON 和 CSV 文件（-dry-run 只校验）", runQuizzesImportCommand},
+	{"flashcards import", "导入卡片组目录中的 CSV 和 Anki TSV 文件（-dry-run 只校验）", runFlashcardsImportCommand},
+	{"glossary import", "导入术语目录中的 CSV 文件（-dry-run 只校验）", runGlossaryImportCommand},
+	{"marketdata import", "导入本地行情目录中的证券列表和日线 CSV 文件（-dry-run 只校验）", runMarketDataImportCommand},
+	{"marketdata quote", "通过配置的行情来源查询证券的报价和最近的日线", runMarketDataQuoteCommand},
+	{"marketdata search", "通过配置的行情来源搜索证券", runMarketDataSearchCommand},
+	{"marketdata serve", "以 HTTP 行情接口的形式提供本地行情（供 http 行情来源测试使用）", runMarketDataServeCommand},
+	{"progress rebuild", "由课程、测验和记忆卡片的记录重建学习事件（经验值、连续天数和徽章随之重新计算）", runProgressRebuildCommand},
+	{"replay", "回放录制的事件，输出机器人将会发送的回复（不会真正发送）", runReplayCommand},
+	{"config print", "输出生效的配置（敏感字段已掩码）", runConfigPrintCommand},
+	{"config schema", "输出配置项说明（Markdown）", runConfigSchemaCommand},
+	{"config check", "校验配置，列出所有问题", runConfigCheckCommand},
+	{"db vacuum", "整理数据库文件，回收空闲空间", runDBVacuumCommand},
+	{"db backup", "生成数据库备份（按 backup 配置压缩、加密和清理）", runDBBackupCommand},
+	{"db backups", "列出备份目录中的备份", runDBBackupsCommand},
+	{"db restore", "从备份恢复数据库（需先停止服务）", runDBRestoreCommand},
+	{"db reencrypt", "立即按 encryption 配置重新加密所有消息", runDBReencryptCommand},
+	{"db keygen", "生成加密密钥文件中的一行密钥", runDBKeygenCommand},
+	{"audit export", "导出审计日志（jsonl 或 csv，按时间顺序）", runAuditExportCommand},
+	{"audit verify", "校验审计日志的哈希链", runAuditVerifyCommand},
+}
+
+// runCLI 根据参数分发子命令，返回进程退出码；未指定子命令时启动服务
+func runCLI(configPath string, args []string) int {
+	if len(args) == 0 {
+		return runServeCommand(configPath, nil)
+	}
+
+	// 优先匹配两级子命令
+	if len(args) > 1 {
+		if cmd := findCommand(args[0] + " " + args[1]); cmd != nil {
+			return cmd.run(configPath, args[2:])
+		}
+	}
+	if cmd := findCommand(args[0]); cmd != nil {
+		return cmd.run(configPath, args[1:])
+	}
+
+	// 只输入了一级（例如 "db"）时列出它的子命令
+	var subs []string
+	for _, cmd := range cliCommands {
+		if group, sub, ok := strings.Cut(cmd.name, " "); ok && group == args[0] {
+			subs = append(subs, sub)
+		}
+	}
+	if len(subs) > 0 {
+		if len(args) > 1 {
+			fmt.Fprintf(os.Stderr, "未知的 %s 子命令: %s\n", args[0], args[1])
+		}
+		fmt.Fprintf(os.Stderr, "用法: fin_bot %s %s\n", args[0], strings.Join(subs, "|"))
+		return exitUsage
+	}
+
+	fmt.Fprintf(os.Stderr, "未知的子命令: %s\n\n", args[0])
+	usage()
+	return exitUsage
+}
+
+// findCommand 按名称查找子命令
+func findCommand(name string) *cliCommand {
+	for i := range cliCommands {
+		if cliCommands[i].name == name {
+			return &cliCommands[i]
+		}
+	}
+	return nil
+}
+
+// usage 输出命令行用法
+func usage() {
+	fmt.Fprintf(os.Stderr, "用法: fin_bot [-config 文件] [子命令] [参数]\n\n子命令:\n")
+	for _, cmd := range cliCommands {
+		fmt.Fprintf(os.Stderr, "  %-17s %s\n", cmd.name, cmd.desc)
+	}
+	fmt.Fprintf(os.Stderr, `
+各子命令的参数可通过 fin_bot <子命令> -h 查看。
+退出码: 0 成功，1 执行失败，2 参数错误。
+
+运行中修改配置文件或发送 SIGHUP 信号会重新加载配置；
+app_id、数据库路径、端口等字段需要重启才能生效，热加载时会被忽略。
+
+参数:
+`)
+	flag.PrintDefaults()
+}
+
+// cliFlags 子命令参数，统一处理用法输出、日志开关和退出码
+type cliFlags struct {
+	*flag.FlagSet
+	name     string
+	synopsis string
+	verbose  *bool
+}
+
+// newCLIFlags 创建子命令参数集合，synopsis 为参数概要（例如 "-to ID -text 内容"）
+func newCLIFlags(name, synopsis string) *cliFlags {
+	f := &cliFlags{
+		FlagSet:  flag.NewFlagSet(name, flag.ContinueOnError),
+		name:     name,
+		synopsis: synopsis,
+	}
+	f.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法: fin_bot [-config 文件] %s %s\n\n参数:\n", f.name, f.synopsis)
+		f.PrintDefaults()
+	}
+	return f
+}
+
+// logFlag 注册 -verbose 参数；未指定时丢弃运行日志，只保留命令本身的输出
+func (f *cliFlags) logFlag() {
+	f.verbose = f.Bool("verbose", false, "输出运行日志")
+}
+
+// parse 解析参数，返回值不为 exitOK 时调用方应直接以该退出码返回
+func (f *cliFlags) parse(args []string) int {
+	if err := f.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if f.NArg() > 0 {
+		return f.usageError("多余的参数: %s", strings.Join(f.Args(), " "))
+	}
+	if f.verbose != nil && !*f.verbose {
+		log.SetOutput(io.Discard)
+	}
+	return exitOK
+}
+
+// usageError 输出参数错误和用法，返回 exitUsage
+func (f *cliFlags) usageError(format string, a ...interface{}) int {
+	fmt.Fprintf(os.Stderr, format+"\n", a...)
+	f.Usage()
+	return exitUsage
+}
+
+// loadConfig 加载配置，失败时输出错误并返回 nil
+// strict 为 false 时忽略校验错误，供只用到部分配置的运维命令使用（例如不需要飞书凭证的数据库命令）
+func loadConfig(configPath string, strict bool) *config.Config {
+	cfg, err := config.Load(configPath)
+	var verr *config.ValidationError
+	if err != nil && (strict || !errors.As(err, &verr)) {
+		fmt.Fprintf(os.Stderr, "加载配置失败: %v\n", err)
+		return nil
+	}
+	return cfg
+}
+
+// openStorage 打开数据库但不执行迁移，dbPath 为空时使用配置中的路径
+// 结构不是最新版本时返回错误，提示先执行 migrate
+func openStorage(cfg *config.Config, dbPath string) (*storage.Storage, error) {
+	if dbPath == "" {
+		dbPath = cfg.Database.Path
+	}
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, fmt.Errorf("数据库不可用: %w", err)
+	}
+	store, err := storage.Open(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := store.PendingMigrations(context.Background())
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	if len(pending) > 0 {
+		store.Close()
+		return nil, fmt.Errorf("数据库有 %d 个未执行的迁移，请先执行 fin_bot migrate", len(pending))
+	}
+	return store, nil
+}
+
+// selectApp 按名称或 App ID 选择配置中的应用
+// name 为空时只托管一个应用则使用该应用，托管多个应用时必须指定（与 HTTP 接口一致）
+func selectApp(cfg *config.Config, name string) (*service.App, error) {
+	apps := cfg.Lark.AllApps()
+	var selected *config.LarkAppConfig
+	switch {
+	case name != "":
+		for i := range apps {
+			if apps[i].Name == name || apps[i].AppID == name {
+				selected = &apps[i]
+				break
+			}
+		}
+		if selected == nil {
+			return nil, fmt.Errorf("%w: %s", service.ErrUnknownApp, name)
+		}
+	case len(apps) == 1:
+		selected = &apps[0]
+	case len(apps) == 0:
+		return nil, errors.New("未配置飞书应用")
+	default:
+		return nil, errors.New("托管了多个应用，请使用 -app 指定应用名称或 App ID")
+	}
+
+	return &service.App{
+		Name:      selected.Name,
+		AppID:     selected.AppID,
+		TenantKey: selected.TenantKey,
+		Lark:      service.NewLarkService(selected.AppID, selected.AppSecret, cfg.Lark.BaseURL),
+	}, nil
+}
@@ -0,0 +1,87 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"fin_bot/metrics"
+)
+
+// ActionKey 卡片按钮 value 中表示交互名称的字段，按钮点击后按这个字段路由到 RegisterAction 注册的处理函数
+const ActionKey = "action"
+
+// ActionRequest 一次卡片交互（点击卡片上的按钮）
+type ActionRequest struct {
+	AppID     string // 收到回调的应用
+	TenantKey string
+	ChatID    string // 卡片所在的会话
+	MessageID string // 卡片消息ID
+	SenderID  string // 点击者 open_id
+	Name      string // value 中的 action 字段
+	Value     map[string]string
+}
+
+// ActionFunc 卡片交互处理函数，返回发送到卡片所在会话的回复，返回 nil 表示不回复
+type ActionFunc func(ctx context.Context, req *ActionRequest) (*Reply, error)
+
+// TextFunc 处理不是命令的文本消息（例如直接回复测验的答案），handled 为 false 时交给下一个处理函数，
+// handled 为 true 且 reply 为 nil 时不回复
+type TextFunc func(ctx context.Context, req *Request) (reply *Reply, handled bool, err error)
+
+// RegisterAction 注册卡片交互，同名交互会被覆盖
+func (r *Router) RegisterAction(name string, fn ActionFunc) {
+	r.actions[name] = fn
+}
+
+// RegisterText 注册文本消息处理函数，按注册顺序调用
+func (r *Router) RegisterText(fn TextFunc) {
+	r.texts = append(r.texts, fn)
+}
+
+// ParseText 把不是命令的文本消息转换为请求（Name 为空），去掉群聊中 @机器人 的占位符
+func ParseText(text string) *Request {
+	text = strings.TrimSpace(mentionPattern.ReplaceAllString(text, ""))
+	return &Request{Args: strings.Fields(text), RawArgs: text}
+}
+
+// DispatchAction 处理卡片交互，blocked 用户的点击和未注册的交互返回错误
+func (r *Router) DispatchAction(ctx context.Context, req *ActionRequest) (*Reply, error) {
+	fn, ok := r.actions[req.Name]
+	if !ok {
+		return nil, fmt.Errorf("未知的卡片交互: %s", req.Name)
+	}
+	label := "card:" + req.Name
+	role := r.RoleOf(ctx, &Request{
+		AppID: req.AppID, TenantKey: req.TenantKey, ChatID: req.ChatID, MessageID: req.MessageID, SenderID: req.SenderID,
+	})
+	if role == RoleBlocked {
+		metrics.CommandsExecuted.WithLabelValues(label, "denied").Inc()
+		return nil, fmt.Errorf("blocked 用户的卡片交互: sender=%s", req.SenderID)
+	}
+
+	reply, err := fn(ctx, req)
+	if err != nil {
+		metrics.CommandsExecuted.WithLabelValues(label, "error").Inc()
+		log.Printf("[command] 卡片交互处理失败: name=%s, value=%v, error=%v", req.Name, req.Value, err)
+		return TextReply(fmt.Sprintf("操作失败: %v", err)), nil
+	}
+	metrics.CommandsExecuted.WithLabelValues(label, "success").Inc()
+	return reply, nil
+}
+
+// DispatchText 把不是命令的文本消息交给注册的处理函数，handled 为 false 表示没有处理函数接收
+func (r *Router) DispatchText(ctx context.Context, req *Request) (reply *Reply, handled bool) {
+	for _, fn := range r.texts {
+		reply, handled, err := fn(ctx, req)
+		if err != nil {
+			log.Printf("[command] 文本消息处理失败: text=%q, error=%v", req.RawArgs, err)
+			return TextReply(fmt.Sprintf("处理失败: %v", err)), true
+		}
+		if handled {
+			return reply, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,41 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// 回复的消息类型，与开放平台的 msg_type 一致
+const (
+	MsgTypeText = "text"
+	MsgTypePost = "post"
+	MsgTypeCard = "interactive"
+)
+
+// Reply 命令的回复
+type Reply struct {
+	MsgType string // MsgType* 常量，为空时视为文本
+	Content string // 文本回复为原始文本，post 和卡片为序列化后的 content JSON
+}
+
+// ReplyFunc 需要回复富文本或消息卡片的命令处理函数，返回 nil 表示不回复（处理函数自行发送了消息）
+type ReplyFunc func(ctx context.Context, req *Request) (*Reply, error)
+
+// TextReply 文本回复
+func TextReply(text string) *Reply {
+	return &Reply{MsgType: MsgTypeText, Content: text}
+}
+
+// CardReply 消息卡片回复，card 会被序列化为 JSON
+func CardReply(card interface{}) (*Reply, error) {
+	data, err := json.Marshal(card)
+	if err != nil {
+		return nil, err
+	}
+	return &Reply{MsgType: MsgTypeCard, Content: string(data)}, nil
+}
+
+// IsText 是否为文本回复
+func (r *Reply) IsText() bool {
+	return r.MsgType == "" || r.MsgType == MsgTypeText
+}
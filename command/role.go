@@ -0,0 +1,51 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Role 用户角色，数值越大权限越高；零值为普通成员
+type Role int
+
+const (
+	RoleBlocked Role = iota - 1 // 禁止使用机器人
+	RoleMember                  // 普通成员（默认）
+	RoleTeacher                 // 讲师，可以管理课程等教学内容
+	RoleAdmin                   // 管理员，可以执行管理命令
+	RoleOwner                   // 所有者，可以任命管理员
+)
+
+// roleNames 角色名称，用于命令参数和数据库存储
+var roleNames = map[Role]string{
+	RoleBlocked: "blocked",
+	RoleMember:  "member",
+	RoleTeacher: "teacher",
+	RoleAdmin:   "admin",
+	RoleOwner:   "owner",
+}
+
+// String 角色名称
+func (r Role) String() string {
+	if name, ok := roleNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("role(%d)", int(r))
+}
+
+// ParseRole 解析角色名称（不区分大小写）
+func ParseRole(name string) (Role, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for role, n := range roleNames {
+		if n == name {
+			return role, nil
+		}
+	}
+	return RoleMember, fmt.Errorf("未知的角色: %s（可选 owner/admin/teacher/member/blocked）", name)
+}
+
+// Authorizer 确定命令调用者的角色（使用 req 中的应用、租户、会话和发送者）
+type Authorizer interface {
+	RoleOf(ctx context.Context, req *Request) Role
+}
@@ -0,0 +1,179 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+
+	"fin_bot/audit"
+	"fin_bot/metrics"
+	"fin_bot/storage"
+)
+
+// Request 一次命令调用
+type Request struct {
+	AppID     string // 收到消息的应用
+	TenantKey string // 消息所属租户
+	ChatID    string
+	ChatType  string // "p2p" 或 "group"
+	MessageID string
+	SenderID  string // 发送者 open_id
+	Name      string // 命令名（不含斜杠，小写）
+	Args      []string
+	RawArgs   string // 命令名之后的原始文本
+}
+
+// HandlerFunc 命令处理函数，返回回复给用户的文本
+type HandlerFunc func(ctx context.Context, req *Request) (string, error)
+
+// Command 机器人命令，Handler 和 ReplyHandler 设置其中一个
+type Command struct {
+	Name         string
+	Usage        string
+	Description  string
+	MinRole      Role // 执行命令需要的最低角色，零值表示所有未被禁止的用户
+	Handler      HandlerFunc
+	ReplyHandler ReplyFunc // 回复消息卡片等非文本消息时使用
+}
+
+// Router 命令路由
+type Router struct {
+	commands map[string]*Command
+	actions  map[string]ActionFunc // 卡片交互，见 RegisterAction
+	texts    []TextFunc            // 不是命令的文本消息，见 RegisterText
+	auth     Authorizer
+	audit    *audit.Logger // 记录管理命令（admin 及以上角色的命令），为 nil 时不记录
+}
+
+// mentionPattern 群聊中 @机器人 在文本中的占位符，例如 @_user_1
+var mentionPattern = regexp.MustCompile(`@_user_\d+`)
+
+// NewRouter 创建命令路由，auth 为 nil 时所有用户都是普通成员
+func NewRouter(auth Authorizer) *Router {
+	r := &Router{
+		commands: make(map[string]*Command),
+		actions:  make(map[string]ActionFunc),
+		auth:     auth,
+	}
+	r.Register(&Command{
+		Name:        "help",
+		Usage:       "/help",
+		Description: "查看可用命令",
+		Handler:     r.help,
+	})
+	return r
+}
+
+// Register 注册命令，同名命令会被覆盖
+func (r *Router) Register(cmd *Command) {
+	r.commands[strings.ToLower(cmd.Name)] = cmd
+}
+
+// SetAuditLogger 设置审计日志，需要在开始处理消息之前调用
+func (r *Router) SetAuditLogger(l *audit.Logger) {
+	r.audit = l
+}
+
+// RoleOf 获取命令调用者的角色
+func (r *Router) RoleOf(ctx context.Context, req *Request) Role {
+	if r.auth == nil {
+		return RoleMember
+	}
+	return r.auth.RoleOf(ctx, req)
+}
+
+// Parse 从文本消息中解析命令，不是命令时返回 nil
+func Parse(text string) *Request {
+	text = strings.TrimSpace(mentionPattern.ReplaceAllString(text, ""))
+	if !strings.HasPrefix(text, "/") {
+		return nil
+	}
+
+	body := strings.TrimPrefix(text, "/")
+	name, rawArgs, _ := strings.Cut(body, " ")
+	if name == "" {
+		return nil
+	}
+	rawArgs = strings.TrimSpace(rawArgs)
+	return &Request{
+		Name:    strings.ToLower(name),
+		Args:    strings.Fields(rawArgs),
+		RawArgs: rawArgs,
+	}
+}
+
+// Dispatch 执行命令，handled 为 false 表示不是已注册的命令
+func (r *Router) Dispatch(ctx context.Context, req *Request) (reply *Reply, handled bool) {
+	cmd, ok := r.commands[req.Name]
+	if !ok {
+		return nil, false
+	}
+
+	if role := r.RoleOf(ctx, req); role < cmd.MinRole || role == RoleBlocked {
+		metrics.CommandsExecuted.WithLabelValues(cmd.Name, "denied").Inc()
+		log.Printf("[command] 无权限执行命令: name=%s, sender=%s, role=%s", cmd.Name, req.SenderID, role)
+		r.record(ctx, cmd, req, "denied")
+		if role == RoleBlocked {
+			return TextReply("你没有使用机器人的权限"), true
+		}
+		return TextReply(fmt.Sprintf("该命令需要 %s 及以上角色", cmd.MinRole)), true
+	}
+
+	reply, err := cmd.run(ctx, req)
+	if err != nil {
+		metrics.CommandsExecuted.WithLabelValues(cmd.Name, "error").Inc()
+		log.Printf("[command] 命令执行失败: name=%s, args=%q, error=%v", cmd.Name, req.RawArgs, err)
+		r.record(ctx, cmd, req, "error")
+		return TextReply(fmt.Sprintf("命令执行失败: %v\n用法: %s", err, cmd.Usage)), true
+	}
+
+	metrics.CommandsExecuted.WithLabelValues(cmd.Name, "success").Inc()
+	r.record(ctx, cmd, req, "success")
+	return reply, true
+}
+
+// run 调用命令的处理函数
+func (cmd *Command) run(ctx context.Context, req *Request) (*Reply, error) {
+	if cmd.ReplyHandler != nil {
+		return cmd.ReplyHandler(ctx, req)
+	}
+	text, err := cmd.Handler(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return TextReply(text), nil
+}
+
+// record 把管理命令的调用（包括被拒绝的）写入审计日志
+func (r *Router) record(ctx context.Context, cmd *Command, req *Request, result string) {
+	if cmd.MinRole < RoleAdmin {
+		return
+	}
+	scope := storage.Scope{AppID: req.AppID, TenantKey: req.TenantKey}
+	r.audit.Record(ctx, scope, audit.ActionCommand+cmd.Name, "chat_id:"+req.ChatID,
+		fmt.Sprintf("args=%q result=%s", req.RawArgs, result))
+}
+
+// help 列出当前用户可用的命令
+func (r *Router) help(ctx context.Context, req *Request) (string, error) {
+	role := r.RoleOf(ctx, req)
+	names := make([]string, 0, len(r.commands))
+	for name, cmd := range r.commands {
+		if role < cmd.MinRole {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("可用命令:")
+	for _, name := range names {
+		cmd := r.commands[name]
+		fmt.Fprintf(&b, "\n%s  %s", cmd.Usage, cmd.Description)
+	}
+	return b.String(), nil
+}
@@ -1,56 +1,294 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// DefaultConfigFile 未指定配置文件时尝试加载的默认路径（不存在时忽略）
+const DefaultConfigFile = "config.yaml"
+
 // Config 存储应用程序配置
+//
+// 字段标签说明：
+//   - yaml: 配置文件中的键名
+//   - env: 覆盖该字段的环境变量（优先级高于配置文件）
+//   - default: 默认值
+//   - desc: 字段说明（用于生成配置文档）
+//   - secret: 敏感字段，打印时会被掩码
+//   - required: 必填字段
+//   - immutable: 运行中不能修改的字段，热加载时会被拒绝并保留原值
 type Config struct {
-	// 飞书应用配置
-	AppID     string
-	AppSecret string
-	
-	// 其他配置
-	APIKey      string
-	SecretKey   string
-	DatabaseURL string
-	DatabasePath string // SQLite 数据库文件路径
-	AppEnv      string
-	Port        string
-}
-
-// Load 加载环境变量配置
-func Load() *Config {
+	Lark       LarkConfig       `yaml:"lark" desc:"飞书应用配置"`
+	Server     ServerConfig     `yaml:"server" desc:"HTTP 服务配置"`
+	Database   DatabaseConfig   `yaml:"database" desc:"数据库配置"`
+	Security   SecurityConfig   `yaml:"security" desc:"密钥配置"`
+	Admin      AdminConfig      `yaml:"admin" desc:"管理员配置"`
+	Events     EventsConfig     `yaml:"events" desc:"消息事件处理配置"`
+	Scheduler  SchedulerConfig  `yaml:"scheduler" desc:"定时任务配置"`
+	Retention  RetentionConfig  `yaml:"retention" desc:"数据保留策略（由调度器每小时清理一次消息）"`
+	RateLimit  RateLimitConfig  `yaml:"rate_limit" desc:"限流配置"`
+	Recorder   RecorderConfig   `yaml:"recorder" desc:"事件录制配置"`
+	Backup     BackupConfig     `yaml:"backup" desc:"数据库备份配置"`
+	Encryption EncryptionConfig `yaml:"encryption" desc:"敏感数据加密配置"`
+	Search     SearchConfig     `yaml:"search" desc:"消息搜索索引配置"`
+	Courses    CoursesConfig    `yaml:"courses" desc:"课程配置"`
+	Quizzes    QuizzesConfig    `yaml:"quizzes" desc:"测验题库配置"`
+	Flashcards FlashcardsConfig `yaml:"flashcards" desc:"记忆卡片配置"`
+	Progress   ProgressConfig   `yaml:"progress" desc:"学习进度配置"`
+	Glossary   GlossaryConfig   `yaml:"glossary" desc:"金融术语表配置"`
+	MarketData MarketDataConfig `yaml:"marketdata" desc:"行情数据配置"`
+	Quote      QuoteConfig      `yaml:"quote" desc:"行情卡片配置"`
+	Watchlist  WatchlistConfig  `yaml:"watchlist" desc:"自选列表配置"`
+	Alerts     AlertsConfig     `yaml:"alerts" desc:"价格和指标提醒配置"`
+
+	AppEnv string `yaml:"app_env" env:"APP_ENV" default:"development" desc:"运行环境: development/staging/production"`
+}
+
+// DefaultAppName lark.app_id/app_secret 配置的默认应用名称
+const DefaultAppName = "default"
+
+// LarkConfig 飞书应用配置
+// app_id/app_secret 为默认应用，apps 中可以配置更多应用，每个应用使用独立的长连接和数据范围
+type LarkConfig struct {
+	AppID     string          `yaml:"app_id" env:"APP_ID" secret:"true" immutable:"true" desc:"默认飞书应用 App ID（与 apps 至少配置一个）"`
+	AppSecret string          `yaml:"app_secret" env:"APP_SECRET" secret:"true" immutable:"true" desc:"默认飞书应用 App Secret"`
+	TenantKey string          `yaml:"tenant_key" env:"TENANT_KEY" immutable:"true" desc:"默认应用所属租户的 tenant_key，HTTP 接口未指定租户时使用"`
+	Apps      []LarkAppConfig `yaml:"apps" immutable:"true" desc:"额外托管的飞书应用列表"`
+	BaseURL   string          `yaml:"base_url" env:"LARK_BASE_URL" immutable:"true" desc:"开放平台地址（为空使用飞书 open.feishu.cn，可改为 Lark 国际版或测试用的模拟服务）"`
+}
+
+// LarkAppConfig 单个飞书应用
+type LarkAppConfig struct {
+	Name      string `yaml:"name" desc:"应用名称（HTTP 接口中可用于选择应用）"`
+	AppID     string `yaml:"app_id" secret:"true" desc:"App ID"`
+	AppSecret string `yaml:"app_secret" secret:"true" desc:"App Secret"`
+	TenantKey string `yaml:"tenant_key" desc:"应用所属租户的 tenant_key，HTTP 接口未指定租户时使用"`
+}
+
+// AllApps 返回所有需要托管的应用，默认应用（如果配置了）排在最前
+func (c *LarkConfig) AllApps() []LarkAppConfig {
+	apps := make([]LarkAppConfig, 0, len(c.Apps)+1)
+	if c.AppID != "" || c.AppSecret != "" {
+		apps = append(apps, LarkAppConfig{
+			Name:      DefaultAppName,
+			AppID:     c.AppID,
+			AppSecret: c.AppSecret,
+			TenantKey: c.TenantKey,
+		})
+	}
+	return append(apps, c.Apps...)
+}
+
+// ServerConfig HTTP 服务配置
+type ServerConfig struct {
+	Port            string        `yaml:"port" env:"PORT" immutable:"true" default:"8080" desc:"HTTP 监听端口（1-65535）"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" default:"15s" desc:"优雅关闭的总时限（包括排空进行中的消息处理）"`
+}
+
+// DatabaseConfig 数据库配置
+type DatabaseConfig struct {
+	Path string `yaml:"path" env:"DATABASE_PATH" immutable:"true" default:"data/fin_bot.db" desc:"SQLite 数据库文件路径"`
+}
+
+// SecurityConfig 密钥配置
+type SecurityConfig struct {
+	APIKey    string `yaml:"api_key" env:"API_KEY" secret:"true" desc:"HTTP 管理接口（/api/*）的 API Key，通过 Authorization: Bearer 或 X-API-Key 请求头传递；为空时不校验（production 环境必填）"`
+	SecretKey string `yaml:"secret_key" env:"SECRET_KEY" immutable:"true" secret:"true" desc:"用于派生加密密钥的主密钥"`
+}
+
+// AdminConfig 管理员配置
+type AdminConfig struct {
+	Owners         []string `yaml:"owners" env:"ADMIN_OWNERS" desc:"所有者列表（环境变量用逗号分隔），格式为 应用名称或App ID:open_id，不带应用前缀的 open_id 只在默认应用中生效；可以任命管理员，角色不能通过命令修改"`
+	Users          []string `yaml:"users" env:"ADMIN_USERS" desc:"管理员列表（环境变量用逗号分隔），格式同 owners；可执行 /schedule、/grant 等管理命令，角色不能通过命令修改"`
+	ChatOwnerAdmin bool     `yaml:"chat_owner_admin" env:"ADMIN_CHAT_OWNER_ADMIN" default:"true" desc:"群主在自己的群中是否视为管理员"`
+}
+
+// EventsConfig 消息事件处理配置
+type EventsConfig struct {
+	Workers         int     `yaml:"workers" env:"EVENT_WORKERS" immutable:"true" default:"4" desc:"消息事件处理 worker 数"`
+	QueueSize       int     `yaml:"queue_size" env:"EVENT_QUEUE_SIZE" immutable:"true" default:"256" desc:"消息事件队列容量"`
+	QueueSaturation float64 `yaml:"queue_saturation" env:"EVENT_QUEUE_SATURATION" default:"0.9" desc:"队列积压比例达到该值时就绪检查失败（0-1）"`
+}
+
+// SchedulerConfig 定时任务配置
+type SchedulerConfig struct {
+	Interval        time.Duration `yaml:"interval" env:"SCHEDULER_INTERVAL" default:"30s" desc:"到期任务检查间隔"`
+	DefaultTimezone string        `yaml:"default_timezone" env:"SCHEDULER_TIMEZONE" default:"Asia/Shanghai" desc:"未指定时区的任务使用的默认时区"`
+}
+
+// RetentionConfig 数据保留策略
+type RetentionConfig struct {
+	MessagesPerChat int           `yaml:"messages_per_chat" env:"RETENTION_MESSAGES_PER_CHAT" default:"0" desc:"每个会话最多保留的消息条数（0 表示不限制）"`
+	MessageMaxAge   time.Duration `yaml:"message_max_age" env:"RETENTION_MESSAGE_MAX_AGE" default:"0s" desc:"消息最长保留时间（0 表示不限制）"`
+}
+
+// RecorderConfig 事件录制配置，录制的事件可通过 replay 子命令回放
+type RecorderConfig struct {
+	Enabled       bool   `yaml:"enabled" env:"RECORDER_ENABLED" immutable:"true" default:"false" desc:"是否录制收到的原始事件"`
+	Path          string `yaml:"path" env:"RECORDER_PATH" immutable:"true" default:"data/events.jsonl" desc:"录制文件路径（JSONL，追加写入）"`
+	RedactContent bool   `yaml:"redact_content" env:"RECORDER_REDACT_CONTENT" immutable:"true" default:"false" desc:"是否脱敏消息正文（命令名保留），事件中的 verification token、user_id、union_id 始终会被移除"`
+}
+
+// BackupConfig 数据库备份配置
+type BackupConfig struct {
+	Dir      string `yaml:"dir" env:"BACKUP_DIR" immutable:"true" default:"data/backups" desc:"备份文件目录"`
+	Schedule string `yaml:"schedule" env:"BACKUP_SCHEDULE" desc:"定时备份的 cron 表达式，例如 0 3 * * *（可加 CRON_TZ=Asia/Shanghai 前缀指定时区，为空表示不定时备份）"`
+	Keep     int    `yaml:"keep" env:"BACKUP_KEEP" default:"7" desc:"备份目录中保留的备份数量，超出时删除最旧的（0 表示不清理）"`
+	Compress bool   `yaml:"compress" env:"BACKUP_COMPRESS" default:"true" desc:"是否使用 gzip 压缩备份"`
+	Encrypt  bool   `yaml:"encrypt" env:"BACKUP_ENCRYPT" default:"false" desc:"是否加密备份（AES-256-GCM，密钥由 security.secret_key 派生）"`
+}
+
+// EncryptionConfig 敏感数据加密配置（目前加密消息内容）
+type EncryptionConfig struct {
+	Enabled           bool          `yaml:"enabled" env:"ENCRYPTION_ENABLED" immutable:"true" default:"false" desc:"是否加密保存消息内容（AES-256-GCM 信封加密）；关闭后后台任务会把已加密的数据解密为明文"`
+	KeyFile           string        `yaml:"key_file" env:"ENCRYPTION_KEY_FILE" immutable:"true" desc:"密钥文件，每行 <key_id> <base64 编码的 32 字节密钥>（可用 fin_bot db keygen 生成）；为空时按 key_id 从 security.secret_key 派生"`
+	PrimaryKeyID      string        `yaml:"primary_key_id" env:"ENCRYPTION_PRIMARY_KEY_ID" immutable:"true" default:"k1" desc:"加密新数据使用的密钥ID，更换后后台任务会用新密钥重新加密旧数据"`
+	ReencryptInterval time.Duration `yaml:"reencrypt_interval" env:"ENCRYPTION_REENCRYPT_INTERVAL" immutable:"true" default:"1m" desc:"后台重新加密任务的检查间隔"`
+	ReencryptBatch    int           `yaml:"reencrypt_batch" env:"ENCRYPTION_REENCRYPT_BATCH" immutable:"true" default:"200" desc:"重新加密任务每批处理的消息数"`
+}
+
+// SearchConfig 消息搜索索引配置
+// 消息内容可能已加密，索引中只保存下面允许的词，修改后需要执行 fin_bot messages reindex
+type SearchConfig struct {
+	IndexCommands bool     `yaml:"index_commands" env:"SEARCH_INDEX_COMMANDS" immutable:"true" default:"true" desc:"索引命令名（例如 /schedule）"`
+	IndexSymbols  bool     `yaml:"index_symbols" env:"SEARCH_INDEX_SYMBOLS" immutable:"true" default:"true" desc:"索引证券代码（例如 AAPL、600519.SH），不索引单独的数字"`
+	Keywords      []string `yaml:"keywords" env:"SEARCH_KEYWORDS" immutable:"true" desc:"额外允许索引的关键词（环境变量用逗号分隔），按包含关系匹配"`
+}
+
+// CoursesConfig 课程配置
+// 课程以 Markdown 文件编写，导入数据库后通过 /courses、/enroll、/next、/lesson 命令学习
+type CoursesConfig struct {
+	Dir          string `yaml:"dir" env:"COURSES_DIR" default:"courses" desc:"课程目录（每门课程一个子目录，包含 course.md 和各节课的 Markdown 文件）"`
+	ImportOnLoad bool   `yaml:"import_on_load" env:"COURSES_IMPORT_ON_LOAD" default:"true" desc:"启动时和修改 courses 配置后是否自动导入课程目录（目录不存在时跳过）；修改课程文件后用 fin_bot courses import 导入"`
+}
+
+// QuizzesConfig 测验题库配置
+// 题库以 JSON 或 CSV 文件编写，导入数据库后通过 /quiz、/answer 命令或卡片按钮作答
+type QuizzesConfig struct {
+	Dir          string `yaml:"dir" env:"QUIZZES_DIR" default:"quizzes" desc:"题库目录（每个 .json 或 .csv 文件一个题库）"`
+	ImportOnLoad bool   `yaml:"import_on_load" env:"QUIZZES_IMPORT_ON_LOAD" default:"true" desc:"启动时和修改 quizzes.dir 或 import_on_load 后是否自动导入题库目录（目录不存在时跳过）；修改题库文件后用 fin_bot quizzes import 导入"`
+
+	BattleTimeout      time.Duration `yaml:"battle_timeout" env:"QUIZZES_BATTLE_TIMEOUT" default:"30s" desc:"群聊答题赛（/quizbattle）每道题的作答时间（5s-10m），修改后对之后开始的答题赛生效"`
+	BattleMaxQuestions int           `yaml:"battle_max_questions" env:"QUIZZES_BATTLE_MAX_QUESTIONS" default:"20" desc:"一场答题赛最多的题数"`
+}
+
+// FlashcardsConfig 记忆卡片配置
+// 卡片组以 CSV 或 Anki 导出的 TSV 文件编写，导入数据库后通过 /review 命令和卡片按钮复习，按 SM-2 算法安排复习时间
+type FlashcardsConfig struct {
+	Dir          string `yaml:"dir" env:"FLASHCARDS_DIR" default:"flashcards" desc:"卡片组目录（每个 .csv、.tsv 或 .txt 文件一个卡片组）"`
+	ImportOnLoad bool   `yaml:"import_on_load" env:"FLASHCARDS_IMPORT_ON_LOAD" default:"true" desc:"启动时和修改 flashcards 配置后是否自动导入卡片组目录（目录不存在时跳过）；修改卡片组文件后用 fin_bot flashcards import 导入"`
+	NewPerDay    int    `yaml:"new_per_day" env:"FLASHCARDS_NEW_PER_DAY" default:"20" desc:"每个用户每天最多学习的新卡片数（所有卡片组合计）"`
+	PushSchedule string `yaml:"push_schedule" env:"FLASHCARDS_PUSH_SCHEDULE" default:"0 9 * * *" desc:"每日提醒的 cron 表达式，有到期卡片的用户会在单聊中收到提醒（为空表示不提醒）"`
+	Timezone     string `yaml:"timezone" env:"FLASHCARDS_TIMEZONE" default:"Asia/Shanghai" desc:"每日提醒和复习日期使用的时区（决定卡片在哪天到期、每天的新卡片数何时重置）"`
+}
+
+// ProgressConfig 学习进度配置
+// 经验值、连续学习天数和徽章由学习事件（学完课、完成测验、复习卡片）实时计算，通过 /me 和 /leaderboard 查看
+type ProgressConfig struct {
+	Timezone        string `yaml:"timezone" env:"PROGRESS_TIMEZONE" default:"Asia/Shanghai" desc:"计算连续学习天数的默认时区（用户可以用 /me timezone 修改自己的时区）和排行榜每周的起始时间（周一零点）"`
+	LeaderboardSize int    `yaml:"leaderboard_size" env:"PROGRESS_LEADERBOARD_SIZE" default:"10" desc:"排行榜显示的人数"`
+}
+
+// GlossaryConfig 金融术语表配置
+// 术语以 CSV 文件编写，导入数据库后通过 /define 或直接提问「什么是X」查询，订阅的会话每天收到一个术语
+type GlossaryConfig struct {
+	Dir           string `yaml:"dir" env:"GLOSSARY_DIR" default:"terms" desc:"术语目录（每个 .csv 文件一组术语）"`
+	ImportOnLoad  bool   `yaml:"import_on_load" env:"GLOSSARY_IMPORT_ON_LOAD" default:"true" desc:"启动时和修改 glossary.dir 或 import_on_load 后是否自动导入术语目录（目录不存在时跳过）；修改术语文件后用 fin_bot glossary import 导入"`
+	DailySchedule string `yaml:"daily_schedule" env:"GLOSSARY_DAILY_SCHEDULE" default:"0 9 * * 1-5" desc:"每日术语的 cron 表达式，通过 /termofday on 订阅的会话会收到当天的术语（为空表示不发送）"`
+	Timezone      string `yaml:"timezone" env:"GLOSSARY_TIMEZONE" default:"Asia/Shanghai" desc:"每日术语使用的时区（决定发送时间和每天的术语何时更换）"`
+}
+
+// MarketDataConfig 行情数据配置
+// 行情来源可以是本地行情（由 marketdata.dir 中的 CSV 文件导入数据库，用于离线运行和测试）或 HTTP 行情接口，
+// 查询结果按类型缓存一段时间
+type MarketDataConfig struct {
+	Provider     string        `yaml:"provider" env:"MARKETDATA_PROVIDER" immutable:"true" default:"local" desc:"行情来源: local（本地行情）或 http（HTTP 行情接口）"`
+	Dir          string        `yaml:"dir" env:"MARKETDATA_DIR" default:"market" desc:"本地行情目录（instruments.csv 和 bars/<代码>.csv）"`
+	ImportOnLoad bool          `yaml:"import_on_load" env:"MARKETDATA_IMPORT_ON_LOAD" default:"true" desc:"使用本地行情时，启动时和修改 marketdata.dir 或 import_on_load 后是否自动导入本地行情目录（目录不存在时跳过）；修改行情文件后用 fin_bot marketdata import 导入"`
+	HTTPURL      string        `yaml:"http_url" env:"MARKETDATA_HTTP_URL" immutable:"true" desc:"HTTP 行情接口地址（provider 为 http 时必填，可以指向 fin_bot marketdata serve 启动的模拟服务）"`
+	HTTPToken    string        `yaml:"http_token" env:"MARKETDATA_HTTP_TOKEN" immutable:"true" secret:"true" desc:"HTTP 行情接口的 Bearer token（为空时不发送）"`
+	HTTPTimeout  time.Duration `yaml:"http_timeout" env:"MARKETDATA_HTTP_TIMEOUT" immutable:"true" default:"5s" desc:"HTTP 行情接口单次请求的超时时间"`
+	QuoteTTL     time.Duration `yaml:"quote_ttl" env:"MARKETDATA_QUOTE_TTL" default:"15s" desc:"最新报价的缓存时间（0 表示不缓存）"`
+	HistoryTTL   time.Duration `yaml:"history_ttl" env:"MARKETDATA_HISTORY_TTL" default:"10m" desc:"日线历史的缓存时间（0 表示不缓存）"`
+	SearchTTL    time.Duration `yaml:"search_ttl" env:"MARKETDATA_SEARCH_TTL" default:"1h" desc:"证券搜索结果的缓存时间（0 表示不缓存）"`
+}
+
+// QuoteConfig 行情卡片（/quote）配置
+type QuoteConfig struct {
+	UpColor  string `yaml:"up_color" env:"QUOTE_UP_COLOR" default:"red" desc:"上涨使用的颜色: red（红涨绿跌，A 股和港股的习惯）或 green（绿涨红跌）"`
+	Timezone string `yaml:"timezone" env:"QUOTE_TIMEZONE" default:"Asia/Shanghai" desc:"行情卡片中数据时间使用的时区"`
+}
+
+// WatchlistConfig 自选列表配置
+// 自选列表按会话保存（单聊中属于用户，群聊中属于整个群），定时向自选列表不为空的会话发送价格汇总
+type WatchlistConfig struct {
+	MorningSchedule string `yaml:"morning_schedule" env:"WATCHLIST_MORNING_SCHEDULE" default:"25 9 * * 1-5" desc:"早盘汇总的 cron 表达式（为空表示不发送）"`
+	EveningSchedule string `yaml:"evening_schedule" env:"WATCHLIST_EVENING_SCHEDULE" default:"5 15 * * 1-5" desc:"收盘汇总的 cron 表达式（为空表示不发送）"`
+	Timezone        string `yaml:"timezone" env:"WATCHLIST_TIMEZONE" default:"Asia/Shanghai" desc:"定时汇总使用的时区"`
+	MaxSymbols      int    `yaml:"max_symbols" env:"WATCHLIST_MAX_SYMBOLS" default:"30" desc:"每个会话最多的自选证券数"`
+}
+
+// AlertsConfig 价格和指标提醒配置
+// 用户通过 /alert 创建提醒规则，后台按间隔检查，条件满足时私聊通知创建者
+type AlertsConfig struct {
+	Interval          time.Duration `yaml:"interval" env:"ALERTS_INTERVAL" default:"1m" desc:"检查提醒的间隔"`
+	HysteresisPercent float64       `yaml:"hysteresis_percent" env:"ALERTS_HYSTERESIS_PERCENT" default:"1" desc:"价格提醒的回差（阈值的百分比）：重复提醒触发后，价格回到阈值另一侧超过回差才会再次生效"`
+	HysteresisPoints  float64       `yaml:"hysteresis_points" env:"ALERTS_HYSTERESIS_POINTS" default:"2" desc:"涨跌幅和 RSI 提醒的回差（点）"`
+	MaxRules          int           `yaml:"max_rules" env:"ALERTS_MAX_RULES" default:"20" desc:"每个用户最多的生效中提醒数"`
+	Timezone          string        `yaml:"timezone" env:"ALERTS_TIMEZONE" default:"Asia/Shanghai" desc:"提醒记录中时间使用的时区"`
+}
+
+// RateLimitConfig 限流配置
+type RateLimitConfig struct {
+	Enabled       bool          `yaml:"enabled" env:"RATE_LIMIT_ENABLED" default:"true" desc:"是否启用限流"`
+	UserRate      float64       `yaml:"user_rate" env:"RATE_LIMIT_USER_RATE" default:"0.2" desc:"每个用户每秒补充的令牌数"`
+	UserBurst     int           `yaml:"user_burst" env:"RATE_LIMIT_USER_BURST" default:"5" desc:"每个用户的令牌桶容量"`
+	ChatRate      float64       `yaml:"chat_rate" env:"RATE_LIMIT_CHAT_RATE" default:"1" desc:"每个会话每秒补充的令牌数"`
+	ChatBurst     int           `yaml:"chat_burst" env:"RATE_LIMIT_CHAT_BURST" default:"20" desc:"每个会话的令牌桶容量"`
+	GlobalRate    float64       `yaml:"global_rate" env:"RATE_LIMIT_GLOBAL_RATE" default:"20" desc:"全局每秒补充的令牌数"`
+	GlobalBurst   int           `yaml:"global_burst" env:"RATE_LIMIT_GLOBAL_BURST" default:"100" desc:"全局令牌桶容量"`
+	ReplyCooldown time.Duration `yaml:"reply_cooldown" env:"RATE_LIMIT_REPLY_COOLDOWN" default:"1m" desc:"被限流后再次提示的冷却时间"`
+}
+
+// Load 加载配置：默认值 -> 配置文件 -> 环境变量（含 .env 文件），最后统一校验
+// path 为空时尝试加载 DefaultConfigFile，文件不存在也不会报错
+// 校验失败时同时返回已加载的配置和 *ValidationError，便于输出诊断信息
+func Load(path string) (*Config, error) {
 	// 尝试加载 .env 文件（如果存在）
 	// 如果文件不存在也不会报错，因为可能使用系统环境变量
 	if err := godotenv.Load(); err != nil {
 		log.Println("未找到 .env 文件，将使用系统环境变量")
 	}
 
-	return &Config{
-		// 飞书应用配置
-		AppID:     getEnv("APP_ID", ""),
-		AppSecret: getEnv("APP_SECRET", ""),
-		
-		// 其他配置
-		APIKey:       getEnv("API_KEY", ""),
-		SecretKey:    getEnv("SECRET_KEY", ""),
-		DatabaseURL:  getEnv("DATABASE_URL", ""),
-		DatabasePath: getEnv("DATABASE_PATH", "data/fin_bot.db"), // 默认数据库路径
-		AppEnv:       getEnv("APP_ENV", "development"),
-		Port:         getEnv("PORT", "8080"),
+	cfg := &Config{}
+	if err := applyDefaults(cfg); err != nil {
+		return nil, fmt.Errorf("应用默认配置失败: %w", err)
 	}
-}
 
-// getEnv 获取环境变量，如果不存在则返回默认值
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
+	explicit := path != ""
+	if !explicit {
+		path = DefaultConfigFile
+	}
+	if err := loadFile(cfg, path); err != nil {
+		if !explicit && errors.Is(err, os.ErrNotExist) {
+			log.Printf("未找到配置文件 %s，仅使用环境变量", path)
+		} else {
+			return nil, err
+		}
 	}
-	return value
-}
 
+	if err := applyEnv(cfg); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
@@ -0,0 +1,157 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// fieldVisitor 遍历配置字段时的回调，path 为 yaml 键路径（例如 server.port）
+type fieldVisitor func(path string, field reflect.StructField, value reflect.Value) error
+
+// walkFields 递归遍历结构体中的叶子字段（嵌套结构体会展开，切片不展开）
+func walkFields(v reflect.Value, prefix string, visit fieldVisitor) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		path := yamlKey(field)
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+
+		value := v.Field(i)
+		if field.Type.Kind() == reflect.Struct {
+			if err := walkFields(value, path, visit); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := visit(path, field, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// yamlKey 获取字段在配置文件中的键名
+func yamlKey(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if name == "" {
+		return strings.ToLower(field.Name)
+	}
+	return name
+}
+
+// applyDefaults 根据 default 标签填充默认值
+func applyDefaults(cfg *Config) error {
+	return walkFields(reflect.ValueOf(cfg).Elem(), "", func(path string, field reflect.StructField, value reflect.Value) error {
+		def, ok := field.Tag.Lookup("default")
+		if !ok {
+			return nil
+		}
+		if err := setFromString(value, def); err != nil {
+			return fmt.Errorf("%s 的默认值无效: %w", path, err)
+		}
+		return nil
+	})
+}
+
+// loadFile 从 YAML 文件加载配置，文件中出现未知的键会报错
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取配置文件失败: %w", err)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("解析配置文件 %s 失败: %w", path, err)
+	}
+	return nil
+}
+
+// applyEnv 用环境变量覆盖配置，所有格式错误会合并返回
+func applyEnv(cfg *Config) error {
+	var errs []string
+	_ = walkFields(reflect.ValueOf(cfg).Elem(), "", func(path string, field reflect.StructField, value reflect.Value) error {
+		key := field.Tag.Get("env")
+		if key == "" {
+			return nil
+		}
+		raw, ok := os.LookupEnv(key)
+		if !ok || raw == "" {
+			return nil
+		}
+		if err := setFromString(value, raw); err != nil {
+			errs = append(errs, fmt.Sprintf("环境变量 %s（%s）无效: %v", key, path, err))
+		}
+		return nil
+	})
+	if len(errs) > 0 {
+		return &ValidationError{Problems: errs}
+	}
+	return nil
+}
+
+// setFromString 将字符串解析为字段对应的类型并赋值
+func setFromString(value reflect.Value, raw string) error {
+	if value.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(d))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		value.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		value.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		value.SetFloat(f)
+	case reflect.Slice:
+		if value.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("不支持通过字符串设置 %s", value.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		value.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("不支持的配置类型 %s", value.Type())
+	}
+	return nil
+}
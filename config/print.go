@@ -0,0 +1,118 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MaskString 隐藏字符串的大部分内容，只显示前4位和后4位
+func MaskString(s string) string {
+	if s == "" {
+		return ""
+	}
+	if len(s) <= 8 {
+		return "****"
+	}
+	return s[:4] + "..." + s[len(s)-4:]
+}
+
+// Masked 返回配置的副本，其中 secret 字段已被掩码
+func (c *Config) Masked() *Config {
+	masked := *c
+	masked.Admin.Owners = append([]string(nil), c.Admin.Owners...)
+	masked.Admin.Users = append([]string(nil), c.Admin.Users...)
+	masked.Lark.Apps = append([]LarkAppConfig(nil), c.Lark.Apps...)
+	maskSecrets(reflect.ValueOf(&masked).Elem())
+	return &masked
+}
+
+// maskSecrets 递归掩码带 secret 标签的字符串字段（包括切片中的结构体）
+func maskSecrets(v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		value := v.Field(i)
+		switch {
+		case field.Type.Kind() == reflect.Struct:
+			maskSecrets(value)
+		case field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.Struct:
+			for j := 0; j < value.Len(); j++ {
+				maskSecrets(value.Index(j))
+			}
+		case field.Type.Kind() == reflect.String && field.Tag.Get("secret") == "true":
+			value.SetString(MaskString(value.String()))
+		}
+	}
+}
+
+// Print 以 YAML 格式输出生效的配置（敏感字段已掩码）
+func (c *Config) Print(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(c.Masked()); err != nil {
+		return fmt.Errorf("输出配置失败: %w", err)
+	}
+	return enc.Close()
+}
+
+// Schema 根据 Config 结构体生成 Markdown 格式的配置说明
+func Schema() string {
+	var b strings.Builder
+	b.WriteString("| 配置项 | 环境变量 | 类型 | 默认值 | 说明 |\n")
+	b.WriteString("| --- | --- | --- | --- | --- |\n")
+	writeSchema(&b, reflect.TypeOf(Config{}), "")
+	return b.String()
+}
+
+// writeSchema 递归输出结构体字段说明
+func writeSchema(b *strings.Builder, t reflect.Type, prefix string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		path := yamlKey(field)
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+
+		switch {
+		case field.Type.Kind() == reflect.Struct:
+			writeSchema(b, field.Type, path)
+			continue
+		case field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.Struct:
+			writeSchema(b, field.Type.Elem(), path+"[]")
+			continue
+		}
+
+		desc := field.Tag.Get("desc")
+		if field.Tag.Get("required") == "true" {
+			desc = "**必填** " + desc
+		}
+		if field.Tag.Get("secret") == "true" {
+			desc += "（敏感，输出时掩码）"
+		}
+		fmt.Fprintf(b, "| `%s` | %s | %s | %s | %s |\n",
+			path, code(field.Tag.Get("env")), typeName(field.Type), code(field.Tag.Get("default")), desc)
+	}
+}
+
+// typeName 配置项类型的展示名
+func typeName(t reflect.Type) string {
+	if t == durationType {
+		return "duration"
+	}
+	if t.Kind() == reflect.Slice {
+		return "list<" + typeName(t.Elem()) + ">"
+	}
+	return t.Kind().String()
+}
+
+// code 用反引号包裹非空值
+func code(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "`" + s + "`"
+}
@@ -0,0 +1,258 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// ValidationError 配置校验错误，包含所有问题而不是遇到第一个就返回
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("配置校验失败（%d 项）:\n  - %s", len(e.Problems), strings.Join(e.Problems, "\n  - "))
+}
+
+// Validate 校验配置，返回 *ValidationError
+func (c *Config) Validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	// 必填字段
+	_ = walkFields(reflect.ValueOf(c).Elem(), "", func(path string, field reflect.StructField, value reflect.Value) error {
+		if field.Tag.Get("required") == "true" && value.IsZero() {
+			if env := field.Tag.Get("env"); env != "" {
+				add("%s 不能为空（环境变量 %s）", path, env)
+			} else {
+				add("%s 不能为空", path)
+			}
+		}
+		return nil
+	})
+
+	apps := c.Lark.AllApps()
+	if len(apps) == 0 {
+		add("至少需要配置一个飞书应用（lark.app_id/app_secret 或 lark.apps，环境变量 APP_ID/APP_SECRET）")
+	}
+	appNames := make(map[string]bool, len(apps))
+	appIDs := make(map[string]bool, len(apps))
+	for _, app := range apps {
+		switch {
+		case app.Name == "":
+			add("lark.apps 中存在未命名的应用")
+		case appNames[app.Name]:
+			add("lark.apps 中存在重复的名称 %q（%q 保留给默认应用）", app.Name, DefaultAppName)
+		}
+		appNames[app.Name] = true
+
+		if app.AppID == "" || app.AppSecret == "" {
+			add("飞书应用 %q 的 app_id 和 app_secret 不能为空", app.Name)
+			continue
+		}
+		if appIDs[app.AppID] {
+			add("飞书应用 %q 的 app_id 与其他应用重复", app.Name)
+		}
+		appIDs[app.AppID] = true
+	}
+	for _, list := range []struct {
+		path string
+		ids  []string
+	}{{"admin.owners", c.Admin.Owners}, {"admin.users", c.Admin.Users}} {
+		for _, entry := range list.ids {
+			app, _, ok := strings.Cut(strings.TrimSpace(entry), ":")
+			if ok && !appNames[app] && !appIDs[app] {
+				add("%s 中的 %q 引用了未配置的飞书应用 %q", list.path, entry, app)
+			}
+		}
+	}
+
+	if port, err := strconv.Atoi(c.Server.Port); err != nil || port < 1 || port > 65535 {
+		add("server.port 必须是 1-65535 之间的整数，当前为 %q", c.Server.Port)
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		add("server.shutdown_timeout 必须大于 0")
+	}
+
+	switch c.AppEnv {
+	case "development", "staging", "production":
+	default:
+		add("app_env 必须是 development/staging/production 之一，当前为 %q", c.AppEnv)
+	}
+	if c.AppEnv == "production" && c.Security.APIKey == "" {
+		add("production 环境必须配置 security.api_key，否则 HTTP 管理接口无需认证即可调用")
+	}
+
+	if c.Database.Path == "" {
+		add("database.path 不能为空")
+	}
+
+	if c.Events.Workers < 1 {
+		add("events.workers 至少为 1")
+	}
+	if c.Events.QueueSize < 1 {
+		add("events.queue_size 至少为 1")
+	}
+	if c.Events.QueueSaturation <= 0 || c.Events.QueueSaturation > 1 {
+		add("events.queue_saturation 必须在 (0, 1] 之间")
+	}
+
+	if c.Scheduler.Interval < time.Second {
+		add("scheduler.interval 不能小于 1s")
+	}
+	if _, err := time.LoadLocation(c.Scheduler.DefaultTimezone); err != nil {
+		add("scheduler.default_timezone 无效: %q", c.Scheduler.DefaultTimezone)
+	}
+
+	if c.Flashcards.NewPerDay < 0 {
+		add("flashcards.new_per_day 不能小于 0")
+	}
+	if c.Flashcards.PushSchedule != "" {
+		if _, err := cron.ParseStandard(c.Flashcards.PushSchedule); err != nil {
+			add("flashcards.push_schedule 无效: %v", err)
+		}
+	}
+	if _, err := time.LoadLocation(c.Flashcards.Timezone); err != nil {
+		add("flashcards.timezone 无效: %q", c.Flashcards.Timezone)
+	}
+
+	if c.Quizzes.BattleTimeout < 5*time.Second || c.Quizzes.BattleTimeout > 10*time.Minute {
+		add("quizzes.battle_timeout 应在 5s 到 10m 之间: %s", c.Quizzes.BattleTimeout)
+	}
+	if c.Quizzes.BattleMaxQuestions <= 0 {
+		add("quizzes.battle_max_questions 必须大于 0")
+	}
+
+	if _, err := time.LoadLocation(c.Progress.Timezone); err != nil {
+		add("progress.timezone 无效: %q", c.Progress.Timezone)
+	}
+	if c.Progress.LeaderboardSize <= 0 {
+		add("progress.leaderboard_size 必须大于 0")
+	}
+
+	if c.Glossary.DailySchedule != "" {
+		if _, err := cron.ParseStandard(c.Glossary.DailySchedule); err != nil {
+			add("glossary.daily_schedule 无效: %v", err)
+		}
+	}
+	if _, err := time.LoadLocation(c.Glossary.Timezone); err != nil {
+		add("glossary.timezone 无效: %q", c.Glossary.Timezone)
+	}
+
+	switch c.MarketData.Provider {
+	case "local":
+	case "http":
+		if u, err := url.Parse(c.MarketData.HTTPURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("marketdata.http_url 无效（provider 为 http 时必填，例如 http://127.0.0.1:9300）: %q", c.MarketData.HTTPURL)
+		}
+		if c.MarketData.HTTPTimeout <= 0 {
+			add("marketdata.http_timeout 必须大于 0")
+		}
+	default:
+		add("marketdata.provider 无效: %q（应为 local 或 http）", c.MarketData.Provider)
+	}
+	if c.MarketData.QuoteTTL < 0 || c.MarketData.HistoryTTL < 0 || c.MarketData.SearchTTL < 0 {
+		add("marketdata 的缓存时间不能小于 0")
+	}
+
+	if c.Quote.UpColor != "red" && c.Quote.UpColor != "green" {
+		add("quote.up_color 无效: %q（应为 red 或 green）", c.Quote.UpColor)
+	}
+	if _, err := time.LoadLocation(c.Quote.Timezone); err != nil {
+		add("quote.timezone 无效: %q", c.Quote.Timezone)
+	}
+
+	if c.Watchlist.MorningSchedule != "" {
+		if _, err := cron.ParseStandard(c.Watchlist.MorningSchedule); err != nil {
+			add("watchlist.morning_schedule 无效: %v", err)
+		}
+	}
+	if c.Watchlist.EveningSchedule != "" {
+		if _, err := cron.ParseStandard(c.Watchlist.EveningSchedule); err != nil {
+			add("watchlist.evening_schedule 无效: %v", err)
+		}
+	}
+	if _, err := time.LoadLocation(c.Watchlist.Timezone); err != nil {
+		add("watchlist.timezone 无效: %q", c.Watchlist.Timezone)
+	}
+	if c.Watchlist.MaxSymbols <= 0 {
+		add("watchlist.max_symbols 必须大于 0")
+	}
+
+	if c.Alerts.Interval <= 0 {
+		add("alerts.interval 必须大于 0")
+	}
+	if c.Alerts.HysteresisPercent < 0 || c.Alerts.HysteresisPoints < 0 {
+		add("alerts 的回差不能小于 0")
+	}
+	if c.Alerts.MaxRules <= 0 {
+		add("alerts.max_rules 必须大于 0")
+	}
+	if _, err := time.LoadLocation(c.Alerts.Timezone); err != nil {
+		add("alerts.timezone 无效: %q", c.Alerts.Timezone)
+	}
+
+	if c.Backup.Dir == "" {
+		add("backup.dir 不能为空")
+	}
+	if c.Backup.Schedule != "" {
+		if _, err := cron.ParseStandard(c.Backup.Schedule); err != nil {
+			add("backup.schedule 无效: %v", err)
+		}
+	}
+	if c.Backup.Keep < 0 {
+		add("backup.keep 不能小于 0")
+	}
+	if c.Backup.Encrypt && c.Security.SecretKey == "" {
+		add("backup.encrypt 需要配置 security.secret_key（环境变量 SECRET_KEY）")
+	}
+
+	if c.Encryption.Enabled && c.Encryption.KeyFile == "" && c.Security.SecretKey == "" {
+		add("encryption.enabled 需要配置 encryption.key_file 或 security.secret_key")
+	}
+	if id := c.Encryption.PrimaryKeyID; id == "" || strings.ContainsAny(id, " \t") {
+		add("encryption.primary_key_id 不能为空且不能包含空白字符")
+	}
+	if c.Encryption.ReencryptInterval < time.Second {
+		add("encryption.reencrypt_interval 不能小于 1s")
+	}
+	if c.Encryption.ReencryptBatch < 1 {
+		add("encryption.reencrypt_batch 至少为 1")
+	}
+
+	if c.Retention.MessagesPerChat < 0 {
+		add("retention.messages_per_chat 不能为负数")
+	}
+	if c.Retention.MessageMaxAge < 0 {
+		add("retention.message_max_age 不能为负数")
+	}
+
+	if c.RateLimit.Enabled {
+		if c.RateLimit.UserRate <= 0 || c.RateLimit.ChatRate <= 0 || c.RateLimit.GlobalRate <= 0 {
+			add("rate_limit 的 user_rate/chat_rate/global_rate 必须大于 0")
+		}
+		if c.RateLimit.UserBurst < 1 || c.RateLimit.ChatBurst < 1 || c.RateLimit.GlobalBurst < 1 {
+			add("rate_limit 的 user_burst/chat_burst/global_burst 至少为 1")
+		}
+	}
+	if c.RateLimit.ReplyCooldown < 0 {
+		add("rate_limit.reply_cooldown 不能为负数")
+	}
+
+	if c.Recorder.Enabled && c.Recorder.Path == "" {
+		add("recorder.path 不能为空")
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
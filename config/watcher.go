@@ -0,0 +1,235 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"fin_bot/metrics"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDebounce 文件变化后等待的时间，合并编辑器保存时产生的多次事件
+const reloadDebounce = 500 * time.Millisecond
+
+// ChangeFunc 配置变更回调，old 和 new 均为只读快照
+type ChangeFunc func(old, new *Config)
+
+// subscriber 配置变更订阅者
+type subscriber struct {
+	name string
+	fn   ChangeFunc
+}
+
+// Watcher 监听配置文件变化和 SIGHUP 信号，校验通过后原子替换配置快照并通知订阅者
+type Watcher struct {
+	path    string
+	current atomic.Pointer[Config]
+
+	mu          sync.Mutex // 串行化 Reload 和订阅
+	subscribers []subscriber
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewWatcher 创建配置监听器，path 为空时只响应 SIGHUP 并按 Load 的规则查找默认配置文件
+func NewWatcher(path string, initial *Config) *Watcher {
+	w := &Watcher{path: path}
+	w.current.Store(initial)
+	return w
+}
+
+// Current 获取当前生效的配置快照（调用方不应修改）
+func (w *Watcher) Current() *Config {
+	return w.current.Load()
+}
+
+// Subscribe 订阅配置变更，回调在 Reload 所在的 goroutine 中同步执行
+func (w *Watcher) Subscribe(name string, fn ChangeFunc) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.subscribers = append(w.subscribers, subscriber{name: name, fn: fn})
+}
+
+// Reload 重新加载配置
+// 校验失败时保留当前配置并返回错误；不可热更新的字段保留原值，并逐项记录拒绝原因
+func (w *Watcher) Reload() (changed []string, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	defer func() {
+		switch {
+		case err != nil:
+			metrics.ConfigReloads.WithLabelValues("error").Inc()
+		case len(changed) == 0:
+			metrics.ConfigReloads.WithLabelValues("unchanged").Inc()
+		default:
+			metrics.ConfigReloads.WithLabelValues("success").Inc()
+		}
+	}()
+
+	next, err := Load(w.path)
+	if err != nil {
+		log.Printf("[config] 热加载失败，继续使用当前配置: %v", err)
+		return nil, err
+	}
+
+	old := w.current.Load()
+	for _, path := range keepImmutable(old, next) {
+		log.Printf("[config] 拒绝热更新 %s: 该配置需要重启进程才能生效，已保留原值", path)
+	}
+
+	changed = Diff(old, next)
+	if len(changed) == 0 {
+		log.Println("[config] 配置未发生变化")
+		return nil, nil
+	}
+
+	w.current.Store(next)
+	log.Printf("[config] 配置已热更新: %v", changed)
+
+	for _, sub := range w.subscribers {
+		w.notify(sub, old, next)
+	}
+	return changed, nil
+}
+
+// notify 调用单个订阅者，避免某个订阅者 panic 影响其他订阅者
+func (w *Watcher) notify(sub subscriber, old, next *Config) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[config] 订阅者 %s 处理配置变更时 panic: %v", sub.name, r)
+		}
+	}()
+	sub.fn(old, next)
+}
+
+// Start 在后台监听配置文件和 SIGHUP 信号
+func (w *Watcher) Start(ctx context.Context) error {
+	watchPath := w.path
+	if watchPath == "" {
+		watchPath = DefaultConfigFile
+	}
+
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("创建文件监听失败: %w", err)
+	}
+	// 监听所在目录而不是文件本身，兼容编辑器先写临时文件再重命名的保存方式
+	if err := fsWatcher.Add(filepath.Dir(watchPath)); err != nil {
+		fsWatcher.Close()
+		return fmt.Errorf("监听配置目录失败: %w", err)
+	}
+
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	w.cancel = cancel
+	w.done = make(chan struct{})
+	go w.loop(runCtx, fsWatcher, sighup, filepath.Clean(watchPath))
+
+	log.Printf("[config] 已开始监听配置变更: file=%s, signal=SIGHUP", watchPath)
+	return nil
+}
+
+// Stop 停止监听
+func (w *Watcher) Stop(ctx context.Context) error {
+	if w.cancel == nil {
+		return nil
+	}
+	w.cancel()
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// loop 监听主循环
+func (w *Watcher) loop(ctx context.Context, fsWatcher *fsnotify.Watcher, sighup chan os.Signal, file string) {
+	defer close(w.done)
+	defer fsWatcher.Close()
+	defer signal.Stop(sighup)
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sighup:
+			log.Println("[config] 收到 SIGHUP，重新加载配置")
+			w.Reload()
+		case event, ok := <-fsWatcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) == file && event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+				debounce = time.After(reloadDebounce)
+			}
+		case <-debounce:
+			debounce = nil
+			log.Printf("[config] 检测到配置文件变化: %s", file)
+			w.Reload()
+		case err, ok := <-fsWatcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("[config] 文件监听出错: %v", err)
+		}
+	}
+}
+
+// keepImmutable 将 next 中不可热更新的字段恢复为 old 的值，返回被拒绝修改的字段路径
+func keepImmutable(old, next *Config) []string {
+	oldValue := reflect.ValueOf(old).Elem()
+	var rejected []string
+	_ = walkFields(reflect.ValueOf(next).Elem(), "", func(path string, field reflect.StructField, value reflect.Value) error {
+		if field.Tag.Get("immutable") != "true" {
+			return nil
+		}
+		oldField := fieldByPath(oldValue, path)
+		if !reflect.DeepEqual(oldField.Interface(), value.Interface()) {
+			rejected = append(rejected, path)
+			value.Set(oldField)
+		}
+		return nil
+	})
+	return rejected
+}
+
+// Diff 返回两份配置中取值不同的字段路径
+func Diff(old, next *Config) []string {
+	oldValue := reflect.ValueOf(old).Elem()
+	var changed []string
+	_ = walkFields(reflect.ValueOf(next).Elem(), "", func(path string, field reflect.StructField, value reflect.Value) error {
+		if !reflect.DeepEqual(fieldByPath(oldValue, path).Interface(), value.Interface()) {
+			changed = append(changed, path)
+		}
+		return nil
+	})
+	return changed
+}
+
+// fieldByPath 按 yaml 键路径查找字段
+func fieldByPath(v reflect.Value, path string) reflect.Value {
+	var found reflect.Value
+	_ = walkFields(v, "", func(p string, field reflect.StructField, value reflect.Value) error {
+		if p == path {
+			found = value
+		}
+		return nil
+	})
+	return found
+}
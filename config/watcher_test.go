@@ -0,0 +1,132 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newTestConfig 创建只填充默认值的配置
+func newTestConfig(t *testing.T) *Config {
+	t.Helper()
+	cfg := &Config{}
+	if err := applyDefaults(cfg); err != nil {
+		t.Fatalf("applyDefaults: %v", err)
+	}
+	return cfg
+}
+
+func TestKeepImmutable(t *testing.T) {
+	tests := []struct {
+		name         string
+		modify       func(c *Config)
+		wantDiff     []string // keepImmutable 之前的差异
+		wantRejected []string
+		wantChanged  []string // keepImmutable 之后的差异
+	}{
+		{
+			name:   "没有修改",
+			modify: func(c *Config) {},
+		},
+		{
+			name:        "只修改可热更新字段",
+			modify:      func(c *Config) { c.Scheduler.Interval = time.Minute },
+			wantDiff:    []string{"scheduler.interval"},
+			wantChanged: []string{"scheduler.interval"},
+		},
+		{
+			name:         "只修改不可热更新字段",
+			modify:       func(c *Config) { c.Server.Port = "9090" },
+			wantDiff:     []string{"server.port"},
+			wantRejected: []string{"server.port"},
+		},
+		{
+			name: "同时修改两类字段",
+			modify: func(c *Config) {
+				c.Server.Port = "9090"
+				c.Scheduler.Interval = time.Minute
+			},
+			wantDiff:     []string{"server.port", "scheduler.interval"},
+			wantRejected: []string{"server.port"},
+			wantChanged:  []string{"scheduler.interval"},
+		},
+		{
+			name:        "切片字段",
+			modify:      func(c *Config) { c.Admin.Users = []string{"ou_1"} },
+			wantDiff:    []string{"admin.users"},
+			wantChanged: []string{"admin.users"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old, next := newTestConfig(t), newTestConfig(t)
+			tt.modify(next)
+
+			if got := Diff(old, next); !reflect.DeepEqual(got, tt.wantDiff) {
+				t.Errorf("修改后 Diff = %v, want %v", got, tt.wantDiff)
+			}
+			if got := keepImmutable(old, next); !reflect.DeepEqual(got, tt.wantRejected) {
+				t.Errorf("keepImmutable = %v, want %v", got, tt.wantRejected)
+			}
+			if got := Diff(old, next); !reflect.DeepEqual(got, tt.wantChanged) {
+				t.Errorf("保留原值后 Diff = %v, want %v", got, tt.wantChanged)
+			}
+			if next.Server.Port != old.Server.Port {
+				t.Errorf("server.port = %q, 应保留原值 %q", next.Server.Port, old.Server.Port)
+			}
+		})
+	}
+}
+
+// TestReloadKeepsImmutable 热加载时拒绝修改不可热更新的字段，其余修改正常生效并通知订阅者
+func TestReloadKeepsImmutable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(port, interval string) {
+		t.Helper()
+		content := "lark:\n  app_id: cli_test\n  app_secret: secret\nserver:\n  port: \"" + port + "\"\nscheduler:\n  interval: " + interval + "\n"
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("8080", "30s")
+	initial, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	w := NewWatcher(path, initial)
+	var notified []string
+	w.Subscribe("test", func(old, next *Config) {
+		notified = append(notified, old.Scheduler.Interval.String()+"->"+next.Scheduler.Interval.String())
+	})
+
+	write("9090", "1m")
+	changed, err := w.Reload()
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if want := []string{"scheduler.interval"}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("changed = %v, want %v", changed, want)
+	}
+	cur := w.Current()
+	if cur.Server.Port != "8080" {
+		t.Errorf("server.port = %q, 应保留原值 8080", cur.Server.Port)
+	}
+	if cur.Scheduler.Interval != time.Minute {
+		t.Errorf("scheduler.interval = %v, want 1m", cur.Scheduler.Interval)
+	}
+	if want := []string{"30s->1m0s"}; !reflect.DeepEqual(notified, want) {
+		t.Errorf("订阅者收到 %v, want %v", notified, want)
+	}
+
+	// 只修改不可热更新字段时配置不变，也不通知订阅者
+	write("9091", "1m")
+	if changed, err := w.Reload(); err != nil || len(changed) != 0 {
+		t.Errorf("Reload = %v, %v, want 没有变化", changed, err)
+	}
+	if w.Current() != cur || len(notified) != 1 {
+		t.Error("没有生效的修改时不应替换快照或通知订阅者")
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"fin_bot/config"
+)
+
+// runConfigPrintCommand 输出生效的配置，校验失败时仍然输出，并在标准错误中列出问题
+func runConfigPrintCommand(configPath string, args []string) int {
+	fs := newCLIFlags("config print", "")
+	if code := fs.parse(args); code != exitOK {
+		return code
+	}
+
+	cfg, err := config.Load(configPath)
+	var verr *config.ValidationError
+	if err != nil && !errors.As(err, &verr) {
+		fmt.Fprintf(os.Stderr, "加载配置失败: %v\n", err)
+		return exitError
+	}
+	if err := cfg.Print(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	if verr != nil {
+		fmt.Fprintln(os.Stderr, verr)
+		return exitError
+	}
+	return exitOK
+}
+
+// runConfigSchemaCommand 输出配置项说明
+func runConfigSchemaCommand(configPath string, args []string) int {
+	fs := newCLIFlags("config schema", "")
+	if code := fs.parse(args); code != exitOK {
+		return code
+	}
+
+	fmt.Print(config.Schema())
+	return exitOK
+}
+
+// runConfigCheckCommand 校验配置（文件、环境变量和默认值合并后的结果），适合在部署前执行
+func runConfigCheckCommand(configPath string, args []string) int {
+	fs := newCLIFlags("config check", "")
+	if code := fs.parse(args); code != exitOK {
+		return code
+	}
+
+	cfg := loadConfig(configPath, true)
+	if cfg == nil {
+		return exitError
+	}
+	fmt.Printf("配置有效，共 %d 个飞书应用\n", len(cfg.Lark.AllApps()))
+	return exitOK
+}
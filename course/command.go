@@ -0,0 +1,159 @@
+package course
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"fin_bot/card"
+	"fin_bot/command"
+	"fin_bot/storage"
+)
+
+// Commands 返回学习课程的聊天命令（所有成员可用）
+func (s *Service) Commands() []*command.Command {
+	return []*command.Command{
+		{
+			Name:        "courses",
+			Usage:       "/courses",
+			Description: "查看所有课程和学习进度",
+			Handler:     s.commandCourses,
+		},
+		{
+			Name:        "enroll",
+			Usage:       "/enroll <课程>",
+			Description: "报名课程并设为当前学习的课程",
+			Handler:     s.commandEnroll,
+		},
+		{
+			Name:         "next",
+			Usage:        "/next",
+			Description:  "学习当前课程的下一课",
+			ReplyHandler: s.commandNext,
+		},
+		{
+			Name:         "lesson",
+			Usage:        "/lesson <编号>",
+			Description:  "查看指定编号的课",
+			ReplyHandler: s.commandLesson,
+		},
+	}
+}
+
+// commandCourses 处理 /courses 命令
+func (s *Service) commandCourses(ctx context.Context, req *command.Request) (string, error) {
+	progress, err := s.Courses(ctx, requestScope(req), req.SenderID)
+	if err != nil {
+		return "", err
+	}
+	if len(progress) == 0 {
+		return "还没有任何课程", nil
+	}
+
+	var b strings.Builder
+	b.WriteString("课程列表:")
+	var current *Progress
+	for _, p := range progress {
+		fmt.Fprintf(&b, "\n%s  《%s》 %d 课", p.Course.ID, p.Course.Title, p.Course.Lessons)
+		switch {
+		case p.Enrollment == nil:
+		case p.Enrollment.CompletedAt != nil:
+			b.WriteString("  ✅ 已学完")
+		default:
+			fmt.Fprintf(&b, "  已学 %d/%d", p.Viewed, p.Course.Lessons)
+		}
+		if p.Course.Description != "" {
+			fmt.Fprintf(&b, "\n    %s", p.Course.Description)
+		}
+		if p.Enrollment != nil && (current == nil || p.Enrollment.ActiveAt.After(current.Enrollment.ActiveAt)) {
+			current = p
+		}
+	}
+	if current != nil && current.Enrollment.CompletedAt == nil {
+		fmt.Fprintf(&b, "\n\n当前课程: %s，发送 /next 继续学习", current.Course.ID)
+	} else {
+		b.WriteString("\n\n发送 /enroll <课程> 报名")
+	}
+	return b.String(), nil
+}
+
+// commandEnroll 处理 /enroll 命令
+func (s *Service) commandEnroll(ctx context.Context, req *command.Request) (string, error) {
+	if req.RawArgs == "" {
+		return "", errors.New("缺少课程")
+	}
+	c, err := s.FindCourse(ctx, req.RawArgs)
+	if err != nil {
+		return "", err
+	}
+	created, err := s.Enroll(ctx, requestScope(req), req.SenderID, c.ID)
+	if err != nil {
+		return "", err
+	}
+
+	if !created {
+		return fmt.Sprintf("已切换到《%s》，发送 /next 继续学习", c.Title), nil
+	}
+	reply := fmt.Sprintf("已报名《%s》，共 %d 课", c.Title, c.Lessons)
+	if c.Intro != "" {
+		reply += "\n\n" + c.Intro
+	}
+	return reply + "\n\n发送 /next 开始学习第一课", nil
+}
+
+// commandNext 处理 /next 命令
+func (s *Service) commandNext(ctx context.Context, req *command.Request) (*command.Reply, error) {
+	if len(req.Args) > 0 {
+		return nil, errors.New("参数数量不正确")
+	}
+	d, err := s.Next(ctx, requestScope(req), req.SenderID)
+	if errors.Is(err, ErrNotEnrolled) {
+		return command.TextReply(err.Error()), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if d.Lesson == nil {
+		return command.TextReply(fmt.Sprintf("《%s》的 %d 课已全部学完，发送 /courses 选择新的课程，或 /lesson <编号> 复习",
+			d.Course.Title, d.Course.Lessons)), nil
+	}
+	return deliveryReply(d)
+}
+
+// commandLesson 处理 /lesson 命令
+func (s *Service) commandLesson(ctx context.Context, req *command.Request) (*command.Reply, error) {
+	if len(req.Args) != 1 {
+		return nil, errors.New("参数数量不正确")
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(req.Args[0], "#"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("无效的课程编号: %s", req.Args[0])
+	}
+	d, err := s.Lesson(ctx, requestScope(req), req.SenderID, id)
+	if err != nil {
+		return nil, err
+	}
+	return deliveryReply(d)
+}
+
+// deliveryReply 把推送的课渲染为卡片回复
+func deliveryReply(d *Delivery) (*command.Reply, error) {
+	cd := lessonCard(d.Course, d.Module, d.Lesson, d.Course.Lessons)
+	for _, q := range d.Quizzes {
+		cd.Add(card.Markdown(fmt.Sprintf("📝 本课测验《%s》%d 题，发送 /quiz %s 检验学习效果", q.Title, q.Questions, q.ID)))
+	}
+	switch {
+	case d.Completed:
+		cd.Add(card.Markdown(fmt.Sprintf("🎉 **恭喜学完《%s》全部 %d 课！** 发送 /courses 选择新的课程", d.Course.Title, d.Course.Lessons)))
+	case d.Lesson.Position < d.Course.Lessons:
+		cd.Add(card.Markdown("发送 /next 学习下一课"))
+	}
+	return command.CardReply(cd)
+}
+
+// requestScope 命令所属的应用和租户
+func requestScope(req *command.Request) storage.Scope {
+	return storage.Scope{AppID: req.AppID, TenantKey: req.TenantKey}
+}
@@ -0,0 +1,278 @@
+package course
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"fin_bot/progress"
+	"fin_bot/storage"
+)
+
+var (
+	// ErrCourseNotFound 课程不存在
+	ErrCourseNotFound = errors.New("课程不存在，发送 /courses 查看所有课程")
+	// ErrNotEnrolled 没有报名任何课程
+	ErrNotEnrolled = errors.New("还没有报名课程，发送 /courses 查看课程，/enroll <课程> 报名")
+)
+
+// Service 课程：导入课程文件，管理报名并按顺序推送课
+// 课程内容所有应用共用，报名和学习记录按应用、租户和用户隔离
+type Service struct {
+	store    *storage.Storage
+	progress *progress.Tracker // 记录学完课和课程的学习事件，为 nil 时不记录
+	now      func() time.Time
+}
+
+// New 创建课程服务
+func New(store *storage.Storage) *Service {
+	return &Service{store: store, now: time.Now}
+}
+
+// SetProgressTracker 设置学习进度，需要在开始处理消息之前调用
+func (s *Service) SetProgressTracker(t *progress.Tracker) {
+	s.progress = t
+}
+
+// ImportResult 导入一门课程的结果
+type ImportResult struct {
+	CourseID string `json:"course_id"`
+	Title    string `json:"title"`
+	Lessons  int    `json:"lessons"`
+	storage.CourseImportStats
+}
+
+// Import 导入目录中的所有课程（已有课程按 id 更新，目录中没有的课程保持不变）
+// 任何文件有问题时返回 *LoadError，不导入任何课程
+func (s *Service) Import(ctx context.Context, dir string) ([]ImportResult, error) {
+	courses, err := Load(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]ImportResult, 0, len(courses))
+	for _, imp := range courses {
+		stats, err := s.store.ImportCourse(ctx, imp)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, ImportResult{
+			CourseID:          imp.Course.ID,
+			Title:             imp.Course.Title,
+			Lessons:           len(imp.Lessons),
+			CourseImportStats: stats,
+		})
+		log.Printf("[course] 已导入课程 %s: 共 %d 课，新增 %d，更新 %d，删除 %d",
+			imp.Course.ID, len(imp.Lessons), stats.Added, stats.Updated, stats.Removed)
+	}
+	return results, nil
+}
+
+// Progress 用户在一门课程中的进度
+type Progress struct {
+	Course     *storage.Course
+	Enrollment *storage.Enrollment // 未报名时为 nil
+	Viewed     int                 // 已学的课数
+}
+
+// Courses 获取所有课程和用户在各课程中的进度
+func (s *Service) Courses(ctx context.Context, scope storage.Scope, userID string) ([]*Progress, error) {
+	courses, err := s.store.ListCourses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	enrollments, err := s.store.ListEnrollments(ctx, scope, userID)
+	if err != nil {
+		return nil, err
+	}
+	byCourse := make(map[string]*storage.Enrollment, len(enrollments))
+	for _, e := range enrollments {
+		byCourse[e.CourseID] = e
+	}
+
+	progress := make([]*Progress, 0, len(courses))
+	for _, c := range courses {
+		p := &Progress{Course: c, Enrollment: byCourse[c.ID]}
+		if p.Enrollment != nil {
+			views, err := s.store.ListLessonViews(ctx, scope, userID, c.ID)
+			if err != nil {
+				return nil, err
+			}
+			p.Viewed = len(views)
+		}
+		progress = append(progress, p)
+	}
+	return progress, nil
+}
+
+// FindCourse 按 id（不区分大小写）或标题查找课程
+func (s *Service) FindCourse(ctx context.Context, key string) (*storage.Course, error) {
+	courses, err := s.store.ListCourses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key = strings.Trim(strings.TrimSpace(key), "《》")
+	for _, c := range courses {
+		if strings.EqualFold(c.ID, key) || c.Title == key {
+			return c, nil
+		}
+	}
+	return nil, ErrCourseNotFound
+}
+
+// Enroll 报名课程并设为当前学习的课程，created 为 false 表示之前已经报名
+func (s *Service) Enroll(ctx context.Context, scope storage.Scope, userID, courseID string) (created bool, err error) {
+	return s.store.Enroll(ctx, scope, userID, courseID, s.now())
+}
+
+// Delivery 推送给用户的一节课
+type Delivery struct {
+	Course    *storage.Course
+	Module    *storage.CourseModule // 不分模块时为 nil
+	Lesson    *storage.Lesson
+	Completed bool                // 学完这节课后完成了整门课程
+	Quizzes   []*storage.QuizBank // 关联到这节课的题库
+}
+
+// Next 推送当前课程（最近报名或学习的课程）中第一节还没学过的课
+// 课程已全部学完时返回的 Delivery 中 Lesson 为 nil
+func (s *Service) Next(ctx context.Context, scope storage.Scope, userID string) (*Delivery, error) {
+	enrollments, err := s.store.ListEnrollments(ctx, scope, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(enrollments) == 0 {
+		return nil, ErrNotEnrolled
+	}
+	c, err := s.store.GetCourse(ctx, enrollments[0].CourseID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrCourseNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lessons, err := s.store.ListLessons(ctx, c.ID)
+	if err != nil {
+		return nil, err
+	}
+	views, err := s.store.ListLessonViews(ctx, scope, userID, c.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range lessons {
+		if _, ok := views[l.ID]; !ok {
+			return s.deliver(ctx, scope, userID, c, l, len(views)+1 == len(lessons))
+		}
+	}
+
+	// 课程更新后可能出现已完成但未标记的情况
+	if err := s.complete(ctx, scope, userID, c.ID, s.now()); err != nil {
+		return nil, err
+	}
+	return &Delivery{Course: c, Completed: true}, nil
+}
+
+// Lesson 按编号推送一节课；用户报名了该课程时记为已学
+func (s *Service) Lesson(ctx context.Context, scope storage.Scope, userID string, id int64) (*Delivery, error) {
+	l, err := s.store.GetLesson(ctx, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, fmt.Errorf("第 %d 课不存在", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c, err := s.store.GetCourse(ctx, l.CourseID)
+	if err != nil {
+		return nil, err
+	}
+
+	enrollments, err := s.store.ListEnrollments(ctx, scope, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range enrollments {
+		if e.CourseID != c.ID {
+			continue
+		}
+		views, err := s.store.ListLessonViews(ctx, scope, userID, c.ID)
+		if err != nil {
+			return nil, err
+		}
+		_, viewed := views[l.ID]
+		return s.deliver(ctx, scope, userID, c, l, !viewed && len(views)+1 == c.Lessons)
+	}
+
+	return s.delivery(ctx, c, l, false)
+}
+
+// deliver 记录用户学习了一节课，completes 为 true 时同时标记课程已学完
+func (s *Service) deliver(ctx context.Context, scope storage.Scope, userID string, c *storage.Course, l *storage.Lesson, completes bool) (*Delivery, error) {
+	now := s.now()
+	first, err := s.store.RecordLessonView(ctx, scope, userID, l.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	if first {
+		s.progress.Record(ctx, &storage.ProgressEvent{
+			AppID: scope.AppID, TenantKey: scope.TenantKey, UserID: userID,
+			Kind: storage.ProgressLessonCompleted, Ref: strconv.FormatInt(l.ID, 10), OccurredAt: now,
+		})
+	}
+	if err := s.store.TouchEnrollment(ctx, scope, userID, c.ID, now); err != nil {
+		return nil, err
+	}
+	if completes {
+		if err := s.complete(ctx, scope, userID, c.ID, now); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.delivery(ctx, c, l, completes)
+}
+
+// complete 标记课程已学完并记录学习事件（已记录过时不重复记录）
+func (s *Service) complete(ctx context.Context, scope storage.Scope, userID, courseID string, at time.Time) error {
+	if err := s.store.CompleteEnrollment(ctx, scope, userID, courseID, at); err != nil {
+		return err
+	}
+	s.progress.Record(ctx, &storage.ProgressEvent{
+		AppID: scope.AppID, TenantKey: scope.TenantKey, UserID: userID,
+		Kind: storage.ProgressCourseCompleted, Ref: courseID, OccurredAt: at,
+	})
+	return nil
+}
+
+// delivery 补充课所在的模块和关联的题库
+func (s *Service) delivery(ctx context.Context, c *storage.Course, l *storage.Lesson, completed bool) (*Delivery, error) {
+	module, err := s.module(ctx, l)
+	if err != nil {
+		return nil, err
+	}
+	quizzes, err := s.store.ListLessonQuizBanks(ctx, c.ID, l.Slug)
+	if err != nil {
+		return nil, err
+	}
+	return &Delivery{Course: c, Module: module, Lesson: l, Completed: completed, Quizzes: quizzes}, nil
+}
+
+// module 获取课所在的模块
+func (s *Service) module(ctx context.Context, l *storage.Lesson) (*storage.CourseModule, error) {
+	if l.ModuleID == "" {
+		return nil, nil
+	}
+	modules, err := s.store.ListCourseModules(ctx, l.CourseID)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range modules {
+		if m.ID == l.ModuleID {
+			return m, nil
+		}
+	}
+	return nil, nil
+}
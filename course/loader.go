@@ -0,0 +1,370 @@
+package course
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"fin_bot/storage"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 课程目录结构（每门课程一个子目录）:
+//
+//	<dir>/<course>/course.md             课程信息（front matter）和简介（正文）
+//	<dir>/<course>/<lesson>.md           不分模块的课
+//	<dir>/<course>/<module>/module.md    模块信息（可选）
+//	<dir>/<course>/<module>/<lesson>.md  模块中的课
+//
+// 同一层级按 front matter 中的 order、再按文件名排序，文件名可以用 01-、02- 等前缀控制顺序；
+// 未指定 id 时使用去掉数字前缀的文件名（或目录名）。以 . 或 _ 开头的文件和目录会被忽略
+const (
+	courseFile = "course.md"
+	moduleFile = "module.md"
+
+	// maxLessonBytes 单节课正文的最大长度，消息卡片的内容上限约 30KB
+	maxLessonBytes = 16 * 1024
+)
+
+var (
+	// idPattern 课程、模块和课的 id
+	idPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+	// orderPrefix 文件名中表示顺序的数字前缀，例如 01-
+	orderPrefix = regexp.MustCompile(`^\d+[-_. ]+`)
+)
+
+// courseMeta course.md 的 front matter
+type courseMeta struct {
+	ID          string `yaml:"id"`
+	Title       string `yaml:"title"`
+	Description string `yaml:"description"`
+	Order       int    `yaml:"order"`
+}
+
+// moduleMeta module.md 的 front matter
+type moduleMeta struct {
+	Title string `yaml:"title"`
+	Order int    `yaml:"order"`
+}
+
+// lessonMeta 课的 front matter
+type lessonMeta struct {
+	ID      string `yaml:"id"`
+	Title   string `yaml:"title"` // 为空时使用正文的第一个一级标题
+	Order   int    `yaml:"order"`
+	Minutes int    `yaml:"minutes"` // 预计学习时长（分钟）
+}
+
+// LoadError 课程文件校验错误，包含所有问题而不是遇到第一个就返回
+type LoadError struct {
+	Problems []string
+}
+
+func (e *LoadError) Error() string {
+	return fmt.Sprintf("课程文件校验失败（%d 项）:\n  - %s", len(e.Problems), strings.Join(e.Problems, "\n  - "))
+}
+
+// Load 读取目录中的所有课程，任何文件有问题时返回 *LoadError 且不返回任何课程
+func Load(dir string) ([]*storage.CourseImport, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取课程目录失败: %w", err)
+	}
+
+	l := &loader{}
+	var courses []*storage.CourseImport
+	ids := make(map[string]string)
+	for _, entry := range entries {
+		if !entry.IsDir() || ignored(entry.Name()) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		imp := l.loadCourse(path)
+		if imp == nil {
+			continue
+		}
+		if other, ok := ids[imp.Course.ID]; ok {
+			l.addf("%s: 课程 id %s 与 %s 重复", path, imp.Course.ID, other)
+			continue
+		}
+		ids[imp.Course.ID] = path
+		courses = append(courses, imp)
+	}
+
+	if len(l.problems) > 0 {
+		return nil, &LoadError{Problems: l.problems}
+	}
+	return courses, nil
+}
+
+// loader 读取课程时收集问题
+type loader struct {
+	problems []string
+}
+
+func (l *loader) addf(format string, args ...interface{}) {
+	l.problems = append(l.problems, fmt.Sprintf(format, args...))
+}
+
+// lessonFile 排序前的课
+type lessonFile struct {
+	lesson *storage.Lesson
+	order  int
+	name   string
+}
+
+// loadCourse 读取一门课程，有问题时记录并返回 nil
+func (l *loader) loadCourse(dir string) *storage.CourseImport {
+	var meta courseMeta
+	intro, ok := l.parseFile(filepath.Join(dir, courseFile), &meta)
+	if !ok {
+		return nil
+	}
+	if meta.ID == "" {
+		meta.ID = idFromName(filepath.Base(dir))
+	}
+	if !idPattern.MatchString(meta.ID) {
+		l.addf("%s: 课程 id %q 只能包含字母、数字、-、_ 和 .（可在 front matter 中指定 id）", dir, meta.ID)
+		return nil
+	}
+	if meta.Title == "" {
+		l.addf("%s: 缺少 title", filepath.Join(dir, courseFile))
+		return nil
+	}
+
+	imp := &storage.CourseImport{Course: &storage.Course{
+		ID:          meta.ID,
+		Title:       meta.Title,
+		Description: meta.Description,
+		Intro:       intro,
+		Position:    meta.Order,
+		Source:      dir,
+	}}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		l.addf("%s: %v", dir, err)
+		return nil
+	}
+
+	// 直接放在课程目录下的课排在所有模块之前
+	type moduleFiles struct {
+		module  *storage.CourseModule
+		order   int
+		name    string
+		lessons []lessonFile
+	}
+	var modules []*moduleFiles
+	var topLevel []lessonFile
+	for _, entry := range entries {
+		name := entry.Name()
+		path := filepath.Join(dir, name)
+		switch {
+		case ignored(name) || name == courseFile:
+		case entry.IsDir():
+			module, order := l.loadModule(meta.ID, path)
+			if module != nil {
+				modules = append(modules, &moduleFiles{module: module, order: order, name: name, lessons: l.loadLessons(path, module.ID)})
+			}
+		case strings.HasSuffix(name, ".md"):
+			if f, ok := l.loadLesson(path, ""); ok {
+				topLevel = append(topLevel, f)
+			}
+		}
+	}
+
+	sort.SliceStable(modules, func(i, j int) bool {
+		if modules[i].order != modules[j].order {
+			return modules[i].order < modules[j].order
+		}
+		return modules[i].name < modules[j].name
+	})
+	sortLessons(topLevel)
+	for _, f := range topLevel {
+		imp.Lessons = append(imp.Lessons, f.lesson)
+	}
+	for i, m := range modules {
+		m.module.Position = i + 1
+		imp.Modules = append(imp.Modules, m.module)
+		sortLessons(m.lessons)
+		for _, f := range m.lessons {
+			imp.Lessons = append(imp.Lessons, f.lesson)
+		}
+	}
+
+	if len(imp.Lessons) == 0 {
+		l.addf("%s: 课程中没有任何课", dir)
+		return nil
+	}
+	slugs := make(map[string]string)
+	for i, lesson := range imp.Lessons {
+		lesson.Position = i + 1
+		if other, ok := slugs[lesson.Slug]; ok {
+			l.addf("%s: 课的 id %s 与 %s 重复（同一课程中不能重复）", lesson.Source, lesson.Slug, other)
+		}
+		slugs[lesson.Slug] = lesson.Source
+	}
+	return imp
+}
+
+// loadModule 读取模块信息，module.md 不存在时使用目录名作为标题
+func (l *loader) loadModule(courseID, dir string) (*storage.CourseModule, int) {
+	module := &storage.CourseModule{CourseID: courseID, ID: idFromName(filepath.Base(dir))}
+	if !idPattern.MatchString(module.ID) {
+		l.addf("%s: 模块目录名 %q 只能包含字母、数字、-、_ 和 .", dir, filepath.Base(dir))
+		return nil, 0
+	}
+
+	var meta moduleMeta
+	path := filepath.Join(dir, moduleFile)
+	if _, err := os.Stat(path); err == nil {
+		if _, ok := l.parseFile(path, &meta); !ok {
+			return nil, 0
+		}
+	}
+	module.Title = meta.Title
+	if module.Title == "" {
+		module.Title = module.ID
+	}
+	return module, meta.Order
+}
+
+// loadLessons 读取模块目录中的课
+func (l *loader) loadLessons(dir, moduleID string) []lessonFile {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		l.addf("%s: %v", dir, err)
+		return nil
+	}
+	var files []lessonFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || ignored(name) || name == moduleFile || !strings.HasSuffix(name, ".md") {
+			continue
+		}
+		if f, ok := l.loadLesson(filepath.Join(dir, name), moduleID); ok {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// loadLesson 读取一节课
+func (l *loader) loadLesson(path, moduleID string) (lessonFile, bool) {
+	var meta lessonMeta
+	body, ok := l.parseFile(path, &meta)
+	if !ok {
+		return lessonFile{}, false
+	}
+	name := filepath.Base(path)
+	if meta.ID == "" {
+		meta.ID = idFromName(strings.TrimSuffix(name, ".md"))
+	}
+	if meta.Title == "" {
+		meta.Title, body = titleFromBody(body)
+	}
+
+	switch {
+	case !idPattern.MatchString(meta.ID):
+		l.addf("%s: 课的 id %q 只能包含字母、数字、-、_ 和 .（可在 front matter 中指定 id）", path, meta.ID)
+	case meta.Title == "":
+		l.addf("%s: 缺少 title（也可以用正文第一行的一级标题）", path)
+	case strings.TrimSpace(body) == "":
+		l.addf("%s: 正文为空", path)
+	case len(body) > maxLessonBytes:
+		l.addf("%s: 正文 %d 字节，超过上限 %d 字节，请拆分为多节课", path, len(body), maxLessonBytes)
+	case meta.Minutes < 0:
+		l.addf("%s: minutes 不能为负数", path)
+	default:
+		return lessonFile{
+			lesson: &storage.Lesson{
+				ModuleID: moduleID,
+				Slug:     meta.ID,
+				Title:    meta.Title,
+				Minutes:  meta.Minutes,
+				Body:     body,
+				Source:   path,
+			},
+			order: meta.Order,
+			name:  name,
+		}, true
+	}
+	return lessonFile{}, false
+}
+
+// parseFile 读取 Markdown 文件，把 front matter 解析到 meta，返回正文
+func (l *loader) parseFile(path string, meta interface{}) (string, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			l.addf("%s: 文件不存在", path)
+		} else {
+			l.addf("%s: %v", path, err)
+		}
+		return "", false
+	}
+	front, body := splitFrontMatter(data)
+	if front != nil {
+		dec := yaml.NewDecoder(bytes.NewReader(front))
+		dec.KnownFields(true)
+		if err := dec.Decode(meta); err != nil && !errors.Is(err, io.EOF) {
+			l.addf("%s: front matter 格式错误: %v", path, err)
+			return "", false
+		}
+	}
+	return body, true
+}
+
+// splitFrontMatter 拆分文件开头由 --- 包围的 YAML front matter 和正文，没有 front matter 时 front 为 nil
+func splitFrontMatter(data []byte) (front []byte, body string) {
+	text := strings.ReplaceAll(string(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))), "\r\n", "\n")
+	lines := strings.SplitAfter(text, "\n")
+	if strings.TrimSpace(lines[0]) != "---" {
+		return nil, strings.TrimSpace(text)
+	}
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "---" {
+			return []byte(strings.Join(lines[1:i], "")), strings.TrimSpace(strings.Join(lines[i+1:], ""))
+		}
+	}
+	return nil, strings.TrimSpace(text)
+}
+
+// titleFromBody 使用正文开头的一级标题作为标题，并从正文中去掉
+func titleFromBody(body string) (title, rest string) {
+	first, rest, _ := strings.Cut(body, "\n")
+	if !strings.HasPrefix(first, "# ") {
+		return "", body
+	}
+	return strings.TrimSpace(first[2:]), strings.TrimSpace(rest)
+}
+
+// idFromName 去掉文件名或目录名中的顺序前缀
+func idFromName(name string) string {
+	if id := orderPrefix.ReplaceAllString(name, ""); id != "" {
+		return id
+	}
+	return name
+}
+
+// ignored 是否忽略的文件或目录
+func ignored(name string) bool {
+	return strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")
+}
+
+// sortLessons 按 order 和文件名排序
+func sortLessons(files []lessonFile) {
+	sort.SliceStable(files, func(i, j int) bool {
+		if files[i].order != files[j].order {
+			return files[i].order < files[j].order
+		}
+		return files[i].name < files[j].name
+	})
+}
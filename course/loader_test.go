@@ -0,0 +1,180 @@
+package course
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeTree 在临时目录中按相对路径写入文件，返回目录
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoad(t *testing.T) {
+	dir := writeTree(t, map[string]string{
+		"01-bonds/course.md":                "---\ntitle: 债券入门\ndescription: 从票息到久期\n---\n课程简介",
+		"01-bonds/02-coupon.md":             "# 票息\n票息是债券按期支付的利息",
+		"01-bonds/01-what-is-bond.md":       "---\nminutes: 5\n---\n# 什么是债券\n债券是一种债务凭证",
+		"01-bonds/_draft.md":                "# 草稿\n不会被导入",
+		"01-bonds/02-risk/module.md":        "---\ntitle: 风险\n---\n",
+		"01-bonds/02-risk/credit.md":        "# 信用风险\n发行人违约的风险",
+		"01-bonds/02-risk/duration.md":      "---\nid: dur\norder: -1\n---\n# 久期\n价格对利率的敏感度",
+		"01-bonds/01-basics/yield.md":       "# 收益率\n到期收益率",
+		"01-bonds/03-extra/module.md":       "---\ntitle: 延伸阅读\norder: -1\n---\n",
+		"01-bonds/03-extra/history.md":      "# 债券的历史\n国债的起源",
+		"02-stocks/course.md":               "---\nid: equity\ntitle: 股票入门\n---\n",
+		"02-stocks/pe.md":                   "# 市盈率\n股价与每股收益之比",
+		".hidden/course.md":                 "不是课程",
+		"not-a-course.md":                   "课程目录中的文件会被忽略",
+		"01-bonds/01-basics/.swap.md":       "编辑器的临时文件",
+		"01-bonds/02-risk/notes/nested.md":  "模块中的子目录会被忽略",
+		"01-bonds/02-risk/not-markdown.txt": "不是 Markdown",
+	})
+
+	courses, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(courses) != 2 {
+		t.Fatalf("courses = %d, want 2", len(courses))
+	}
+
+	bonds := courses[0]
+	if bonds.Course.ID != "bonds" || bonds.Course.Title != "债券入门" || bonds.Course.Intro != "课程简介" {
+		t.Errorf("course = %+v", bonds.Course)
+	}
+	var modules []string
+	for _, m := range bonds.Modules {
+		modules = append(modules, m.ID+":"+m.Title)
+	}
+	// order 小的模块在前，没有 module.md 的模块使用目录名作为标题
+	if want := []string{"extra:延伸阅读", "basics:basics", "risk:风险"}; !reflect.DeepEqual(modules, want) {
+		t.Errorf("modules = %v, want %v", modules, want)
+	}
+	var lessons []string
+	for i, l := range bonds.Lessons {
+		if l.Position != i+1 {
+			t.Errorf("%s position = %d, want %d", l.Slug, l.Position, i+1)
+		}
+		lessons = append(lessons, l.ModuleID+"/"+l.Slug+":"+l.Title)
+	}
+	// 不分模块的课在前，同一层级先按 order 再按文件名
+	want := []string{
+		"/what-is-bond:什么是债券", "/coupon:票息",
+		"extra/history:债券的历史",
+		"basics/yield:收益率",
+		"risk/dur:久期", "risk/credit:信用风险",
+	}
+	if !reflect.DeepEqual(lessons, want) {
+		t.Errorf("lessons = %v, want %v", lessons, want)
+	}
+	if l := bonds.Lessons[0]; l.Minutes != 5 || l.Body != "债券是一种债务凭证" {
+		t.Errorf("lesson = %+v, want minutes 5 and the heading removed from the body", l)
+	}
+
+	if courses[1].Course.ID != "equity" {
+		t.Errorf("front matter 中的 id = %q, want equity", courses[1].Course.ID)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		problem string
+	}{
+		{
+			name:    "缺少 course.md",
+			files:   map[string]string{"bonds/coupon.md": "# 票息\n正文"},
+			problem: "course.md: 文件不存在",
+		},
+		{
+			name:    "front matter 不是合法的 YAML",
+			files:   map[string]string{"bonds/course.md": "---\ntitle: [债券\n---\n", "bonds/coupon.md": "# 票息\n正文"},
+			problem: "front matter 格式错误",
+		},
+		{
+			name:    "front matter 中有未知字段",
+			files:   map[string]string{"bonds/course.md": "---\ntitle: 债券\n---\n", "bonds/coupon.md": "---\ntitel: 票息\n---\n正文"},
+			problem: "coupon.md: front matter 格式错误",
+		},
+		{
+			name:    "front matter 没有结束标记时整个文件作为正文",
+			files:   map[string]string{"bonds/course.md": "---\ntitle: 债券\n", "bonds/coupon.md": "# 票息\n正文"},
+			problem: "course.md: 缺少 title",
+		},
+		{
+			name:    "课没有标题",
+			files:   map[string]string{"bonds/course.md": "---\ntitle: 债券\n---\n", "bonds/coupon.md": "正文没有一级标题"},
+			problem: "coupon.md: 缺少 title",
+		},
+		{
+			name:    "课的正文为空",
+			files:   map[string]string{"bonds/course.md": "---\ntitle: 债券\n---\n", "bonds/coupon.md": "# 票息\n"},
+			problem: "coupon.md: 正文为空",
+		},
+		{
+			name:    "课程中没有课",
+			files:   map[string]string{"bonds/course.md": "---\ntitle: 债券\n---\n"},
+			problem: "课程中没有任何课",
+		},
+		{
+			name:    "非法的 id",
+			files:   map[string]string{"bonds/course.md": "---\nid: 债券\ntitle: 债券\n---\n", "bonds/coupon.md": "# 票息\n正文"},
+			problem: `课程 id "债券"`,
+		},
+		{
+			name: "课程 id 重复",
+			files: map[string]string{
+				"01-bonds/course.md": "---\ntitle: 债券\n---\n", "01-bonds/coupon.md": "# 票息\n正文",
+				"02-bonds/course.md": "---\ntitle: 债券进阶\n---\n", "02-bonds/yield.md": "# 收益率\n正文",
+			},
+			problem: "课程 id bonds 与",
+		},
+		{
+			name: "同一课程中课的 id 重复",
+			files: map[string]string{
+				"bonds/course.md":      "---\ntitle: 债券\n---\n",
+				"bonds/01-coupon.md":   "# 票息\n正文",
+				"bonds/risk/coupon.md": "# 浮动票息\n正文",
+			},
+			problem: "课的 id coupon 与",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			courses, err := Load(writeTree(t, tt.files))
+			var loadErr *LoadError
+			if !errors.As(err, &loadErr) {
+				t.Fatalf("Load = %d courses, %v, want *LoadError", len(courses), err)
+			}
+			if courses != nil {
+				t.Errorf("出错时不应返回课程: %d", len(courses))
+			}
+			if !strings.Contains(err.Error(), tt.problem) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.problem)
+			}
+		})
+	}
+}
+
+func TestLoadMissingDir(t *testing.T) {
+	if _, err := Load(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Load on a missing directory should fail")
+	}
+}
@@ -0,0 +1,76 @@
+package course
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"fin_bot/card"
+	"fin_bot/storage"
+)
+
+var (
+	// headingPattern Markdown 标题，卡片的 Markdown 不支持标题，转换为加粗
+	headingPattern = regexp.MustCompile(`^#{1,6}\s+(.+?)\s*#*$`)
+	// imagePattern Markdown 图片，卡片中的图片需要先上传获得 img_key，这里转换为链接
+	imagePattern = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)[^)]*\)`)
+	// breakPattern 分隔线，拆分为多个 Markdown 元素并用卡片的分割线隔开
+	breakPattern = regexp.MustCompile(`^\s*(-{3,}|\*{3,}|_{3,})\s*$`)
+)
+
+// lessonCard 把一节课渲染为消息卡片
+// module 为 nil 表示不分模块；total 为课程的总课时，用于显示进度
+func lessonCard(c *storage.Course, module *storage.CourseModule, l *storage.Lesson, total int) *card.Card {
+	cd := card.New(l.Title, card.ColorBlue)
+	for i, section := range markdownSections(l.Body) {
+		if i > 0 {
+			cd.Add(card.Divider())
+		}
+		cd.Add(card.Markdown(section))
+	}
+
+	info := []string{"《" + c.Title + "》"}
+	if module != nil {
+		info = append(info, module.Title)
+	}
+	info = append(info, fmt.Sprintf("第 %d/%d 课", l.Position, total))
+	if l.Minutes > 0 {
+		info = append(info, fmt.Sprintf("约 %d 分钟", l.Minutes))
+	}
+	info = append(info, fmt.Sprintf("编号 %d", l.ID))
+	return cd.Add(card.Divider(), card.Note(strings.Join(info, " · ")))
+}
+
+// markdownSections 把课程正文转换为卡片支持的 Markdown，按分隔线拆分为多段（代码块中的内容保持不变）
+func markdownSections(body string) []string {
+	var sections []string
+	var current []string
+	inCode := false
+	flush := func() {
+		if text := strings.TrimSpace(strings.Join(current, "\n")); text != "" {
+			sections = append(sections, text)
+		}
+		current = current[:0]
+	}
+
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inCode = !inCode
+			current = append(current, line)
+			continue
+		}
+		switch {
+		case inCode:
+		case breakPattern.MatchString(line):
+			flush()
+			continue
+		case headingPattern.MatchString(line):
+			line = headingPattern.ReplaceAllString(line, "**$1**")
+		default:
+			line = imagePattern.ReplaceAllString(line, "[🖼 $1]($2)")
+		}
+		current = append(current, line)
+	}
+	flush()
+	return sections
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"fin_bot/config"
+	"fin_bot/course"
+)
+
+// runCoursesImportCommand 导入课程目录中的 Markdown 文件，-dry-run 时只校验文件
+func runCoursesImportCommand(configPath string, args []string) int {
+	fs := newCLIFlags("courses import", "[-dir 课程目录] [-db 数据库] [-dry-run] [-format text|json]")
+	dir := fs.String("dir", "", "课程目录（默认使用配置中的 courses.dir）")
+	dbPath := fs.String("db", "", "数据库文件路径（默认使用配置中的 database.path）")
+	dryRun := fs.Bool("dry-run", false, "只校验课程文件，不写入数据库")
+	format := fs.String("format", "text", "输出格式: text 或 json")
+	fs.logFlag()
+	if code := fs.parse(args); code != exitOK {
+		return code
+	}
+	if *format != "text" && *format != "json" {
+		return fs.usageError("无效的输出格式: %s", *format)
+	}
+
+	cfg := loadConfig(configPath, false)
+	if cfg == nil {
+		return exitError
+	}
+	if *dir == "" {
+		*dir = cfg.Courses.Dir
+	}
+
+	var results []course.ImportResult
+	if *dryRun {
+		courses, err := course.Load(*dir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+		for _, imp := range courses {
+			results = append(results, course.ImportResult{CourseID: imp.Course.ID, Title: imp.Course.Title, Lessons: len(imp.Lessons)})
+		}
+	} else {
+		store, err := openStorage(cfg, *dbPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "打开数据库失败: %v\n", err)
+			return exitError
+		}
+		defer store.Close()
+
+		results, err = course.New(store).Import(context.Background(), *dir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+	}
+
+	if *format == "json" {
+		if results == nil {
+			results = []course.ImportResult{}
+		}
+		if err := json.NewEncoder(os.Stdout).Encode(results); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+		return exitOK
+	}
+	for _, r := range results {
+		if *dryRun {
+			fmt.Printf("%s\t《%s》\t%d 课\n", r.CourseID, r.Title, r.Lessons)
+			continue
+		}
+		fmt.Printf("%s\t《%s》\t%d 课（新增 %d，更新 %d，未变 %d，删除 %d）\n",
+			r.CourseID, r.Title, r.Lessons, r.Added, r.Updated, r.Unchanged, r.Removed)
+	}
+	if *dryRun {
+		fmt.Fprintf(os.Stderr, "%s 中的 %d 门课程校验通过\n", *dir, len(results))
+	} else {
+		fmt.Fprintf(os.Stderr, "已从 %s 导入 %d 门课程\n", *dir, len(results))
+	}
+	return exitOK
+}
+
+// importCourses 服务启动和配置变更时自动导入课程目录，失败时保留数据库中已有的课程
+func importCourses(ctx context.Context, courses *course.Service, c config.CoursesConfig) {
+	if !c.ImportOnLoad {
+		return
+	}
+	if _, err := os.Stat(c.Dir); errors.Is(err, os.ErrNotExist) {
+		log.Printf("[course] 课程目录 %s 不存在，跳过导入", c.Dir)
+		return
+	}
+	results, err := courses.Import(ctx, c.Dir)
+	if err != nil {
+		log.Printf("[警告] 导入课程失败，继续使用已导入的课程: %v", err)
+		return
+	}
+	fmt.Printf("已导入 %d 门课程: %s\n", len(results), c.Dir)
+}
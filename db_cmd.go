@@ -0,0 +1,357 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"fin_bot/audit"
+	"fin_bot/backup"
+	"fin_bot/config"
+	"fin_bot/secure"
+	"fin_bot/storage"
+)
+
+// runMigrateCommand 执行未完成的数据库迁移，-status 时只输出当前版本和待执行的迁移
+func runMigrateCommand(configPath string, args []string) int {
+	fs := newCLIFlags("migrate", "[-db 数据库] [-status]")
+	dbPath := fs.String("db", "", "数据库文件路径（默认使用配置中的 database.path）")
+	status := fs.Bool("status", false, "只查看迁移状态，不执行迁移")
+	fs.logFlag()
+	if code := fs.parse(args); code != exitOK {
+		return code
+	}
+
+	cfg := loadConfig(configPath, false)
+	if cfg == nil {
+		return exitError
+	}
+	if *dbPath == "" {
+		*dbPath = cfg.Database.Path
+	}
+	// 查看状态时不创建新的数据库文件
+	if _, err := os.Stat(*dbPath); err != nil && *status {
+		fmt.Fprintf(os.Stderr, "数据库不可用: %v\n", err)
+		return exitError
+	}
+
+	store, err := storage.Open(*dbPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "打开数据库失败: %v\n", err)
+		return exitError
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	pending, err := store.PendingMigrations(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "检查迁移状态失败: %v\n", err)
+		return exitError
+	}
+
+	if *status || len(pending) == 0 {
+		version, err := store.SchemaVersion(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "获取数据库版本失败: %v\n", err)
+			return exitError
+		}
+		fmt.Printf("当前版本: %d\n", version)
+		if len(pending) == 0 {
+			fmt.Println("没有待执行的迁移")
+		}
+		for _, name := range pending {
+			fmt.Printf("待执行: %s\n", name)
+		}
+		return exitOK
+	}
+
+	if err := store.Migrate(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "迁移失败: %v\n", err)
+		return exitError
+	}
+	for _, name := range pending {
+		fmt.Printf("已执行: %s\n", name)
+	}
+	return exitOK
+}
+
+// runDBVacuumCommand 整理数据库文件，服务运行中也可以执行（期间写入会等待）
+func runDBVacuumCommand(configPath string, args []string) int {
+	fs := newCLIFlags("db vacuum", "[-db 数据库]")
+	dbPath := fs.String("db", "", "数据库文件路径（默认使用配置中的 database.path）")
+	fs.logFlag()
+	if code := fs.parse(args); code != exitOK {
+		return code
+	}
+
+	cfg := loadConfig(configPath, false)
+	if cfg == nil {
+		return exitError
+	}
+	store, err := openStorage(cfg, *dbPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "打开数据库失败: %v\n", err)
+		return exitError
+	}
+	defer store.Close()
+
+	start := time.Now()
+	if err := store.Vacuum(context.Background()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	fmt.Printf("数据库整理完成，耗时 %s\n", time.Since(start).Round(time.Millisecond))
+	return exitOK
+}
+
+// runDBBackupCommand 按 backup 配置生成备份：默认写入备份目录并清理旧备份，-o 指定路径时只写入该文件
+func runDBBackupCommand(configPath string, args []string) int {
+	fs := newCLIFlags("db backup", "[-o 备份文件] [-db 数据库]")
+	dbPath := fs.String("db", "", "数据库文件路径（默认使用配置中的 database.path）")
+	output := fs.String("o", "", "备份文件路径（默认写入 backup.dir，文件不能已存在）")
+	fs.logFlag()
+	if code := fs.parse(args); code != exitOK {
+		return code
+	}
+
+	cfg := loadConfig(configPath, false)
+	if cfg == nil {
+		return exitError
+	}
+	store, err := openStorage(cfg, *dbPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "打开数据库失败: %v\n", err)
+		return exitError
+	}
+	defer store.Close()
+
+	backups := newBackupManager(cfg, store)
+	ctx := audit.WithActor(context.Background(), audit.CLIActor())
+	var info *backup.Info
+	if *output != "" {
+		info, err = backups.CreateFile(ctx, *output)
+	} else {
+		info, err = backups.Create(ctx)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	path := *output
+	if path == "" {
+		path = filepath.Join(backups.Dir(), info.Name)
+	}
+	fmt.Printf("数据库已备份到 %s（%d 字节）\n", path, info.Size)
+	return exitOK
+}
+
+// runDBBackupsCommand 列出备份目录中的备份
+func runDBBackupsCommand(configPath string, args []string) int {
+	fs := newCLIFlags("db backups", "[-format text|json]")
+	format := fs.String("format", "text", "输出格式: text 或 json")
+	fs.logFlag()
+	if code := fs.parse(args); code != exitOK {
+		return code
+	}
+	if *format != "text" && *format != "json" {
+		return fs.usageError("无效的输出格式: %s", *format)
+	}
+
+	cfg := loadConfig(configPath, false)
+	if cfg == nil {
+		return exitError
+	}
+	backups, err := newBackupManager(cfg, nil).List()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	if *format == "json" {
+		if err := json.NewEncoder(os.Stdout).Encode(backups); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+		return exitOK
+	}
+	for _, info := range backups {
+		fmt.Printf("%s\t%s\t%d\n", info.Name, info.CreatedAt.Local().Format(time.RFC3339), info.Size)
+	}
+	fmt.Fprintf(os.Stderr, "%s 中共有 %d 个备份\n", cfg.Backup.Dir, len(backups))
+	return exitOK
+}
+
+// runDBRestoreCommand 从备份恢复数据库，恢复前会校验备份的完整性；执行前需要停止服务
+func runDBRestoreCommand(configPath string, args []string) int {
+	fs := newCLIFlags("db restore", "-from 备份文件 [-db 数据库] [-verify-only]")
+	from := fs.String("from", "", "备份文件路径，或备份目录中的备份文件名（必填）")
+	dbPath := fs.String("db", "", "恢复到的数据库文件路径（默认使用配置中的 database.path）")
+	verifyOnly := fs.Bool("verify-only", false, "只校验备份，不替换数据库")
+	fs.logFlag()
+	if code := fs.parse(args); code != exitOK {
+		return code
+	}
+	if *from == "" {
+		return fs.usageError("缺少 -from 参数")
+	}
+
+	cfg := loadConfig(configPath, false)
+	if cfg == nil {
+		return exitError
+	}
+	src := *from
+	if _, err := os.Stat(src); err != nil {
+		// 不是已存在的路径时按备份目录中的文件名查找
+		if _, err := os.Stat(filepath.Join(cfg.Backup.Dir, src)); err == nil {
+			src = filepath.Join(cfg.Backup.Dir, src)
+		}
+	}
+	if *dbPath == "" {
+		*dbPath = cfg.Database.Path
+	}
+
+	ctx := context.Background()
+	secret := []byte(cfg.Security.SecretKey)
+	if *verifyOnly {
+		version, err := backup.Verify(ctx, src, secret)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "备份校验失败: %v\n", err)
+			return exitError
+		}
+		fmt.Printf("备份校验通过: %s（数据库版本 %d）\n", src, version)
+		return exitOK
+	}
+
+	previous, err := backup.Restore(ctx, src, *dbPath, secret)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "恢复失败: %v\n", err)
+		return exitError
+	}
+	fmt.Printf("已从 %s 恢复数据库到 %s\n", src, *dbPath)
+	if previous != "" {
+		fmt.Printf("原数据库已保留为 %s\n", previous)
+	}
+
+	// 恢复操作记录到恢复后的数据库中；备份的结构版本较旧时需要先执行迁移，此时无法记录
+	store, err := openStorage(cfg, *dbPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "未能记录审计日志: %v\n", err)
+		return exitOK
+	}
+	defer store.Close()
+	audit.NewLogger(store).Record(audit.WithActor(ctx, audit.CLIActor()), storage.Scope{}, audit.ActionDataRestore,
+		*dbPath, fmt.Sprintf("from=%q previous=%q", src, previous))
+	return exitOK
+}
+
+// runDBReencryptCommand 立即把所有消息转换为当前的加密设置（与后台任务相同，适合轮换密钥后手动执行）
+func runDBReencryptCommand(configPath string, args []string) int {
+	fs := newCLIFlags("db reencrypt", "[-db 数据库]")
+	dbPath := fs.String("db", "", "数据库文件路径（默认使用配置中的 database.path）")
+	fs.logFlag()
+	if code := fs.parse(args); code != exitOK {
+		return code
+	}
+
+	cfg := loadConfig(configPath, false)
+	if cfg == nil {
+		return exitError
+	}
+	store, err := openStorage(cfg, *dbPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "打开数据库失败: %v\n", err)
+		return exitError
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	if _, err := configureStorage(ctx, cfg, store); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	n, failed, err := secure.NewReencryptJob(store, cfg.Encryption.ReencryptInterval, cfg.Encryption.ReencryptBatch).RunOnce(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "重新加密失败（已处理 %d 条）: %v\n", n, err)
+		return exitError
+	}
+	target := "明文"
+	if cfg.Encryption.Enabled {
+		target = "密钥 " + cfg.Encryption.PrimaryKeyID
+	}
+	fmt.Printf("已将 %d 条消息转换为%s\n", n, target)
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "有 %d 条消息无法解密而被跳过（密钥缺失或数据已损坏），详见日志\n", failed)
+		return exitError
+	}
+	return exitOK
+}
+
+// runDBKeygenCommand 生成一行密钥文件内容
+func runDBKeygenCommand(configPath string, args []string) int {
+	fs := newCLIFlags("db keygen", "-id 密钥ID")
+	id := fs.String("id", "", "密钥ID（必填，例如 k2）")
+	if code := fs.parse(args); code != exitOK {
+		return code
+	}
+	if *id == "" || strings.ContainsAny(*id, " \t") {
+		return fs.usageError("-id 不能为空且不能包含空白字符")
+	}
+
+	key, err := secure.GenerateKey()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	fmt.Printf("%s %s\n", *id, key)
+	return exitOK
+}
+
+// configureStorage 按配置设置消息加密和搜索索引规则，并确认已加密数据使用的密钥都可用
+// 返回的密钥集合为 nil 表示没有可用的密钥（未开启加密且未配置 security.secret_key）
+func configureStorage(ctx context.Context, cfg *config.Config, store *storage.Storage) (*secure.Keyring, error) {
+	var keyring *secure.Keyring
+	var err error
+	switch {
+	case cfg.Encryption.KeyFile != "":
+		keyring, err = secure.LoadKeyFile(cfg.Encryption.KeyFile, cfg.Encryption.PrimaryKeyID)
+	case cfg.Security.SecretKey != "":
+		keyring, err = secure.NewDerivedKeyring([]byte(cfg.Security.SecretKey), cfg.Encryption.PrimaryKeyID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("加载加密密钥失败: %w", err)
+	}
+	if keyring != nil {
+		store.SetCipher(keyring, cfg.Encryption.Enabled)
+	}
+	store.SetIndexer(secure.NewTermFilter(cfg.Search.IndexCommands, cfg.Search.IndexSymbols, cfg.Search.Keywords).Terms)
+
+	keyIDs, err := store.KeyIDsInUse(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range keyIDs {
+		if keyring == nil || !keyring.HasKey(id) {
+			return nil, fmt.Errorf("数据库中有使用密钥 %q 加密的消息，但未配置该密钥", id)
+		}
+	}
+	return keyring, nil
+}
+
+// newBackupManager 按配置创建备份管理器
+func newBackupManager(cfg *config.Config, store *storage.Storage) *backup.Manager {
+	return backup.NewManager(store, cfg.Backup.Dir, []byte(cfg.Security.SecretKey), backupSettings(cfg.Backup))
+}
+
+// backupSettings 从配置中提取可热更新的备份设置
+func backupSettings(c config.BackupConfig) backup.Settings {
+	return backup.Settings{
+		Schedule: c.Schedule,
+		Keep:     c.Keep,
+		Compress: c.Compress,
+		Encrypt:  c.Encrypt,
+	}
+}
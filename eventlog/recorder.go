@@ -0,0 +1,113 @@
+package eventlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Entry 录制文件中的一条事件（JSONL 的一行）
+type Entry struct {
+	RecordedAt time.Time       `json:"recorded_at"`
+	App        string          `json:"app"`
+	AppID      string          `json:"app_id"`
+	EventType  string          `json:"event_type"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// Recorder 将收到的原始事件脱敏后追加写入 JSONL 文件
+type Recorder struct {
+	path          string
+	redactContent bool
+
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// NewRecorder 打开录制文件（不存在时创建），redactContent 为 true 时脱敏消息正文
+func NewRecorder(path string, redactContent bool) (*Recorder, error) {
+	if dir := filepath.Dir(path); dir != "." && dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("创建录制目录失败: %w", err)
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("打开录制文件失败: %w", err)
+	}
+	return &Recorder{
+		path:          path,
+		redactContent: redactContent,
+		file:          file,
+		enc:           json.NewEncoder(file),
+	}, nil
+}
+
+// Record 录制一条事件
+func (r *Recorder) Record(app, appID, eventType string, payload []byte) error {
+	redacted, err := Redact(payload, r.redactContent)
+	if err != nil {
+		return fmt.Errorf("事件脱敏失败: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return os.ErrClosed
+	}
+	return r.enc.Encode(&Entry{
+		RecordedAt: time.Now(),
+		App:        app,
+		AppID:      appID,
+		EventType:  eventType,
+		Payload:    redacted,
+	})
+}
+
+// Close 关闭录制文件
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// ReadFile 按顺序读取录制文件中的事件，fn 返回错误时停止读取
+func ReadFile(path string, fn func(entry *Entry) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("打开录制文件失败: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return fmt.Errorf("解析录制文件第 %d 行失败: %w", line, err)
+		}
+		if err := fn(&entry); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取录制文件失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package eventlog
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// redactedText 脱敏后的消息正文
+const redactedText = "[已脱敏]"
+
+// Redact 对事件 JSON 脱敏
+//   - 始终移除 verification token 以及发送者、被 @ 用户的 user_id、union_id（open_id 按应用隔离，保留用于回放权限判断）
+//   - redactContent 为 true 时替换消息正文和被 @ 用户的名字，文本命令保留命令名，便于回放时复现命令路由
+func Redact(payload []byte, redactContent bool) ([]byte, error) {
+	var event map[string]interface{}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+
+	delete(event, "token")
+	if header, ok := event["header"].(map[string]interface{}); ok {
+		delete(header, "token")
+	}
+
+	body, _ := event["event"].(map[string]interface{})
+	if sender, ok := body["sender"].(map[string]interface{}); ok {
+		redactUserID(sender["sender_id"])
+	}
+
+	if message, ok := body["message"].(map[string]interface{}); ok {
+		mentions, _ := message["mentions"].([]interface{})
+		for _, m := range mentions {
+			mention, ok := m.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			redactUserID(mention["id"])
+			if redactContent {
+				mention["name"] = redactedText
+			}
+		}
+		if redactContent {
+			message["content"] = redactContentJSON(message["message_type"], message["content"])
+		}
+	}
+
+	return json.Marshal(event)
+}
+
+// redactUserID 移除用户ID中跨应用通用的 user_id 和 union_id
+func redactUserID(v interface{}) {
+	if id, ok := v.(map[string]interface{}); ok {
+		delete(id, "user_id")
+		delete(id, "union_id")
+	}
+}
+
+// redactContentJSON 替换消息 content，文本命令保留第一个词（命令名）
+func redactContentJSON(messageType, content interface{}) string {
+	if messageType != "text" {
+		return "{}"
+	}
+
+	var text struct {
+		Text string `json:"text"`
+	}
+	raw, _ := content.(string)
+	_ = json.Unmarshal([]byte(raw), &text)
+
+	redacted := redactedText
+	if fields := strings.Fields(text.Text); len(fields) > 0 {
+		// 群聊中命令前可能有 @机器人 的占位符
+		for i, f := range fields {
+			if strings.HasPrefix(f, "/") {
+				redacted = strings.Join(fields[:i+1], " ")
+				if i+1 < len(fields) {
+					redacted += " " + redactedText
+				}
+				break
+			}
+			if !strings.HasPrefix(f, "@_user_") {
+				break
+			}
+		}
+	}
+
+	out, _ := json.Marshal(map[string]string{"text": redacted})
+	return string(out)
+}
@@ -0,0 +1,175 @@
+package flashcard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"fin_bot/command"
+	"fin_bot/storage"
+)
+
+// Commands 返回记忆卡片的聊天命令（所有成员可用）
+func (s *Service) Commands() []*command.Command {
+	return []*command.Command{
+		{
+			Name:         "review",
+			Usage:        "/review [卡片组] | /review decks | /review push on|off",
+			Description:  "复习到期的记忆卡片、查看卡片组或开关每日提醒",
+			ReplyHandler: s.commandReview,
+		},
+	}
+}
+
+// commandReview 处理 /review 命令
+func (s *Service) commandReview(ctx context.Context, req *command.Request) (*command.Reply, error) {
+	scope := requestScope(req)
+	switch {
+	case len(req.Args) == 1 && strings.EqualFold(req.Args[0], "decks"):
+		return s.listDecks(ctx, scope, req.SenderID)
+	case len(req.Args) >= 1 && strings.EqualFold(req.Args[0], "push"):
+		return s.commandPush(ctx, scope, req)
+	case len(req.Args) > 1:
+		return nil, errors.New("参数数量不正确")
+	}
+
+	deckID := ""
+	if len(req.Args) == 1 {
+		deck, err := s.FindDeck(ctx, req.Args[0])
+		if err != nil {
+			return nil, err
+		}
+		deckID = deck.ID
+	}
+	return s.nextReply(ctx, scope, req.SenderID, deckID, "")
+}
+
+// commandPush 处理 /review push
+func (s *Service) commandPush(ctx context.Context, scope storage.Scope, req *command.Request) (*command.Reply, error) {
+	if len(req.Args) == 1 {
+		enabled, err := s.PushEnabled(ctx, scope, req.SenderID)
+		if err != nil {
+			return nil, err
+		}
+		status := "已关闭"
+		if enabled {
+			status = "已开启"
+		}
+		return command.TextReply(fmt.Sprintf("每日复习提醒%s，发送 /review push on|off 修改", status)), nil
+	}
+
+	var enabled bool
+	switch strings.ToLower(req.Args[1]) {
+	case "on":
+		enabled = true
+	case "off":
+	default:
+		return nil, fmt.Errorf("无效的参数: %s（应为 on 或 off）", req.Args[1])
+	}
+	if err := s.SetPush(ctx, scope, req.SenderID, enabled); err != nil {
+		return nil, err
+	}
+	if enabled {
+		return command.TextReply("已开启每日复习提醒，有到期的卡片时会在单聊中提醒你"), nil
+	}
+	return command.TextReply("已关闭每日复习提醒"), nil
+}
+
+// HandleAction 处理记忆卡片上的显示答案和评分按钮；点击别人的卡片或已经评过分的卡片时不回复
+func (s *Service) HandleAction(ctx context.Context, req *command.ActionRequest) (*command.Reply, error) {
+	if req.Value["user"] != req.SenderID {
+		return nil, nil
+	}
+	cardID, err := strconv.ParseInt(req.Value["card"], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("无效的卡片: %q", req.Value["card"])
+	}
+	reviews, err := strconv.Atoi(req.Value["reviews"])
+	if err != nil {
+		return nil, fmt.Errorf("无效的复习次数: %q", req.Value["reviews"])
+	}
+
+	scope := storage.Scope{AppID: req.AppID, TenantKey: req.TenantKey}
+	r, err := s.Card(ctx, scope, req.SenderID, cardID, reviews, req.Value["deck"])
+	switch {
+	case errors.Is(err, ErrAlreadyReviewed):
+		return nil, nil
+	case errors.Is(err, ErrCardNotFound):
+		return command.TextReply(err.Error()), nil
+	case err != nil:
+		return nil, err
+	}
+
+	if req.Name == ActionShow {
+		return command.CardReply(backCard(r, s.Preview(r), s.now()))
+	}
+	g, ok := parseGrade(req.Value["grade"])
+	if !ok {
+		return nil, fmt.Errorf("无效的评分: %q", req.Value["grade"])
+	}
+	st, err := s.Rate(ctx, scope, r, g)
+	if errors.Is(err, ErrAlreadyReviewed) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.nextReply(ctx, scope, req.SenderID, r.Filter, feedbackText(r, g, st, s.now()))
+}
+
+// nextReply 回复下一张要复习的卡片，没有时回复本轮复习完成
+func (s *Service) nextReply(ctx context.Context, scope storage.Scope, userID, deckID, feedback string) (*command.Reply, error) {
+	r, err := s.Next(ctx, scope, userID, deckID)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := s.Counts(ctx, scope, userID, deckID)
+	if err != nil {
+		return nil, err
+	}
+	if r != nil {
+		return command.CardReply(frontCard(r, counts, feedback))
+	}
+
+	decks, err := s.Decks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(decks) == 0 {
+		return command.TextReply("还没有任何卡片组"), nil
+	}
+	return command.CardReply(doneCard(counts, feedback, s.currentSettings().NewPerDay, s.now()))
+}
+
+// listDecks 列出所有卡片组和用户在每个卡片组中待复习的卡片数
+func (s *Service) listDecks(ctx context.Context, scope storage.Scope, userID string) (*command.Reply, error) {
+	decks, err := s.Decks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(decks) == 0 {
+		return command.TextReply("还没有任何卡片组"), nil
+	}
+
+	var b strings.Builder
+	b.WriteString("卡片组列表:")
+	for _, d := range decks {
+		counts, err := s.Counts(ctx, scope, userID, d.ID)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&b, "\n%s  《%s》 %d 张  待复习 %d，未学 %d", d.ID, d.Title, d.Cards, counts.Due, counts.New)
+		if d.Description != "" {
+			fmt.Fprintf(&b, "\n    %s", d.Description)
+		}
+	}
+	b.WriteString("\n\n发送 /review 复习所有卡片组，/review <卡片组> 只复习一个卡片组")
+	return command.TextReply(b.String()), nil
+}
+
+// requestScope 命令所属的应用和租户
+func requestScope(req *command.Request) storage.Scope {
+	return storage.Scope{AppID: req.AppID, TenantKey: req.TenantKey}
+}
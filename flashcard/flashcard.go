@@ -0,0 +1,428 @@
+package flashcard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"fin_bot/audit"
+	"fin_bot/progress"
+	"fin_bot/service"
+	"fin_bot/storage"
+
+	"github.com/robfig/cron/v3"
+)
+
+var (
+	// ErrDeckNotFound 卡片组不存在
+	ErrDeckNotFound = errors.New("卡片组不存在，发送 /review decks 查看所有卡片组")
+	// ErrCardNotFound 卡片在重新导入时被删除
+	ErrCardNotFound = errors.New("这张卡片已被删除，发送 /review 继续复习")
+	// ErrAlreadyReviewed 卡片已经评过分（例如重复点击评分按钮）
+	ErrAlreadyReviewed = errors.New("这张卡片已经复习过了")
+)
+
+// pushPreview 每日提醒中列出的到期卡片数
+const pushPreview = 5
+
+// Settings 可以在运行中修改的复习设置
+type Settings struct {
+	NewPerDay    int    // 每个用户每天最多学习的新卡片数
+	PushSchedule string // 每日提醒的 cron 表达式，为空表示不提醒
+	Timezone     string // 复习日期和每日提醒使用的时区
+}
+
+// Service 记忆卡片：导入卡片组，按 SM-2 算法安排每个用户每张卡片的复习时间，并每天提醒有到期卡片的用户
+// 卡片组所有应用共用，复习状态按应用、租户和用户隔离
+type Service struct {
+	store    *storage.Storage
+	apps     *service.AppRegistry // 发送每日提醒
+	progress *progress.Tracker    // 记录复习卡片的学习事件，为 nil 时不记录
+	now      func() time.Time
+
+	settingsMu sync.RWMutex // 保护 settings 和 loc，配置热加载时会被修改
+	settings   Settings
+	loc        *time.Location
+
+	pushMu  sync.Mutex // 串行化每日提醒
+	cronMu  sync.Mutex
+	cron    *cron.Cron
+	entryID cron.EntryID
+}
+
+// New 创建记忆卡片服务
+func New(store *storage.Storage, apps *service.AppRegistry, settings Settings) *Service {
+	s := &Service{store: store, apps: apps, now: time.Now}
+	s.setSettings(settings)
+	return s
+}
+
+// SetProgressTracker 设置学习进度，需要在开始处理消息之前调用
+func (s *Service) SetProgressTracker(t *progress.Tracker) {
+	s.progress = t
+}
+
+// SetSettings 修改复习设置，每日提醒运行中时按新的 cron 表达式和时区重新调度
+func (s *Service) SetSettings(settings Settings) {
+	old := s.currentSettings()
+	s.setSettings(settings)
+
+	if old.PushSchedule != settings.PushSchedule || old.Timezone != settings.Timezone {
+		s.cronMu.Lock()
+		defer s.cronMu.Unlock()
+		if s.cron != nil {
+			if err := s.reschedule(); err != nil {
+				log.Printf("[flashcard] 更新每日提醒失败: %v", err)
+			}
+		}
+	}
+}
+
+// setSettings 保存设置，时区无效时使用 UTC（配置校验保证时区有效）
+func (s *Service) setSettings(settings Settings) {
+	loc, err := time.LoadLocation(settings.Timezone)
+	if err != nil {
+		log.Printf("[flashcard] 无效的时区 %q，使用 UTC: %v", settings.Timezone, err)
+		loc = time.UTC
+	}
+	s.settingsMu.Lock()
+	s.settings = settings
+	s.loc = loc
+	s.settingsMu.Unlock()
+}
+
+// currentSettings 获取当前的复习设置
+func (s *Service) currentSettings() Settings {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return s.settings
+}
+
+// location 获取复习日期使用的时区
+func (s *Service) location() *time.Location {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return s.loc
+}
+
+// ImportResult 导入一个卡片组的结果
+type ImportResult struct {
+	DeckID string `json:"deck_id"`
+	Title  string `json:"title"`
+	Cards  int    `json:"cards"`
+	storage.FlashcardImportStats
+}
+
+// Import 导入目录中的所有卡片组（已有卡片组按 id 更新，目录中没有的卡片组保持不变）
+// 任何文件有问题时返回 *LoadError，不导入任何卡片组
+func (s *Service) Import(ctx context.Context, dir string) ([]ImportResult, error) {
+	decks, err := Load(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]ImportResult, 0, len(decks))
+	for _, imp := range decks {
+		stats, err := s.store.ImportFlashcardDeck(ctx, imp)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, ImportResult{
+			DeckID:               imp.Deck.ID,
+			Title:                imp.Deck.Title,
+			Cards:                len(imp.Cards),
+			FlashcardImportStats: stats,
+		})
+		log.Printf("[flashcard] 已导入卡片组 %s: 共 %d 张，新增 %d，更新 %d，删除 %d",
+			imp.Deck.ID, len(imp.Cards), stats.Added, stats.Updated, stats.Removed)
+	}
+	return results, nil
+}
+
+// Decks 获取所有卡片组
+func (s *Service) Decks(ctx context.Context) ([]*storage.FlashcardDeck, error) {
+	return s.store.ListFlashcardDecks(ctx)
+}
+
+// FindDeck 按 id（不区分大小写）或标题查找卡片组
+func (s *Service) FindDeck(ctx context.Context, key string) (*storage.FlashcardDeck, error) {
+	decks, err := s.store.ListFlashcardDecks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key = strings.Trim(strings.TrimSpace(key), "《》")
+	for _, d := range decks {
+		if strings.EqualFold(d.ID, key) || d.Title == key {
+			return d, nil
+		}
+	}
+	return nil, ErrDeckNotFound
+}
+
+// Counts 统计用户到期和没有学过的卡片，deckID 为空时统计所有卡片组
+func (s *Service) Counts(ctx context.Context, scope storage.Scope, userID, deckID string) (*storage.FlashcardCounts, error) {
+	now := s.now()
+	return s.store.CountFlashcards(ctx, scope, userID, deckID, now, dayStart(now, s.location()))
+}
+
+// Review 复习中的一张卡片
+type Review struct {
+	UserID string // 复习的用户
+	Card   *storage.FlashcardCard
+	Deck   *storage.FlashcardDeck
+	State  *storage.FlashcardState // 没有复习过的新卡片为 nil
+	Filter string                  // 复习范围（卡片组 id），为空表示所有卡片组
+}
+
+// Reviews 卡片已复习的次数，用于识别重复点击
+func (r *Review) Reviews() int {
+	if r.State == nil {
+		return 0
+	}
+	return r.State.Reviews
+}
+
+// Next 获取用户下一张要复习的卡片：先复习到期的卡片，再学习新卡片（不超过每天的新卡片数）；
+// 没有要复习的卡片时返回 nil
+func (s *Service) Next(ctx context.Context, scope storage.Scope, userID, deckID string) (*Review, error) {
+	now := s.now()
+	card, st, err := s.store.NextDueFlashcard(ctx, scope, userID, deckID, now)
+	if errors.Is(err, storage.ErrNotFound) {
+		var counts *storage.FlashcardCounts
+		if counts, err = s.Counts(ctx, scope, userID, deckID); err != nil {
+			return nil, err
+		}
+		if counts.New == 0 || counts.LearnedToday >= s.currentSettings().NewPerDay {
+			return nil, nil
+		}
+		card, err = s.store.NextNewFlashcard(ctx, scope, userID, deckID)
+	}
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.review(ctx, userID, card, st, deckID)
+}
+
+// Card 获取用户正在复习的一张卡片，reviews 与卡片当前的复习次数不一致时返回 ErrAlreadyReviewed
+func (s *Service) Card(ctx context.Context, scope storage.Scope, userID string, cardID int64, reviews int, deckID string) (*Review, error) {
+	card, err := s.store.GetFlashcardCard(ctx, cardID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrCardNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	st, err := s.store.GetFlashcardState(ctx, scope, userID, cardID)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return nil, err
+	}
+	r, err := s.review(ctx, userID, card, st, deckID)
+	if err != nil {
+		return nil, err
+	}
+	if r.Reviews() != reviews {
+		return nil, ErrAlreadyReviewed
+	}
+	return r, nil
+}
+
+// review 补充卡片所属的卡片组
+func (s *Service) review(ctx context.Context, userID string, card *storage.FlashcardCard, st *storage.FlashcardState, deckID string) (*Review, error) {
+	decks, err := s.store.ListFlashcardDecks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r := &Review{UserID: userID, Card: card, State: st, Filter: deckID, Deck: &storage.FlashcardDeck{ID: card.DeckID, Title: card.DeckID}}
+	for _, d := range decks {
+		if d.ID == card.DeckID {
+			r.Deck = d
+		}
+	}
+	return r, nil
+}
+
+// Preview 每个评分对应的复习状态，评分按钮上显示下次复习的间隔
+func (s *Service) Preview(r *Review) map[Grade]*storage.FlashcardState {
+	now := s.now()
+	states := make(map[Grade]*storage.FlashcardState, len(Grades))
+	for _, g := range Grades {
+		states[g] = Schedule(r.State, g, now, s.location())
+	}
+	return states
+}
+
+// Rate 记录评分并安排下次复习时间，卡片已经评过分时返回 ErrAlreadyReviewed
+func (s *Service) Rate(ctx context.Context, scope storage.Scope, r *Review, g Grade) (*storage.FlashcardState, error) {
+	st := Schedule(r.State, g, s.now(), s.location())
+	st.AppID, st.TenantKey, st.UserID, st.CardID = scope.AppID, scope.TenantKey, r.UserID, r.Card.ID
+	recorded, err := s.store.SaveFlashcardReview(ctx, st, r.Reviews(), string(g))
+	if err != nil {
+		return nil, err
+	}
+	if !recorded {
+		return nil, ErrAlreadyReviewed
+	}
+	s.progress.Record(ctx, &storage.ProgressEvent{
+		AppID: st.AppID, TenantKey: st.TenantKey, UserID: st.UserID,
+		Kind: storage.ProgressCardReviewed, Ref: fmt.Sprintf("%d:%d", st.CardID, st.Reviews), OccurredAt: st.LastReviewedAt,
+	})
+	return st, nil
+}
+
+// SetPush 开启或关闭用户的每日提醒
+func (s *Service) SetPush(ctx context.Context, scope storage.Scope, userID string, enabled bool) error {
+	return s.store.SetFlashcardPush(ctx, scope, userID, enabled)
+}
+
+// PushEnabled 用户是否会收到每日提醒（没有设置过时默认开启）
+func (s *Service) PushEnabled(ctx context.Context, scope storage.Scope, userID string) (bool, error) {
+	l, err := s.store.GetFlashcardLearner(ctx, scope, userID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return l.PushEnabled, nil
+}
+
+// Start 启动每日提醒
+func (s *Service) Start(ctx context.Context) error {
+	s.cronMu.Lock()
+	defer s.cronMu.Unlock()
+
+	s.cron = cron.New()
+	if err := s.reschedule(); err != nil {
+		return err
+	}
+	s.cron.Start()
+	return nil
+}
+
+// Stop 停止每日提醒，等待正在发送的提醒完成
+func (s *Service) Stop(ctx context.Context) error {
+	s.cronMu.Lock()
+	c := s.cron
+	s.cron = nil
+	s.cronMu.Unlock()
+	if c == nil {
+		return nil
+	}
+
+	select {
+	case <-c.Stop().Done():
+		log.Println("[flashcard] 每日提醒已停止")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reschedule 按当前设置替换每日提醒任务，调用方需持有 cronMu
+func (s *Service) reschedule() error {
+	if s.entryID != 0 {
+		s.cron.Remove(s.entryID)
+		s.entryID = 0
+	}
+	settings := s.currentSettings()
+	if settings.PushSchedule == "" {
+		log.Println("[flashcard] 未配置每日提醒")
+		return nil
+	}
+
+	spec := "CRON_TZ=" + s.location().String() + " " + settings.PushSchedule
+	id, err := s.cron.AddFunc(spec, func() { s.Push(context.Background()) })
+	if err != nil {
+		return fmt.Errorf("无效的每日提醒表达式 %q: %w", settings.PushSchedule, err)
+	}
+	s.entryID = id
+	log.Printf("[flashcard] 每日提醒已启用: schedule=%q, timezone=%s", settings.PushSchedule, s.location())
+	return nil
+}
+
+// Push 向有到期卡片且开启了提醒的用户发送单聊提醒，每个用户每天最多一次，返回发送的提醒数
+func (s *Service) Push(ctx context.Context) int {
+	s.pushMu.Lock()
+	defer s.pushMu.Unlock()
+
+	learners, err := s.store.ListFlashcardLearners(ctx)
+	if err != nil {
+		log.Printf("[flashcard] 加载复习用户失败: %v", err)
+		return 0
+	}
+
+	now := s.now()
+	today := dayStart(now, s.location())
+	sent := 0
+	for _, l := range learners {
+		if ctx.Err() != nil {
+			break
+		}
+		if l.LastPushedAt != nil && !l.LastPushedAt.Before(today) {
+			continue
+		}
+		ok, err := s.push(ctx, l, now)
+		if err != nil {
+			log.Printf("[flashcard] 发送每日提醒失败: app=%s, tenant=%s, user=%s, error=%v", l.AppID, l.TenantKey, l.UserID, err)
+			continue
+		}
+		if ok {
+			sent++
+		}
+	}
+	log.Printf("[flashcard] 每日提醒已发送: users=%d, sent=%d", len(learners), sent)
+	return sent
+}
+
+// push 向一个用户发送提醒，没有到期的卡片时不发送
+func (s *Service) push(ctx context.Context, l *storage.FlashcardLearner, now time.Time) (bool, error) {
+	scope := storage.Scope{AppID: l.AppID, TenantKey: l.TenantKey}
+	counts, err := s.store.CountFlashcards(ctx, scope, l.UserID, "", now, dayStart(now, s.location()))
+	if err != nil {
+		return false, err
+	}
+	if counts.Due == 0 {
+		return false, nil
+	}
+	cards, err := s.store.ListDueFlashcards(ctx, scope, l.UserID, now, pushPreview)
+	if err != nil {
+		return false, err
+	}
+	larkService, err := s.apps.LarkService(l.AppID)
+	if err != nil {
+		return false, err
+	}
+
+	ctx = audit.WithActor(ctx, audit.Actor{ID: "flashcard:push", Via: audit.ViaSystem, TenantKey: l.TenantKey})
+	if err := larkService.SendTextMessage(ctx, l.UserID, "open_id", pushText(counts, cards)); err != nil {
+		return false, err
+	}
+	return true, s.store.MarkFlashcardPushed(ctx, scope, l.UserID, now)
+}
+
+// pushText 每日提醒的内容
+func pushText(counts *storage.FlashcardCounts, cards []*storage.FlashcardCard) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "📚 今天有 %d 张记忆卡片需要复习:", counts.Due)
+	for _, c := range cards {
+		fmt.Fprintf(&b, "\n· %s", firstLine(c.Front))
+	}
+	if counts.Due > len(cards) {
+		fmt.Fprintf(&b, "\n……等 %d 张", counts.Due)
+	}
+	b.WriteString("\n\n发送 /review 开始复习，/review push off 关闭每日提醒")
+	return b.String()
+}
+
+// firstLine 多行内容的第一行
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
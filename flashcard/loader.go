@@ -0,0 +1,421 @@
+package flashcard
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"fin_bot/storage"
+)
+
+// 卡片组目录中每个 .csv、.tsv 或 .txt 文件是一个卡片组（可以放在子目录中），以 . 或 _ 开头的文件和目录会被忽略；
+// 未指定 id 时使用去掉数字前缀和扩展名的文件名
+//
+// CSV 格式：第一行为表头（front,back,tags,id，其中 front、back 必填），tags 用空格或逗号分隔；
+// 卡片组信息写在表头之前的注释行中，例如 "# title: 金融术语"
+//
+// TSV/TXT 格式：Anki 的「纯文本笔记」导出（没有表头行），文件开头的 #key:value 行描述文件格式，例如
+//
+//	#separator:tab
+//	#html:true
+//	#guid column:1
+//	#tags column:4
+//
+// 除 guid、notetype、deck、tags 列之外的第一列为正面，第二列为背面，其余非空的列追加到背面；
+// html:true 时去掉 HTML 标签；也可以用 #title:、#description:、#id: 指定卡片组信息
+var (
+	// idPattern 卡片组和卡片的 id
+	idPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+	// orderPrefix 文件名中表示顺序的数字前缀，例如 01-
+	orderPrefix = regexp.MustCompile(`^\d+[-_. ]+`)
+	// htmlBreak 转换为换行的 HTML 标签
+	htmlBreak = regexp.MustCompile(`(?i)<br\s*/?>|</(div|p|li)>`)
+	// htmlTag 其余 HTML 标签
+	htmlTag = regexp.MustCompile(`<[^>]*>`)
+	// ankiMedia Anki 的声音和图片引用，聊天中无法展示
+	ankiMedia = regexp.MustCompile(`\[sound:[^\]]*\]`)
+)
+
+// csvColumns CSV 卡片组支持的列
+var csvColumns = []string{"front", "back", "tags", "id"}
+
+// ankiSeparators Anki 导出文件 #separator 的取值
+var ankiSeparators = map[string]rune{
+	"tab": '\t', "comma": ',', "semicolon": ';', "pipe": '|', "space": ' ', "colon": ':',
+}
+
+// LoadError 卡片组文件校验错误，包含所有问题而不是遇到第一个就返回
+type LoadError struct {
+	Problems []string
+}
+
+func (e *LoadError) Error() string {
+	return fmt.Sprintf("卡片组文件校验失败（%d 项）:\n  - %s", len(e.Problems), strings.Join(e.Problems, "\n  - "))
+}
+
+// Load 读取目录中的所有卡片组，任何文件有问题时返回 *LoadError 且不返回任何卡片组
+func Load(dir string) ([]*storage.FlashcardDeckImport, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("读取卡片组目录失败: %w", err)
+	}
+
+	l := &loader{}
+	var decks []*storage.FlashcardDeckImport
+	ids := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir && ignored(entry.Name()) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		var deck *storage.FlashcardDeckImport
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".csv":
+			deck = l.loadCSV(path)
+		case ".tsv", ".txt":
+			deck = l.loadAnki(path)
+		default:
+			return nil
+		}
+		if deck == nil {
+			return nil
+		}
+		if other, ok := ids[deck.Deck.ID]; ok {
+			l.addf("%s: 卡片组 id %s 与 %s 重复", path, deck.Deck.ID, other)
+			return nil
+		}
+		ids[deck.Deck.ID] = path
+		decks = append(decks, deck)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("读取卡片组目录失败: %w", err)
+	}
+
+	if len(l.problems) > 0 {
+		return nil, &LoadError{Problems: l.problems}
+	}
+	return decks, nil
+}
+
+// loader 读取卡片组时收集问题
+type loader struct {
+	problems []string
+}
+
+func (l *loader) addf(format string, args ...interface{}) {
+	l.problems = append(l.problems, fmt.Sprintf(format, args...))
+}
+
+// loadCSV 读取带表头的 CSV 卡片组，有问题时记录并返回 nil
+func (l *loader) loadCSV(path string) *storage.FlashcardDeckImport {
+	data, meta, ok := l.readFile(path)
+	if !ok {
+		return nil
+	}
+	deck := l.newDeck(path, meta)
+	if deck == nil {
+		return nil
+	}
+
+	r := newReader(data, ',')
+	header, err := r.Read()
+	if errors.Is(err, io.EOF) {
+		l.addf("%s: 缺少表头", path)
+		return nil
+	}
+	if err != nil {
+		l.addf("%s: CSV 格式错误: %v", path, err)
+		return nil
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !slices.Contains(csvColumns, name) {
+			l.addf("%s: 未知的列 %q（支持 %s）", path, name, strings.Join(csvColumns, ","))
+			return nil
+		}
+		columns[name] = i
+	}
+	for _, name := range []string{"front", "back"} {
+		if _, ok := columns[name]; !ok {
+			l.addf("%s: 缺少 %s 列", path, name)
+			return nil
+		}
+	}
+
+	rows := 0
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			l.addf("%s: CSV 格式错误: %v", path, err)
+			return nil
+		}
+		rows++
+		line, _ := r.FieldPos(0)
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		where := fmt.Sprintf("%s: 第 %d 行", path, line)
+		if c := l.newCard(where, rows, field("id"), field("front"), field("back"), field("tags")); c != nil {
+			deck.Cards = append(deck.Cards, c)
+		}
+	}
+	return l.checkDeck(path, deck, rows)
+}
+
+// loadAnki 读取 Anki 纯文本导出的卡片组，有问题时记录并返回 nil
+func (l *loader) loadAnki(path string) *storage.FlashcardDeckImport {
+	data, meta, ok := l.readFile(path)
+	if !ok {
+		return nil
+	}
+	if meta["title"] == "" {
+		meta["title"] = meta["deck"]
+	}
+	deck := l.newDeck(path, meta)
+	if deck == nil {
+		return nil
+	}
+
+	sep := '\t'
+	if s, ok := meta["separator"]; ok {
+		if r, ok := ankiSeparators[strings.ToLower(s)]; ok {
+			sep = r
+		} else if runes := []rune(s); len(runes) == 1 {
+			sep = runes[0]
+		} else {
+			l.addf("%s: 无效的 separator %q", path, s)
+			return nil
+		}
+	}
+	stripHTML := false
+	if s, ok := meta["html"]; ok {
+		var err error
+		if stripHTML, err = strconv.ParseBool(s); err != nil {
+			l.addf("%s: html 只能是 true 或 false", path)
+			return nil
+		}
+	}
+	// 特殊列（从 1 开始），不存在时为 0
+	special := make(map[string]int)
+	for _, name := range []string{"guid", "notetype", "deck", "tags"} {
+		s, ok := meta[name+" column"]
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			l.addf("%s: 无效的 %s column %q", path, name, s)
+			return nil
+		}
+		special[name] = n
+	}
+
+	r := newReader(data, sep)
+	rows := 0
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			l.addf("%s: 格式错误: %v", path, err)
+			return nil
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		rows++
+		line, _ := r.FieldPos(0)
+		where := fmt.Sprintf("%s: 第 %d 行", path, line)
+
+		column := func(name string) string {
+			if n := special[name]; n > 0 && n <= len(record) {
+				return strings.TrimSpace(record[n-1])
+			}
+			return ""
+		}
+		var fields []string
+		for i, v := range record {
+			isSpecial := false
+			for _, n := range special {
+				isSpecial = isSpecial || n == i+1
+			}
+			if isSpecial {
+				continue
+			}
+			if stripHTML {
+				v = plainText(v)
+			}
+			fields = append(fields, strings.TrimSpace(v))
+		}
+		if len(fields) < 2 {
+			l.addf("%s: 至少需要正面和背面两列", where)
+			continue
+		}
+		back := fields[1]
+		for _, extra := range fields[2:] {
+			if extra != "" {
+				back += "\n" + extra
+			}
+		}
+		if c := l.newCard(where, rows, column("guid"), fields[0], back, column("tags")); c != nil {
+			deck.Cards = append(deck.Cards, c)
+		}
+	}
+	return l.checkDeck(path, deck, rows)
+}
+
+// readFile 读取文件内容和开头注释行中的 key: value 信息（key 为小写）
+func (l *loader) readFile(path string) ([]byte, map[string]string, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		l.addf("%s: %v", path, err)
+		return nil, nil, false
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	meta := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+		if key, value, ok := strings.Cut(strings.TrimPrefix(line, "#"), ":"); ok {
+			meta[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+		}
+	}
+	return data, meta, true
+}
+
+// newDeck 校验卡片组信息，有问题时记录并返回 nil
+func (l *loader) newDeck(path string, meta map[string]string) *storage.FlashcardDeckImport {
+	id := meta["id"]
+	if id == "" {
+		id = idFromName(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+	}
+	if !idPattern.MatchString(id) {
+		l.addf("%s: 卡片组 id %q 只能包含字母、数字、-、_ 和 .（可以在文件中指定 id）", path, id)
+		return nil
+	}
+	title := meta["title"]
+	if title == "" {
+		title = id
+	}
+	return &storage.FlashcardDeckImport{Deck: &storage.FlashcardDeck{
+		ID:          id,
+		Title:       title,
+		Description: meta["description"],
+		Source:      path,
+	}}
+}
+
+// newCard 校验一张卡片，有问题时记录并返回 nil
+func (l *loader) newCard(where string, position int, key, front, back, tags string) *storage.FlashcardCard {
+	switch {
+	case front == "":
+		l.addf("%s: 缺少正面内容", where)
+		return nil
+	case back == "":
+		l.addf("%s: 缺少背面内容", where)
+		return nil
+	}
+	if key == "" {
+		key = front
+	}
+	return &storage.FlashcardCard{
+		Key:      key,
+		Position: position,
+		Front:    front,
+		Back:     back,
+		Tags:     strings.Join(strings.FieldsFunc(tags, func(r rune) bool { return r == ',' || r == '，' || r == ' ' }), " "),
+	}
+}
+
+// checkDeck 检查卡片组中的卡片，count 为文件中的卡片数（包括有问题的）
+func (l *loader) checkDeck(path string, deck *storage.FlashcardDeckImport, count int) *storage.FlashcardDeckImport {
+	if count == 0 {
+		l.addf("%s: 卡片组中没有任何卡片", path)
+		return nil
+	}
+	if len(deck.Cards) < count {
+		return nil
+	}
+	keys := make(map[string]int)
+	for i, c := range deck.Cards {
+		if other, ok := keys[c.Key]; ok {
+			l.addf("%s: 第 %d 张卡片与第 %d 张重复（%q），可以用 id 列区分", path, i+1, other, c.Key)
+		}
+		keys[c.Key] = i + 1
+	}
+	return deck
+}
+
+// newReader 创建跳过注释行的 CSV 读取器，允许字段中出现不成对的引号（Anki 导出的 HTML 中常见）
+func newReader(data []byte, sep rune) *csv.Reader {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = sep
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	return r
+}
+
+// plainText 把 Anki 字段中的 HTML 转换为纯文本
+func plainText(s string) string {
+	s = htmlBreak.ReplaceAllString(s, "\n")
+	s = htmlTag.ReplaceAllString(s, "")
+	s = ankiMedia.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	lines := strings.Split(s, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// idFromName 去掉文件名中的顺序前缀
+func idFromName(name string) string {
+	if id := orderPrefix.ReplaceAllString(name, ""); id != "" {
+		return id
+	}
+	return name
+}
+
+// ignored 是否忽略的文件或目录
+func ignored(name string) bool {
+	return strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")
+}
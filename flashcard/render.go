@@ -0,0 +1,127 @@
+package flashcard
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"fin_bot/card"
+	"fin_bot/command"
+	"fin_bot/storage"
+)
+
+// 卡片按钮的交互名称
+const (
+	ActionShow  = "flashcard.show"  // 显示背面
+	ActionGrade = "flashcard.grade" // 评分
+)
+
+// gradeStyles 评分按钮的样式
+var gradeStyles = map[Grade]string{
+	GradeAgain: card.ButtonDanger,
+	GradeHard:  card.ButtonDefault,
+	GradeGood:  card.ButtonPrimary,
+	GradeEasy:  card.ButtonDefault,
+}
+
+// frontCard 卡片正面，feedback 为上一张卡片的评分结果（第一张为空）
+func frontCard(r *Review, counts *storage.FlashcardCounts, feedback string) *card.Card {
+	cd := card.New(title(r), card.ColorBlue)
+	if feedback != "" {
+		cd.Add(card.Markdown(feedback), card.Divider())
+	}
+	cd.Add(card.Markdown("**" + r.Card.Front + "**"))
+	cd.Add(card.Actions(card.Button("显示答案", card.ButtonPrimary, actionValue(r, ActionShow))))
+	return cd.Add(card.Note(fmt.Sprintf("待复习 %d 张 · 新卡片 %d 张 · 回想答案后点击显示答案", counts.Due, counts.New)))
+}
+
+// backCard 卡片背面和评分按钮，按钮上显示选择这个评分后的复习间隔
+func backCard(r *Review, preview map[Grade]*storage.FlashcardState, now time.Time) *card.Card {
+	cd := card.New(title(r), card.ColorTurquoise)
+	cd.Add(card.Markdown("**" + r.Card.Front + "**"))
+	cd.Add(card.Divider())
+	cd.Add(card.Markdown(r.Card.Back))
+
+	var buttons []interface{}
+	for _, g := range Grades {
+		v := actionValue(r, ActionGrade)
+		v["grade"] = string(g)
+		label := gradeLabels[g] + " · " + intervalText(preview[g], now)
+		buttons = append(buttons, card.Button(label, gradeStyles[g], v))
+	}
+	cd.Add(card.Actions(buttons...))
+
+	note := "根据回想的难易程度评分"
+	if r.Card.Tags != "" {
+		note = "标签: " + r.Card.Tags + " · " + note
+	}
+	return cd.Add(card.Note(note))
+}
+
+// doneCard 本轮复习完成
+func doneCard(counts *storage.FlashcardCounts, feedback string, newPerDay int, now time.Time) *card.Card {
+	cd := card.New("今天的复习完成了", card.ColorGreen)
+	if feedback != "" {
+		cd.Add(card.Markdown(feedback), card.Divider())
+	}
+	text := "🎉 没有需要复习的卡片了"
+	if counts.NextDueAt != nil {
+		text += fmt.Sprintf("\n下一张卡片在 %s后到期", formatInterval(counts.NextDueAt.Sub(now)))
+	}
+	if counts.New > 0 && counts.LearnedToday >= newPerDay {
+		text += fmt.Sprintf("\n今天已学习 %d 张新卡片，剩余 %d 张新卡片明天继续", counts.LearnedToday, counts.New)
+	}
+	cd.Add(card.Markdown(text))
+	return cd.Add(card.Note("有卡片到期时会在单聊中提醒你 · 发送 /review push off 关闭提醒"))
+}
+
+// feedbackText 评分后的提示
+func feedbackText(r *Review, g Grade, st *storage.FlashcardState, now time.Time) string {
+	return fmt.Sprintf("已记录「%s」: %s，%s后复习", firstLine(r.Card.Front), gradeLabels[g], intervalText(st, now))
+}
+
+// title 卡片标题
+func title(r *Review) string {
+	if r.State == nil {
+		return fmt.Sprintf("《%s》· 新卡片", r.Deck.Title)
+	}
+	return fmt.Sprintf("《%s》· 第 %d 次复习", r.Deck.Title, r.State.Reviews+1)
+}
+
+// intervalText 复习间隔：记住的卡片按天数显示，忘记的卡片按到期时间显示
+func intervalText(st *storage.FlashcardState, now time.Time) string {
+	if st.IntervalDays > 0 {
+		return formatDays(st.IntervalDays)
+	}
+	return formatInterval(st.DueAt.Sub(now))
+}
+
+// formatInterval 把时间间隔显示为分钟、小时或天
+func formatInterval(d time.Duration) string {
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%d 分钟", max(int(d.Round(time.Minute)/time.Minute), 1))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%d 小时", int(d.Round(time.Hour)/time.Hour))
+	}
+	return formatDays(int((d + 12*time.Hour) / (24 * time.Hour)))
+}
+
+// formatDays 把天数显示为天或月
+func formatDays(days int) string {
+	if days < 60 {
+		return fmt.Sprintf("%d 天", days)
+	}
+	return fmt.Sprintf("%.1f 个月", float64(days)/30)
+}
+
+// actionValue 卡片按钮回传的数据：卡片、复习次数（识别重复点击）、复习范围和复习的用户
+func actionValue(r *Review, action string) map[string]string {
+	return map[string]string{
+		command.ActionKey: action,
+		"card":            strconv.FormatInt(r.Card.ID, 10),
+		"reviews":         strconv.Itoa(r.Reviews()),
+		"deck":            r.Filter,
+		"user":            r.UserID,
+	}
+}
@@ -0,0 +1,115 @@
+package flashcard
+
+import (
+	"math"
+	"time"
+
+	"fin_bot/storage"
+)
+
+// Grade 复习时对一张卡片的评分
+type Grade string
+
+// 评分，对应 SM-2 算法中的回忆质量
+const (
+	GradeAgain Grade = "again" // 忘记了，10 分钟后重新复习
+	GradeHard  Grade = "hard"  // 想起来了但很吃力
+	GradeGood  Grade = "good"  // 正常想起
+	GradeEasy  Grade = "easy"  // 毫不费力
+)
+
+// Grades 评分按钮的顺序
+var Grades = []Grade{GradeAgain, GradeHard, GradeGood, GradeEasy}
+
+// gradeLabels 评分的中文名称
+var gradeLabels = map[Grade]string{
+	GradeAgain: "忘记",
+	GradeHard:  "困难",
+	GradeGood:  "记得",
+	GradeEasy:  "简单",
+}
+
+// quality SM-2 的回忆质量（0-5），忘记统一按 1 计算
+var quality = map[Grade]float64{
+	GradeAgain: 1,
+	GradeHard:  3,
+	GradeGood:  4,
+	GradeEasy:  5,
+}
+
+const (
+	initialEase = 2.5
+	minEase     = 1.3
+	// relearnDelay 忘记的卡片在本轮复习中重新出现的间隔
+	relearnDelay = 10 * time.Minute
+	// hardFactor、easyBonus 困难和简单在间隔上的调整（SM-2 原算法只按难度系数计算间隔）
+	hardFactor = 1.2
+	easyBonus  = 1.3
+)
+
+// Schedule 按 SM-2 算法计算评分后的复习状态，st 为 nil 表示第一次复习
+// 间隔以天为单位，到期时间为 loc 时区中第 n 天的零点，这样当天任何时候复习过的卡片都在同一天到期
+func Schedule(st *storage.FlashcardState, g Grade, now time.Time, loc *time.Location) *storage.FlashcardState {
+	next := &storage.FlashcardState{Ease: initialEase, FirstReviewedAt: now}
+	if st != nil {
+		copied := *st
+		next = &copied
+	}
+	next.Reviews++
+	next.LastReviewedAt = now
+
+	q := quality[g]
+	next.Ease = math.Max(minEase, next.Ease+0.1-(5-q)*(0.08+(5-q)*0.02))
+
+	if g == GradeAgain {
+		next.Repetitions = 0
+		next.IntervalDays = 0
+		if st != nil && st.Repetitions > 0 {
+			next.Lapses++
+		}
+		next.DueAt = now.Add(relearnDelay)
+		return next
+	}
+
+	prev := next.IntervalDays
+	next.Repetitions++
+	switch {
+	case next.Repetitions == 1:
+		next.IntervalDays = 1
+		if g == GradeEasy {
+			next.IntervalDays = 4
+		}
+	case next.Repetitions == 2:
+		next.IntervalDays = 6
+		if g == GradeHard {
+			next.IntervalDays = 3
+		}
+	default:
+		var days float64
+		switch g {
+		case GradeHard:
+			days = float64(prev) * hardFactor
+		case GradeEasy:
+			days = float64(prev) * next.Ease * easyBonus
+		default:
+			days = float64(prev) * next.Ease
+		}
+		// 记住的卡片间隔至少增加一天
+		next.IntervalDays = max(int(math.Round(days)), prev+1)
+	}
+	next.DueAt = dayStart(now, loc).AddDate(0, 0, next.IntervalDays)
+	return next
+}
+
+// dayStart t 在 loc 时区中当天的零点
+func dayStart(t time.Time, loc *time.Location) time.Time {
+	local := t.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+}
+
+// parseGrade 解析按钮回传的评分
+func parseGrade(s string) (Grade, bool) {
+	g := Grade(s)
+	_, ok := quality[g]
+	return g, ok
+}
@@ -0,0 +1,101 @@
+package flashcard
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"fin_bot/storage"
+)
+
+func TestSchedule(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	now := time.Date(2026, 10, 18, 15, 30, 0, 0, loc)
+	first := now.Add(-30 * 24 * time.Hour)
+	day := func(n int) time.Time { return time.Date(2026, 10, 18+n, 0, 0, 0, 0, loc) }
+	state := func(reps, interval int, ease float64) *storage.FlashcardState {
+		return &storage.FlashcardState{Repetitions: reps, IntervalDays: interval, Ease: ease, Reviews: 5, FirstReviewedAt: first}
+	}
+
+	tests := []struct {
+		name         string
+		st           *storage.FlashcardState
+		grade        Grade
+		wantReps     int
+		wantInterval int
+		wantEase     float64
+		wantLapses   int
+		wantDue      time.Time
+	}{
+		{"第一次记得", nil, GradeGood, 1, 1, 2.5, 0, day(1)},
+		{"第一次简单", nil, GradeEasy, 1, 4, 2.6, 0, day(4)},
+		{"第一次困难", nil, GradeHard, 1, 1, 2.36, 0, day(1)},
+		{"第一次忘记不算遗忘", nil, GradeAgain, 0, 0, 1.96, 0, now.Add(relearnDelay)},
+		{"第二次记得", state(1, 1, 2.5), GradeGood, 2, 6, 2.5, 0, day(6)},
+		{"第二次困难", state(1, 1, 2.5), GradeHard, 2, 3, 2.36, 0, day(3)},
+		{"之后按难度系数", state(2, 6, 2.5), GradeGood, 3, 15, 2.5, 0, day(15)},
+		{"之后困难", state(2, 6, 2.5), GradeHard, 3, 7, 2.36, 0, day(7)},
+		{"之后简单", state(2, 6, 2.5), GradeEasy, 3, 20, 2.6, 0, day(20)},
+		{"记住后忘记计为遗忘", state(3, 15, 2.5), GradeAgain, 0, 0, 1.96, 1, now.Add(relearnDelay)},
+		{"难度系数不低于下限", state(3, 10, 1.4), GradeHard, 4, 12, minEase, 0, day(12)},
+		{"间隔至少增加一天", state(2, 1, 1.3), GradeHard, 3, 2, minEase, 0, day(2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var before storage.FlashcardState
+			if tt.st != nil {
+				before = *tt.st
+			}
+			got := Schedule(tt.st, tt.grade, now, loc)
+
+			if got.Repetitions != tt.wantReps || got.IntervalDays != tt.wantInterval || got.Lapses != tt.wantLapses {
+				t.Errorf("repetitions/interval/lapses = %d/%d/%d, want %d/%d/%d",
+					got.Repetitions, got.IntervalDays, got.Lapses, tt.wantReps, tt.wantInterval, tt.wantLapses)
+			}
+			if math.Abs(got.Ease-tt.wantEase) > 1e-9 {
+				t.Errorf("ease = %v, want %v", got.Ease, tt.wantEase)
+			}
+			if !got.DueAt.Equal(tt.wantDue) {
+				t.Errorf("due = %v, want %v", got.DueAt, tt.wantDue)
+			}
+			if !got.LastReviewedAt.Equal(now) {
+				t.Errorf("last reviewed = %v, want %v", got.LastReviewedAt, now)
+			}
+			if tt.st == nil {
+				if got.Reviews != 1 || !got.FirstReviewedAt.Equal(now) {
+					t.Errorf("reviews/first = %d/%v, want 1/%v", got.Reviews, got.FirstReviewedAt, now)
+				}
+				return
+			}
+			if got.Reviews != before.Reviews+1 || !got.FirstReviewedAt.Equal(first) {
+				t.Errorf("reviews/first = %d/%v, want %d/%v", got.Reviews, got.FirstReviewedAt, before.Reviews+1, first)
+			}
+			if *tt.st != before {
+				t.Errorf("Schedule 修改了传入的状态: %+v", *tt.st)
+			}
+		})
+	}
+}
+
+func TestScheduleDueAtLocalMidnight(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	// UTC 的 10 月 18 日 20:00 在东八区已经是 10 月 19 日
+	now := time.Date(2026, 10, 18, 20, 0, 0, 0, time.UTC)
+	got := Schedule(nil, GradeGood, now, loc)
+	if want := time.Date(2026, 10, 20, 0, 0, 0, 0, loc); !got.DueAt.Equal(want) {
+		t.Errorf("due = %v, want %v", got.DueAt, want)
+	}
+}
+
+func TestParseGrade(t *testing.T) {
+	for _, g := range Grades {
+		if got, ok := parseGrade(string(g)); !ok || got != g {
+			t.Errorf("parseGrade(%q) = %q, %v", g, got, ok)
+		}
+	}
+	for _, s := range []string{"", "GOOD", "perfect"} {
+		if _, ok := parseGrade(s); ok {
+			t.Errorf("parseGrade(%q) 应该失败", s)
+		}
+	}
+}
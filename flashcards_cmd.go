@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"fin_bot/config"
+	"fin_bot/flashcard"
+)
+
+// runFlashcardsImportCommand 导入卡片组目录中的 CSV 和 Anki TSV 文件，-dry-run 时只校验文件
+func runFlashcardsImportCommand(configPath string, args []string) int {
+	fs := newCLIFlags("flashcards import", "[-dir 卡片组目录] [-db 数据库] [-dry-run] [-format text|json]")
+	dir := fs.String("dir", "", "卡片组目录（默认使用配置中的 flashcards.dir）")
+	dbPath := fs.String("db", "", "数据库文件路径（默认使用配置中的 database.path）")
+	dryRun := fs.Bool("dry-run", false, "只校验卡片组文件，不写入数据库")
+	format := fs.String("format", "text", "输出格式: text 或 json")
+	fs.logFlag()
+	if code := fs.parse(args); code != exitOK {
+		return code
+	}
+	if *format != "text" && *format != "json" {
+		return fs.usageError("无效的输出格式: %s", *format)
+	}
+
+	cfg := loadConfig(configPath, false)
+	if cfg == nil {
+		return exitError
+	}
+	if *dir == "" {
+		*dir = cfg.Flashcards.Dir
+	}
+
+	var results []flashcard.ImportResult
+	if *dryRun {
+		decks, err := flashcard.Load(*dir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+		for _, imp := range decks {
+			results = append(results, flashcard.ImportResult{DeckID: imp.Deck.ID, Title: imp.Deck.Title, Cards: len(imp.Cards)})
+		}
+	} else {
+		store, err := openStorage(cfg, *dbPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "打开数据库失败: %v\n", err)
+			return exitError
+		}
+		defer store.Close()
+
+		results, err = flashcard.New(store, nil, flashcardSettings(cfg.Flashcards)).Import(context.Background(), *dir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+	}
+
+	if *format == "json" {
+		if results == nil {
+			results = []flashcard.ImportResult{}
+		}
+		if err := json.NewEncoder(os.Stdout).Encode(results); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+		return exitOK
+	}
+	for _, r := range results {
+		if *dryRun {
+			fmt.Printf("%s\t《%s》\t%d 张\n", r.DeckID, r.Title, r.Cards)
+			continue
+		}
+		fmt.Printf("%s\t《%s》\t%d 张（新增 %d，更新 %d，未变 %d，删除 %d）\n",
+			r.DeckID, r.Title, r.Cards, r.Added, r.Updated, r.Unchanged, r.Removed)
+	}
+	if *dryRun {
+		fmt.Fprintf(os.Stderr, "%s 中的 %d 个卡片组校验通过\n", *dir, len(results))
+	} else {
+		fmt.Fprintf(os.Stderr, "已从 %s 导入 %d 个卡片组\n", *dir, len(results))
+	}
+	return exitOK
+}
+
+// importFlashcards 服务启动和配置变更时自动导入卡片组目录，失败时保留数据库中已有的卡片组
+func importFlashcards(ctx context.Context, flashcards *flashcard.Service, c config.FlashcardsConfig) {
+	if !c.ImportOnLoad {
+		return
+	}
+	if _, err := os.Stat(c.Dir); errors.Is(err, os.ErrNotExist) {
+		log.Printf("[flashcard] 卡片组目录 %s 不存在，跳过导入", c.Dir)
+		return
+	}
+	results, err := flashcards.Import(ctx, c.Dir)
+	if err != nil {
+		log.Printf("[警告] 导入卡片组失败，继续使用已导入的卡片组: %v", err)
+		return
+	}
+	fmt.Printf("已导入 %d 个卡片组: %s\n", len(results), c.Dir)
+}
+
+// flashcardSettings 将配置转换为复习设置
+func flashcardSettings(c config.FlashcardsConfig) flashcard.Settings {
+	return flashcard.Settings{
+		NewPerDay:    c.NewPerDay,
+		PushSchedule: c.PushSchedule,
+		Timezone:     c.Timezone,
+	}
+}
@@ -0,0 +1,155 @@
+package glossary
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"fin_bot/command"
+	"fin_bot/storage"
+)
+
+var (
+	// questionPrefix 「什么是X」形式的提问
+	questionPrefix = regexp.MustCompile(`^(?:请问|想问一?下)?\s*(?:什么是|啥是|何谓|何为)\s*(.+?)$`)
+	// questionSuffix 「X是什么（意思）」形式的提问
+	questionSuffix = regexp.MustCompile(`^(?:请问|想问一?下)?\s*(.+?)\s*(?:是什么意思|是啥意思|是什么|是啥|什么意思|啥意思|指的是什么|指什么)$`)
+	// questionEnding 提问末尾的语气词和标点
+	questionEnding = regexp.MustCompile(`[\s?？。.!！~～呢啊呀]+$`)
+)
+
+// maxQuestionTerm 从提问中识别的术语最多的字符数，更长的消息不当作术语提问
+const maxQuestionTerm = 30
+
+// Commands 返回术语表的聊天命令
+func (s *Service) Commands() []*command.Command {
+	return []*command.Command{
+		{
+			Name:         "define",
+			Usage:        "/define <术语>",
+			Description:  "查询金融术语的解释、公式和示例（支持中文、英文、缩写和拼音，也可以直接问「什么是X」）",
+			ReplyHandler: s.commandDefine,
+		},
+		{
+			Name:         "termofday",
+			Usage:        "/termofday [on|off]",
+			Description:  "查看今天的术语，或为当前会话开启、关闭每日术语",
+			MinRole:      command.RoleAdmin,
+			ReplyHandler: s.commandTermOfDay,
+		},
+	}
+}
+
+// commandDefine 处理 /define 命令
+func (s *Service) commandDefine(ctx context.Context, req *command.Request) (*command.Reply, error) {
+	if req.RawArgs == "" {
+		return nil, errors.New("缺少要查询的术语，例如 /define 市盈率、/define ROE、/define syl")
+	}
+	d, err := s.Define(ctx, req.RawArgs)
+	if errors.Is(err, ErrEmpty) {
+		return command.TextReply(err.Error()), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if d == nil {
+		return command.TextReply(fmt.Sprintf("没有找到与「%s」相关的术语", req.RawArgs)), nil
+	}
+	return command.CardReply(termCard(d))
+}
+
+// commandTermOfDay 处理 /termofday 命令
+func (s *Service) commandTermOfDay(ctx context.Context, req *command.Request) (*command.Reply, error) {
+	scope := storage.Scope{AppID: req.AppID, TenantKey: req.TenantKey}
+	if len(req.Args) == 0 {
+		d, err := s.TermOfDay(ctx)
+		if errors.Is(err, ErrEmpty) {
+			return command.TextReply(err.Error()), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		subscribed, err := s.Subscribed(ctx, scope, req.ChatID)
+		if err != nil {
+			return nil, err
+		}
+		note := "当前会话没有开启每日术语，发送 /termofday on 开启"
+		if subscribed {
+			note = "当前会话已开启每日术语，发送 /termofday off 关闭"
+		}
+		return command.CardReply(dailyCard(d, note))
+	}
+	if len(req.Args) > 1 {
+		return nil, errors.New("参数数量不正确")
+	}
+
+	switch strings.ToLower(req.Args[0]) {
+	case "on":
+		if err := s.Subscribe(ctx, scope, req.ChatID, req.SenderID); err != nil {
+			return nil, err
+		}
+		settings, loc := s.currentSettings()
+		if settings.DailySchedule == "" {
+			return command.TextReply("已为当前会话开启每日术语（每日术语的发送时间还没有配置，配置后开始发送）"), nil
+		}
+		return command.TextReply(fmt.Sprintf("已为当前会话开启每日术语，发送时间: %s（%s）", settings.DailySchedule, loc)), nil
+	case "off":
+		err := s.Unsubscribe(ctx, scope, req.ChatID)
+		if errors.Is(err, storage.ErrNotFound) {
+			return command.TextReply("当前会话没有开启每日术语"), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return command.TextReply("已为当前会话关闭每日术语"), nil
+	}
+	return nil, fmt.Errorf("无效的参数: %s（应为 on 或 off）", req.Args[0])
+}
+
+// HandleText 处理「什么是X」「X是什么意思」形式的提问：术语表中有匹配的词条时回复解释，
+// 其他消息交给下一个处理函数
+func (s *Service) HandleText(ctx context.Context, req *command.Request) (*command.Reply, bool, error) {
+	term := questionTerm(req.RawArgs)
+	if term == "" {
+		return nil, false, nil
+	}
+	d, err := s.Define(ctx, term)
+	if errors.Is(err, ErrEmpty) {
+		return nil, false, nil
+	}
+	if err != nil || d == nil {
+		return nil, false, err
+	}
+	reply, err := command.CardReply(termCard(d))
+	return reply, true, err
+}
+
+// HandleAction 处理术语卡片上的相关术语按钮
+func (s *Service) HandleAction(ctx context.Context, req *command.ActionRequest) (*command.Reply, error) {
+	d, err := s.Define(ctx, req.Value["term"])
+	if err != nil {
+		return nil, err
+	}
+	if d == nil {
+		return command.TextReply(fmt.Sprintf("术语「%s」已被删除", req.Value["term"])), nil
+	}
+	return command.CardReply(termCard(d))
+}
+
+// questionTerm 从提问中取出要查询的术语，不是术语提问时返回空字符串
+func questionTerm(text string) string {
+	text = questionEnding.ReplaceAllString(strings.TrimSpace(text), "")
+	var term string
+	if m := questionPrefix.FindStringSubmatch(text); m != nil {
+		term = m[1]
+	} else if m := questionSuffix.FindStringSubmatch(text); m != nil {
+		term = m[1]
+	}
+	term = strings.Trim(strings.TrimSpace(term), "「」“”\"'《》")
+	if term == "" || len([]rune(term)) > maxQuestionTerm {
+		return ""
+	}
+	return term
+}
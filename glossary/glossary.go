@@ -0,0 +1,307 @@
+package glossary
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"fin_bot/audit"
+	"fin_bot/service"
+	"fin_bot/storage"
+
+	"github.com/robfig/cron/v3"
+)
+
+// ErrEmpty 还没有导入任何术语
+var ErrEmpty = errors.New("还没有导入任何术语")
+
+// suggestions 查询时最多列出的候选词条数
+const suggestions = 5
+
+// Settings 可以在运行中修改的术语表设置
+type Settings struct {
+	DailySchedule string // 每日术语的 cron 表达式，为空表示不发送
+	Timezone      string // 每日术语使用的时区（决定每天的术语何时更换）
+}
+
+// Service 金融术语表：导入中英文术语、解释、公式和示例，支持按中文、英文、缩写、拼音模糊查询，
+// 并每天向订阅的会话发送一个术语
+// 术语所有应用共用，订阅按应用、租户和会话隔离
+type Service struct {
+	store *storage.Storage
+	apps  *service.AppRegistry // 发送每日术语
+	now   func() time.Time
+
+	settingsMu sync.RWMutex // 保护 settings 和 loc，配置热加载时会被修改
+	settings   Settings
+	loc        *time.Location
+
+	pushMu  sync.Mutex // 串行化每日术语的发送
+	cronMu  sync.Mutex
+	cron    *cron.Cron
+	entryID cron.EntryID
+}
+
+// New 创建术语表服务
+func New(store *storage.Storage, apps *service.AppRegistry, settings Settings) *Service {
+	s := &Service{store: store, apps: apps, now: time.Now}
+	s.setSettings(settings)
+	return s
+}
+
+// SetSettings 修改术语表设置，每日术语运行中时按新的 cron 表达式和时区重新调度
+func (s *Service) SetSettings(settings Settings) {
+	old, _ := s.currentSettings()
+	s.setSettings(settings)
+
+	if old.DailySchedule != settings.DailySchedule || old.Timezone != settings.Timezone {
+		s.cronMu.Lock()
+		defer s.cronMu.Unlock()
+		if s.cron != nil {
+			if err := s.reschedule(); err != nil {
+				log.Printf("[glossary] 更新每日术语失败: %v", err)
+			}
+		}
+	}
+}
+
+// setSettings 保存设置，时区无效时使用 UTC（配置校验保证时区有效）
+func (s *Service) setSettings(settings Settings) {
+	loc, err := time.LoadLocation(settings.Timezone)
+	if err != nil {
+		log.Printf("[glossary] 无效的时区 %q，使用 UTC: %v", settings.Timezone, err)
+		loc = time.UTC
+	}
+	s.settingsMu.Lock()
+	s.settings = settings
+	s.loc = loc
+	s.settingsMu.Unlock()
+}
+
+// currentSettings 获取当前的术语表设置和时区
+func (s *Service) currentSettings() (Settings, *time.Location) {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return s.settings, s.loc
+}
+
+// ImportResult 导入术语目录的结果
+type ImportResult struct {
+	Files []*File `json:"files"`
+	storage.GlossaryImportStats
+}
+
+// Import 导入目录中的所有术语文件（按中文术语新增或更新，删除这些文件中已不存在的词条）
+// 任何文件有问题时返回 *LoadError，不导入任何术语
+func (s *Service) Import(ctx context.Context, dir string) (*ImportResult, error) {
+	files, terms, err := Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	sources := make([]string, len(files))
+	for i, f := range files {
+		sources[i] = f.Source
+	}
+	stats, err := s.store.ImportGlossary(ctx, sources, terms)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[glossary] 已导入术语: 文件 %d 个，共 %d 条，新增 %d，更新 %d，删除 %d",
+		len(files), len(terms), stats.Added, stats.Updated, stats.Removed)
+	return &ImportResult{Files: files, GlossaryImportStats: stats}, nil
+}
+
+// Definition 查询结果：最接近的词条、可以直接查看的相关术语和其他候选词条
+type Definition struct {
+	Query       string
+	Match       *Match
+	Related     []*storage.GlossaryTerm // 术语表中存在的相关术语
+	Unlisted    []string                // 术语表中没有的相关术语
+	Suggestions []*Match                // 其他匹配的词条（最接近的词条不是完全匹配时用于提示）
+}
+
+// Define 查询术语，没有匹配的词条时返回 nil
+func (s *Service) Define(ctx context.Context, query string) (*Definition, error) {
+	terms, err := s.store.ListGlossaryTerms(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(terms) == 0 {
+		return nil, ErrEmpty
+	}
+	matches := Lookup(terms, query, suggestions+1)
+	if len(matches) == 0 {
+		return nil, nil
+	}
+	d := describe(terms, matches[0])
+	d.Query, d.Suggestions = query, matches[1:]
+	return d, nil
+}
+
+// TermOfDay 当天的术语：按导入顺序轮流，同一天所有会话相同
+func (s *Service) TermOfDay(ctx context.Context) (*Definition, error) {
+	terms, err := s.store.ListGlossaryTerms(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(terms) == 0 {
+		return nil, ErrEmpty
+	}
+	_, loc := s.currentSettings()
+	local := s.now().In(loc)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400
+	t := terms[int(day%int64(len(terms)))]
+	return describe(terms, &Match{Term: t, Key: t.Term, Score: scoreExact}), nil
+}
+
+// describe 补充词条的相关术语
+func describe(terms []*storage.GlossaryTerm, m *Match) *Definition {
+	d := &Definition{Match: m}
+	for _, name := range splitList(m.Term.Related) {
+		if related := Lookup(terms, name, 1); len(related) > 0 && related[0].Exact() && related[0].Term.ID != m.Term.ID {
+			d.Related = append(d.Related, related[0].Term)
+		} else {
+			d.Unlisted = append(d.Unlisted, name)
+		}
+	}
+	return d
+}
+
+// Subscribe 会话订阅每日术语
+func (s *Service) Subscribe(ctx context.Context, scope storage.Scope, chatID, userID string) error {
+	return s.store.SubscribeGlossary(ctx, &storage.GlossarySubscription{
+		AppID: scope.AppID, TenantKey: scope.TenantKey, ChatID: chatID, CreatedBy: userID, CreatedAt: s.now(),
+	})
+}
+
+// Unsubscribe 取消会话的每日术语订阅，没有订阅时返回 storage.ErrNotFound
+func (s *Service) Unsubscribe(ctx context.Context, scope storage.Scope, chatID string) error {
+	return s.store.UnsubscribeGlossary(ctx, scope, chatID)
+}
+
+// Subscribed 会话是否订阅了每日术语
+func (s *Service) Subscribed(ctx context.Context, scope storage.Scope, chatID string) (bool, error) {
+	_, err := s.store.GetGlossarySubscription(ctx, scope, chatID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Start 启动每日术语
+func (s *Service) Start(ctx context.Context) error {
+	s.cronMu.Lock()
+	defer s.cronMu.Unlock()
+
+	s.cron = cron.New()
+	if err := s.reschedule(); err != nil {
+		return err
+	}
+	s.cron.Start()
+	return nil
+}
+
+// Stop 停止每日术语，等待正在发送的术语完成
+func (s *Service) Stop(ctx context.Context) error {
+	s.cronMu.Lock()
+	c := s.cron
+	s.cron = nil
+	s.cronMu.Unlock()
+	if c == nil {
+		return nil
+	}
+
+	select {
+	case <-c.Stop().Done():
+		log.Println("[glossary] 每日术语已停止")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reschedule 按当前设置替换每日术语任务，调用方需持有 cronMu
+func (s *Service) reschedule() error {
+	if s.entryID != 0 {
+		s.cron.Remove(s.entryID)
+		s.entryID = 0
+	}
+	settings, loc := s.currentSettings()
+	if settings.DailySchedule == "" {
+		log.Println("[glossary] 未配置每日术语")
+		return nil
+	}
+
+	spec := "CRON_TZ=" + loc.String() + " " + settings.DailySchedule
+	id, err := s.cron.AddFunc(spec, func() { s.Push(context.Background()) })
+	if err != nil {
+		return fmt.Errorf("无效的每日术语表达式 %q: %w", settings.DailySchedule, err)
+	}
+	s.entryID = id
+	log.Printf("[glossary] 每日术语已启用: schedule=%q, timezone=%s", settings.DailySchedule, loc)
+	return nil
+}
+
+// Push 向所有订阅的会话发送当天的术语，每个会话每天最多一次，返回发送的会话数
+func (s *Service) Push(ctx context.Context) int {
+	s.pushMu.Lock()
+	defer s.pushMu.Unlock()
+
+	d, err := s.TermOfDay(ctx)
+	if errors.Is(err, ErrEmpty) {
+		log.Println("[glossary] 还没有导入任何术语，跳过每日术语")
+		return 0
+	}
+	if err != nil {
+		log.Printf("[glossary] 获取每日术语失败: %v", err)
+		return 0
+	}
+	subs, err := s.store.ListGlossarySubscriptions(ctx)
+	if err != nil {
+		log.Printf("[glossary] 加载每日术语订阅失败: %v", err)
+		return 0
+	}
+	content, err := json.Marshal(dailyCard(d, "发送 /define <术语> 查询其他术语，管理员发送 /termofday off 取消订阅"))
+	if err != nil {
+		log.Printf("[glossary] 生成每日术语卡片失败: %v", err)
+		return 0
+	}
+
+	now := s.now()
+	_, loc := s.currentSettings()
+	local := now.In(loc)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	sent := 0
+	for _, sub := range subs {
+		if ctx.Err() != nil {
+			break
+		}
+		if sub.LastSentAt != nil && !sub.LastSentAt.Before(today) {
+			continue
+		}
+		if err := s.push(ctx, sub, string(content), now); err != nil {
+			log.Printf("[glossary] 发送每日术语失败: app=%s, tenant=%s, chat=%s, error=%v", sub.AppID, sub.TenantKey, sub.ChatID, err)
+			continue
+		}
+		sent++
+	}
+	log.Printf("[glossary] 每日术语已发送: term=%s, chats=%d, sent=%d", d.Match.Term.Term, len(subs), sent)
+	return sent
+}
+
+// push 向一个会话发送每日术语并记录发送时间
+func (s *Service) push(ctx context.Context, sub *storage.GlossarySubscription, content string, now time.Time) error {
+	larkService, err := s.apps.LarkService(sub.AppID)
+	if err != nil {
+		return err
+	}
+	ctx = audit.WithActor(ctx, audit.Actor{ID: "glossary:daily", Via: audit.ViaSystem, TenantKey: sub.TenantKey})
+	if err := larkService.SendCardMessage(ctx, sub.ChatID, "chat_id", content); err != nil {
+		return err
+	}
+	return s.store.MarkGlossarySent(ctx, storage.Scope{AppID: sub.AppID, TenantKey: sub.TenantKey}, sub.ChatID, now)
+}
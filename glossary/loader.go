@@ -0,0 +1,226 @@
+package glossary
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"fin_bot/storage"
+)
+
+// 术语目录中每个 .csv 文件是一组术语（可以放在子目录中），以 . 或 _ 开头的文件和目录会被忽略；
+// 文件名去掉数字前缀和扩展名后作为来源，重新导入一个文件时删除文件中已不存在的词条
+//
+// CSV 格式：第一行为表头（term,english,aliases,pinyin,definition,formula,example,related，其中 term、definition 必填），
+// 以 # 开头的行为注释；aliases 和 related 中的多个值用 | 或 、 分隔；
+// pinyin 为中文术语的拼音（不带声调，音节之间用空格分隔，ü 写作 v），用于拼音和拼音首字母查询
+var (
+	// orderPrefix 文件名中表示顺序的数字前缀，例如 01-
+	orderPrefix = regexp.MustCompile(`^\d+[-_. ]+`)
+	// pinyinPattern 拼音只能包含字母和空格
+	pinyinPattern = regexp.MustCompile(`^[a-z]+( [a-z]+)*$`)
+)
+
+// csvColumns 术语文件支持的列
+var csvColumns = []string{"term", "english", "aliases", "pinyin", "definition", "formula", "example", "related"}
+
+// LoadError 术语文件校验错误，包含所有问题而不是遇到第一个就返回
+type LoadError struct {
+	Problems []string
+}
+
+func (e *LoadError) Error() string {
+	return fmt.Sprintf("术语文件校验失败（%d 项）:\n  - %s", len(e.Problems), strings.Join(e.Problems, "\n  - "))
+}
+
+// File 一个术语文件
+type File struct {
+	Source string `json:"source"`
+	Path   string `json:"path"`
+	Terms  int    `json:"terms"`
+}
+
+// Load 读取目录中的所有术语文件，任何文件有问题时返回 *LoadError 且不返回任何术语
+func Load(dir string) ([]*File, []*storage.GlossaryTerm, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, nil, fmt.Errorf("读取术语目录失败: %w", err)
+	}
+
+	l := &loader{where: make(map[string]string)}
+	var files []*File
+	sources := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir && ignored(entry.Name()) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(path), ".csv") {
+			return nil
+		}
+
+		source := idFromName(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+		if other, ok := sources[source]; ok {
+			l.addf("%s: 来源 %s 与 %s 重复（文件名去掉数字前缀后不能相同）", path, source, other)
+			return nil
+		}
+		sources[source] = path
+		if n, ok := l.loadCSV(path, source); ok {
+			files = append(files, &File{Source: source, Path: path, Terms: n})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取术语目录失败: %w", err)
+	}
+
+	if len(l.problems) > 0 {
+		return nil, nil, &LoadError{Problems: l.problems}
+	}
+	return files, l.terms, nil
+}
+
+// loader 读取术语文件时收集问题
+type loader struct {
+	problems []string
+	terms    []*storage.GlossaryTerm
+	where    map[string]string // 术语第一次出现的位置，用于检查重复
+}
+
+func (l *loader) addf(format string, args ...interface{}) {
+	l.problems = append(l.problems, fmt.Sprintf(format, args...))
+}
+
+// loadCSV 读取一个术语文件，返回词条数
+func (l *loader) loadCSV(path, source string) (int, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		l.addf("%s: %v", path, err)
+		return 0, false
+	}
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+
+	header, err := r.Read()
+	if errors.Is(err, io.EOF) {
+		l.addf("%s: 缺少表头", path)
+		return 0, false
+	}
+	if err != nil {
+		l.addf("%s: CSV 格式错误: %v", path, err)
+		return 0, false
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !slices.Contains(csvColumns, name) {
+			l.addf("%s: 未知的列 %q（支持 %s）", path, name, strings.Join(csvColumns, ","))
+			return 0, false
+		}
+		columns[name] = i
+	}
+	for _, name := range []string{"term", "definition"} {
+		if _, ok := columns[name]; !ok {
+			l.addf("%s: 缺少 %s 列", path, name)
+			return 0, false
+		}
+	}
+
+	problems := len(l.problems)
+	count := 0
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			l.addf("%s: CSV 格式错误: %v", path, err)
+			return 0, false
+		}
+		line, _ := r.FieldPos(0)
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		where := fmt.Sprintf("%s: 第 %d 行", path, line)
+		count++
+
+		t := &storage.GlossaryTerm{
+			Source:     source,
+			Term:       field("term"),
+			English:    field("english"),
+			Aliases:    joinList(field("aliases")),
+			Pinyin:     strings.Join(strings.Fields(strings.ReplaceAll(strings.ToLower(field("pinyin")), "ü", "v")), " "),
+			Definition: field("definition"),
+			Formula:    field("formula"),
+			Example:    field("example"),
+			Related:    joinList(field("related")),
+		}
+		switch {
+		case t.Term == "":
+			l.addf("%s: 缺少术语", where)
+			continue
+		case t.Definition == "":
+			l.addf("%s: 术语 %q 缺少解释", where, t.Term)
+			continue
+		case t.Pinyin != "" && !pinyinPattern.MatchString(t.Pinyin):
+			l.addf("%s: 术语 %q 的拼音 %q 只能包含字母和空格（不带声调）", where, t.Term, t.Pinyin)
+			continue
+		}
+		if other, ok := l.where[t.Term]; ok {
+			l.addf("%s: 术语 %q 与 %s 重复", where, t.Term, other)
+			continue
+		}
+		l.where[t.Term] = where
+		l.terms = append(l.terms, t)
+	}
+	if count == 0 {
+		l.addf("%s: 文件中没有任何术语", path)
+		return 0, false
+	}
+	return count, len(l.problems) == problems
+}
+
+// splitList 拆分 | 或 、 分隔的多个值
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.FieldsFunc(s, func(r rune) bool { return r == '|' || r == '、' }) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// joinList 把多个值统一为 | 分隔保存
+func joinList(s string) string {
+	return strings.Join(splitList(s), "|")
+}
+
+// idFromName 去掉文件名中的顺序前缀
+func idFromName(name string) string {
+	if id := orderPrefix.ReplaceAllString(name, ""); id != "" {
+		return id
+	}
+	return name
+}
+
+// ignored 是否忽略的文件或目录
+func ignored(name string) bool {
+	return strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")
+}
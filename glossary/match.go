@@ -0,0 +1,177 @@
+package glossary
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"fin_bot/storage"
+)
+
+// 匹配程度，数值越小越接近
+const (
+	scoreExact    = 0 // 与术语、英文名、别名或拼音完全相同
+	scorePrefix   = 1 // 是某个名称的开头
+	scoreContains = 2 // 包含某个名称或被某个名称包含
+	scoreFuzzy    = 3 // 编辑距离在允许范围内，实际分数再加上距离
+)
+
+// Match 一个匹配的词条
+type Match struct {
+	Term  *storage.GlossaryTerm
+	Key   string // 匹配到的名称（术语、英文名、别名或拼音）
+	Score int
+}
+
+// Exact 是否完全匹配
+func (m *Match) Exact() bool {
+	return m.Score == scoreExact
+}
+
+// key 词条的一个可查询名称
+type key struct {
+	text  string // 展示用的原文
+	norm  string // 规范化之后
+	fuzzy bool   // 是否允许按编辑距离匹配（拼音首字母太短，只允许完全匹配和前缀匹配）
+}
+
+// keys 词条的所有可查询名称：中文术语、英文名、别名、拼音、拼音首字母和英文名的首字母缩写
+func keys(t *storage.GlossaryTerm) []key {
+	var ks []key
+	add := func(text string, fuzzy bool) {
+		if n := normalize(text); n != "" {
+			ks = append(ks, key{text: text, norm: n, fuzzy: fuzzy})
+		}
+	}
+	add(t.Term, true)
+	add(t.English, true)
+	for _, alias := range splitList(t.Aliases) {
+		add(alias, true)
+	}
+	if t.Pinyin != "" {
+		add(t.Pinyin, true)
+		var initials strings.Builder
+		for _, syllable := range strings.Fields(t.Pinyin) {
+			initials.WriteByte(syllable[0])
+		}
+		add(initials.String(), false)
+	}
+	if words := strings.FieldsFunc(t.English, func(r rune) bool { return !unicode.IsLetter(r) }); len(words) > 1 {
+		var acronym strings.Builder
+		for _, w := range words {
+			acronym.WriteRune(unicode.ToLower([]rune(w)[0]))
+		}
+		add(acronym.String(), false)
+	}
+	return ks
+}
+
+// Lookup 在词条中查找与 query 匹配的词条，按匹配程度排列（相同时按导入顺序），最多返回 limit 个
+func Lookup(terms []*storage.GlossaryTerm, query string, limit int) []*Match {
+	q := normalize(query)
+	if q == "" {
+		return nil
+	}
+	var matches []*Match
+	for _, t := range terms {
+		var best *Match
+		for _, k := range keys(t) {
+			score, ok := compare(q, k)
+			if ok && (best == nil || score < best.Score) {
+				best = &Match{Term: t, Key: k.text, Score: score}
+			}
+		}
+		if best != nil {
+			matches = append(matches, best)
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score < matches[j].Score })
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// compare 比较规范化后的查询和名称
+func compare(q string, k key) (int, bool) {
+	switch {
+	case q == k.norm:
+		return scoreExact, true
+	case runeLen(q) >= 2 && strings.HasPrefix(k.norm, q):
+		return scorePrefix, true
+	case !k.fuzzy:
+		return 0, false
+	case runeLen(q) >= 2 && strings.Contains(k.norm, q), significant(k.norm) && strings.Contains(q, k.norm):
+		return scoreContains, true
+	}
+	if d := distance(q, k.norm); d <= tolerance(runeLen(q)) {
+		return scoreFuzzy + d, true
+	}
+	return 0, false
+}
+
+// significant 名称是否足够长，查询中包含这个名称时才算匹配（例如「市盈率怎么算」包含「市盈率」）
+func significant(norm string) bool {
+	for _, r := range norm {
+		if r > unicode.MaxASCII {
+			return runeLen(norm) >= 2
+		}
+	}
+	return len(norm) >= 3
+}
+
+// tolerance 允许的编辑距离，查询越长允许的错误越多，太短的查询只允许完全匹配
+func tolerance(n int) int {
+	switch {
+	case n <= 2:
+		return 0
+	case n <= 4:
+		return 1
+	case n <= 8:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// normalize 规范化名称：全角字符转为半角，字母转为小写，只保留字母、数字和汉字
+// 例如 "P/E"、"ｐｅ" 和 "pe" 相同
+func normalize(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '！' && r <= '～' {
+			r -= '！' - '!'
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+// distance 两个字符串按字符（而不是字节）计算的编辑距离
+func distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// runeLen 字符数
+func runeLen(s string) int {
+	return len([]rune(s))
+}
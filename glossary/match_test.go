@@ -0,0 +1,95 @@
+package glossary
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"fin_bot/storage"
+)
+
+// testTerms 测试用的词条（按导入顺序）
+var testTerms = []*storage.GlossaryTerm{
+	{Term: "市盈率", English: "Price-to-Earnings Ratio", Aliases: "PE|P/E", Pinyin: "shi ying lv"},
+	{Term: "市净率", English: "Price-to-Book Ratio", Aliases: "PB|P/B", Pinyin: "shi jing lv"},
+	{Term: "净资产收益率", English: "Return on Equity", Aliases: "ROE", Pinyin: "jing zi chan shou yi lv"},
+	{Term: "资产负债率", English: "Debt to Asset Ratio", Pinyin: "zi chan fu zhai lv"},
+}
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		limit int
+		want  []string // 词条和分数，例如 "市盈率/0"
+	}{
+		{"中文完全匹配排在相近的术语之前", "市盈率", 5, []string{"市盈率/0", "市净率/4"}},
+		{"英文缩写", "roe", 5, []string{"净资产收益率/0"}},
+		{"全角和标点", "Ｐ／Ｅ", 5, []string{"市盈率/0"}},
+		{"拼音首字母", "syl", 5, []string{"市盈率/0"}},
+		{"英文名首字母缩写", "DTAR", 5, []string{"资产负债率/0"}},
+		{"拼音前缀", "shi ying", 5, []string{"市盈率/1"}},
+		{"拼音首字母前缀", "sy", 5, []string{"市盈率/1"}},
+		{"英文前缀", "price to earnings", 5, []string{"市盈率/1"}},
+		{"提问中包含术语", "市盈率怎么算", 5, []string{"市盈率/2"}},
+		{"英文拼写错误", "Retrun on Equity", 5, []string{"净资产收益率/5"}},
+		{"拼音拼写错误按距离排序", "shi yin lv", 5, []string{"市盈率/4", "市净率/5"}},
+		{"中文错别字距离相同时按导入顺序", "市赢率", 5, []string{"市盈率/4", "市净率/4"}},
+		{"太短的查询不模糊匹配", "pe", 5, []string{"市盈率/0"}},
+		{"拼音首字母不模糊匹配", "syx", 5, nil},
+		{"单个汉字", "率", 5, nil},
+		{"没有匹配", "xyz", 5, nil},
+		{"空查询", " ？ ", 5, nil},
+		{"最多返回 limit 个", "lv", 2, []string{"市盈率/2", "市净率/2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, m := range Lookup(testTerms, tt.query, tt.limit) {
+				got = append(got, fmt.Sprintf("%s/%d", m.Term.Term, m.Score))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lookup(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"P/E", "pe"},
+		{"ｐｅ", "pe"},
+		{"Return-on Equity", "returnonequity"},
+		{"市盈率（TTM）", "市盈率ttm"},
+		{"？！", ""},
+	}
+	for _, tt := range tests {
+		if got := normalize(tt.in); got != tt.want {
+			t.Errorf("normalize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"市盈率", "市赢率", 1},
+		{"市盈率", "市净率", 1},
+		{"shiyinlv", "shiyinglv", 1},
+		{"retrun", "return", 2},
+	}
+	for _, tt := range tests {
+		if got := distance(tt.a, tt.b); got != tt.want {
+			t.Errorf("distance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := distance(tt.b, tt.a); got != tt.want {
+			t.Errorf("distance(%q, %q) = %d, want %d", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package glossary
+
+import (
+	"fmt"
+	"strings"
+
+	"fin_bot/card"
+	"fin_bot/command"
+)
+
+// ActionDefine 术语卡片上相关术语按钮的交互名称
+const ActionDefine = "glossary.define"
+
+// maxRelatedButtons 术语卡片上最多的相关术语按钮数
+const maxRelatedButtons = 5
+
+// termCard 术语的解释卡片，最接近的词条不是完全匹配时注明，并列出其他候选词条
+func termCard(d *Definition) *card.Card {
+	cd := card.New(title(d), card.ColorTurquoise)
+	if !d.Match.Exact() && d.Query != "" {
+		cd.Add(card.Markdown(fmt.Sprintf("没有找到「%s」，最接近的是「%s」", d.Query, d.Match.Key)))
+	}
+	addBody(cd, d)
+
+	if len(d.Suggestions) > 0 && !d.Match.Exact() {
+		names := make([]string, len(d.Suggestions))
+		for i, m := range d.Suggestions {
+			names[i] = m.Term.Term
+		}
+		cd.Add(card.Note("你可能还想找: " + strings.Join(names, "、")))
+	}
+	return cd
+}
+
+// dailyCard 每日术语卡片，note 为底部的说明
+func dailyCard(d *Definition, note string) *card.Card {
+	cd := card.New("📖 每日术语 · "+title(d), card.ColorBlue)
+	addBody(cd, d)
+	return cd.Add(card.Note(note))
+}
+
+// title 卡片标题：中文术语和英文名
+func title(d *Definition) string {
+	t := d.Match.Term
+	if t.English != "" {
+		return fmt.Sprintf("%s（%s）", t.Term, t.English)
+	}
+	return t.Term
+}
+
+// addBody 术语的别名、解释、公式、示例和相关术语
+func addBody(cd *card.Card, d *Definition) {
+	t := d.Match.Term
+	text := t.Definition
+	if aliases := splitList(t.Aliases); len(aliases) > 0 {
+		text = "**又称** " + strings.Join(aliases, "、") + "\n" + text
+	}
+	cd.Add(card.Markdown(text))
+	if t.Formula != "" {
+		cd.Add(card.Markdown("**公式** " + t.Formula))
+	}
+	if t.Example != "" {
+		cd.Add(card.Markdown("**示例** " + t.Example))
+	}
+
+	if len(d.Related) == 0 && len(d.Unlisted) == 0 {
+		return
+	}
+	cd.Add(card.Divider())
+	names := make([]string, 0, len(d.Related)+len(d.Unlisted))
+	for _, r := range d.Related {
+		names = append(names, r.Term)
+	}
+	cd.Add(card.Markdown("**相关术语** " + strings.Join(append(names, d.Unlisted...), "、")))
+	var buttons []interface{}
+	for i, r := range d.Related {
+		if i == maxRelatedButtons {
+			break
+		}
+		buttons = append(buttons, card.Button(r.Term, card.ButtonDefault, map[string]string{
+			command.ActionKey: ActionDefine,
+			"term":            r.Term,
+		}))
+	}
+	if len(buttons) > 0 {
+		cd.Add(card.Actions(buttons...))
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"fin_bot/config"
+	"fin_bot/glossary"
+)
+
+// runGlossaryImportCommand 导入术语目录中的 CSV 文件，-dry-run 时只校验文件
+func runGlossaryImportCommand(configPath string, args []string) int {
+	fs := newCLIFlags("glossary import", "[-dir 术语目录] [-db 数据库] [-dry-run] [-format text|json]")
+	dir := fs.String("dir", "", "术语目录（默认使用配置中的 glossary.dir）")
+	dbPath := fs.String("db", "", "数据库文件路径（默认使用配置中的 database.path）")
+	dryRun := fs.Bool("dry-run", false, "只校验术语文件，不写入数据库")
+	format := fs.String("format", "text", "输出格式: text 或 json")
+	fs.logFlag()
+	if code := fs.parse(args); code != exitOK {
+		return code
+	}
+	if *format != "text" && *format != "json" {
+		return fs.usageError("无效的输出格式: %s", *format)
+	}
+
+	cfg := loadConfig(configPath, false)
+	if cfg == nil {
+		return exitError
+	}
+	if *dir == "" {
+		*dir = cfg.Glossary.Dir
+	}
+
+	result := &glossary.ImportResult{}
+	if *dryRun {
+		files, _, err := glossary.Load(*dir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+		result.Files = files
+	} else {
+		store, err := openStorage(cfg, *dbPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "打开数据库失败: %v\n", err)
+			return exitError
+		}
+		defer store.Close()
+
+		result, err = glossary.New(store, nil, glossarySettings(cfg.Glossary)).Import(context.Background(), *dir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+	}
+	if result.Files == nil {
+		result.Files = []*glossary.File{}
+	}
+
+	if *format == "json" {
+		if err := json.NewEncoder(os.Stdout).Encode(result); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+		return exitOK
+	}
+	total := 0
+	for _, f := range result.Files {
+		fmt.Printf("%s\t%s\t%d 条\n", f.Source, f.Path, f.Terms)
+		total += f.Terms
+	}
+	if *dryRun {
+		fmt.Fprintf(os.Stderr, "%s 中的 %d 个文件、%d 条术语校验通过\n", *dir, len(result.Files), total)
+	} else {
+		fmt.Fprintf(os.Stderr, "已从 %s 导入 %d 条术语（新增 %d，更新 %d，未变 %d，删除 %d）\n",
+			*dir, total, result.Added, result.Updated, result.Unchanged, result.Removed)
+	}
+	return exitOK
+}
+
+// importGlossary 服务启动和配置变更时自动导入术语目录，失败时保留数据库中已有的术语
+func importGlossary(ctx context.Context, terms *glossary.Service, c config.GlossaryConfig) {
+	if !c.ImportOnLoad {
+		return
+	}
+	if _, err := os.Stat(c.Dir); errors.Is(err, os.ErrNotExist) {
+		log.Printf("[glossary] 术语目录 %s 不存在，跳过导入", c.Dir)
+		return
+	}
+	result, err := terms.Import(ctx, c.Dir)
+	if err != nil {
+		log.Printf("[警告] 导入术语失败，继续使用已导入的术语: %v", err)
+		return
+	}
+	fmt.Printf("已导入 %d 个术语文件: %s\n", len(result.Files), c.Dir)
+}
+
+// glossarySettings 将配置转换为术语表设置
+func glossarySettings(c config.GlossaryConfig) glossary.Settings {
+	return glossary.Settings{
+		DailySchedule: c.DailySchedule,
+		Timezone:      c.Timezone,
+	}
+}
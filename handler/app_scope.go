@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+
+	"fin_bot/service"
+	"fin_bot/storage"
+
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+// 选择应用和租户的请求头，也可以使用同名的查询参数 app、tenant_key
+const (
+	HeaderAppID     = "X-App-ID"
+	HeaderTenantKey = "X-Tenant-Key"
+)
+
+// resolveApp 根据请求选择应用（应用名称或 App ID）
+// 只托管一个应用时可以省略；托管多个应用时必须显式指定，避免误操作其他应用的数据
+// 失败时直接写入 400/404 响应
+func resolveApp(apps *service.AppRegistry, c *app.RequestContext) (*service.App, bool) {
+	name := string(c.GetHeader(HeaderAppID))
+	if name == "" {
+		name = c.Query("app")
+	}
+
+	if name == "" {
+		if all := apps.All(); len(all) > 1 {
+			writeError(c, 400, "请选择应用", fmt.Errorf("托管了 %d 个应用，需要通过 %s 请求头或 app 参数指定", len(all), HeaderAppID))
+			return nil, false
+		}
+		selected, err := apps.Default()
+		if err != nil {
+			writeError(c, 500, "没有可用的应用", err)
+			return nil, false
+		}
+		return selected, true
+	}
+
+	selected, err := apps.Get(name)
+	if err != nil {
+		code := 500
+		if errors.Is(err, service.ErrUnknownApp) {
+			code = 404
+		}
+		writeError(c, code, "应用不存在", err)
+		return nil, false
+	}
+	return selected, true
+}
+
+// resolveScope 根据请求确定数据范围：应用由 resolveApp 选择，
+// 租户取 X-Tenant-Key 请求头或 tenant_key 参数，未指定时使用应用配置的 tenant_key
+func resolveScope(apps *service.AppRegistry, c *app.RequestContext) (storage.Scope, bool) {
+	selected, ok := resolveApp(apps, c)
+	if !ok {
+		return storage.Scope{}, false
+	}
+
+	tenantKey := string(c.GetHeader(HeaderTenantKey))
+	if tenantKey == "" {
+		tenantKey = c.Query("tenant_key")
+	}
+	if tenantKey == "" {
+		tenantKey = selected.TenantKey
+	}
+	return storage.Scope{AppID: selected.AppID, TenantKey: tenantKey}, true
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"fin_bot/audit"
+	"fin_bot/service"
+	"fin_bot/storage"
+
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+// AuditHandler 审计日志查询接口
+type AuditHandler struct {
+	apps    *service.AppRegistry
+	storage *storage.Storage
+}
+
+// NewAuditHandler 创建新的审计日志处理器
+func NewAuditHandler(apps *service.AppRegistry, store *storage.Storage) *AuditHandler {
+	return &AuditHandler{apps: apps, storage: store}
+}
+
+// List 查询所选应用和租户的审计日志（同时返回配置重载等与应用无关的记录），按时间倒序
+// GET /api/audit?action=role.&actor=ou_xxx&since=2024-01-01T00:00:00Z&until=...&limit=100
+func (h *AuditHandler) List(ctx context.Context, c *app.RequestContext) {
+	scope, ok := resolveScope(h.apps, c)
+	if !ok {
+		return
+	}
+
+	filter := storage.AuditFilter{
+		Scope:         &scope,
+		IncludeGlobal: true,
+		Action:        c.Query("action"),
+		Actor:         c.Query("actor"),
+	}
+	for name, dest := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if raw := c.Query(name); raw != "" {
+			t, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				writeError(c, 400, "无效的 "+name+" 参数", err)
+				return
+			}
+			*dest = t
+		}
+	}
+
+	limit := 100
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > 1000 {
+			writeError(c, 400, "无效的 limit 参数", fmt.Errorf("limit 必须是 1-1000 之间的整数"))
+			return
+		}
+		limit = n
+	}
+
+	entries, err := h.storage.ListAudit(ctx, filter, limit)
+	if err != nil {
+		writeError(c, 500, "查询审计日志失败", err)
+		return
+	}
+	writeOK(c, "ok", entries)
+}
+
+// Verify 校验整个审计日志的哈希链
+// GET /api/audit/verify
+func (h *AuditHandler) Verify(ctx context.Context, c *app.RequestContext) {
+	count, head, err := h.storage.VerifyAudit(ctx)
+	var chainErr *storage.AuditChainError
+	if errors.As(err, &chainErr) {
+		c.JSON(409, map[string]interface{}{
+			"code":    409,
+			"message": "审计日志哈希链校验失败",
+			"error":   err.Error(),
+			"data":    map[string]interface{}{"verified": count, "broken_at": chainErr.ID},
+		})
+		return
+	}
+	if err != nil {
+		writeError(c, 500, "校验审计日志失败", err)
+		return
+	}
+	writeOK(c, "审计日志哈希链校验通过", map[string]interface{}{
+		"verified":  count,
+		"head_hash": head,
+	})
+}
+
+// AuditMiddleware 为管理接口设置操作人（http:<客户端IP>），并记录所有修改数据的请求（非 GET）
+// 通过接口发送的消息由 LarkService 单独记录
+func AuditMiddleware(logger *audit.Logger) app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		ctx = audit.WithActor(ctx, audit.Actor{ID: "http:" + c.ClientIP(), Via: audit.ViaHTTP})
+		c.Next(ctx)
+
+		method := string(c.Method())
+		if method == "GET" || method == "HEAD" {
+			return
+		}
+		logger.Record(ctx, storage.Scope{}, audit.ActionHTTP+method, string(c.Path()),
+			fmt.Sprintf("route=%s status=%d", c.FullPath(), c.Response.StatusCode()))
+	}
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+// HeaderAPIKey 传递 API Key 的请求头，也可以使用 Authorization: Bearer <key>
+const HeaderAPIKey = "X-API-Key"
+
+// APIKeyAuth HTTP 管理接口的认证，API Key 支持热加载
+type APIKeyAuth struct {
+	mu  sync.RWMutex
+	key string
+}
+
+// NewAPIKeyAuth 创建认证中间件，key 为空时不校验
+func NewAPIKeyAuth(key string) *APIKeyAuth {
+	return &APIKeyAuth{key: key}
+}
+
+// SetKey 替换 API Key
+func (a *APIKeyAuth) SetKey(key string) {
+	a.mu.Lock()
+	a.key = key
+	a.mu.Unlock()
+}
+
+// Middleware 校验请求携带的 API Key，不匹配时返回 401
+func (a *APIKeyAuth) Middleware() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		a.mu.RLock()
+		key := a.key
+		a.mu.RUnlock()
+		if key == "" {
+			c.Next(ctx)
+			return
+		}
+
+		provided := string(c.GetHeader(HeaderAPIKey))
+		if provided == "" {
+			provided, _ = strings.CutPrefix(string(c.GetHeader("Authorization")), "Bearer ")
+		}
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(key)) != 1 {
+			writeError(c, 401, "未认证", errors.New("缺少或错误的 API Key"))
+			c.Abort()
+			return
+		}
+		c.Next(ctx)
+	}
+}
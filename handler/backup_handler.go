@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"fin_bot/backup"
+
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+// BackupHandler 数据库备份管理接口
+// 恢复需要停止服务，只能通过 fin_bot db restore 命令执行
+type BackupHandler struct {
+	backups *backup.Manager
+}
+
+// NewBackupHandler 创建新的备份处理器
+func NewBackupHandler(backups *backup.Manager) *BackupHandler {
+	return &BackupHandler{backups: backups}
+}
+
+// List 列出备份目录中的备份（按时间倒序）
+// GET /api/backups
+func (h *BackupHandler) List(ctx context.Context, c *app.RequestContext) {
+	backups, err := h.backups.List()
+	if err != nil {
+		writeError(c, 500, "获取备份列表失败", err)
+		return
+	}
+	writeOK(c, "ok", backups)
+}
+
+// Create 立即生成一个备份（同步执行，完成后返回备份信息）
+// POST /api/backups
+func (h *BackupHandler) Create(ctx context.Context, c *app.RequestContext) {
+	info, err := h.backups.Create(ctx)
+	if err != nil {
+		writeError(c, 500, "备份失败", err)
+		return
+	}
+	writeOK(c, "备份已生成", info)
+}
+
+// Verify 校验备份能否解密、解压并通过完整性检查
+// POST /api/backups/:name/verify
+func (h *BackupHandler) Verify(ctx context.Context, c *app.RequestContext) {
+	name := c.Param("name")
+	version, err := h.backups.Verify(ctx, name)
+	if err != nil {
+		code := 422
+		if errors.Is(err, backup.ErrNotFound) {
+			code = 404
+		}
+		writeError(c, code, "备份校验失败", err)
+		return
+	}
+	writeOK(c, "备份校验通过", map[string]interface{}{
+		"name":           name,
+		"schema_version": version,
+	})
+}
@@ -0,0 +1,381 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"fin_bot/audit"
+	"fin_bot/command"
+	"fin_bot/eventlog"
+	"fin_bot/metrics"
+	"fin_bot/ratelimit"
+	"fin_bot/service"
+	"fin_bot/storage"
+	"fin_bot/worker"
+
+	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
+	larkevent "github.com/larksuite/oapi-sdk-go/v3/event"
+	"github.com/larksuite/oapi-sdk-go/v3/event/dispatcher"
+	"github.com/larksuite/oapi-sdk-go/v3/event/dispatcher/callback"
+	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
+)
+
+// EventHandler 飞书事件处理器，所有应用共用，事件按收到它的应用区分
+type EventHandler struct {
+	storage  *storage.Storage
+	router   *command.Router
+	recorder *eventlog.Recorder
+	limiter  *ratelimit.Limiter
+}
+
+// NewEventHandler 创建新的事件处理器，recorder 为 nil 时不录制事件，limiter 为 nil 时不限流
+func NewEventHandler(store *storage.Storage, router *command.Router, recorder *eventlog.Recorder, limiter *ratelimit.Limiter) *EventHandler {
+	return &EventHandler{
+		storage:  store,
+		router:   router,
+		recorder: recorder,
+		limiter:  limiter,
+	}
+}
+
+// Dispatcher 为单个应用创建事件分发器，收到的事件交给 eventPool 处理
+// 线上使用 *worker.Pool 异步处理，回放时使用 worker.Inline 按顺序同步处理
+func (h *EventHandler) Dispatcher(app *service.App, eventPool worker.Submitter) *dispatcher.EventDispatcher {
+	/**
+	 * 注册事件处理器。
+	 * Register event handler.
+	 */
+	return dispatcher.NewEventDispatcher("", "").
+		/**
+		 * 注册接收消息事件，处理接收到的消息。
+		 * Register event handler to handle received messages.
+		 * https://open.feishu.cn/document/uAjLw4CM/ukTMukTMukTM/reference/im-v1/message/events/receive
+		 */
+		OnP2MessageReceiveV1(func(ctx context.Context, event *larkim.P2MessageReceiveV1) error {
+			metrics.EventsReceived.WithLabelValues("im.message.receive_v1").Inc()
+			h.record(app, "im.message.receive_v1", event.EventReq)
+
+			// 放入任务队列异步处理，尽快返回以免长连接推送超时重试
+			err := eventPool.Submit(func(ctx context.Context) {
+				if err := h.HandleMessage(ctx, app, event); err != nil {
+					log.Printf("[错误] 处理消息事件失败: %v", err)
+				}
+			})
+			if err != nil {
+				log.Printf("[错误] 消息事件入队失败: %v", err)
+			}
+			return err
+		}).
+		/**
+		 * 注册卡片回传交互回调，处理消息卡片上的按钮点击。
+		 * 需要在开发者后台订阅 card.action.trigger 回调并选择长连接接收。
+		 * https://open.feishu.cn/document/uAjLw4CM/ukzMukzMukzM/feishu-cards/card-callback-communication
+		 */
+		OnP2CardActionTrigger(func(ctx context.Context, event *callback.CardActionTriggerEvent) (*callback.CardActionTriggerResponse, error) {
+			metrics.EventsReceived.WithLabelValues("card.action.trigger").Inc()
+			h.record(app, "card.action.trigger", event.EventReq)
+
+			// 回调需要在 3 秒内响应，处理结果作为新消息发送到卡片所在的会话
+			err := eventPool.Submit(func(ctx context.Context) {
+				if err := h.HandleCardAction(ctx, app, event); err != nil {
+					log.Printf("[错误] 处理卡片交互失败: %v", err)
+				}
+			})
+			if err != nil {
+				log.Printf("[错误] 卡片交互入队失败: %v", err)
+				return &callback.CardActionTriggerResponse{Toast: &callback.Toast{Type: "error", Content: "机器人繁忙，请稍后再试"}}, nil
+			}
+			return &callback.CardActionTriggerResponse{Toast: &callback.Toast{Type: "info", Content: "已收到"}}, nil
+		})
+}
+
+// record 录制原始事件，录制失败不影响事件处理
+func (h *EventHandler) record(app *service.App, eventType string, req *larkevent.EventReq) {
+	if h.recorder == nil || req == nil {
+		return
+	}
+	if err := h.recorder.Record(app.Name, app.AppID, eventType, req.Body); err != nil {
+		log.Printf("[警告] 录制事件失败: %v", err)
+	}
+}
+
+// HandleMessage 处理接收到的消息事件：记录会话、保存消息并回复
+// 消息按收到事件的应用和事件中的 tenant_key 归属，回复也使用同一个应用发送
+func (h *EventHandler) HandleMessage(ctx context.Context, app *service.App, event *larkim.P2MessageReceiveV1) error {
+	start := time.Now()
+	defer func() {
+		metrics.HandlerDuration.WithLabelValues("im.message.receive_v1").Observe(time.Since(start).Seconds())
+	}()
+
+	log.Printf("========== 收到新消息事件 ==========")
+	log.Printf("[OnP2MessageReceiveV1] 完整事件数据: %s", larkcore.Prettify(event))
+
+	// 记录消息基本信息
+	var messageID, chatID, messageType, chatType, senderID string
+	var contentLen int
+
+	if event.Event.Message.MessageId != nil {
+		messageID = *event.Event.Message.MessageId
+	}
+	if event.Event.Message.ChatId != nil {
+		chatID = *event.Event.Message.ChatId
+	}
+	if event.Event.Message.MessageType != nil {
+		messageType = *event.Event.Message.MessageType
+	}
+	if event.Event.Message.ChatType != nil {
+		chatType = *event.Event.Message.ChatType
+	}
+	if event.Event.Message.Content != nil {
+		contentLen = len(*event.Event.Message.Content)
+	}
+	if event.Event.Sender != nil && event.Event.Sender.SenderId != nil && event.Event.Sender.SenderId.OpenId != nil {
+		senderID = *event.Event.Sender.SenderId.OpenId
+	}
+
+	larkService := app.Lark
+	tenantKey := event.TenantKey()
+	// 回复和命令触发的发送都记在消息发送者名下
+	ctx = audit.WithActor(ctx, audit.Actor{ID: senderID, Via: audit.ViaCommand, TenantKey: tenantKey})
+
+	log.Printf("[消息信息] app=%s, tenant_key=%s, message_id=%s, chat_id=%s, message_type=%s, chat_type=%s, content_length=%d",
+		app.Name, tenantKey, messageID, chatID, messageType, chatType, contentLen)
+
+	// 角色、限流和封禁检查在保存和处理消息之前完成
+	// blocked 角色的消息直接忽略；管理员不受限流；被限流的消息不保存也不回复，只在冷却时间内提示一次
+	role := h.router.RoleOf(ctx, &command.Request{
+		AppID: app.AppID, TenantKey: tenantKey, ChatID: chatID, ChatType: chatType, MessageID: messageID, SenderID: senderID,
+	})
+	if role == command.RoleBlocked {
+		log.Printf("[权限] 忽略 blocked 用户的消息: app=%s, chat_id=%s, sender=%s", app.Name, chatID, senderID)
+		return nil
+	}
+	if h.limiter != nil && role < command.RoleAdmin {
+		decision := h.limiter.Allow(storage.Scope{AppID: app.AppID, TenantKey: tenantKey}, chatID, senderID)
+		if !decision.Allowed {
+			log.Printf("[限流] 消息未处理: app=%s, chat_id=%s, sender=%s, reason=%s, notify=%v",
+				app.Name, chatID, senderID, decision.Reason, decision.Notify)
+			if decision.Notify {
+				h.reply(ctx, larkService, chatType, chatID, messageID, command.TextReply(decision.Message()))
+			}
+			return nil
+		}
+	}
+
+	// 记录最近交互的会话信息（用于 HTTP 接口默认发送）
+	if event.Event.Message.ChatId != nil {
+		chatTypeStr := "group"
+		if *event.Event.Message.ChatType == "p2p" {
+			chatTypeStr = "p2p"
+		}
+		log.Printf("[更新最近会话] chat_id=%s, chat_type=%s", *event.Event.Message.ChatId, chatTypeStr)
+		larkService.UpdateRecentChat(*event.Event.Message.ChatId, chatTypeStr)
+	} else {
+		log.Printf("[警告] ChatId 为 nil，无法更新最近会话")
+	}
+
+	// 保存消息到数据库
+	if event.Event.Message.MessageId != nil && event.Event.Message.ChatId != nil {
+		log.Printf("[开始保存消息] message_id=%s, chat_id=%s", *event.Event.Message.MessageId, *event.Event.Message.ChatId)
+
+		var content string
+		if event.Event.Message.Content != nil {
+			content = *event.Event.Message.Content
+			log.Printf("[消息内容] 长度=%d, 预览=%s", len(content), truncateString(content, 100))
+		} else {
+			log.Printf("[警告] Content 为 nil")
+		}
+
+		var messageTypeStr string
+		if event.Event.Message.MessageType != nil {
+			messageTypeStr = *event.Event.Message.MessageType
+		}
+
+		msg := &storage.Message{
+			AppID:       app.AppID,
+			TenantKey:   tenantKey,
+			ChatID:      *event.Event.Message.ChatId,
+			MessageID:   *event.Event.Message.MessageId,
+			SenderID:    senderID, // 发送者 open_id
+			SenderType:  "user",
+			Content:     content,
+			MessageType: messageTypeStr,
+			CreatedAt:   time.Now(),
+		}
+
+		log.Printf("[准备保存] Message对象: chat_id=%s, message_id=%s, content_len=%d, created_at=%s",
+			msg.ChatID, msg.MessageID, len(msg.Content), msg.CreatedAt.Format(time.RFC3339))
+
+		if err := h.storage.SaveMessage(ctx, msg); err != nil {
+			log.Printf("[错误] 保存消息到数据库失败: chat_id=%s, message_id=%s, error=%v",
+				msg.ChatID, msg.MessageID, err)
+		} else {
+			log.Printf("[成功] 消息已保存到数据库: chat_id=%s, message_id=%s, content_length=%d",
+				msg.ChatID, msg.MessageID, len(msg.Content))
+		}
+	} else {
+		log.Printf("[警告] 消息未保存: message_id=%v, chat_id=%v (其中一个或两个为nil)",
+			event.Event.Message.MessageId, event.Event.Message.ChatId)
+	}
+
+	log.Printf("========== 消息处理完成 ==========")
+
+	/**
+	 * 解析用户发送的消息。
+	 * Parse the message sent by the user.
+	 */
+	var respContent map[string]string
+	err := json.Unmarshal([]byte(*event.Event.Message.Content), &respContent)
+	/**
+	 * 检查消息类型是否为文本
+	 * Check if the message type is text
+	 */
+	if err != nil || *event.Event.Message.MessageType != "text" {
+		respContent = map[string]string{
+			"text": "解析消息失败，请发送文本消息\nparse message failed, please send text message",
+		}
+	}
+
+	/**
+	 * 构建回复消息
+	 * Build reply message
+	 */
+	reply := command.TextReply("收到你发送的消息: " + respContent["text"] + "\n" +
+		"Received message: " + respContent["text"])
+
+	// 以 / 开头的文本消息交给命令路由处理，其他文本消息交给注册的文本处理函数（例如测验的答案）
+	if err == nil && messageType == "text" {
+		req := command.Parse(respContent["text"])
+		isCommand := req != nil
+		if !isCommand {
+			req = command.ParseText(respContent["text"])
+		}
+		req.AppID, req.TenantKey = app.AppID, tenantKey
+		req.ChatID, req.ChatType, req.MessageID, req.SenderID = chatID, chatType, messageID, senderID
+
+		var result *command.Reply
+		var handled bool
+		if isCommand {
+			result, handled = h.router.Dispatch(ctx, req)
+		} else {
+			result, handled = h.router.DispatchText(ctx, req)
+		}
+		if handled {
+			reply = result
+		}
+	}
+
+	// 处理函数返回 nil 表示不回复（例如答题赛中的答案，结果通过更新计分板展示）
+	if reply == nil {
+		return nil
+	}
+	h.reply(ctx, larkService, chatType, chatID, messageID, reply)
+	return nil
+}
+
+// HandleCardAction 处理卡片按钮点击：按按钮 value 中的 action 交给命令路由，回复发送到卡片所在的会话
+func (h *EventHandler) HandleCardAction(ctx context.Context, app *service.App, event *callback.CardActionTriggerEvent) error {
+	start := time.Now()
+	defer func() {
+		metrics.HandlerDuration.WithLabelValues("card.action.trigger").Observe(time.Since(start).Seconds())
+	}()
+
+	if event.Event == nil || event.Event.Action == nil || event.Event.Operator == nil || event.Event.Context == nil {
+		return fmt.Errorf("卡片交互事件缺少 action、operator 或 context")
+	}
+	req := &command.ActionRequest{
+		AppID:     app.AppID,
+		ChatID:    event.Event.Context.OpenChatID,
+		MessageID: event.Event.Context.OpenMessageID,
+		SenderID:  event.Event.Operator.OpenID,
+		Value:     make(map[string]string, len(event.Event.Action.Value)),
+	}
+	if event.Event.Operator.TenantKey != nil {
+		req.TenantKey = *event.Event.Operator.TenantKey
+	} else if event.EventV2Base != nil && event.EventV2Base.Header != nil {
+		req.TenantKey = event.EventV2Base.Header.TenantKey
+	}
+	for k, v := range event.Event.Action.Value {
+		req.Value[k] = fmt.Sprint(v)
+	}
+	req.Name = req.Value[command.ActionKey]
+	ctx = audit.WithActor(ctx, audit.Actor{ID: req.SenderID, Via: audit.ViaCommand, TenantKey: req.TenantKey})
+
+	log.Printf("[卡片交互] app=%s, tenant_key=%s, chat_id=%s, message_id=%s, operator=%s, action=%s",
+		app.Name, req.TenantKey, req.ChatID, req.MessageID, req.SenderID, req.Name)
+
+	// 点击按钮与发送消息共用限流，管理员同样不受限流；blocked 用户的点击由 DispatchAction 拒绝
+	if h.limiter != nil {
+		role := h.router.RoleOf(ctx, &command.Request{
+			AppID: req.AppID, TenantKey: req.TenantKey, ChatID: req.ChatID, MessageID: req.MessageID, SenderID: req.SenderID,
+		})
+		if role != command.RoleBlocked && role < command.RoleAdmin {
+			decision := h.limiter.Allow(storage.Scope{AppID: req.AppID, TenantKey: req.TenantKey}, req.ChatID, req.SenderID)
+			if !decision.Allowed {
+				log.Printf("[限流] 卡片交互未处理: app=%s, chat_id=%s, operator=%s, action=%s, reason=%s, notify=%v",
+					app.Name, req.ChatID, req.SenderID, req.Name, decision.Reason, decision.Notify)
+				if decision.Notify && req.ChatID != "" {
+					return app.Lark.SendTextMessage(ctx, req.ChatID, "chat_id", decision.Message())
+				}
+				return nil
+			}
+		}
+	}
+
+	reply, err := h.router.DispatchAction(ctx, req)
+	if err != nil {
+		return err
+	}
+	if reply == nil || req.ChatID == "" {
+		return nil
+	}
+	if reply.IsText() {
+		return app.Lark.SendTextMessage(ctx, req.ChatID, "chat_id", reply.Content)
+	}
+	return app.Lark.SendMessage(ctx, req.ChatID, "chat_id", reply.MsgType, reply.Content)
+}
+
+// reply 私聊直接发送到会话，群聊回复触发的消息
+func (h *EventHandler) reply(ctx context.Context, larkService *service.LarkService, chatType, chatID, messageID string, reply *command.Reply) {
+	if chatType == "p2p" {
+		/**
+		 * 使用SDK调用发送消息接口。 Use SDK to call send message interface.
+		 * https://open.feishu.cn/document/uAjLw4CM/ukTMukTMukTM/reference/im-v1/message/create
+		 */
+		var err error
+		if reply.IsText() {
+			err = larkService.SendTextMessage(ctx, chatID, "chat_id", reply.Content)
+		} else {
+			err = larkService.SendMessage(ctx, chatID, "chat_id", reply.MsgType, reply.Content)
+		}
+		if err != nil {
+			fmt.Println(err)
+		}
+		return
+	}
+
+	/**
+	 * 使用SDK调用回复消息接口。 Use SDK to call send message interface.
+	 * https://open.feishu.cn/document/server-docs/im-v1/message/reply
+	 */
+	var err error
+	if reply.IsText() {
+		err = larkService.ReplyTextMessage(ctx, messageID, reply.Content)
+	} else {
+		err = larkService.ReplyMessage(ctx, messageID, reply.MsgType, reply.Content)
+	}
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+// truncateString 截断字符串，用于日志输出
+func truncateString(s string, maxLen int) string {
+	if len(s) <= maxLen {
+		return s
+	}
+	return s[:maxLen] + "..."
+}
@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"fin_bot/command"
+	"fin_bot/larktest"
+	"fin_bot/ratelimit"
+	"fin_bot/storage"
+	"fin_bot/worker"
+)
+
+// newTestHandler 创建连接到模拟开放平台的事件处理器和应用
+// SDK 按 App ID 全局缓存 tenant_access_token，每个测试使用不同的 appID
+func newTestHandler(t *testing.T, appID string) (*larktest.Server, *storage.Storage, func(larktest.MessageEvent)) {
+	t.Helper()
+	srv := larktest.NewServer()
+	t.Cleanup(srv.Close)
+
+	store, err := storage.NewStorage(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	h := NewEventHandler(store, command.NewRouter(nil), nil, nil)
+	app := srv.NewApp("test", appID, "tenant_test")
+	d := h.Dispatcher(app, worker.Inline{})
+
+	inject := func(e larktest.MessageEvent) {
+		t.Helper()
+		e.AppID, e.TenantKey = app.AppID, app.TenantKey
+		if err := larktest.Inject(context.Background(), d, e); err != nil {
+			t.Fatalf("Inject: %v", err)
+		}
+	}
+	return srv, store, inject
+}
+
+func TestHandleMessageSavesAndReplies(t *testing.T) {
+	tests := []struct {
+		name      string
+		appID     string
+		chatType  string
+		text      string
+		endpoint  string
+		wantReply string
+	}{
+		{"群聊回复原消息", "cli_handler_group", "group", "什么是久期", larktest.EndpointMessageReply, "收到你发送的消息: 什么是久期"},
+		{"私聊直接发送", "cli_handler_p2p", "p2p", "hello", larktest.EndpointMessageCreate, "收到你发送的消息: hello"},
+		{"命令", "cli_handler_command", "group", "/help", larktest.EndpointMessageReply, "/help"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, store, inject := newTestHandler(t, tt.appID)
+			messageID := "om_in_" + tt.chatType
+			inject(larktest.MessageEvent{MessageID: messageID, ChatID: "oc_study", ChatType: tt.chatType, Text: tt.text})
+
+			scope := storage.Scope{AppID: tt.appID, TenantKey: "tenant_test"}
+			msgs, err := store.GetRecentMessagesByChatID(context.Background(), scope, "oc_study", 10)
+			if err != nil {
+				t.Fatalf("GetRecentMessagesByChatID: %v", err)
+			}
+			if len(msgs) != 1 || msgs[0].MessageID != messageID {
+				t.Fatalf("stored messages = %+v, want one with message_id %s", msgs, messageID)
+			}
+			var content map[string]string
+			if err := json.Unmarshal([]byte(msgs[0].Content), &content); err != nil || content["text"] != tt.text {
+				t.Errorf("stored content = %q, want text %q", msgs[0].Content, tt.text)
+			}
+
+			reqs := srv.Requests(tt.endpoint)
+			if len(reqs) != 1 {
+				t.Fatalf("%s requests = %d, want 1", tt.endpoint, len(reqs))
+			}
+			if got := reqs[0].Header.Get("Authorization"); !strings.HasPrefix(got, "Bearer t-") {
+				t.Errorf("Authorization = %q, want tenant token", got)
+			}
+
+			sent, err := srv.WaitMessages(1, time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(sent[0].Text, tt.wantReply) {
+				t.Errorf("reply text = %q, want it to contain %q", sent[0].Text, tt.wantReply)
+			}
+			if tt.chatType == "group" && sent[0].ReplyTo != messageID {
+				t.Errorf("reply_to = %q, want %q", sent[0].ReplyTo, messageID)
+			}
+			if tt.chatType == "p2p" && (sent[0].ReceiveID != "oc_study" || sent[0].ReceiveIDType != "chat_id") {
+				t.Errorf("receive_id = %s (%s), want oc_study (chat_id)", sent[0].ReceiveID, sent[0].ReceiveIDType)
+			}
+		})
+	}
+}
+
+func TestHandleMessageTokenFailure(t *testing.T) {
+	srv, store, inject := newTestHandler(t, "cli_handler_token_failure")
+	srv.FailNext(larktest.EndpointTenantToken, 10014, "app secret invalid")
+
+	// 获取 token 失败时消息仍然保存，但不会发出回复
+	inject(larktest.MessageEvent{MessageID: "om_token_1", Text: "第一条"})
+	if got := len(srv.Requests(larktest.EndpointTenantToken)); got != 1 {
+		t.Fatalf("token requests = %d, want 1", got)
+	}
+	if got := len(srv.Messages()); got != 0 {
+		t.Fatalf("messages sent after token failure = %d, want 0", got)
+	}
+	scope := storage.Scope{AppID: "cli_handler_token_failure", TenantKey: "tenant_test"}
+	msgs, err := store.GetRecentMessagesByChatID(context.Background(), scope, "oc_test_chat", 10)
+	if err != nil {
+		t.Fatalf("GetRecentMessagesByChatID: %v", err)
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("stored messages = %d, want 1", len(msgs))
+	}
+
+	// 下一条消息重新获取 token 并正常回复
+	inject(larktest.MessageEvent{MessageID: "om_token_2", Text: "第二条"})
+	sent, err := srv.WaitMessages(1, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent[0].ReplyTo != "om_token_2" {
+		t.Errorf("reply_to = %q, want om_token_2", sent[0].ReplyTo)
+	}
+	if got := len(srv.Requests(larktest.EndpointTenantToken)); got != 2 {
+		t.Errorf("token requests = %d, want 2", got)
+	}
+}
+
+// staticRoles 按 open_id 返回固定角色，未列出的用户为普通成员
+type staticRoles map[string]command.Role
+
+func (r staticRoles) RoleOf(ctx context.Context, req *command.Request) command.Role {
+	return r[req.SenderID]
+}
+
+// TestHandleCardActionRateLimit 卡片交互与消息共用限流，管理员不受限流
+func TestHandleCardActionRateLimit(t *testing.T) {
+	tests := []struct {
+		name         string
+		appID        string
+		sender       string
+		wantHandled  int
+		wantNotified int
+	}{
+		{"普通成员超出限流只提示一次", "cli_handler_card_member", "ou_member", 1, 1},
+		{"管理员不受限流", "cli_handler_card_admin", "ou_admin", 3, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := larktest.NewServer()
+			t.Cleanup(srv.Close)
+			store, err := storage.NewStorage(filepath.Join(t.TempDir(), "test.db"))
+			if err != nil {
+				t.Fatalf("NewStorage: %v", err)
+			}
+			t.Cleanup(func() { store.Close() })
+
+			router := command.NewRouter(staticRoles{"ou_admin": command.RoleAdmin})
+			handled := 0
+			router.RegisterAction("test", func(ctx context.Context, req *command.ActionRequest) (*command.Reply, error) {
+				handled++
+				return nil, nil
+			})
+			limiter := ratelimit.New(store, ratelimit.Settings{
+				Enabled:  true,
+				UserRate: 0.001, UserBurst: 1,
+				ChatRate: 100, ChatBurst: 100,
+				GlobalRate: 100, GlobalBurst: 100,
+				ReplyCooldown: time.Hour,
+			})
+			h := NewEventHandler(store, router, nil, limiter)
+			app := srv.NewApp("test", tt.appID, "tenant_test")
+			d := h.Dispatcher(app, worker.Inline{})
+
+			for i := 0; i < 3; i++ {
+				e := larktest.CardActionEvent{
+					AppID: app.AppID, TenantKey: app.TenantKey, SenderID: tt.sender,
+					Value: map[string]string{command.ActionKey: "test"},
+				}
+				if _, err := larktest.InjectCardAction(context.Background(), d, e); err != nil {
+					t.Fatalf("InjectCardAction: %v", err)
+				}
+			}
+
+			if handled != tt.wantHandled {
+				t.Errorf("处理的点击 = %d, want %d", handled, tt.wantHandled)
+			}
+			sent := srv.Messages()
+			if len(sent) != tt.wantNotified {
+				t.Fatalf("发送的提示 = %d, want %d", len(sent), tt.wantNotified)
+			}
+			if tt.wantNotified > 0 && !strings.Contains(sent[0].Text, "太快") {
+				t.Errorf("提示内容 = %q, want 限流提示", sent[0].Text)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"fin_bot/health"
+
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+// HealthHandler 存活/就绪检查处理器
+type HealthHandler struct {
+	checker   *health.Checker
+	startedAt time.Time
+}
+
+// NewHealthHandler 创建新的健康检查处理器
+func NewHealthHandler(checker *health.Checker) *HealthHandler {
+	return &HealthHandler{
+		checker:   checker,
+		startedAt: time.Now(),
+	}
+}
+
+// Livez 存活检查：进程能处理请求即视为存活，不检查外部依赖
+func (h *HealthHandler) Livez(ctx context.Context, c *app.RequestContext) {
+	c.JSON(200, map[string]interface{}{
+		"status":         health.StatusOK,
+		"message":        "服务运行正常",
+		"uptime_seconds": int64(time.Since(h.startedAt).Seconds()),
+	})
+}
+
+// Readyz 就绪检查：检查数据库、WebSocket、飞书凭证和任务队列，任意一项失败返回 503
+func (h *HealthHandler) Readyz(ctx context.Context, c *app.RequestContext) {
+	report := h.checker.Run(ctx)
+	status := 200
+	if report.Status != health.StatusOK {
+		status = 503
+	}
+	c.JSON(status, report)
+}
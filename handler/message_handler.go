@@ -2,31 +2,44 @@ package handler
 
 import (
 	"context"
+	"errors"
+	"strconv"
+
+	"fin_bot/audit"
 	"fin_bot/service"
+	"fin_bot/storage"
 
 	"github.com/cloudwego/hertz/pkg/app"
 )
 
 // MessageHandler 消息处理器
 type MessageHandler struct {
-	larkService *service.LarkService
+	apps    *service.AppRegistry
+	storage *storage.Storage
 }
 
 // NewMessageHandler 创建新的消息处理器
-func NewMessageHandler(larkService *service.LarkService) *MessageHandler {
+func NewMessageHandler(apps *service.AppRegistry, store *storage.Storage) *MessageHandler {
 	return &MessageHandler{
-		larkService: larkService,
+		apps:    apps,
+		storage: store,
 	}
 }
 
 // SendMessage 发送消息的 HTTP 接口
-// 该接口会找到所有已加入的群聊，并向每个群聊发送 "helloworld" 消息
+// 该接口会找到所选应用已加入的所有群聊，并向每个群聊发送 "helloworld" 消息
 func (h *MessageHandler) SendMessage(ctx context.Context, c *app.RequestContext) {
+	selected, ok := resolveApp(h.apps, c)
+	if !ok {
+		return
+	}
+
 	// 固定发送 "helloworld" 消息
 	content := "helloworld"
+	ctx = audit.WithTenant(ctx, selected.TenantKey)
 
 	// 向所有群聊发送消息
-	result, err := h.larkService.SendMessageToAllChats(ctx, content)
+	result, err := selected.Lark.SendMessageToAllChats(ctx, content)
 	if err != nil {
 		c.JSON(500, map[string]interface{}{
 			"code":    500,
@@ -44,4 +57,40 @@ func (h *MessageHandler) SendMessage(ctx context.Context, c *app.RequestContext)
 	})
 }
 
+// Search 按搜索词查找消息（结果已解密）
+// GET /api/messages/search?q=xxx&limit=50
+// 消息内容可能已加密，只能搜索索引范围内的词（命令名、证券代码和配置的关键词）
+func (h *MessageHandler) Search(ctx context.Context, c *app.RequestContext) {
+	scope, ok := resolveScope(h.apps, c)
+	if !ok {
+		return
+	}
+	query := c.Query("q")
+	if query == "" {
+		writeError(c, 400, "缺少搜索词", errors.New("q 参数不能为空"))
+		return
+	}
+	limit := 50
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 500 {
+			writeError(c, 400, "无效的 limit", errors.New("limit 必须是 1-500 之间的整数"))
+			return
+		}
+		limit = n
+	}
 
+	messages, err := h.storage.SearchMessages(ctx, scope, query, limit)
+	if err != nil {
+		if errors.Is(err, storage.ErrTermNotIndexed) {
+			writeError(c, 400, "搜索失败", err)
+			return
+		}
+		writeError(c, 500, "搜索失败", err)
+		return
+	}
+	if messages == nil {
+		messages = []*storage.Message{}
+	}
+	writeOK(c, "ok", messages)
+}
@@ -0,0 +1,228 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"fin_bot/scheduler"
+	"fin_bot/service"
+	"fin_bot/storage"
+
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+// ScheduleHandler 定时任务管理接口
+// 所有接口只能访问所选应用和租户的任务（见 resolveScope）
+type ScheduleHandler struct {
+	scheduler *scheduler.Scheduler
+	apps      *service.AppRegistry
+}
+
+// NewScheduleHandler 创建新的定时任务处理器
+func NewScheduleHandler(s *scheduler.Scheduler, apps *service.AppRegistry) *ScheduleHandler {
+	return &ScheduleHandler{
+		scheduler: s,
+		apps:      apps,
+	}
+}
+
+// createScheduleRequest 创建定时任务的请求体
+type createScheduleRequest struct {
+	Name          string   `json:"name"`
+	CronExpr      string   `json:"cron_expr"`
+	Timezone      string   `json:"timezone"`
+	TargetType    string   `json:"target_type"`
+	Targets       []string `json:"targets"`
+	Content       string   `json:"content"`
+	CatchUpPolicy string   `json:"catch_up_policy"`
+	Enabled       *bool    `json:"enabled"`
+	CreatedBy     string   `json:"created_by"`
+}
+
+// List 获取定时任务列表
+// GET /api/schedules
+func (h *ScheduleHandler) List(ctx context.Context, c *app.RequestContext) {
+	scope, ok := resolveScope(h.apps, c)
+	if !ok {
+		return
+	}
+	schedules, err := h.scheduler.List(ctx, scope)
+	if err != nil {
+		writeError(c, 500, "获取定时任务失败", err)
+		return
+	}
+	writeOK(c, "ok", schedules)
+}
+
+// Get 获取单个定时任务及最近执行记录
+// GET /api/schedules/:id
+func (h *ScheduleHandler) Get(ctx context.Context, c *app.RequestContext) {
+	scope, id, ok := h.scopedID(c)
+	if !ok {
+		return
+	}
+
+	sch, err := h.scheduler.Get(ctx, scope, id)
+	if err != nil {
+		writeScheduleError(c, "获取定时任务失败", err)
+		return
+	}
+	runs, err := h.scheduler.Runs(ctx, scope, id, 20)
+	if err != nil {
+		writeError(c, 500, "获取执行记录失败", err)
+		return
+	}
+	writeOK(c, "ok", map[string]interface{}{
+		"schedule": sch,
+		"runs":     runs,
+	})
+}
+
+// Create 创建定时任务
+// POST /api/schedules
+func (h *ScheduleHandler) Create(ctx context.Context, c *app.RequestContext) {
+	scope, ok := resolveScope(h.apps, c)
+	if !ok {
+		return
+	}
+
+	var req createScheduleRequest
+	if err := json.Unmarshal(c.Request.Body(), &req); err != nil {
+		writeError(c, 400, "请求体不是合法的 JSON", err)
+		return
+	}
+
+	sch := &storage.Schedule{
+		AppID:         scope.AppID,
+		TenantKey:     scope.TenantKey,
+		Name:          req.Name,
+		CronExpr:      req.CronExpr,
+		Timezone:      req.Timezone,
+		TargetType:    req.TargetType,
+		Targets:       req.Targets,
+		Content:       req.Content,
+		CatchUpPolicy: req.CatchUpPolicy,
+		Enabled:       req.Enabled == nil || *req.Enabled,
+		CreatedBy:     req.CreatedBy,
+	}
+	if sch.CreatedBy == "" {
+		sch.CreatedBy = "http"
+	}
+
+	if err := h.scheduler.Create(ctx, sch); err != nil {
+		writeScheduleError(c, "创建定时任务失败", err)
+		return
+	}
+	writeOK(c, "定时任务已创建", sch)
+}
+
+// Delete 删除定时任务
+// DELETE /api/schedules/:id
+func (h *ScheduleHandler) Delete(ctx context.Context, c *app.RequestContext) {
+	scope, id, ok := h.scopedID(c)
+	if !ok {
+		return
+	}
+	if err := h.scheduler.Delete(ctx, scope, id); err != nil {
+		writeScheduleError(c, "删除定时任务失败", err)
+		return
+	}
+	writeOK(c, "定时任务已删除", nil)
+}
+
+// Enable 启用定时任务
+// POST /api/schedules/:id/enable
+func (h *ScheduleHandler) Enable(ctx context.Context, c *app.RequestContext) {
+	h.setEnabled(ctx, c, true)
+}
+
+// Disable 暂停定时任务
+// POST /api/schedules/:id/disable
+func (h *ScheduleHandler) Disable(ctx context.Context, c *app.RequestContext) {
+	h.setEnabled(ctx, c, false)
+}
+
+// Run 立即执行一次定时任务
+// POST /api/schedules/:id/run
+func (h *ScheduleHandler) Run(ctx context.Context, c *app.RequestContext) {
+	scope, id, ok := h.scopedID(c)
+	if !ok {
+		return
+	}
+	run, err := h.scheduler.RunNow(ctx, scope, id)
+	if err != nil {
+		writeScheduleError(c, "执行定时任务失败", err)
+		return
+	}
+	writeOK(c, "定时任务已执行", run)
+}
+
+// setEnabled 启用或暂停定时任务
+func (h *ScheduleHandler) setEnabled(ctx context.Context, c *app.RequestContext, enabled bool) {
+	scope, id, ok := h.scopedID(c)
+	if !ok {
+		return
+	}
+	if err := h.scheduler.SetEnabled(ctx, scope, id, enabled); err != nil {
+		writeScheduleError(c, "更新定时任务状态失败", err)
+		return
+	}
+	sch, err := h.scheduler.Get(ctx, scope, id)
+	if err != nil {
+		writeScheduleError(c, "获取定时任务失败", err)
+		return
+	}
+	writeOK(c, "定时任务状态已更新", sch)
+}
+
+// scopedID 解析数据范围和路径中的任务ID，失败时直接写入错误响应
+func (h *ScheduleHandler) scopedID(c *app.RequestContext) (storage.Scope, int64, bool) {
+	scope, ok := resolveScope(h.apps, c)
+	if !ok {
+		return scope, 0, false
+	}
+	id, ok := scheduleID(c)
+	return scope, id, ok
+}
+
+// scheduleID 解析路径中的任务ID，失败时直接写入 400 响应
+func scheduleID(c *app.RequestContext) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		writeError(c, 400, "无效的任务ID", err)
+		return 0, false
+	}
+	return id, true
+}
+
+// writeScheduleError 根据错误类型返回 400/404/500
+func writeScheduleError(c *app.RequestContext, message string, err error) {
+	switch {
+	case errors.Is(err, scheduler.ErrInvalidSchedule):
+		writeError(c, 400, message, err)
+	case errors.Is(err, storage.ErrNotFound):
+		writeError(c, 404, message, err)
+	default:
+		writeError(c, 500, message, err)
+	}
+}
+
+// writeOK 返回成功响应
+func writeOK(c *app.RequestContext, message string, data interface{}) {
+	c.JSON(200, map[string]interface{}{
+		"code":    200,
+		"message": message,
+		"data":    data,
+	})
+}
+
+// writeError 返回错误响应
+func writeError(c *app.RequestContext, code int, message string, err error) {
+	c.JSON(code, map[string]interface{}{
+		"code":    code,
+		"message": message,
+		"error":   err.Error(),
+	})
+}
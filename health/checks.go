@@ -0,0 +1,67 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"fin_bot/service"
+	"fin_bot/storage"
+	"fin_bot/worker"
+)
+
+// DatabaseCheck 检查数据库连接和可写性
+func DatabaseCheck(s *storage.Storage) CheckFunc {
+	return func(ctx context.Context) (map[string]interface{}, error) {
+		if err := s.Ping(ctx); err != nil {
+			return nil, fmt.Errorf("数据库 ping 失败: %w", err)
+		}
+		if err := s.CheckWritable(ctx); err != nil {
+			return map[string]interface{}{"ping": StatusOK, "writable": false}, err
+		}
+		return map[string]interface{}{"ping": StatusOK, "writable": true}, nil
+	}
+}
+
+// WebSocketCheck 检查 WebSocket 长连接是否已建立
+func WebSocketCheck(m *service.WSMonitor) CheckFunc {
+	return func(ctx context.Context) (map[string]interface{}, error) {
+		status := m.Status()
+		detail := map[string]interface{}{"connected": status.Connected}
+		if !status.LastChange.IsZero() {
+			detail["last_change"] = status.LastChange.Format(time.RFC3339)
+		}
+		if status.LastError != "" {
+			detail["last_error"] = status.LastError
+		}
+		if !status.Connected {
+			return detail, fmt.Errorf("WebSocket 未连接")
+		}
+		return detail, nil
+	}
+}
+
+// LarkTokenCheck 检查应用凭证能否换取 tenant_access_token
+func LarkTokenCheck(ls *service.LarkService) CheckFunc {
+	return func(ctx context.Context) (map[string]interface{}, error) {
+		checkedAt, err := ls.CheckTenantToken(ctx)
+		return map[string]interface{}{"checked_at": checkedAt.Format(time.RFC3339)}, err
+	}
+}
+
+// QueueCheck 检查任务队列是否饱和，积压比例达到 threshold（0~1）即视为不可用
+func QueueCheck(p *worker.Pool, threshold float64) CheckFunc {
+	return func(ctx context.Context) (map[string]interface{}, error) {
+		depth, capacity := p.Len(), p.Cap()
+		usage := float64(depth) / float64(capacity)
+		detail := map[string]interface{}{
+			"depth":    depth,
+			"capacity": capacity,
+			"usage":    usage,
+		}
+		if usage >= threshold {
+			return detail, fmt.Errorf("任务队列积压过多: %d/%d", depth, capacity)
+		}
+		return detail, nil
+	}
+}
@@ -0,0 +1,117 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// 检查状态
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// CheckFunc 单项检查，返回 detail 用于在响应中展示额外信息
+type CheckFunc func(ctx context.Context) (detail map[string]interface{}, err error)
+
+// Result 单项检查结果
+type Result struct {
+	Status     string                 `json:"status"`
+	Error      string                 `json:"error,omitempty"`
+	DurationMs float64                `json:"duration_ms"`
+	Detail     map[string]interface{} `json:"detail,omitempty"`
+}
+
+// Report 整体检查报告
+type Report struct {
+	Status     string            `json:"status"`
+	DurationMs float64           `json:"duration_ms"`
+	Checks     map[string]Result `json:"checks"`
+}
+
+type namedCheck struct {
+	name string
+	fn   CheckFunc
+}
+
+// Checker 就绪检查注册表
+type Checker struct {
+	timeout time.Duration
+	mu      sync.RWMutex
+	checks  []namedCheck
+}
+
+// NewChecker 创建检查器，timeout 为单项检查的超时时间
+func NewChecker(timeout time.Duration) *Checker {
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	return &Checker{timeout: timeout}
+}
+
+// Register 注册一项检查
+func (c *Checker) Register(name string, fn CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, namedCheck{name: name, fn: fn})
+}
+
+// Run 并发执行所有检查，任意一项失败则整体失败
+func (c *Checker) Run(ctx context.Context) *Report {
+	c.mu.RLock()
+	checks := make([]namedCheck, len(c.checks))
+	copy(checks, c.checks)
+	c.mu.RUnlock()
+
+	start := time.Now()
+	report := &Report{
+		Status: StatusOK,
+		Checks: make(map[string]Result, len(checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range checks {
+		wg.Add(1)
+		go func(check namedCheck) {
+			defer wg.Done()
+			result := c.runOne(ctx, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[check.name] = result
+			if result.Status != StatusOK {
+				report.Status = StatusFail
+			}
+		}(check)
+	}
+	wg.Wait()
+
+	report.DurationMs = durationMs(time.Since(start))
+	return report
+}
+
+// runOne 执行单项检查并计时
+func (c *Checker) runOne(ctx context.Context, check namedCheck) Result {
+	checkCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	detail, err := check.fn(checkCtx)
+	result := Result{
+		Status:     StatusOK,
+		DurationMs: durationMs(time.Since(start)),
+		Detail:     detail,
+	}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// durationMs 将耗时转换为毫秒（保留小数）
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
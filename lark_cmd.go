@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"fin_bot/audit"
+	"fin_bot/service"
+)
+
+// larkCommandTimeout 命令行调用开放平台的默认超时时间
+const larkCommandTimeout = 30 * time.Second
+
+// runSendCommand 通过指定应用发送一条文本消息或消息卡片
+func runSendCommand(configPath string, args []string) int {
+	fs := newCLIFlags("send", "-to ID (-text 内容 | -card 卡片文件) [-type chat_id|open_id|user_id|union_id|email] [-app 应用] [-db 数据库]")
+	appName := fs.String("app", "", "发送消息的应用名称或 App ID（只托管一个应用时可省略）")
+	to := fs.String("to", "", "接收者ID（必填）")
+	idType := fs.String("type", "chat_id", "接收者ID类型: chat_id、open_id、user_id、union_id 或 email")
+	text := fs.String("text", "", "文本消息内容")
+	cardFile := fs.String("card", "", "消息卡片 JSON 文件（- 表示从标准输入读取）")
+	dbPath := fs.String("db", "", "写入审计日志的数据库文件路径（默认使用配置中的 database.path）")
+	fs.logFlag()
+	if code := fs.parse(args); code != exitOK {
+		return code
+	}
+	if *to == "" {
+		return fs.usageError("缺少 -to 参数")
+	}
+	if (*text == "") == (*cardFile == "") {
+		return fs.usageError("-text 和 -card 必须且只能指定一个")
+	}
+	switch *idType {
+	case "chat_id", "open_id", "user_id", "union_id", "email":
+	default:
+		return fs.usageError("无效的接收者ID类型: %s", *idType)
+	}
+
+	var card string
+	if *cardFile != "" {
+		data, err := readInput(*cardFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "读取卡片失败: %v\n", err)
+			return exitError
+		}
+		if !json.Valid(data) {
+			fmt.Fprintln(os.Stderr, "卡片内容不是合法的 JSON")
+			return exitError
+		}
+		card = string(data)
+	}
+
+	cfg := loadConfig(configPath, true)
+	if cfg == nil {
+		return exitError
+	}
+	app, err := selectApp(cfg, *appName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+
+	// 发送的消息都要写入审计日志，数据库不可用时不发送
+	store, err := openStorage(cfg, *dbPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "打开数据库失败（发送的消息需要写入审计日志）: %v\n", err)
+		return exitError
+	}
+	defer store.Close()
+	app.Lark.SetAuditLogger(audit.NewLogger(store))
+
+	actor := audit.CLIActor()
+	actor.TenantKey = app.TenantKey
+	ctx, cancel := context.WithTimeout(audit.WithActor(context.Background(), actor), larkCommandTimeout)
+	defer cancel()
+
+	if card != "" {
+		err = app.Lark.SendCardMessage(ctx, *to, *idType, card)
+	} else {
+		err = app.Lark.SendTextMessage(ctx, *to, *idType, *text)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	fmt.Printf("消息已通过应用 %s 发送到 %s\n", app.Name, *to)
+	return exitOK
+}
+
+// runChatsListCommand 列出机器人已加入的群聊
+func runChatsListCommand(configPath string, args []string) int {
+	fs := newCLIFlags("chats list", "[-app 应用] [-format text|json]")
+	appName := fs.String("app", "", "应用名称或 App ID（只托管一个应用时可省略）")
+	format := fs.String("format", "text", "输出格式: text（每行一个 chat_id）或 json")
+	fs.logFlag()
+	if code := fs.parse(args); code != exitOK {
+		return code
+	}
+	if *format != "text" && *format != "json" {
+		return fs.usageError("无效的输出格式: %s", *format)
+	}
+
+	app, code := commandApp(configPath, *appName)
+	if code != exitOK {
+		return code
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), larkCommandTimeout)
+	defer cancel()
+
+	chatIDs, err := app.Lark.GetChatList(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	if *format == "json" {
+		if chatIDs == nil {
+			chatIDs = []string{}
+		}
+		if err := json.NewEncoder(os.Stdout).Encode(chatIDs); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+		return exitOK
+	}
+	for _, chatID := range chatIDs {
+		fmt.Println(chatID)
+	}
+	fmt.Fprintf(os.Stderr, "应用 %s 共加入 %d 个群聊\n", app.Name, len(chatIDs))
+	return exitOK
+}
+
+// commandApp 加载配置并选择调用开放平台使用的应用，失败时输出错误并返回非零退出码
+func commandApp(configPath, name string) (*service.App, int) {
+	cfg := loadConfig(configPath, true)
+	if cfg == nil {
+		return nil, exitError
+	}
+	app, err := selectApp(cfg, name)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return nil, exitUsage
+	}
+	return app, exitOK
+}
+
+// readInput 读取文件内容，path 为 - 时读取标准输入
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
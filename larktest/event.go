@@ -0,0 +1,169 @@
+package larktest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/larksuite/oapi-sdk-go/v3/event/dispatcher"
+)
+
+// eventSeq 生成唯一的事件和消息ID
+var eventSeq atomic.Int64
+
+// MessageEvent 构造 im.message.receive_v1 事件的参数，未填写的字段使用默认值
+type MessageEvent struct {
+	AppID       string
+	TenantKey   string
+	EventID     string // 默认自动生成
+	MessageID   string // 默认自动生成
+	ChatID      string // 默认 oc_test_chat
+	ChatType    string // p2p 或 group，默认 group
+	SenderID    string // 发送者 open_id，默认 ou_test_user
+	MessageType string // 默认 text
+	Text        string // 文本消息内容，MessageType 为 text 时使用
+	Content     string // 原始 content JSON，非空时忽略 Text
+	CreateTime  time.Time
+}
+
+// Payload 生成与长连接推送格式一致的事件 JSON
+func (e MessageEvent) Payload() ([]byte, error) {
+	seq := eventSeq.Add(1)
+	if e.EventID == "" {
+		e.EventID = fmt.Sprintf("ev_test_%d", seq)
+	}
+	if e.MessageID == "" {
+		e.MessageID = fmt.Sprintf("om_test_%d", seq)
+	}
+	if e.ChatID == "" {
+		e.ChatID = "oc_test_chat"
+	}
+	if e.ChatType == "" {
+		e.ChatType = "group"
+	}
+	if e.SenderID == "" {
+		e.SenderID = "ou_test_user"
+	}
+	if e.MessageType == "" {
+		e.MessageType = "text"
+	}
+	if e.CreateTime.IsZero() {
+		e.CreateTime = time.Now()
+	}
+	content := e.Content
+	if content == "" {
+		raw, err := json.Marshal(map[string]string{"text": e.Text})
+		if err != nil {
+			return nil, err
+		}
+		content = string(raw)
+	}
+	createTime := strconv.FormatInt(e.CreateTime.UnixMilli(), 10)
+
+	return json.Marshal(map[string]interface{}{
+		"schema": "2.0",
+		"header": map[string]interface{}{
+			"event_id":    e.EventID,
+			"event_type":  "im.message.receive_v1",
+			"create_time": createTime,
+			"app_id":      e.AppID,
+			"tenant_key":  e.TenantKey,
+		},
+		"event": map[string]interface{}{
+			"sender": map[string]interface{}{
+				"sender_id":   map[string]string{"open_id": e.SenderID},
+				"sender_type": "user",
+				"tenant_key":  e.TenantKey,
+			},
+			"message": map[string]interface{}{
+				"message_id":   e.MessageID,
+				"chat_id":      e.ChatID,
+				"chat_type":    e.ChatType,
+				"message_type": e.MessageType,
+				"content":      content,
+				"create_time":  createTime,
+			},
+		},
+	})
+}
+
+// Inject 将事件注入分发器，与长连接收到推送后的处理路径相同
+func Inject(ctx context.Context, d *dispatcher.EventDispatcher, e MessageEvent) error {
+	payload, err := e.Payload()
+	if err != nil {
+		return fmt.Errorf("生成事件失败: %w", err)
+	}
+	if _, err := d.Do(ctx, payload); err != nil {
+		return fmt.Errorf("分发事件失败: %w", err)
+	}
+	return nil
+}
+
+// CardActionEvent 构造 card.action.trigger 回调（点击卡片按钮）的参数，未填写的字段使用默认值
+type CardActionEvent struct {
+	AppID     string
+	TenantKey string
+	EventID   string // 默认自动生成
+	MessageID string // 卡片消息ID，默认自动生成
+	ChatID    string // 默认 oc_test_chat
+	SenderID  string // 点击者 open_id，默认 ou_test_user
+	Value     map[string]string
+}
+
+// Payload 生成与长连接推送格式一致的回调 JSON
+func (e CardActionEvent) Payload() ([]byte, error) {
+	seq := eventSeq.Add(1)
+	if e.EventID == "" {
+		e.EventID = fmt.Sprintf("ev_test_%d", seq)
+	}
+	if e.MessageID == "" {
+		e.MessageID = fmt.Sprintf("om_test_%d", seq)
+	}
+	if e.ChatID == "" {
+		e.ChatID = "oc_test_chat"
+	}
+	if e.SenderID == "" {
+		e.SenderID = "ou_test_user"
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"schema": "2.0",
+		"header": map[string]interface{}{
+			"event_id":    e.EventID,
+			"event_type":  "card.action.trigger",
+			"create_time": strconv.FormatInt(time.Now().UnixMilli(), 10),
+			"app_id":      e.AppID,
+			"tenant_key":  e.TenantKey,
+		},
+		"event": map[string]interface{}{
+			"operator": map[string]interface{}{
+				"open_id":    e.SenderID,
+				"tenant_key": e.TenantKey,
+			},
+			"action": map[string]interface{}{
+				"tag":   "button",
+				"value": e.Value,
+			},
+			"context": map[string]string{
+				"open_message_id": e.MessageID,
+				"open_chat_id":    e.ChatID,
+			},
+		},
+	})
+}
+
+// InjectCardAction 将卡片回调注入分发器，返回回调的响应（toast 等）
+func InjectCardAction(ctx context.Context, d *dispatcher.EventDispatcher, e CardActionEvent) (interface{}, error) {
+	payload, err := e.Payload()
+	if err != nil {
+		return nil, fmt.Errorf("生成回调失败: %w", err)
+	}
+	resp, err := d.Do(ctx, payload)
+	if err != nil {
+		return nil, fmt.Errorf("分发回调失败: %w", err)
+	}
+	return resp, nil
+}
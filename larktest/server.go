@@ -0,0 +1,408 @@
+package larktest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fin_bot/service"
+
+	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
+)
+
+// 模拟的开放平台接口（与 metrics 中的 endpoint 标签一致）
+const (
+	EndpointTenantToken   = "auth.tenant_access_token"
+	EndpointMessageCreate = "im.message.create"
+	EndpointMessageReply  = "im.message.reply"
+	EndpointMessagePatch  = "im.message.patch"
+	EndpointChatList      = "im.chat.list"
+	EndpointChatGet       = "im.chat.get"
+)
+
+// 飞书开放平台的错误码
+const (
+	CodeInvalidToken = 99991663 // tenant_access_token 无效
+	CodeInvalidParam = 99992402 // 参数错误
+)
+
+// Request 模拟服务收到的一次请求
+type Request struct {
+	Endpoint string
+	Method   string
+	Path     string
+	Query    url.Values
+	Header   http.Header
+	Body     []byte
+	Time     time.Time
+}
+
+// Message 机器人通过模拟服务发送或回复的一条消息
+type Message struct {
+	MessageID     string
+	ReceiveID     string // 发送消息时的接收者
+	ReceiveIDType string
+	ReplyTo       string // 回复消息时被回复的消息ID
+	MsgType       string
+	Content       string // 原始 content JSON，卡片被更新后为最新内容
+	Text          string // 文本消息的内容
+	Patches       int    // 卡片被更新的次数
+}
+
+// Chat 机器人所在的群聊
+type Chat struct {
+	ChatID  string `json:"chat_id"`
+	Name    string `json:"name"`
+	OwnerID string `json:"owner_id,omitempty"` // 群主 open_id
+}
+
+// failure 预设的接口错误
+type failure struct {
+	code int
+	msg  string
+}
+
+// Server 进程内的飞书开放平台模拟服务，覆盖机器人用到的 IM 接口和 tenant_access_token 接口，
+// 记录收到的所有请求，用于在没有网络的情况下验证消息处理的完整流程
+type Server struct {
+	srv *httptest.Server
+
+	mu       sync.Mutex
+	requests []Request
+	messages []Message
+	chats    []Chat
+	failures map[string][]failure
+	nextID   int
+	notify   chan struct{}
+}
+
+// NewServer 启动模拟服务，使用完毕后需要调用 Close
+func NewServer() *Server {
+	s := &Server{
+		failures: make(map[string][]failure),
+		notify:   make(chan struct{}),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /open-apis/auth/v3/tenant_access_token/internal", s.record(EndpointTenantToken, s.handleTenantToken))
+	mux.HandleFunc("POST /open-apis/im/v1/messages", s.record(EndpointMessageCreate, s.authorized(s.handleMessageCreate)))
+	mux.HandleFunc("POST /open-apis/im/v1/messages/{message_id}/reply", s.record(EndpointMessageReply, s.authorized(s.handleMessageReply)))
+	mux.HandleFunc("PATCH /open-apis/im/v1/messages/{message_id}", s.record(EndpointMessagePatch, s.authorized(s.handleMessagePatch)))
+	mux.HandleFunc("GET /open-apis/im/v1/chats", s.record(EndpointChatList, s.authorized(s.handleChatList)))
+	mux.HandleFunc("GET /open-apis/im/v1/chats/{chat_id}", s.record(EndpointChatGet, s.authorized(s.handleChatGet)))
+	s.srv = httptest.NewServer(mux)
+	return s
+}
+
+// URL 模拟服务的地址，可作为 lark.base_url 使用
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// Close 关闭模拟服务
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// NewApp 创建连接到模拟服务的应用（不包含长连接）
+// SDK 按 App ID 全局缓存 tenant_access_token，需要断言 token 请求时请使用不重复的 App ID
+func (s *Server) NewApp(name, appID, tenantKey string) *service.App {
+	return &service.App{
+		Name:      name,
+		AppID:     appID,
+		TenantKey: tenantKey,
+		Lark:      service.NewLarkService(appID, "secret_"+appID, s.URL()),
+		Monitor:   service.NewWSMonitor(name, larkcore.LogLevelError),
+	}
+}
+
+// AddChats 设置机器人所在的群聊，用于群聊列表接口
+func (s *Server) AddChats(chats ...Chat) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chats = append(s.chats, chats...)
+}
+
+// FailNext 让指定接口的下一次调用返回业务错误码，可多次调用依次生效
+func (s *Server) FailNext(endpoint string, code int, msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[endpoint] = append(s.failures[endpoint], failure{code: code, msg: msg})
+}
+
+// Requests 获取收到的请求，endpoint 为空时返回全部
+func (s *Server) Requests(endpoint string) []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []Request
+	for _, req := range s.requests {
+		if endpoint == "" || req.Endpoint == endpoint {
+			out = append(out, req)
+		}
+	}
+	return out
+}
+
+// Messages 获取机器人发送和回复的所有消息（按时间顺序）
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// WaitMessages 等待机器人至少发出 n 条消息，超时返回已收到的消息和错误
+// 消息事件是异步处理的，注入事件后用它等待回复
+func (s *Server) WaitMessages(n int, timeout time.Duration) ([]Message, error) {
+	deadline := time.After(timeout)
+	for {
+		s.mu.Lock()
+		messages := append([]Message(nil), s.messages...)
+		notify := s.notify
+		s.mu.Unlock()
+
+		if len(messages) >= n {
+			return messages, nil
+		}
+		select {
+		case <-notify:
+		case <-deadline:
+			return messages, fmt.Errorf("等待 %d 条消息超时，实际收到 %d 条", n, len(messages))
+		}
+	}
+}
+
+// Reset 清空请求记录、消息和预设错误（保留群聊）
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+	s.messages = nil
+	s.failures = make(map[string][]failure)
+}
+
+// record 记录请求并处理预设错误
+func (s *Server) record(endpoint string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(strings.NewReader(string(body)))
+
+		s.mu.Lock()
+		s.requests = append(s.requests, Request{
+			Endpoint: endpoint,
+			Method:   r.Method,
+			Path:     r.URL.Path,
+			Query:    r.URL.Query(),
+			Header:   r.Header.Clone(),
+			Body:     body,
+			Time:     time.Now(),
+		})
+		var fail *failure
+		if queue := s.failures[endpoint]; len(queue) > 0 {
+			fail = &queue[0]
+			s.failures[endpoint] = queue[1:]
+		}
+		s.mu.Unlock()
+
+		if fail != nil {
+			writeJSON(w, map[string]interface{}{"code": fail.code, "msg": fail.msg})
+			return
+		}
+		next(w, r)
+	}
+}
+
+// authorized 校验请求携带了 tenant_access_token
+func (s *Server) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer t-") {
+			writeJSON(w, map[string]interface{}{"code": CodeInvalidToken, "msg": "Invalid access token for authorization."})
+			return
+		}
+		next(w, r)
+	}
+}
+
+// handleTenantToken 模拟 POST /open-apis/auth/v3/tenant_access_token/internal
+func (s *Server) handleTenantToken(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		AppID     string `json:"app_id"`
+		AppSecret string `json:"app_secret"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.AppID == "" || body.AppSecret == "" {
+		writeJSON(w, map[string]interface{}{"code": 10003, "msg": "invalid param"})
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"code":                0,
+		"msg":                 "ok",
+		"tenant_access_token": "t-" + body.AppID,
+		"expire":              7200,
+	})
+}
+
+// handleMessageCreate 模拟 POST /open-apis/im/v1/messages
+func (s *Server) handleMessageCreate(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		ReceiveID string `json:"receive_id"`
+		MsgType   string `json:"msg_type"`
+		Content   string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.ReceiveID == "" {
+		writeJSON(w, map[string]interface{}{"code": CodeInvalidParam, "msg": "invalid receive_id"})
+		return
+	}
+	msg := s.addMessage(Message{
+		ReceiveID:     body.ReceiveID,
+		ReceiveIDType: r.URL.Query().Get("receive_id_type"),
+		MsgType:       body.MsgType,
+		Content:       body.Content,
+	})
+	writeJSON(w, map[string]interface{}{"code": 0, "msg": "success", "data": messageData(msg)})
+}
+
+// handleMessageReply 模拟 POST /open-apis/im/v1/messages/:message_id/reply
+func (s *Server) handleMessageReply(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		MsgType string `json:"msg_type"`
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, map[string]interface{}{"code": CodeInvalidParam, "msg": "invalid body"})
+		return
+	}
+	msg := s.addMessage(Message{
+		ReplyTo: r.PathValue("message_id"),
+		MsgType: body.MsgType,
+		Content: body.Content,
+	})
+	writeJSON(w, map[string]interface{}{"code": 0, "msg": "success", "data": messageData(msg)})
+}
+
+// handleMessagePatch 模拟 PATCH /open-apis/im/v1/messages/:message_id，只能更新卡片消息
+func (s *Server) handleMessagePatch(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Content == "" {
+		writeJSON(w, map[string]interface{}{"code": CodeInvalidParam, "msg": "invalid content"})
+		return
+	}
+
+	messageID := r.PathValue("message_id")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.messages {
+		if s.messages[i].MessageID != messageID {
+			continue
+		}
+		if s.messages[i].MsgType != "interactive" {
+			writeJSON(w, map[string]interface{}{"code": CodeInvalidParam, "msg": "message is not a card"})
+			return
+		}
+		s.messages[i].Content = body.Content
+		s.messages[i].Patches++
+		writeJSON(w, map[string]interface{}{"code": 0, "msg": "success"})
+		return
+	}
+	writeJSON(w, map[string]interface{}{"code": CodeInvalidParam, "msg": "message not found"})
+}
+
+// handleChatList 模拟 GET /open-apis/im/v1/chats，page_token 为下一页的起始下标
+func (s *Server) handleChatList(w http.ResponseWriter, r *http.Request) {
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	offset, _ := strconv.Atoi(r.URL.Query().Get("page_token"))
+
+	s.mu.Lock()
+	chats := s.chats
+	s.mu.Unlock()
+
+	if offset > len(chats) {
+		offset = len(chats)
+	}
+	end := offset + pageSize
+	if end > len(chats) {
+		end = len(chats)
+	}
+
+	data := map[string]interface{}{
+		"items":    chats[offset:end],
+		"has_more": end < len(chats),
+	}
+	if end < len(chats) {
+		data["page_token"] = strconv.Itoa(end)
+	}
+	writeJSON(w, map[string]interface{}{"code": 0, "msg": "success", "data": data})
+}
+
+// handleChatGet 模拟 GET /open-apis/im/v1/chats/:chat_id
+func (s *Server) handleChatGet(w http.ResponseWriter, r *http.Request) {
+	chatID := r.PathValue("chat_id")
+
+	s.mu.Lock()
+	var found *Chat
+	for i := range s.chats {
+		if s.chats[i].ChatID == chatID {
+			found = &s.chats[i]
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	if found == nil {
+		writeJSON(w, map[string]interface{}{"code": CodeInvalidParam, "msg": "chat not found"})
+		return
+	}
+	writeJSON(w, map[string]interface{}{"code": 0, "msg": "success", "data": map[string]interface{}{
+		"name":          found.Name,
+		"owner_id":      found.OwnerID,
+		"owner_id_type": "open_id",
+	}})
+}
+
+// addMessage 保存一条发出的消息并通知等待者
+func (s *Server) addMessage(msg Message) Message {
+	if msg.MsgType == "text" {
+		var content struct {
+			Text string `json:"text"`
+		}
+		// SDK 的 TextMsgBuilder 不转义换行，开放平台可以接受，这里同样兼容
+		raw := strings.NewReplacer("\n", `\n`, "\t", `\t`).Replace(msg.Content)
+		if err := json.Unmarshal([]byte(raw), &content); err == nil {
+			msg.Text = content.Text
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	msg.MessageID = fmt.Sprintf("om_fake_%d", s.nextID)
+	s.messages = append(s.messages, msg)
+	close(s.notify)
+	s.notify = make(chan struct{})
+	return msg
+}
+
+// messageData 消息接口返回的 data 字段
+func messageData(msg Message) map[string]interface{} {
+	return map[string]interface{}{
+		"message_id":  msg.MessageID,
+		"msg_type":    msg.MsgType,
+		"create_time": strconv.FormatInt(time.Now().UnixMilli(), 10),
+		"body":        map[string]string{"content": msg.Content},
+	}
+}
+
+// writeJSON 写入 JSON 响应
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(v)
+}
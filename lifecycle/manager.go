@@ -0,0 +1,92 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Hook 一个受生命周期管理的组件
+// OnStart 应尽快返回，长期运行的逻辑放到 goroutine 中；OnStop 需要在 ctx 截止前完成清理
+type Hook struct {
+	Name    string
+	OnStart func(ctx context.Context) error
+	OnStop  func(ctx context.Context) error
+}
+
+// Manager 按注册顺序启动组件，按相反顺序停止组件
+type Manager struct {
+	hooks       []Hook
+	started     []Hook
+	stopTimeout time.Duration
+}
+
+// NewManager 创建生命周期管理器，stopTimeout 为停止所有组件的总时限
+func NewManager(stopTimeout time.Duration) *Manager {
+	if stopTimeout <= 0 {
+		stopTimeout = 10 * time.Second
+	}
+	return &Manager{stopTimeout: stopTimeout}
+}
+
+// Append 注册组件，启动顺序与注册顺序一致
+func (m *Manager) Append(hook Hook) {
+	m.hooks = append(m.hooks, hook)
+}
+
+// Start 依次启动所有组件
+// 任一组件启动失败时，已启动的组件会被逆序停止，并返回该错误
+func (m *Manager) Start(ctx context.Context) error {
+	for _, hook := range m.hooks {
+		if hook.OnStart != nil {
+			log.Printf("[lifecycle] 启动组件: %s", hook.Name)
+			if err := hook.OnStart(ctx); err != nil {
+				startErr := fmt.Errorf("启动组件 %s 失败: %w", hook.Name, err)
+				stopCtx, cancel := context.WithTimeout(context.Background(), m.stopTimeout)
+				defer cancel()
+				return errors.Join(startErr, m.Stop(stopCtx))
+			}
+		}
+		m.started = append(m.started, hook)
+	}
+	log.Printf("[lifecycle] 所有组件已启动: %d 个", len(m.started))
+	return nil
+}
+
+// Stop 逆序停止已启动的组件，所有组件共用 ctx 的截止时间
+// 某个组件停止失败不会影响后续组件的停止，所有错误会合并返回
+func (m *Manager) Stop(ctx context.Context) error {
+	var errs []error
+	for i := len(m.started) - 1; i >= 0; i-- {
+		hook := m.started[i]
+		if hook.OnStop == nil {
+			continue
+		}
+		log.Printf("[lifecycle] 停止组件: %s", hook.Name)
+		start := time.Now()
+		if err := hook.OnStop(ctx); err != nil {
+			log.Printf("[lifecycle] 停止组件 %s 出错: %v", hook.Name, err)
+			errs = append(errs, fmt.Errorf("停止组件 %s 失败: %w", hook.Name, err))
+		} else {
+			log.Printf("[lifecycle] 组件已停止: %s (耗时 %s)", hook.Name, time.Since(start).Round(time.Millisecond))
+		}
+	}
+	m.started = nil
+	return errors.Join(errs...)
+}
+
+// Run 启动所有组件，阻塞直到 ctx 被取消，然后在 stopTimeout 内停止所有组件
+func (m *Manager) Run(ctx context.Context) error {
+	if err := m.Start(ctx); err != nil {
+		return err
+	}
+
+	<-ctx.Done()
+	log.Printf("[lifecycle] 开始关闭，时限 %s", m.stopTimeout)
+
+	stopCtx, cancel := context.WithTimeout(context.Background(), m.stopTimeout)
+	defer cancel()
+	return m.Stop(stopCtx)
+}
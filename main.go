@@ -2,51 +2,317 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"fin_bot/alert"
+	"fin_bot/audit"
+	"fin_bot/backup"
+	"fin_bot/command"
 	"fin_bot/config"
+	"fin_bot/course"
+	"fin_bot/eventlog"
+	"fin_bot/flashcard"
+	"fin_bot/glossary"
 	"fin_bot/handler"
+	"fin_bot/health"
+	"fin_bot/lifecycle"
+	"fin_bot/marketdata"
+	"fin_bot/metrics"
+	"fin_bot/progress"
+	"fin_bot/quiz"
+	"fin_bot/quote"
+	"fin_bot/ratelimit"
+	"fin_bot/rbac"
+	"fin_bot/scheduler"
+	"fin_bot/secure"
 	"fin_bot/service"
 	"fin_bot/storage"
+	"fin_bot/watchlist"
+	"fin_bot/worker"
 
-	"github.com/cloudwego/hertz/pkg/app"
 	"github.com/cloudwego/hertz/pkg/app/server"
-	lark "github.com/larksuite/oapi-sdk-go/v3"
+	"github.com/cloudwego/hertz/pkg/common/adaptor"
 	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
-	"github.com/larksuite/oapi-sdk-go/v3/event/dispatcher"
-	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
-	larkws "github.com/larksuite/oapi-sdk-go/v3/ws"
 )
 
 func main() {
-	// 加载配置（从 .env 文件或系统环境变量）
-	cfg := config.Load()
+	configPath := flag.String("config", "", "配置文件路径（默认尝试 "+config.DefaultConfigFile+"）")
+	flag.Usage = usage
+	flag.Parse()
 
-	// 检查必要的配置
-	if cfg.AppID == "" || cfg.AppSecret == "" {
-		log.Fatal("错误: APP_ID 和 APP_SECRET 必须设置（请在 .env 文件中配置）")
+	os.Exit(runCLI(*configPath, flag.Args()))
+}
+
+// runServeCommand 处理 serve 子命令
+func runServeCommand(configPath string, args []string) int {
+	fs := newCLIFlags("serve", "")
+	if code := fs.parse(args); code != exitOK {
+		return code
 	}
 
+	// 加载配置（配置文件 + .env 文件或系统环境变量），校验失败时列出所有问题后退出
+	cfg := loadConfig(configPath, true)
+	if cfg == nil {
+		return exitError
+	}
+
+	serve(cfg, configPath)
+	return exitOK
+}
+
+// serve 启动机器人服务，阻塞直到收到退出信号
+// configPath 用于热加载，为空时监听默认配置文件
+func serve(cfg *config.Config, configPath string) {
 	fmt.Printf("正在启动飞书机器人服务...\n")
-	fmt.Printf("App ID: %s\n", maskString(cfg.AppID))
 
 	// 初始化数据库
-	dbStorage, err := storage.NewStorage(cfg.DatabasePath)
+	dbStorage, err := storage.NewStorage(cfg.Database.Path)
 	if err != nil {
 		log.Fatalf("初始化数据库失败: %v", err)
 	}
-	defer dbStorage.Close()
-	fmt.Printf("数据库已初始化: %s\n", cfg.DatabasePath)
+	fmt.Printf("数据库已初始化: %s\n", cfg.Database.Path)
+
+	// 消息内容加密和搜索索引
+	keyring, err := configureStorage(context.Background(), cfg, dbStorage)
+	if err != nil {
+		log.Fatalf("初始化加密失败: %v", err)
+	}
+	if cfg.Encryption.Enabled {
+		fmt.Printf("消息内容加密已开启: key_id=%s\n", cfg.Encryption.PrimaryKeyID)
+	}
+
+	// 审计日志：发送消息、管理命令、管理接口的修改操作、配置重载和数据导出
+	auditLog := audit.NewLogger(dbStorage)
+
+	// 每个飞书应用使用独立的 LarkService 和长连接，消息事件共用同一个处理队列
+	apps := service.NewAppRegistry()
+	eventPool := worker.NewPool("events", cfg.Events.Workers, cfg.Events.QueueSize)
+
+	// 定时消息调度器和聊天命令
+	sched := scheduler.New(dbStorage, apps, cfg.Scheduler.Interval, cfg.Scheduler.DefaultTimezone)
+	sched.SetAuditLogger(auditLog)
+	sched.SetRetention(cfg.Retention.MessagesPerChat, cfg.Retention.MessageMaxAge)
+	// 角色：配置文件中的所有者和管理员、数据库中分配的角色以及群主
+	roles := rbac.New(dbStorage, apps)
+	roles.SetBootstrap(cfg.Admin.Owners, cfg.Admin.Users, cfg.Admin.ChatOwnerAdmin)
+	if err := roles.Load(context.Background()); err != nil {
+		log.Fatalf("加载角色分配失败: %v", err)
+	}
+	router := command.NewRouter(roles)
+	router.SetAuditLogger(auditLog)
+	router.Register(sched.Command())
+	for _, cmd := range roles.Commands() {
+		router.Register(cmd)
+	}
+
+	// 学习进度：课程、测验和记忆卡片记录学习事件，由学习事件计算经验值、连续学习天数、徽章和排行榜
+	tracker := progress.New(dbStorage, progressSettings(cfg.Progress))
+	for _, cmd := range tracker.Commands() {
+		router.Register(cmd)
+	}
+
+	// 课程（由 courses.dir 中的 Markdown 文件导入）
+	courses := course.New(dbStorage)
+	courses.SetProgressTracker(tracker)
+	importCourses(context.Background(), courses, cfg.Courses)
+	for _, cmd := range courses.Commands() {
+		router.Register(cmd)
+	}
+
+	// 测验（由 quizzes.dir 中的 JSON 和 CSV 文件导入），答案可以通过命令、直接回复或卡片按钮提交
+	quizzes := quiz.New(dbStorage)
+	quizzes.SetProgressTracker(tracker)
+	importQuizzes(context.Background(), quizzes, cfg.Quizzes)
+	for _, cmd := range quizzes.Commands() {
+		router.Register(cmd)
+	}
+	// 群聊答题赛：直接回复的答案先交给进行中的答题赛，不是答题赛的答案再交给个人测验
+	arena := quiz.NewArena(quizzes, apps, battleSettings(cfg.Quizzes))
+	for _, cmd := range arena.Commands() {
+		router.Register(cmd)
+	}
+	router.RegisterText(arena.HandleText)
+	router.RegisterAction(quiz.ActionBattle, arena.HandleAction)
+	router.RegisterText(quizzes.HandleText)
+	router.RegisterAction(quiz.ActionAnswer, quizzes.HandleAction)
+
+	// 记忆卡片（由 flashcards.dir 中的 CSV 和 Anki TSV 文件导入），通过卡片按钮评分，每天提醒有到期卡片的用户
+	flashcards := flashcard.New(dbStorage, apps, flashcardSettings(cfg.Flashcards))
+	flashcards.SetProgressTracker(tracker)
+	importFlashcards(context.Background(), flashcards, cfg.Flashcards)
+	for _, cmd := range flashcards.Commands() {
+		router.Register(cmd)
+	}
+	router.RegisterAction(flashcard.ActionShow, flashcards.HandleAction)
+	router.RegisterAction(flashcard.ActionGrade, flashcards.HandleAction)
+
+	// 金融术语表（由 glossary.dir 中的 CSV 文件导入），通过 /define 或直接提问查询，每天向订阅的会话发送一个术语
+	// 提问的识别放在测验之后，避免把答案当作提问
+	terms := glossary.New(dbStorage, apps, glossarySettings(cfg.Glossary))
+	importGlossary(context.Background(), terms, cfg.Glossary)
+	for _, cmd := range terms.Commands() {
+		router.Register(cmd)
+	}
+	router.RegisterText(terms.HandleText)
+	router.RegisterAction(glossary.ActionDefine, terms.HandleAction)
+
+	// 行情数据：本地行情（由 marketdata.dir 中的 CSV 文件导入）或 HTTP 行情接口，查询结果按类型缓存
+	provider, err := newMarketProvider(dbStorage, cfg.MarketData)
+	if err != nil {
+		log.Fatalf("创建行情来源失败: %v", err)
+	}
+	market := marketdata.NewCache(provider, marketCacheSettings(cfg.MarketData))
+	importMarketData(context.Background(), dbStorage, market, cfg.MarketData)
+
+	// 证券报价（/quote），找不到证券时列出相近的证券
+	quotes := quote.New(market, quoteSettings(cfg.Quote))
+	for _, cmd := range quotes.Commands() {
+		router.Register(cmd)
+	}
+	router.RegisterAction(quote.ActionQuote, quotes.HandleAction)
+
+	// 自选列表（单聊中属于用户，群聊中属于整个群），早盘和收盘时向自选列表不为空的会话发送价格汇总
+	watchlists := watchlist.New(dbStorage, apps, market, quotes, watchlistSettings(cfg.Watchlist))
+	for _, cmd := range watchlists.Commands() {
+		router.Register(cmd)
+	}
 
-	// 初始化 LarkService（在启动时初始化，供 HTTP 接口和 WebSocket 使用）
-	larkService := service.NewLarkService(cfg.AppID, cfg.AppSecret)
-	client := larkService.GetClient()
+	// 价格和指标提醒（/alert），后台按间隔检查，条件满足时私聊通知创建者
+	alerts := alert.New(dbStorage, apps, market, quotes, alertSettings(cfg.Alerts))
+	for _, cmd := range alerts.Commands() {
+		router.Register(cmd)
+	}
+
+	// 消息限流和封禁名单（状态保存在数据库中，重启后恢复）
+	limiter := ratelimit.New(dbStorage, rateLimitSettings(cfg.RateLimit))
+	for _, cmd := range limiter.Commands() {
+		router.Register(cmd)
+	}
+
+	// 数据库备份（定时备份由 backup.schedule 控制）
+	backups := newBackupManager(cfg, dbStorage)
+
+	// 事件录制（可选），用于通过 replay 子命令复现问题
+	var recorder *eventlog.Recorder
+	if cfg.Recorder.Enabled {
+		recorder, err = eventlog.NewRecorder(cfg.Recorder.Path, cfg.Recorder.RedactContent)
+		if err != nil {
+			log.Fatalf("初始化事件录制失败: %v", err)
+		}
+		fmt.Printf("事件录制已开启: %s\n", cfg.Recorder.Path)
+	}
+
+	eventHandler := handler.NewEventHandler(dbStorage, router, recorder, limiter)
+	for _, appCfg := range cfg.Lark.AllApps() {
+		app := &service.App{
+			Name:      appCfg.Name,
+			AppID:     appCfg.AppID,
+			TenantKey: appCfg.TenantKey,
+			Lark:      service.NewLarkService(appCfg.AppID, appCfg.AppSecret, cfg.Lark.BaseURL),
+			Monitor:   service.NewWSMonitor(appCfg.Name, larkcore.LogLevelDebug),
+		}
+		app.Lark.SetAuditLogger(auditLog)
+		app.WS = service.NewWSClient(appCfg.AppID, appCfg.AppSecret, cfg.Lark.BaseURL, eventHandler.Dispatcher(app, eventPool), app.Monitor)
+		if err := apps.Add(app); err != nil {
+			log.Fatalf("注册飞书应用失败: %v", err)
+		}
+		fmt.Printf("飞书应用: %s (App ID: %s)\n", app.Name, config.MaskString(app.AppID))
+	}
+
+	// 引入多应用之前保存的数据归属到第一个应用
+	if defaultApp, err := apps.Default(); err == nil {
+		scope := storage.Scope{AppID: defaultApp.AppID, TenantKey: defaultApp.TenantKey}
+		if err := dbStorage.ClaimUnscoped(context.Background(), scope); err != nil {
+			log.Fatalf("归属历史数据失败: %v", err)
+		}
+	}
+
+	// HTTP 管理接口认证
+	auth := handler.NewAPIKeyAuth(cfg.Security.APIKey)
+	if cfg.Security.APIKey == "" {
+		log.Printf("[警告] 未配置 security.api_key，HTTP 管理接口无需认证即可调用")
+	}
+
+	// 配置热加载：各组件订阅自己关心的配置项
+	watcher := config.NewWatcher(configPath, cfg)
+	watcher.Subscribe("audit", func(old, new *config.Config) {
+		auditLog.Record(context.Background(), storage.Scope{}, audit.ActionConfigReload, "config",
+			"changed="+strings.Join(config.Diff(old, new), ","))
+	})
+	watcher.Subscribe("rbac", func(old, new *config.Config) {
+		roles.SetBootstrap(new.Admin.Owners, new.Admin.Users, new.Admin.ChatOwnerAdmin)
+	})
+	watcher.Subscribe("http_auth", func(old, new *config.Config) {
+		auth.SetKey(new.Security.APIKey)
+	})
+	watcher.Subscribe("scheduler", func(old, new *config.Config) {
+		sched.SetInterval(new.Scheduler.Interval)
+		sched.SetDefaultTimezone(new.Scheduler.DefaultTimezone)
+		sched.SetRetention(new.Retention.MessagesPerChat, new.Retention.MessageMaxAge)
+	})
+	watcher.Subscribe("courses", func(old, new *config.Config) {
+		if old.Courses != new.Courses {
+			importCourses(context.Background(), courses, new.Courses)
+		}
+	})
+	watcher.Subscribe("quizzes", func(old, new *config.Config) {
+		arena.SetSettings(battleSettings(new.Quizzes))
+		if old.Quizzes.Dir != new.Quizzes.Dir || old.Quizzes.ImportOnLoad != new.Quizzes.ImportOnLoad {
+			importQuizzes(context.Background(), quizzes, new.Quizzes)
+		}
+	})
+	watcher.Subscribe("flashcards", func(old, new *config.Config) {
+		flashcards.SetSettings(flashcardSettings(new.Flashcards))
+		if old.Flashcards.Dir != new.Flashcards.Dir || old.Flashcards.ImportOnLoad != new.Flashcards.ImportOnLoad {
+			importFlashcards(context.Background(), flashcards, new.Flashcards)
+		}
+	})
+	watcher.Subscribe("glossary", func(old, new *config.Config) {
+		terms.SetSettings(glossarySettings(new.Glossary))
+		if old.Glossary.Dir != new.Glossary.Dir || old.Glossary.ImportOnLoad != new.Glossary.ImportOnLoad {
+			importGlossary(context.Background(), terms, new.Glossary)
+		}
+	})
+	watcher.Subscribe("marketdata", func(old, new *config.Config) {
+		market.SetSettings(marketCacheSettings(new.MarketData))
+		if old.MarketData.Dir != new.MarketData.Dir || old.MarketData.ImportOnLoad != new.MarketData.ImportOnLoad {
+			importMarketData(context.Background(), dbStorage, market, new.MarketData)
+		}
+	})
+	watcher.Subscribe("quote", func(old, new *config.Config) {
+		quotes.SetSettings(quoteSettings(new.Quote))
+	})
+	watcher.Subscribe("watchlist", func(old, new *config.Config) {
+		watchlists.SetSettings(watchlistSettings(new.Watchlist))
+	})
+	watcher.Subscribe("alerts", func(old, new *config.Config) {
+		alerts.SetSettings(alertSettings(new.Alerts))
+	})
+	watcher.Subscribe("progress", func(old, new *config.Config) {
+		tracker.SetSettings(progressSettings(new.Progress))
+	})
+	watcher.Subscribe("ratelimit", func(old, new *config.Config) {
+		limiter.SetSettings(rateLimitSettings(new.RateLimit))
+	})
+	watcher.Subscribe("backup", func(old, new *config.Config) {
+		backups.SetSettings(backupSettings(new.Backup))
+	})
+
+	// 就绪检查项（长连接和凭证按应用分别检查）
+	checker := health.NewChecker(3 * time.Second)
+	checker.Register("database", health.DatabaseCheck(dbStorage))
+	for _, app := range apps.All() {
+		checker.Register("websocket:"+app.Name, health.WebSocketCheck(app.Monitor))
+		checker.Register("lark_token:"+app.Name, health.LarkTokenCheck(app.Lark))
+	}
+	checker.Register("event_queue", health.QueueCheck(eventPool, cfg.Events.QueueSaturation))
 
 	// 创建可取消的 context，用于优雅关闭
 	ctx, cancel := context.WithCancel(context.Background())
@@ -58,255 +324,198 @@ func main() {
 	go func() {
 		<-sigChan
 		log.Println("\n收到退出信号，开始优雅关闭...")
-		cancel() // 取消 context，通知所有 goroutine 退出
+		cancel() // 取消 context，通知所有组件退出
 	}()
 
-	// 在后台 goroutine 启动 WebSocket 连接（用于接收用户消息）
-	go startWebSocketConnection(ctx, cfg.AppID, cfg.AppSecret, client, larkService, dbStorage)
-
-	// 启动 HTTP 服务（在主 goroutine 中运行，使用 context 控制）
-	startHTTPServer(ctx, cfg, larkService, dbStorage)
-
-	log.Println("程序已退出")
-}
-
-// startWebSocketConnection 启动 WebSocket 连接用于接收用户消息
-func startWebSocketConnection(ctx context.Context, appID, appSecret string, client *lark.Client, larkService *service.LarkService, dbStorage *storage.Storage) {
-	/**
-	 * 注册事件处理器。
-	 * Register event handler.
-	 */
-	eventHandler := dispatcher.NewEventDispatcher("", "").
-		/**
-		 * 注册接收消息事件，处理接收到的消息。
-		 * Register event handler to handle received messages.
-		 * https://open.feishu.cn/document/uAjLw4CM/ukTMukTMukTM/reference/im-v1/message/events/receive
-		 */
-		OnP2MessageReceiveV1(func(ctx context.Context, event *larkim.P2MessageReceiveV1) error {
-			log.Printf("========== 收到新消息事件 ==========")
-			log.Printf("[OnP2MessageReceiveV1] 完整事件数据: %s", larkcore.Prettify(event))
-			
-			// 记录消息基本信息
-			var messageID, chatID, messageType, chatType string
-			var contentLen int
-			
-			if event.Event.Message.MessageId != nil {
-				messageID = *event.Event.Message.MessageId
-			}
-			if event.Event.Message.ChatId != nil {
-				chatID = *event.Event.Message.ChatId
-			}
-			if event.Event.Message.MessageType != nil {
-				messageType = *event.Event.Message.MessageType
-			}
-			if event.Event.Message.ChatType != nil {
-				chatType = *event.Event.Message.ChatType
-			}
-			if event.Event.Message.Content != nil {
-				contentLen = len(*event.Event.Message.Content)
-			}
-			
-			log.Printf("[消息信息] message_id=%s, chat_id=%s, message_type=%s, chat_type=%s, content_length=%d",
-				messageID, chatID, messageType, chatType, contentLen)
-			
-			// 记录最近交互的会话信息（用于 HTTP 接口默认发送）
-			if event.Event.Message.ChatId != nil {
-				chatTypeStr := "group"
-				if *event.Event.Message.ChatType == "p2p" {
-					chatTypeStr = "p2p"
-				}
-				log.Printf("[更新最近会话] chat_id=%s, chat_type=%s", *event.Event.Message.ChatId, chatTypeStr)
-				larkService.UpdateRecentChat(*event.Event.Message.ChatId, chatTypeStr)
-			} else {
-				log.Printf("[警告] ChatId 为 nil，无法更新最近会话")
-			}
-			
-			// 保存消息到数据库
-			if event.Event.Message.MessageId != nil && event.Event.Message.ChatId != nil {
-				log.Printf("[开始保存消息] message_id=%s, chat_id=%s", *event.Event.Message.MessageId, *event.Event.Message.ChatId)
-				
-				var content string
-				if event.Event.Message.Content != nil {
-					content = *event.Event.Message.Content
-					log.Printf("[消息内容] 长度=%d, 预览=%s", len(content), truncateString(content, 100))
-				} else {
-					log.Printf("[警告] Content 为 nil")
-				}
-				
-				var messageTypeStr string
-				if event.Event.Message.MessageType != nil {
-					messageTypeStr = *event.Event.Message.MessageType
-				}
-				
-				msg := &storage.Message{
-					ChatID:      *event.Event.Message.ChatId,
-					MessageID:   *event.Event.Message.MessageId,
-					SenderID:    "", // EventMessage 中没有直接的发送者信息
-					SenderType:  "user",
-					Content:     content,
-					MessageType: messageTypeStr,
-					CreatedAt:   time.Now(),
-				}
-				
-				log.Printf("[准备保存] Message对象: chat_id=%s, message_id=%s, content_len=%d, created_at=%s",
-					msg.ChatID, msg.MessageID, len(msg.Content), msg.CreatedAt.Format(time.RFC3339))
-				
-				if err := dbStorage.SaveMessage(ctx, msg); err != nil {
-					log.Printf("[错误] 保存消息到数据库失败: chat_id=%s, message_id=%s, error=%v", 
-						msg.ChatID, msg.MessageID, err)
-				} else {
-					log.Printf("[成功] 消息已保存到数据库: chat_id=%s, message_id=%s, content_length=%d", 
-						msg.ChatID, msg.MessageID, len(msg.Content))
-				}
-			} else {
-				log.Printf("[警告] 消息未保存: message_id=%v, chat_id=%v (其中一个或两个为nil)",
-					event.Event.Message.MessageId, event.Event.Message.ChatId)
-			}
-			
-			log.Printf("========== 消息处理完成 ==========")
-			
-			/**
-			 * 解析用户发送的消息。
-			 * Parse the message sent by the user.
-			 */
-			var respContent map[string]string
-			err := json.Unmarshal([]byte(*event.Event.Message.Content), &respContent)
-			/**
-			 * 检查消息类型是否为文本
-			 * Check if the message type is text
-			 */
-			if err != nil || *event.Event.Message.MessageType != "text" {
-				respContent = map[string]string{
-					"text": "解析消息失败，请发送文本消息\nparse message failed, please send text message",
-				}
-			}
-
-			/**
-			 * 构建回复消息
-			 * Build reply message
-			 */
-			content := larkim.NewTextMsgBuilder().
-				TextLine("收到你发送的消息: " + respContent["text"]).
-				TextLine("Received message: " + respContent["text"]).
-				Build()
-
-			if *event.Event.Message.ChatType == "p2p" {
-				/**
-				 * 使用SDK调用发送消息接口。 Use SDK to call send message interface.
-				 * https://open.feishu.cn/document/uAjLw4CM/ukTMukTMukTM/reference/im-v1/message/create
-				 */
-				resp, err := client.Im.Message.Create(context.Background(), larkim.NewCreateMessageReqBuilder().
-					ReceiveIdType(larkim.ReceiveIdTypeChatId). // 消息接收者的 ID 类型，设置为会话ID。 ID type of the message receiver, set to chat ID.
-					Body(larkim.NewCreateMessageReqBodyBuilder().
-						MsgType(larkim.MsgTypeText).            // 设置消息类型为文本消息。 Set message type to text message.
-						ReceiveId(*event.Event.Message.ChatId). // 消息接收者的 ID 为消息发送的会话ID。 ID of the message receiver is the chat ID of the message sending.
-						Content(content).
-						Build()).
-					Build())
-
-				if err != nil || !resp.Success() {
-					fmt.Println(err)
-					fmt.Println(resp.Code, resp.Msg, resp.RequestId())
-					return nil
-				}
-
-			} else {
-				/**
-				 * 使用SDK调用回复消息接口。 Use SDK to call send message interface.
-				 * https://open.feishu.cn/document/server-docs/im-v1/message/reply
-				 */
-				resp, err := client.Im.Message.Reply(context.Background(), larkim.NewReplyMessageReqBuilder().
-					MessageId(*event.Event.Message.MessageId).
-					Body(larkim.NewReplyMessageReqBodyBuilder().
-						MsgType(larkim.MsgTypeText). // 设置消息类型为文本消息。 Set message type to text message.
-						Content(content).
-						Build()).
-					Build())
-				if err != nil || !resp.Success() {
-					fmt.Printf("logId: %s, error response: \n%s", resp.RequestId(), larkcore.Prettify(resp.CodeError))
-					return nil
+	// 按顺序启动组件，退出时逆序停止：HTTP -> 配置监听 -> 定时备份 -> 重新加密 -> 复习提醒 -> 调度器 -> 长连接 -> worker -> 飞书客户端 -> 限流状态 -> 数据库
+	h := newHTTPServer(cfg, dbStorage, apps, checker, sched, backups, auth, auditLog)
+	manager := lifecycle.NewManager(cfg.Server.ShutdownTimeout)
+	manager.Append(lifecycle.Hook{
+		Name:    "storage",
+		OnStart: dbStorage.CheckWritable,
+		OnStop: func(ctx context.Context) error {
+			return dbStorage.Close()
+		},
+	})
+	if recorder != nil {
+		// 在长连接和 worker 停止之后才关闭录制文件
+		manager.Append(lifecycle.Hook{
+			Name: "recorder",
+			OnStop: func(ctx context.Context) error {
+				return recorder.Close()
+			},
+		})
+	}
+	manager.Append(lifecycle.Hook{
+		Name:   "quiz_battles",
+		OnStop: arena.Shutdown,
+	})
+	manager.Append(lifecycle.Hook{
+		Name:    "ratelimit",
+		OnStart: limiter.Start,
+		OnStop:  limiter.Stop,
+	})
+	manager.Append(lifecycle.Hook{
+		Name: "lark_client",
+		OnStart: func(ctx context.Context) error {
+			// 凭证校验失败不阻止启动，由就绪检查持续暴露
+			for _, app := range apps.All() {
+				if _, err := app.Lark.CheckTenantToken(ctx); err != nil {
+					log.Printf("[警告] 飞书应用 %s 凭证校验失败: %v", app.Name, err)
 				}
 			}
-
 			return nil
+		},
+	})
+	manager.Append(lifecycle.Hook{
+		Name: "workers",
+		OnStart: func(ctx context.Context) error {
+			eventPool.Start(ctx)
+			return nil
+		},
+		OnStop: eventPool.Stop,
+	})
+	// 长连接在 worker 之后启动、之前停止，停止过程中 worker 仍能处理已收到的事件
+	for _, app := range apps.All() {
+		manager.Append(lifecycle.Hook{
+			Name:    "event_ingestion:" + app.Name,
+			OnStart: app.WS.Start,
+			OnStop:  app.WS.Stop,
+		})
+	}
+	manager.Append(lifecycle.Hook{
+		Name:    "scheduler",
+		OnStart: sched.Start,
+		OnStop:  sched.Stop,
+	})
+	manager.Append(lifecycle.Hook{
+		Name:    "flashcard_push",
+		OnStart: flashcards.Start,
+		OnStop:  flashcards.Stop,
+	})
+	manager.Append(lifecycle.Hook{
+		Name:    "glossary_daily",
+		OnStart: terms.Start,
+		OnStop:  terms.Stop,
+	})
+	manager.Append(lifecycle.Hook{
+		Name:    "watchlist_summary",
+		OnStart: watchlists.Start,
+		OnStop:  watchlists.Stop,
+	})
+	manager.Append(lifecycle.Hook{
+		Name:    "alerts",
+		OnStart: alerts.Start,
+		OnStop:  alerts.Stop,
+	})
+	if keyring != nil {
+		// 把存量数据逐步转换为当前的加密设置（轮换密钥、开启或关闭加密之后）
+		reencrypt := secure.NewReencryptJob(dbStorage, cfg.Encryption.ReencryptInterval, cfg.Encryption.ReencryptBatch)
+		manager.Append(lifecycle.Hook{
+			Name:    "reencrypt",
+			OnStart: reencrypt.Start,
+			OnStop:  reencrypt.Stop,
 		})
+	}
+	manager.Append(lifecycle.Hook{
+		Name:    "backup",
+		OnStart: backups.Start,
+		OnStop:  backups.Stop,
+	})
+	manager.Append(lifecycle.Hook{
+		Name:    "config_watcher",
+		OnStart: watcher.Start,
+		OnStop:  watcher.Stop,
+	})
+	manager.Append(lifecycle.Hook{
+		Name: "http",
+		OnStart: func(ctx context.Context) error {
+			go func() {
+				if err := h.Run(); err != nil {
+					log.Printf("HTTP 服务器异常退出: %v", err)
+					cancel()
+				}
+			}()
+			return nil
+		},
+		OnStop: h.Shutdown,
+	})
 
-	/**
-	 * 启动长连接，并注册事件处理器。
-	 * Start long connection and register event handler.
-	 */
-	cli := larkws.NewClient(appID, appSecret,
-		larkws.WithEventHandler(eventHandler),
-		larkws.WithLogLevel(larkcore.LogLevelDebug),
-	)
-
-	fmt.Println("WebSocket 连接已启动，等待接收用户消息...")
-	err := cli.Start(context.Background())
-	if err != nil {
-		log.Fatalf("WebSocket 启动失败: %v", err)
+	if err := manager.Run(ctx); err != nil {
+		log.Printf("程序退出时出错: %v", err)
 	}
+
+	log.Println("程序已退出")
 }
 
-// startHTTPServer 启动 HTTP 服务
-func startHTTPServer(ctx context.Context, cfg *config.Config, larkService *service.LarkService, dbStorage *storage.Storage) {
-	// 创建 Hertz 服务器
-	port := ":" + cfg.Port
+// newHTTPServer 创建 HTTP 服务并注册路由，由生命周期管理器负责启动和关闭
+// /api/* 接口需要通过 auth 认证，修改数据的请求（包括认证失败的）写入审计日志；指标和健康检查接口不需要
+func newHTTPServer(cfg *config.Config, dbStorage *storage.Storage, apps *service.AppRegistry, checker *health.Checker, sched *scheduler.Scheduler, backups *backup.Manager, auth *handler.APIKeyAuth, auditLog *audit.Logger) *server.Hertz {
+	// 创建 Hertz 服务器（不使用 Spin，信号由 main 统一处理）
+	port := ":" + cfg.Server.Port
 	h := server.Default(server.WithHostPorts(port))
 
 	// 创建消息处理器
-	messageHandler := handler.NewMessageHandler(larkService)
+	messageHandler := handler.NewMessageHandler(apps, dbStorage)
 
 	// 注册路由
-	h.GET("/api/send-message", messageHandler.SendMessage)
+	api := h.Group("/api", handler.AuditMiddleware(auditLog), auth.Middleware())
+	api.GET("/send-message", messageHandler.SendMessage)
+	api.GET("/messages/search", messageHandler.Search)
 
-	// 健康检查接口
-	h.GET("/health", func(ctx context.Context, c *app.RequestContext) {
-		c.JSON(200, map[string]interface{}{
-			"status":  "ok",
-			"message": "服务运行正常",
-		})
-	})
+	// 定时任务管理接口
+	scheduleHandler := handler.NewScheduleHandler(sched, apps)
+	api.GET("/schedules", scheduleHandler.List)
+	api.POST("/schedules", scheduleHandler.Create)
+	api.GET("/schedules/:id", scheduleHandler.Get)
+	api.DELETE("/schedules/:id", scheduleHandler.Delete)
+	api.POST("/schedules/:id/enable", scheduleHandler.Enable)
+	api.POST("/schedules/:id/disable", scheduleHandler.Disable)
+	api.POST("/schedules/:id/run", scheduleHandler.Run)
 
-	fmt.Printf("HTTP 服务已启动，监听端口: %s\n", cfg.Port)
-	fmt.Printf("发送消息接口: GET http://localhost:%s/api/send-message?receive_id=xxx&content=xxx\n", cfg.Port)
-	fmt.Printf("健康检查接口: GET http://localhost:%s/health\n", cfg.Port)
+	// 数据库备份接口
+	backupHandler := handler.NewBackupHandler(backups)
+	api.GET("/backups", backupHandler.List)
+	api.POST("/backups", backupHandler.Create)
+	api.POST("/backups/:name/verify", backupHandler.Verify)
 
-	// 在 goroutine 中启动服务器
-	serverDone := make(chan struct{})
-	go func() {
-		h.Spin()
-		close(serverDone)
-	}()
+	// 审计日志查询接口
+	auditHandler := handler.NewAuditHandler(apps, dbStorage)
+	api.GET("/audit", auditHandler.List)
+	api.GET("/audit/verify", auditHandler.Verify)
 
-	// 等待 context 取消或服务器退出
-	select {
-	case <-ctx.Done():
-		log.Println("收到关闭信号，正在关闭 HTTP 服务器...")
-		// 优雅关闭 HTTP 服务器
-		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer shutdownCancel()
-		if err := h.Shutdown(shutdownCtx); err != nil {
-			log.Printf("关闭 HTTP 服务器时出错: %v", err)
-		} else {
-			log.Println("HTTP 服务器已关闭")
-		}
-	case <-serverDone:
-		log.Println("HTTP 服务器已退出")
-	}
-}
+	// Prometheus 指标接口
+	h.GET("/metrics", adaptor.HertzHandler(metrics.Handler()))
 
-// maskString 隐藏字符串的大部分内容，只显示前4位和后4位
-func maskString(s string) string {
-	if len(s) <= 8 {
-		return "****"
-	}
-	return s[:4] + "..." + s[len(s)-4:]
+	// 健康检查接口
+	healthHandler := handler.NewHealthHandler(checker)
+	h.GET("/health", healthHandler.Livez)
+	h.GET("/livez", healthHandler.Livez)
+	h.GET("/readyz", healthHandler.Readyz)
+
+	fmt.Printf("HTTP 服务已启动，监听端口: %s\n", cfg.Server.Port)
+	fmt.Printf("发送消息接口: GET http://localhost:%s/api/send-message?receive_id=xxx&content=xxx\n", cfg.Server.Port)
+	fmt.Printf("定时任务接口: GET/POST http://localhost:%s/api/schedules\n", cfg.Server.Port)
+	fmt.Printf("备份接口: GET/POST http://localhost:%s/api/backups\n", cfg.Server.Port)
+	fmt.Printf("审计日志接口: GET http://localhost:%s/api/audit\n", cfg.Server.Port)
+	fmt.Printf("托管多个应用时通过 %s 请求头或 app 参数选择应用，%s 请求头或 tenant_key 参数选择租户\n", handler.HeaderAppID, handler.HeaderTenantKey)
+	fmt.Printf("存活检查接口: GET http://localhost:%s/livez\n", cfg.Server.Port)
+	fmt.Printf("就绪检查接口: GET http://localhost:%s/readyz\n", cfg.Server.Port)
+	fmt.Printf("监控指标接口: GET http://localhost:%s/metrics\n", cfg.Server.Port)
+
+	return h
 }
 
-// truncateString 截断字符串，用于日志输出
-func truncateString(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
+// rateLimitSettings 将配置转换为限流参数
+func rateLimitSettings(c config.RateLimitConfig) ratelimit.Settings {
+	return ratelimit.Settings{
+		Enabled:       c.Enabled,
+		UserRate:      c.UserRate,
+		UserBurst:     c.UserBurst,
+		ChatRate:      c.ChatRate,
+		ChatBurst:     c.ChatBurst,
+		GlobalRate:    c.GlobalRate,
+		GlobalBurst:   c.GlobalBurst,
+		ReplyCooldown: c.ReplyCooldown,
 	}
-	return s[:maxLen] + "..."
 }
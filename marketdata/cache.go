@@ -0,0 +1,145 @@
+package marketdata
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fin_bot/metrics"
+)
+
+// maxCacheEntries 缓存的最大条目数，超过时先清理过期的条目，仍然超过时清空缓存
+const maxCacheEntries = 10000
+
+// CacheSettings 各类行情的缓存时间，为 0 表示不缓存
+type CacheSettings struct {
+	QuoteTTL   time.Duration
+	HistoryTTL time.Duration
+	SearchTTL  time.Duration
+}
+
+// Cache 为行情来源增加按时间过期的缓存，本身也是一个 Provider
+// 证券不存在（ErrNotFound）和没有行情（ErrNoData）的结果同样会被缓存，其他错误不缓存
+type Cache struct {
+	provider Provider
+	now      func() time.Time
+
+	mu       sync.Mutex // 保护 settings 和 entries
+	settings CacheSettings
+	entries  map[string]*cacheEntry
+}
+
+// cacheEntry 一条缓存的结果
+type cacheEntry struct {
+	value   interface{}
+	err     error
+	expires time.Time
+}
+
+// NewCache 创建行情缓存
+func NewCache(p Provider, settings CacheSettings) *Cache {
+	return &Cache{provider: p, now: time.Now, settings: settings, entries: make(map[string]*cacheEntry)}
+}
+
+// SetSettings 修改缓存时间并清空缓存
+func (c *Cache) SetSettings(settings CacheSettings) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.settings = settings
+	clear(c.entries)
+}
+
+// Purge 清空缓存，例如重新导入本地行情之后
+func (c *Cache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.entries)
+}
+
+// Name 被缓存的行情来源的名称
+func (c *Cache) Name() string {
+	return c.provider.Name()
+}
+
+// Quote 获取证券的最新报价，缓存 QuoteTTL
+func (c *Cache) Quote(ctx context.Context, sym Symbol) (*Quote, error) {
+	v, err := c.get("quote", sym.String(), c.ttl().QuoteTTL, func() (interface{}, error) {
+		return c.provider.Quote(ctx, sym)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*Quote), nil
+}
+
+// History 获取证券在 [from, to] 日期范围内的日线，缓存 HistoryTTL
+func (c *Cache) History(ctx context.Context, sym Symbol, from, to time.Time) ([]*Bar, error) {
+	key := sym.String() + "|" + from.Format(DateLayout) + "|" + to.Format(DateLayout)
+	v, err := c.get("history", key, c.ttl().HistoryTTL, func() (interface{}, error) {
+		return c.provider.History(ctx, sym, from, to)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]*Bar), nil
+}
+
+// Search 搜索证券，缓存 SearchTTL（查询不区分大小写）
+func (c *Cache) Search(ctx context.Context, query string, limit int) ([]*Instrument, error) {
+	key := strings.ToLower(strings.TrimSpace(query)) + "|" + strconv.Itoa(limit)
+	v, err := c.get("search", key, c.ttl().SearchTTL, func() (interface{}, error) {
+		return c.provider.Search(ctx, query, limit)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]*Instrument), nil
+}
+
+// ttl 当前的缓存时间
+func (c *Cache) ttl() CacheSettings {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.settings
+}
+
+// get 从缓存中获取结果，没有或已过期时调用 fetch 并缓存结果
+func (c *Cache) get(operation, key string, ttl time.Duration, fetch func() (interface{}, error)) (interface{}, error) {
+	if ttl <= 0 {
+		return fetch()
+	}
+	key = operation + ":" + key
+
+	now := c.now()
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && now.Before(e.expires) {
+		metrics.MarketDataCache.WithLabelValues(operation, "hit").Inc()
+		return e.value, e.err
+	}
+	metrics.MarketDataCache.WithLabelValues(operation, "miss").Inc()
+
+	v, err := fetch()
+	if err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrNoData) {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxCacheEntries {
+		for k, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxCacheEntries {
+			clear(c.entries)
+		}
+	}
+	c.entries[key] = &cacheEntry{value: v, err: err, expires: now.Add(ttl)}
+	return v, err
+}
@@ -0,0 +1,116 @@
+package marketdata
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// countingProvider 记录调用次数的行情来源，Quote 返回 err 或价格为调用次数的报价
+type countingProvider struct {
+	calls int
+	err   error
+}
+
+func (p *countingProvider) Name() string { return "counting" }
+
+func (p *countingProvider) Quote(ctx context.Context, sym Symbol) (*Quote, error) {
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &Quote{Symbol: sym, Price: float64(p.calls)}, nil
+}
+
+func (p *countingProvider) History(ctx context.Context, sym Symbol, from, to time.Time) ([]*Bar, error) {
+	p.calls++
+	return nil, p.err
+}
+
+func (p *countingProvider) Search(ctx context.Context, query string, limit int) ([]*Instrument, error) {
+	p.calls++
+	return nil, p.err
+}
+
+func TestCacheExpiry(t *testing.T) {
+	const ttl = time.Minute
+	tests := []struct {
+		name      string
+		err       error
+		elapsed   time.Duration // 第二次查询距第一次的时间
+		wantCalls int
+	}{
+		{"有效期内命中", nil, ttl - time.Nanosecond, 1},
+		{"到期后重新获取", nil, ttl, 2},
+		{"证券不存在同样缓存", ErrNotFound, ttl / 2, 1},
+		{"没有行情同样缓存", ErrNoData, ttl / 2, 1},
+		{"不存在的结果到期后重新获取", ErrNotFound, ttl + time.Second, 2},
+		{"其他错误不缓存", errors.New("超时"), 0, 2},
+	}
+	sym := Symbol{Code: "600519", Market: MarketSH}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &countingProvider{err: tt.err}
+			c := NewCache(p, CacheSettings{QuoteTTL: ttl})
+			now := time.Date(2026, 10, 19, 9, 30, 0, 0, time.UTC)
+			c.now = func() time.Time { return now }
+
+			first, err1 := c.Quote(context.Background(), sym)
+			now = now.Add(tt.elapsed)
+			second, err2 := c.Quote(context.Background(), sym)
+
+			if p.calls != tt.wantCalls {
+				t.Errorf("provider calls = %d, want %d", p.calls, tt.wantCalls)
+			}
+			if !errors.Is(err1, tt.err) || !errors.Is(err2, tt.err) {
+				t.Errorf("errors = %v, %v, want %v", err1, err2, tt.err)
+			}
+			if tt.err == nil && tt.wantCalls == 1 && first != second {
+				t.Error("命中缓存时应返回同一个报价")
+			}
+		})
+	}
+}
+
+func TestCacheSettings(t *testing.T) {
+	sym := Symbol{Code: "AAPL", Market: MarketUS}
+	p := &countingProvider{}
+	c := NewCache(p, CacheSettings{})
+	now := time.Date(2026, 10, 19, 9, 30, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	// 缓存时间为 0 时不缓存
+	c.Quote(context.Background(), sym)
+	c.Quote(context.Background(), sym)
+	if p.calls != 2 {
+		t.Fatalf("provider calls = %d, want 2", p.calls)
+	}
+
+	c.SetSettings(CacheSettings{QuoteTTL: time.Minute, SearchTTL: time.Minute})
+	c.Quote(context.Background(), sym)
+	c.Quote(context.Background(), sym)
+	if p.calls != 3 {
+		t.Fatalf("provider calls = %d, want 3", p.calls)
+	}
+
+	// 搜索不区分大小写和首尾空格，不同的 limit 分别缓存
+	c.Search(context.Background(), "Apple", 5)
+	c.Search(context.Background(), " apple ", 5)
+	c.Search(context.Background(), "apple", 10)
+	if p.calls != 5 {
+		t.Fatalf("provider calls = %d, want 5", p.calls)
+	}
+
+	// 修改设置后清空缓存
+	c.SetSettings(CacheSettings{QuoteTTL: time.Hour})
+	c.Quote(context.Background(), sym)
+	if p.calls != 6 {
+		t.Errorf("provider calls = %d, want 6", p.calls)
+	}
+	c.Purge()
+	c.Quote(context.Background(), sym)
+	if p.calls != 7 {
+		t.Errorf("provider calls after Purge = %d, want 7", p.calls)
+	}
+}
@@ -0,0 +1,163 @@
+package marketdata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"fin_bot/metrics"
+)
+
+// HTTP 行情接口的约定（NewHandler 提供同样的接口，可以作为本地的模拟服务）：
+//
+//	GET /quote?symbol=600519.SH                          → Quote
+//	GET /history?symbol=600519.SH&from=2024-01-02&to=…   → {"bars": [Bar…]}
+//	GET /search?q=茅台&limit=10                          → {"instruments": [Instrument…]}
+//
+// 出错时返回非 2xx 状态码和 {"code": "...", "message": "..."}，证券不存在时 code 为 not_found，
+// 没有行情时为 no_data；配置了 token 时请求带有 Authorization: Bearer <token>
+const (
+	codeNotFound = "not_found"
+	codeNoData   = "no_data"
+	codeInvalid  = "invalid_request"
+	codeInternal = "internal"
+)
+
+// maxResponseSize 行情接口响应的最大字节数
+const maxResponseSize = 8 << 20
+
+// apiError 行情接口返回的错误
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// historyResponse /history 的响应
+type historyResponse struct {
+	Bars []*Bar `json:"bars"`
+}
+
+// searchResponse /search 的响应
+type searchResponse struct {
+	Instruments []*Instrument `json:"instruments"`
+}
+
+// HTTPOptions HTTP 行情来源的设置
+type HTTPOptions struct {
+	BaseURL string        // 接口地址，例如 http://127.0.0.1:9300
+	Token   string        // 为空时不发送 Authorization
+	Timeout time.Duration // 单次请求的超时时间
+}
+
+// HTTP 通过 HTTP 接口获取行情
+type HTTP struct {
+	baseURL *url.URL
+	token   string
+	client  *http.Client
+}
+
+// NewHTTP 创建 HTTP 行情来源
+func NewHTTP(opts HTTPOptions) (*HTTP, error) {
+	u, err := url.Parse(strings.TrimSuffix(opts.BaseURL, "/"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("无效的行情接口地址 %q", opts.BaseURL)
+	}
+	return &HTTP{baseURL: u, token: opts.Token, client: &http.Client{Timeout: opts.Timeout}}, nil
+}
+
+// Name 行情来源的名称
+func (h *HTTP) Name() string {
+	return "http"
+}
+
+// Quote 获取证券的最新报价
+func (h *HTTP) Quote(ctx context.Context, sym Symbol) (*Quote, error) {
+	q := &Quote{}
+	if err := h.get(ctx, "quote", url.Values{"symbol": {sym.String()}}, q); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// History 获取证券在 [from, to] 日期范围内的日线
+func (h *HTTP) History(ctx context.Context, sym Symbol, from, to time.Time) ([]*Bar, error) {
+	resp := &historyResponse{}
+	err := h.get(ctx, "history", url.Values{
+		"symbol": {sym.String()},
+		"from":   {from.Format(DateLayout)},
+		"to":     {to.Format(DateLayout)},
+	}, resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Bars, nil
+}
+
+// Search 按代码、名称或别名搜索证券
+func (h *HTTP) Search(ctx context.Context, query string, limit int) ([]*Instrument, error) {
+	resp := &searchResponse{}
+	if err := h.get(ctx, "search", url.Values{"q": {query}, "limit": {strconv.Itoa(limit)}}, resp); err != nil {
+		return nil, err
+	}
+	return resp.Instruments, nil
+}
+
+// get 调用行情接口并解析响应，not_found 和 no_data 转换为 ErrNotFound 和 ErrNoData
+func (h *HTTP) get(ctx context.Context, operation string, query url.Values, out interface{}) (err error) {
+	start := time.Now()
+	defer func() {
+		if errors.Is(err, ErrNotFound) || errors.Is(err, ErrNoData) {
+			metrics.ObserveMarketData(h.Name(), operation, start, nil)
+			return
+		}
+		metrics.ObserveMarketData(h.Name(), operation, start, err)
+	}()
+
+	u := *h.baseURL
+	u.Path += "/" + operation
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if h.token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.token)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求行情接口失败: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return fmt.Errorf("读取行情接口响应失败: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var apiErr apiError
+		_ = json.Unmarshal(body, &apiErr)
+		switch apiErr.Code {
+		case codeNotFound:
+			return ErrNotFound
+		case codeNoData:
+			return ErrNoData
+		}
+		if apiErr.Message == "" {
+			apiErr.Message = strings.TrimSpace(string(body))
+		}
+		return fmt.Errorf("行情接口返回错误: status=%d, code=%s, message=%s", resp.StatusCode, apiErr.Code, apiErr.Message)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("解析行情接口响应失败: %w", err)
+	}
+	return nil
+}
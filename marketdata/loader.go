@@ -0,0 +1,306 @@
+package marketdata
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"fin_bot/storage"
+)
+
+// 本地行情目录的结构：
+//
+//	instruments.csv    证券列表，表头 symbol,name,currency,aliases（symbol、name 必填）
+//	bars/<代码>.csv     每个证券一个日线文件，例如 bars/600519.SH.csv，表头 date,open,high,low,close,volume
+//
+// 代码可以使用 ParseSymbol 支持的任何写法，导入时规范化；currency 为空时使用市场的默认币种；
+// aliases 中的多个名称用 | 或 、 分隔；日期格式为 YYYY-MM-DD（也支持 YYYY/MM/DD 和 YYYYMMDD）；
+// 以 # 开头的行为注释，日线文件中的其他列（例如 adj_close）会被忽略
+const (
+	instrumentsFile = "instruments.csv"
+	barsDir         = "bars"
+)
+
+// dateLayouts 日线文件支持的日期格式
+var dateLayouts = []string{DateLayout, "2006/01/02", "20060102", "2006/1/2"}
+
+// LoadError 行情文件校验错误，包含所有问题而不是遇到第一个就返回
+type LoadError struct {
+	Problems []string
+}
+
+func (e *LoadError) Error() string {
+	return fmt.Sprintf("行情文件校验失败（%d 项）:\n  - %s", len(e.Problems), strings.Join(e.Problems, "\n  - "))
+}
+
+// File 一个证券的日线文件
+type File struct {
+	Symbol string `json:"symbol"`
+	Path   string `json:"path"`
+	Bars   int    `json:"bars"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+}
+
+// Data 从本地行情目录读取的数据
+type Data struct {
+	Files       []*File
+	Instruments []*storage.MarketInstrument
+	Bars        []*storage.MarketBar
+}
+
+// Load 读取本地行情目录，任何文件有问题时返回 *LoadError 且不返回任何数据
+func Load(dir string) (*Data, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("读取行情目录失败: %w", err)
+	}
+
+	l := &loader{data: &Data{}, instruments: make(map[string]bool)}
+	l.loadInstruments(filepath.Join(dir, instrumentsFile))
+
+	paths, err := filepath.Glob(filepath.Join(dir, barsDir, "*"))
+	if err != nil {
+		return nil, fmt.Errorf("读取行情目录失败: %w", err)
+	}
+	sort.Strings(paths)
+	files := make(map[string]string)
+	for _, path := range paths {
+		name := filepath.Base(path)
+		if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || !strings.EqualFold(filepath.Ext(name), ".csv") {
+			continue
+		}
+		sym, err := ParseSymbol(strings.TrimSuffix(name, filepath.Ext(name)))
+		if err != nil {
+			l.addf("%s: 文件名应为证券代码: %v", path, err)
+			continue
+		}
+		if other, ok := files[sym.String()]; ok {
+			l.addf("%s: 证券 %s 与 %s 重复", path, sym, other)
+			continue
+		}
+		files[sym.String()] = path
+		if !l.instruments[sym.String()] {
+			l.addf("%s: 证券 %s 不在 %s 中", path, sym, instrumentsFile)
+			continue
+		}
+		l.loadBars(path, sym)
+	}
+
+	if len(l.problems) > 0 {
+		return nil, &LoadError{Problems: l.problems}
+	}
+	return l.data, nil
+}
+
+// loader 读取行情文件时收集问题
+type loader struct {
+	problems    []string
+	data        *Data
+	instruments map[string]bool // 证券列表中的代码
+}
+
+func (l *loader) addf(format string, args ...interface{}) {
+	l.problems = append(l.problems, fmt.Sprintf(format, args...))
+}
+
+// loadInstruments 读取证券列表
+func (l *loader) loadInstruments(path string) {
+	rows, ok := l.readCSV(path, []string{"symbol", "name"}, []string{"symbol", "name", "currency", "aliases"})
+	if !ok {
+		return
+	}
+	for _, row := range rows {
+		sym, err := ParseSymbol(row.field("symbol"))
+		if err != nil {
+			l.addf("%s: %v", row.where, err)
+			continue
+		}
+		if l.instruments[sym.String()] {
+			l.addf("%s: 证券 %s 重复", row.where, sym)
+			continue
+		}
+		inst := &storage.MarketInstrument{
+			Symbol:   sym.String(),
+			Name:     row.field("name"),
+			Market:   string(sym.Market),
+			Currency: strings.ToUpper(row.field("currency")),
+			Aliases:  strings.Join(splitList(row.field("aliases")), "|"),
+		}
+		if inst.Name == "" {
+			l.addf("%s: 证券 %s 缺少名称", row.where, sym)
+			continue
+		}
+		if inst.Currency == "" {
+			inst.Currency = sym.Market.Currency()
+		}
+		l.instruments[inst.Symbol] = true
+		l.data.Instruments = append(l.data.Instruments, inst)
+	}
+	if len(rows) == 0 {
+		l.addf("%s: 文件中没有任何证券", path)
+	}
+}
+
+// loadBars 读取一个证券的日线文件
+func (l *loader) loadBars(path string, sym Symbol) {
+	rows, ok := l.readCSV(path, []string{"date", "open", "high", "low", "close"}, nil)
+	if !ok {
+		return
+	}
+	problems := len(l.problems)
+	var bars []*storage.MarketBar
+	dates := make(map[string]int)
+	for _, row := range rows {
+		date, err := parseDate(row.field("date"))
+		if err != nil {
+			l.addf("%s: 无效的日期 %q", row.where, row.field("date"))
+			continue
+		}
+		b := &storage.MarketBar{Symbol: sym.String(), Date: date.Format(DateLayout)}
+		if other, ok := dates[b.Date]; ok {
+			l.addf("%s: 日期 %s 与第 %d 行重复", row.where, b.Date, other)
+			continue
+		}
+		dates[b.Date] = row.line
+
+		valid := true
+		for _, p := range []struct {
+			name  string
+			value *float64
+		}{{"open", &b.Open}, {"high", &b.High}, {"low", &b.Low}, {"close", &b.Close}} {
+			v, err := strconv.ParseFloat(row.field(p.name), 64)
+			if err != nil || !(v > 0) || math.IsInf(v, 0) {
+				l.addf("%s: %s 应为正数: %q", row.where, p.name, row.field(p.name))
+				valid = false
+			}
+			*p.value = v
+		}
+		if v := row.field("volume"); v != "" {
+			volume, err := strconv.ParseFloat(v, 64)
+			if err != nil || volume < 0 {
+				l.addf("%s: volume 应为非负数: %q", row.where, v)
+				valid = false
+			}
+			b.Volume = int64(volume)
+		}
+		if valid && (b.High < max(b.Open, b.Close, b.Low) || b.Low > min(b.Open, b.Close)) {
+			l.addf("%s: high 应为当日最高价、low 应为当日最低价", row.where)
+			valid = false
+		}
+		if valid {
+			bars = append(bars, b)
+		}
+	}
+	if len(rows) == 0 {
+		l.addf("%s: 文件中没有任何日线", path)
+		return
+	}
+	if len(l.problems) > problems {
+		return
+	}
+
+	sort.Slice(bars, func(i, j int) bool { return bars[i].Date < bars[j].Date })
+	l.data.Bars = append(l.data.Bars, bars...)
+	l.data.Files = append(l.data.Files, &File{
+		Symbol: sym.String(), Path: path, Bars: len(bars), From: bars[0].Date, To: bars[len(bars)-1].Date,
+	})
+}
+
+// csvRow CSV 文件中的一行
+type csvRow struct {
+	line    int
+	where   string
+	record  []string
+	columns map[string]int
+}
+
+// field 取出一列的值，没有这一列时返回空字符串
+func (r *csvRow) field(name string) string {
+	if i, ok := r.columns[name]; ok && i < len(r.record) {
+		return strings.TrimSpace(r.record[i])
+	}
+	return ""
+}
+
+// readCSV 读取 CSV 文件，检查必填的列；known 不为空时不允许其他列
+func (l *loader) readCSV(path string, required, known []string) ([]*csvRow, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		l.addf("%s: %v", path, err)
+		return nil, false
+	}
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+
+	header, err := r.Read()
+	if errors.Is(err, io.EOF) {
+		l.addf("%s: 缺少表头", path)
+		return nil, false
+	}
+	if err != nil {
+		l.addf("%s: CSV 格式错误: %v", path, err)
+		return nil, false
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if len(known) > 0 && !slices.Contains(known, name) {
+			l.addf("%s: 未知的列 %q（支持 %s）", path, name, strings.Join(known, ","))
+			return nil, false
+		}
+		columns[name] = i
+	}
+	for _, name := range required {
+		if _, ok := columns[name]; !ok {
+			l.addf("%s: 缺少 %s 列", path, name)
+			return nil, false
+		}
+	}
+
+	var rows []*csvRow
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			l.addf("%s: CSV 格式错误: %v", path, err)
+			return nil, false
+		}
+		line, _ := r.FieldPos(0)
+		rows = append(rows, &csvRow{line: line, where: fmt.Sprintf("%s: 第 %d 行", path, line), record: record, columns: columns})
+	}
+	return rows, true
+}
+
+// parseDate 按支持的格式解析日期
+func parseDate(s string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无效的日期 %q", s)
+}
+
+// splitList 拆分 | 或 、 分隔的多个值
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.FieldsFunc(s, func(r rune) bool { return r == '|' || r == '、' }) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
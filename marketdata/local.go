@@ -0,0 +1,191 @@
+package marketdata
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"fin_bot/storage"
+)
+
+// Local 本地行情来源：读取从 CSV 文件导入数据库的证券和日线，用于离线运行和测试
+// 最新报价为最近一个交易日的日线，涨跌相对前一个交易日的收盘价
+type Local struct {
+	store *storage.Storage
+	now   func() time.Time
+}
+
+// NewLocal 创建本地行情来源
+func NewLocal(store *storage.Storage) *Local {
+	return &Local{store: store, now: time.Now}
+}
+
+// Name 行情来源的名称
+func (l *Local) Name() string {
+	return "local"
+}
+
+// ImportResult 导入本地行情目录的结果
+type ImportResult struct {
+	Files []*File `json:"files"`
+	storage.MarketImportStats
+}
+
+// Import 导入本地行情目录，替换数据库中的全部本地行情
+// 任何文件有问题时返回 *LoadError，不修改已有的数据
+func (l *Local) Import(ctx context.Context, dir string) (*ImportResult, error) {
+	data, err := Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := l.store.ImportMarketData(ctx, data.Instruments, data.Bars)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[marketdata] 已导入本地行情: 证券 %d 个，日线 %d 条，删除证券 %d 个", stats.Instruments, stats.Bars, stats.Removed)
+	return &ImportResult{Files: data.Files, MarketImportStats: stats}, nil
+}
+
+// Quote 证券最近一个交易日（不晚于今天）的日线作为报价
+func (l *Local) Quote(ctx context.Context, sym Symbol) (*Quote, error) {
+	inst, err := l.instrument(ctx, sym)
+	if err != nil {
+		return nil, err
+	}
+	bars, err := l.store.LatestMarketBars(ctx, sym.String(), Day(l.now()).Format(DateLayout), 2)
+	if err != nil {
+		return nil, err
+	}
+	if len(bars) == 0 {
+		return nil, ErrNoData
+	}
+	last, err := toBar(bars[0])
+	if err != nil {
+		return nil, err
+	}
+	q := &Quote{
+		Symbol: sym, Name: inst.Name, Currency: inst.Currency,
+		Price: last.Close, Open: last.Open, High: last.High, Low: last.Low, Volume: last.Volume, Time: last.Date,
+	}
+	if len(bars) > 1 {
+		q.PrevClose = bars[1].Close
+	}
+	return q, nil
+}
+
+// History 证券在 [from, to] 日期范围内的日线
+func (l *Local) History(ctx context.Context, sym Symbol, from, to time.Time) ([]*Bar, error) {
+	if _, err := l.instrument(ctx, sym); err != nil {
+		return nil, err
+	}
+	rows, err := l.store.ListMarketBars(ctx, sym.String(), from.Format(DateLayout), to.Format(DateLayout))
+	if err != nil {
+		return nil, err
+	}
+	bars := make([]*Bar, 0, len(rows))
+	for _, row := range rows {
+		b, err := toBar(row)
+		if err != nil {
+			return nil, err
+		}
+		bars = append(bars, b)
+	}
+	return bars, nil
+}
+
+// 搜索的匹配程度，数值越小越接近
+const (
+	searchSymbol   = iota // 代码完全相同（查询按 ParseSymbol 规范化后比较）
+	searchName            // 名称或别名完全相同
+	searchPrefix          // 代码、名称或别名以查询开头
+	searchContains        // 名称或别名包含查询
+)
+
+// Search 按代码、名称或别名搜索证券（不区分大小写），匹配程度相同时按代码排列
+func (l *Local) Search(ctx context.Context, query string, limit int) ([]*Instrument, error) {
+	query = strings.TrimSpace(query)
+	if query == "" || limit <= 0 {
+		return nil, nil
+	}
+	rows, err := l.store.ListMarketInstruments(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sym, symErr := ParseSymbol(query)
+	q := strings.ToLower(query)
+	type match struct {
+		inst  *Instrument
+		score int
+	}
+	var matches []match
+	for _, row := range rows {
+		inst, err := toInstrument(row)
+		if err != nil {
+			return nil, err
+		}
+		names := append([]string{inst.Name}, inst.Aliases...)
+		score := -1
+		switch {
+		case symErr == nil && inst.Symbol == sym:
+			score = searchSymbol
+		case anyName(names, q, strings.EqualFold):
+			score = searchName
+		case strings.HasPrefix(strings.ToLower(inst.Symbol.String()), q),
+			anyName(names, q, func(name, q string) bool { return strings.HasPrefix(strings.ToLower(name), q) }):
+			score = searchPrefix
+		case anyName(names, q, func(name, q string) bool { return strings.Contains(strings.ToLower(name), q) }):
+			score = searchContains
+		}
+		if score >= 0 {
+			matches = append(matches, match{inst: inst, score: score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score < matches[j].score })
+
+	result := make([]*Instrument, 0, min(len(matches), limit))
+	for _, m := range matches[:min(len(matches), limit)] {
+		result = append(result, m.inst)
+	}
+	return result, nil
+}
+
+// anyName 是否有名称与查询匹配
+func anyName(names []string, q string, match func(name, q string) bool) bool {
+	for _, name := range names {
+		if match(name, q) {
+			return true
+		}
+	}
+	return false
+}
+
+// instrument 获取证券，不存在时返回 ErrNotFound
+func (l *Local) instrument(ctx context.Context, sym Symbol) (*storage.MarketInstrument, error) {
+	inst, err := l.store.GetMarketInstrument(ctx, sym.String())
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	return inst, err
+}
+
+// toInstrument 转换数据库中的证券
+func toInstrument(row *storage.MarketInstrument) (*Instrument, error) {
+	sym, err := ParseSymbol(row.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	return &Instrument{Symbol: sym, Name: row.Name, Currency: row.Currency, Aliases: splitList(row.Aliases)}, nil
+}
+
+// toBar 转换数据库中的日线
+func toBar(row *storage.MarketBar) (*Bar, error) {
+	date, err := time.Parse(DateLayout, row.Date)
+	if err != nil {
+		return nil, err
+	}
+	return &Bar{Date: date, Open: row.Open, High: row.High, Low: row.Low, Close: row.Close, Volume: row.Volume}, nil
+}
@@ -0,0 +1,84 @@
+package marketdata
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrNotFound 行情来源中没有这个证券
+	ErrNotFound = errors.New("没有找到该证券")
+	// ErrNoData 证券存在，但没有所请求时间段的行情
+	ErrNoData = errors.New("没有行情数据")
+)
+
+// DateLayout 日线日期的格式
+const DateLayout = "2006-01-02"
+
+// Provider 行情来源：实时（或最新）报价、日线历史和证券搜索
+// 实现需要可以并发调用；返回的结果可能被缓存共享，调用方不能修改
+type Provider interface {
+	// Name 行情来源的名称，用于日志和指标
+	Name() string
+	// Quote 证券的最新报价，证券不存在时返回 ErrNotFound，没有任何行情时返回 ErrNoData
+	Quote(ctx context.Context, sym Symbol) (*Quote, error)
+	// History 证券在 [from, to] 日期范围内的日线（按日期升序，只比较日期部分），
+	// 证券不存在时返回 ErrNotFound，范围内没有交易日时返回空切片
+	History(ctx context.Context, sym Symbol, from, to time.Time) ([]*Bar, error)
+	// Search 按代码、名称或别名搜索证券，最多返回 limit 个，最匹配的在前
+	Search(ctx context.Context, query string, limit int) ([]*Instrument, error)
+}
+
+// Instrument 一个证券
+type Instrument struct {
+	Symbol   Symbol   `json:"symbol"`
+	Name     string   `json:"name"`
+	Currency string   `json:"currency"`
+	Aliases  []string `json:"aliases,omitempty"` // 简称、英文名等可以搜索的名称
+}
+
+// Quote 证券的最新报价
+type Quote struct {
+	Symbol    Symbol    `json:"symbol"`
+	Name      string    `json:"name"`
+	Currency  string    `json:"currency"`
+	Price     float64   `json:"price"`      // 最新价（收盘后为收盘价）
+	PrevClose float64   `json:"prev_close"` // 上一交易日收盘价，没有时为 0
+	Open      float64   `json:"open"`
+	High      float64   `json:"high"`
+	Low       float64   `json:"low"`
+	Volume    int64     `json:"volume"`
+	Time      time.Time `json:"time"` // 报价时间，日线数据为交易日期
+}
+
+// Change 相对上一交易日收盘价的涨跌额
+func (q *Quote) Change() float64 {
+	if q.PrevClose == 0 {
+		return 0
+	}
+	return q.Price - q.PrevClose
+}
+
+// ChangePercent 相对上一交易日收盘价的涨跌幅（百分比）
+func (q *Quote) ChangePercent() float64 {
+	if q.PrevClose == 0 {
+		return 0
+	}
+	return (q.Price - q.PrevClose) / q.PrevClose * 100
+}
+
+// Bar 一个交易日的日线（OHLCV）
+type Bar struct {
+	Date   time.Time `json:"date"` // 交易日期，UTC 零点
+	Open   float64   `json:"open"`
+	High   float64   `json:"high"`
+	Low    float64   `json:"low"`
+	Close  float64   `json:"close"`
+	Volume int64     `json:"volume"`
+}
+
+// Day 去掉时间部分，作为日线的日期（按 t 所在时区的日期，转为 UTC 零点）
+func Day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
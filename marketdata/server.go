@@ -0,0 +1,114 @@
+package marketdata
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxSearchLimit 搜索接口一次最多返回的证券数
+const maxSearchLimit = 50
+
+// NewHandler 返回按 HTTP 行情接口约定提供 p 的行情的处理器，用于本地模拟行情服务（fin_bot marketdata serve）
+// token 不为空时要求请求带有 Authorization: Bearer <token>
+func NewHandler(p Provider, token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /quote", func(w http.ResponseWriter, r *http.Request) {
+		sym, err := ParseSymbol(r.URL.Query().Get("symbol"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalid, err.Error())
+			return
+		}
+		q, err := p.Quote(r.Context(), sym)
+		if err != nil {
+			writeProviderError(w, err)
+			return
+		}
+		writeJSON(w, q)
+	})
+	mux.HandleFunc("GET /history", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		sym, err := ParseSymbol(query.Get("symbol"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalid, err.Error())
+			return
+		}
+		from, err1 := time.Parse(DateLayout, query.Get("from"))
+		to, err2 := time.Parse(DateLayout, query.Get("to"))
+		if err1 != nil || err2 != nil {
+			writeError(w, http.StatusBadRequest, codeInvalid, "from 和 to 应为 YYYY-MM-DD 格式的日期")
+			return
+		}
+		bars, err := p.History(r.Context(), sym, from, to)
+		if err != nil {
+			writeProviderError(w, err)
+			return
+		}
+		if bars == nil {
+			bars = []*Bar{}
+		}
+		writeJSON(w, &historyResponse{Bars: bars})
+	})
+	mux.HandleFunc("GET /search", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		limit := 10
+		if s := query.Get("limit"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n <= 0 {
+				writeError(w, http.StatusBadRequest, codeInvalid, "limit 应为正整数")
+				return
+			}
+			limit = min(n, maxSearchLimit)
+		}
+		instruments, err := p.Search(r.Context(), query.Get("q"), limit)
+		if err != nil {
+			writeProviderError(w, err)
+			return
+		}
+		if instruments == nil {
+			instruments = []*Instrument{}
+		}
+		writeJSON(w, &searchResponse{Instruments: instruments})
+	})
+
+	if token == "" {
+		return mux
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "unauthorized", "缺少或错误的 token")
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// writeProviderError 将行情来源的错误转换为接口错误
+func writeProviderError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		writeError(w, http.StatusNotFound, codeNotFound, err.Error())
+	case errors.Is(err, ErrNoData):
+		writeError(w, http.StatusNotFound, codeNoData, err.Error())
+	default:
+		log.Printf("[marketdata] 行情接口出错: %v", err)
+		writeError(w, http.StatusInternalServerError, codeInternal, "内部错误")
+	}
+}
+
+// writeError 返回接口错误
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(&apiError{Code: code, Message: message})
+}
+
+// writeJSON 返回 JSON 响应
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,194 @@
+package marketdata
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Market 证券所在的市场
+type Market string
+
+const (
+	MarketSH Market = "SH" // 上海证券交易所
+	MarketSZ Market = "SZ" // 深圳证券交易所
+	MarketBJ Market = "BJ" // 北京证券交易所
+	MarketHK Market = "HK" // 香港交易所
+	MarketUS Market = "US" // 美国市场（NYSE、NASDAQ 等不再区分）
+)
+
+// marketLabels 市场的中文名称
+var marketLabels = map[Market]string{
+	MarketSH: "上交所",
+	MarketSZ: "深交所",
+	MarketBJ: "北交所",
+	MarketHK: "港股",
+	MarketUS: "美股",
+}
+
+// Label 市场的中文名称
+func (m Market) Label() string {
+	if label, ok := marketLabels[m]; ok {
+		return label
+	}
+	return string(m)
+}
+
+// Currency 市场的默认交易币种
+func (m Market) Currency() string {
+	switch m {
+	case MarketHK:
+		return "HKD"
+	case MarketUS:
+		return "USD"
+	default:
+		return "CNY"
+	}
+}
+
+// marketSuffixes 代码后缀（或前缀）对应的市场，包括常见行情软件的写法：
+// Yahoo 的 .SS（上交所），Wind 的 .O（NASDAQ）和 .N（NYSE）
+var marketSuffixes = map[string]Market{
+	"SH": MarketSH,
+	"SS": MarketSH,
+	"SZ": MarketSZ,
+	"BJ": MarketBJ,
+	"HK": MarketHK,
+	"US": MarketUS,
+	"O":  MarketUS,
+	"N":  MarketUS,
+}
+
+var (
+	// aShareCode A 股代码为 6 位数字
+	aShareCode = regexp.MustCompile(`^\d{6}$`)
+	// hkCode 港股代码为 1-5 位数字，规范化后补足 5 位
+	hkCode = regexp.MustCompile(`^\d{1,5}$`)
+	// usCode 美股代码为字母开头，可以包含数字、. 和 -（例如 BRK.B、BF-B）
+	usCode = regexp.MustCompile(`^[A-Z][A-Z0-9]*([.-][A-Z0-9]+)?$`)
+	// prefixed 市场写在代码前面的形式，例如 sh600519、hk00700、US.AAPL
+	prefixed = regexp.MustCompile(`^(SH|SZ|BJ|HK|US)(\.?)(.+)$`)
+)
+
+// Symbol 规范化的证券代码，例如 600519.SH、00700.HK、AAPL.US
+type Symbol struct {
+	Code   string
+	Market Market
+}
+
+// ParseSymbol 解析并规范化证券代码，支持以下写法（不区分大小写）：
+//   - 带后缀：600519.SH、600519.SS、700.HK、AAPL.US、AAPL.O
+//   - 带前缀：sh600519、SZ000001、hk00700、US.AAPL
+//   - 不带市场：6 位数字按代码段判断沪深北市场，5 位以内的数字为港股，字母为美股
+//
+// 港股代码补足 5 位，美股代码转为大写
+func ParseSymbol(s string) (Symbol, error) {
+	text := strings.ToUpper(strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		if r >= '！' && r <= '～' { // 全角字符
+			r -= '！' - '!'
+		}
+		return r
+	}, s))
+	if text == "" {
+		return Symbol{}, fmt.Errorf("证券代码不能为空")
+	}
+
+	code, market := text, Market("")
+	if i := strings.LastIndexByte(text, '.'); i > 0 {
+		if m, ok := marketSuffixes[text[i+1:]]; ok {
+			code, market = text[:i], m
+		}
+	}
+	if market == "" {
+		// 字母代码只有用 . 分隔时才当作前缀，避免把 SHOP、USB 之类的美股代码拆开
+		if m := prefixed.FindStringSubmatch(text); m != nil && (isDigits(m[3]) || m[2] == ".") {
+			code, market = m[3], Market(m[1])
+		}
+	}
+	if market == "" {
+		market = guessMarket(code)
+	}
+
+	sym := Symbol{Code: code, Market: market}
+	switch market {
+	case MarketSH, MarketSZ, MarketBJ:
+		if !aShareCode.MatchString(code) {
+			return Symbol{}, fmt.Errorf("无效的 A 股代码 %q（应为 6 位数字）", s)
+		}
+	case MarketHK:
+		if !hkCode.MatchString(code) {
+			return Symbol{}, fmt.Errorf("无效的港股代码 %q（应为 5 位以内的数字）", s)
+		}
+		sym.Code = fmt.Sprintf("%05s", code)
+	case MarketUS:
+		if !usCode.MatchString(code) {
+			return Symbol{}, fmt.Errorf("无效的美股代码 %q", s)
+		}
+	default:
+		return Symbol{}, fmt.Errorf("无法识别的证券代码 %q", s)
+	}
+	return sym, nil
+}
+
+// guessMarket 按代码本身判断市场，无法判断时返回空字符串
+// A 股按代码段：4、8 和 920 开头为北交所，5、6、7、9 开头为上交所（含科创板、基金和 B 股），0、1、2、3 开头为深交所
+func guessMarket(code string) Market {
+	switch {
+	case aShareCode.MatchString(code):
+		switch {
+		case strings.HasPrefix(code, "920"), code[0] == '4', code[0] == '8':
+			return MarketBJ
+		case strings.ContainsRune("5679", rune(code[0])):
+			return MarketSH
+		default:
+			return MarketSZ
+		}
+	case hkCode.MatchString(code):
+		return MarketHK
+	case usCode.MatchString(code):
+		return MarketUS
+	}
+	return ""
+}
+
+// isDigits 是否全部是数字
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// String 规范化的代码，例如 600519.SH
+func (s Symbol) String() string {
+	if s.Code == "" {
+		return ""
+	}
+	return s.Code + "." + string(s.Market)
+}
+
+// IsZero 是否为空代码
+func (s Symbol) IsZero() bool {
+	return s.Code == ""
+}
+
+// MarshalText 序列化为规范化的代码
+func (s Symbol) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText 按 ParseSymbol 解析代码
+func (s *Symbol) UnmarshalText(text []byte) error {
+	sym, err := ParseSymbol(string(text))
+	if err != nil {
+		return err
+	}
+	*s = sym
+	return nil
+}
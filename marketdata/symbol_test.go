@@ -0,0 +1,68 @@
+package marketdata
+
+import "testing"
+
+func TestParseSymbol(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"上交所后缀", "600519.SH", "600519.SH"},
+		{"Yahoo 上交所后缀", "600519.ss", "600519.SH"},
+		{"小写前缀", "sh600519", "600519.SH"},
+		{"不带市场的沪市代码", "600519", "600519.SH"},
+		{"科创板", "688981", "688981.SH"},
+		{"深交所前缀", "SZ000001", "000001.SZ"},
+		{"不带市场的深市代码", "300750", "300750.SZ"},
+		{"北交所", "920002", "920002.BJ"},
+		{"北交所 8 开头", "830799", "830799.BJ"},
+		{"全角和空格", "６００５１９．ＳＨ ", "600519.SH"},
+		{"港股补足 5 位", "700.HK", "00700.HK"},
+		{"港股前缀", "hk00700", "00700.HK"},
+		{"港股带点前缀", "HK.9988", "09988.HK"},
+		{"不带市场的港股代码", "1810", "01810.HK"},
+		{"美股", "aapl", "AAPL.US"},
+		{"美股后缀", "AAPL.US", "AAPL.US"},
+		{"Wind NASDAQ 后缀", "AAPL.O", "AAPL.US"},
+		{"Wind NYSE 后缀", "BRK.B.N", "BRK.B.US"},
+		{"美股带点前缀", "US.TSLA", "TSLA.US"},
+		{"美股带点的代码", "BRK.B", "BRK.B.US"},
+		{"美股带横线的代码", "BF-B", "BF-B.US"},
+		{"以市场代码开头的美股代码", "SHOP", "SHOP.US"},
+		{"以 US 开头的美股代码", "USB", "USB.US"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSymbol(tt.input)
+			if err != nil {
+				t.Fatalf("ParseSymbol(%q): %v", tt.input, err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("ParseSymbol(%q) = %s, want %s", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSymbolInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"空", "  "},
+		{"A 股代码不是 6 位", "60051.SH"},
+		{"港股代码超过 5 位", "123456.HK"},
+		{"港股代码不是数字", "ABC.HK"},
+		{"美股代码以数字开头", "1ABC.US"},
+		{"7 位数字", "1234567"},
+		{"无法识别", "茅台"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := ParseSymbol(tt.input); err == nil {
+				t.Errorf("ParseSymbol(%q) = %s, want error", tt.input, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"fin_bot/alert"
+	"fin_bot/config"
+	"fin_bot/marketdata"
+	"fin_bot/quote"
+	"fin_bot/storage"
+	"fin_bot/watchlist"
+)
+
+// runMarketDataImportCommand 导入本地行情目录中的 CSV 文件，-dry-run 时只校验文件
+func runMarketDataImportCommand(configPath string, args []string) int {
+	fs := newCLIFlags("marketdata import", "[-dir 行情目录] [-db 数据库] [-dry-run] [-format text|json]")
+	dir := fs.String("dir", "", "本地行情目录（默认使用配置中的 marketdata.dir）")
+	dbPath := fs.String("db", "", "数据库文件路径（默认使用配置中的 database.path）")
+	dryRun := fs.Bool("dry-run", false, "只校验行情文件，不写入数据库")
+	format := fs.String("format", "text", "输出格式: text 或 json")
+	fs.logFlag()
+	if code := fs.parse(args); code != exitOK {
+		return code
+	}
+	if *format != "text" && *format != "json" {
+		return fs.usageError("无效的输出格式: %s", *format)
+	}
+
+	cfg := loadConfig(configPath, false)
+	if cfg == nil {
+		return exitError
+	}
+	if *dir == "" {
+		*dir = cfg.MarketData.Dir
+	}
+
+	result := &marketdata.ImportResult{}
+	if *dryRun {
+		data, err := marketdata.Load(*dir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+		result.Files = data.Files
+		result.Instruments, result.Bars = len(data.Instruments), len(data.Bars)
+	} else {
+		store, err := openStorage(cfg, *dbPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "打开数据库失败: %v\n", err)
+			return exitError
+		}
+		defer store.Close()
+
+		result, err = marketdata.NewLocal(store).Import(context.Background(), *dir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+	}
+	if result.Files == nil {
+		result.Files = []*marketdata.File{}
+	}
+
+	if *format == "json" {
+		if err := json.NewEncoder(os.Stdout).Encode(result); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+		return exitOK
+	}
+	for _, f := range result.Files {
+		fmt.Printf("%s\t%s\t%d 条\t%s ~ %s\n", f.Symbol, f.Path, f.Bars, f.From, f.To)
+	}
+	if *dryRun {
+		fmt.Fprintf(os.Stderr, "%s 中的 %d 个证券、%d 条日线校验通过\n", *dir, result.Instruments, result.Bars)
+	} else {
+		fmt.Fprintf(os.Stderr, "已从 %s 导入 %d 个证券、%d 条日线（删除证券 %d 个）\n",
+			*dir, result.Instruments, result.Bars, result.Removed)
+	}
+	return exitOK
+}
+
+// runMarketDataQuoteCommand 通过配置的行情来源查询报价和最近的日线，用于检查行情来源是否可用
+func runMarketDataQuoteCommand(configPath string, args []string) int {
+	fs := newCLIFlags("marketdata quote", "-symbol 代码 [-days 天数] [-db 数据库] [-format text|json]")
+	symbol := fs.String("symbol", "", "证券代码，例如 600519、00700.HK、AAPL（必填）")
+	days := fs.Int("days", 0, "同时输出最近多少天的日线（按自然日计算）")
+	dbPath := fs.String("db", "", "本地行情的数据库文件路径（默认使用配置中的 database.path）")
+	format := fs.String("format", "text", "输出格式: text 或 json")
+	fs.logFlag()
+	if code := fs.parse(args); code != exitOK {
+		return code
+	}
+	if *format != "text" && *format != "json" {
+		return fs.usageError("无效的输出格式: %s", *format)
+	}
+	if *days < 0 {
+		return fs.usageError("-days 不能小于 0")
+	}
+	sym, err := marketdata.ParseSymbol(*symbol)
+	if err != nil {
+		return fs.usageError("%v", err)
+	}
+
+	cfg := loadConfig(configPath, true)
+	if cfg == nil {
+		return exitError
+	}
+	provider, closeProvider, err := openMarketData(cfg, *dbPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	defer closeProvider()
+
+	ctx := context.Background()
+	q, err := provider.Quote(ctx, sym)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", sym, err)
+		return exitError
+	}
+	var bars []*marketdata.Bar
+	if *days > 0 {
+		to := marketdata.Day(time.Now())
+		if bars, err = provider.History(ctx, sym, to.AddDate(0, 0, -*days), to); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", sym, err)
+			return exitError
+		}
+	}
+
+	if *format == "json" {
+		out := struct {
+			Quote *marketdata.Quote `json:"quote"`
+			Bars  []*marketdata.Bar `json:"bars,omitempty"`
+		}{q, bars}
+		if err := json.NewEncoder(os.Stdout).Encode(out); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+		return exitOK
+	}
+	fmt.Printf("%s %s（%s，%s）\n", q.Symbol, q.Name, q.Symbol.Market.Label(), q.Currency)
+	at := q.Time.Format(time.DateTime)
+	if q.Time.Equal(marketdata.Day(q.Time)) {
+		at = q.Time.Format(marketdata.DateLayout) // 日线数据只有日期
+	}
+	fmt.Printf("最新 %.2f  涨跌 %+.2f（%+.2f%%）  开 %.2f  高 %.2f  低 %.2f  量 %d  时间 %s\n",
+		q.Price, q.Change(), q.ChangePercent(), q.Open, q.High, q.Low, q.Volume, at)
+	if len(bars) > 0 {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "\n日期\t开盘\t最高\t最低\t收盘\t成交量")
+		for _, b := range bars {
+			fmt.Fprintf(w, "%s\t%.2f\t%.2f\t%.2f\t%.2f\t%d\n", b.Date.Format(marketdata.DateLayout), b.Open, b.High, b.Low, b.Close, b.Volume)
+		}
+		w.Flush()
+	}
+	return exitOK
+}
+
+// runMarketDataSearchCommand 通过配置的行情来源搜索证券
+func runMarketDataSearchCommand(configPath string, args []string) int {
+	fs := newCLIFlags("marketdata search", "-q 关键词 [-limit 数量] [-db 数据库] [-format text|json]")
+	query := fs.String("q", "", "代码、名称或别名（必填）")
+	limit := fs.Int("limit", 10, "最多输出的证券数")
+	dbPath := fs.String("db", "", "本地行情的数据库文件路径（默认使用配置中的 database.path）")
+	format := fs.String("format", "text", "输出格式: text 或 json")
+	fs.logFlag()
+	if code := fs.parse(args); code != exitOK {
+		return code
+	}
+	if *query == "" {
+		return fs.usageError("缺少 -q 参数")
+	}
+	if *limit <= 0 {
+		return fs.usageError("-limit 必须大于 0")
+	}
+	if *format != "text" && *format != "json" {
+		return fs.usageError("无效的输出格式: %s", *format)
+	}
+
+	cfg := loadConfig(configPath, true)
+	if cfg == nil {
+		return exitError
+	}
+	provider, closeProvider, err := openMarketData(cfg, *dbPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	defer closeProvider()
+
+	instruments, err := provider.Search(context.Background(), *query, *limit)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	if instruments == nil {
+		instruments = []*marketdata.Instrument{}
+	}
+	if *format == "json" {
+		if err := json.NewEncoder(os.Stdout).Encode(instruments); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+		return exitOK
+	}
+	for _, inst := range instruments {
+		fmt.Printf("%s\t%s\t%s\t%s\n", inst.Symbol, inst.Name, inst.Symbol.Market.Label(), inst.Currency)
+	}
+	fmt.Fprintf(os.Stderr, "共 %d 个证券\n", len(instruments))
+	return exitOK
+}
+
+// runMarketDataServeCommand 以 HTTP 行情接口的形式提供本地行情，作为 http 行情来源的模拟服务
+func runMarketDataServeCommand(configPath string, args []string) int {
+	fs := newCLIFlags("marketdata serve", "[-addr 地址] [-token token] [-db 数据库]")
+	addr := fs.String("addr", "127.0.0.1:9300", "监听地址")
+	token := fs.String("token", "", "要求请求带有的 Bearer token（为空时不校验）")
+	dbPath := fs.String("db", "", "数据库文件路径（默认使用配置中的 database.path）")
+	fs.logFlag()
+	if code := fs.parse(args); code != exitOK {
+		return code
+	}
+
+	cfg := loadConfig(configPath, false)
+	if cfg == nil {
+		return exitError
+	}
+	store, err := openStorage(cfg, *dbPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "打开数据库失败: %v\n", err)
+		return exitError
+	}
+	defer store.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	srv := &http.Server{Addr: *addr, Handler: marketdata.NewHandler(marketdata.NewLocal(store), *token)}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Fprintf(os.Stderr, "本地行情接口已启动: http://%s（按 Ctrl+C 退出）\n", *addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(os.Stderr, "行情接口出错: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// openMarketData 为命令行按配置打开行情来源（不缓存），使用本地行情时同时打开数据库
+func openMarketData(cfg *config.Config, dbPath string) (marketdata.Provider, func(), error) {
+	if cfg.MarketData.Provider == "http" {
+		p, err := newMarketProvider(nil, cfg.MarketData)
+		return p, func() {}, err
+	}
+	store, err := openStorage(cfg, dbPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("打开数据库失败: %w", err)
+	}
+	return marketdata.NewLocal(store), func() { store.Close() }, nil
+}
+
+// newMarketProvider 按配置创建行情来源（不带缓存）
+func newMarketProvider(store *storage.Storage, c config.MarketDataConfig) (marketdata.Provider, error) {
+	if c.Provider == "http" {
+		return marketdata.NewHTTP(marketdata.HTTPOptions{BaseURL: c.HTTPURL, Token: c.HTTPToken, Timeout: c.HTTPTimeout})
+	}
+	return marketdata.NewLocal(store), nil
+}
+
+// importMarketData 使用本地行情时，服务启动和配置变更时自动导入本地行情目录，失败时保留数据库中已有的行情
+func importMarketData(ctx context.Context, store *storage.Storage, cache *marketdata.Cache, c config.MarketDataConfig) {
+	if c.Provider != "local" || !c.ImportOnLoad {
+		return
+	}
+	if _, err := os.Stat(c.Dir); errors.Is(err, os.ErrNotExist) {
+		log.Printf("[marketdata] 本地行情目录 %s 不存在，跳过导入", c.Dir)
+		return
+	}
+	result, err := marketdata.NewLocal(store).Import(ctx, c.Dir)
+	if err != nil {
+		log.Printf("[警告] 导入本地行情失败，继续使用已导入的行情: %v", err)
+		return
+	}
+	cache.Purge()
+	fmt.Printf("已导入 %d 个证券的本地行情: %s\n", result.Instruments, c.Dir)
+}
+
+// marketCacheSettings 将配置转换为行情缓存设置
+func marketCacheSettings(c config.MarketDataConfig) marketdata.CacheSettings {
+	return marketdata.CacheSettings{
+		QuoteTTL:   c.QuoteTTL,
+		HistoryTTL: c.HistoryTTL,
+		SearchTTL:  c.SearchTTL,
+	}
+}
+
+// quoteSettings 将配置转换为行情卡片设置
+func quoteSettings(c config.QuoteConfig) quote.Settings {
+	return quote.Settings{
+		UpColor:  c.UpColor,
+		Timezone: c.Timezone,
+	}
+}
+
+// watchlistSettings 将配置转换为自选列表设置
+func watchlistSettings(c config.WatchlistConfig) watchlist.Settings {
+	return watchlist.Settings{
+		MorningSchedule: c.MorningSchedule,
+		EveningSchedule: c.EveningSchedule,
+		Timezone:        c.Timezone,
+		MaxSymbols:      c.MaxSymbols,
+	}
+}
+
+// alertSettings 将配置转换为提醒设置
+func alertSettings(c config.AlertsConfig) alert.Settings {
+	return alert.Settings{
+		Interval:          c.Interval,
+		HysteresisPercent: c.HysteresisPercent,
+		HysteresisPoints:  c.HysteresisPoints,
+		MaxRules:          c.MaxRules,
+		Timezone:          c.Timezone,
+	}
+}
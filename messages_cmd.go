@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"fin_bot/audit"
+	"fin_bot/storage"
+)
+
+// exportCSVHeader csv 格式的表头，与 storage.Message 的 JSON 字段一致
+var exportCSVHeader = []string{"id", "app_id", "tenant_key", "chat_id", "message_id", "sender_id", "sender_type", "content", "message_type", "created_at"}
+
+// runMessagesExportCommand 按应用、会话和时间导出保存的消息
+func runMessagesExportCommand(configPath string, args []string) int {
+	fs := newCLIFlags("messages export", "[-app 应用 [-tenant 租户]] [-chat chat_id] [-since 时间] [-format jsonl|csv] [-o 文件] [-db 数据库]")
+	appName := fs.String("app", "", "只导出指定应用的消息（应用名称或 App ID，默认导出所有应用）")
+	tenant := fs.String("tenant", "", "租户 tenant_key（默认使用应用配置的 tenant_key，需要同时指定 -app）")
+	chatID := fs.String("chat", "", "只导出指定会话的消息")
+	since := fs.String("since", "", "起始时间: RFC3339、2006-01-02 或相对时长（例如 72h）")
+	format := fs.String("format", "jsonl", "输出格式: jsonl 或 csv")
+	output := fs.String("o", "", "输出文件（默认输出到标准输出）")
+	dbPath := fs.String("db", "", "数据库文件路径（默认使用配置中的 database.path）")
+	fs.logFlag()
+	if code := fs.parse(args); code != exitOK {
+		return code
+	}
+	if *format != "jsonl" && *format != "csv" {
+		return fs.usageError("无效的输出格式: %s", *format)
+	}
+	if *tenant != "" && *appName == "" {
+		return fs.usageError("-tenant 需要同时指定 -app")
+	}
+
+	filter := storage.MessageFilter{ChatID: *chatID}
+	if *since != "" {
+		t, err := parseSince(*since, time.Now())
+		if err != nil {
+			return fs.usageError("%v", err)
+		}
+		filter.Since = t
+	}
+
+	cfg := loadConfig(configPath, false)
+	if cfg == nil {
+		return exitError
+	}
+	if *appName != "" {
+		app, err := selectApp(cfg, *appName)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitUsage
+		}
+		scope := storage.Scope{AppID: app.AppID, TenantKey: app.TenantKey}
+		if *tenant != "" {
+			scope.TenantKey = *tenant
+		}
+		filter.Scope = &scope
+	}
+
+	store, err := openStorage(cfg, *dbPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "打开数据库失败: %v\n", err)
+		return exitError
+	}
+	defer store.Close()
+
+	if _, err := configureStorage(context.Background(), cfg, store); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.OpenFile(*output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "创建输出文件失败: %v\n", err)
+			return exitError
+		}
+		defer f.Close()
+		out = f
+	}
+
+	ctx := audit.WithActor(context.Background(), audit.CLIActor())
+	count, err := exportMessages(ctx, store, filter, *format, out)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "导出消息失败: %v\n", err)
+		return exitError
+	}
+	var scope storage.Scope
+	if filter.Scope != nil {
+		scope = *filter.Scope
+	}
+	audit.NewLogger(store).Record(ctx, scope, audit.ActionDataExport, "messages",
+		fmt.Sprintf("chat=%s since=%q format=%s output=%q count=%d", *chatID, *since, *format, *output, count))
+	fmt.Fprintf(os.Stderr, "共导出 %d 条消息\n", count)
+	return exitOK
+}
+
+// runMessagesReindexCommand 按当前的 search 配置重建消息搜索索引
+func runMessagesReindexCommand(configPath string, args []string) int {
+	fs := newCLIFlags("messages reindex", "[-db 数据库]")
+	dbPath := fs.String("db", "", "数据库文件路径（默认使用配置中的 database.path）")
+	fs.logFlag()
+	if code := fs.parse(args); code != exitOK {
+		return code
+	}
+
+	cfg := loadConfig(configPath, false)
+	if cfg == nil {
+		return exitError
+	}
+	store, err := openStorage(cfg, *dbPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "打开数据库失败: %v\n", err)
+		return exitError
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	if _, err := configureStorage(ctx, cfg, store); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	n, err := store.ReindexMessages(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "重建索引失败（已处理 %d 条）: %v\n", n, err)
+		return exitError
+	}
+	fmt.Printf("已重建 %d 条消息的搜索索引\n", n)
+	return exitOK
+}
+
+// exportMessages 将消息按指定格式写入 out，返回导出条数
+func exportMessages(ctx context.Context, store *storage.Storage, filter storage.MessageFilter, format string, out io.Writer) (int, error) {
+	w := bufio.NewWriter(out)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	cw := csv.NewWriter(w)
+	if format == "csv" {
+		if err := cw.Write(exportCSVHeader); err != nil {
+			return 0, err
+		}
+	}
+
+	count := 0
+	err := store.ExportMessages(ctx, filter, func(msg *storage.Message) error {
+		count++
+		if format == "csv" {
+			return cw.Write([]string{
+				strconv.FormatInt(msg.ID, 10), msg.AppID, msg.TenantKey, msg.ChatID, msg.MessageID,
+				msg.SenderID, msg.SenderType, msg.Content, msg.MessageType, msg.CreatedAt.Format(time.RFC3339),
+			})
+		}
+		return enc.Encode(msg)
+	})
+	if err != nil {
+		return count, err
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return count, err
+	}
+	return count, w.Flush()
+}
+
+// parseSince 解析起始时间，支持 RFC3339、日期（本地时区）和相对于 now 的时长
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("无效的起始时间: %s", s)
+}
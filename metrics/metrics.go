@@ -0,0 +1,186 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "fin_bot"
+
+var (
+	// EventsReceived 按事件类型统计收到的飞书事件数
+	EventsReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_received_total",
+		Help:      "收到的飞书事件数量（按事件类型）",
+	}, []string{"event_type"})
+
+	// MessagesStored 成功写入数据库的消息数
+	MessagesStored = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_stored_total",
+		Help:      "成功保存到数据库的消息数量",
+	})
+
+	// CommandsExecuted 按命令和结果统计执行的机器人命令数
+	CommandsExecuted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "commands_executed_total",
+		Help:      "执行的机器人命令数量（按命令和结果）",
+	}, []string{"command", "result"})
+
+	// HandlerDuration 事件处理耗时
+	HandlerDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "handler_duration_seconds",
+		Help:      "事件处理耗时（按事件类型）",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"event_type"})
+
+	// LarkAPIDuration 飞书开放平台接口调用耗时
+	LarkAPIDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "lark_api_duration_seconds",
+		Help:      "飞书 OpenAPI 调用耗时（按接口和结果）",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"endpoint", "result"})
+
+	// BroadcastResults 群发消息的成功/失败次数
+	BroadcastResults = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "broadcast_messages_total",
+		Help:      "群发消息的发送结果（success/failed）",
+	}, []string{"result"})
+
+	// DBQueryDuration 数据库操作耗时
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "数据库操作耗时（按操作和结果）",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"operation", "result"})
+
+	// WSConnected 各应用的 WebSocket 连接状态（1 已连接，0 未连接）
+	WSConnected = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "ws_connected",
+		Help:      "WebSocket 长连接状态（1 已连接，0 未连接，按应用）",
+	}, []string{"app"})
+
+	// WSReconnects 各应用的 WebSocket 重连次数
+	WSReconnects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ws_reconnects_total",
+		Help:      "WebSocket 长连接重连次数（按应用）",
+	}, []string{"app"})
+
+	// ConfigReloads 配置热加载次数（success/unchanged/error）
+	ConfigReloads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "config_reloads_total",
+		Help:      "配置热加载次数（按结果）",
+	}, []string{"result"})
+
+	// Backups 数据库备份次数（success/error）
+	Backups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "backups_total",
+		Help:      "数据库备份次数（按结果）",
+	}, []string{"result"})
+
+	// BackupLastSuccess 最近一次备份成功的时间
+	BackupLastSuccess = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "backup_last_success_timestamp_seconds",
+		Help:      "最近一次数据库备份成功的时间（Unix 时间戳）",
+	})
+
+	// Reencrypted 后台任务重新加密（或解密为明文）的消息数
+	Reencrypted = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reencrypted_messages_total",
+		Help:      "后台任务重新加密的消息数量",
+	})
+
+	// ReencryptFailed 重新加密时因无法解密而跳过的消息数
+	ReencryptFailed = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reencrypt_failed_messages_total",
+		Help:      "重新加密时因密钥缺失或数据损坏而跳过的消息数量",
+	})
+
+	// ReencryptPending 等待重新加密的消息数
+	ReencryptPending = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "reencrypt_pending_messages",
+		Help:      "密钥与当前设置不一致、等待重新加密的消息数量",
+	})
+
+	// RateLimited 被限流或封禁而未处理的消息数
+	RateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_messages_total",
+		Help:      "被限流而未处理的消息数量（按原因 user/chat/global/banned）",
+	}, []string{"reason"})
+
+	// MarketDataDuration 行情来源的调用耗时
+	MarketDataDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "marketdata_duration_seconds",
+		Help:      "行情来源的调用耗时（按来源、操作和结果）",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"provider", "operation", "result"})
+
+	// MarketDataCache 行情缓存的命中次数
+	MarketDataCache = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "marketdata_cache_total",
+		Help:      "行情缓存的查询次数（按操作和结果 hit/miss）",
+	}, []string{"operation", "result"})
+
+	// AlertsTriggered 价格和指标提醒的触发次数
+	AlertsTriggered = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "alerts_triggered_total",
+		Help:      "价格和指标提醒的触发次数（按指标和投递结果 delivered/failed）",
+	}, []string{"metric", "result"})
+
+	// QueueDepth 各内部队列当前积压的任务数
+	QueueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "queue_depth",
+		Help:      "内部队列当前积压的任务数（按队列名）",
+	}, []string{"queue"})
+)
+
+// Handler 返回 Prometheus 指标的 HTTP 处理器
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveLarkAPI 记录一次飞书接口调用的耗时和结果
+func ObserveLarkAPI(endpoint string, start time.Time, err error) {
+	LarkAPIDuration.WithLabelValues(endpoint, result(err)).Observe(time.Since(start).Seconds())
+}
+
+// ObserveDBQuery 记录一次数据库操作的耗时和结果
+func ObserveDBQuery(operation string, start time.Time, err error) {
+	DBQueryDuration.WithLabelValues(operation, result(err)).Observe(time.Since(start).Seconds())
+}
+
+// ObserveMarketData 记录一次行情来源调用的耗时和结果
+func ObserveMarketData(provider, operation string, start time.Time, err error) {
+	MarketDataDuration.WithLabelValues(provider, operation, result(err)).Observe(time.Since(start).Seconds())
+}
+
+// result 将错误转换为指标标签值
+func result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
@@ -0,0 +1,74 @@
+package progress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"fin_bot/command"
+	"fin_bot/storage"
+)
+
+// Commands 返回学习进度的聊天命令（所有成员可用）
+func (t *Tracker) Commands() []*command.Command {
+	return []*command.Command{
+		{
+			Name:         "me",
+			Usage:        "/me | /me timezone <时区|default>",
+			Description:  "查看自己的经验值、连续学习天数和徽章，或设置计算学习天数的时区",
+			ReplyHandler: t.commandMe,
+		},
+		{
+			Name:         "leaderboard",
+			Usage:        "/leaderboard [week|all]",
+			Description:  "查看本群本周或全部时间的学习排行榜",
+			ReplyHandler: t.commandLeaderboard,
+		},
+	}
+}
+
+// commandMe 处理 /me 命令
+func (t *Tracker) commandMe(ctx context.Context, req *command.Request) (*command.Reply, error) {
+	scope := storage.Scope{AppID: req.AppID, TenantKey: req.TenantKey}
+	switch {
+	case len(req.Args) == 0:
+	case len(req.Args) == 2 && strings.EqualFold(req.Args[0], "timezone"):
+		tz := req.Args[1]
+		if strings.EqualFold(tz, "default") {
+			tz = ""
+		}
+		if err := t.SetTimezone(ctx, scope, req.SenderID, tz); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("用法: /me | /me timezone <时区|default>")
+	}
+
+	p, err := t.Profile(ctx, scope, req.SenderID)
+	if err != nil {
+		return nil, err
+	}
+	return command.CardReply(profileCard(p))
+}
+
+// commandLeaderboard 处理 /leaderboard 命令
+func (t *Tracker) commandLeaderboard(ctx context.Context, req *command.Request) (*command.Reply, error) {
+	if req.ChatType != "group" {
+		return nil, errors.New("排行榜只能在群聊中查看")
+	}
+	window := WindowWeek
+	if len(req.Args) > 0 {
+		window = Window(strings.ToLower(req.Args[0]))
+	}
+	if len(req.Args) > 1 || (window != WindowWeek && window != WindowAll) {
+		return nil, fmt.Errorf("无效的参数: %s（应为 week 或 all）", req.RawArgs)
+	}
+
+	scope := storage.Scope{AppID: req.AppID, TenantKey: req.TenantKey}
+	rankings, since, err := t.Leaderboard(ctx, scope, req.ChatID, window)
+	if err != nil {
+		return nil, err
+	}
+	return command.CardReply(leaderboardCard(rankings, window, since))
+}
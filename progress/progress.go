@@ -0,0 +1,178 @@
+package progress
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"fin_bot/storage"
+)
+
+// Window 排行榜的统计时间范围
+type Window string
+
+const (
+	WindowWeek Window = "week" // 本周（默认时区的周一零点开始）
+	WindowAll  Window = "all"  // 全部
+)
+
+// Settings 可以在运行中修改的学习进度设置
+type Settings struct {
+	Timezone        string // 默认时区（用户没有设置时区时计算连续学习天数，以及排行榜每周的起始时间）
+	LeaderboardSize int    // 排行榜显示的人数
+}
+
+// Tracker 学习进度：记录学习事件，由学习事件计算经验值、等级、连续学习天数、徽章和排行榜
+// 学习事件按应用、租户和用户隔离；为 nil 时 Record 不做任何事（例如命令行导入时创建的课程、测验和记忆卡片服务）
+type Tracker struct {
+	store *storage.Storage
+	now   func() time.Time
+
+	settingsMu sync.RWMutex // 保护 settings 和 loc，配置热加载时会被修改
+	settings   Settings
+	loc        *time.Location
+}
+
+// New 创建学习进度服务
+func New(store *storage.Storage, settings Settings) *Tracker {
+	t := &Tracker{store: store, now: time.Now}
+	t.SetSettings(settings)
+	return t
+}
+
+// SetSettings 修改学习进度设置，时区无效时使用 UTC（配置校验保证时区有效）
+func (t *Tracker) SetSettings(settings Settings) {
+	loc, err := time.LoadLocation(settings.Timezone)
+	if err != nil {
+		log.Printf("[progress] 无效的时区 %q，使用 UTC: %v", settings.Timezone, err)
+		loc = time.UTC
+	}
+	t.settingsMu.Lock()
+	t.settings = settings
+	t.loc = loc
+	t.settingsMu.Unlock()
+}
+
+// currentSettings 获取当前的学习进度设置和默认时区
+func (t *Tracker) currentSettings() (Settings, *time.Location) {
+	t.settingsMu.RLock()
+	defer t.settingsMu.RUnlock()
+	return t.settings, t.loc
+}
+
+// Record 记录一条学习事件（没有时间时使用当前时间），写入失败只记录运行日志，不影响学习本身
+func (t *Tracker) Record(ctx context.Context, ev *storage.ProgressEvent) {
+	if t == nil {
+		return
+	}
+	if ev.OccurredAt.IsZero() {
+		ev.OccurredAt = t.now()
+	}
+	// 使用独立的 context，调用方取消时学习事件仍然写入
+	if _, err := t.store.AppendProgressEvent(context.WithoutCancel(ctx), ev); err != nil {
+		log.Printf("[progress] 记录学习事件失败: kind=%s, ref=%s, user=%s, error=%v", ev.Kind, ev.Ref, ev.UserID, err)
+	}
+}
+
+// Rebuild 由课程、测验和记忆卡片的记录重新生成所有学习事件，返回每种事件的数量
+func (t *Tracker) Rebuild(ctx context.Context) (map[string]int, error) {
+	return t.store.RebuildProgressEvents(ctx)
+}
+
+// Profile 计算用户的学习进度
+func (t *Tracker) Profile(ctx context.Context, scope storage.Scope, userID string) (*Profile, error) {
+	loc, err := t.userLocation(ctx, scope, userID)
+	if err != nil {
+		return nil, err
+	}
+	events, err := t.store.ListProgressEvents(ctx, scope, userID)
+	if err != nil {
+		return nil, err
+	}
+	now := t.now()
+	return buildProfile(userID, events, now, loc, weekStart(now, loc)), nil
+}
+
+// SetTimezone 设置用户计算连续学习天数使用的时区，tz 为空时恢复默认时区
+func (t *Tracker) SetTimezone(ctx context.Context, scope storage.Scope, userID, tz string) error {
+	if tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return fmt.Errorf("无效的时区: %s（例如 Asia/Shanghai）", tz)
+		}
+		tz = loc.String()
+	}
+	return t.store.SetProgressTimezone(ctx, scope, userID, tz)
+}
+
+// userLocation 用户设置的时区，没有设置或设置已失效时使用默认时区
+func (t *Tracker) userLocation(ctx context.Context, scope storage.Scope, userID string) (*time.Location, error) {
+	_, loc := t.currentSettings()
+	tz, err := t.store.GetProgressTimezone(ctx, scope, userID)
+	if err != nil || tz == "" {
+		return loc, err
+	}
+	if userLoc, err := time.LoadLocation(tz); err == nil {
+		return userLoc, nil
+	}
+	return loc, nil
+}
+
+// Ranking 排行榜中的一名用户
+type Ranking struct {
+	Rank   int
+	UserID string
+	XP     int
+}
+
+// Leaderboard 群排行榜：群成员在时间范围内获得的经验值，从高到低排列（相同时先达到的在前），没有经验值的成员不上榜
+// 返回的排名最多 LeaderboardSize 名，以及时间范围的起点（全部时为零值）
+func (t *Tracker) Leaderboard(ctx context.Context, scope storage.Scope, chatID string, window Window) ([]*Ranking, time.Time, error) {
+	settings, loc := t.currentSettings()
+	var since time.Time
+	if window == WindowWeek {
+		since = weekStart(t.now(), loc)
+	}
+	events, err := t.store.ListChatProgressEvents(ctx, scope, chatID, since)
+	if err != nil {
+		return nil, since, err
+	}
+
+	byUser := make(map[string]*Ranking)
+	reached := make(map[string]time.Time) // 最后一次获得经验值的时间
+	var rankings []*Ranking
+	for _, ev := range events {
+		xp := XP(ev)
+		if xp == 0 {
+			continue
+		}
+		r, ok := byUser[ev.UserID]
+		if !ok {
+			r = &Ranking{UserID: ev.UserID}
+			byUser[ev.UserID] = r
+			rankings = append(rankings, r)
+		}
+		r.XP += xp
+		reached[ev.UserID] = ev.OccurredAt
+	}
+	sort.SliceStable(rankings, func(i, j int) bool {
+		if rankings[i].XP != rankings[j].XP {
+			return rankings[i].XP > rankings[j].XP
+		}
+		return reached[rankings[i].UserID].Before(reached[rankings[j].UserID])
+	})
+
+	for i, r := range rankings {
+		r.Rank = i + 1
+		if i > 0 && r.XP == rankings[i-1].XP {
+			r.Rank = rankings[i-1].Rank
+		}
+	}
+	if len(rankings) > settings.LeaderboardSize {
+		rankings = rankings[:settings.LeaderboardSize]
+	}
+	return rankings, since, nil
+}
@@ -0,0 +1,83 @@
+package progress
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"fin_bot/card"
+)
+
+// medals 排行榜前三名的图标
+var medals = []string{"🥇", "🥈", "🥉"}
+
+// profileCard 用户的学习进度卡片
+func profileCard(p *Profile) *card.Card {
+	cd := card.New("我的学习进度", card.ColorBlue)
+	cd.Add(card.Markdown(fmt.Sprintf("**Lv.%d** · 累计 %d 经验值（距 Lv.%d 还差 %d）\n本周获得 %d 经验值",
+		p.Level, p.XP, p.Level+1, p.NextLevel-p.XP, p.WeekXP)))
+
+	streak := fmt.Sprintf("🔥 连续学习 **%d** 天 · 最长 %d 天 · 共学习 %d 天", p.Streak, p.LongestStreak, p.ActiveDays)
+	switch {
+	case p.StudiedToday:
+		streak += "\n今天已经学习过了"
+	case p.Streak > 0:
+		streak += "\n今天还没有学习，学习一次保持连续天数"
+	}
+	cd.Add(card.Markdown(streak))
+
+	cd.Add(card.Divider())
+	cd.Add(card.Markdown(fmt.Sprintf("📖 学完 %d 节课 · %d 门课程\n📝 完成 %d 次测验 · 满分 %d 次\n🧠 复习 %d 张记忆卡片",
+		p.Lessons, p.Courses, p.Quizzes, p.PerfectQuizzes, p.Reviews)))
+
+	cd.Add(card.Divider())
+	lines := []string{fmt.Sprintf("**徽章 %d/%d**", len(p.Badges), len(Badges))}
+	for _, b := range p.Badges {
+		lines = append(lines, fmt.Sprintf("%s %s · %s（%s）", b.Icon, b.Name, b.Description, b.EarnedAt.In(p.Location).Format("2006-01-02")))
+	}
+	var locked []string
+	for _, b := range Badges {
+		if !p.Has(b.ID) {
+			locked = append(locked, fmt.Sprintf("%s（%s）", b.Name, b.Description))
+		}
+	}
+	if len(locked) > 0 {
+		lines = append(lines, "未获得: "+strings.Join(locked, "、"))
+	}
+	cd.Add(card.Markdown(strings.Join(lines, "\n")))
+
+	note := fmt.Sprintf("学习天数按 %s 时区计算，发送 /me timezone <时区> 修改", p.Location)
+	if p.LastActiveAt == nil {
+		note = "学习课程（/next）、完成测验（/quiz）或复习记忆卡片（/review）获得经验值 · " + note
+	}
+	return cd.Add(card.Note(note))
+}
+
+// leaderboardCard 群排行榜
+func leaderboardCard(rankings []*Ranking, window Window, since time.Time) *card.Card {
+	title, other := "学习排行榜 · 本周", "/leaderboard all 查看总榜"
+	if window == WindowAll {
+		title, other = "学习排行榜 · 总榜", "/leaderboard 查看本周排行"
+	}
+	cd := card.New(title, card.ColorOrange)
+
+	if len(rankings) == 0 {
+		cd.Add(card.Markdown("还没有人获得经验值"))
+	} else {
+		lines := make([]string, 0, len(rankings))
+		for _, r := range rankings {
+			rank := fmt.Sprintf("%d.", r.Rank)
+			if r.Rank <= len(medals) {
+				rank = medals[r.Rank-1]
+			}
+			lines = append(lines, fmt.Sprintf("%s <at id=%s></at> %d 经验值", rank, r.UserID, r.XP))
+		}
+		cd.Add(card.Markdown(strings.Join(lines, "\n")))
+	}
+
+	note := "统计在本群发过言的成员 · " + other
+	if !since.IsZero() {
+		note = fmt.Sprintf("从 %s 开始统计，", since.Format("2006-01-02 15:04 MST")) + note
+	}
+	return cd.Add(card.Note(note))
+}
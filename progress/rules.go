@@ -0,0 +1,201 @@
+package progress
+
+import (
+	"slices"
+	"sort"
+	"time"
+
+	"fin_bot/storage"
+)
+
+// 各类学习事件获得的经验值
+const (
+	xpLesson      = 10 // 学完一节课
+	xpCourse      = 50 // 学完一门课程
+	xpQuizCorrect = 2  // 测验中每答对一题
+	xpQuizPerfect = 10 // 测验全部答对的奖励
+	xpReview      = 1  // 复习一张卡片
+	// xpLevelStep 升到第 n+1 级需要比第 n 级多 n*xpLevelStep 经验值（100、300、600……）
+	xpLevelStep = 100
+)
+
+// XP 一条学习事件获得的经验值，规则修改后所有用户的经验值按新规则重新计算
+func XP(ev *storage.ProgressEvent) int {
+	switch ev.Kind {
+	case storage.ProgressLessonCompleted:
+		return xpLesson
+	case storage.ProgressCourseCompleted:
+		return xpCourse
+	case storage.ProgressQuizFinished:
+		xp := ev.Score * xpQuizCorrect
+		if perfect(ev) {
+			xp += xpQuizPerfect
+		}
+		return xp
+	case storage.ProgressCardReviewed:
+		return xpReview
+	}
+	return 0
+}
+
+// perfect 测验是否全部答对
+func perfect(ev *storage.ProgressEvent) bool {
+	return ev.Kind == storage.ProgressQuizFinished && ev.Total > 0 && ev.Score >= ev.Total
+}
+
+// Level 经验值对应的等级（从 1 开始），以及当前等级和下一等级的起点经验值
+func Level(xp int) (level, floor, next int) {
+	level, floor, next = 1, 0, xpLevelStep
+	for xp >= next {
+		level++
+		floor = next
+		next += level * xpLevelStep
+	}
+	return level, floor, next
+}
+
+// Badge 徽章，获得条件由学习事件累计的统计数据判断
+type Badge struct {
+	ID          string
+	Icon        string
+	Name        string
+	Description string
+	earned      func(p *Profile) bool
+}
+
+// Badges 所有徽章（按显示顺序）
+var Badges = []*Badge{
+	{ID: "first_lesson", Icon: "📖", Name: "初窥门径", Description: "学完第一节课",
+		earned: func(p *Profile) bool { return p.Lessons >= 1 }},
+	{ID: "course_graduate", Icon: "🎓", Name: "结业", Description: "学完一门课程",
+		earned: func(p *Profile) bool { return p.Courses >= 1 }},
+	{ID: "first_perfect_quiz", Icon: "💯", Name: "满分", Description: "第一次在测验中全部答对",
+		earned: func(p *Profile) bool { return p.PerfectQuizzes >= 1 }},
+	{ID: "quizzes_10", Icon: "📝", Name: "题海", Description: "完成 10 次测验",
+		earned: func(p *Profile) bool { return p.Quizzes >= 10 }},
+	{ID: "reviews_100", Icon: "🧠", Name: "过目不忘", Description: "复习 100 张记忆卡片",
+		earned: func(p *Profile) bool { return p.Reviews >= 100 }},
+	{ID: "streak_7", Icon: "🔥", Name: "一周不断", Description: "连续学习 7 天",
+		earned: func(p *Profile) bool { return p.Streak >= 7 }},
+	{ID: "streak_30", Icon: "🏆", Name: "月度坚持", Description: "连续学习 30 天",
+		earned: func(p *Profile) bool { return p.Streak >= 30 }},
+	{ID: "xp_1000", Icon: "⭐", Name: "千分达人", Description: "累计获得 1000 经验值",
+		earned: func(p *Profile) bool { return p.XP >= 1000 }},
+}
+
+// EarnedBadge 用户获得的徽章和获得时间（达成条件的学习事件的时间）
+type EarnedBadge struct {
+	*Badge
+	EarnedAt time.Time
+}
+
+// Profile 用户的学习进度，由学习事件按时间顺序累计得到
+type Profile struct {
+	UserID   string
+	Location *time.Location // 计算连续学习天数使用的时区
+
+	XP     int
+	WeekXP int // 本周（周一零点开始）获得的经验值
+	Level  int
+	// LevelFloor、NextLevel 当前等级和下一等级的起点经验值
+	LevelFloor int
+	NextLevel  int
+
+	Streak        int // 连续学习天数，今天和昨天都没有学习时为 0
+	LongestStreak int
+	StudiedToday  bool
+	ActiveDays    int
+
+	Lessons        int
+	Courses        int
+	Quizzes        int
+	PerfectQuizzes int
+	Reviews        int
+
+	Badges       []EarnedBadge
+	LastActiveAt *time.Time
+}
+
+// Has 是否已获得徽章
+func (p *Profile) Has(id string) bool {
+	for _, b := range p.Badges {
+		if b.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// buildProfile 按时间顺序累计学习事件：经验值、连续学习天数（按 loc 时区的日期计算）和徽章
+// 连续天数、徽章的获得时间和最近学习时间依赖事件的先后，events 先按 OccurredAt 排序（不修改传入的切片）
+func buildProfile(userID string, events []*storage.ProgressEvent, now time.Time, loc *time.Location, weekStart time.Time) *Profile {
+	events = slices.Clone(events)
+	sort.SliceStable(events, func(i, j int) bool { return events[i].OccurredAt.Before(events[j].OccurredAt) })
+
+	p := &Profile{UserID: userID, Location: loc}
+	var lastDay time.Time
+	for _, ev := range events {
+		xp := XP(ev)
+		p.XP += xp
+		if !ev.OccurredAt.Before(weekStart) {
+			p.WeekXP += xp
+		}
+		switch ev.Kind {
+		case storage.ProgressLessonCompleted:
+			p.Lessons++
+		case storage.ProgressCourseCompleted:
+			p.Courses++
+		case storage.ProgressQuizFinished:
+			p.Quizzes++
+			if perfect(ev) {
+				p.PerfectQuizzes++
+			}
+		case storage.ProgressCardReviewed:
+			p.Reviews++
+		}
+
+		day := date(ev.OccurredAt, loc)
+		switch {
+		case lastDay.IsZero() || day.After(lastDay.AddDate(0, 0, 1)):
+			p.Streak = 1
+			p.ActiveDays++
+		case day.Equal(lastDay.AddDate(0, 0, 1)):
+			p.Streak++
+			p.ActiveDays++
+		}
+		if day.After(lastDay) {
+			lastDay = day
+		}
+		p.LongestStreak = max(p.LongestStreak, p.Streak)
+
+		for _, b := range Badges {
+			if !p.Has(b.ID) && b.earned(p) {
+				p.Badges = append(p.Badges, EarnedBadge{Badge: b, EarnedAt: ev.OccurredAt})
+			}
+		}
+		at := ev.OccurredAt
+		p.LastActiveAt = &at
+	}
+
+	// 连续学习到昨天的用户今天还有机会保持，更早中断的连续天数清零
+	today := date(now, loc)
+	p.StudiedToday = !lastDay.IsZero() && lastDay.Equal(today)
+	if lastDay.IsZero() || lastDay.Before(today.AddDate(0, 0, -1)) {
+		p.Streak = 0
+	}
+	p.Level, p.LevelFloor, p.NextLevel = Level(p.XP)
+	return p
+}
+
+// date t 在 loc 时区中的日期（以 UTC 零点表示，便于按天比较和加减）
+func date(t time.Time, loc *time.Location) time.Time {
+	local := t.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// weekStart t 所在周的周一零点（loc 时区）
+func weekStart(t time.Time, loc *time.Location) time.Time {
+	local := t.In(loc)
+	offset := (int(local.Weekday()) + 6) % 7
+	return time.Date(local.Year(), local.Month(), local.Day()-offset, 0, 0, 0, 0, loc)
+}
@@ -0,0 +1,163 @@
+package progress
+
+import (
+	"testing"
+	"time"
+
+	"fin_bot/storage"
+)
+
+var shanghai = time.FixedZone("CST", 8*3600)
+
+// at 上海时区 2026 年 10 月的某个时刻（10 月 19 日为周一）
+func at(day, hour, minute, second int) time.Time {
+	return time.Date(2026, 10, day, hour, minute, second, 0, shanghai)
+}
+
+// lessons 在给定时刻各学完一节课的学习事件
+func lessons(times ...time.Time) []*storage.ProgressEvent {
+	events := make([]*storage.ProgressEvent, len(times))
+	for i, t := range times {
+		events[i] = &storage.ProgressEvent{Kind: storage.ProgressLessonCompleted, OccurredAt: t.UTC()}
+	}
+	return events
+}
+
+func TestDate(t *testing.T) {
+	tests := []struct {
+		name string
+		t    time.Time
+		loc  *time.Location
+		want time.Time
+	}{
+		{"本地午夜前", time.Date(2026, 10, 18, 15, 59, 59, 0, time.UTC), shanghai, time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{"本地午夜", time.Date(2026, 10, 18, 16, 0, 0, 0, time.UTC), shanghai, time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)},
+		{"UTC 时区", time.Date(2026, 10, 18, 16, 0, 0, 0, time.UTC), time.UTC, time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{"西半球时区", time.Date(2026, 10, 19, 3, 0, 0, 0, time.UTC), time.FixedZone("EST", -5*3600), time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := date(tt.t, tt.loc); !got.Equal(tt.want) {
+				t.Errorf("date(%v) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWeekStart(t *testing.T) {
+	tests := []struct {
+		name string
+		t    time.Time
+		want time.Time
+	}{
+		{"周一零点", at(19, 0, 0, 0), at(19, 0, 0, 0)},
+		{"周一零点之后在 UTC 仍是周日", time.Date(2026, 10, 18, 16, 30, 0, 0, time.UTC), at(19, 0, 0, 0)},
+		{"周三", at(21, 10, 0, 0), at(19, 0, 0, 0)},
+		{"周日深夜属于上一周", at(18, 23, 59, 59), at(12, 0, 0, 0)},
+		{"跨月", time.Date(2026, 11, 1, 12, 0, 0, 0, shanghai), at(26, 0, 0, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := weekStart(tt.t, shanghai); !got.Equal(tt.want) {
+				t.Errorf("weekStart(%v) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildProfileStreak(t *testing.T) {
+	now := at(21, 10, 0, 0)
+	tests := []struct {
+		name         string
+		events       []*storage.ProgressEvent
+		wantStreak   int
+		wantLongest  int
+		wantDays     int
+		studiedToday bool
+	}{
+		{"没有学习", nil, 0, 0, 0, false},
+		{"今天和昨天都学习", lessons(at(20, 9, 0, 0), at(21, 8, 0, 0)), 2, 2, 2, true},
+		{"学到昨天的连续天数保留到今天结束", lessons(at(19, 9, 0, 0), at(20, 23, 0, 0)), 2, 2, 2, false},
+		{"前天之后没有学习时清零", lessons(at(18, 9, 0, 0), at(19, 9, 0, 0)), 0, 2, 2, false},
+		{"跳过一天后重新计算", lessons(at(16, 9, 0, 0), at(17, 9, 0, 0), at(18, 9, 0, 0), at(20, 9, 0, 0), at(21, 9, 0, 0)), 2, 3, 5, true},
+		{"本地午夜前后算两天", lessons(at(20, 23, 59, 59), at(21, 0, 0, 0)), 2, 2, 2, true},
+		{"同一天多次学习只算一天", lessons(at(21, 0, 0, 0), at(21, 9, 59, 59)), 1, 1, 1, true},
+		{"事件乱序", lessons(at(21, 9, 0, 0), at(19, 9, 0, 0), at(20, 9, 0, 0)), 3, 3, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := buildProfile("ou_1", tt.events, now, shanghai, weekStart(now, shanghai))
+			if p.Streak != tt.wantStreak || p.LongestStreak != tt.wantLongest || p.ActiveDays != tt.wantDays || p.StudiedToday != tt.studiedToday {
+				t.Errorf("streak=%d longest=%d days=%d today=%t, want %d %d %d %t",
+					p.Streak, p.LongestStreak, p.ActiveDays, p.StudiedToday,
+					tt.wantStreak, tt.wantLongest, tt.wantDays, tt.studiedToday)
+			}
+		})
+	}
+}
+
+func TestBuildProfile(t *testing.T) {
+	now := at(21, 10, 0, 0)
+	// 传入的事件乱序，最早的一节课获得「初窥门径」
+	events := []*storage.ProgressEvent{
+		{Kind: storage.ProgressQuizFinished, Score: 5, Total: 5, OccurredAt: at(20, 9, 0, 0).UTC()},
+		{Kind: storage.ProgressLessonCompleted, OccurredAt: at(19, 0, 0, 0).UTC()},
+		{Kind: storage.ProgressLessonCompleted, OccurredAt: at(18, 23, 59, 59).UTC()},
+		{Kind: storage.ProgressCardReviewed, OccurredAt: at(21, 8, 0, 0).UTC()},
+	}
+	first := events[0]
+
+	p := buildProfile("ou_1", events, now, shanghai, weekStart(now, shanghai))
+	if events[0] != first {
+		t.Error("buildProfile 不应修改传入的切片")
+	}
+	if want := xpLesson*2 + 5*xpQuizCorrect + xpQuizPerfect + xpReview; p.XP != want {
+		t.Errorf("XP = %d, want %d", p.XP, want)
+	}
+	// 周日 23:59:59 的课不计入本周
+	if want := xpLesson + 5*xpQuizCorrect + xpQuizPerfect + xpReview; p.WeekXP != want {
+		t.Errorf("WeekXP = %d, want %d", p.WeekXP, want)
+	}
+	if p.Lessons != 2 || p.Quizzes != 1 || p.PerfectQuizzes != 1 || p.Reviews != 1 {
+		t.Errorf("lessons=%d quizzes=%d perfect=%d reviews=%d", p.Lessons, p.Quizzes, p.PerfectQuizzes, p.Reviews)
+	}
+	if p.Streak != 4 {
+		t.Errorf("Streak = %d, want 4", p.Streak)
+	}
+	if p.LastActiveAt == nil || !p.LastActiveAt.Equal(at(21, 8, 0, 0)) {
+		t.Errorf("LastActiveAt = %v, want %v", p.LastActiveAt, at(21, 8, 0, 0))
+	}
+	earned := make(map[string]time.Time)
+	for _, b := range p.Badges {
+		earned[b.ID] = b.EarnedAt
+	}
+	if got := earned["first_lesson"]; !got.Equal(at(18, 23, 59, 59)) {
+		t.Errorf("first_lesson earned at %v, want %v", got, at(18, 23, 59, 59))
+	}
+	if got := earned["first_perfect_quiz"]; !got.Equal(at(20, 9, 0, 0)) {
+		t.Errorf("first_perfect_quiz earned at %v, want %v", got, at(20, 9, 0, 0))
+	}
+	if len(p.Badges) != 2 {
+		t.Errorf("badges = %d, want 2", len(p.Badges))
+	}
+}
+
+func TestLevel(t *testing.T) {
+	tests := []struct {
+		xp                     int
+		wantLevel, floor, next int
+	}{
+		{0, 1, 0, 100},
+		{99, 1, 0, 100},
+		{100, 2, 100, 300},
+		{299, 2, 100, 300},
+		{300, 3, 300, 600},
+		{600, 4, 600, 1000},
+	}
+	for _, tt := range tests {
+		level, floor, next := Level(tt.xp)
+		if level != tt.wantLevel || floor != tt.floor || next != tt.next {
+			t.Errorf("Level(%d) = %d, %d, %d, want %d, %d, %d", tt.xp, level, floor, next, tt.wantLevel, tt.floor, tt.next)
+		}
+	}
+}
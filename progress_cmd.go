@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"fin_bot/config"
+	"fin_bot/progress"
+	"fin_bot/storage"
+)
+
+// progressKindLabels 学习事件类型的中文名称
+var progressKindLabels = map[string]string{
+	storage.ProgressLessonCompleted: "学完课",
+	storage.ProgressCourseCompleted: "学完课程",
+	storage.ProgressQuizFinished:    "完成测验",
+	storage.ProgressCardReviewed:    "复习卡片",
+}
+
+// runProgressRebuildCommand 由课程、测验和记忆卡片的记录重新生成学习事件
+// 用于升级后补充之前的学习记录，或学习事件记录失败之后修复
+func runProgressRebuildCommand(configPath string, args []string) int {
+	fs := newCLIFlags("progress rebuild", "[-db 数据库] [-format text|json]")
+	dbPath := fs.String("db", "", "数据库文件路径（默认使用配置中的 database.path）")
+	format := fs.String("format", "text", "输出格式: text 或 json")
+	fs.logFlag()
+	if code := fs.parse(args); code != exitOK {
+		return code
+	}
+	if *format != "text" && *format != "json" {
+		return fs.usageError("无效的输出格式: %s", *format)
+	}
+
+	cfg := loadConfig(configPath, false)
+	if cfg == nil {
+		return exitError
+	}
+	store, err := openStorage(cfg, *dbPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "打开数据库失败: %v\n", err)
+		return exitError
+	}
+	defer store.Close()
+
+	counts, err := progress.New(store, progressSettings(cfg.Progress)).Rebuild(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "重建学习事件失败: %v\n", err)
+		return exitError
+	}
+
+	if *format == "json" {
+		if err := json.NewEncoder(os.Stdout).Encode(counts); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+		return exitOK
+	}
+	total := 0
+	for _, kind := range storage.ProgressKinds {
+		fmt.Printf("%s\t%s\t%d\n", kind, progressKindLabels[kind], counts[kind])
+		total += counts[kind]
+	}
+	fmt.Fprintf(os.Stderr, "已重建 %d 条学习事件\n", total)
+	return exitOK
+}
+
+// progressSettings 将配置转换为学习进度设置
+func progressSettings(c config.ProgressConfig) progress.Settings {
+	return progress.Settings{
+		Timezone:        c.Timezone,
+		LeaderboardSize: c.LeaderboardSize,
+	}
+}
@@ -0,0 +1,545 @@
+package quiz
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"fin_bot/audit"
+	"fin_bot/card"
+	"fin_bot/service"
+	"fin_bot/storage"
+)
+
+// Scoring 答题赛的计分方式
+type Scoring string
+
+const (
+	ScoringFirst Scoring = "first" // 每题只有第一个答对的人得分，有人答对后立即结束这道题
+	ScoringAll   Scoring = "all"   // 限时内答对的人都得分，时间到后结束这道题
+)
+
+// scoringLabels 计分方式的说明
+var scoringLabels = map[Scoring]string{
+	ScoringFirst: "先答对者得分",
+	ScoringAll:   "限时内答对都得分",
+}
+
+const (
+	// battleGap 一道题结束后到下一道题出现的间隔，留出时间看正确答案
+	battleGap = 3 * time.Second
+	// scoreboardDelay 合并这段时间内的作答再更新计分板，避免超过同一条消息的更新频率限制
+	scoreboardDelay = time.Second
+)
+
+var (
+	// ErrBattleRunning 群里已有进行中的答题赛
+	ErrBattleRunning = errors.New("本群已有进行中的答题赛，发送 /quizbattle stop 结束")
+	// ErrNoBattle 群里没有进行中的答题赛
+	ErrNoBattle = errors.New("本群没有进行中的答题赛")
+)
+
+// BattleSettings 可以在运行中修改的答题赛设置，只影响之后开始的答题赛
+type BattleSettings struct {
+	Timeout      time.Duration // 每道题的作答时间
+	MaxQuestions int           // 一场答题赛最多的题数
+}
+
+// battleKey 答题赛所在的群（每个群同时只有一场答题赛）
+type battleKey struct {
+	appID, tenantKey, chatID string
+}
+
+// Arena 群聊答题赛：管理员发起后机器人依次发出题目卡片，群成员通过按钮或直接回复作答，
+// 计分板卡片通过更新消息接口原地更新，全部题目结束后发出最终排名
+// 答题赛的状态只保存在内存中，服务重启后中断
+type Arena struct {
+	quizzes *Service
+	apps    *service.AppRegistry // 发送和更新卡片
+
+	settingsMu sync.RWMutex
+	settings   BattleSettings
+
+	mu      sync.Mutex // 保护 battles，不在持有时调用答题赛的方法
+	battles map[battleKey]*Battle
+}
+
+// NewArena 创建答题赛管理
+func NewArena(quizzes *Service, apps *service.AppRegistry, settings BattleSettings) *Arena {
+	return &Arena{quizzes: quizzes, apps: apps, settings: settings, battles: make(map[battleKey]*Battle)}
+}
+
+// SetSettings 修改答题赛设置，进行中的答题赛不受影响
+func (a *Arena) SetSettings(settings BattleSettings) {
+	a.settingsMu.Lock()
+	a.settings = settings
+	a.settingsMu.Unlock()
+}
+
+// currentSettings 获取当前的答题赛设置
+func (a *Arena) currentSettings() BattleSettings {
+	a.settingsMu.RLock()
+	defer a.settingsMu.RUnlock()
+	return a.settings
+}
+
+// Battle 一场答题赛
+// 作答来自不同的消息事件和卡片回调，可能同时到达；计时器也在单独的 goroutine 中触发，
+// 所有状态变化都在 mu 中进行，卡片也在持有 mu 时生成并按顺序加入发送队列；
+// 每场答题赛只有一个 goroutine 在释放 mu 之后依次发送和更新卡片，网络调用不会阻塞作答和计时
+type Battle struct {
+	ID        string
+	Bank      *storage.QuizBank
+	Questions []*Question
+	Scoring   Scoring
+	Timeout   time.Duration
+	StartedBy string
+
+	arena *Arena
+	key   battleKey
+	lark  *service.LarkService
+	ctx   context.Context // 发送和更新卡片使用，审计日志的操作人为发起人
+
+	mu         sync.Mutex
+	index      int             // 当前题目的下标
+	open       bool            // 当前题目是否接受作答
+	answered   map[string]bool // 当前题目已作答的用户，每人每题只有第一次作答有效
+	correct    []string        // 当前题目答对的用户（按作答顺序）
+	scores     map[string]*BattleScore
+	scoreboard *cardRef // 计分板卡片
+	question   *cardRef // 当前题目卡片
+	timer      *time.Timer
+	refreshing bool // 已安排更新计分板
+	finished   bool
+	stopped    bool // 被提前结束或因服务停止中断
+
+	outMu   sync.Mutex // 保护 outbox 和 sending，可以在持有 mu 时获取
+	outbox  []outgoing // 等待发送或更新的卡片
+	sending bool       // 发送队列的 goroutine 正在运行
+}
+
+// cardRef 答题赛发出的一张卡片，消息ID 在发送成功后回填，只在发送队列的 goroutine 中读写
+type cardRef struct {
+	messageID string
+}
+
+// outgoing 发送队列中的一项：发送新卡片、更新已发出的卡片，或者在之前的项都完成后关闭 done
+type outgoing struct {
+	ref    *cardRef
+	card   *card.Card
+	patch  bool
+	failed func(err error) // 发送新卡片失败时调用，调用时不持有任何锁
+	done   chan struct{}
+}
+
+// BattleScore 一名参赛者的成绩
+type BattleScore struct {
+	Rank     int
+	UserID   string
+	Points   int
+	Answers  int       // 作答的题数
+	ScoredAt time.Time // 最后一次得分的时间，同分时先得分的排在前面
+	joined   int       // 第一次作答的顺序，都没有得分时先作答的排在前面
+}
+
+// Start 在群里开始一场答题赛：从题库中随机抽取 n 道题，发出计分板和第一道题
+func (a *Arena) Start(ctx context.Context, scope storage.Scope, chatID, userID string, bank *storage.QuizBank, n int, scoring Scoring) (*Battle, error) {
+	settings := a.currentSettings()
+	if n <= 0 || n > settings.MaxQuestions {
+		return nil, fmt.Errorf("题数应为 1-%d", settings.MaxQuestions)
+	}
+	if _, ok := scoringLabels[scoring]; !ok {
+		return nil, fmt.Errorf("无效的计分方式: %s（应为 first 或 all）", scoring)
+	}
+	lark, err := a.apps.LarkService(scope.AppID)
+	if err != nil {
+		return nil, err
+	}
+	questions, err := a.quizzes.store.ListQuizQuestions(ctx, bank.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(questions) == 0 {
+		return nil, fmt.Errorf("题库《%s》中没有题目", bank.Title)
+	}
+
+	now := a.quizzes.now()
+	// 选项顺序由测验的随机种子决定，答题赛中不保存测验，用一个只有种子的测验生成题目
+	attempt := &storage.QuizAttempt{BankID: bank.ID, Seed: now.UnixNano(), StartedAt: now}
+	r := rand.New(rand.NewSource(attempt.Seed))
+	r.Shuffle(len(questions), func(i, j int) { questions[i], questions[j] = questions[j], questions[i] })
+	if n < len(questions) {
+		questions = questions[:n]
+	}
+
+	b := &Battle{
+		ID:         strconv.FormatInt(attempt.Seed, 36),
+		Bank:       bank,
+		Scoring:    scoring,
+		Timeout:    settings.Timeout,
+		StartedBy:  userID,
+		arena:      a,
+		key:        battleKey{appID: scope.AppID, tenantKey: scope.TenantKey, chatID: chatID},
+		lark:       lark,
+		ctx:        audit.WithActor(context.Background(), audit.Actor{ID: userID, Via: audit.ViaCommand, TenantKey: scope.TenantKey}),
+		scores:     make(map[string]*BattleScore),
+		scoreboard: &cardRef{},
+	}
+	for _, q := range questions {
+		attempt.QuestionIDs = append(attempt.QuestionIDs, q.ID)
+		b.Questions = append(b.Questions, newQuestion(bank, attempt, q))
+	}
+
+	a.mu.Lock()
+	if _, ok := a.battles[b.key]; ok {
+		a.mu.Unlock()
+		return nil, ErrBattleRunning
+	}
+	a.battles[b.key] = b
+	a.mu.Unlock()
+
+	// 等待计分板发出，发送失败时答题赛不开始
+	var sendErr error
+	b.mu.Lock()
+	b.send(b.scoreboard, scoreboardCard(b), func(err error) { sendErr = err })
+	sent := b.drained()
+	b.mu.Unlock()
+	<-sent
+	if sendErr != nil {
+		a.remove(b)
+		return nil, sendErr
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.finished {
+		log.Printf("[quiz] 答题赛开始: battle=%s, chat=%s, bank=%s, questions=%d, scoring=%s, by=%s",
+			b.ID, chatID, bank.ID, len(b.Questions), scoring, userID)
+		b.ask(0)
+	}
+	return b, nil
+}
+
+// Stop 提前结束群里进行中的答题赛并发出最终排名
+func (a *Arena) Stop(ctx context.Context, scope storage.Scope, chatID string) error {
+	b := a.get(battleKey{appID: scope.AppID, tenantKey: scope.TenantKey, chatID: chatID})
+	if b == nil {
+		return ErrNoBattle
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.finished {
+		return ErrNoBattle
+	}
+	log.Printf("[quiz] 答题赛被提前结束: battle=%s, chat=%s", b.ID, chatID)
+	b.stopped = true
+	if b.open {
+		b.closeQuestion()
+	}
+	b.finish()
+	return nil
+}
+
+// Shutdown 服务停止时中断所有进行中的答题赛（停止计时并在计分板上注明），等待卡片发送完成，实现 lifecycle 的 OnStop
+func (a *Arena) Shutdown(ctx context.Context) error {
+	a.mu.Lock()
+	battles := make([]*Battle, 0, len(a.battles))
+	for _, b := range a.battles {
+		battles = append(battles, b)
+	}
+	a.mu.Unlock()
+
+	pending := make([]<-chan struct{}, 0, len(battles))
+	for _, b := range battles {
+		b.mu.Lock()
+		if !b.finished {
+			b.finished, b.stopped = true, true
+			if b.open {
+				b.closeQuestion()
+			}
+			b.stopTimer()
+			b.patch(b.scoreboard, scoreboardCard(b))
+			a.remove(b)
+		}
+		pending = append(pending, b.drained())
+		b.mu.Unlock()
+	}
+
+	for _, done := range pending {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Answer 记录群成员对当前题目的作答，battleID 为空、question 小于 0 时为当前答题赛的当前题目（直接回复的答案）
+// accepted 为 false 表示群里没有这场答题赛、题目已经结束或答案的格式不符合题型
+func (a *Arena) Answer(scope storage.Scope, chatID, userID, battleID string, question int, input string) (accepted bool) {
+	b := a.get(battleKey{appID: scope.AppID, tenantKey: scope.TenantKey, chatID: chatID})
+	if b == nil || (battleID != "" && battleID != b.ID) {
+		return false
+	}
+	return b.answer(userID, question, input)
+}
+
+// get 获取群里进行中的答题赛
+func (a *Arena) get(key battleKey) *Battle {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.battles[key]
+}
+
+// remove 答题赛结束后从群里移除
+func (a *Arena) remove(b *Battle) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.battles[b.key] == b {
+		delete(a.battles, b.key)
+	}
+}
+
+// ranking 按得分排列的参赛者（同分时先得分的在前，名次相同），调用时持有 mu
+func (b *Battle) ranking() []*BattleScore {
+	ranking := make([]*BattleScore, 0, len(b.scores))
+	for _, s := range b.scores {
+		ranking = append(ranking, s)
+	}
+	sort.Slice(ranking, func(i, j int) bool {
+		x, y := ranking[i], ranking[j]
+		switch {
+		case x.Points != y.Points:
+			return x.Points > y.Points
+		case !x.ScoredAt.Equal(y.ScoredAt):
+			return x.ScoredAt.Before(y.ScoredAt)
+		}
+		return x.joined < y.joined
+	})
+	for i, s := range ranking {
+		s.Rank = i + 1
+		if i > 0 && s.Points == ranking[i-1].Points {
+			s.Rank = ranking[i-1].Rank
+		}
+	}
+	return ranking
+}
+
+// answer 记录一次作答，见 Arena.Answer
+func (b *Battle) answer(userID string, question int, input string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.finished || !b.open || (question >= 0 && question != b.index) {
+		return false
+	}
+	correct, valid := b.Questions[b.index].Grade(input)
+	if !valid {
+		return false
+	}
+	if b.answered[userID] {
+		return true
+	}
+
+	b.answered[userID] = true
+	s, ok := b.scores[userID]
+	if !ok {
+		s = &BattleScore{UserID: userID, joined: len(b.scores)}
+		b.scores[userID] = s
+	}
+	s.Answers++
+	if correct {
+		s.Points++
+		s.ScoredAt = b.arena.quizzes.now()
+		b.correct = append(b.correct, userID)
+	}
+
+	if correct && b.Scoring == ScoringFirst {
+		b.closeQuestion()
+		b.next()
+		return true
+	}
+	b.refreshScoreboard()
+	return true
+}
+
+// ask 发出第 i 道题并开始计时，调用时持有 mu
+func (b *Battle) ask(i int) {
+	b.index, b.open = i, true
+	b.answered, b.correct = make(map[string]bool), nil
+
+	b.question = &cardRef{}
+	b.send(b.question, questionBattleCard(b, b.Questions[i], false), func(err error) {
+		log.Printf("[quiz] 发送答题赛题目失败，结束答题赛: battle=%s, question=%d, error=%v", b.ID, i+1, err)
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if b.finished || b.index != i {
+			return
+		}
+		b.open, b.stopped = false, true
+		b.finish()
+	})
+	b.patch(b.scoreboard, scoreboardCard(b))
+	b.refreshing = false
+
+	b.timer = time.AfterFunc(b.Timeout, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if b.finished || !b.open || b.index != i {
+			return
+		}
+		b.closeQuestion()
+		b.next()
+	})
+}
+
+// closeQuestion 结束当前题目：停止计时，在题目卡片上公布正确答案和答对的人，调用时持有 mu
+func (b *Battle) closeQuestion() {
+	b.open = false
+	b.stopTimer()
+	b.patch(b.question, questionBattleCard(b, b.Questions[b.index], true))
+}
+
+// next 间隔一段时间后发出下一道题，已是最后一道题时结束答题赛，调用时持有 mu
+func (b *Battle) next() {
+	if b.index+1 >= len(b.Questions) {
+		b.finish()
+		return
+	}
+	b.patch(b.scoreboard, scoreboardCard(b))
+	b.refreshing = false
+
+	i := b.index + 1
+	b.timer = time.AfterFunc(battleGap, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if b.finished || b.open || b.index != i-1 {
+			return
+		}
+		b.ask(i)
+	})
+}
+
+// finish 结束答题赛：更新计分板并发出最终排名，调用时持有 mu
+func (b *Battle) finish() {
+	b.finished = true
+	b.stopTimer()
+	b.arena.remove(b)
+	b.patch(b.scoreboard, scoreboardCard(b))
+	b.send(&cardRef{}, rankingCard(b), func(err error) {
+		log.Printf("[quiz] 发送答题赛排名失败: battle=%s, error=%v", b.ID, err)
+	})
+	log.Printf("[quiz] 答题赛结束: battle=%s, chat=%s, players=%d, stopped=%t", b.ID, b.key.chatID, len(b.scores), b.stopped)
+}
+
+// refreshScoreboard 合并 scoreboardDelay 内的作答后更新计分板（显示已作答人数和最新得分），调用时持有 mu
+func (b *Battle) refreshScoreboard() {
+	if b.refreshing {
+		return
+	}
+	b.refreshing = true
+	i := b.index
+	time.AfterFunc(scoreboardDelay, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if b.finished || !b.refreshing || b.index != i {
+			return
+		}
+		b.refreshing = false
+		b.patch(b.scoreboard, scoreboardCard(b))
+	})
+}
+
+// stopTimer 停止题目计时或下一题的等待
+func (b *Battle) stopTimer() {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+}
+
+// send 把发送新卡片加入发送队列，发送成功后回填 ref 的消息ID，失败时调用 failed，调用时持有 mu
+func (b *Battle) send(ref *cardRef, cd *card.Card, failed func(err error)) {
+	b.enqueue(outgoing{ref: ref, card: cd, failed: failed})
+}
+
+// patch 把更新已发出的卡片加入发送队列，调用时持有 mu
+func (b *Battle) patch(ref *cardRef, cd *card.Card) {
+	b.enqueue(outgoing{ref: ref, card: cd, patch: true})
+}
+
+// drained 返回一个通道，在此之前加入发送队列的卡片都发送或更新完成后关闭
+func (b *Battle) drained() <-chan struct{} {
+	done := make(chan struct{})
+	b.enqueue(outgoing{done: done})
+	return done
+}
+
+// enqueue 加入发送队列，没有正在运行的发送 goroutine 时启动一个
+func (b *Battle) enqueue(o outgoing) {
+	b.outMu.Lock()
+	defer b.outMu.Unlock()
+	b.outbox = append(b.outbox, o)
+	if !b.sending {
+		b.sending = true
+		go b.deliver()
+	}
+}
+
+// deliver 按加入的顺序逐个处理发送队列，队列为空时退出
+func (b *Battle) deliver() {
+	for {
+		b.outMu.Lock()
+		if len(b.outbox) == 0 {
+			b.sending = false
+			b.outMu.Unlock()
+			return
+		}
+		o := b.outbox[0]
+		b.outbox[0] = outgoing{}
+		b.outbox = b.outbox[1:]
+		b.outMu.Unlock()
+
+		switch {
+		case o.done != nil:
+			close(o.done)
+		case o.patch:
+			b.patchCard(o.ref, o.card)
+		default:
+			if err := b.sendCard(o.ref, o.card); err != nil && o.failed != nil {
+				o.failed(err)
+			}
+		}
+	}
+}
+
+// sendCard 把卡片发送到群里并回填消息ID，只在发送队列中调用
+func (b *Battle) sendCard(ref *cardRef, cd *card.Card) error {
+	data, err := json.Marshal(cd)
+	if err != nil {
+		return err
+	}
+	ref.messageID, err = b.lark.SendCard(b.ctx, b.key.chatID, "chat_id", string(data))
+	return err
+}
+
+// patchCard 更新已发出的卡片，卡片没有发出时忽略，失败时只记录日志（下一次更新会带上最新内容），只在发送队列中调用
+func (b *Battle) patchCard(ref *cardRef, cd *card.Card) {
+	if ref.messageID == "" {
+		return
+	}
+	data, err := json.Marshal(cd)
+	if err == nil {
+		err = b.lark.PatchCard(b.ctx, ref.messageID, string(data))
+	}
+	if err != nil {
+		log.Printf("[quiz] 更新答题赛卡片失败: battle=%s, message_id=%s, error=%v", b.ID, ref.messageID, err)
+	}
+}
@@ -0,0 +1,79 @@
+package quiz
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"fin_bot/card"
+	"fin_bot/larktest"
+)
+
+// waitDrained 等待发送队列中已有的卡片处理完成
+func waitDrained(t *testing.T, b *Battle) {
+	t.Helper()
+	b.mu.Lock()
+	done := b.drained()
+	b.mu.Unlock()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("等待发送队列超时")
+	}
+}
+
+func TestBattleOutbox(t *testing.T) {
+	srv := larktest.NewServer()
+	defer srv.Close()
+	app := srv.NewApp("quiz", "cli_battle_outbox", "t1")
+	b := &Battle{ID: "test", lark: app.Lark, ctx: context.Background(), key: battleKey{chatID: "oc_battle"}}
+
+	// 发送和更新按加入的顺序执行，更新使用发送后回填的消息ID
+	board := &cardRef{}
+	b.mu.Lock()
+	b.send(board, card.New("计分板 v1", "blue"), nil)
+	b.patch(board, card.New("计分板 v2", "blue"))
+	b.patch(board, card.New("计分板 v3", "blue"))
+	b.mu.Unlock()
+	waitDrained(t, b)
+
+	// 发送失败时调用 failed，之后对这张卡片的更新被忽略，后面的卡片照常发送
+	srv.FailNext(larktest.EndpointMessageCreate, larktest.CodeInvalidParam, "send failed")
+	var sendErr error
+	question := &cardRef{}
+	b.mu.Lock()
+	b.send(question, card.New("第 1 题", "blue"), func(err error) { sendErr = err })
+	b.patch(question, card.New("第 1 题 答案", "blue"))
+	b.send(&cardRef{}, card.New("最终排名", "blue"), nil)
+	b.mu.Unlock()
+	waitDrained(t, b)
+
+	if sendErr == nil {
+		t.Error("发送失败时应调用 failed")
+	}
+	if question.messageID != "" {
+		t.Errorf("发送失败的卡片不应有消息ID: %q", question.messageID)
+	}
+
+	messages := srv.Messages()
+	if len(messages) != 2 {
+		t.Fatalf("发出 %d 条消息, want 2", len(messages))
+	}
+	if messages[0].MessageID != board.messageID || messages[0].Patches != 2 ||
+		!strings.Contains(messages[0].Content, "计分板 v3") {
+		t.Errorf("计分板 = %+v, want 更新 2 次且内容为 v3", messages[0])
+	}
+	if !strings.Contains(messages[1].Content, "最终排名") {
+		t.Errorf("第二条消息 = %s, want 最终排名", messages[1].Content)
+	}
+	if n := len(srv.Requests(larktest.EndpointMessagePatch)); n != 2 {
+		t.Errorf("更新请求 %d 次, want 2", n)
+	}
+
+	b.outMu.Lock()
+	defer b.outMu.Unlock()
+	if b.sending || len(b.outbox) != 0 {
+		t.Errorf("队列处理完成后发送 goroutine 应退出: sending=%t, outbox=%d", b.sending, len(b.outbox))
+	}
+}
@@ -0,0 +1,267 @@
+package quiz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"fin_bot/command"
+	"fin_bot/storage"
+)
+
+// Commands 返回测验的聊天命令（所有成员可用）
+func (s *Service) Commands() []*command.Command {
+	return []*command.Command{
+		{
+			Name:         "quiz",
+			Usage:        "/quiz [题库] [题数] | /quiz stop",
+			Description:  "查看题库、开始测验或结束进行中的测验",
+			ReplyHandler: s.commandQuiz,
+		},
+		{
+			Name:         "answer",
+			Usage:        "/answer <答案>",
+			Description:  "回答测验的当前题目（选项字母、对/错或数值）",
+			ReplyHandler: s.commandAnswer,
+		},
+	}
+}
+
+// commandQuiz 处理 /quiz 命令
+func (s *Service) commandQuiz(ctx context.Context, req *command.Request) (*command.Reply, error) {
+	scope := requestScope(req)
+	switch {
+	case len(req.Args) == 0:
+		// 有进行中的测验时重新发送当前题目
+		q, err := s.Current(ctx, scope, req.SenderID)
+		if err == nil {
+			return command.CardReply(questionCard(q, nil))
+		}
+		if !errors.Is(err, ErrNoActiveQuiz) && !errors.Is(err, ErrBankChanged) {
+			return nil, err
+		}
+		return s.listBanks(ctx, scope, req.SenderID)
+	case len(req.Args) == 1 && strings.EqualFold(req.Args[0], "stop"):
+		a, err := s.Stop(ctx, scope, req.SenderID)
+		if errors.Is(err, ErrNoActiveQuiz) {
+			return command.TextReply(err.Error()), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return command.TextReply(fmt.Sprintf("已结束测验，共回答 %d/%d 题，答对 %d 题", a.Answered, a.Total(), a.Correct)), nil
+	case len(req.Args) > 2:
+		return nil, errors.New("参数数量不正确")
+	}
+
+	bank, err := s.FindBank(ctx, req.Args[0])
+	if err != nil {
+		return nil, err
+	}
+	n := 0
+	if len(req.Args) == 2 {
+		if n, err = strconv.Atoi(req.Args[1]); err != nil || n <= 0 {
+			return nil, fmt.Errorf("无效的题数: %s", req.Args[1])
+		}
+	}
+	q, err := s.Start(ctx, scope, req.SenderID, req.ChatID, bank, n)
+	if err != nil {
+		return nil, err
+	}
+	return command.CardReply(questionCard(q, nil))
+}
+
+// commandAnswer 处理 /answer 命令
+func (s *Service) commandAnswer(ctx context.Context, req *command.Request) (*command.Reply, error) {
+	if req.RawArgs == "" {
+		return nil, errors.New("缺少答案")
+	}
+	q, err := s.Current(ctx, requestScope(req), req.SenderID)
+	if errors.Is(err, ErrNoActiveQuiz) || errors.Is(err, ErrBankChanged) {
+		return command.TextReply(err.Error()), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.answerReply(ctx, q, req.RawArgs)
+}
+
+// HandleText 处理直接回复的答案：发送者在这个会话中有进行中的测验，且消息是符合当前题型的答案时判题，
+// 其他消息交给下一个处理函数
+func (s *Service) HandleText(ctx context.Context, req *command.Request) (*command.Reply, bool, error) {
+	if req.RawArgs == "" {
+		return nil, false, nil
+	}
+	q, err := s.Current(ctx, requestScope(req), req.SenderID)
+	if errors.Is(err, ErrNoActiveQuiz) || errors.Is(err, ErrBankChanged) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if q.Attempt.ChatID != req.ChatID {
+		return nil, false, nil
+	}
+	if _, valid := q.Grade(req.RawArgs); !valid {
+		return nil, false, nil
+	}
+	reply, err := s.answerReply(ctx, q, req.RawArgs)
+	return reply, true, err
+}
+
+// HandleAction 处理题目卡片上的答案按钮；点击别人的测验或已经回答过的题目时不回复
+func (s *Service) HandleAction(ctx context.Context, req *command.ActionRequest) (*command.Reply, error) {
+	attemptID, err := strconv.ParseInt(req.Value["attempt"], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("无效的测验: %q", req.Value["attempt"])
+	}
+	questionID, err := strconv.ParseInt(req.Value["question"], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("无效的题目: %q", req.Value["question"])
+	}
+
+	scope := storage.Scope{AppID: req.AppID, TenantKey: req.TenantKey}
+	result, err := s.AnswerButton(ctx, scope, req.SenderID, attemptID, questionID, req.Value["answer"])
+	var invalid *InvalidAnswerError
+	switch {
+	case errors.Is(err, ErrNoActiveQuiz), errors.Is(err, ErrAlreadyAnswered):
+		return nil, nil
+	case errors.As(err, &invalid), errors.Is(err, ErrBankChanged):
+		return command.TextReply(err.Error()), nil
+	case err != nil:
+		return nil, err
+	}
+	return s.resultReply(ctx, result)
+}
+
+// answerReply 回答当前题目，回复判题结果和下一题（或成绩）
+func (s *Service) answerReply(ctx context.Context, q *Question, input string) (*command.Reply, error) {
+	result, err := s.Answer(ctx, q, input)
+	var invalid *InvalidAnswerError
+	switch {
+	case errors.As(err, &invalid), errors.Is(err, ErrAlreadyAnswered), errors.Is(err, ErrBankChanged):
+		return command.TextReply(err.Error()), nil
+	case err != nil:
+		return nil, err
+	}
+	return s.resultReply(ctx, result)
+}
+
+// resultReply 把判题结果渲染为卡片：还有题目时为带判题结果的下一题，测验结束时为成绩卡片
+func (s *Service) resultReply(ctx context.Context, r *Result) (*command.Reply, error) {
+	if r.Next != nil {
+		return command.CardReply(questionCard(r.Next, feedback(r)))
+	}
+	mistakes, err := s.Mistakes(ctx, r.Attempt.ID)
+	if err != nil {
+		return nil, err
+	}
+	return command.CardReply(resultCard(r, mistakes))
+}
+
+// listBanks 列出所有题库和用户的最好成绩
+func (s *Service) listBanks(ctx context.Context, scope storage.Scope, userID string) (*command.Reply, error) {
+	banks, err := s.Banks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(banks) == 0 {
+		return command.TextReply("还没有任何题库"), nil
+	}
+	best, err := s.BestScores(ctx, scope, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	b.WriteString("题库列表:")
+	for _, bank := range banks {
+		fmt.Fprintf(&b, "\n%s  《%s》 %d 题", bank.ID, bank.Title, bank.Questions)
+		if a, ok := best[bank.ID]; ok {
+			fmt.Fprintf(&b, "  最好成绩 %d/%d", a.Correct, a.Total())
+		}
+		if bank.Description != "" {
+			fmt.Fprintf(&b, "\n    %s", bank.Description)
+		}
+	}
+	b.WriteString("\n\n发送 /quiz <题库> [题数] 开始测验")
+	return command.TextReply(b.String()), nil
+}
+
+// requestScope 命令所属的应用和租户
+func requestScope(req *command.Request) storage.Scope {
+	return storage.Scope{AppID: req.AppID, TenantKey: req.TenantKey}
+}
+
+// Commands 返回答题赛的聊天命令（管理员在群聊中使用）
+func (a *Arena) Commands() []*command.Command {
+	return []*command.Command{
+		{
+			Name:         "quizbattle",
+			Usage:        "/quizbattle <题库> <题数> [first|all] | /quizbattle stop",
+			Description:  "在群里发起限时答题赛（first: 先答对者得分，all: 限时内答对都得分），或提前结束进行中的答题赛",
+			MinRole:      command.RoleAdmin,
+			ReplyHandler: a.commandQuizBattle,
+		},
+	}
+}
+
+// commandQuizBattle 处理 /quizbattle 命令，答题赛开始后题目和计分板由答题赛自行发送，不回复
+func (a *Arena) commandQuizBattle(ctx context.Context, req *command.Request) (*command.Reply, error) {
+	if req.ChatType != "group" {
+		return nil, errors.New("答题赛只能在群聊中发起")
+	}
+	scope := requestScope(req)
+	switch {
+	case len(req.Args) == 1 && strings.EqualFold(req.Args[0], "stop"):
+		if err := a.Stop(ctx, scope, req.ChatID); errors.Is(err, ErrNoBattle) {
+			return command.TextReply(err.Error()), nil
+		} else if err != nil {
+			return nil, err
+		}
+		return nil, nil
+	case len(req.Args) < 2 || len(req.Args) > 3:
+		return nil, errors.New("用法: /quizbattle <题库> <题数> [first|all] | /quizbattle stop")
+	}
+
+	bank, err := a.quizzes.FindBank(ctx, req.Args[0])
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(req.Args[1])
+	if err != nil || n <= 0 {
+		return nil, fmt.Errorf("无效的题数: %s", req.Args[1])
+	}
+	scoring := ScoringFirst
+	if len(req.Args) == 3 {
+		scoring = Scoring(strings.ToLower(req.Args[2]))
+	}
+	if _, err := a.Start(ctx, scope, req.ChatID, req.SenderID, bank, n, scoring); errors.Is(err, ErrBattleRunning) {
+		return command.TextReply(err.Error()), nil
+	} else if err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// HandleText 处理答题赛中直接回复的答案：群里有进行中的答题赛且消息是符合当前题型的答案时记录作答，不回复
+// （结果通过更新计分板展示），其他消息交给下一个处理函数
+func (a *Arena) HandleText(ctx context.Context, req *command.Request) (*command.Reply, bool, error) {
+	if req.ChatType != "group" || req.RawArgs == "" {
+		return nil, false, nil
+	}
+	return nil, a.Answer(requestScope(req), req.ChatID, req.SenderID, "", -1, req.RawArgs), nil
+}
+
+// HandleAction 处理答题赛题目卡片上的答案按钮，不回复；点击已经结束的题目或重复作答时忽略
+func (a *Arena) HandleAction(ctx context.Context, req *command.ActionRequest) (*command.Reply, error) {
+	question, err := strconv.Atoi(req.Value["question"])
+	if err != nil {
+		return nil, fmt.Errorf("无效的题目: %q", req.Value["question"])
+	}
+	scope := storage.Scope{AppID: req.AppID, TenantKey: req.TenantKey}
+	a.Answer(scope, req.ChatID, req.SenderID, req.Value["battle"], question, req.Value["answer"])
+	return nil, nil
+}
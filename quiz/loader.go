@@ -0,0 +1,438 @@
+package quiz
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"fin_bot/storage"
+)
+
+// 题库目录中每个 .json 或 .csv 文件是一个题库（可以放在子目录中），以 . 或 _ 开头的文件和目录会被忽略；
+// 未指定 id 时使用去掉数字前缀和扩展名的文件名，未指定题目 id 时按顺序编号为 q1、q2……
+//
+// JSON 格式:
+//
+//	{
+//	  "id": "price-and-yield", "title": "价格与收益率", "lesson": "bond-basics/price-and-yield",
+//	  "questions": [
+//	    {"type": "single", "question": "...", "options": ["...", "..."], "answer": "B", "explanation": "..."},
+//	    {"type": "multiple", "question": "...", "options": ["...", "...", "..."], "answer": ["A", "C"]},
+//	    {"type": "truefalse", "question": "...", "answer": false},
+//	    {"type": "numeric", "question": "...", "answer": 4.35, "tolerance": 0.01}
+//	  ]
+//	}
+//
+// CSV 格式：第一行为表头（id,type,question,options,answer,tolerance,explanation，其中 type、question、answer 必填），
+// 选项用 | 分隔；题库信息写在表头之前的注释行中，例如 "# title: 价格与收益率"、"# lesson: bond-basics/price-and-yield"
+var (
+	// idPattern 题库和题目的 id
+	idPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+	// orderPrefix 文件名中表示顺序的数字前缀，例如 01-
+	orderPrefix = regexp.MustCompile(`^\d+[-_. ]+`)
+)
+
+// bankFile JSON 题库文件
+type bankFile struct {
+	ID             string         `json:"id"`
+	Title          string         `json:"title"` // 为空时使用 id
+	Description    string         `json:"description"`
+	Lesson         string         `json:"lesson"`          // 关联的课: <课程 id>/<课的 id>
+	ShuffleOptions *bool          `json:"shuffle_options"` // 默认打乱选项顺序
+	Questions      []questionFile `json:"questions"`
+}
+
+// questionFile JSON 题库文件中的一道题
+type questionFile struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	Question    string          `json:"question"`
+	Options     []string        `json:"options"`
+	Answer      json.RawMessage `json:"answer"` // 字符串、数值、布尔值或字符串数组（多选题）
+	Tolerance   float64         `json:"tolerance"`
+	Explanation string          `json:"explanation"`
+}
+
+// csvColumns CSV 题库支持的列
+var csvColumns = []string{"id", "type", "question", "options", "answer", "tolerance", "explanation"}
+
+// LoadError 题库文件校验错误，包含所有问题而不是遇到第一个就返回
+type LoadError struct {
+	Problems []string
+}
+
+func (e *LoadError) Error() string {
+	return fmt.Sprintf("题库文件校验失败（%d 项）:\n  - %s", len(e.Problems), strings.Join(e.Problems, "\n  - "))
+}
+
+// Load 读取目录中的所有题库，任何文件有问题时返回 *LoadError 且不返回任何题库
+func Load(dir string) ([]*storage.QuizBankImport, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("读取题库目录失败: %w", err)
+	}
+
+	l := &loader{}
+	var banks []*storage.QuizBankImport
+	ids := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir && ignored(entry.Name()) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		var bank *storage.QuizBankImport
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".json":
+			bank = l.loadJSON(path)
+		case ".csv":
+			bank = l.loadCSV(path)
+		default:
+			return nil
+		}
+		if bank == nil {
+			return nil
+		}
+		if other, ok := ids[bank.Bank.ID]; ok {
+			l.addf("%s: 题库 id %s 与 %s 重复", path, bank.Bank.ID, other)
+			return nil
+		}
+		ids[bank.Bank.ID] = path
+		banks = append(banks, bank)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("读取题库目录失败: %w", err)
+	}
+
+	if len(l.problems) > 0 {
+		return nil, &LoadError{Problems: l.problems}
+	}
+	return banks, nil
+}
+
+// loader 读取题库时收集问题
+type loader struct {
+	problems []string
+}
+
+func (l *loader) addf(format string, args ...interface{}) {
+	l.problems = append(l.problems, fmt.Sprintf(format, args...))
+}
+
+// loadJSON 读取 JSON 题库，有问题时记录并返回 nil
+func (l *loader) loadJSON(path string) *storage.QuizBankImport {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		l.addf("%s: %v", path, err)
+		return nil
+	}
+	var f bankFile
+	dec := json.NewDecoder(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&f); err != nil {
+		l.addf("%s: JSON 格式错误: %v", path, err)
+		return nil
+	}
+
+	bank := l.newBank(path, f.ID, f.Title, f.Description, f.Lesson, f.ShuffleOptions)
+	if bank == nil {
+		return nil
+	}
+	for i, qf := range f.Questions {
+		answer, ok := rawAnswer(qf.Answer)
+		if !ok {
+			l.addf("%s: 第 %d 题: answer 只能是字符串、数值、布尔值或字符串数组", path, i+1)
+			continue
+		}
+		where := fmt.Sprintf("%s: 第 %d 题", path, i+1)
+		if q := l.newQuestion(where, i+1, qf.ID, qf.Type, qf.Question, qf.Options, answer, qf.Tolerance, qf.Explanation); q != nil {
+			bank.Questions = append(bank.Questions, q)
+		}
+	}
+	return l.checkBank(path, bank, len(f.Questions))
+}
+
+// loadCSV 读取 CSV 题库，有问题时记录并返回 nil
+func (l *loader) loadCSV(path string) *storage.QuizBankImport {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		l.addf("%s: %v", path, err)
+		return nil
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	// 表头之前的注释行为题库信息
+	meta := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+		if key, value, ok := strings.Cut(strings.TrimPrefix(line, "#"), ":"); ok {
+			meta[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+		}
+	}
+	var shuffle *bool
+	if v, ok := meta["shuffle_options"]; ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			l.addf("%s: shuffle_options 只能是 true 或 false", path)
+			return nil
+		}
+		shuffle = &b
+	}
+	bank := l.newBank(path, meta["id"], meta["title"], meta["description"], meta["lesson"], shuffle)
+	if bank == nil {
+		return nil
+	}
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if errors.Is(err, io.EOF) {
+		l.addf("%s: 缺少表头", path)
+		return nil
+	}
+	if err != nil {
+		l.addf("%s: CSV 格式错误: %v", path, err)
+		return nil
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if indexOf(csvColumns, name) < 0 {
+			l.addf("%s: 未知的列 %q（支持 %s）", path, name, strings.Join(csvColumns, ","))
+			return nil
+		}
+		columns[name] = i
+	}
+	for _, name := range []string{"type", "question", "answer"} {
+		if _, ok := columns[name]; !ok {
+			l.addf("%s: 缺少 %s 列", path, name)
+			return nil
+		}
+	}
+
+	rows := 0
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			l.addf("%s: CSV 格式错误: %v", path, err)
+			return nil
+		}
+		rows++
+		line, _ := r.FieldPos(0)
+		where := fmt.Sprintf("%s: 第 %d 行", path, line)
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		var options []string
+		if s := field("options"); s != "" {
+			for _, o := range strings.Split(s, "|") {
+				options = append(options, strings.TrimSpace(o))
+			}
+		}
+		var tolerance float64
+		if s := field("tolerance"); s != "" {
+			if tolerance, err = strconv.ParseFloat(s, 64); err != nil {
+				l.addf("%s: 无效的 tolerance %q", where, s)
+				continue
+			}
+		}
+		if q := l.newQuestion(where, rows, field("id"), field("type"), field("question"), options, field("answer"), tolerance, field("explanation")); q != nil {
+			bank.Questions = append(bank.Questions, q)
+		}
+	}
+	return l.checkBank(path, bank, rows)
+}
+
+// newBank 校验题库信息，有问题时记录并返回 nil
+func (l *loader) newBank(path, id, title, description, lesson string, shuffle *bool) *storage.QuizBankImport {
+	if id == "" {
+		id = idFromName(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+	}
+	if !idPattern.MatchString(id) {
+		l.addf("%s: 题库 id %q 只能包含字母、数字、-、_ 和 .（可以在文件中指定 id）", path, id)
+		return nil
+	}
+	if title == "" {
+		title = id
+	}
+	bank := &storage.QuizBank{
+		ID:             id,
+		Title:          title,
+		Description:    description,
+		ShuffleOptions: shuffle == nil || *shuffle,
+		Source:         path,
+	}
+	if lesson != "" {
+		courseID, slug, ok := strings.Cut(lesson, "/")
+		if !ok || !idPattern.MatchString(courseID) || !idPattern.MatchString(slug) {
+			l.addf("%s: lesson %q 的格式应为 <课程 id>/<课的 id>", path, lesson)
+			return nil
+		}
+		bank.CourseID, bank.LessonSlug = courseID, slug
+	}
+	return &storage.QuizBankImport{Bank: bank}
+}
+
+// newQuestion 校验一道题并转换为保存的格式，有问题时记录并返回 nil
+func (l *loader) newQuestion(where string, position int, id, typ, prompt string, options []string, answer string, tolerance float64, explanation string) *storage.QuizQuestion {
+	if id == "" {
+		id = fmt.Sprintf("q%d", position)
+	}
+	t, ok := typeAliases[strings.ToLower(strings.TrimSpace(typ))]
+	switch {
+	case !idPattern.MatchString(id):
+		l.addf("%s: 题目 id %q 只能包含字母、数字、-、_ 和 .", where, id)
+		return nil
+	case !ok:
+		l.addf("%s: 未知的题型 %q（支持 single、multiple、truefalse、numeric）", where, typ)
+		return nil
+	case strings.TrimSpace(prompt) == "":
+		l.addf("%s: 缺少题目", where)
+		return nil
+	case tolerance < 0:
+		l.addf("%s: tolerance 不能为负数", where)
+		return nil
+	case tolerance != 0 && t != TypeNumeric:
+		l.addf("%s: 只有数值题可以设置 tolerance", where)
+		return nil
+	}
+
+	if t == TypeSingle || t == TypeMultiple {
+		if len(options) < 2 || len(options) > maxOptions {
+			l.addf("%s: 选择题需要 2 到 %d 个选项", where, maxOptions)
+			return nil
+		}
+		for i, o := range options {
+			if o == "" {
+				l.addf("%s: 第 %d 个选项为空", where, i+1)
+				return nil
+			}
+			if indexOf(options[:i], o) >= 0 {
+				l.addf("%s: 选项 %q 重复", where, o)
+				return nil
+			}
+		}
+	} else if len(options) > 0 {
+		l.addf("%s: %s不需要 options", where, typeLabels[t])
+		return nil
+	}
+
+	normalized, ok := normalizeAnswer(t, options, answer)
+	if !ok {
+		switch t {
+		case TypeSingle:
+			l.addf("%s: 无效的答案 %q，单选题的答案为一个选项字母或选项内容", where, answer)
+		case TypeMultiple:
+			l.addf("%s: 无效的答案 %q，多选题的答案为选项字母（例如 AC）或用 | 分隔的选项内容", where, answer)
+		case TypeTrueFalse:
+			l.addf("%s: 无效的答案 %q，判断题的答案为 true/false 或 对/错", where, answer)
+		default:
+			l.addf("%s: 无效的答案 %q，数值题的答案为数值", where, answer)
+		}
+		return nil
+	}
+
+	return &storage.QuizQuestion{
+		Slug:        id,
+		Position:    position,
+		Type:        t,
+		Prompt:      strings.TrimSpace(prompt),
+		Options:     options,
+		Answer:      normalized,
+		Tolerance:   tolerance,
+		Explanation: strings.TrimSpace(explanation),
+	}
+}
+
+// checkBank 检查题库中的题目，count 为文件中的题数（包括有问题的）
+func (l *loader) checkBank(path string, bank *storage.QuizBankImport, count int) *storage.QuizBankImport {
+	if count == 0 {
+		l.addf("%s: 题库中没有任何题目", path)
+		return nil
+	}
+	if len(bank.Questions) < count {
+		return nil
+	}
+	slugs := make(map[string]int)
+	for i, q := range bank.Questions {
+		if other, ok := slugs[q.Slug]; ok {
+			l.addf("%s: 第 %d 题的 id %s 与第 %d 题重复", path, i+1, q.Slug, other)
+		}
+		slugs[q.Slug] = i + 1
+	}
+	return bank
+}
+
+// rawAnswer 把 JSON 中的答案转换为字符串，数组（多选题）用 | 连接
+func rawAnswer(raw json.RawMessage) (string, bool) {
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return "", false
+	}
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case bool:
+		return strconv.FormatBool(v), true
+	case float64:
+		return formatNumber(v), true
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return "", false
+			}
+			parts = append(parts, s)
+		}
+		return strings.Join(parts, "|"), true
+	}
+	return "", false
+}
+
+// idFromName 去掉文件名中的顺序前缀
+func idFromName(name string) string {
+	if id := orderPrefix.ReplaceAllString(name, ""); id != "" {
+		return id
+	}
+	return name
+}
+
+// ignored 是否忽略的文件或目录
+func ignored(name string) bool {
+	return strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")
+}
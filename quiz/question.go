@@ -0,0 +1,273 @@
+package quiz
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+
+	"fin_bot/storage"
+)
+
+// 题型
+const (
+	TypeSingle    = "single"    // 单选题
+	TypeMultiple  = "multiple"  // 多选题，选出全部正确选项才算正确
+	TypeTrueFalse = "truefalse" // 判断题
+	TypeNumeric   = "numeric"   // 数值题，与答案的差不超过 tolerance 算正确（例如计算到期收益率）
+)
+
+// maxOptions 选择题的选项数上限（A-J）
+const maxOptions = 10
+
+// typeAliases 题库文件中题型的写法
+var typeAliases = map[string]string{
+	"single": TypeSingle, "单选": TypeSingle, "单选题": TypeSingle,
+	"multiple": TypeMultiple, "multi": TypeMultiple, "多选": TypeMultiple, "多选题": TypeMultiple,
+	"truefalse": TypeTrueFalse, "tf": TypeTrueFalse, "bool": TypeTrueFalse, "判断": TypeTrueFalse, "判断题": TypeTrueFalse,
+	"numeric": TypeNumeric, "number": TypeNumeric, "数值": TypeNumeric, "数值题": TypeNumeric, "计算题": TypeNumeric,
+}
+
+// typeLabels 题型的中文名称
+var typeLabels = map[string]string{
+	TypeSingle:    "单选题",
+	TypeMultiple:  "多选题",
+	TypeTrueFalse: "判断题",
+	TypeNumeric:   "数值题",
+}
+
+// Question 测验中的一道题：题目和本次测验中的选项顺序
+type Question struct {
+	Bank     *storage.QuizBank
+	Attempt  *storage.QuizAttempt
+	Question *storage.QuizQuestion
+	order    []int // order[i] 为第 i 个展示的选项在题库中的下标
+}
+
+// newQuestion 按测验的随机种子确定选项顺序，同一次测验中重复展示同一道题时顺序不变
+func newQuestion(bank *storage.QuizBank, a *storage.QuizAttempt, q *storage.QuizQuestion) *Question {
+	order := make([]int, len(q.Options))
+	for i := range order {
+		order[i] = i
+	}
+	if bank.ShuffleOptions && (q.Type == TypeSingle || q.Type == TypeMultiple) {
+		r := rand.New(rand.NewSource(a.Seed ^ q.ID))
+		r.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+	}
+	return &Question{Bank: bank, Attempt: a, Question: q, order: order}
+}
+
+// Number 题目在测验中的序号（从 1 开始）
+func (q *Question) Number() int {
+	return q.Attempt.Answered + 1
+}
+
+// Options 按展示顺序排列的选项
+func (q *Question) Options() []string {
+	options := make([]string, len(q.order))
+	for i, idx := range q.order {
+		options[i] = q.Question.Options[idx]
+	}
+	return options
+}
+
+// Grade 判断答案是否正确，valid 为 false 表示答案的格式不符合题型（例如单选题回复了两个选项）
+func (q *Question) Grade(input string) (correct, valid bool) {
+	switch q.Question.Type {
+	case TypeSingle, TypeMultiple:
+		positions, ok := parseLetters(input, len(q.order))
+		if !ok || (q.Question.Type == TypeSingle && len(positions) != 1) {
+			return false, false
+		}
+		indexes := make([]int, len(positions))
+		for i, p := range positions {
+			indexes[i] = q.order[p]
+		}
+		return joinIndexes(indexes) == q.Question.Answer, true
+	case TypeTrueFalse:
+		v, ok := parseBool(input)
+		return ok && strconv.FormatBool(v) == q.Question.Answer, ok
+	case TypeNumeric:
+		v, ok := parseNumber(input)
+		if !ok {
+			return false, false
+		}
+		want, err := strconv.ParseFloat(q.Question.Answer, 64)
+		// 加上很小的余量，避免 0.1+0.2 这类浮点误差导致边界上的答案判错
+		return err == nil && math.Abs(v-want) <= q.Question.Tolerance+1e-9, true
+	}
+	return false, false
+}
+
+// CorrectAnswer 按本次测验的选项顺序描述正确答案
+func (q *Question) CorrectAnswer() string {
+	switch q.Question.Type {
+	case TypeSingle, TypeMultiple:
+		answer := make(map[int]bool)
+		for _, s := range strings.Split(q.Question.Answer, ",") {
+			idx, _ := strconv.Atoi(s)
+			answer[idx] = true
+		}
+		var parts []string
+		for i, idx := range q.order {
+			if answer[idx] {
+				parts = append(parts, letter(i)+". "+q.Question.Options[idx])
+			}
+		}
+		return strings.Join(parts, "；")
+	case TypeTrueFalse:
+		if q.Question.Answer == "true" {
+			return "对"
+		}
+		return "错"
+	case TypeNumeric:
+		if q.Question.Tolerance > 0 {
+			return q.Question.Answer + "（允许误差 ±" + formatNumber(q.Question.Tolerance) + "）"
+		}
+		return q.Question.Answer
+	}
+	return q.Question.Answer
+}
+
+// AnswerHint 答案的格式说明，答案格式不正确时提示用户
+func (q *Question) AnswerHint() string {
+	switch q.Question.Type {
+	case TypeSingle:
+		return "单选题，请回复一个选项字母，例如 /answer B"
+	case TypeMultiple:
+		return "多选题，请回复所有正确选项的字母，例如 /answer AC"
+	case TypeTrueFalse:
+		return "判断题，请回复 对 或 错，例如 /answer 对"
+	case TypeNumeric:
+		hint := "数值题，请回复数值，例如 /answer 4.35"
+		if q.Question.Tolerance > 0 {
+			hint += "（允许误差 ±" + formatNumber(q.Question.Tolerance) + "）"
+		}
+		return hint
+	}
+	return ""
+}
+
+// normalizeAnswer 把题库文件中的答案转换为保存的标准格式：
+// 选择题为选项下标（多选按升序用逗号分隔），判断题为 true/false，数值题为数值
+// 选择题的答案可以写选项字母（例如 AC）或选项内容，多个选项内容用 | 分隔
+func normalizeAnswer(typ string, options []string, answer string) (string, bool) {
+	answer = strings.TrimSpace(answer)
+	switch typ {
+	case TypeSingle, TypeMultiple:
+		var indexes []int
+		if idx := indexOf(options, answer); idx >= 0 {
+			indexes = []int{idx}
+		} else if positions, ok := parseLetters(answer, len(options)); ok {
+			indexes = positions
+		} else {
+			for _, part := range strings.Split(answer, "|") {
+				part = strings.TrimSpace(part)
+				if idx := indexOf(options, part); idx >= 0 {
+					indexes = append(indexes, idx)
+				} else if positions, ok := parseLetters(part, len(options)); ok && len(positions) == 1 {
+					indexes = append(indexes, positions[0])
+				} else {
+					return "", false
+				}
+			}
+		}
+		if typ == TypeSingle && len(indexes) != 1 {
+			return "", false
+		}
+		return joinIndexes(indexes), true
+	case TypeTrueFalse:
+		v, ok := parseBool(answer)
+		return strconv.FormatBool(v), ok
+	case TypeNumeric:
+		v, ok := parseNumber(answer)
+		return formatNumber(v), ok
+	}
+	return "", false
+}
+
+// parseLetters 解析选项字母（例如 B、AC、A,C、a c、选B），返回去重后的选项位置
+func parseLetters(input string, n int) ([]int, bool) {
+	s := strings.ToUpper(strings.TrimSpace(input))
+	s = strings.TrimPrefix(s, "选")
+	s = strings.TrimRight(s, "。.!！")
+	seen := make(map[int]bool)
+	var positions []int
+	for _, r := range s {
+		if r >= 'Ａ' && r <= 'Ｚ' {
+			r = r - 'Ａ' + 'A'
+		}
+		switch {
+		case r == ',' || r == '，' || r == '、' || r == ' ' || r == '　':
+		case r >= 'A' && int(r-'A') < n:
+			if p := int(r - 'A'); !seen[p] {
+				seen[p] = true
+				positions = append(positions, p)
+			}
+		default:
+			return nil, false
+		}
+	}
+	return positions, len(positions) > 0
+}
+
+// parseBool 解析判断题的答案
+func parseBool(input string) (bool, bool) {
+	switch strings.ToLower(strings.TrimRight(strings.TrimSpace(input), "。.!！")) {
+	case "对", "正确", "是", "√", "✓", "✔", "true", "t", "yes", "y":
+		return true, true
+	case "错", "错误", "否", "不对", "×", "✗", "✘", "x", "false", "f", "no", "n":
+		return false, true
+	}
+	return false, false
+}
+
+// parseNumber 解析数值答案，允许千分位逗号、百分号和「约」，百分号不换算（答案和题目使用同一单位）
+func parseNumber(input string) (float64, bool) {
+	s := strings.TrimSpace(input)
+	s = strings.TrimPrefix(s, "约")
+	s = strings.TrimPrefix(s, "≈")
+	s = strings.TrimRight(s, "。")
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "%"), "％")
+	s = strings.ReplaceAll(strings.ReplaceAll(s, ",", ""), " ", "")
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, false
+	}
+	return v, true
+}
+
+// formatNumber 输出不带多余 0 的数值
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// joinIndexes 排序去重后用逗号连接选项下标
+func joinIndexes(indexes []int) string {
+	sorted := append([]int(nil), indexes...)
+	sort.Ints(sorted)
+	parts := make([]string, 0, len(sorted))
+	for i, idx := range sorted {
+		if i > 0 && idx == sorted[i-1] {
+			continue
+		}
+		parts = append(parts, strconv.Itoa(idx))
+	}
+	return strings.Join(parts, ",")
+}
+
+// indexOf 按内容查找选项
+func indexOf(options []string, s string) int {
+	for i, o := range options {
+		if o == s {
+			return i
+		}
+	}
+	return -1
+}
+
+// letter 选项位置对应的字母
+func letter(i int) string {
+	return string(rune('A' + i))
+}
@@ -0,0 +1,153 @@
+package quiz
+
+import (
+	"reflect"
+	"testing"
+
+	"fin_bot/storage"
+)
+
+// testQuestion 创建按 order 展示选项的题目，order 为 nil 时按题库中的顺序
+func testQuestion(typ, answer string, tolerance float64, order []int) *Question {
+	options := []string{"票息", "久期", "凸性", "信用利差"}
+	if order == nil {
+		order = []int{0, 1, 2, 3}
+	}
+	return &Question{
+		Question: &storage.QuizQuestion{Type: typ, Options: options, Answer: answer, Tolerance: tolerance},
+		order:    order,
+	}
+}
+
+func TestGrade(t *testing.T) {
+	tests := []struct {
+		name        string
+		q           *Question
+		input       string
+		wantCorrect bool
+		wantValid   bool
+	}{
+		{"单选正确", testQuestion(TypeSingle, "1", 0, nil), "B", true, true},
+		{"单选小写", testQuestion(TypeSingle, "1", 0, nil), "b", true, true},
+		{"单选全角字母", testQuestion(TypeSingle, "1", 0, nil), "Ｂ", true, true},
+		{"单选「选B」和句号", testQuestion(TypeSingle, "1", 0, nil), "选B。", true, true},
+		{"单选重复字母视为一个选项", testQuestion(TypeSingle, "1", 0, nil), "BB", true, true},
+		{"单选错误", testQuestion(TypeSingle, "1", 0, nil), "C", false, true},
+		{"单选回复多个字母", testQuestion(TypeSingle, "1", 0, nil), "AB", false, false},
+		{"单选超出选项范围", testQuestion(TypeSingle, "1", 0, nil), "E", false, false},
+		{"单选不是字母", testQuestion(TypeSingle, "1", 0, nil), "久期", false, false},
+		{"单选按打乱后的顺序作答", testQuestion(TypeSingle, "1", 0, []int{2, 3, 1, 0}), "C", true, true},
+		{"多选正确", testQuestion(TypeMultiple, "0,2", 0, nil), "AC", true, true},
+		{"多选顺序无关", testQuestion(TypeMultiple, "0,2", 0, nil), "CA", true, true},
+		{"多选分隔符", testQuestion(TypeMultiple, "0,2", 0, nil), "a，c", true, true},
+		{"多选「选AC」", testQuestion(TypeMultiple, "0,2", 0, nil), "选AC", true, true},
+		{"多选全角字母和全角空格", testQuestion(TypeMultiple, "0,2", 0, nil), "Ａ　Ｃ", true, true},
+		{"多选重复字母", testQuestion(TypeMultiple, "0,2", 0, nil), "ACA", true, true},
+		{"多选少选", testQuestion(TypeMultiple, "0,2", 0, nil), "A", false, true},
+		{"多选多选", testQuestion(TypeMultiple, "0,2", 0, nil), "ACD", false, true},
+		{"多选按打乱后的顺序作答", testQuestion(TypeMultiple, "0,2", 0, []int{2, 3, 1, 0}), "DA", true, true},
+		{"判断正确", testQuestion(TypeTrueFalse, "true", 0, nil), "对", true, true},
+		{"判断错误", testQuestion(TypeTrueFalse, "true", 0, nil), "×", false, true},
+		{"判断无法识别", testQuestion(TypeTrueFalse, "true", 0, nil), "不确定", false, false},
+		{"数值精确", testQuestion(TypeNumeric, "4.35", 0.05, nil), "4.35", true, true},
+		{"数值误差上边界", testQuestion(TypeNumeric, "4.35", 0.05, nil), "4.4", true, true},
+		{"数值误差下边界", testQuestion(TypeNumeric, "4.35", 0.05, nil), "4.3", true, true},
+		{"数值超出边界 1e-9", testQuestion(TypeNumeric, "4.35", 0.05, nil), "4.400000001", false, true},
+		{"数值超出边界", testQuestion(TypeNumeric, "4.35", 0.05, nil), "4.41", false, true},
+		{"数值浮点误差不判错", testQuestion(TypeNumeric, "0.3", 0.1, nil), "0.4", true, true},
+		{"数值没有误差", testQuestion(TypeNumeric, "0.3", 0, nil), "0.30", true, true},
+		{"数值百分号", testQuestion(TypeNumeric, "4.35", 0, nil), "4.35%", true, true},
+		{"数值千分位", testQuestion(TypeNumeric, "1234.5", 0, nil), "1,234.5", true, true},
+		{"数值不是数字", testQuestion(TypeNumeric, "4.35", 0.05, nil), "四点三五", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			correct, valid := tt.q.Grade(tt.input)
+			if correct != tt.wantCorrect || valid != tt.wantValid {
+				t.Errorf("Grade(%q) = %t, %t, want %t, %t", tt.input, correct, valid, tt.wantCorrect, tt.wantValid)
+			}
+		})
+	}
+}
+
+func TestParseLetters(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []int
+		ok    bool
+	}{
+		{"单个字母", "B", []int{1}, true},
+		{"保持输入顺序", "CA", []int{2, 0}, true},
+		{"重复字母去重", "AACA", []int{0, 2}, true},
+		{"全角字母", "ＡＣ", []int{0, 2}, true},
+		{"中英文分隔符", " a, c、d ", []int{0, 2, 3}, true},
+		{"选字前缀和感叹号", "选AC！", []int{0, 2}, true},
+		{"超出选项数", "AE", nil, false},
+		{"其他字符", "A或C", nil, false},
+		{"只有分隔符", "，", nil, false},
+		{"空", "", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseLetters(tt.input, 4)
+			if ok != tt.ok || (tt.ok && !reflect.DeepEqual(got, tt.want)) {
+				t.Errorf("parseLetters(%q) = %v, %t, want %v, %t", tt.input, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  float64
+		ok    bool
+	}{
+		{"小数", "4.35", 4.35, true},
+		{"负数", "-2.5", -2.5, true},
+		{"百分号不换算", "4.35%", 4.35, true},
+		{"全角百分号", "4.35％", 4.35, true},
+		{"千分位", "1,234,567.8", 1234567.8, true},
+		{"约和句号", "约 1,000。", 1000, true},
+		{"约等号", "≈3.14", 3.14, true},
+		{"科学计数法", "1e3", 1000, true},
+		{"不是数字", "abc", 0, false},
+		{"NaN", "NaN", 0, false},
+		{"无穷大", "Inf", 0, false},
+		{"只有百分号", "%", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseNumber(tt.input)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("parseNumber(%q) = %v, %t, want %v, %t", tt.input, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestJoinIndexes(t *testing.T) {
+	tests := []struct {
+		name    string
+		indexes []int
+		want    string
+	}{
+		{"单个", []int{1}, "1"},
+		{"排序", []int{3, 0, 2}, "0,2,3"},
+		{"去重", []int{2, 0, 2}, "0,2"},
+		{"空", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := append([]int(nil), tt.indexes...)
+			if got := joinIndexes(input); got != tt.want {
+				t.Errorf("joinIndexes(%v) = %q, want %q", tt.indexes, got, tt.want)
+			}
+			if !reflect.DeepEqual(input, tt.indexes) && tt.indexes != nil {
+				t.Errorf("joinIndexes 修改了传入的切片: %v", input)
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+package quiz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"fin_bot/progress"
+	"fin_bot/storage"
+)
+
+var (
+	// ErrBankNotFound 题库不存在
+	ErrBankNotFound = errors.New("题库不存在，发送 /quiz 查看所有题库")
+	// ErrNoActiveQuiz 没有进行中的测验
+	ErrNoActiveQuiz = errors.New("没有进行中的测验，发送 /quiz <题库> 开始测验")
+	// ErrAlreadyAnswered 测验已经不在这道题上（例如重复点击卡片按钮）
+	ErrAlreadyAnswered = errors.New("这道题已经回答过了")
+	// ErrBankChanged 题库重新导入后进行中的测验的题目已被删除
+	ErrBankChanged = errors.New("题库已更新，这次测验无法继续，请重新开始")
+)
+
+// InvalidAnswerError 答案的格式不符合题型
+type InvalidAnswerError struct {
+	Question *Question
+}
+
+func (e *InvalidAnswerError) Error() string {
+	return "答案格式不正确：" + e.Question.AnswerHint()
+}
+
+// Service 测验：导入题库，出题和判题，保存每次测验和每道题的作答
+// 题库所有应用共用，测验记录按应用、租户和用户隔离；每个用户同时只有一次进行中的测验
+type Service struct {
+	store    *storage.Storage
+	progress *progress.Tracker // 记录完成测验的学习事件，为 nil 时不记录
+	now      func() time.Time
+}
+
+// New 创建测验服务
+func New(store *storage.Storage) *Service {
+	return &Service{store: store, now: time.Now}
+}
+
+// SetProgressTracker 设置学习进度，需要在开始处理消息之前调用
+func (s *Service) SetProgressTracker(t *progress.Tracker) {
+	s.progress = t
+}
+
+// ImportResult 导入一个题库的结果
+type ImportResult struct {
+	BankID    string `json:"bank_id"`
+	Title     string `json:"title"`
+	Questions int    `json:"questions"`
+	storage.QuizImportStats
+}
+
+// Import 导入目录中的所有题库（已有题库按 id 更新，目录中没有的题库保持不变）
+// 任何文件有问题时返回 *LoadError，不导入任何题库
+func (s *Service) Import(ctx context.Context, dir string) ([]ImportResult, error) {
+	banks, err := Load(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]ImportResult, 0, len(banks))
+	for _, imp := range banks {
+		stats, err := s.store.ImportQuizBank(ctx, imp)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, ImportResult{
+			BankID:          imp.Bank.ID,
+			Title:           imp.Bank.Title,
+			Questions:       len(imp.Questions),
+			QuizImportStats: stats,
+		})
+		log.Printf("[quiz] 已导入题库 %s: 共 %d 题，新增 %d，更新 %d，删除 %d",
+			imp.Bank.ID, len(imp.Questions), stats.Added, stats.Updated, stats.Removed)
+	}
+	return results, nil
+}
+
+// Banks 获取所有题库
+func (s *Service) Banks(ctx context.Context) ([]*storage.QuizBank, error) {
+	return s.store.ListQuizBanks(ctx)
+}
+
+// FindBank 按 id（不区分大小写）或标题查找题库
+func (s *Service) FindBank(ctx context.Context, key string) (*storage.QuizBank, error) {
+	banks, err := s.store.ListQuizBanks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key = strings.Trim(strings.TrimSpace(key), "《》")
+	for _, b := range banks {
+		if strings.EqualFold(b.ID, key) || b.Title == key {
+			return b, nil
+		}
+	}
+	return nil, ErrBankNotFound
+}
+
+// BestScores 用户在各题库中得分率最高的一次测验（bank_id -> 测验）
+func (s *Service) BestScores(ctx context.Context, scope storage.Scope, userID string) (map[string]*storage.QuizAttempt, error) {
+	attempts, err := s.store.ListQuizAttempts(ctx, scope, userID, "")
+	if err != nil {
+		return nil, err
+	}
+	best := make(map[string]*storage.QuizAttempt)
+	for _, a := range attempts {
+		if b, ok := best[a.BankID]; !ok || a.Correct*b.Total() > b.Correct*a.Total() {
+			best[a.BankID] = a
+		}
+	}
+	return best, nil
+}
+
+// Start 开始一次测验：打乱题目顺序，n > 0 时只抽取其中 n 道题；用户进行中的测验会被放弃
+func (s *Service) Start(ctx context.Context, scope storage.Scope, userID, chatID string, bank *storage.QuizBank, n int) (*Question, error) {
+	questions, err := s.store.ListQuizQuestions(ctx, bank.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(questions) == 0 {
+		return nil, fmt.Errorf("题库《%s》中没有题目", bank.Title)
+	}
+
+	now := s.now()
+	a := &storage.QuizAttempt{
+		AppID:     scope.AppID,
+		TenantKey: scope.TenantKey,
+		UserID:    userID,
+		ChatID:    chatID,
+		BankID:    bank.ID,
+		Seed:      now.UnixNano(),
+		StartedAt: now,
+	}
+	r := rand.New(rand.NewSource(a.Seed))
+	r.Shuffle(len(questions), func(i, j int) { questions[i], questions[j] = questions[j], questions[i] })
+	if n > 0 && n < len(questions) {
+		questions = questions[:n]
+	}
+	for _, q := range questions {
+		a.QuestionIDs = append(a.QuestionIDs, q.ID)
+	}
+
+	if err := s.store.StartQuizAttempt(ctx, a); err != nil {
+		return nil, err
+	}
+	log.Printf("[quiz] 开始测验: attempt=%d, bank=%s, user=%s, questions=%d", a.ID, bank.ID, userID, a.Total())
+	return newQuestion(bank, a, questions[0]), nil
+}
+
+// Current 获取用户进行中的测验的当前题目，没有进行中的测验时返回 ErrNoActiveQuiz
+func (s *Service) Current(ctx context.Context, scope storage.Scope, userID string) (*Question, error) {
+	a, err := s.store.GetActiveQuizAttempt(ctx, scope, userID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrNoActiveQuiz
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.question(ctx, a)
+}
+
+// Stop 放弃用户进行中的测验
+func (s *Service) Stop(ctx context.Context, scope storage.Scope, userID string) (*storage.QuizAttempt, error) {
+	a, err := s.store.GetActiveQuizAttempt(ctx, scope, userID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrNoActiveQuiz
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.AbandonQuizAttempt(ctx, a.ID, s.now()); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Result 一道题的判题结果
+type Result struct {
+	Question *Question // 作答的题（作答前的状态，用于展示正确答案）
+	Input    string
+	Correct  bool
+	Attempt  *storage.QuizAttempt // 作答后的测验
+	Next     *Question            // 下一题，测验结束时为 nil
+}
+
+// Answer 回答当前题目，答案格式不正确时返回 *InvalidAnswerError 且不记录
+func (s *Service) Answer(ctx context.Context, q *Question, input string) (*Result, error) {
+	input = strings.TrimSpace(input)
+	correct, valid := q.Grade(input)
+	if !valid {
+		return nil, &InvalidAnswerError{Question: q}
+	}
+
+	a, recorded, err := s.store.AnswerQuizQuestion(ctx, &storage.QuizAnswer{
+		AttemptID:  q.Attempt.ID,
+		QuestionID: q.Question.ID,
+		Answer:     input,
+		Correct:    correct,
+		AnsweredAt: s.now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !recorded {
+		return nil, ErrAlreadyAnswered
+	}
+
+	result := &Result{Question: q, Input: input, Correct: correct, Attempt: a}
+	if a.Status == storage.QuizActive {
+		if result.Next, err = s.question(ctx, a); err != nil {
+			return nil, err
+		}
+	} else {
+		log.Printf("[quiz] 测验完成: attempt=%d, bank=%s, user=%s, score=%d/%d", a.ID, a.BankID, a.UserID, a.Correct, a.Total())
+		s.progress.Record(ctx, &storage.ProgressEvent{
+			AppID: a.AppID, TenantKey: a.TenantKey, UserID: a.UserID,
+			Kind: storage.ProgressQuizFinished, Ref: strconv.FormatInt(a.ID, 10),
+			Score: a.Correct, Total: a.Total(), OccurredAt: *a.FinishedAt,
+		})
+	}
+	return result, nil
+}
+
+// AnswerButton 通过卡片按钮回答：按钮对应的测验和题目必须是点击者进行中的测验的当前题目，
+// 否则返回 ErrAlreadyAnswered（例如点击了之前题目的按钮）
+func (s *Service) AnswerButton(ctx context.Context, scope storage.Scope, userID string, attemptID, questionID int64, input string) (*Result, error) {
+	q, err := s.Current(ctx, scope, userID)
+	if err != nil {
+		return nil, err
+	}
+	if q.Attempt.ID != attemptID || q.Question.ID != questionID {
+		return nil, ErrAlreadyAnswered
+	}
+	return s.Answer(ctx, q, input)
+}
+
+// Mistakes 获取一次测验中答错的题的序号
+func (s *Service) Mistakes(ctx context.Context, attemptID int64) ([]int, error) {
+	answers, err := s.store.ListQuizAnswers(ctx, attemptID)
+	if err != nil {
+		return nil, err
+	}
+	var positions []int
+	for _, ans := range answers {
+		if !ans.Correct {
+			positions = append(positions, ans.Position)
+		}
+	}
+	return positions, nil
+}
+
+// question 加载测验的当前题目；题目在重新导入时被删除的测验无法继续，标记为放弃
+func (s *Service) question(ctx context.Context, a *storage.QuizAttempt) (*Question, error) {
+	bank, err := s.store.GetQuizBank(ctx, a.BankID)
+	if err == nil {
+		var q *storage.QuizQuestion
+		if q, err = s.store.GetQuizQuestion(ctx, a.CurrentQuestionID()); err == nil {
+			return newQuestion(bank, a, q), nil
+		}
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		return nil, err
+	}
+	if err := s.store.AbandonQuizAttempt(ctx, a.ID, s.now()); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return nil, err
+	}
+	return nil, ErrBankChanged
+}
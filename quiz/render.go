@@ -0,0 +1,248 @@
+package quiz
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"fin_bot/card"
+	"fin_bot/command"
+)
+
+// ActionAnswer 题目卡片上答案按钮的交互名称
+const ActionAnswer = "quiz.answer"
+
+// questionCard 把一道题渲染为消息卡片，feedback 为上一题的判题结果（第一题为空）
+// 单选题和判断题带答案按钮，多选题和数值题通过 /answer 或直接回复作答
+func questionCard(q *Question, feedback []interface{}) *card.Card {
+	title := fmt.Sprintf("《%s》第 %d/%d 题 · %s", q.Bank.Title, q.Number(), q.Attempt.Total(), typeLabels[q.Question.Type])
+	cd := card.New(title, card.ColorBlue)
+	if len(feedback) > 0 {
+		cd.Add(feedback...)
+		cd.Add(card.Divider())
+	}
+	cd.Add(card.Markdown(q.Question.Prompt))
+
+	var options []string
+	for i, o := range q.Options() {
+		options = append(options, fmt.Sprintf("**%s.** %s", letter(i), o))
+	}
+	if len(options) > 0 {
+		cd.Add(card.Markdown(strings.Join(options, "\n")))
+	}
+
+	switch q.Question.Type {
+	case TypeSingle:
+		var buttons []interface{}
+		for i := range options {
+			buttons = append(buttons, card.Button(letter(i), card.ButtonDefault, answerValue(q, letter(i))))
+		}
+		cd.Add(card.Actions(buttons...))
+	case TypeTrueFalse:
+		cd.Add(card.Actions(
+			card.Button("对", card.ButtonPrimary, answerValue(q, "true")),
+			card.Button("错", card.ButtonDanger, answerValue(q, "false")),
+		))
+	}
+
+	hint := q.AnswerHint() + "，也可以直接回复答案"
+	if q.Question.Type == TypeSingle || q.Question.Type == TypeTrueFalse {
+		hint = "点击按钮作答，或回复 /answer <答案>"
+	}
+	return cd.Add(card.Note(hint + " · 发送 /quiz stop 结束测验"))
+}
+
+// resultCard 测验结束后的成绩卡片，mistakes 为答错的题的序号
+func resultCard(r *Result, mistakes []int) *card.Card {
+	a := r.Attempt
+	color := card.ColorOrange
+	if a.Correct == a.Total() {
+		color = card.ColorGreen
+	}
+	cd := card.New(fmt.Sprintf("《%s》测验完成", r.Question.Bank.Title), color)
+	cd.Add(feedback(r)...)
+	cd.Add(card.Divider())
+
+	summary := fmt.Sprintf("得分 **%d/%d**（%d%%）", a.Correct, a.Total(), a.Correct*100/a.Total())
+	if a.Correct == a.Total() {
+		summary += " 🎉 全部答对！"
+	}
+	if len(mistakes) > 0 {
+		numbers := make([]string, len(mistakes))
+		for i, p := range mistakes {
+			numbers[i] = strconv.Itoa(p)
+		}
+		summary += fmt.Sprintf("\n答错的题: 第 %s 题", strings.Join(numbers, "、"))
+	}
+	cd.Add(card.Markdown(summary))
+	return cd.Add(card.Note(fmt.Sprintf("发送 /quiz %s 再测一次，/quiz 查看所有题库", r.Question.Bank.ID)))
+}
+
+// feedback 一道题的判题结果，答错时给出正确答案和解析
+func feedback(r *Result) []interface{} {
+	n := r.Question.Number()
+	if r.Correct {
+		return []interface{}{card.Markdown(fmt.Sprintf("✅ 第 %d 题回答正确", n))}
+	}
+	text := fmt.Sprintf("❌ **第 %d 题回答错误**\n你的答案: %s\n正确答案: %s", n, displayInput(r), r.Question.CorrectAnswer())
+	if r.Question.Question.Explanation != "" {
+		text += "\n解析: " + r.Question.Question.Explanation
+	}
+	return []interface{}{card.Markdown(text)}
+}
+
+// displayInput 用户的答案，判断题按钮的 true/false 显示为对/错
+func displayInput(r *Result) string {
+	if r.Question.Question.Type == TypeTrueFalse {
+		if v, ok := parseBool(r.Input); ok && v {
+			return "对"
+		}
+		return "错"
+	}
+	return r.Input
+}
+
+// answerValue 答案按钮回传的数据
+func answerValue(q *Question, answer string) map[string]string {
+	return map[string]string{
+		command.ActionKey: ActionAnswer,
+		"attempt":         strconv.FormatInt(q.Attempt.ID, 10),
+		"question":        strconv.FormatInt(q.Question.ID, 10),
+		"answer":          answer,
+	}
+}
+
+// ActionBattle 答题赛题目卡片上答案按钮的交互名称
+const ActionBattle = "quiz.battle"
+
+// medals 答题赛前三名的图标
+var medals = []string{"🥇", "🥈", "🥉"}
+
+// battleCard 答题赛的卡片，设置 update_multi 使更新后群里所有成员看到最新内容
+func battleCard(title, color string) *card.Card {
+	cd := card.New(title, color)
+	cd.Config.UpdateMulti = true
+	return cd
+}
+
+// questionBattleCard 答题赛的当前题目，closed 为 true 时去掉答案按钮，公布正确答案、解析和答对的人
+func questionBattleCard(b *Battle, q *Question, closed bool) *card.Card {
+	title := fmt.Sprintf("答题赛 · 第 %d/%d 题 · %s", b.index+1, len(b.Questions), typeLabels[q.Question.Type])
+	color := card.ColorBlue
+	if closed {
+		color = card.ColorGrey
+	}
+	cd := battleCard(title, color)
+	cd.Add(card.Markdown(q.Question.Prompt))
+
+	var options []string
+	for i, o := range q.Options() {
+		options = append(options, fmt.Sprintf("**%s.** %s", letter(i), o))
+	}
+	if len(options) > 0 {
+		cd.Add(card.Markdown(strings.Join(options, "\n")))
+	}
+
+	if closed {
+		text := "正确答案: " + q.CorrectAnswer()
+		if q.Question.Explanation != "" {
+			text += "\n解析: " + q.Question.Explanation
+		}
+		cd.Add(card.Divider(), card.Markdown(text))
+		if len(b.correct) == 0 {
+			return cd.Add(card.Note("没有人答对"))
+		}
+		mentions := make([]string, len(b.correct))
+		for i, u := range b.correct {
+			mentions[i] = fmt.Sprintf("<at id=%s></at>", u)
+		}
+		return cd.Add(card.Markdown("✅ 答对: " + strings.Join(mentions, " ")))
+	}
+
+	switch q.Question.Type {
+	case TypeSingle:
+		var buttons []interface{}
+		for i := range options {
+			buttons = append(buttons, card.Button(letter(i), card.ButtonDefault, battleValue(b, letter(i))))
+		}
+		cd.Add(card.Actions(buttons...))
+	case TypeTrueFalse:
+		cd.Add(card.Actions(
+			card.Button("对", card.ButtonPrimary, battleValue(b, "true")),
+			card.Button("错", card.ButtonDanger, battleValue(b, "false")),
+		))
+	}
+
+	hint := "点击按钮作答"
+	switch q.Question.Type {
+	case TypeMultiple:
+		hint = "@机器人 回复全部正确选项的字母，例如 AC"
+	case TypeNumeric:
+		hint = "@机器人 回复数值"
+	}
+	return cd.Add(card.Note(fmt.Sprintf("%s · 限时 %s · %s · 每人只有第一次作答有效", hint, b.Timeout, scoringLabels[b.Scoring])))
+}
+
+// scoreboardCard 答题赛的计分板，每道题开始和结束时以及有人作答后原地更新
+func scoreboardCard(b *Battle) *card.Card {
+	var title, color, status string
+	switch {
+	case b.finished && b.stopped:
+		title, color, status = "答题赛已中断", card.ColorGrey, fmt.Sprintf("答题赛在第 %d/%d 题提前结束", b.index+1, len(b.Questions))
+	case b.finished:
+		title, color, status = "答题赛已结束", card.ColorGreen, "全部题目已结束"
+	case b.open:
+		title, color = "答题赛进行中", card.ColorOrange
+		status = fmt.Sprintf("第 %d/%d 题作答中 · 已作答 %d 人", b.index+1, len(b.Questions), len(b.answered))
+	default:
+		title, color = "答题赛进行中", card.ColorOrange
+		status = fmt.Sprintf("第 %d/%d 题已结束，稍后出下一题", b.index+1, len(b.Questions))
+	}
+	cd := battleCard(fmt.Sprintf("%s · 《%s》", title, b.Bank.Title), color)
+	cd.Add(card.Markdown(fmt.Sprintf("共 %d 题 · %s · 每题限时 %s\n%s", len(b.Questions), scoringLabels[b.Scoring], b.Timeout, status)))
+	cd.Add(card.Divider())
+	cd.Add(card.Markdown(rankingLines(b.ranking(), "还没有人作答")))
+	if b.finished {
+		return cd
+	}
+	return cd.Add(card.Note("管理员发送 /quizbattle stop 提前结束"))
+}
+
+// rankingCard 答题赛结束后的最终排名
+func rankingCard(b *Battle) *card.Card {
+	title := fmt.Sprintf("答题赛排名 · 《%s》", b.Bank.Title)
+	cd := battleCard(title, card.ColorGreen)
+	asked := b.index + 1
+	note := fmt.Sprintf("共 %d 题", asked)
+	if b.stopped && asked < len(b.Questions) {
+		note = fmt.Sprintf("答题赛提前结束，共进行 %d/%d 题", asked, len(b.Questions))
+	}
+	cd.Add(card.Markdown(rankingLines(b.ranking(), "没有人参加这场答题赛")))
+	return cd.Add(card.Note(note + " · " + scoringLabels[b.Scoring]))
+}
+
+// rankingLines 按名次列出参赛者的得分
+func rankingLines(ranking []*BattleScore, empty string) string {
+	if len(ranking) == 0 {
+		return empty
+	}
+	lines := make([]string, 0, len(ranking))
+	for _, s := range ranking {
+		rank := fmt.Sprintf("%d.", s.Rank)
+		if s.Rank <= len(medals) && s.Points > 0 {
+			rank = medals[s.Rank-1]
+		}
+		lines = append(lines, fmt.Sprintf("%s <at id=%s></at> %d 分（作答 %d 题）", rank, s.UserID, s.Points, s.Answers))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// battleValue 答题赛答案按钮回传的数据
+func battleValue(b *Battle, answer string) map[string]string {
+	return map[string]string{
+		command.ActionKey: ActionBattle,
+		"battle":          b.ID,
+		"question":        strconv.Itoa(b.index),
+		"answer":          answer,
+	}
+}
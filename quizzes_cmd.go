@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"fin_bot/config"
+	"fin_bot/quiz"
+)
+
+// runQuizzesImportCommand 导入题库目录中的 JSON 和 CSV 文件，-dry-run 时只校验文件
+func runQuizzesImportCommand(configPath string, args []string) int {
+	fs := newCLIFlags("quizzes import", "[-dir 题库目录] [-db 数据库] [-dry-run] [-format text|json]")
+	dir := fs.String("dir", "", "题库目录（默认使用配置中的 quizzes.dir）")
+	dbPath := fs.String("db", "", "数据库文件路径（默认使用配置中的 database.path）")
+	dryRun := fs.Bool("dry-run", false, "只校验题库文件，不写入数据库")
+	format := fs.String("format", "text", "输出格式: text 或 json")
+	fs.logFlag()
+	if code := fs.parse(args); code != exitOK {
+		return code
+	}
+	if *format != "text" && *format != "json" {
+		return fs.usageError("无效的输出格式: %s", *format)
+	}
+
+	cfg := loadConfig(configPath, false)
+	if cfg == nil {
+		return exitError
+	}
+	if *dir == "" {
+		*dir = cfg.Quizzes.Dir
+	}
+
+	var results []quiz.ImportResult
+	if *dryRun {
+		banks, err := quiz.Load(*dir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+		for _, imp := range banks {
+			results = append(results, quiz.ImportResult{BankID: imp.Bank.ID, Title: imp.Bank.Title, Questions: len(imp.Questions)})
+		}
+	} else {
+		store, err := openStorage(cfg, *dbPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "打开数据库失败: %v\n", err)
+			return exitError
+		}
+		defer store.Close()
+
+		results, err = quiz.New(store).Import(context.Background(), *dir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+	}
+
+	if *format == "json" {
+		if results == nil {
+			results = []quiz.ImportResult{}
+		}
+		if err := json.NewEncoder(os.Stdout).Encode(results); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+		return exitOK
+	}
+	for _, r := range results {
+		if *dryRun {
+			fmt.Printf("%s\t《%s》\t%d 题\n", r.BankID, r.Title, r.Questions)
+			continue
+		}
+		fmt.Printf("%s\t《%s》\t%d 题（新增 %d，更新 %d，未变 %d，删除 %d）\n",
+			r.BankID, r.Title, r.Questions, r.Added, r.Updated, r.Unchanged, r.Removed)
+	}
+	if *dryRun {
+		fmt.Fprintf(os.Stderr, "%s 中的 %d 个题库校验通过\n", *dir, len(results))
+	} else {
+		fmt.Fprintf(os.Stderr, "已从 %s 导入 %d 个题库\n", *dir, len(results))
+	}
+	return exitOK
+}
+
+// battleSettings 将配置转换为答题赛设置
+func battleSettings(c config.QuizzesConfig) quiz.BattleSettings {
+	return quiz.BattleSettings{
+		Timeout:      c.BattleTimeout,
+		MaxQuestions: c.BattleMaxQuestions,
+	}
+}
+
+// importQuizzes 服务启动和配置变更时自动导入题库目录，失败时保留数据库中已有的题库
+func importQuizzes(ctx context.Context, quizzes *quiz.Service, c config.QuizzesConfig) {
+	if !c.ImportOnLoad {
+		return
+	}
+	if _, err := os.Stat(c.Dir); errors.Is(err, os.ErrNotExist) {
+		log.Printf("[quiz] 题库目录 %s 不存在，跳过导入", c.Dir)
+		return
+	}
+	results, err := quizzes.Import(ctx, c.Dir)
+	if err != nil {
+		log.Printf("[警告] 导入题库失败，继续使用已导入的题库: %v", err)
+		return
+	}
+	fmt.Printf("已导入 %d 个题库: %s\n", len(results), c.Dir)
+}
@@ -0,0 +1,67 @@
+package quote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"fin_bot/command"
+	"fin_bot/marketdata"
+)
+
+// Commands 返回报价的聊天命令
+func (s *Service) Commands() []*command.Command {
+	return []*command.Command{
+		{
+			Name:         "quote",
+			Usage:        "/quote <代码或名称>",
+			Description:  "查询证券的最新价、涨跌、日内区间、成交量和 52 周区间（支持 A 股、港股和美股，例如 600519、00700.HK、AAPL、茅台）",
+			ReplyHandler: s.commandQuote,
+		},
+	}
+}
+
+// commandQuote 处理 /quote 命令
+func (s *Service) commandQuote(ctx context.Context, req *command.Request) (*command.Reply, error) {
+	if req.RawArgs == "" {
+		return nil, errors.New("缺少要查询的证券，例如 /quote 600519、/quote 00700.HK、/quote AAPL")
+	}
+	result, err := s.Lookup(ctx, req.RawArgs)
+	if err != nil {
+		return s.errorReply(req.RawArgs, err)
+	}
+	return s.reply(result)
+}
+
+// HandleAction 处理相近证券按钮
+func (s *Service) HandleAction(ctx context.Context, req *command.ActionRequest) (*command.Reply, error) {
+	sym, err := marketdata.ParseSymbol(req.Value["symbol"])
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.Get(ctx, sym)
+	if err != nil {
+		return s.errorReply(sym.String(), err)
+	}
+	return s.reply(result)
+}
+
+// reply 行情卡片回复
+func (s *Service) reply(result *Result) (*command.Reply, error) {
+	settings, loc := s.currentSettings()
+	return command.CardReply(quoteCard(result, settings, loc))
+}
+
+// errorReply 找不到证券或没有行情时的回复，其他错误原样返回
+func (s *Service) errorReply(query string, err error) (*command.Reply, error) {
+	var unknown *UnknownError
+	switch {
+	case errors.As(err, &unknown) && len(unknown.Suggestions) > 0:
+		return command.CardReply(suggestionsCard(unknown))
+	case errors.As(err, &unknown), errors.Is(err, marketdata.ErrNotFound):
+		return command.TextReply(fmt.Sprintf("没有找到证券「%s」，请检查代码或名称（例如 600519、00700.HK、AAPL）", query)), nil
+	case errors.Is(err, marketdata.ErrNoData):
+		return command.TextReply(fmt.Sprintf("「%s」暂时没有行情数据", query)), nil
+	}
+	return nil, err
+}
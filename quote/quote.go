@@ -0,0 +1,182 @@
+package quote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"fin_bot/marketdata"
+)
+
+// 涨跌颜色
+const (
+	UpRed   = "red"   // 红涨绿跌（A 股和港股的习惯）
+	UpGreen = "green" // 绿涨红跌（美股等海外市场的习惯）
+)
+
+// maxSuggestions 找不到证券时最多列出的相近证券数
+const maxSuggestions = 5
+
+// minSimilarCode 查找相近代码时最短的代码前缀
+const minSimilarCode = 3
+
+// Settings 可以在运行中修改的行情卡片设置
+type Settings struct {
+	UpColor  string // 上涨使用的颜色，UpRed 或 UpGreen
+	Timezone string // 数据时间使用的时区
+}
+
+// Service 证券报价：按代码或名称查询最新报价、日内区间和 52 周区间，生成行情卡片
+type Service struct {
+	market marketdata.Provider
+
+	settingsMu sync.RWMutex // 保护 settings 和 loc，配置热加载时会被修改
+	settings   Settings
+	loc        *time.Location
+}
+
+// New 创建报价服务，market 为行情来源（通常是带缓存的 *marketdata.Cache）
+func New(market marketdata.Provider, settings Settings) *Service {
+	s := &Service{market: market}
+	s.SetSettings(settings)
+	return s
+}
+
+// SetSettings 修改行情卡片设置，时区无效时使用 UTC（配置校验保证时区有效）
+func (s *Service) SetSettings(settings Settings) {
+	loc, err := time.LoadLocation(settings.Timezone)
+	if err != nil {
+		log.Printf("[quote] 无效的时区 %q，使用 UTC: %v", settings.Timezone, err)
+		loc = time.UTC
+	}
+	s.settingsMu.Lock()
+	s.settings = settings
+	s.loc = loc
+	s.settingsMu.Unlock()
+}
+
+// currentSettings 获取当前的行情卡片设置和时区
+func (s *Service) currentSettings() (Settings, *time.Location) {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return s.settings, s.loc
+}
+
+// UnknownError 找不到要查询的证券，Suggestions 为名称或代码相近的证券
+type UnknownError struct {
+	Query       string
+	Suggestions []*marketdata.Instrument
+}
+
+func (e *UnknownError) Error() string {
+	return fmt.Sprintf("没有找到证券「%s」", e.Query)
+}
+
+// Range 价格区间
+type Range struct {
+	Low  float64
+	High float64
+}
+
+// Result 一个证券的报价
+type Result struct {
+	Quote  *marketdata.Quote
+	Year   *Range // 52 周区间（含当日），没有日线数据时为 nil
+	Source string // 行情来源的名称
+}
+
+// Lookup 按代码、名称或别名查询报价：先按代码查询，找不到时搜索名称，
+// 搜索结果唯一或与名称完全相同时直接查询该证券，否则返回 *UnknownError 和相近的证券
+func (s *Service) Lookup(ctx context.Context, query string) (*Result, error) {
+	query = strings.TrimSpace(query)
+	sym, symErr := marketdata.ParseSymbol(query)
+	if symErr == nil {
+		result, err := s.Get(ctx, sym)
+		if !errors.Is(err, marketdata.ErrNotFound) {
+			return result, err
+		}
+	}
+
+	matches, err := s.market.Search(ctx, query, maxSuggestions)
+	if err != nil {
+		return nil, err
+	}
+	if inst := pick(matches, query); inst != nil {
+		return s.Get(ctx, inst.Symbol)
+	}
+	if len(matches) == 0 && symErr == nil {
+		// 代码可能输错了最后几位，按越来越短的前缀查找相近的代码
+		for n := len(sym.Code) - 1; n >= minSimilarCode && len(matches) == 0; n-- {
+			if matches, err = s.market.Search(ctx, sym.Code[:n], maxSuggestions); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return nil, &UnknownError{Query: query, Suggestions: matches}
+}
+
+// Resolve 按代码、名称或别名查找证券，找不到时返回 *UnknownError
+// 证券存在但暂时没有行情时也返回该证券（用于加入自选、创建提醒等）
+func (s *Service) Resolve(ctx context.Context, query string) (marketdata.Symbol, error) {
+	result, err := s.Lookup(ctx, query)
+	if err == nil {
+		return result.Quote.Symbol, nil
+	}
+	if errors.Is(err, marketdata.ErrNoData) {
+		if sym, perr := marketdata.ParseSymbol(query); perr == nil {
+			return sym, nil
+		}
+	}
+	return marketdata.Symbol{}, err
+}
+
+// pick 从搜索结果中选出要查询的证券：只有一个结果，或者有且只有一个名称、别名与查询完全相同
+func pick(matches []*marketdata.Instrument, query string) *marketdata.Instrument {
+	if len(matches) == 1 {
+		return matches[0]
+	}
+	var exact *marketdata.Instrument
+	for _, inst := range matches {
+		for _, name := range append([]string{inst.Name}, inst.Aliases...) {
+			if strings.EqualFold(name, query) {
+				if exact != nil && exact != inst {
+					return nil
+				}
+				exact = inst
+			}
+		}
+	}
+	return exact
+}
+
+// Get 查询证券的报价和 52 周区间，证券不存在时返回 marketdata.ErrNotFound
+// 日线查询失败时只记录日志，报价中不包含 52 周区间
+func (s *Service) Get(ctx context.Context, sym marketdata.Symbol) (*Result, error) {
+	q, err := s.market.Quote(ctx, sym)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Quote: q, Source: s.market.Name()}
+
+	to := marketdata.Day(q.Time)
+	bars, err := s.market.History(ctx, sym, to.AddDate(-1, 0, 0), to)
+	if err != nil {
+		log.Printf("[quote] 查询 %s 的日线失败: %v", sym, err)
+		return result, nil
+	}
+	if len(bars) > 0 {
+		year := &Range{Low: q.Low, High: q.High}
+		for _, b := range bars {
+			if year.Low == 0 || b.Low < year.Low {
+				year.Low = b.Low
+			}
+			year.High = max(year.High, b.High)
+		}
+		result.Year = year
+	}
+	return result, nil
+}
@@ -0,0 +1,159 @@
+package quote
+
+import (
+	"fmt"
+	"time"
+
+	"fin_bot/card"
+	"fin_bot/command"
+	"fin_bot/marketdata"
+)
+
+// ActionQuote 相近证券按钮的交互名称
+const ActionQuote = "quote.show"
+
+// quoteCard 行情卡片：最新价、涨跌、日内区间、成交量和 52 周区间，标题栏和涨跌按设置的颜色区分涨跌
+func quoteCard(r *Result, settings Settings, loc *time.Location) *card.Card {
+	q := r.Quote
+	color := changeColor(q.Change(), settings.UpColor)
+	cd := card.New(fmt.Sprintf("%s %s", q.Name, q.Symbol), color)
+
+	change := "—"
+	if q.PrevClose > 0 {
+		change = card.Colored(fmt.Sprintf("%s %s（%+.2f%%）", arrow(q.Change()), signed(q.Change(), q.Price), q.ChangePercent()), color)
+	}
+	cd.Add(card.Markdown(fmt.Sprintf("**%s** %s　%s", price(q.Price, q.Price), q.Currency, change)))
+
+	dayRange := "—"
+	if q.High > 0 {
+		dayRange = price(q.Low, q.Price) + " ~ " + price(q.High, q.Price)
+	}
+	yearRange := "—"
+	if r.Year != nil {
+		yearRange = price(r.Year.Low, q.Price) + " ~ " + price(r.Year.High, q.Price)
+	}
+	prevClose := "—"
+	if q.PrevClose > 0 {
+		prevClose = price(q.PrevClose, q.Price)
+	}
+	open := "—"
+	if q.Open > 0 {
+		open = price(q.Open, q.Price)
+	}
+	cd.Add(card.Fields(
+		card.Field{Short: true, Content: "**今开**\n" + open},
+		card.Field{Short: true, Content: "**昨收**\n" + prevClose},
+		card.Field{Short: true, Content: "**日内区间**\n" + dayRange},
+		card.Field{Short: true, Content: "**成交量**\n" + volume(q.Volume)},
+		card.Field{Short: true, Content: "**52 周区间**\n" + yearRange},
+		card.Field{Short: true, Content: "**市场**\n" + q.Symbol.Market.Label()},
+	))
+
+	scheme := "红涨绿跌"
+	if settings.UpColor == UpGreen {
+		scheme = "绿涨红跌"
+	}
+	return cd.Add(card.Note(fmt.Sprintf("数据时间 %s · 来源 %s · %s", asOf(q.Time, loc), r.Source, scheme)))
+}
+
+// Brief 一行的报价摘要（名称、代码、最新价和按设置着色的涨跌幅），用于自选列表等汇总卡片
+func (s *Service) Brief(q *marketdata.Quote) string {
+	settings, _ := s.currentSettings()
+	change := "—"
+	if q.PrevClose > 0 {
+		change = card.Colored(fmt.Sprintf("%s %+.2f%%", arrow(q.Change()), q.ChangePercent()), changeColor(q.Change(), settings.UpColor))
+	}
+	return fmt.Sprintf("**%s** %s　%s　%s", q.Name, q.Symbol, price(q.Price, q.Price), change)
+}
+
+// AsOf 按设置的时区格式化报价的数据时间
+func (s *Service) AsOf(t time.Time) string {
+	_, loc := s.currentSettings()
+	return asOf(t, loc)
+}
+
+// suggestionsCard 找不到证券时列出相近的证券，点击按钮查询
+func suggestionsCard(e *UnknownError) *card.Card {
+	cd := card.New("没有找到「"+e.Query+"」", card.ColorOrange)
+	cd.Add(card.Markdown("你要找的是不是："))
+	var buttons []interface{}
+	for _, inst := range e.Suggestions {
+		buttons = append(buttons, card.Button(fmt.Sprintf("%s %s", inst.Name, inst.Symbol), card.ButtonDefault, map[string]string{
+			command.ActionKey: ActionQuote,
+			"symbol":          inst.Symbol.String(),
+		}))
+	}
+	cd.Add(card.Actions(buttons...))
+	return cd.Add(card.Note("也可以发送 /quote <代码或名称> 重新查询，例如 /quote 600519、/quote 00700.HK、/quote AAPL"))
+}
+
+// changeColor 涨跌对应的颜色，平盘为灰色
+func changeColor(change float64, upColor string) string {
+	up, down := card.ColorRed, card.ColorGreen
+	if upColor == UpGreen {
+		up, down = card.ColorGreen, card.ColorRed
+	}
+	switch {
+	case change > 0:
+		return up
+	case change < 0:
+		return down
+	default:
+		return card.ColorGrey
+	}
+}
+
+// arrow 涨跌箭头
+func arrow(change float64) string {
+	switch {
+	case change > 0:
+		return "▲"
+	case change < 0:
+		return "▼"
+	default:
+		return "■"
+	}
+}
+
+// decimals 价格的小数位数：低价证券（例如 ETF）保留 3 位，其他保留 2 位
+func decimals(ref float64) int {
+	if ref > 0 && ref < 10 {
+		return 3
+	}
+	return 2
+}
+
+// price 按参考价格的精度格式化价格
+func price(v, ref float64) string {
+	return fmt.Sprintf("%.*f", decimals(ref), v)
+}
+
+// signed 带正负号的涨跌额
+func signed(v, ref float64) string {
+	return fmt.Sprintf("%+.*f", decimals(ref), v)
+}
+
+// volume 成交量，使用万、亿为单位
+func volume(v int64) string {
+	switch f := float64(v); {
+	case v <= 0:
+		return "—"
+	case f >= 1e8:
+		return fmt.Sprintf("%.2f 亿", f/1e8)
+	case f >= 1e4:
+		return fmt.Sprintf("%.2f 万", f/1e4)
+	default:
+		return fmt.Sprintf("%d", v)
+	}
+}
+
+// asOf 数据时间：日线数据（UTC 零点）只显示日期并注明收盘，其他按设置的时区显示到分钟
+func asOf(t time.Time, loc *time.Location) string {
+	if t.IsZero() {
+		return "未知"
+	}
+	if t.Equal(marketdata.Day(t)) {
+		return t.Format(marketdata.DateLayout) + " 收盘"
+	}
+	return t.In(loc).Format("2006-01-02 15:04 MST")
+}
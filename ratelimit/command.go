@@ -0,0 +1,123 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"fin_bot/command"
+	"fin_bot/storage"
+)
+
+// Commands 返回管理封禁名单的聊天命令（仅管理员可用）
+// 封禁只作用于执行命令的应用和租户
+func (l *Limiter) Commands() []*command.Command {
+	return []*command.Command{
+		{
+			Name:        "ban",
+			Usage:       "/ban <open_id> <时长，例如 30m、2h、7d> [原因]",
+			Description: "暂时禁止用户使用机器人",
+			MinRole:     command.RoleAdmin,
+			Handler:     l.commandBan,
+		},
+		{
+			Name:        "unban",
+			Usage:       "/unban <open_id>",
+			Description: "解除对用户的禁止",
+			MinRole:     command.RoleAdmin,
+			Handler:     l.commandUnban,
+		},
+		{
+			Name:        "bans",
+			Usage:       "/bans",
+			Description: "查看被禁止使用机器人的用户",
+			MinRole:     command.RoleAdmin,
+			Handler:     l.commandBans,
+		},
+	}
+}
+
+// commandBan 处理 /ban 命令
+func (l *Limiter) commandBan(ctx context.Context, req *command.Request) (string, error) {
+	if len(req.Args) < 2 {
+		return "", errors.New("缺少用户或时长")
+	}
+	duration, err := parseDuration(req.Args[1])
+	if err != nil {
+		return "", err
+	}
+
+	ban := &storage.Ban{
+		AppID:     req.AppID,
+		TenantKey: req.TenantKey,
+		UserID:    req.Args[0],
+		Reason:    strings.Join(req.Args[2:], " "),
+		CreatedBy: req.SenderID,
+		Until:     l.now().Add(duration),
+	}
+	if err := l.Ban(ctx, ban); err != nil {
+		return "", err
+	}
+
+	reply := fmt.Sprintf("已禁止 %s 使用机器人，将于 %s 解除", ban.UserID, ban.Until.Local().Format("2006-01-02 15:04"))
+	if ban.Reason != "" {
+		reply += "，原因: " + ban.Reason
+	}
+	return reply, nil
+}
+
+// commandUnban 处理 /unban 命令
+func (l *Limiter) commandUnban(ctx context.Context, req *command.Request) (string, error) {
+	if len(req.Args) != 1 {
+		return "", errors.New("需要指定一个用户")
+	}
+	scope := storage.Scope{AppID: req.AppID, TenantKey: req.TenantKey}
+	if err := l.Unban(ctx, scope, req.Args[0]); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Sprintf("%s 未被禁止", req.Args[0]), nil
+		}
+		return "", err
+	}
+	return fmt.Sprintf("已解除对 %s 的禁止", req.Args[0]), nil
+}
+
+// commandBans 处理 /bans 命令
+func (l *Limiter) commandBans(ctx context.Context, req *command.Request) (string, error) {
+	bans, err := l.Bans(ctx, storage.Scope{AppID: req.AppID, TenantKey: req.TenantKey})
+	if err != nil {
+		return "", err
+	}
+	if len(bans) == 0 {
+		return "暂无被禁止的用户", nil
+	}
+
+	var b strings.Builder
+	b.WriteString("被禁止的用户:")
+	for _, ban := range bans {
+		fmt.Fprintf(&b, "\n%s 至 %s（操作人 %s）", ban.UserID, ban.Until.Local().Format("2006-01-02 15:04"), ban.CreatedBy)
+		if ban.Reason != "" {
+			fmt.Fprintf(&b, " 原因: %s", ban.Reason)
+		}
+	}
+	return b.String(), nil
+}
+
+// parseDuration 解析封禁时长，在 time.ParseDuration 的基础上支持天（例如 7d）
+func parseDuration(s string) (time.Duration, error) {
+	var d time.Duration
+	var err error
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		var n int
+		n, err = strconv.Atoi(days)
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err = time.ParseDuration(s)
+	}
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("无效的时长: %s", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,338 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"fin_bot/metrics"
+	"fin_bot/storage"
+)
+
+// flushInterval 令牌桶状态写入数据库的间隔，重启最多丢失这段时间内的消耗
+const flushInterval = 30 * time.Second
+
+// 被拒绝的原因
+const (
+	ReasonUser   = "user"   // 用户发送过于频繁
+	ReasonChat   = "chat"   // 会话内消息过多
+	ReasonGlobal = "global" // 机器人整体负载过高
+	ReasonBanned = "banned" // 用户被管理员临时禁止
+)
+
+// Settings 限流参数，rate 为每秒补充的令牌数，burst 为桶容量
+type Settings struct {
+	Enabled       bool
+	UserRate      float64
+	UserBurst     int
+	ChatRate      float64
+	ChatBurst     int
+	GlobalRate    float64
+	GlobalBurst   int
+	ReplyCooldown time.Duration // 同一用户两次限流提示之间的最短间隔
+}
+
+// Decision 一条消息的限流结果
+type Decision struct {
+	Allowed    bool
+	Reason     string        // 被拒绝的原因（Reason* 常量）
+	RetryAfter time.Duration // 预计多久之后可以再次发送
+	Notify     bool          // 是否需要回复提示（冷却时间内只提示一次，其余消息静默丢弃）
+	Ban        *storage.Ban  // Reason 为 ReasonBanned 时的封禁记录
+}
+
+// Message 回复给被限流用户的提示
+func (d Decision) Message() string {
+	switch d.Reason {
+	case ReasonBanned:
+		return fmt.Sprintf("你已被管理员暂时禁止使用机器人，将于 %s 解除", d.Ban.Until.Local().Format("2006-01-02 15:04"))
+	case ReasonGlobal:
+		return "机器人当前比较繁忙，请稍后再试"
+	default:
+		return fmt.Sprintf("消息发送得太快了，请 %d 秒后再试", int(math.Ceil(d.RetryAfter.Seconds())))
+	}
+}
+
+// bucket 令牌桶，tokens 为 updated 时刻的令牌数
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// refill 按经过的时间补充令牌，不超过容量
+func (b *bucket) refill(now time.Time, rate float64, burst int) {
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * rate
+	}
+	b.tokens = math.Min(b.tokens, float64(burst))
+	b.updated = now
+}
+
+// Limiter 按用户、会话和全局三级令牌桶限流，并维护管理员设置的临时封禁名单
+// 令牌桶在内存中计算，定期和停止时写入数据库；封禁立即写入数据库
+type Limiter struct {
+	store *storage.Storage
+	now   func() time.Time
+
+	mu       sync.Mutex
+	settings Settings
+	buckets  map[string]*bucket
+	notified map[string]time.Time // 各用户最近一次收到限流提示的时间
+	bans     map[string]*storage.Ban
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New 创建限流器，需要调用 Start 恢复保存的状态
+func New(store *storage.Storage, settings Settings) *Limiter {
+	return &Limiter{
+		store:    store,
+		now:      time.Now,
+		settings: settings,
+		buckets:  make(map[string]*bucket),
+		notified: make(map[string]time.Time),
+		bans:     make(map[string]*storage.Ban),
+	}
+}
+
+// SetSettings 替换限流参数（配置热加载），已有的令牌按新容量截断
+func (l *Limiter) SetSettings(settings Settings) {
+	l.mu.Lock()
+	l.settings = settings
+	l.mu.Unlock()
+}
+
+// Start 从数据库恢复令牌桶和封禁名单，并在后台定期保存
+func (l *Limiter) Start(ctx context.Context) error {
+	buckets, err := l.store.LoadRateBuckets(ctx)
+	if err != nil {
+		return err
+	}
+	bans, err := l.store.ListActiveBans(ctx, l.now())
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	for _, b := range buckets {
+		l.buckets[b.Key] = &bucket{tokens: b.Tokens, updated: b.UpdatedAt}
+	}
+	for _, ban := range bans {
+		l.bans[banKey(ban.Scope(), ban.UserID)] = ban
+	}
+	runCtx, cancel := context.WithCancel(ctx)
+	l.cancel = cancel
+	l.done = make(chan struct{})
+	l.mu.Unlock()
+
+	log.Printf("[ratelimit] 已恢复 %d 个令牌桶、%d 条封禁记录", len(buckets), len(bans))
+	go l.loop(runCtx)
+	return nil
+}
+
+// Stop 停止后台任务并保存最终状态
+func (l *Limiter) Stop(ctx context.Context) error {
+	l.mu.Lock()
+	cancel, done := l.cancel, l.done
+	l.mu.Unlock()
+	if cancel == nil {
+		return nil
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return l.Flush(ctx)
+}
+
+// loop 定期保存令牌桶状态
+func (l *Limiter) loop(ctx context.Context) {
+	defer close(l.done)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.Flush(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("[ratelimit] 保存限流状态失败: %v", err)
+			}
+		}
+	}
+}
+
+// Flush 保存未满的令牌桶，并清理内存中已满的桶、过期的提示记录和封禁
+// 已满的桶与新建的桶相同，不需要保存
+func (l *Limiter) Flush(ctx context.Context) error {
+	now := l.now()
+
+	l.mu.Lock()
+	snapshot := make([]storage.RateBucket, 0, len(l.buckets))
+	for key, b := range l.buckets {
+		rate, burst := l.limitFor(key)
+		b.refill(now, rate, burst)
+		if b.tokens >= float64(burst) {
+			delete(l.buckets, key)
+			continue
+		}
+		snapshot = append(snapshot, storage.RateBucket{Key: key, Tokens: b.tokens, UpdatedAt: b.updated})
+	}
+	for key, at := range l.notified {
+		if now.Sub(at) >= l.settings.ReplyCooldown {
+			delete(l.notified, key)
+		}
+	}
+	for key, ban := range l.bans {
+		if !ban.Until.After(now) {
+			delete(l.bans, key)
+		}
+	}
+	l.mu.Unlock()
+
+	if err := l.store.SaveRateBuckets(ctx, snapshot); err != nil {
+		return err
+	}
+	return l.store.PurgeExpiredBans(ctx, now)
+}
+
+// Allow 判断一条消息是否可以处理，允许时消耗用户、会话和全局桶各一个令牌
+// userID 或 chatID 为空时跳过对应的桶；封禁在关闭限流时同样生效
+func (l *Limiter) Allow(scope storage.Scope, chatID, userID string) Decision {
+	now := l.now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	userKey := ""
+	if userID != "" {
+		userKey = "user:" + scopeKey(scope) + ":" + userID
+		if ban, ok := l.bans[banKey(scope, userID)]; ok {
+			if ban.Until.After(now) {
+				return l.deny(Decision{Reason: ReasonBanned, RetryAfter: ban.Until.Sub(now), Ban: ban}, userKey, now)
+			}
+			delete(l.bans, banKey(scope, userID))
+		}
+	}
+	if !l.settings.Enabled {
+		return Decision{Allowed: true}
+	}
+
+	var keys []string
+	if userKey != "" {
+		keys = append(keys, userKey)
+	}
+	if chatID != "" {
+		keys = append(keys, "chat:"+scopeKey(scope)+":"+chatID)
+	}
+	keys = append(keys, "global")
+
+	// 先检查所有桶，全部有令牌时才一起扣减，避免被拒绝的消息消耗其他桶的令牌
+	checked := make([]*bucket, len(keys))
+	for i, key := range keys {
+		rate, burst := l.limitFor(key)
+		b, ok := l.buckets[key]
+		if !ok {
+			b = &bucket{tokens: float64(burst), updated: now}
+			l.buckets[key] = b
+		}
+		b.refill(now, rate, burst)
+		if b.tokens < 1 {
+			retry := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+			notifyKey := userKey
+			if notifyKey == "" {
+				notifyKey = keys[0]
+			}
+			return l.deny(Decision{Reason: reasonFor(key), RetryAfter: retry}, notifyKey, now)
+		}
+		checked[i] = b
+	}
+	for _, b := range checked {
+		b.tokens--
+	}
+	return Decision{Allowed: true}
+}
+
+// deny 记录被拒绝的消息，并按冷却时间决定是否提示
+func (l *Limiter) deny(d Decision, notifyKey string, now time.Time) Decision {
+	metrics.RateLimited.WithLabelValues(d.Reason).Inc()
+	if last, ok := l.notified[notifyKey]; !ok || now.Sub(last) >= l.settings.ReplyCooldown {
+		l.notified[notifyKey] = now
+		d.Notify = true
+	}
+	return d
+}
+
+// limitFor 按桶的类型返回补充速率和容量
+func (l *Limiter) limitFor(key string) (float64, int) {
+	switch reasonFor(key) {
+	case ReasonUser:
+		return l.settings.UserRate, l.settings.UserBurst
+	case ReasonChat:
+		return l.settings.ChatRate, l.settings.ChatBurst
+	default:
+		return l.settings.GlobalRate, l.settings.GlobalBurst
+	}
+}
+
+// reasonFor 桶对应的拒绝原因（桶键的前缀）
+func reasonFor(key string) string {
+	kind, _, _ := strings.Cut(key, ":")
+	return kind
+}
+
+// Ban 禁止用户在 until 之前使用机器人，同一用户重复封禁时覆盖原记录
+func (l *Limiter) Ban(ctx context.Context, ban *storage.Ban) error {
+	if err := l.store.SaveBan(ctx, ban); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	l.bans[banKey(ban.Scope(), ban.UserID)] = ban
+	l.mu.Unlock()
+	return nil
+}
+
+// Unban 解除封禁，用户未被封禁时返回 storage.ErrNotFound
+func (l *Limiter) Unban(ctx context.Context, scope storage.Scope, userID string) error {
+	if err := l.store.DeleteBan(ctx, scope, userID); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	delete(l.bans, banKey(scope, userID))
+	delete(l.notified, "user:"+scopeKey(scope)+":"+userID)
+	l.mu.Unlock()
+	return nil
+}
+
+// Bans 获取指定范围内仍然有效的封禁记录
+func (l *Limiter) Bans(ctx context.Context, scope storage.Scope) ([]*storage.Ban, error) {
+	all, err := l.store.ListActiveBans(ctx, l.now())
+	if err != nil {
+		return nil, err
+	}
+	var bans []*storage.Ban
+	for _, ban := range all {
+		if ban.Scope() == scope {
+			bans = append(bans, ban)
+		}
+	}
+	return bans, nil
+}
+
+// scopeKey 应用和租户组成的桶键前缀
+func scopeKey(scope storage.Scope) string {
+	return scope.AppID + "/" + scope.TenantKey
+}
+
+// banKey 封禁名单的键
+func banKey(scope storage.Scope, userID string) string {
+	return scopeKey(scope) + ":" + userID
+}
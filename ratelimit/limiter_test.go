@@ -0,0 +1,166 @@
+package ratelimit
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"fin_bot/storage"
+)
+
+// fakeClock 可手动推进的时钟
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestLimiter(settings Settings) (*Limiter, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)}
+	l := New(nil, settings)
+	l.now = clock.now
+	return l, clock
+}
+
+var testSettings = Settings{
+	Enabled:       true,
+	UserRate:      1,
+	UserBurst:     2,
+	ChatRate:      10,
+	ChatBurst:     4,
+	GlobalRate:    100,
+	GlobalBurst:   100,
+	ReplyCooldown: 10 * time.Second,
+}
+
+func TestAllow(t *testing.T) {
+	scope := storage.Scope{AppID: "cli_a", TenantKey: "t"}
+	type msg struct {
+		after  time.Duration // 距离上一条消息的时间
+		chat   string
+		user   string
+		allow  bool
+		reason string
+		notify bool
+	}
+
+	tests := []struct {
+		name     string
+		settings Settings
+		msgs     []msg
+	}{
+		{
+			name:     "用户桶耗尽后按速率恢复",
+			settings: testSettings,
+			msgs: []msg{
+				{0, "oc_1", "ou_1", true, "", false},
+				{0, "oc_1", "ou_1", true, "", false},
+				{0, "oc_1", "ou_1", false, ReasonUser, true},
+				{0, "oc_1", "ou_1", false, ReasonUser, false}, // 冷却时间内不再提示
+				{time.Second, "oc_1", "ou_1", true, "", false},
+				{0, "oc_1", "ou_2", true, "", false}, // 其他用户不受影响
+			},
+		},
+		{
+			name:     "会话桶在多个用户之间共享",
+			settings: testSettings,
+			msgs: []msg{
+				{0, "oc_1", "ou_1", true, "", false},
+				{0, "oc_1", "ou_2", true, "", false},
+				{0, "oc_1", "ou_3", true, "", false},
+				{0, "oc_1", "ou_4", true, "", false},
+				{0, "oc_1", "ou_5", false, ReasonChat, true},
+				{0, "oc_2", "ou_5", true, "", false},
+			},
+		},
+		{
+			name:     "被拒绝的消息不消耗其他桶",
+			settings: Settings{Enabled: true, UserRate: 1, UserBurst: 1, ChatRate: 0.1, ChatBurst: 2, GlobalRate: 100, GlobalBurst: 100},
+			msgs: []msg{
+				{0, "oc_1", "ou_1", true, "", false},
+				{0, "oc_1", "ou_1", false, ReasonUser, true},
+				{0, "oc_1", "ou_1", false, ReasonUser, true},
+				{0, "oc_1", "ou_2", true, "", false}, // 会话桶仍剩一个令牌
+				{0, "oc_1", "ou_3", false, ReasonChat, true},
+			},
+		},
+		{
+			name:     "全局桶",
+			settings: Settings{Enabled: true, UserRate: 10, UserBurst: 10, ChatRate: 10, ChatBurst: 10, GlobalRate: 1, GlobalBurst: 1},
+			msgs: []msg{
+				{0, "oc_1", "ou_1", true, "", false},
+				{0, "oc_2", "ou_2", false, ReasonGlobal, true},
+			},
+		},
+		{
+			name:     "关闭限流",
+			settings: Settings{},
+			msgs: []msg{
+				{0, "oc_1", "ou_1", true, "", false},
+				{0, "oc_1", "ou_1", true, "", false},
+				{0, "oc_1", "ou_1", true, "", false},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, clock := newTestLimiter(tt.settings)
+			for i, m := range tt.msgs {
+				clock.advance(m.after)
+				d := l.Allow(scope, m.chat, m.user)
+				if d.Allowed != m.allow || d.Reason != m.reason || d.Notify != m.notify {
+					t.Errorf("msg %d: got allowed=%v reason=%q notify=%v, want %v %q %v",
+						i, d.Allowed, d.Reason, d.Notify, m.allow, m.reason, m.notify)
+				}
+				if !d.Allowed && d.RetryAfter <= 0 {
+					t.Errorf("msg %d: RetryAfter = %s, want > 0", i, d.RetryAfter)
+				}
+			}
+		})
+	}
+}
+
+func TestAllowScopesAreIsolated(t *testing.T) {
+	l, _ := newTestLimiter(testSettings)
+	a := storage.Scope{AppID: "cli_a", TenantKey: "t"}
+	b := storage.Scope{AppID: "cli_b", TenantKey: "t"}
+	l.Allow(a, "oc_1", "ou_1")
+	l.Allow(a, "oc_1", "ou_1")
+	if d := l.Allow(a, "oc_1", "ou_1"); d.Allowed {
+		t.Fatal("third message in scope a allowed")
+	}
+	if d := l.Allow(b, "oc_1", "ou_1"); !d.Allowed {
+		t.Errorf("same ids in scope b denied: %+v", d)
+	}
+}
+
+func TestAllowBanned(t *testing.T) {
+	store, err := storage.NewStorage(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	l, clock := newTestLimiter(Settings{}) // 封禁在关闭限流时同样生效
+	l.store = store
+	scope := storage.Scope{AppID: "cli_a", TenantKey: "t"}
+	ban := &storage.Ban{AppID: scope.AppID, TenantKey: scope.TenantKey, UserID: "ou_bad", CreatedBy: "ou_admin",
+		Until: clock.now().Add(time.Hour), CreatedAt: clock.now()}
+	if err := l.Ban(context.Background(), ban); err != nil {
+		t.Fatalf("Ban: %v", err)
+	}
+
+	d := l.Allow(scope, "oc_1", "ou_bad")
+	if d.Allowed || d.Reason != ReasonBanned || !d.Notify || d.RetryAfter != time.Hour {
+		t.Errorf("banned user: %+v", d)
+	}
+	if d := l.Allow(storage.Scope{AppID: "cli_b", TenantKey: "t"}, "oc_1", "ou_bad"); !d.Allowed {
+		t.Errorf("ban leaked into another app: %+v", d)
+	}
+
+	clock.advance(time.Hour)
+	if d := l.Allow(scope, "oc_1", "ou_bad"); !d.Allowed {
+		t.Errorf("expired ban still denies: %+v", d)
+	}
+}
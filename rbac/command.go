@@ -0,0 +1,180 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"fin_bot/command"
+	"fin_bot/storage"
+)
+
+// Commands 返回管理角色的聊天命令（仅管理员可用）
+// 只能分配低于自己的角色，也只能修改角色低于自己的用户
+func (s *Service) Commands() []*command.Command {
+	return []*command.Command{
+		{
+			Name:        "grant",
+			Usage:       "/grant <open_id> <admin|teacher|blocked> [here]",
+			Description: "为用户分配角色（加 here 只在当前会话生效）",
+			MinRole:     command.RoleAdmin,
+			Handler:     s.commandGrant,
+		},
+		{
+			Name:        "revoke",
+			Usage:       "/revoke <open_id> [here]",
+			Description: "撤销用户的角色",
+			MinRole:     command.RoleAdmin,
+			Handler:     s.commandRevoke,
+		},
+		{
+			Name:        "roles",
+			Usage:       "/roles [open_id]",
+			Description: "查看角色分配或指定用户的角色",
+			MinRole:     command.RoleAdmin,
+			Handler:     s.commandRoles,
+		},
+	}
+}
+
+// commandGrant 处理 /grant 命令
+func (s *Service) commandGrant(ctx context.Context, req *command.Request) (string, error) {
+	if len(req.Args) < 2 || len(req.Args) > 3 {
+		return "", errors.New("参数数量不正确")
+	}
+	userID := req.Args[0]
+	role, err := command.ParseRole(req.Args[1])
+	if err != nil {
+		return "", err
+	}
+	if role == command.RoleMember {
+		return "", errors.New("member 是默认角色，请使用 /revoke 撤销分配")
+	}
+	chatID, err := chatArg(req, req.Args[2:])
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.checkTarget(ctx, req, userID, role); err != nil {
+		return "", err
+	}
+	scope := storage.Scope{AppID: req.AppID, TenantKey: req.TenantKey}
+	if err := s.Grant(ctx, scope, chatID, userID, role, req.SenderID); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("已将 %s 设为 %s（%s）", userID, role, describeScope(chatID)), nil
+}
+
+// commandRevoke 处理 /revoke 命令
+func (s *Service) commandRevoke(ctx context.Context, req *command.Request) (string, error) {
+	if len(req.Args) < 1 || len(req.Args) > 2 {
+		return "", errors.New("参数数量不正确")
+	}
+	userID := req.Args[0]
+	chatID, err := chatArg(req, req.Args[1:])
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.checkTarget(ctx, req, userID, command.RoleMember); err != nil {
+		return "", err
+	}
+	scope := storage.Scope{AppID: req.AppID, TenantKey: req.TenantKey}
+	if err := s.Revoke(ctx, scope, chatID, userID, req.SenderID); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Sprintf("%s 在%s没有分配角色", userID, describeScope(chatID)), nil
+		}
+		return "", err
+	}
+	return fmt.Sprintf("已撤销 %s 在%s的角色", userID, describeScope(chatID)), nil
+}
+
+// commandRoles 处理 /roles 命令
+func (s *Service) commandRoles(ctx context.Context, req *command.Request) (string, error) {
+	if len(req.Args) > 1 {
+		return "", errors.New("参数数量不正确")
+	}
+	if len(req.Args) == 1 {
+		target := *req
+		target.SenderID = req.Args[0]
+		return fmt.Sprintf("%s 在当前会话中的角色: %s", target.SenderID, s.RoleOf(ctx, &target)), nil
+	}
+
+	assignments := s.Assignments(storage.Scope{AppID: req.AppID, TenantKey: req.TenantKey}, req.ChatID)
+	sort.Slice(assignments, func(i, j int) bool {
+		if assignments[i].ChatID != assignments[j].ChatID {
+			return assignments[i].ChatID < assignments[j].ChatID
+		}
+		return assignments[i].UserID < assignments[j].UserID
+	})
+
+	var b strings.Builder
+	s.mu.RLock()
+	configured := make([]string, 0, len(s.bootstrap))
+	for id, role := range s.bootstrap {
+		configured = append(configured, fmt.Sprintf("%s %s", id, role))
+	}
+	s.mu.RUnlock()
+	sort.Strings(configured)
+	b.WriteString("配置文件指定:")
+	if len(configured) == 0 {
+		b.WriteString(" 无")
+	}
+	for _, line := range configured {
+		b.WriteString("\n" + line)
+	}
+
+	b.WriteString("\n已分配的角色:")
+	if len(assignments) == 0 {
+		b.WriteString(" 无")
+	}
+	for _, a := range assignments {
+		fmt.Fprintf(&b, "\n%s %s（%s，操作人 %s）", a.UserID, a.Role, describeScope(a.ChatID), a.GrantedBy)
+	}
+	return b.String(), nil
+}
+
+// checkTarget 检查调用者是否可以把 userID 的角色改为 role
+func (s *Service) checkTarget(ctx context.Context, req *command.Request, userID string, role command.Role) error {
+	if userID == req.SenderID {
+		return errors.New("不能修改自己的角色")
+	}
+	if configured, ok := s.configured(req.AppID, userID); ok {
+		return fmt.Errorf("%s 的角色（%s）由配置文件指定，不能通过命令修改", userID, configured)
+	}
+
+	actorRole := s.RoleOf(ctx, req)
+	if role >= actorRole {
+		return fmt.Errorf("只能分配低于自己（%s）的角色", actorRole)
+	}
+	target := *req
+	target.SenderID = userID
+	if current := s.RoleOf(ctx, &target); current >= actorRole {
+		return fmt.Errorf("%s 的角色（%s）不低于你，不能修改", userID, current)
+	}
+	return nil
+}
+
+// chatArg 解析可选的 here 参数：指定时返回当前会话ID，否则为空（整个租户）
+func chatArg(req *command.Request, args []string) (string, error) {
+	if len(args) == 0 {
+		return "", nil
+	}
+	if strings.ToLower(args[0]) != "here" {
+		return "", fmt.Errorf("未知的参数: %s", args[0])
+	}
+	if req.ChatID == "" {
+		return "", errors.New("无法确定当前会话")
+	}
+	return req.ChatID, nil
+}
+
+// describeScope 角色分配的生效范围说明
+func describeScope(chatID string) string {
+	if chatID == "" {
+		return "所有会话"
+	}
+	return "会话 " + chatID
+}
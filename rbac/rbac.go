@@ -0,0 +1,311 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"fin_bot/command"
+	"fin_bot/service"
+	"fin_bot/storage"
+)
+
+// 群主信息的缓存时间，查询失败时缩短，避免频繁调用开放平台
+const (
+	chatOwnerTTL      = 10 * time.Minute
+	chatOwnerErrorTTL = time.Minute
+)
+
+// 审计日志中的操作类型
+const (
+	ActionGrant  = "role.grant"
+	ActionRevoke = "role.revoke"
+)
+
+// Service 角色管理：配置文件中的所有者和管理员、数据库中按租户或会话分配的角色，以及群主
+//
+// 角色的确定规则：
+//   - 配置文件中的 owners/users 分别为 owner/admin，不能通过命令修改；open_id 只在签发它的应用中有效，
+//     因此条目写作 "应用名称或App ID:open_id"，不带前缀的条目只在默认应用中生效
+//   - 租户级或会话级任意一个分配为 blocked 时为 blocked
+//   - 否则取租户级和会话级分配中较高的角色，没有分配时为 member
+//   - 开启 chat_owner_admin 时，群主在自己的群中至少为 admin
+type Service struct {
+	store *storage.Storage
+	apps  *service.AppRegistry
+	now   func() time.Time
+
+	mu             sync.RWMutex
+	bootstrap      map[bootstrapKey]command.Role
+	chatOwnerAdmin bool
+	assignments    map[assignmentKey]*storage.RoleAssignment
+	chatOwners     map[string]chatOwner
+}
+
+// assignmentKey 角色分配的唯一键，chatID 为空表示租户级分配
+type assignmentKey struct {
+	scope  storage.Scope
+	chatID string
+	userID string
+}
+
+// bootstrapKey 配置文件中的一个条目，app 为应用名称或 App ID，为空表示默认应用
+type bootstrapKey struct {
+	app    string
+	userID string
+}
+
+func (k bootstrapKey) String() string {
+	if k.app == "" {
+		return k.userID
+	}
+	return k.app + ":" + k.userID
+}
+
+// parseBootstrapKey 解析 "应用名称或App ID:open_id" 格式的条目
+func parseBootstrapKey(entry string) bootstrapKey {
+	if app, userID, ok := strings.Cut(entry, ":"); ok {
+		return bootstrapKey{strings.TrimSpace(app), strings.TrimSpace(userID)}
+	}
+	return bootstrapKey{userID: entry}
+}
+
+// chatOwner 缓存的群主信息
+type chatOwner struct {
+	ownerID string
+	expires time.Time
+}
+
+// New 创建角色管理，需要调用 Load 加载数据库中的角色分配
+// apps 用于查询群主，为 nil 时不识别群主
+func New(store *storage.Storage, apps *service.AppRegistry) *Service {
+	return &Service{
+		store:       store,
+		apps:        apps,
+		now:         time.Now,
+		bootstrap:   make(map[bootstrapKey]command.Role),
+		assignments: make(map[assignmentKey]*storage.RoleAssignment),
+		chatOwners:  make(map[string]chatOwner),
+	}
+}
+
+// SetBootstrap 替换配置文件中的所有者和管理员（同时出现在两个列表中时为 owner）
+func (s *Service) SetBootstrap(owners, admins []string, chatOwnerAdmin bool) {
+	bootstrap := make(map[bootstrapKey]command.Role, len(owners)+len(admins))
+	for role, entries := range map[command.Role][]string{command.RoleAdmin: admins, command.RoleOwner: owners} {
+		for _, entry := range entries {
+			key := parseBootstrapKey(strings.TrimSpace(entry))
+			if key.userID != "" {
+				bootstrap[key] = max(bootstrap[key], role)
+			}
+		}
+	}
+
+	s.mu.Lock()
+	s.bootstrap = bootstrap
+	s.chatOwnerAdmin = chatOwnerAdmin
+	s.mu.Unlock()
+}
+
+// Load 从数据库加载角色分配
+func (s *Service) Load(ctx context.Context) error {
+	assignments, err := s.store.ListRoleAssignments(ctx)
+	if err != nil {
+		return err
+	}
+
+	loaded := make(map[assignmentKey]*storage.RoleAssignment, len(assignments))
+	for _, a := range assignments {
+		if _, err := command.ParseRole(a.Role); err != nil {
+			log.Printf("[rbac] 忽略无效的角色分配: user=%s, role=%s", a.UserID, a.Role)
+			continue
+		}
+		loaded[assignmentKey{a.Scope(), a.ChatID, a.UserID}] = a
+	}
+
+	s.mu.Lock()
+	s.assignments = loaded
+	s.mu.Unlock()
+	return nil
+}
+
+// RoleOf 确定命令调用者在当前会话中的角色，实现 command.Authorizer
+func (s *Service) RoleOf(ctx context.Context, req *command.Request) command.Role {
+	scope := storage.Scope{AppID: req.AppID, TenantKey: req.TenantKey}
+
+	if role, ok := s.configured(req.AppID, req.SenderID); ok {
+		return role
+	}
+
+	s.mu.RLock()
+	role, blocked := command.RoleMember, false
+	for _, chatID := range []string{"", req.ChatID} {
+		if a, ok := s.assignments[assignmentKey{scope, chatID, req.SenderID}]; ok {
+			assigned, _ := command.ParseRole(a.Role)
+			blocked = blocked || assigned == command.RoleBlocked
+			role = max(role, assigned)
+		}
+		if req.ChatID == "" {
+			break
+		}
+	}
+	chatOwnerAdmin := s.chatOwnerAdmin
+	s.mu.RUnlock()
+
+	if blocked {
+		return command.RoleBlocked
+	}
+	if role < command.RoleAdmin && chatOwnerAdmin && req.ChatType == "group" && req.SenderID != "" &&
+		s.chatOwner(ctx, req.AppID, req.ChatID) == req.SenderID {
+		return command.RoleAdmin
+	}
+	return role
+}
+
+// chatOwner 获取群主的 open_id（带缓存），获取失败时返回空字符串
+func (s *Service) chatOwner(ctx context.Context, appID, chatID string) string {
+	if s.apps == nil || chatID == "" {
+		return ""
+	}
+	key := appID + "/" + chatID
+	now := s.now()
+
+	s.mu.RLock()
+	cached, ok := s.chatOwners[key]
+	s.mu.RUnlock()
+	if ok && now.Before(cached.expires) {
+		return cached.ownerID
+	}
+
+	var ownerID string
+	ttl := chatOwnerTTL
+	larkService, err := s.apps.LarkService(appID)
+	if err == nil {
+		ownerID, err = larkService.GetChatOwner(ctx, chatID)
+	}
+	if err != nil {
+		log.Printf("[rbac] 获取群主失败: app_id=%s, chat_id=%s, error=%v", appID, chatID, err)
+		ttl = chatOwnerErrorTTL
+	}
+
+	s.mu.Lock()
+	s.chatOwners[key] = chatOwner{ownerID: ownerID, expires: now.Add(ttl)}
+	s.mu.Unlock()
+	return ownerID
+}
+
+// Grant 为用户分配角色，chatID 为空时作用于整个租户
+func (s *Service) Grant(ctx context.Context, scope storage.Scope, chatID, userID string, role command.Role, actor string) error {
+	key := assignmentKey{scope, chatID, userID}
+	previous := command.RoleMember
+	s.mu.RLock()
+	if a, ok := s.assignments[key]; ok {
+		previous, _ = command.ParseRole(a.Role)
+	}
+	s.mu.RUnlock()
+
+	a := &storage.RoleAssignment{
+		AppID:     scope.AppID,
+		TenantKey: scope.TenantKey,
+		ChatID:    chatID,
+		UserID:    userID,
+		Role:      role.String(),
+		GrantedBy: actor,
+	}
+	audit := &storage.AuditEntry{
+		AppID:     scope.AppID,
+		TenantKey: scope.TenantKey,
+		Actor:     actor,
+		Action:    ActionGrant,
+		Target:    userID,
+		Detail:    fmt.Sprintf("role=%s previous=%s chat=%s", role, previous, chatScope(chatID)),
+	}
+	if err := s.store.SaveRoleAssignment(ctx, a, audit); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.assignments[key] = a
+	s.mu.Unlock()
+	log.Printf("[rbac] 分配角色: user=%s, role=%s, chat=%s, actor=%s", userID, role, chatScope(chatID), actor)
+	return nil
+}
+
+// Revoke 删除用户的角色分配，没有分配时返回 storage.ErrNotFound
+func (s *Service) Revoke(ctx context.Context, scope storage.Scope, chatID, userID, actor string) error {
+	key := assignmentKey{scope, chatID, userID}
+	s.mu.RLock()
+	a, ok := s.assignments[key]
+	s.mu.RUnlock()
+	if !ok {
+		return storage.ErrNotFound
+	}
+
+	audit := &storage.AuditEntry{
+		AppID:     scope.AppID,
+		TenantKey: scope.TenantKey,
+		Actor:     actor,
+		Action:    ActionRevoke,
+		Target:    userID,
+		Detail:    fmt.Sprintf("role=%s chat=%s", a.Role, chatScope(chatID)),
+	}
+	if err := s.store.DeleteRoleAssignment(ctx, scope, chatID, userID, audit); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	delete(s.assignments, key)
+	s.mu.Unlock()
+	log.Printf("[rbac] 撤销角色: user=%s, role=%s, chat=%s, actor=%s", userID, a.Role, chatScope(chatID), actor)
+	return nil
+}
+
+// Assignments 获取指定范围内的角色分配：租户级分配和 chatID 会话中的分配
+func (s *Service) Assignments(scope storage.Scope, chatID string) []*storage.RoleAssignment {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []*storage.RoleAssignment
+	for key, a := range s.assignments {
+		if key.scope == scope && (key.chatID == "" || key.chatID == chatID) {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// configured 用户在 appID 应用中的角色是否由配置文件指定
+// 条目可以用 App ID 或应用名称指定应用，不带前缀的条目只匹配默认应用；apps 为 nil 时只有一个应用
+func (s *Service) configured(appID, userID string) (command.Role, bool) {
+	keys := []bootstrapKey{{appID, userID}}
+	if s.apps == nil {
+		keys = append(keys, bootstrapKey{userID: userID})
+	} else {
+		if app, err := s.apps.Get(appID); err == nil {
+			keys = append(keys, bootstrapKey{app.Name, userID})
+		}
+		if app, err := s.apps.Default(); err == nil && app.AppID == appID {
+			keys = append(keys, bootstrapKey{userID: userID})
+		}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	role, found := command.RoleMember, false
+	for _, key := range keys {
+		if r, ok := s.bootstrap[key]; ok {
+			role, found = max(role, r), true
+		}
+	}
+	return role, found
+}
+
+// chatScope 审计日志和回复中的会话范围
+func chatScope(chatID string) string {
+	if chatID == "" {
+		return "*"
+	}
+	return chatID
+}
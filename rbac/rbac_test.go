@@ -0,0 +1,50 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"fin_bot/command"
+	"fin_bot/service"
+)
+
+func TestRoleOfBootstrap(t *testing.T) {
+	apps := service.NewAppRegistry()
+	for _, app := range []*service.App{
+		{Name: "default", AppID: "cli_a"},
+		{Name: "edu", AppID: "cli_b"},
+	} {
+		if err := apps.Add(app); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := New(nil, apps)
+	s.SetBootstrap(
+		[]string{"ou_owner", "edu:ou_edu_owner"},
+		[]string{"cli_b:ou_edu_admin", "ou_owner", " default : ou_admin "},
+		false,
+	)
+
+	tests := []struct {
+		name   string
+		appID  string
+		sender string
+		want   command.Role
+	}{
+		{"不带前缀的条目在默认应用中生效", "cli_a", "ou_owner", command.RoleOwner},
+		{"不带前缀的条目在其他应用中不生效", "cli_b", "ou_owner", command.RoleMember},
+		{"按应用名称匹配", "cli_b", "ou_edu_owner", command.RoleOwner},
+		{"按 App ID 匹配", "cli_b", "ou_edu_admin", command.RoleAdmin},
+		{"应用前缀两侧的空白被忽略", "cli_a", "ou_admin", command.RoleAdmin},
+		{"其他应用的条目不生效", "cli_a", "ou_edu_owner", command.RoleMember},
+		{"未知应用", "cli_x", "ou_owner", command.RoleMember},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &command.Request{AppID: tt.appID, TenantKey: "t1", SenderID: tt.sender}
+			if got := s.RoleOf(context.Background(), req); got != tt.want {
+				t.Errorf("RoleOf(%s, %s) = %s, want %s", tt.appID, tt.sender, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"fin_bot/alert"
+	"fin_bot/command"
+	"fin_bot/course"
+	"fin_bot/eventlog"
+	"fin_bot/flashcard"
+	"fin_bot/glossary"
+	"fin_bot/handler"
+	"fin_bot/larktest"
+	"fin_bot/marketdata"
+	"fin_bot/progress"
+	"fin_bot/quiz"
+	"fin_bot/quote"
+	"fin_bot/ratelimit"
+	"fin_bot/rbac"
+	"fin_bot/scheduler"
+	"fin_bot/service"
+	"fin_bot/storage"
+	"fin_bot/watchlist"
+	"fin_bot/worker"
+
+	"github.com/larksuite/oapi-sdk-go/v3/event/dispatcher"
+	"github.com/larksuite/oapi-sdk-go/v3/event/dispatcher/callback"
+	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
+)
+
+// replayResult 一条事件的回放结果（-format json 时按行输出）
+type replayResult struct {
+	Index      int            `json:"index"`
+	RecordedAt time.Time      `json:"recorded_at"`
+	App        string         `json:"app"`
+	ChatID     string         `json:"chat_id"`
+	SenderID   string         `json:"sender_id"`
+	Input      string         `json:"input"`
+	Replies    []replayOutput `json:"replies"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// replayOutput 回放时机器人将会发出的一条消息
+type replayOutput struct {
+	ReceiveID string `json:"receive_id,omitempty"`
+	ReplyTo   string `json:"reply_to,omitempty"`
+	MsgType   string `json:"msg_type"`
+	Text      string `json:"text"` // 非文本消息为原始 content JSON
+}
+
+// runReplayCommand 回放录制的事件：事件经过与线上相同的分发和处理流程，
+// 开放平台调用发往进程内的模拟服务，只输出将会发送的回复，不会真正发送消息
+func runReplayCommand(configPath string, args []string) int {
+	fs := newCLIFlags("replay", "-file events.jsonl [-db 数据库] [-app 应用] [-format text|json] [-verbose]")
+	file := fs.String("file", "", "录制文件路径（必填）")
+	dbPath := fs.String("db", "", "回放使用的数据库（默认使用临时数据库，避免污染线上数据）")
+	appFilter := fs.String("app", "", "只回放指定应用的事件（应用名称或 App ID）")
+	format := fs.String("format", "text", "输出格式: text 或 json（每行一个结果，便于回归对比）")
+	fs.logFlag()
+	if code := fs.parse(args); code != exitOK {
+		return code
+	}
+	if *file == "" {
+		return fs.usageError("缺少 -file 参数")
+	}
+	if *format != "text" && *format != "json" {
+		return fs.usageError("无效的输出格式: %s", *format)
+	}
+
+	// 回放不连接飞书，应用凭证缺失等校验错误不影响回放
+	cfg := loadConfig(configPath, false)
+	if cfg == nil {
+		return exitError
+	}
+
+	if *dbPath == "" {
+		dir, err := os.MkdirTemp("", "fin_bot_replay")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "创建临时目录失败: %v\n", err)
+			return exitError
+		}
+		defer os.RemoveAll(dir)
+		*dbPath = filepath.Join(dir, "replay.db")
+	}
+	dbStorage, err := storage.NewStorage(*dbPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "初始化数据库失败: %v\n", err)
+		return exitError
+	}
+	defer dbStorage.Close()
+	if _, err := configureStorage(context.Background(), cfg, dbStorage); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	fake := larktest.NewServer()
+	defer fake.Close()
+
+	apps := service.NewAppRegistry()
+	roles := rbac.New(dbStorage, apps)
+	roles.SetBootstrap(cfg.Admin.Owners, cfg.Admin.Users, cfg.Admin.ChatOwnerAdmin)
+	if err := roles.Load(context.Background()); err != nil {
+		fmt.Fprintf(os.Stderr, "加载角色分配失败: %v\n", err)
+		return exitError
+	}
+	router := command.NewRouter(roles)
+	for _, cmd := range roles.Commands() {
+		router.Register(cmd)
+	}
+	router.Register(scheduler.New(dbStorage, apps, cfg.Scheduler.Interval, cfg.Scheduler.DefaultTimezone).Command())
+	tracker := progress.New(dbStorage, progressSettings(cfg.Progress))
+	for _, cmd := range tracker.Commands() {
+		router.Register(cmd)
+	}
+	courses := course.New(dbStorage)
+	courses.SetProgressTracker(tracker)
+	for _, cmd := range courses.Commands() {
+		router.Register(cmd)
+	}
+	quizzes := quiz.New(dbStorage)
+	quizzes.SetProgressTracker(tracker)
+	for _, cmd := range quizzes.Commands() {
+		router.Register(cmd)
+	}
+	// 答题赛的后续题目由计时器发出，回放只包含发起时的计分板和第一道题
+	arena := quiz.NewArena(quizzes, apps, battleSettings(cfg.Quizzes))
+	defer arena.Shutdown(context.Background())
+	for _, cmd := range arena.Commands() {
+		router.Register(cmd)
+	}
+	router.RegisterText(arena.HandleText)
+	router.RegisterAction(quiz.ActionBattle, arena.HandleAction)
+	router.RegisterText(quizzes.HandleText)
+	router.RegisterAction(quiz.ActionAnswer, quizzes.HandleAction)
+	// 回放时不启动每日提醒
+	flashcards := flashcard.New(dbStorage, apps, flashcardSettings(cfg.Flashcards))
+	flashcards.SetProgressTracker(tracker)
+	for _, cmd := range flashcards.Commands() {
+		router.Register(cmd)
+	}
+	router.RegisterAction(flashcard.ActionShow, flashcards.HandleAction)
+	router.RegisterAction(flashcard.ActionGrade, flashcards.HandleAction)
+	// 回放时不发送每日术语
+	terms := glossary.New(dbStorage, apps, glossarySettings(cfg.Glossary))
+	for _, cmd := range terms.Commands() {
+		router.Register(cmd)
+	}
+	router.RegisterText(terms.HandleText)
+	router.RegisterAction(glossary.ActionDefine, terms.HandleAction)
+	// 回放不自动导入本地行情，使用 -db 指定的数据库中已导入的行情或配置的 HTTP 行情接口
+	provider, err := newMarketProvider(dbStorage, cfg.MarketData)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "创建行情来源失败: %v\n", err)
+		return exitError
+	}
+	market := marketdata.NewCache(provider, marketCacheSettings(cfg.MarketData))
+	quotes := quote.New(market, quoteSettings(cfg.Quote))
+	for _, cmd := range quotes.Commands() {
+		router.Register(cmd)
+	}
+	router.RegisterAction(quote.ActionQuote, quotes.HandleAction)
+	// 回放时不发送定时汇总
+	for _, cmd := range watchlist.New(dbStorage, apps, market, quotes, watchlistSettings(cfg.Watchlist)).Commands() {
+		router.Register(cmd)
+	}
+	// 回放时不检查提醒
+	for _, cmd := range alert.New(dbStorage, apps, market, quotes, alertSettings(cfg.Alerts)).Commands() {
+		router.Register(cmd)
+	}
+	// 回放时事件连续到达，不做限流；封禁命令照常执行
+	for _, cmd := range ratelimit.New(dbStorage, rateLimitSettings(cfg.RateLimit)).Commands() {
+		router.Register(cmd)
+	}
+	eventHandler := handler.NewEventHandler(dbStorage, router, nil, nil)
+	dispatchers := make(map[string]*dispatcher.EventDispatcher)
+
+	ctx := context.Background()
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	index, failed := 0, 0
+	err = eventlog.ReadFile(*file, func(entry *eventlog.Entry) error {
+		if *appFilter != "" && *appFilter != entry.App && *appFilter != entry.AppID {
+			return nil
+		}
+
+		// 按录制时的应用创建连接到模拟服务的应用，回复使用同一个应用发出
+		d, ok := dispatchers[entry.AppID]
+		if !ok {
+			app := fake.NewApp(entry.App, entry.AppID, "")
+			if err := apps.Add(app); err != nil {
+				return err
+			}
+			d = eventHandler.Dispatcher(app, worker.Inline{})
+			dispatchers[entry.AppID] = d
+		}
+
+		index++
+		result := summarizeEvent(index, entry)
+		before := len(fake.Messages())
+		if _, err := d.Do(ctx, entry.Payload); err != nil {
+			result.Error = err.Error()
+			failed++
+		}
+		for _, msg := range fake.Messages()[before:] {
+			text := msg.Text
+			if msg.MsgType != "text" {
+				text = msg.Content
+			}
+			result.Replies = append(result.Replies, replayOutput{ReceiveID: msg.ReceiveID, ReplyTo: msg.ReplyTo, MsgType: msg.MsgType, Text: text})
+		}
+
+		if *format == "json" {
+			return enc.Encode(result)
+		}
+		printReplayResult(result)
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "回放失败: %v\n", err)
+		return exitError
+	}
+
+	fmt.Fprintf(os.Stderr, "回放完成: 共 %d 个事件，失败 %d 个\n", index, failed)
+	if failed > 0 {
+		return exitError
+	}
+	return exitOK
+}
+
+// summarizeEvent 提取事件中用于展示的会话、发送者和消息文本
+func summarizeEvent(index int, entry *eventlog.Entry) *replayResult {
+	result := &replayResult{Index: index, RecordedAt: entry.RecordedAt, App: entry.App}
+
+	if entry.EventType == "card.action.trigger" {
+		var event callback.CardActionTriggerEvent
+		if err := json.Unmarshal(entry.Payload, &event); err != nil || event.Event == nil {
+			return result
+		}
+		if event.Event.Context != nil {
+			result.ChatID = event.Event.Context.OpenChatID
+		}
+		if event.Event.Operator != nil {
+			result.SenderID = event.Event.Operator.OpenID
+		}
+		if event.Event.Action != nil {
+			value, _ := json.Marshal(event.Event.Action.Value)
+			result.Input = "[卡片交互] " + string(value)
+		}
+		return result
+	}
+
+	var event larkim.P2MessageReceiveV1
+	if err := json.Unmarshal(entry.Payload, &event); err != nil || event.Event == nil || event.Event.Message == nil {
+		return result
+	}
+	msg := event.Event.Message
+	if msg.ChatId != nil {
+		result.ChatID = *msg.ChatId
+	}
+	if s := event.Event.Sender; s != nil && s.SenderId != nil && s.SenderId.OpenId != nil {
+		result.SenderID = *s.SenderId.OpenId
+	}
+	if msg.Content != nil {
+		var content struct {
+			Text string `json:"text"`
+		}
+		if json.Unmarshal([]byte(*msg.Content), &content) == nil && content.Text != "" {
+			result.Input = content.Text
+		} else {
+			result.Input = *msg.Content
+		}
+	}
+	return result
+}
+
+// printReplayResult 以文本格式输出回放结果
+func printReplayResult(r *replayResult) {
+	fmt.Printf("#%d %s app=%s chat=%s sender=%s\n", r.Index, r.RecordedAt.Format(time.RFC3339), r.App, r.ChatID, r.SenderID)
+	fmt.Printf("  > %s\n", r.Input)
+	for _, reply := range r.Replies {
+		if reply.MsgType != "text" {
+			fmt.Printf("  < [%s] %s\n", reply.MsgType, reply.Text)
+			continue
+		}
+		fmt.Printf("  < %s\n", reply.Text)
+	}
+	if r.Error != "" {
+		fmt.Printf("  ! %s\n", r.Error)
+	}
+}
@@ -0,0 +1,189 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"fin_bot/command"
+	"fin_bot/storage"
+)
+
+const scheduleUsage = `/schedule list
+/schedule add <名称> | <cron> | <目标> | <内容> [| <时区> [| skip/once/all]]
+/schedule pause|resume|del|run|runs <id>
+目标: here（当前会话）、chat:<chat_id,...>、user:<open_id,...>、segment:all_groups|active_chats`
+
+// Command 返回管理定时任务的聊天命令（仅管理员可用）
+func (s *Scheduler) Command() *command.Command {
+	return &command.Command{
+		Name:        "schedule",
+		Usage:       scheduleUsage,
+		Description: "管理定时消息",
+		MinRole:     command.RoleAdmin,
+		Handler:     s.handleCommand,
+	}
+}
+
+// handleCommand 处理 /schedule 命令
+func (s *Scheduler) handleCommand(ctx context.Context, req *command.Request) (string, error) {
+	if len(req.Args) == 0 {
+		return scheduleUsage, nil
+	}
+
+	scope := storage.Scope{AppID: req.AppID, TenantKey: req.TenantKey}
+	sub := strings.ToLower(req.Args[0])
+	switch sub {
+	case "list", "ls":
+		return s.commandList(ctx, scope)
+	case "add":
+		return s.commandAdd(ctx, req)
+	case "pause", "resume", "del", "delete", "run", "runs":
+		if len(req.Args) < 2 {
+			return "", fmt.Errorf("缺少任务ID")
+		}
+		id, err := strconv.ParseInt(req.Args[1], 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("无效的任务ID: %s", req.Args[1])
+		}
+		return s.commandByID(ctx, scope, sub, id)
+	default:
+		return "", fmt.Errorf("未知的子命令: %s", sub)
+	}
+}
+
+// commandList 列出当前应用和租户的所有定时任务
+func (s *Scheduler) commandList(ctx context.Context, scope storage.Scope) (string, error) {
+	schedules, err := s.List(ctx, scope)
+	if err != nil {
+		return "", err
+	}
+	if len(schedules) == 0 {
+		return "暂无定时任务", nil
+	}
+
+	var b strings.Builder
+	b.WriteString("定时任务:")
+	for _, sch := range schedules {
+		status := "启用"
+		if !sch.Enabled {
+			status = "暂停"
+		}
+		next := "-"
+		if sch.NextRunAt != nil {
+			if loc, err := time.LoadLocation(sch.Timezone); err == nil {
+				next = sch.NextRunAt.In(loc).Format("2006-01-02 15:04")
+			}
+		}
+		fmt.Fprintf(&b, "\n#%d %s [%s] cron=%q tz=%s 目标=%s:%s 下次=%s",
+			sch.ID, sch.Name, status, sch.CronExpr, sch.Timezone,
+			sch.TargetType, strings.Join(sch.Targets, ","), next)
+	}
+	return b.String(), nil
+}
+
+// commandAdd 通过聊天命令创建定时任务
+func (s *Scheduler) commandAdd(ctx context.Context, req *command.Request) (string, error) {
+	rest := strings.TrimSpace(strings.TrimPrefix(req.RawArgs, req.Args[0]))
+	parts := strings.Split(rest, "|")
+	if len(parts) < 4 {
+		return "", fmt.Errorf("参数不足")
+	}
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+
+	targetType, targets, err := parseTarget(parts[2], req.ChatID)
+	if err != nil {
+		return "", err
+	}
+
+	sch := &storage.Schedule{
+		AppID:      req.AppID,
+		TenantKey:  req.TenantKey,
+		Name:       parts[0],
+		CronExpr:   parts[1],
+		TargetType: targetType,
+		Targets:    targets,
+		Content:    parts[3],
+		Enabled:    true,
+		CreatedBy:  req.SenderID,
+	}
+	if len(parts) > 4 {
+		sch.Timezone = parts[4]
+	}
+	if len(parts) > 5 {
+		sch.CatchUpPolicy = strings.ToLower(parts[5])
+	}
+
+	if err := s.Create(ctx, sch); err != nil {
+		return "", err
+	}
+
+	loc, _ := time.LoadLocation(sch.Timezone)
+	return fmt.Sprintf("已创建定时任务 #%d %s，下次执行: %s (%s)",
+		sch.ID, sch.Name, sch.NextRunAt.In(loc).Format("2006-01-02 15:04"), sch.Timezone), nil
+}
+
+// commandByID 处理针对单个任务的子命令
+func (s *Scheduler) commandByID(ctx context.Context, scope storage.Scope, sub string, id int64) (string, error) {
+	switch sub {
+	case "pause":
+		if err := s.SetEnabled(ctx, scope, id, false); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("已暂停定时任务 #%d", id), nil
+	case "resume":
+		if err := s.SetEnabled(ctx, scope, id, true); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("已恢复定时任务 #%d", id), nil
+	case "del", "delete":
+		if err := s.Delete(ctx, scope, id); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("已删除定时任务 #%d", id), nil
+	case "run":
+		run, err := s.RunNow(ctx, scope, id)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("已执行定时任务 #%d: 成功 %d，失败 %d", id, run.Success, run.Failed), nil
+	default: // runs
+		runs, err := s.Runs(ctx, scope, id, 10)
+		if err != nil {
+			return "", err
+		}
+		if len(runs) == 0 {
+			return fmt.Sprintf("定时任务 #%d 暂无执行记录", id), nil
+		}
+		var b strings.Builder
+		fmt.Fprintf(&b, "定时任务 #%d 最近执行记录:", id)
+		for _, run := range runs {
+			fmt.Fprintf(&b, "\n%s 成功 %d 失败 %d", run.StartedAt.Format(time.RFC3339), run.Success, run.Failed)
+		}
+		return b.String(), nil
+	}
+}
+
+// parseTarget 解析目标描述，例如 here、chat:oc_1,oc_2、segment:all_groups
+func parseTarget(spec, currentChatID string) (string, []string, error) {
+	if strings.EqualFold(spec, "here") {
+		return storage.TargetTypeChat, []string{currentChatID}, nil
+	}
+
+	targetType, list, ok := strings.Cut(spec, ":")
+	if !ok {
+		return "", nil, fmt.Errorf("无效的目标: %s", spec)
+	}
+
+	var targets []string
+	for _, id := range strings.Split(list, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			targets = append(targets, id)
+		}
+	}
+	return strings.ToLower(strings.TrimSpace(targetType)), targets, nil
+}
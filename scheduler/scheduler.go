@@ -0,0 +1,583 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"fin_bot/audit"
+	"fin_bot/service"
+	"fin_bot/storage"
+
+	"github.com/robfig/cron/v3"
+)
+
+// 预定义的会话分组
+const (
+	SegmentAllGroups   = "all_groups"   // 机器人已加入的所有群聊
+	SegmentActiveChats = "active_chats" // 最近有过消息往来的会话
+)
+
+const (
+	// activeChatWindow active_chats 分组统计的时间窗口
+	activeChatWindow = 30 * 24 * time.Hour
+	// maxCatchUpRuns catch_up_policy=all 时单次最多补发的次数
+	maxCatchUpRuns = 24
+	// pruneInterval 按保留策略清理消息的间隔
+	pruneInterval = time.Hour
+)
+
+// ErrInvalidSchedule 定时任务参数不合法
+var ErrInvalidSchedule = errors.New("定时任务参数不合法")
+
+// cronParser 标准 5 段 cron 表达式，同时支持 @daily 等描述符
+var cronParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// Scheduler 定时消息调度器
+// 任务持久化在 SQLite 中，调度器按 interval 轮询所有应用的到期任务，并通过任务所属应用的 LarkService 投递；
+// 对外的查询和管理接口都需要指定数据范围，不同应用、租户之间互不可见
+type Scheduler struct {
+	storage *storage.Storage
+	apps    *service.AppRegistry
+	audit   *audit.Logger // 记录任务删除，为 nil 时不记录
+
+	settingsMu      sync.RWMutex // 保护 interval、defaultTimezone 和保留策略，配置热加载时会被修改
+	interval        time.Duration
+	defaultTimezone string
+	keepPerChat     int
+	keepMaxAge      time.Duration
+	intervalChanged chan struct{}
+
+	runMu     sync.Mutex // 串行化到期检查和手动执行，避免同一任务并发投递
+	lastPrune time.Time  // 上一次清理消息的时间，由 runMu 保护
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New 创建新的调度器
+// interval: 检查到期任务的间隔
+// defaultTimezone: 未指定时区的任务使用的时区
+func New(store *storage.Storage, apps *service.AppRegistry, interval time.Duration, defaultTimezone string) *Scheduler {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	return &Scheduler{
+		storage:         store,
+		apps:            apps,
+		interval:        interval,
+		defaultTimezone: defaultTimezone,
+		intervalChanged: make(chan struct{}, 1),
+	}
+}
+
+// SetAuditLogger 设置审计日志，需要在 Start 之前调用
+func (s *Scheduler) SetAuditLogger(l *audit.Logger) {
+	s.audit = l
+}
+
+// SetInterval 修改到期任务检查间隔，运行中的调度循环会立即按新间隔重置
+func (s *Scheduler) SetInterval(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	s.settingsMu.Lock()
+	changed := s.interval != interval
+	s.interval = interval
+	s.settingsMu.Unlock()
+
+	if changed {
+		select {
+		case s.intervalChanged <- struct{}{}:
+		default:
+		}
+		log.Printf("[scheduler] 检查间隔已更新: interval=%s", interval)
+	}
+}
+
+// SetDefaultTimezone 修改新建任务的默认时区，已存在的任务不受影响
+func (s *Scheduler) SetDefaultTimezone(tz string) {
+	s.settingsMu.Lock()
+	s.defaultTimezone = tz
+	s.settingsMu.Unlock()
+}
+
+// SetRetention 设置消息保留策略，perChat 为每个会话保留的条数，maxAge 为最长保留时间（0 表示不限制）
+// 调度循环每小时按该策略清理一次消息
+func (s *Scheduler) SetRetention(perChat int, maxAge time.Duration) {
+	s.settingsMu.Lock()
+	s.keepPerChat = perChat
+	s.keepMaxAge = maxAge
+	s.settingsMu.Unlock()
+}
+
+// currentInterval 获取当前的检查间隔
+func (s *Scheduler) currentInterval() time.Duration {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return s.interval
+}
+
+// Start 在后台启动调度循环
+func (s *Scheduler) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	runCtx, cancel := context.WithCancel(ctx)
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	go s.loop(runCtx)
+	log.Printf("[scheduler] 调度器已启动: interval=%s", s.currentInterval())
+	return nil
+}
+
+// Stop 停止调度循环，等待正在进行的投递完成
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.mu.Unlock()
+	if cancel == nil {
+		return nil
+	}
+
+	cancel()
+	select {
+	case <-done:
+		log.Println("[scheduler] 调度器已停止")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Create 校验并创建定时任务
+func (s *Scheduler) Create(ctx context.Context, sch *storage.Schedule) error {
+	if err := s.normalize(sch); err != nil {
+		return err
+	}
+
+	next, err := NextRun(sch.CronExpr, sch.Timezone, time.Now())
+	if err != nil {
+		return err
+	}
+	if sch.Enabled {
+		sch.NextRunAt = &next
+	}
+
+	if err := s.storage.CreateSchedule(ctx, sch); err != nil {
+		return err
+	}
+	log.Printf("[scheduler] 创建定时任务: id=%d, name=%s, cron=%s, tz=%s, next=%s",
+		sch.ID, sch.Name, sch.CronExpr, sch.Timezone, next.Format(time.RFC3339))
+	return nil
+}
+
+// List 获取指定范围内的所有定时任务
+func (s *Scheduler) List(ctx context.Context, scope storage.Scope) ([]*storage.Schedule, error) {
+	return s.storage.ListSchedules(ctx, &scope, false)
+}
+
+// Get 获取单个定时任务，不属于 scope 的任务视为不存在
+func (s *Scheduler) Get(ctx context.Context, scope storage.Scope, id int64) (*storage.Schedule, error) {
+	sch, err := s.storage.GetSchedule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if sch.Scope() != scope {
+		return nil, storage.ErrNotFound
+	}
+	return sch, nil
+}
+
+// Runs 获取定时任务最近的执行记录
+func (s *Scheduler) Runs(ctx context.Context, scope storage.Scope, id int64, limit int) ([]*storage.ScheduleRun, error) {
+	if _, err := s.Get(ctx, scope, id); err != nil {
+		return nil, err
+	}
+	return s.storage.ListScheduleRuns(ctx, id, limit)
+}
+
+// Delete 删除定时任务
+func (s *Scheduler) Delete(ctx context.Context, scope storage.Scope, id int64) error {
+	sch, err := s.Get(ctx, scope, id)
+	if err != nil {
+		return err
+	}
+	if err := s.storage.DeleteSchedule(ctx, id); err != nil {
+		return err
+	}
+	log.Printf("[scheduler] 删除定时任务: id=%d", id)
+	s.audit.Record(ctx, scope, audit.ActionDataDelete, fmt.Sprintf("schedule:%d", id), fmt.Sprintf("name=%q", sch.Name))
+	return nil
+}
+
+// SetEnabled 启用或暂停定时任务；重新启用时从当前时间开始计算下一次执行，不补发暂停期间的执行
+func (s *Scheduler) SetEnabled(ctx context.Context, scope storage.Scope, id int64, enabled bool) error {
+	sch, err := s.Get(ctx, scope, id)
+	if err != nil {
+		return err
+	}
+
+	var nextRunAt *time.Time
+	if enabled {
+		next, err := NextRun(sch.CronExpr, sch.Timezone, time.Now())
+		if err != nil {
+			return err
+		}
+		nextRunAt = &next
+	}
+
+	if err := s.storage.SetScheduleEnabled(ctx, id, enabled, nextRunAt); err != nil {
+		return err
+	}
+	log.Printf("[scheduler] 更新定时任务状态: id=%d, enabled=%v", id, enabled)
+	return nil
+}
+
+// RunNow 立即执行一次定时任务（不影响下一次计划执行时间）
+func (s *Scheduler) RunNow(ctx context.Context, scope storage.Scope, id int64) (*storage.ScheduleRun, error) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	sch, err := s.Get(ctx, scope, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.deliver(ctx, sch, time.Now()), nil
+}
+
+// loop 调度主循环
+func (s *Scheduler) loop(ctx context.Context) {
+	defer close(s.done)
+
+	// 启动时立即检查一次，处理停机期间错过的任务
+	s.tick(ctx, time.Now())
+
+	ticker := time.NewTicker(s.currentInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.intervalChanged:
+			ticker.Reset(s.currentInterval())
+		case now := <-ticker.C:
+			s.tick(ctx, now)
+		}
+	}
+}
+
+// tick 检查并执行所有到期的任务
+func (s *Scheduler) tick(ctx context.Context, now time.Time) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	schedules, err := s.storage.ListSchedules(ctx, nil, true)
+	if err != nil {
+		log.Printf("[scheduler] 加载定时任务失败: %v", err)
+		return
+	}
+
+	for _, sch := range schedules {
+		if ctx.Err() != nil {
+			return
+		}
+		if sch.NextRunAt == nil || sch.NextRunAt.After(now) {
+			continue
+		}
+		s.runDue(ctx, sch, now)
+	}
+
+	s.pruneMessages(ctx, now)
+}
+
+// pruneMessages 距离上次清理超过 pruneInterval 时按保留策略清理消息
+func (s *Scheduler) pruneMessages(ctx context.Context, now time.Time) {
+	s.settingsMu.RLock()
+	perChat, maxAge := s.keepPerChat, s.keepMaxAge
+	s.settingsMu.RUnlock()
+	if perChat <= 0 && maxAge <= 0 {
+		return
+	}
+	if !s.lastPrune.IsZero() && now.Sub(s.lastPrune) < pruneInterval {
+		return
+	}
+	s.lastPrune = now
+
+	deleted, err := s.storage.PruneMessages(ctx, perChat, maxAge, now)
+	if err != nil {
+		log.Printf("[scheduler] 清理消息失败: %v", err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("[scheduler] 按保留策略清理消息: deleted=%d, messages_per_chat=%d, message_max_age=%s", deleted, perChat, maxAge)
+	}
+}
+
+// runDue 按补发策略执行到期任务，并推进下一次执行时间
+func (s *Scheduler) runDue(ctx context.Context, sch *storage.Schedule, now time.Time) {
+	dueTimes, err := dueBetween(sch.CronExpr, sch.Timezone, *sch.NextRunAt, now, maxCatchUpRuns)
+	if err != nil {
+		log.Printf("[scheduler] 计算到期时间失败: id=%d, error=%v", sch.ID, err)
+		return
+	}
+
+	toRun := applyCatchUp(sch.CatchUpPolicy, dueTimes, now, 2*s.currentInterval())
+	if skipped := len(dueTimes) - len(toRun); skipped > 0 {
+		log.Printf("[scheduler] 按补发策略跳过错过的执行: id=%d, policy=%s, skipped=%d",
+			sch.ID, sch.CatchUpPolicy, skipped)
+	}
+
+	var lastRunAt *time.Time
+	for _, scheduledFor := range toRun {
+		s.deliver(ctx, sch, scheduledFor)
+		ranAt := time.Now()
+		lastRunAt = &ranAt
+	}
+
+	next, err := NextRun(sch.CronExpr, sch.Timezone, now)
+	if err != nil {
+		log.Printf("[scheduler] 计算下一次执行时间失败: id=%d, error=%v", sch.ID, err)
+		return
+	}
+	if err := s.storage.MarkScheduleRun(ctx, sch.ID, lastRunAt, &next); err != nil {
+		log.Printf("[scheduler] 更新执行时间失败: id=%d, error=%v", sch.ID, err)
+	}
+}
+
+// deliver 向任务的所有目标投递消息并保存执行记录
+func (s *Scheduler) deliver(ctx context.Context, sch *storage.Schedule, scheduledFor time.Time) *storage.ScheduleRun {
+	run := &storage.ScheduleRun{
+		ScheduleID:   sch.ID,
+		ScheduledFor: scheduledFor,
+		StartedAt:    time.Now(),
+	}
+
+	// 到期自动执行时记在任务名下，手动执行（RunNow）时保留触发执行的操作人
+	actor := audit.ActorFrom(ctx)
+	if actor.Via == audit.ViaSystem {
+		actor = audit.Actor{ID: fmt.Sprintf("schedule:%d", sch.ID), Via: audit.ViaSchedule}
+	}
+	actor.TenantKey = sch.TenantKey
+	ctx = audit.WithActor(ctx, actor)
+
+	var receiveIDType string
+	var receiveIDs []string
+	larkService, err := s.apps.LarkService(sch.AppID)
+	if err == nil {
+		receiveIDType, receiveIDs, err = s.resolveTargets(ctx, larkService, sch)
+	}
+	if err != nil {
+		run.Error = err.Error()
+		log.Printf("[scheduler] 解析投递目标失败: id=%d, app_id=%s, error=%v", sch.ID, sch.AppID, err)
+	}
+
+	var errs []string
+	for _, receiveID := range receiveIDs {
+		if err := larkService.SendTextMessage(ctx, receiveID, receiveIDType, sch.Content); err != nil {
+			run.Failed++
+			errs = append(errs, fmt.Sprintf("%s: %v", receiveID, err))
+			continue
+		}
+		run.Success++
+	}
+	if len(errs) > 0 {
+		run.Error = strings.Join(errs, "; ")
+	}
+
+	log.Printf("[scheduler] 定时任务执行完成: id=%d, name=%s, scheduled_for=%s, success=%d, failed=%d",
+		sch.ID, sch.Name, scheduledFor.Format(time.RFC3339), run.Success, run.Failed)
+
+	if err := s.storage.SaveScheduleRun(ctx, run); err != nil {
+		log.Printf("[scheduler] 保存执行记录失败: id=%d, error=%v", sch.ID, err)
+	}
+	return run
+}
+
+// resolveTargets 将任务目标解析为 receive_id_type 和接收者列表
+func (s *Scheduler) resolveTargets(ctx context.Context, larkService *service.LarkService, sch *storage.Schedule) (string, []string, error) {
+	switch sch.TargetType {
+	case storage.TargetTypeChat:
+		return "chat_id", sch.Targets, nil
+	case storage.TargetTypeUser:
+		return "open_id", sch.Targets, nil
+	case storage.TargetTypeSegment:
+		var chatIDs []string
+		for _, segment := range sch.Targets {
+			ids, err := s.resolveSegment(ctx, larkService, sch.Scope(), segment)
+			if err != nil {
+				return "", nil, err
+			}
+			chatIDs = append(chatIDs, ids...)
+		}
+		return "chat_id", dedupe(chatIDs), nil
+	default:
+		return "", nil, fmt.Errorf("未知的目标类型: %s", sch.TargetType)
+	}
+}
+
+// resolveSegment 解析单个会话分组，只包含任务所属应用和租户的会话
+func (s *Scheduler) resolveSegment(ctx context.Context, larkService *service.LarkService, scope storage.Scope, segment string) ([]string, error) {
+	switch segment {
+	case SegmentAllGroups:
+		return larkService.GetChatList(ctx)
+	case SegmentActiveChats:
+		return s.storage.ListActiveChatIDs(ctx, scope, time.Now().Add(-activeChatWindow))
+	default:
+		return nil, fmt.Errorf("未知的会话分组: %s", segment)
+	}
+}
+
+// normalize 填充默认值并校验定时任务参数
+func (s *Scheduler) normalize(sch *storage.Schedule) error {
+	sch.Name = strings.TrimSpace(sch.Name)
+	sch.CronExpr = strings.TrimSpace(sch.CronExpr)
+	sch.Content = strings.TrimSpace(sch.Content)
+	if sch.Timezone == "" {
+		s.settingsMu.RLock()
+		sch.Timezone = s.defaultTimezone
+		s.settingsMu.RUnlock()
+	}
+	if sch.CatchUpPolicy == "" {
+		sch.CatchUpPolicy = storage.CatchUpSkip
+	}
+
+	if _, err := s.apps.Get(sch.AppID); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+	if sch.Name == "" {
+		return fmt.Errorf("%w: 名称不能为空", ErrInvalidSchedule)
+	}
+	if sch.Content == "" {
+		return fmt.Errorf("%w: 消息内容不能为空", ErrInvalidSchedule)
+	}
+	if _, err := NextRun(sch.CronExpr, sch.Timezone, time.Now()); err != nil {
+		return err
+	}
+
+	switch sch.CatchUpPolicy {
+	case storage.CatchUpSkip, storage.CatchUpOnce, storage.CatchUpAll:
+	default:
+		return fmt.Errorf("%w: 未知的补发策略 %q（可选 skip/once/all）", ErrInvalidSchedule, sch.CatchUpPolicy)
+	}
+
+	if len(sch.Targets) == 0 {
+		return fmt.Errorf("%w: 投递目标不能为空", ErrInvalidSchedule)
+	}
+	switch sch.TargetType {
+	case storage.TargetTypeChat, storage.TargetTypeUser:
+	case storage.TargetTypeSegment:
+		for _, segment := range sch.Targets {
+			if segment != SegmentAllGroups && segment != SegmentActiveChats {
+				return fmt.Errorf("%w: 未知的会话分组 %q（可选 %s/%s）",
+					ErrInvalidSchedule, segment, SegmentAllGroups, SegmentActiveChats)
+			}
+		}
+	default:
+		return fmt.Errorf("%w: 未知的目标类型 %q（可选 chat/user/segment）", ErrInvalidSchedule, sch.TargetType)
+	}
+	return nil
+}
+
+// NextRun 计算 after 之后的下一次执行时间（按任务时区解析 cron 表达式）
+func NextRun(cronExpr, timezone string, after time.Time) (time.Time, error) {
+	sched, loc, err := parse(cronExpr, timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return sched.Next(after.In(loc)), nil
+}
+
+// parse 解析 cron 表达式和时区
+func parse(cronExpr, timezone string) (cron.Schedule, *time.Location, error) {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: 无效的时区 %q", ErrInvalidSchedule, timezone)
+	}
+	sched, err := cronParser.Parse(cronExpr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: 无效的 cron 表达式 %q: %v", ErrInvalidSchedule, cronExpr, err)
+	}
+	return sched, loc, nil
+}
+
+// dueBetween 返回从 first 开始、不晚于 now 的执行时间点，最多 limit 个（保留最近的）
+//
+// 长时间停机后从 first 逐个步进会很慢（分钟级任务停机一个月要迭代四万多次），
+// 这里从 now 往前按成倍扩大的窗口查找，窗口内已有 limit 个时间点或窗口已覆盖 first 时停止
+func dueBetween(cronExpr, timezone string, first, now time.Time, limit int) ([]time.Time, error) {
+	sched, loc, err := parse(cronExpr, timezone)
+	if err != nil {
+		return nil, err
+	}
+	if first.After(now) {
+		return nil, nil
+	}
+
+	for window := time.Minute; ; window *= 2 {
+		from := now.Add(-window).Truncate(time.Second)
+		if !from.After(first) {
+			return collectDue(sched, first.In(loc), now, limit), nil
+		}
+		// Next 返回严格晚于参数的时间点，退一秒以包含 from 本身
+		times := collectDue(sched, sched.Next(from.In(loc).Add(-time.Second)), now, limit)
+		if len(times) >= limit {
+			return times, nil
+		}
+	}
+}
+
+// collectDue 从 start 开始逐个步进到 now，保留最近的 limit 个时间点
+func collectDue(sched cron.Schedule, start, now time.Time, limit int) []time.Time {
+	var times []time.Time
+	for t := start; !t.IsZero() && !t.After(now); t = sched.Next(t) {
+		times = append(times, t)
+		if len(times) > limit {
+			times = times[1:]
+		}
+	}
+	return times
+}
+
+// applyCatchUp 按补发策略从到期时间点中选出需要执行的
+// 距离 now 不超过 grace 的视为按时执行，总是会执行；更早的视为错过，由策略决定
+func applyCatchUp(policy string, dueTimes []time.Time, now time.Time, grace time.Duration) []time.Time {
+	var onTime, missed []time.Time
+	for _, t := range dueTimes {
+		if now.Sub(t) <= grace {
+			onTime = append(onTime, t)
+		} else {
+			missed = append(missed, t)
+		}
+	}
+
+	switch policy {
+	case storage.CatchUpAll:
+		return dueTimes
+	case storage.CatchUpOnce:
+		if len(onTime) == 0 && len(missed) > 0 {
+			return missed[len(missed)-1:]
+		}
+		return onTime
+	default:
+		return onTime
+	}
+}
+
+// dedupe 去除重复的ID，保持原有顺序
+func dedupe(ids []string) []string {
+	seen := make(map[string]bool, len(ids))
+	result := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
@@ -0,0 +1,137 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"fin_bot/storage"
+)
+
+func TestDueBetween(t *testing.T) {
+	utc := func(s string) time.Time {
+		ts, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ts
+	}
+
+	tests := []struct {
+		name      string
+		cron      string
+		first     time.Time
+		now       time.Time
+		limit     int
+		wantLen   int
+		wantFirst time.Time
+		wantLast  time.Time
+	}{
+		{
+			name:      "单个到期",
+			cron:      "0 9 * * *",
+			first:     utc("2026-01-01 09:00"),
+			now:       utc("2026-01-01 09:00"),
+			limit:     24,
+			wantLen:   1,
+			wantFirst: utc("2026-01-01 09:00"),
+			wantLast:  utc("2026-01-01 09:00"),
+		},
+		{
+			name:      "停机三天的每日任务",
+			cron:      "0 9 * * *",
+			first:     utc("2026-01-01 09:00"),
+			now:       utc("2026-01-03 10:00"),
+			limit:     24,
+			wantLen:   3,
+			wantFirst: utc("2026-01-01 09:00"),
+			wantLast:  utc("2026-01-03 09:00"),
+		},
+		{
+			name:      "停机一年的分钟级任务只保留最近的",
+			cron:      "* * * * *",
+			first:     utc("2025-01-01 00:00"),
+			now:       utc("2026-01-01 12:30"),
+			limit:     24,
+			wantLen:   24,
+			wantFirst: utc("2026-01-01 12:07"),
+			wantLast:  utc("2026-01-01 12:30"),
+		},
+		{
+			name:    "尚未到期",
+			cron:    "0 9 * * *",
+			first:   utc("2026-01-02 09:00"),
+			now:     utc("2026-01-01 10:00"),
+			limit:   24,
+			wantLen: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := dueBetween(tt.cron, "UTC", tt.first, tt.now, tt.limit)
+			if err != nil {
+				t.Fatalf("dueBetween: %v", err)
+			}
+			if len(got) != tt.wantLen {
+				t.Fatalf("len = %d, want %d: %v", len(got), tt.wantLen, got)
+			}
+			if tt.wantLen == 0 {
+				return
+			}
+			if !got[0].Equal(tt.wantFirst) || !got[len(got)-1].Equal(tt.wantLast) {
+				t.Errorf("range = %s..%s, want %s..%s", got[0], got[len(got)-1], tt.wantFirst, tt.wantLast)
+			}
+			for i := 1; i < len(got); i++ {
+				if !got[i].After(got[i-1]) {
+					t.Errorf("times not ascending at %d: %v", i, got)
+				}
+			}
+		})
+	}
+}
+
+func TestDueBetweenInvalid(t *testing.T) {
+	now := time.Now()
+	if _, err := dueBetween("bad cron", "UTC", now, now, 1); err == nil {
+		t.Error("expected error for invalid cron expression")
+	}
+	if _, err := dueBetween("* * * * *", "Mars/Olympus", now, now, 1); err == nil {
+		t.Error("expected error for invalid timezone")
+	}
+}
+
+func TestApplyCatchUp(t *testing.T) {
+	now := time.Date(2026, 1, 3, 9, 0, 30, 0, time.UTC)
+	grace := 2 * time.Minute
+	missed1 := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	missed2 := time.Date(2026, 1, 2, 9, 0, 0, 0, time.UTC)
+	onTime := time.Date(2026, 1, 3, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		policy string
+		due    []time.Time
+		want   []time.Time
+	}{
+		{"skip 只执行按时的", storage.CatchUpSkip, []time.Time{missed1, missed2, onTime}, []time.Time{onTime}},
+		{"skip 全部错过", storage.CatchUpSkip, []time.Time{missed1, missed2}, nil},
+		{"once 有按时的不补发", storage.CatchUpOnce, []time.Time{missed1, missed2, onTime}, []time.Time{onTime}},
+		{"once 全部错过补发最近一次", storage.CatchUpOnce, []time.Time{missed1, missed2}, []time.Time{missed2}},
+		{"all 全部补发", storage.CatchUpAll, []time.Time{missed1, missed2, onTime}, []time.Time{missed1, missed2, onTime}},
+		{"未知策略按 skip 处理", "unknown", []time.Time{missed1, onTime}, []time.Time{onTime}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := applyCatchUp(tt.policy, tt.due, now, grace)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("got[%d] = %s, want %s", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
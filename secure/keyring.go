@@ -0,0 +1,232 @@
+package secure
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// 密文格式（base64 编码后存入 TEXT 列）：
+//
+//	版本(1) | 包装 nonce(12) | 被主密钥加密的数据密钥(32+16) | 数据 nonce(12) | 数据密文
+//
+// 每条数据使用随机生成的数据密钥加密，数据密钥再由 key_id 对应的主密钥加密（信封加密）
+const (
+	formatVersion = 1
+	keySize       = 32
+	nonceSize     = 12
+	wrappedSize   = keySize + 16
+	derivedInfo   = "fin_bot field key "
+)
+
+// ErrUnknownKey 没有指定ID的密钥
+var ErrUnknownKey = errors.New("未知的密钥ID")
+
+// Keyring 加密敏感字段使用的主密钥集合，实现 storage.FieldCipher
+// 主密钥来自密钥文件，或按 key_id 从 SECRET_KEY 派生（此时任意 key_id 都可用，更换 key_id 即可轮换）
+type Keyring struct {
+	primary string
+	keys    map[string][]byte
+	secret  []byte // 不为空时按 key_id 派生主密钥
+}
+
+// NewDerivedKeyring 创建从 secret 派生主密钥的密钥集合
+func NewDerivedKeyring(secret []byte, primary string) (*Keyring, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("派生密钥需要配置 security.secret_key")
+	}
+	if primary == "" {
+		return nil, errors.New("主密钥ID不能为空")
+	}
+	return &Keyring{primary: primary, secret: secret}, nil
+}
+
+// LoadKeyFile 从密钥文件加载主密钥
+// 文件每行一个密钥: <key_id> <base64 编码的 32 字节密钥>，空行和 # 开头的行会被忽略
+func LoadKeyFile(path, primary string) (*Keyring, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开密钥文件失败: %w", err)
+	}
+	defer f.Close()
+
+	if stat, err := f.Stat(); err == nil && stat.Mode().Perm()&0077 != 0 {
+		log.Printf("[警告] 密钥文件 %s 的权限为 %s，建议设置为 0600", path, stat.Mode().Perm())
+	}
+
+	k := &Keyring{primary: primary, keys: make(map[string][]byte)}
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("密钥文件第 %d 行格式错误，应为 <key_id> <base64 密钥>", line)
+		}
+		key, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil || len(key) != keySize {
+			return nil, fmt.Errorf("密钥文件第 %d 行的密钥必须是 base64 编码的 %d 字节", line, keySize)
+		}
+		if _, ok := k.keys[fields[0]]; ok {
+			return nil, fmt.Errorf("密钥文件中存在重复的密钥ID %q", fields[0])
+		}
+		k.keys[fields[0]] = key
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取密钥文件失败: %w", err)
+	}
+	if _, ok := k.keys[primary]; !ok {
+		return nil, fmt.Errorf("密钥文件中没有主密钥 %q", primary)
+	}
+	return k, nil
+}
+
+// GenerateKey 生成一个 base64 编码的随机主密钥（用于写入密钥文件）
+func GenerateKey() (string, error) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// PrimaryKeyID 加密新数据使用的密钥ID
+func (k *Keyring) PrimaryKeyID() string {
+	return k.primary
+}
+
+// HasKey 是否持有指定ID的密钥
+func (k *Keyring) HasKey(keyID string) bool {
+	if k.secret != nil {
+		return keyID != ""
+	}
+	_, ok := k.keys[keyID]
+	return ok
+}
+
+// KeyIDs 密钥文件中的所有密钥ID（派生模式下只返回主密钥ID）
+func (k *Keyring) KeyIDs() []string {
+	if k.secret != nil {
+		return []string{k.primary}
+	}
+	ids := make([]string, 0, len(k.keys))
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Encrypt 使用随机数据密钥加密 plaintext，数据密钥由主密钥加密后一起保存
+func (k *Keyring) Encrypt(plaintext, aad []byte) (string, string, error) {
+	kek, err := k.kek(k.primary)
+	if err != nil {
+		return "", "", err
+	}
+
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", "", err
+	}
+	data, err := newGCM(dataKey)
+	if err != nil {
+		return "", "", err
+	}
+
+	out := make([]byte, 1, 1+nonceSize+wrappedSize+nonceSize+len(plaintext)+data.Overhead())
+	out[0] = formatVersion
+	wrapNonce, err := randomNonce()
+	if err != nil {
+		return "", "", err
+	}
+	out = append(out, wrapNonce...)
+	out = kek.Seal(out, wrapNonce, dataKey, []byte(k.primary))
+
+	dataNonce, err := randomNonce()
+	if err != nil {
+		return "", "", err
+	}
+	out = append(out, dataNonce...)
+	out = data.Seal(out, dataNonce, plaintext, aad)
+	return k.primary, base64.StdEncoding.EncodeToString(out), nil
+}
+
+// Decrypt 解密 Encrypt 生成的密文
+func (k *Keyring) Decrypt(keyID, ciphertext string, aad []byte) ([]byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("密文格式错误: %w", err)
+	}
+	if len(raw) < 1+nonceSize+wrappedSize+nonceSize || raw[0] != formatVersion {
+		return nil, errors.New("密文格式错误")
+	}
+	kek, err := k.kek(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	raw = raw[1:]
+	dataKey, err := kek.Open(nil, raw[:nonceSize], raw[nonceSize:nonceSize+wrappedSize], []byte(keyID))
+	if err != nil {
+		return nil, errors.New("数据密钥解密失败，密钥错误或数据已损坏")
+	}
+	raw = raw[nonceSize+wrappedSize:]
+
+	data, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := data.Open(nil, raw[:nonceSize], raw[nonceSize:], aad)
+	if err != nil {
+		return nil, errors.New("数据解密失败，数据已损坏或被篡改")
+	}
+	return plain, nil
+}
+
+// kek 获取指定ID的主密钥
+func (k *Keyring) kek(keyID string) (cipher.AEAD, error) {
+	if !k.HasKey(keyID) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+	key := k.keys[keyID]
+	if k.secret != nil {
+		var err error
+		key, err = hkdf.Key(sha256.New, k.secret, nil, derivedInfo+keyID, keySize)
+		if err != nil {
+			return nil, fmt.Errorf("派生密钥失败: %w", err)
+		}
+	}
+	return newGCM(key)
+}
+
+// newGCM 创建 AES-256-GCM
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// randomNonce 生成随机 nonce
+func randomNonce() ([]byte, error) {
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return nonce, nil
+}
@@ -0,0 +1,144 @@
+package secure
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestKeyringRoundTrip(t *testing.T) {
+	derived, err := NewDerivedKeyring([]byte("test-secret"), "k1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromFile := writeKeyFile(t, "k1 "+key+"\n", "k1")
+
+	aad := []byte("messages.content\x00cli_a\x00om_1")
+	tests := []struct {
+		name    string
+		keyring *Keyring
+		plain   string
+	}{
+		{"派生密钥", derived, "我的持仓是 600519 一百股"},
+		{"密钥文件", fromFile, "hello"},
+		{"空内容", derived, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyID, sealed, err := tt.keyring.Encrypt([]byte(tt.plain), aad)
+			if err != nil {
+				t.Fatalf("Encrypt: %v", err)
+			}
+			if keyID != "k1" {
+				t.Errorf("keyID = %q, want k1", keyID)
+			}
+			if tt.plain != "" && strings.Contains(sealed, tt.plain) {
+				t.Error("ciphertext contains plaintext")
+			}
+			got, err := tt.keyring.Decrypt(keyID, sealed, aad)
+			if err != nil {
+				t.Fatalf("Decrypt: %v", err)
+			}
+			if string(got) != tt.plain {
+				t.Errorf("Decrypt = %q, want %q", got, tt.plain)
+			}
+		})
+	}
+}
+
+func TestKeyringDecryptFailures(t *testing.T) {
+	k1, _ := NewDerivedKeyring([]byte("test-secret"), "k1")
+	aad := []byte("messages.content\x00cli_a\x00om_1")
+	keyID, sealed, err := k1.Encrypt([]byte("secret message"), aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := base64.StdEncoding.DecodeString(sealed)
+	tamper := func(i int) string {
+		out := append([]byte(nil), raw...)
+		out[i] ^= 0x01
+		return base64.StdEncoding.EncodeToString(out)
+	}
+
+	otherSecret, _ := NewDerivedKeyring([]byte("other-secret"), "k1")
+	otherKey, _ := GenerateKey()
+	otherFile := writeKeyFile(t, "k2 "+otherKey+"\n", "k2")
+
+	tests := []struct {
+		name       string
+		keyring    *Keyring
+		keyID      string
+		ciphertext string
+		aad        []byte
+		wantErr    error
+	}{
+		{"其他主密钥", otherSecret, keyID, sealed, aad, nil},
+		{"错误的密钥ID", k1, "k2", sealed, aad, nil},
+		{"密钥文件中没有该密钥", otherFile, keyID, sealed, aad, ErrUnknownKey},
+		{"篡改数据密钥", k1, keyID, tamper(1 + nonceSize + 3), aad, nil},
+		{"篡改数据密文", k1, keyID, tamper(len(raw) - 1), aad, nil},
+		{"复制到其他行", k1, keyID, sealed, []byte("messages.content\x00cli_a\x00om_2"), nil},
+		{"截断", k1, keyID, base64.StdEncoding.EncodeToString(raw[:20]), aad, nil},
+		{"不是 base64", k1, keyID, "!!!", aad, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.keyring.Decrypt(tt.keyID, tt.ciphertext, tt.aad)
+			if err == nil {
+				t.Fatal("Decrypt succeeded, want error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadKeyFile(t *testing.T) {
+	key, _ := GenerateKey()
+	tests := []struct {
+		name    string
+		content string
+		primary string
+		wantErr bool
+	}{
+		{"注释和空行", "# keys\n\nk1 " + key + "\n", "k1", false},
+		{"缺少主密钥", "k1 " + key + "\n", "k2", true},
+		{"重复的密钥ID", "k1 " + key + "\nk1 " + key + "\n", "k1", true},
+		{"密钥长度错误", "k1 " + base64.StdEncoding.EncodeToString([]byte("short")) + "\n", "k1", true},
+		{"格式错误", "k1\n", "k1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "keys")
+			if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadKeyFile(path, tt.primary)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// writeKeyFile 写入临时密钥文件并加载
+func writeKeyFile(t *testing.T, content, primary string) *Keyring {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keys")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	k, err := LoadKeyFile(path, primary)
+	if err != nil {
+		t.Fatalf("LoadKeyFile: %v", err)
+	}
+	return k
+}
@@ -0,0 +1,114 @@
+package secure
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"fin_bot/metrics"
+	"fin_bot/storage"
+)
+
+// ReencryptJob 后台重新加密任务
+// 定期把未加密、使用旧密钥加密（或关闭加密后仍是密文）的消息转换为当前的目标状态，每批在独立事务中完成；
+// 无法解密的消息会被跳过并计入 reencrypt_failed_messages_total，下一轮检查时重试
+type ReencryptJob struct {
+	storage  *storage.Storage
+	interval time.Duration
+	batch    int
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewReencryptJob 创建重新加密任务
+func NewReencryptJob(store *storage.Storage, interval time.Duration, batch int) *ReencryptJob {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	if batch <= 0 {
+		batch = 200
+	}
+	return &ReencryptJob{storage: store, interval: interval, batch: batch}
+}
+
+// Start 在后台启动任务
+func (j *ReencryptJob) Start(ctx context.Context) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	runCtx, cancel := context.WithCancel(ctx)
+	j.cancel = cancel
+	j.done = make(chan struct{})
+	go j.loop(runCtx)
+	return nil
+}
+
+// Stop 停止任务，等待正在处理的批次完成
+func (j *ReencryptJob) Stop(ctx context.Context) error {
+	j.mu.Lock()
+	cancel, done := j.cancel, j.done
+	j.mu.Unlock()
+	if cancel == nil {
+		return nil
+	}
+
+	cancel()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// loop 启动时立即执行一次，之后按 interval 检查
+func (j *ReencryptJob) loop(ctx context.Context) {
+	defer close(j.done)
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		n, failed, err := j.RunOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("[reencrypt] 重新加密失败: %v", err)
+		}
+		if n > 0 || failed > 0 {
+			log.Printf("[reencrypt] 已重新加密 %d 条消息，跳过 %d 条无法解密的消息", n, failed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce 从头到尾分批处理一遍待处理的消息，返回转换成功和跳过的条数
+func (j *ReencryptJob) RunOnce(ctx context.Context) (converted, failed int, err error) {
+	defer func() {
+		if pending, err := j.storage.PendingReencryption(context.Background()); err == nil {
+			metrics.ReencryptPending.Set(float64(pending))
+		}
+	}()
+
+	var afterID int64
+	for ctx.Err() == nil {
+		result, err := j.storage.ReencryptMessages(ctx, afterID, j.batch)
+		converted += result.Updated
+		failed += result.Failed
+		metrics.Reencrypted.Add(float64(result.Updated))
+		metrics.ReencryptFailed.Add(float64(result.Failed))
+		if err != nil {
+			return converted, failed, err
+		}
+		if result.Scanned < j.batch {
+			break
+		}
+		afterID = result.LastID
+	}
+	return converted, failed, ctx.Err()
+}
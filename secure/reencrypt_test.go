@@ -0,0 +1,73 @@
+package secure
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"fin_bot/storage"
+)
+
+func TestReencryptSkipsUndecryptableRows(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.NewStorage(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	k1, _ := NewDerivedKeyring([]byte("test-secret"), "k1")
+	store.SetCipher(k1, true)
+	for i := 0; i < 5; i++ {
+		err := store.SaveMessage(ctx, &storage.Message{
+			AppID: "cli_a", TenantKey: "t", ChatID: "oc_1", MessageID: fmt.Sprintf("om_%d", i),
+			SenderID: "ou_1", SenderType: "user", Content: fmt.Sprintf(`{"text":"msg %d"}`, i),
+			MessageType: "text", CreatedAt: time.Now(),
+		})
+		if err != nil {
+			t.Fatalf("SaveMessage: %v", err)
+		}
+	}
+
+	// 第一行和第四行损坏，它们会出现在第一批和第二批中
+	if _, err := store.GetDB().ExecContext(ctx,
+		`UPDATE messages SET content = 'AQID' WHERE message_id IN ('om_0', 'om_3')`); err != nil {
+		t.Fatal(err)
+	}
+
+	// 轮换到 k2：k1 的消息都需要重新加密
+	k2, _ := NewDerivedKeyring([]byte("test-secret"), "k2")
+	store.SetCipher(k2, true)
+	job := NewReencryptJob(store, time.Minute, 2)
+
+	converted, failed, err := job.RunOnce(ctx)
+	if err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if converted != 3 || failed != 2 {
+		t.Errorf("converted=%d failed=%d, want 3 and 2", converted, failed)
+	}
+	pending, err := store.PendingReencryption(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending != 2 {
+		t.Errorf("pending = %d, want 2 (only the damaged rows)", pending)
+	}
+
+	// 再执行一次只会重试损坏的行
+	converted, failed, err = job.RunOnce(ctx)
+	if err != nil || converted != 0 || failed != 2 {
+		t.Errorf("second RunOnce = (%d, %d, %v), want (0, 2, nil)", converted, failed, err)
+	}
+
+	var keyID string
+	if err := store.GetDB().QueryRowContext(ctx, `SELECT key_id FROM messages WHERE message_id = 'om_4'`).Scan(&keyID); err != nil {
+		t.Fatal(err)
+	}
+	if keyID != "k2" {
+		t.Errorf("om_4 key_id = %q, want k2", keyID)
+	}
+}
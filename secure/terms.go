@@ -0,0 +1,73 @@
+package secure
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+var (
+	// commandPattern 机器人命令，例如 /schedule
+	commandPattern = regexp.MustCompile(`(?:^|\s)(/[A-Za-z][A-Za-z0-9_]*)`)
+	// symbolPattern 证券代码：2-5 位大写字母（AAPL），或带交易所标记的 6 位代码（600519.SH、SZ000001）
+	// 不索引单独的数字，避免把金额、账号等个人信息写入索引
+	symbolPattern = regexp.MustCompile(`\b(?:[A-Z]{2,5}|\d{6}\.(?:SH|SZ|BJ)|(?:SH|SZ|BJ)\d{6})\b`)
+)
+
+// TermFilter 决定消息中哪些词可以进入搜索索引
+// 消息内容加密后不能直接检索，索引中只保存不涉及个人信息的词：命令名、证券代码和配置的关键词
+type TermFilter struct {
+	commands bool
+	symbols  bool
+	keywords []string
+}
+
+// NewTermFilter 创建搜索词过滤规则，keywords 按包含关系匹配（不区分大小写）
+func NewTermFilter(commands, symbols bool, keywords []string) *TermFilter {
+	f := &TermFilter{commands: commands, symbols: symbols}
+	for _, kw := range keywords {
+		if kw = strings.ToLower(strings.TrimSpace(kw)); kw != "" {
+			f.keywords = append(f.keywords, kw)
+		}
+	}
+	return f
+}
+
+// Terms 提取文本中允许索引的词（小写、去重）
+// 文本为飞书消息内容 JSON 时只处理其中的 text 字段
+func (f *TermFilter) Terms(text string) []string {
+	var content struct {
+		Text string `json:"text"`
+	}
+	if json.Unmarshal([]byte(text), &content) == nil && content.Text != "" {
+		text = content.Text
+	}
+
+	seen := make(map[string]bool)
+	var terms []string
+	add := func(term string) {
+		term = strings.ToLower(term)
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+
+	if f.commands {
+		for _, m := range commandPattern.FindAllStringSubmatch(text, -1) {
+			add(m[1])
+		}
+	}
+	if f.symbols {
+		for _, m := range symbolPattern.FindAllString(text, -1) {
+			add(m)
+		}
+	}
+	lower := strings.ToLower(text)
+	for _, kw := range f.keywords {
+		if strings.Contains(lower, kw) {
+			add(kw)
+		}
+	}
+	return terms
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrUnknownApp 未托管的飞书应用
+var ErrUnknownApp = errors.New("未知的飞书应用")
+
+// App 进程内托管的一个飞书应用，拥有独立的开放平台客户端和长连接
+type App struct {
+	Name      string
+	AppID     string
+	TenantKey string // 应用所属租户，HTTP 接口未指定租户时使用
+	Lark      *LarkService
+	Monitor   *WSMonitor
+	WS        *WSClient
+}
+
+// AppRegistry 应用注册表，按名称或 App ID 查找应用
+type AppRegistry struct {
+	mu   sync.RWMutex
+	apps []*App
+}
+
+// NewAppRegistry 创建应用注册表
+func NewAppRegistry() *AppRegistry {
+	return &AppRegistry{}
+}
+
+// Add 注册应用，名称或 App ID 重复时返回错误
+func (r *AppRegistry) Add(app *App) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.apps {
+		if existing.Name == app.Name || existing.AppID == app.AppID {
+			return fmt.Errorf("飞书应用重复: name=%s, app_id=%s", app.Name, app.AppID)
+		}
+	}
+	r.apps = append(r.apps, app)
+	return nil
+}
+
+// Get 按名称或 App ID 查找应用
+func (r *AppRegistry) Get(nameOrAppID string) (*App, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, app := range r.apps {
+		if app.Name == nameOrAppID || app.AppID == nameOrAppID {
+			return app, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownApp, nameOrAppID)
+}
+
+// Default 获取默认应用（第一个注册的应用）
+func (r *AppRegistry) Default() (*App, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.apps) == 0 {
+		return nil, fmt.Errorf("%w: 没有已注册的应用", ErrUnknownApp)
+	}
+	return r.apps[0], nil
+}
+
+// All 获取所有应用（按注册顺序）
+func (r *AppRegistry) All() []*App {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]*App(nil), r.apps...)
+}
+
+// LarkService 获取指定 App ID 的飞书服务
+func (r *AppRegistry) LarkService(appID string) (*LarkService, error) {
+	app, err := r.Get(appID)
+	if err != nil {
+		return nil, err
+	}
+	return app.Lark, nil
+}
@@ -5,8 +5,13 @@ import (
 	"fmt"
 	"log"
 	"sync"
+	"time"
+
+	"fin_bot/audit"
+	"fin_bot/metrics"
 
 	lark "github.com/larksuite/oapi-sdk-go/v3"
+	larkauth "github.com/larksuite/oapi-sdk-go/v3/service/auth/v3"
 	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
 )
 
@@ -16,26 +21,85 @@ type RecentChat struct {
 	ChatType string // "p2p" 或 "group"
 }
 
+// tokenCheckTTL tenant_access_token 校验结果的缓存时间，避免就绪探针频繁请求开放平台
+const tokenCheckTTL = time.Minute
+
 // LarkService 飞书服务
 type LarkService struct {
 	client     *lark.Client
+	appID      string
+	appSecret  string
 	recentChat *RecentChat
 	mu         sync.RWMutex // 保护 recentChat 的并发访问
+
+	tokenMu        sync.Mutex
+	tokenCheckedAt time.Time
+	tokenErr       error
+
+	audit *audit.Logger // 记录每次发送和回复，为 nil 时不记录
 }
 
 // NewLarkService 创建新的飞书服务实例
-func NewLarkService(appID, appSecret string) *LarkService {
-	client := lark.NewClient(appID, appSecret)
+// baseURL 为开放平台地址，为空时使用飞书默认地址
+func NewLarkService(appID, appSecret, baseURL string) *LarkService {
+	var opts []lark.ClientOptionFunc
+	if baseURL != "" {
+		opts = append(opts, lark.WithOpenBaseUrl(baseURL))
+	}
+	client := lark.NewClient(appID, appSecret, opts...)
 	return &LarkService{
-		client: client,
+		client:    client,
+		appID:     appID,
+		appSecret: appSecret,
 	}
 }
 
+// SetAuditLogger 设置审计日志，需要在开始发送消息之前调用
+// 触发发送的操作人通过 audit.WithActor 放在 context 中
+func (s *LarkService) SetAuditLogger(l *audit.Logger) {
+	s.audit = l
+}
+
 // SendTextMessage 发送文本消息
 // receiveID: 接收者的ID（可以是 open_id, user_id, chat_id 等）
 // receiveIDType: 接收者ID类型，如 "open_id", "user_id", "chat_id"
 // content: 消息内容
 func (s *LarkService) SendTextMessage(ctx context.Context, receiveID, receiveIDType, content string) error {
+	// 构建消息内容
+	msgContent := larkim.NewTextMsgBuilder().
+		TextLine(content).
+		Build()
+
+	_, err := s.createMessage(ctx, receiveID, receiveIDType, larkim.MsgTypeText, msgContent)
+	return err
+}
+
+// SendCardMessage 发送消息卡片
+// card: 卡片 JSON（消息卡片搭建工具导出的内容）
+func (s *LarkService) SendCardMessage(ctx context.Context, receiveID, receiveIDType, card string) error {
+	_, err := s.createMessage(ctx, receiveID, receiveIDType, larkim.MsgTypeInteractive, card)
+	return err
+}
+
+// SendCard 发送消息卡片并返回消息ID，之后可以通过 PatchCard 更新卡片内容
+func (s *LarkService) SendCard(ctx context.Context, receiveID, receiveIDType, card string) (messageID string, err error) {
+	return s.createMessage(ctx, receiveID, receiveIDType, larkim.MsgTypeInteractive, card)
+}
+
+// SendMessage 发送任意类型的消息，content 为已序列化的消息内容（例如 post 或卡片 JSON）
+func (s *LarkService) SendMessage(ctx context.Context, receiveID, receiveIDType, msgType, content string) error {
+	_, err := s.createMessage(ctx, receiveID, receiveIDType, msgType, content)
+	return err
+}
+
+// createMessage 调用发送消息接口，content 为已序列化的消息内容，返回新消息的ID
+func (s *LarkService) createMessage(ctx context.Context, receiveID, receiveIDType, msgType, content string) (messageID string, err error) {
+	start := time.Now()
+	defer func() {
+		metrics.ObserveLarkAPI("im.message.create", start, err)
+		s.audit.Send(ctx, s.appID, receiveIDType, receiveID, "", msgType, content, err)
+	}()
+
 	// 验证并规范化 receiveIDType
 	var receiveIDTypeStr string
 	switch receiveIDType {
@@ -45,39 +109,147 @@ func (s *LarkService) SendTextMessage(ctx context.Context, receiveID, receiveIDT
 		receiveIDTypeStr = larkim.ReceiveIdTypeOpenId // 默认使用 open_id
 	}
 
-	// 构建消息内容
-	msgContent := larkim.NewTextMsgBuilder().
-		TextLine(content).
-		Build()
-
 	// 发送消息
 	resp, err := s.client.Im.Message.Create(ctx, larkim.NewCreateMessageReqBuilder().
 		ReceiveIdType(receiveIDTypeStr).
 		Body(larkim.NewCreateMessageReqBodyBuilder().
-			MsgType(larkim.MsgTypeText).
+			MsgType(msgType).
 			ReceiveId(receiveID).
-			Content(msgContent).
+			Content(content).
 			Build()).
 		Build())
 
 	if err != nil {
-		return fmt.Errorf("发送消息失败: %w", err)
+		return "", fmt.Errorf("发送消息失败: %w", err)
 	}
 
 	if !resp.Success() {
-		return fmt.Errorf("发送消息失败: code=%d, msg=%s, request_id=%s", 
+		return "", fmt.Errorf("发送消息失败: code=%d, msg=%s, request_id=%s", 
 			resp.Code, resp.Msg, resp.RequestId())
 	}
 
+	if resp.Data == nil || resp.Data.MessageId == nil {
+		return "", fmt.Errorf("发送消息失败: 响应中没有 message_id, request_id=%s", resp.RequestId())
+	}
+
 	log.Printf("消息发送成功: message_id=%s", *resp.Data.MessageId)
+	return *resp.Data.MessageId, nil
+}
+
+// ReplyTextMessage 以回复的形式发送文本消息
+// messageID: 被回复消息的ID
+// content: 消息内容
+func (s *LarkService) ReplyTextMessage(ctx context.Context, messageID, content string) error {
+	msgContent := larkim.NewTextMsgBuilder().
+		TextLine(content).
+		Build()
+
+	return s.ReplyMessage(ctx, messageID, larkim.MsgTypeText, msgContent)
+}
+
+// ReplyMessage 以回复的形式发送任意类型的消息，content 为已序列化的消息内容
+func (s *LarkService) ReplyMessage(ctx context.Context, messageID, msgType, content string) (err error) {
+	start := time.Now()
+	defer func() {
+		metrics.ObserveLarkAPI("im.message.reply", start, err)
+		s.audit.Send(ctx, s.appID, "", "", messageID, msgType, content, err)
+	}()
+
+	resp, err := s.client.Im.Message.Reply(ctx, larkim.NewReplyMessageReqBuilder().
+		MessageId(messageID).
+		Body(larkim.NewReplyMessageReqBodyBuilder().
+			MsgType(msgType).
+			Content(content).
+			Build()).
+		Build())
+
+	if err != nil {
+		return fmt.Errorf("回复消息失败: %w", err)
+	}
+
+	if !resp.Success() {
+		return fmt.Errorf("回复消息失败: code=%d, msg=%s, request_id=%s",
+			resp.Code, resp.Msg, resp.RequestId())
+	}
+
 	return nil
 }
 
+// PatchCard 更新已发送的消息卡片，card 为序列化后的卡片 JSON
+// 群聊中的卡片需要设置 update_multi 才会对所有成员更新；同一条消息的更新频率受开放平台限制（5 QPS）
+func (s *LarkService) PatchCard(ctx context.Context, messageID, card string) (err error) {
+	start := time.Now()
+	defer func() {
+		metrics.ObserveLarkAPI("im.message.patch", start, err)
+		s.audit.Patch(ctx, s.appID, messageID, card, err)
+	}()
+
+	resp, err := s.client.Im.Message.Patch(ctx, larkim.NewPatchMessageReqBuilder().
+		MessageId(messageID).
+		Body(larkim.NewPatchMessageReqBodyBuilder().
+			Content(card).
+			Build()).
+		Build())
+
+	if err != nil {
+		return fmt.Errorf("更新消息卡片失败: %w", err)
+	}
+
+	if !resp.Success() {
+		return fmt.Errorf("更新消息卡片失败: code=%d, msg=%s, request_id=%s",
+			resp.Code, resp.Msg, resp.RequestId())
+	}
+
+	return nil
+}
+
+// AppID 获取应用的 App ID
+func (s *LarkService) AppID() string {
+	return s.appID
+}
+
 // GetClient 获取 Lark 客户端（用于其他需要直接使用 client 的场景）
 func (s *LarkService) GetClient() *lark.Client {
 	return s.client
 }
 
+// CheckTenantToken 校验应用凭证能否换取 tenant_access_token
+// 结果会缓存 tokenCheckTTL，返回值中的时间为最近一次实际校验的时间
+func (s *LarkService) CheckTenantToken(ctx context.Context) (time.Time, error) {
+	s.tokenMu.Lock()
+	defer s.tokenMu.Unlock()
+
+	if !s.tokenCheckedAt.IsZero() && time.Since(s.tokenCheckedAt) < tokenCheckTTL {
+		return s.tokenCheckedAt, s.tokenErr
+	}
+
+	s.tokenErr = s.fetchTenantToken(ctx)
+	s.tokenCheckedAt = time.Now()
+	return s.tokenCheckedAt, s.tokenErr
+}
+
+// fetchTenantToken 调用开放平台获取 tenant_access_token
+func (s *LarkService) fetchTenantToken(ctx context.Context) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveLarkAPI("auth.tenant_access_token", start, err) }()
+
+	resp, err := s.client.Auth.V3.TenantAccessToken.Internal(ctx, larkauth.NewInternalTenantAccessTokenReqBuilder().
+		Body(larkauth.NewInternalTenantAccessTokenReqBodyBuilder().
+			AppId(s.appID).
+			AppSecret(s.appSecret).
+			Build()).
+		Build())
+	if err != nil {
+		return fmt.Errorf("获取 tenant_access_token 失败: %w", err)
+	}
+
+	if !resp.Success() {
+		return fmt.Errorf("获取 tenant_access_token 失败: code=%d, msg=%s", resp.Code, resp.Msg)
+	}
+
+	return nil
+}
+
 // UpdateRecentChat 更新最近交互的会话信息（当收到用户消息时调用）
 func (s *LarkService) UpdateRecentChat(chatID, chatType string) {
 	s.mu.Lock()
@@ -103,21 +275,9 @@ func (s *LarkService) GetChatList(ctx context.Context) ([]string, error) {
 	pageSize := 50
 
 	for {
-		req := larkim.NewListChatReqBuilder().
-			UserIdType(larkim.UserIdTypeListChatUserId).
-			PageSize(pageSize)
-
-		if pageToken != "" {
-			req.PageToken(pageToken)
-		}
-
-		resp, err := s.client.Im.Chat.List(ctx, req.Build())
+		resp, err := s.listChatPage(ctx, pageToken, pageSize)
 		if err != nil {
-			return nil, fmt.Errorf("获取群聊列表失败: %w", err)
-		}
-
-		if !resp.Success() {
-			return nil, fmt.Errorf("获取群聊列表失败: code=%d, msg=%s", resp.Code, resp.Msg)
+			return nil, err
 		}
 
 		// 提取群聊ID（ListChat API 返回的都是群聊）
@@ -139,6 +299,58 @@ func (s *LarkService) GetChatList(ctx context.Context) ([]string, error) {
 	return chatIDs, nil
 }
 
+// listChatPage 获取一页群聊列表
+func (s *LarkService) listChatPage(ctx context.Context, pageToken string, pageSize int) (resp *larkim.ListChatResp, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveLarkAPI("im.chat.list", start, err) }()
+
+	req := larkim.NewListChatReqBuilder().
+		UserIdType(larkim.UserIdTypeListChatUserId).
+		PageSize(pageSize)
+
+	if pageToken != "" {
+		req.PageToken(pageToken)
+	}
+
+	resp, err = s.client.Im.Chat.List(ctx, req.Build())
+	if err != nil {
+		return nil, fmt.Errorf("获取群聊列表失败: %w", err)
+	}
+
+	if !resp.Success() {
+		return nil, fmt.Errorf("获取群聊列表失败: code=%d, msg=%s", resp.Code, resp.Msg)
+	}
+
+	return resp, nil
+}
+
+// GetChatOwner 获取群主的 open_id，群主为机器人或无法获取时返回空字符串
+func (s *LarkService) GetChatOwner(ctx context.Context, chatID string) (ownerID string, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveLarkAPI("im.chat.get", start, err) }()
+
+	req := larkim.NewGetChatReqBuilder().
+		ChatId(chatID).
+		UserIdType(larkim.UserIdTypeGetChatOpenId).
+		Build()
+
+	resp, err := s.client.Im.Chat.Get(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("获取群信息失败: %w", err)
+	}
+	if !resp.Success() {
+		return "", fmt.Errorf("获取群信息失败: code=%d, msg=%s", resp.Code, resp.Msg)
+	}
+
+	if resp.Data.OwnerIdType != nil && *resp.Data.OwnerIdType != larkim.UserIdTypeGetChatOpenId {
+		return "", nil
+	}
+	if resp.Data.OwnerId == nil {
+		return "", nil
+	}
+	return *resp.Data.OwnerId, nil
+}
+
 // SendMessageToAllChats 向所有群聊发送消息
 func (s *LarkService) SendMessageToAllChats(ctx context.Context, content string) (map[string]interface{}, error) {
 	// 获取所有群聊
@@ -166,6 +378,7 @@ func (s *LarkService) SendMessageToAllChats(ctx context.Context, content string)
 		err := s.SendTextMessage(ctx, chatID, "chat_id", content)
 		if err != nil {
 			failedCount++
+			metrics.BroadcastResults.WithLabelValues("failed").Inc()
 			results = append(results, map[string]interface{}{
 				"chat_id": chatID,
 				"status":  "failed",
@@ -174,6 +387,7 @@ func (s *LarkService) SendMessageToAllChats(ctx context.Context, content string)
 			log.Printf("向群聊 %s 发送消息失败: %v", chatID, err)
 		} else {
 			successCount++
+			metrics.BroadcastResults.WithLabelValues("success").Inc()
 			results = append(results, map[string]interface{}{
 				"chat_id": chatID,
 				"status":  "success",
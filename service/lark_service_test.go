@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSendCardWithoutMessageID(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"没有 data", `{"code":0,"msg":"success"}`},
+		{"data 中没有 message_id", `{"code":0,"msg":"success","data":{"chat_id":"oc_1"}}`},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("POST /open-apis/auth/v3/tenant_access_token/internal", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"code":0,"msg":"ok","tenant_access_token":"t-test","expire":7200}`))
+			})
+			mux.HandleFunc("POST /open-apis/im/v1/messages", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(tt.body))
+			})
+			srv := httptest.NewServer(mux)
+			defer srv.Close()
+
+			// SDK 按 App ID 缓存 tenant_access_token，每个用例使用不同的 App ID
+			s := NewLarkService("cli_no_message_id_"+string(rune('a'+i)), "secret", srv.URL)
+			id, err := s.SendCard(context.Background(), "oc_1", "chat_id", `{}`)
+			if err == nil {
+				t.Fatalf("SendCard = %q, want error", id)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/larksuite/oapi-sdk-go/v3/event/dispatcher"
+	larkws "github.com/larksuite/oapi-sdk-go/v3/ws"
+)
+
+// WSClient 飞书 WebSocket 长连接客户端，每个应用只持有一个 SDK 客户端
+//
+// 断线重连交给 SDK 自带的自动重连，连接状态通过 WSMonitor 解析 SDK 日志得到。
+// SDK 的 Start 建连成功后会永久阻塞，只有遇到不可重试的错误（例如凭证无效、被封禁）时才会返回，
+// 此时记录错误后不再重试，由健康检查报告该应用的长连接不可用。
+//
+// SDK 没有关闭连接的接口，重连循环也不检查 ctx，Stop 无法中断 SDK：已建立的连接会继续接收事件，
+// 直到进程退出；Stop 取消的 ctx 只会让 SDK 之后获取连接地址的请求失败，断线后不会再连上
+type WSClient struct {
+	monitor *WSMonitor
+	cli     *larkws.Client
+
+	mu      sync.Mutex
+	started bool
+	cancel  context.CancelFunc
+}
+
+// NewWSClient 创建新的长连接客户端，baseURL 为空时使用飞书默认地址
+func NewWSClient(appID, appSecret, baseURL string, handler *dispatcher.EventDispatcher, monitor *WSMonitor) *WSClient {
+	opts := []larkws.ClientOption{
+		larkws.WithEventHandler(handler),
+		larkws.WithLogger(monitor),
+		larkws.WithAutoReconnect(true),
+	}
+	if baseURL != "" {
+		opts = append(opts, larkws.WithDomain(baseURL))
+	}
+	return &WSClient{
+		monitor: monitor,
+		cli:     larkws.NewClient(appID, appSecret, opts...),
+	}
+}
+
+// Start 在后台建立连接，立即返回；重复调用不会建立新的连接
+func (c *WSClient) Start(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.started {
+		return nil
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	c.cancel = cancel
+	c.started = true
+	go c.run(runCtx)
+	return nil
+}
+
+// Stop 取消 SDK 使用的 ctx，见 WSClient 的说明：已建立的连接不会断开
+func (c *WSClient) Stop(ctx context.Context) error {
+	c.mu.Lock()
+	cancel := c.cancel
+	c.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	return nil
+}
+
+// run 调用 SDK 建立连接，SDK 返回时说明遇到了不可重试的错误
+// 建连成功后 SDK 的 Start 不再返回，该 goroutine 随之常驻（每个应用一个）
+func (c *WSClient) run(ctx context.Context) {
+	log.Printf("[%s] 正在建立 WebSocket 连接，用于接收用户消息...", c.monitor.app)
+	err := c.cli.Start(ctx)
+	if ctx.Err() != nil {
+		log.Printf("[%s] WebSocket 连接已停止", c.monitor.app)
+		return
+	}
+	if err != nil {
+		log.Printf("[%s] WebSocket 连接失败，不再重试: %v", c.monitor.app, err)
+		c.monitor.SetError(err)
+	}
+}
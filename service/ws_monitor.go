@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"fin_bot/metrics"
+
+	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
+	larkws "github.com/larksuite/oapi-sdk-go/v3/ws"
+)
+
+// WSMonitor 跟踪 WebSocket 长连接状态
+// 飞书 SDK 的 ws.Client 没有暴露连接状态，这里通过包装其日志输出来感知建连、断开和重连
+type WSMonitor struct {
+	app        string
+	logger     larkcore.Logger
+	mu         sync.RWMutex
+	connected  bool
+	lastChange time.Time
+	lastError  string
+}
+
+// WSStatus WebSocket 连接状态快照
+type WSStatus struct {
+	Connected  bool      `json:"connected"`
+	LastChange time.Time `json:"last_change"`
+	LastError  string    `json:"last_error,omitempty"`
+}
+
+// NewWSMonitor 创建连接状态监控器，app 为应用名称（用于指标标签），日志仍然转发给 SDK 默认 logger
+func NewWSMonitor(app string, level larkcore.LogLevel) *WSMonitor {
+	return &WSMonitor{
+		app:    app,
+		logger: larkcore.NewDefaultLogger(level),
+	}
+}
+
+// Connected 当前是否已建立连接
+func (m *WSMonitor) Connected() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.connected
+}
+
+// Status 获取连接状态快照
+func (m *WSMonitor) Status() WSStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return WSStatus{
+		Connected:  m.connected,
+		LastChange: m.lastChange,
+		LastError:  m.lastError,
+	}
+}
+
+// SetError 记录连接错误（例如 Start 返回失败），并标记为未连接
+func (m *WSMonitor) SetError(err error) {
+	m.mu.Lock()
+	m.connected = false
+	m.lastChange = time.Now()
+	m.lastError = err.Error()
+	m.mu.Unlock()
+	metrics.WSConnected.WithLabelValues(m.app).Set(0)
+}
+
+// Debug 实现 larkcore.Logger
+func (m *WSMonitor) Debug(ctx context.Context, args ...interface{}) {
+	m.logger.Debug(ctx, args...)
+}
+
+// Info 实现 larkcore.Logger
+func (m *WSMonitor) Info(ctx context.Context, args ...interface{}) {
+	m.observe(args)
+	m.logger.Info(ctx, args...)
+}
+
+// Warn 实现 larkcore.Logger
+func (m *WSMonitor) Warn(ctx context.Context, args ...interface{}) {
+	m.logger.Warn(ctx, args...)
+}
+
+// Error 实现 larkcore.Logger
+func (m *WSMonitor) Error(ctx context.Context, args ...interface{}) {
+	m.observe(args)
+	m.logger.Error(ctx, args...)
+}
+
+// observe 根据 SDK 日志内容更新连接状态
+func (m *WSMonitor) observe(args []interface{}) {
+	if len(args) == 0 {
+		return
+	}
+	// SDK 自动重连遇到不可重试的错误时放弃重连，只输出错误本身
+	if err, ok := args[0].(*larkws.ClientError); ok {
+		m.SetError(fmt.Errorf("自动重连已终止: %w", err))
+		return
+	}
+	msg := fmt.Sprint(args[0])
+
+	switch {
+	case strings.HasPrefix(msg, "connected to"):
+		m.setConnected(true)
+	case strings.HasPrefix(msg, "disconnected to"),
+		strings.HasPrefix(msg, "connection is closed"):
+		m.setConnected(false)
+	case strings.HasPrefix(msg, "trying to reconnect"):
+		metrics.WSReconnects.WithLabelValues(m.app).Inc()
+	case strings.HasPrefix(msg, "connect failed"):
+		m.mu.Lock()
+		m.lastError = msg
+		m.mu.Unlock()
+	}
+}
+
+// setConnected 更新连接状态和对应指标
+func (m *WSMonitor) setConnected(connected bool) {
+	m.mu.Lock()
+	m.connected = connected
+	m.lastChange = time.Now()
+	if connected {
+		m.lastError = ""
+	}
+	m.mu.Unlock()
+
+	if connected {
+		metrics.WSConnected.WithLabelValues(m.app).Set(1)
+	} else {
+		metrics.WSConnected.WithLabelValues(m.app).Set(0)
+	}
+}
@@ -0,0 +1,280 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"fin_bot/metrics"
+)
+
+// 提醒规则的状态
+const (
+	AlertActive    = "active"    // 生效中
+	AlertTriggered = "triggered" // 一次性提醒已触发
+	AlertCancelled = "cancelled" // 已被用户删除
+)
+
+// AlertRule 价格或指标提醒规则，属于创建的用户
+type AlertRule struct {
+	ID              int64      `json:"id"`
+	AppID           string     `json:"app_id"`
+	TenantKey       string     `json:"tenant_key"`
+	UserID          string     `json:"user_id"` // 创建者 open_id，提醒发送给该用户
+	ChatID          string     `json:"chat_id"` // 创建规则的会话
+	Symbol          string     `json:"symbol"`  // 规范化的代码，例如 600519.SH
+	Metric          string     `json:"metric"`  // price、change 或 rsi
+	Period          int        `json:"period,omitempty"`
+	Op              string     `json:"op"` // >= 或 <=
+	Threshold       float64    `json:"threshold"`
+	Repeat          bool       `json:"repeat"`
+	Text            string     `json:"text"` // 创建时的原文
+	Status          string     `json:"status"`
+	Armed           bool       `json:"armed"`
+	TriggerCount    int        `json:"trigger_count"`
+	LastTriggeredAt *time.Time `json:"last_triggered_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// Scope 提醒规则的归属范围
+func (r *AlertRule) Scope() Scope {
+	return Scope{AppID: r.AppID, TenantKey: r.TenantKey}
+}
+
+// AlertEvent 提醒的一次触发
+type AlertEvent struct {
+	ID          int64     `json:"id"`
+	RuleID      int64     `json:"rule_id"`
+	AppID       string    `json:"app_id"`
+	TenantKey   string    `json:"tenant_key"`
+	UserID      string    `json:"user_id"`
+	Symbol      string    `json:"symbol"`
+	Metric      string    `json:"metric"`
+	Condition   string    `json:"condition"` // 触发时规则条件的描述
+	Value       float64   `json:"value"`     // 触发时指标的值
+	Message     string    `json:"message"`   // 发送给用户的内容
+	Delivered   bool      `json:"delivered"`
+	Error       string    `json:"error,omitempty"`
+	TriggeredAt time.Time `json:"triggered_at"`
+}
+
+const alertRuleColumns = `id, app_id, tenant_key, user_id, chat_id, symbol, metric, period, op, threshold, repeat, text,
+	status, armed, trigger_count, last_triggered_at, created_at, updated_at`
+
+// CreateAlertRule 创建提醒规则（生效中），成功后回填 ID
+func (s *Storage) CreateAlertRule(ctx context.Context, r *AlertRule) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("create_alert_rule", start, err) }()
+
+	now := time.Now().UTC()
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO alert_rules (app_id, tenant_key, user_id, chat_id, symbol, metric, period, op, threshold, repeat, text,
+			status, armed, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1, ?, ?)
+	`, r.AppID, r.TenantKey, r.UserID, r.ChatID, r.Symbol, r.Metric, r.Period, r.Op, r.Threshold, r.Repeat, r.Text,
+		AlertActive, now, now)
+	if err != nil {
+		return fmt.Errorf("保存提醒规则失败: %w", err)
+	}
+	r.ID, _ = result.LastInsertId()
+	r.Status, r.Armed, r.CreatedAt, r.UpdatedAt = AlertActive, true, now, now
+	return nil
+}
+
+// GetAlertRule 获取用户的提醒规则，不存在或不属于该用户时返回 ErrNotFound
+func (s *Storage) GetAlertRule(ctx context.Context, scope Scope, userID string, id int64) (r *AlertRule, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("get_alert_rule", start, err) }()
+
+	row := s.db.QueryRowContext(ctx, `SELECT `+alertRuleColumns+` FROM alert_rules
+		WHERE id = ? AND app_id = ? AND tenant_key = ? AND user_id = ?`, id, scope.AppID, scope.TenantKey, userID)
+	r, err = scanAlertRule(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询提醒规则失败: %w", err)
+	}
+	return r, nil
+}
+
+// ListAlertRules 获取用户生效中的提醒规则（按创建顺序）
+func (s *Storage) ListAlertRules(ctx context.Context, scope Scope, userID string) (rules []*AlertRule, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("list_alert_rules", start, err) }()
+
+	return s.queryAlertRules(ctx, `WHERE app_id = ? AND tenant_key = ? AND user_id = ? AND status = ? ORDER BY id`,
+		scope.AppID, scope.TenantKey, userID, AlertActive)
+}
+
+// ListActiveAlertRules 获取所有应用生效中的提醒规则（仅供提醒检查使用）
+func (s *Storage) ListActiveAlertRules(ctx context.Context) (rules []*AlertRule, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("list_active_alert_rules", start, err) }()
+
+	return s.queryAlertRules(ctx, `WHERE status = ? ORDER BY id`, AlertActive)
+}
+
+// queryAlertRules 按条件查询提醒规则
+func (s *Storage) queryAlertRules(ctx context.Context, where string, args ...interface{}) ([]*AlertRule, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+alertRuleColumns+` FROM alert_rules `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询提醒规则失败: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []*AlertRule
+	for rows.Next() {
+		r, err := scanAlertRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("扫描提醒规则失败: %w", err)
+		}
+		rules = append(rules, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历提醒规则失败: %w", err)
+	}
+	return rules, nil
+}
+
+// CancelAlertRule 删除用户生效中的提醒规则（保留触发记录），不存在时返回 ErrNotFound
+func (s *Storage) CancelAlertRule(ctx context.Context, scope Scope, userID string, id int64) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("cancel_alert_rule", start, err) }()
+
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE alert_rules SET status = ?, updated_at = ?
+		WHERE id = ? AND app_id = ? AND tenant_key = ? AND user_id = ? AND status = ?
+	`, AlertCancelled, time.Now().UTC(), id, scope.AppID, scope.TenantKey, userID, AlertActive)
+	if err != nil {
+		return fmt.Errorf("删除提醒规则失败: %w", err)
+	}
+	return checkAffected(result)
+}
+
+// SetAlertArmed 修改重复提醒是否可以再次触发
+func (s *Storage) SetAlertArmed(ctx context.Context, id int64, armed bool) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("set_alert_armed", start, err) }()
+
+	result, err := s.db.ExecContext(ctx, `UPDATE alert_rules SET armed = ?, updated_at = ? WHERE id = ? AND status = ?`,
+		armed, time.Now().UTC(), id, AlertActive)
+	if err != nil {
+		return fmt.Errorf("更新提醒规则失败: %w", err)
+	}
+	return checkAffected(result)
+}
+
+// RecordAlertTrigger 在发送提醒之前记录一次触发：保存触发记录、累计触发次数，
+// 重复提醒等待再次生效，一次性提醒（done 为 true）结束；规则已不是生效中时返回 ErrNotFound
+// 先记录再发送，保证同一次触发不会重复发送
+func (s *Storage) RecordAlertTrigger(ctx context.Context, e *AlertEvent, done bool) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("record_alert_trigger", start, err) }()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	status := AlertActive
+	if done {
+		status = AlertTriggered
+	}
+	triggeredAt := e.TriggeredAt.UTC()
+	result, err := tx.ExecContext(ctx, `
+		UPDATE alert_rules SET status = ?, armed = 0, trigger_count = trigger_count + 1, last_triggered_at = ?, updated_at = ?
+		WHERE id = ? AND status = ?
+	`, status, triggeredAt, time.Now().UTC(), e.RuleID, AlertActive)
+	if err != nil {
+		return fmt.Errorf("更新提醒规则失败: %w", err)
+	}
+	if err := checkAffected(result); err != nil {
+		return err
+	}
+
+	result, err = tx.ExecContext(ctx, `
+		INSERT INTO alert_events (rule_id, app_id, tenant_key, user_id, symbol, metric, condition, value, message, delivered, error,
+			triggered_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, e.RuleID, e.AppID, e.TenantKey, e.UserID, e.Symbol, e.Metric, e.Condition, e.Value, e.Message, e.Delivered, e.Error, triggeredAt)
+	if err != nil {
+		return fmt.Errorf("保存提醒记录失败: %w", err)
+	}
+	e.ID, _ = result.LastInsertId()
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	return nil
+}
+
+// MarkAlertDelivered 记录提醒的投递结果，errMsg 为空表示已送达
+func (s *Storage) MarkAlertDelivered(ctx context.Context, eventID int64, errMsg string) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("mark_alert_delivered", start, err) }()
+
+	result, err := s.db.ExecContext(ctx, `UPDATE alert_events SET delivered = ?, error = ? WHERE id = ?`,
+		errMsg == "", errMsg, eventID)
+	if err != nil {
+		return fmt.Errorf("更新提醒记录失败: %w", err)
+	}
+	return checkAffected(result)
+}
+
+// ListAlertEvents 获取用户最近的提醒记录（按时间倒序），ruleID 不为 0 时只返回该规则的记录
+func (s *Storage) ListAlertEvents(ctx context.Context, scope Scope, userID string, ruleID int64, limit int) (events []*AlertEvent, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("list_alert_events", start, err) }()
+
+	if limit <= 0 {
+		limit = 20
+	}
+	query := `SELECT id, rule_id, app_id, tenant_key, user_id, symbol, metric, condition, value, message, delivered, error, triggered_at
+		FROM alert_events WHERE app_id = ? AND tenant_key = ? AND user_id = ?`
+	args := []interface{}{scope.AppID, scope.TenantKey, userID}
+	if ruleID != 0 {
+		query += ` AND rule_id = ?`
+		args = append(args, ruleID)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询提醒记录失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e AlertEvent
+		if err := rows.Scan(&e.ID, &e.RuleID, &e.AppID, &e.TenantKey, &e.UserID, &e.Symbol, &e.Metric, &e.Condition, &e.Value, &e.Message,
+			&e.Delivered, &e.Error, &e.TriggeredAt); err != nil {
+			return nil, fmt.Errorf("扫描提醒记录失败: %w", err)
+		}
+		events = append(events, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历提醒记录失败: %w", err)
+	}
+	return events, nil
+}
+
+// scanAlertRule 扫描一行提醒规则
+func scanAlertRule(row rowScanner) (*AlertRule, error) {
+	var r AlertRule
+	var lastTriggeredAt sql.NullTime
+	err := row.Scan(&r.ID, &r.AppID, &r.TenantKey, &r.UserID, &r.ChatID, &r.Symbol, &r.Metric, &r.Period, &r.Op,
+		&r.Threshold, &r.Repeat, &r.Text, &r.Status, &r.Armed, &r.TriggerCount, &lastTriggeredAt, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if lastTriggeredAt.Valid {
+		r.LastTriggeredAt = &lastTriggeredAt.Time
+	}
+	return &r, nil
+}
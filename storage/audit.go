@@ -0,0 +1,234 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"fin_bot/metrics"
+)
+
+// AuditEntry 一条审计日志（谁在什么时候对什么做了什么）
+// 审计日志只能追加：每条记录的 Hash 覆盖上一条记录的 Hash 和本条内容，修改或删除任意一条都会使之后的链校验失败
+type AuditEntry struct {
+	ID        int64     `json:"id"`
+	AppID     string    `json:"app_id"`     // 为空表示与应用无关的操作（例如配置重载）
+	TenantKey string    `json:"tenant_key"` // 为空表示与租户无关的操作
+	Actor     string    `json:"actor"`      // 操作人：用户 open_id、http:<IP>、cli:<用户名> 或 system
+	Action    string    `json:"action"`     // 操作类型，例如 role.grant
+	Target    string    `json:"target,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	PrevHash  string    `json:"prev_hash"`
+	Hash      string    `json:"hash"`
+}
+
+// AuditFilter 查询审计日志的条件，零值字段不限制
+type AuditFilter struct {
+	Scope         *Scope // 为 nil 时不限制应用和租户
+	IncludeGlobal bool   // Scope 不为 nil 时是否同时返回与应用无关的记录
+	Action        string // 操作类型前缀，例如 "role." 匹配所有角色变更
+	Actor         string
+	Since         time.Time
+	Until         time.Time
+}
+
+// AuditChainError 审计日志哈希链校验失败
+type AuditChainError struct {
+	ID     int64
+	Reason string
+}
+
+func (e *AuditChainError) Error() string {
+	return fmt.Sprintf("审计日志哈希链在 id=%d 处断开: %s", e.ID, e.Reason)
+}
+
+const auditColumns = `id, app_id, tenant_key, actor, action, target, detail, created_at, prev_hash, hash`
+
+// auditTx withAuditTx 中执行语句的事务
+type auditTx interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// AppendAudit 写入一条审计日志，成功后回填 ID 和哈希
+func (s *Storage) AppendAudit(ctx context.Context, entry *AuditEntry) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("append_audit", start, err) }()
+
+	return s.withAuditTx(ctx, func(tx auditTx) error {
+		return insertAudit(ctx, tx, entry)
+	})
+}
+
+// withAuditTx 在事务中执行 fn 并提交，fn 中可以调用 insertAudit 与被审计的变更一起提交
+// 写事务以 BEGIN IMMEDIATE 开始（见 dsn），读取链尾之前就持有写锁，其他进程（例如 CLI）无法在读取链尾和写入新记录之间插入记录
+func (s *Storage) withAuditTx(ctx context.Context, fn func(tx auditTx) error) error {
+	s.auditMu.Lock()
+	defer s.auditMu.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开始审计日志事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertAudit 在事务中追加审计日志，只能在 withAuditTx 中调用
+func insertAudit(ctx context.Context, tx auditTx, entry *AuditEntry) error {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	entry.CreatedAt = entry.CreatedAt.UTC()
+
+	var prev string
+	err := tx.QueryRowContext(ctx, `SELECT hash FROM audit_log ORDER BY id DESC LIMIT 1`).Scan(&prev)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("读取审计日志链尾失败: %w", err)
+	}
+	entry.PrevHash, entry.Hash = prev, auditHash(prev, entry)
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO audit_log (app_id, tenant_key, actor, action, target, detail, created_at, prev_hash, hash)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, entry.AppID, entry.TenantKey, entry.Actor, entry.Action, entry.Target, entry.Detail, entry.CreatedAt,
+		entry.PrevHash, entry.Hash)
+	if err != nil {
+		return fmt.Errorf("写入审计日志失败: %w", err)
+	}
+	entry.ID, err = result.LastInsertId()
+	return err
+}
+
+// auditHash 计算审计日志的哈希：SHA-256(上一条的哈希和各字段，以 \x00 分隔)
+func auditHash(prev string, e *AuditEntry) string {
+	h := sha256.New()
+	for _, field := range []string{
+		prev, e.AppID, e.TenantKey, e.Actor, e.Action, e.Target, e.Detail, e.CreatedAt.UTC().Format(time.RFC3339Nano),
+	} {
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ListAudit 获取符合条件的最近的审计日志（按时间倒序），最多 limit 条
+func (s *Storage) ListAudit(ctx context.Context, filter AuditFilter, limit int) (entries []*AuditEntry, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("list_audit", start, err) }()
+
+	where, args := filter.where()
+	rows, err := s.db.QueryContext(ctx, `SELECT `+auditColumns+` FROM audit_log`+where+` ORDER BY id DESC LIMIT ?`,
+		append(args, limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("查询审计日志失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := scanAudit(rows)
+		if err != nil {
+			return nil, fmt.Errorf("扫描审计日志失败: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历审计日志失败: %w", err)
+	}
+	return entries, nil
+}
+
+// ExportAudit 按时间顺序逐条读取符合条件的审计日志并交给 fn 处理
+func (s *Storage) ExportAudit(ctx context.Context, filter AuditFilter, fn func(*AuditEntry) error) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("export_audit", start, err) }()
+
+	where, args := filter.where()
+	rows, err := s.db.QueryContext(ctx, `SELECT `+auditColumns+` FROM audit_log`+where+` ORDER BY id`, args...)
+	if err != nil {
+		return fmt.Errorf("查询审计日志失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := scanAudit(rows)
+		if err != nil {
+			return fmt.Errorf("扫描审计日志失败: %w", err)
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// VerifyAudit 按 id 顺序校验整个审计日志的哈希链，返回记录数和链尾哈希
+// 链尾哈希可以记录到外部（例如工单或备份清单），用于发现末尾的记录被删除
+func (s *Storage) VerifyAudit(ctx context.Context) (count int, head string, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("verify_audit", start, err) }()
+
+	err = s.ExportAudit(ctx, AuditFilter{}, func(e *AuditEntry) error {
+		switch {
+		case e.PrevHash != head:
+			return &AuditChainError{ID: e.ID, Reason: "prev_hash 与上一条记录不一致（记录被删除或插入）"}
+		case e.Hash != auditHash(head, e):
+			return &AuditChainError{ID: e.ID, Reason: "内容与哈希不一致（记录被修改）"}
+		}
+		count++
+		head = e.Hash
+		return nil
+	})
+	return count, head, err
+}
+
+// where 生成查询条件
+func (f AuditFilter) where() (string, []interface{}) {
+	where := ` WHERE 1 = 1`
+	var args []interface{}
+	if f.Scope != nil {
+		if f.IncludeGlobal {
+			where += ` AND ((app_id = ? AND tenant_key = ?) OR app_id = '')`
+		} else {
+			where += ` AND app_id = ? AND tenant_key = ?`
+		}
+		args = append(args, f.Scope.AppID, f.Scope.TenantKey)
+	}
+	if f.Action != "" {
+		where += ` AND substr(action, 1, ?) = ?`
+		args = append(args, len(f.Action), f.Action)
+	}
+	if f.Actor != "" {
+		where += ` AND actor = ?`
+		args = append(args, f.Actor)
+	}
+	if !f.Since.IsZero() {
+		where += ` AND created_at >= ?`
+		args = append(args, f.Since.UTC())
+	}
+	if !f.Until.IsZero() {
+		where += ` AND created_at < ?`
+		args = append(args, f.Until.UTC())
+	}
+	return where, args
+}
+
+// scanAudit 扫描一条审计日志
+func scanAudit(row rowScanner) (*AuditEntry, error) {
+	e := &AuditEntry{}
+	if err := row.Scan(&e.ID, &e.AppID, &e.TenantKey, &e.Actor, &e.Action, &e.Target, &e.Detail, &e.CreatedAt,
+		&e.PrevHash, &e.Hash); err != nil {
+		return nil, err
+	}
+	e.CreatedAt = e.CreatedAt.UTC()
+	return e, nil
+}
@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// appendTestAudit 写入 n 条审计日志
+func appendTestAudit(t *testing.T, s *Storage, n int) []*AuditEntry {
+	t.Helper()
+	base := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	entries := make([]*AuditEntry, n)
+	for i := range entries {
+		entries[i] = &AuditEntry{
+			AppID:     "cli_a",
+			TenantKey: "t1",
+			Actor:     "ou_admin",
+			Action:    "role.grant",
+			Target:    fmt.Sprintf("ou_%d", i),
+			Detail:    "role=teacher",
+			CreatedAt: base.Add(time.Duration(i) * time.Minute),
+		}
+		if err := s.AppendAudit(context.Background(), entries[i]); err != nil {
+			t.Fatalf("AppendAudit: %v", err)
+		}
+	}
+	return entries
+}
+
+func TestVerifyAudit(t *testing.T) {
+	tests := []struct {
+		name    string
+		tamper  string // 删除触发器后执行的 SQL，为空表示不修改
+		wantID  int64  // 期望断开的位置，0 表示校验通过
+		wantLen int
+	}{
+		{name: "完整的哈希链", wantLen: 3},
+		{name: "修改内容", tamper: `UPDATE audit_log SET detail = 'role=owner' WHERE id = 2`, wantID: 2},
+		{name: "修改时间", tamper: `UPDATE audit_log SET created_at = '2020-01-01 00:00:00+00:00' WHERE id = 3`, wantID: 3},
+		{name: "删除中间的记录", tamper: `DELETE FROM audit_log WHERE id = 2`, wantID: 3},
+		{name: "替换哈希后下一条断开", tamper: `UPDATE audit_log SET detail = 'x', hash = 'forged' WHERE id = 1`, wantID: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStorage(t)
+			entries := appendTestAudit(t, s, 3)
+
+			if tt.tamper != "" {
+				for _, stmt := range []string{
+					`DROP TRIGGER audit_log_no_update`,
+					`DROP TRIGGER audit_log_no_delete`,
+					tt.tamper,
+				} {
+					if _, err := s.db.Exec(stmt); err != nil {
+						t.Fatalf("%s: %v", stmt, err)
+					}
+				}
+			}
+
+			count, head, err := s.VerifyAudit(context.Background())
+			if tt.wantID == 0 {
+				if err != nil {
+					t.Fatalf("VerifyAudit: %v", err)
+				}
+				if count != tt.wantLen || head != entries[len(entries)-1].Hash {
+					t.Errorf("VerifyAudit = (%d, %s), want (%d, %s)", count, head, tt.wantLen, entries[len(entries)-1].Hash)
+				}
+				return
+			}
+			var chainErr *AuditChainError
+			if !errors.As(err, &chainErr) {
+				t.Fatalf("VerifyAudit error = %v, want *AuditChainError", err)
+			}
+			if chainErr.ID != tt.wantID {
+				t.Errorf("断开位置 = %d, want %d（%v）", chainErr.ID, tt.wantID, err)
+			}
+		})
+	}
+}
+
+func TestAuditAppendOnly(t *testing.T) {
+	s := newTestStorage(t)
+	appendTestAudit(t, s, 1)
+	for _, stmt := range []string{
+		`UPDATE audit_log SET detail = 'x'`,
+		`DELETE FROM audit_log`,
+	} {
+		if _, err := s.db.Exec(stmt); err == nil {
+			t.Errorf("%s 应该被触发器拒绝", stmt)
+		}
+	}
+}
+
+// TestAuditConcurrentHandles 两个数据库连接（模拟服务和 CLI 两个进程）同时写入时哈希链不分叉
+func TestAuditConcurrentHandles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.db")
+	s, err := NewStorage(path)
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	defer s.Close()
+	other, err := NewStorage(path)
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	defer other.Close()
+
+	const perHandle = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*perHandle)
+	for _, store := range []*Storage{s, other} {
+		wg.Add(1)
+		go func(store *Storage) {
+			defer wg.Done()
+			for i := 0; i < perHandle; i++ {
+				errs <- store.AppendAudit(context.Background(), &AuditEntry{Actor: "system", Action: "config.reload"})
+			}
+		}(store)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("AppendAudit: %v", err)
+		}
+	}
+
+	count, _, err := s.VerifyAudit(context.Background())
+	if err != nil {
+		t.Fatalf("VerifyAudit: %v", err)
+	}
+	if count != 2*perHandle {
+		t.Errorf("count = %d, want %d", count, 2*perHandle)
+	}
+}
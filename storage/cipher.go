@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"fin_bot/metrics"
+)
+
+// FieldCipher 敏感字段加解密
+// aad 用于将密文绑定到所在的行，密文被复制到其他行时无法解密
+type FieldCipher interface {
+	// PrimaryKeyID 加密新数据使用的密钥ID
+	PrimaryKeyID() string
+	// HasKey 是否持有指定ID的密钥
+	HasKey(keyID string) bool
+	// Encrypt 使用主密钥加密，返回密钥ID和可以直接存入 TEXT 列的密文
+	Encrypt(plaintext, aad []byte) (keyID, ciphertext string, err error)
+	// Decrypt 使用指定ID的密钥解密
+	Decrypt(keyID, ciphertext string, aad []byte) ([]byte, error)
+}
+
+// ErrNoCipher 数据已加密但未配置对应的密钥
+var ErrNoCipher = errors.New("数据已加密，但未配置可用的密钥")
+
+// SetCipher 设置敏感字段加解密（需要在开始读写之前调用）
+// encrypt 为 true 时新写入的数据使用主密钥加密，否则以明文写入；已加密的数据在两种情况下都能读取，
+// ReencryptMessages 会把存量数据逐步转换为当前的目标状态
+func (s *Storage) SetCipher(c FieldCipher, encrypt bool) {
+	s.cipher = c
+	s.encrypt = encrypt && c != nil
+}
+
+// targetKeyID 存量数据应当使用的密钥ID（为空表示明文）
+func (s *Storage) targetKeyID() string {
+	if !s.encrypt {
+		return ""
+	}
+	return s.cipher.PrimaryKeyID()
+}
+
+// sealContent 按当前设置加密消息内容，返回密钥ID（明文时为空）和写入数据库的内容
+func (s *Storage) sealContent(appID, messageID, content string) (string, string, error) {
+	if !s.encrypt {
+		return "", content, nil
+	}
+	keyID, sealed, err := s.cipher.Encrypt([]byte(content), messageAAD(appID, messageID))
+	if err != nil {
+		return "", "", fmt.Errorf("加密消息内容失败: %w", err)
+	}
+	return keyID, sealed, nil
+}
+
+// openContent 解密数据库中的消息内容，keyID 为空表示明文
+func (s *Storage) openContent(appID, messageID, keyID, stored string) (string, error) {
+	if keyID == "" {
+		return stored, nil
+	}
+	if s.cipher == nil || !s.cipher.HasKey(keyID) {
+		return "", fmt.Errorf("%w: key_id=%s", ErrNoCipher, keyID)
+	}
+	plain, err := s.cipher.Decrypt(keyID, stored, messageAAD(appID, messageID))
+	if err != nil {
+		return "", fmt.Errorf("解密消息 %s 失败: %w", messageID, err)
+	}
+	return string(plain), nil
+}
+
+// messageAAD 消息内容密文绑定的附加数据
+func messageAAD(appID, messageID string) []byte {
+	return []byte("messages.content\x00" + appID + "\x00" + messageID)
+}
+
+// KeyIDsInUse 获取数据库中已加密数据使用的密钥ID，用于启动时确认密钥齐全
+func (s *Storage) KeyIDsInUse(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT DISTINCT key_id FROM messages WHERE key_id != '' ORDER BY key_id`)
+	if err != nil {
+		return nil, fmt.Errorf("查询密钥ID失败: %w", err)
+	}
+	defer rows.Close()
+
+	var keyIDs []string
+	for rows.Next() {
+		var keyID string
+		if err := rows.Scan(&keyID); err != nil {
+			return nil, fmt.Errorf("扫描密钥ID失败: %w", err)
+		}
+		keyIDs = append(keyIDs, keyID)
+	}
+	return keyIDs, rows.Err()
+}
+
+// PendingReencryption 统计密钥ID与目标不一致、需要重新加密（或解密为明文）的消息数
+func (s *Storage) PendingReencryption(ctx context.Context) (int, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM messages WHERE key_id != ?`, s.targetKeyID()).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("统计待重新加密的消息失败: %w", err)
+	}
+	return n, nil
+}
+
+// ReencryptResult 一批重新加密的结果
+type ReencryptResult struct {
+	Scanned int   // 读取的行数，小于 batch 表示后面已没有待处理的消息
+	Updated int   // 转换成功的行数
+	Failed  int   // 解密失败而跳过的行数，留在原状态，之后重试
+	LastID  int64 // 本批最后一行的 id，下一批从它之后开始
+}
+
+// ReencryptMessages 将 id 大于 afterID 的一批密钥ID与目标不一致的消息转换为目标状态：
+// 开启加密时用主密钥重新加密，关闭加密时解密为明文。
+// 无法解密的行（密钥缺失或数据损坏）记录日志后跳过，不影响同一批中的其他行；
+// 调用方用返回的 LastID 作为下一批的 afterID，避免反复读到同一批坏数据
+func (s *Storage) ReencryptMessages(ctx context.Context, afterID int64, batch int) (result ReencryptResult, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("reencrypt_messages", start, err) }()
+
+	result.LastID = afterID
+	target := s.targetKeyID()
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, app_id, message_id, key_id, content FROM messages WHERE key_id != ? AND id > ? ORDER BY id LIMIT ?`,
+		target, afterID, batch)
+	if err != nil {
+		return result, fmt.Errorf("查询待重新加密的消息失败: %w", err)
+	}
+	type pendingRow struct {
+		id                               int64
+		appID, messageID, keyID, content string
+	}
+	var pending []pendingRow
+	for rows.Next() {
+		var r pendingRow
+		if err := rows.Scan(&r.id, &r.appID, &r.messageID, &r.keyID, &r.content); err != nil {
+			rows.Close()
+			return result, fmt.Errorf("扫描消息失败: %w", err)
+		}
+		pending = append(pending, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return result, fmt.Errorf("遍历消息失败: %w", err)
+	}
+	if len(pending) == 0 {
+		return result, nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	var sealErr error
+	for _, r := range pending {
+		plain, err := s.openContent(r.appID, r.messageID, r.keyID, r.content)
+		if err != nil {
+			log.Printf("[Storage.ReencryptMessages] 跳过无法解密的消息: id=%d, key_id=%s, error=%v", r.id, r.keyID, err)
+			result.Failed++
+			result.Scanned++
+			result.LastID = r.id
+			continue
+		}
+		keyID, sealed, err := s.sealContent(r.appID, r.messageID, plain)
+		if err != nil {
+			// 加密失败与具体的行无关，保留已转换的行后停止
+			sealErr = err
+			break
+		}
+		// 只更新读取之后没有被改写的行
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE messages SET key_id = ?, content = ? WHERE id = ? AND key_id = ? AND content = ?`,
+			keyID, sealed, r.id, r.keyID, r.content); err != nil {
+			return ReencryptResult{LastID: afterID}, fmt.Errorf("更新消息失败: %w", err)
+		}
+		result.Updated++
+		result.Scanned++
+		result.LastID = r.id
+	}
+	if err := tx.Commit(); err != nil {
+		return ReencryptResult{LastID: afterID}, fmt.Errorf("提交重新加密失败: %w", err)
+	}
+	return result, sealErr
+}
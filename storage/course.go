@@ -0,0 +1,422 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"fin_bot/metrics"
+)
+
+// Course 课程（由 Markdown 文件导入，所有应用共用）
+type Course struct {
+	ID          string    `json:"id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description,omitempty"`
+	Intro       string    `json:"intro,omitempty"` // course.md 的正文，报名时展示
+	Position    int       `json:"position"`
+	Source      string    `json:"source,omitempty"` // 导入时的目录
+	UpdatedAt   time.Time `json:"updated_at"`
+	Lessons     int       `json:"lessons"` // 课时数，查询时统计
+}
+
+// CourseModule 课程中的模块（章节）
+type CourseModule struct {
+	CourseID string `json:"course_id"`
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Position int    `json:"position"`
+}
+
+// Lesson 一节课
+// ID 在重新导入时保持不变（按课程和 slug 匹配），可以直接用于 /lesson 命令
+type Lesson struct {
+	ID        int64     `json:"id"`
+	CourseID  string    `json:"course_id"`
+	ModuleID  string    `json:"module_id,omitempty"` // 为空表示直接放在课程目录下
+	Slug      string    `json:"slug"`
+	Title     string    `json:"title"`
+	Position  int       `json:"position"` // 在课程中的顺序（从 1 开始，跨模块连续编号）
+	Minutes   int       `json:"minutes,omitempty"`
+	Body      string    `json:"body"`
+	Source    string    `json:"source,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Enrollment 用户报名的课程
+type Enrollment struct {
+	AppID       string     `json:"app_id"`
+	TenantKey   string     `json:"tenant_key"`
+	UserID      string     `json:"user_id"`
+	CourseID    string     `json:"course_id"`
+	EnrolledAt  time.Time  `json:"enrolled_at"`
+	ActiveAt    time.Time  `json:"active_at"` // 最近一次报名或学习的时间，/next 学习最近的课程
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// CourseImport 一门课程的完整内容
+type CourseImport struct {
+	Course  *Course
+	Modules []*CourseModule
+	Lessons []*Lesson
+}
+
+// CourseImportStats 导入一门课程的结果
+type CourseImportStats struct {
+	Added     int `json:"added"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Removed   int `json:"removed"` // 文件中已删除的课，同时删除它们的学习记录
+}
+
+const lessonColumns = `id, course_id, module_id, slug, title, position, minutes, body, source, updated_at`
+
+// ImportCourse 在一个事务中导入一门课程：更新课程和模块，按 slug 新增或更新课，删除文件中已不存在的课
+func (s *Storage) ImportCourse(ctx context.Context, imp *CourseImport) (stats CourseImportStats, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("import_course", start, err) }()
+
+	now := time.Now().UTC()
+	c := imp.Course
+	c.UpdatedAt = now
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return stats, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO courses (id, title, description, intro, position, source, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			title = excluded.title, description = excluded.description, intro = excluded.intro,
+			position = excluded.position, source = excluded.source, updated_at = excluded.updated_at
+	`, c.ID, c.Title, c.Description, c.Intro, c.Position, c.Source, now); err != nil {
+		return stats, fmt.Errorf("保存课程失败: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM course_modules WHERE course_id = ?`, c.ID); err != nil {
+		return stats, fmt.Errorf("删除课程模块失败: %w", err)
+	}
+	for _, m := range imp.Modules {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO course_modules (course_id, id, title, position) VALUES (?, ?, ?, ?)`,
+			c.ID, m.ID, m.Title, m.Position,
+		); err != nil {
+			return stats, fmt.Errorf("保存课程模块失败: %w", err)
+		}
+	}
+
+	existing, err := queryLessons(ctx, tx, `WHERE course_id = ?`, c.ID)
+	if err != nil {
+		return stats, err
+	}
+	bySlug := make(map[string]*Lesson, len(existing))
+	for _, l := range existing {
+		bySlug[l.Slug] = l
+	}
+
+	for _, l := range imp.Lessons {
+		l.CourseID = c.ID
+		old, ok := bySlug[l.Slug]
+		delete(bySlug, l.Slug)
+		switch {
+		case !ok:
+			stats.Added++
+		case old.ModuleID == l.ModuleID && old.Title == l.Title && old.Position == l.Position &&
+			old.Minutes == l.Minutes && old.Body == l.Body && old.Source == l.Source:
+			l.ID, l.UpdatedAt = old.ID, old.UpdatedAt
+			stats.Unchanged++
+			continue
+		default:
+			stats.Updated++
+		}
+
+		l.UpdatedAt = now
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO lessons (course_id, module_id, slug, title, position, minutes, body, source, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(course_id, slug) DO UPDATE SET
+				module_id = excluded.module_id, title = excluded.title, position = excluded.position,
+				minutes = excluded.minutes, body = excluded.body, source = excluded.source, updated_at = excluded.updated_at
+			RETURNING id
+		`, l.CourseID, l.ModuleID, l.Slug, l.Title, l.Position, l.Minutes, l.Body, l.Source, now).Scan(&l.ID)
+		if err != nil {
+			return stats, fmt.Errorf("保存课程 %s 的课 %s 失败: %w", c.ID, l.Slug, err)
+		}
+	}
+
+	for _, l := range bySlug {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM lesson_views WHERE lesson_id = ?`, l.ID); err != nil {
+			return stats, fmt.Errorf("删除学习记录失败: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM lessons WHERE id = ?`, l.ID); err != nil {
+			return stats, fmt.Errorf("删除课程 %s 的课 %s 失败: %w", c.ID, l.Slug, err)
+		}
+		stats.Removed++
+	}
+
+	return stats, tx.Commit()
+}
+
+// ListCourses 获取所有课程及其课时数
+func (s *Storage) ListCourses(ctx context.Context) (courses []*Course, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("list_courses", start, err) }()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT c.id, c.title, c.description, c.intro, c.position, c.source, c.updated_at,
+			(SELECT COUNT(*) FROM lessons l WHERE l.course_id = c.id)
+		FROM courses c ORDER BY c.position, c.id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("查询课程失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		c, err := scanCourse(rows)
+		if err != nil {
+			return nil, fmt.Errorf("扫描课程失败: %w", err)
+		}
+		courses = append(courses, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历课程失败: %w", err)
+	}
+	return courses, nil
+}
+
+// GetCourse 根据 ID 获取课程
+func (s *Storage) GetCourse(ctx context.Context, id string) (c *Course, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("get_course", start, err) }()
+
+	row := s.db.QueryRowContext(ctx, `
+		SELECT c.id, c.title, c.description, c.intro, c.position, c.source, c.updated_at,
+			(SELECT COUNT(*) FROM lessons l WHERE l.course_id = c.id)
+		FROM courses c WHERE c.id = ?
+	`, id)
+	c, err = scanCourse(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询课程失败: %w", err)
+	}
+	return c, nil
+}
+
+// ListCourseModules 获取课程的模块（按顺序）
+func (s *Storage) ListCourseModules(ctx context.Context, courseID string) (modules []*CourseModule, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("list_course_modules", start, err) }()
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT course_id, id, title, position FROM course_modules WHERE course_id = ? ORDER BY position, id`, courseID)
+	if err != nil {
+		return nil, fmt.Errorf("查询课程模块失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		m := &CourseModule{}
+		if err := rows.Scan(&m.CourseID, &m.ID, &m.Title, &m.Position); err != nil {
+			return nil, fmt.Errorf("扫描课程模块失败: %w", err)
+		}
+		modules = append(modules, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历课程模块失败: %w", err)
+	}
+	return modules, nil
+}
+
+// ListLessons 获取课程的所有课（按顺序）
+func (s *Storage) ListLessons(ctx context.Context, courseID string) (lessons []*Lesson, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("list_lessons", start, err) }()
+
+	return queryLessons(ctx, s.db, `WHERE course_id = ? ORDER BY position`, courseID)
+}
+
+// GetLesson 根据 ID 获取一节课
+func (s *Storage) GetLesson(ctx context.Context, id int64) (l *Lesson, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("get_lesson", start, err) }()
+
+	lessons, err := queryLessons(ctx, s.db, `WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(lessons) == 0 {
+		return nil, ErrNotFound
+	}
+	return lessons[0], nil
+}
+
+// Enroll 报名课程并设为最近学习的课程，created 为 false 表示之前已经报名
+func (s *Storage) Enroll(ctx context.Context, scope Scope, userID, courseID string, at time.Time) (created bool, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("enroll", start, err) }()
+
+	at = at.UTC()
+	result, err := s.db.ExecContext(ctx, `
+		INSERT OR IGNORE INTO course_enrollments (app_id, tenant_key, user_id, course_id, enrolled_at, active_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, scope.AppID, scope.TenantKey, userID, courseID, at, at)
+	if err != nil {
+		return false, fmt.Errorf("报名课程失败: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil || n > 0 {
+		return n > 0, err
+	}
+	return false, s.TouchEnrollment(ctx, scope, userID, courseID, at)
+}
+
+// TouchEnrollment 更新报名课程的最近学习时间，未报名时返回 ErrNotFound
+func (s *Storage) TouchEnrollment(ctx context.Context, scope Scope, userID, courseID string, at time.Time) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("touch_enrollment", start, err) }()
+
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE course_enrollments SET active_at = ?
+		WHERE app_id = ? AND tenant_key = ? AND user_id = ? AND course_id = ?
+	`, at.UTC(), scope.AppID, scope.TenantKey, userID, courseID)
+	if err != nil {
+		return fmt.Errorf("更新报名记录失败: %w", err)
+	}
+	return checkAffected(result)
+}
+
+// CompleteEnrollment 标记课程已学完（已标记时保留最早的完成时间）
+func (s *Storage) CompleteEnrollment(ctx context.Context, scope Scope, userID, courseID string, at time.Time) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("complete_enrollment", start, err) }()
+
+	_, err = s.db.ExecContext(ctx, `
+		UPDATE course_enrollments SET completed_at = COALESCE(completed_at, ?)
+		WHERE app_id = ? AND tenant_key = ? AND user_id = ? AND course_id = ?
+	`, at.UTC(), scope.AppID, scope.TenantKey, userID, courseID)
+	if err != nil {
+		return fmt.Errorf("更新报名记录失败: %w", err)
+	}
+	return nil
+}
+
+// ListEnrollments 获取用户报名的课程，最近学习的排在最前
+func (s *Storage) ListEnrollments(ctx context.Context, scope Scope, userID string) (enrollments []*Enrollment, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("list_enrollments", start, err) }()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT app_id, tenant_key, user_id, course_id, enrolled_at, active_at, completed_at
+		FROM course_enrollments WHERE app_id = ? AND tenant_key = ? AND user_id = ?
+		ORDER BY active_at DESC
+	`, scope.AppID, scope.TenantKey, userID)
+	if err != nil {
+		return nil, fmt.Errorf("查询报名记录失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e := &Enrollment{}
+		var completedAt sql.NullTime
+		if err := rows.Scan(&e.AppID, &e.TenantKey, &e.UserID, &e.CourseID, &e.EnrolledAt, &e.ActiveAt, &completedAt); err != nil {
+			return nil, fmt.Errorf("扫描报名记录失败: %w", err)
+		}
+		if completedAt.Valid {
+			e.CompletedAt = &completedAt.Time
+		}
+		enrollments = append(enrollments, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历报名记录失败: %w", err)
+	}
+	return enrollments, nil
+}
+
+// RecordLessonView 记录用户学习了一节课，first 为 false 表示之前已经学过
+func (s *Storage) RecordLessonView(ctx context.Context, scope Scope, userID string, lessonID int64, at time.Time) (first bool, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("record_lesson_view", start, err) }()
+
+	result, err := s.db.ExecContext(ctx, `
+		INSERT OR IGNORE INTO lesson_views (app_id, tenant_key, user_id, lesson_id, viewed_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, scope.AppID, scope.TenantKey, userID, lessonID, at.UTC())
+	if err != nil {
+		return false, fmt.Errorf("保存学习记录失败: %w", err)
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// ListLessonViews 获取用户在一门课程中学过的课（lesson_id -> 首次学习时间）
+func (s *Storage) ListLessonViews(ctx context.Context, scope Scope, userID, courseID string) (views map[int64]time.Time, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("list_lesson_views", start, err) }()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT v.lesson_id, v.viewed_at FROM lesson_views v JOIN lessons l ON l.id = v.lesson_id
+		WHERE v.app_id = ? AND v.tenant_key = ? AND v.user_id = ? AND l.course_id = ?
+	`, scope.AppID, scope.TenantKey, userID, courseID)
+	if err != nil {
+		return nil, fmt.Errorf("查询学习记录失败: %w", err)
+	}
+	defer rows.Close()
+
+	views = make(map[int64]time.Time)
+	for rows.Next() {
+		var id int64
+		var at time.Time
+		if err := rows.Scan(&id, &at); err != nil {
+			return nil, fmt.Errorf("扫描学习记录失败: %w", err)
+		}
+		views[id] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历学习记录失败: %w", err)
+	}
+	return views, nil
+}
+
+// queryer 数据库或事务
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// queryLessons 按条件查询课
+func queryLessons(ctx context.Context, q queryer, where string, args ...interface{}) ([]*Lesson, error) {
+	rows, err := q.QueryContext(ctx, `SELECT `+lessonColumns+` FROM lessons `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询课失败: %w", err)
+	}
+	defer rows.Close()
+
+	var lessons []*Lesson
+	for rows.Next() {
+		l := &Lesson{}
+		if err := rows.Scan(&l.ID, &l.CourseID, &l.ModuleID, &l.Slug, &l.Title, &l.Position, &l.Minutes,
+			&l.Body, &l.Source, &l.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("扫描课失败: %w", err)
+		}
+		lessons = append(lessons, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历课失败: %w", err)
+	}
+	return lessons, nil
+}
+
+// scanCourse 扫描一行课程（最后一列为课时数）
+func scanCourse(row rowScanner) (*Course, error) {
+	c := &Course{}
+	if err := row.Scan(&c.ID, &c.Title, &c.Description, &c.Intro, &c.Position, &c.Source, &c.UpdatedAt, &c.Lessons); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
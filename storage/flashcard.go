@@ -0,0 +1,542 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"fin_bot/metrics"
+)
+
+// FlashcardDeck 记忆卡片组（由 CSV 或 Anki 导出的 TSV 文件导入，所有应用共用）
+type FlashcardDeck struct {
+	ID          string    `json:"id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description,omitempty"`
+	Source      string    `json:"source,omitempty"` // 导入时的文件
+	UpdatedAt   time.Time `json:"updated_at"`
+	Cards       int       `json:"cards"` // 卡片数，查询时统计
+}
+
+// FlashcardCard 一张记忆卡片
+// ID 在重新导入时保持不变（按卡片组和 Key 匹配），复习状态中保存的是 ID
+type FlashcardCard struct {
+	ID        int64     `json:"id"`
+	DeckID    string    `json:"deck_id"`
+	Key       string    `json:"key"` // 文件中的 id 或 Anki 的 guid，没有时为正面内容
+	Position  int       `json:"position"`
+	Front     string    `json:"front"`
+	Back      string    `json:"back"`
+	Tags      string    `json:"tags,omitempty"` // 空格分隔
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// FlashcardDeckImport 一个卡片组的完整内容
+type FlashcardDeckImport struct {
+	Deck  *FlashcardDeck
+	Cards []*FlashcardCard
+}
+
+// FlashcardImportStats 导入一个卡片组的结果
+type FlashcardImportStats struct {
+	Added     int `json:"added"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Removed   int `json:"removed"` // 文件中已删除的卡片，用户的复习状态一并删除，复习记录保留
+}
+
+// FlashcardState 用户一张卡片的复习状态（间隔重复算法的参数），没有复习过的卡片没有状态
+type FlashcardState struct {
+	AppID           string    `json:"app_id"`
+	TenantKey       string    `json:"tenant_key"`
+	UserID          string    `json:"user_id"`
+	CardID          int64     `json:"card_id"`
+	Repetitions     int       `json:"repetitions"`   // 连续记住的次数，忘记时清零
+	IntervalDays    int       `json:"interval_days"` // 当前复习间隔（天），忘记后为 0
+	Ease            float64   `json:"ease"`          // 难度系数
+	DueAt           time.Time `json:"due_at"`        // 下次复习时间
+	Reviews         int       `json:"reviews"`       // 复习次数，也用于识别重复点击
+	Lapses          int       `json:"lapses"`        // 忘记的次数
+	FirstReviewedAt time.Time `json:"first_reviewed_at"`
+	LastReviewedAt  time.Time `json:"last_reviewed_at"`
+}
+
+// FlashcardReview 一次复习记录
+type FlashcardReview struct {
+	ID           int64     `json:"id"`
+	AppID        string    `json:"app_id"`
+	TenantKey    string    `json:"tenant_key"`
+	UserID       string    `json:"user_id"`
+	CardID       int64     `json:"card_id"`
+	Grade        string    `json:"grade"` // again、hard、good 或 easy
+	IntervalDays int       `json:"interval_days"`
+	Ease         float64   `json:"ease"`
+	ReviewedAt   time.Time `json:"reviewed_at"`
+}
+
+// FlashcardCounts 用户的复习统计
+type FlashcardCounts struct {
+	Due          int        `json:"due"`           // 已到期的卡片数
+	New          int        `json:"new"`           // 没有复习过的卡片数
+	LearnedToday int        `json:"learned_today"` // 今天第一次复习的卡片数（所有卡片组）
+	NextDueAt    *time.Time `json:"next_due_at,omitempty"`
+}
+
+// FlashcardLearner 复习过卡片的用户和每日提醒设置
+type FlashcardLearner struct {
+	AppID        string     `json:"app_id"`
+	TenantKey    string     `json:"tenant_key"`
+	UserID       string     `json:"user_id"`
+	PushEnabled  bool       `json:"push_enabled"`
+	LastPushedAt *time.Time `json:"last_pushed_at,omitempty"`
+}
+
+const (
+	// flashcardCardPrefixed 与复习状态联合查询时需要带表名
+	flashcardCardPrefixed = `flashcard_cards.id, flashcard_cards.deck_id, flashcard_cards.key, flashcard_cards.position,
+		flashcard_cards.front, flashcard_cards.back, flashcard_cards.tags, flashcard_cards.updated_at`
+	flashcardStateColumns = `app_id, tenant_key, user_id, card_id, repetitions, interval_days, ease, due_at, reviews, lapses, first_reviewed_at, last_reviewed_at`
+)
+
+// flashcardTime 复习相关的时间统一按 UTC 保存到秒，保证按字符串比较的结果与时间顺序一致
+func flashcardTime(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Second)
+}
+
+// ImportFlashcardDeck 在一个事务中导入一个卡片组：更新卡片组信息，按 Key 新增或更新卡片，
+// 删除文件中已不存在的卡片及其复习状态
+func (s *Storage) ImportFlashcardDeck(ctx context.Context, imp *FlashcardDeckImport) (stats FlashcardImportStats, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("import_flashcard_deck", start, err) }()
+
+	now := time.Now().UTC()
+	d := imp.Deck
+	d.UpdatedAt = now
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return stats, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO flashcard_decks (id, title, description, source, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			title = excluded.title, description = excluded.description,
+			source = excluded.source, updated_at = excluded.updated_at
+	`, d.ID, d.Title, d.Description, d.Source, now); err != nil {
+		return stats, fmt.Errorf("保存卡片组失败: %w", err)
+	}
+
+	existing, err := queryFlashcardCards(ctx, tx, `WHERE deck_id = ?`, d.ID)
+	if err != nil {
+		return stats, err
+	}
+	byKey := make(map[string]*FlashcardCard, len(existing))
+	for _, c := range existing {
+		byKey[c.Key] = c
+	}
+
+	for _, c := range imp.Cards {
+		c.DeckID = d.ID
+		old, ok := byKey[c.Key]
+		delete(byKey, c.Key)
+		switch {
+		case !ok:
+			stats.Added++
+		case old.Position == c.Position && old.Front == c.Front && old.Back == c.Back && old.Tags == c.Tags:
+			c.ID, c.UpdatedAt = old.ID, old.UpdatedAt
+			stats.Unchanged++
+			continue
+		default:
+			stats.Updated++
+		}
+
+		c.UpdatedAt = now
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO flashcard_cards (deck_id, key, position, front, back, tags, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(deck_id, key) DO UPDATE SET
+				position = excluded.position, front = excluded.front, back = excluded.back,
+				tags = excluded.tags, updated_at = excluded.updated_at
+			RETURNING id
+		`, c.DeckID, c.Key, c.Position, c.Front, c.Back, c.Tags, now).Scan(&c.ID)
+		if err != nil {
+			return stats, fmt.Errorf("保存卡片组 %s 的卡片 %q 失败: %w", d.ID, c.Key, err)
+		}
+	}
+
+	for _, c := range byKey {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM flashcard_cards WHERE id = ?`, c.ID); err != nil {
+			return stats, fmt.Errorf("删除卡片组 %s 的卡片 %q 失败: %w", d.ID, c.Key, err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM flashcard_states WHERE card_id = ?`, c.ID); err != nil {
+			return stats, fmt.Errorf("删除卡片 %q 的复习状态失败: %w", c.Key, err)
+		}
+		stats.Removed++
+	}
+
+	return stats, tx.Commit()
+}
+
+// ListFlashcardDecks 获取所有卡片组及其卡片数
+func (s *Storage) ListFlashcardDecks(ctx context.Context) (decks []*FlashcardDeck, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("list_flashcard_decks", start, err) }()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT d.id, d.title, d.description, d.source, d.updated_at,
+			(SELECT COUNT(*) FROM flashcard_cards c WHERE c.deck_id = d.id)
+		FROM flashcard_decks d ORDER BY d.id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("查询卡片组失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		d := &FlashcardDeck{}
+		if err := rows.Scan(&d.ID, &d.Title, &d.Description, &d.Source, &d.UpdatedAt, &d.Cards); err != nil {
+			return nil, fmt.Errorf("扫描卡片组失败: %w", err)
+		}
+		decks = append(decks, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历卡片组失败: %w", err)
+	}
+	return decks, nil
+}
+
+// GetFlashcardCard 根据 ID 获取卡片
+func (s *Storage) GetFlashcardCard(ctx context.Context, id int64) (c *FlashcardCard, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("get_flashcard_card", start, err) }()
+
+	cards, err := queryFlashcardCards(ctx, s.db, `WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(cards) == 0 {
+		return nil, ErrNotFound
+	}
+	return cards[0], nil
+}
+
+// GetFlashcardState 获取用户一张卡片的复习状态，没有复习过时返回 ErrNotFound
+func (s *Storage) GetFlashcardState(ctx context.Context, scope Scope, userID string, cardID int64) (st *FlashcardState, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("get_flashcard_state", start, err) }()
+
+	return getFlashcardState(ctx, s.db, scope, userID, cardID)
+}
+
+// NextDueFlashcard 获取用户最早到期的卡片，deckID 为空时在所有卡片组中查找；没有到期的卡片时返回 ErrNotFound
+func (s *Storage) NextDueFlashcard(ctx context.Context, scope Scope, userID, deckID string, now time.Time) (c *FlashcardCard, st *FlashcardState, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("next_due_flashcard", start, err) }()
+
+	cards, err := s.queryDueFlashcards(ctx, scope, userID, deckID, now, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(cards) == 0 {
+		return nil, nil, ErrNotFound
+	}
+	st, err = getFlashcardState(ctx, s.db, scope, userID, cards[0].ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cards[0], st, nil
+}
+
+// ListDueFlashcards 获取用户已到期的卡片（最早到期的排在最前），最多 limit 张
+func (s *Storage) ListDueFlashcards(ctx context.Context, scope Scope, userID string, now time.Time, limit int) (cards []*FlashcardCard, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("list_due_flashcards", start, err) }()
+
+	return s.queryDueFlashcards(ctx, scope, userID, "", now, limit)
+}
+
+// queryDueFlashcards 按到期时间查询用户已到期的卡片
+func (s *Storage) queryDueFlashcards(ctx context.Context, scope Scope, userID, deckID string, now time.Time, limit int) ([]*FlashcardCard, error) {
+	where := `JOIN flashcard_states s ON s.card_id = flashcard_cards.id
+		WHERE s.app_id = ? AND s.tenant_key = ? AND s.user_id = ? AND s.due_at <= ?`
+	args := []interface{}{scope.AppID, scope.TenantKey, userID, flashcardTime(now)}
+	if deckID != "" {
+		where += ` AND flashcard_cards.deck_id = ?`
+		args = append(args, deckID)
+	}
+	return queryFlashcardCards(ctx, s.db, where+` ORDER BY s.due_at, flashcard_cards.deck_id, flashcard_cards.position LIMIT ?`,
+		append(args, limit)...)
+}
+
+// NextNewFlashcard 获取用户没有复习过的第一张卡片（按卡片组和文件中的顺序），没有时返回 ErrNotFound
+func (s *Storage) NextNewFlashcard(ctx context.Context, scope Scope, userID, deckID string) (c *FlashcardCard, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("next_new_flashcard", start, err) }()
+
+	where := `WHERE NOT EXISTS (
+			SELECT 1 FROM flashcard_states s
+			WHERE s.app_id = ? AND s.tenant_key = ? AND s.user_id = ? AND s.card_id = flashcard_cards.id
+		)`
+	args := []interface{}{scope.AppID, scope.TenantKey, userID}
+	if deckID != "" {
+		where += ` AND deck_id = ?`
+		args = append(args, deckID)
+	}
+	cards, err := queryFlashcardCards(ctx, s.db, where+` ORDER BY deck_id, position LIMIT 1`, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(cards) == 0 {
+		return nil, ErrNotFound
+	}
+	return cards[0], nil
+}
+
+// CountFlashcards 统计用户到期和没有复习过的卡片，deckID 为空时统计所有卡片组；
+// dayStart 为用户所在时区的今天零点，用于统计今天新学的卡片数
+func (s *Storage) CountFlashcards(ctx context.Context, scope Scope, userID, deckID string, now, dayStart time.Time) (counts *FlashcardCounts, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("count_flashcards", start, err) }()
+
+	deckFilter := ``
+	if deckID != "" {
+		deckFilter = ` AND c.deck_id = ?`
+	}
+	args := func(extra ...interface{}) []interface{} {
+		a := append([]interface{}{scope.AppID, scope.TenantKey, userID}, extra...)
+		if deckID != "" {
+			a = append(a, deckID)
+		}
+		return a
+	}
+
+	counts = &FlashcardCounts{}
+	err = s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM flashcard_states s JOIN flashcard_cards c ON c.id = s.card_id
+		WHERE s.app_id = ? AND s.tenant_key = ? AND s.user_id = ? AND s.due_at <= ?`+deckFilter,
+		args(flashcardTime(now))...).Scan(&counts.Due)
+	if err != nil {
+		return nil, fmt.Errorf("统计到期卡片失败: %w", err)
+	}
+	var next time.Time
+	err = s.db.QueryRowContext(ctx, `
+		SELECT s.due_at FROM flashcard_states s JOIN flashcard_cards c ON c.id = s.card_id
+		WHERE s.app_id = ? AND s.tenant_key = ? AND s.user_id = ? AND s.due_at > ?`+deckFilter+`
+		ORDER BY s.due_at LIMIT 1`, args(flashcardTime(now))...).Scan(&next)
+	switch {
+	case err == nil:
+		counts.NextDueAt = &next
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, fmt.Errorf("查询下次复习时间失败: %w", err)
+	}
+
+	err = s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM flashcard_cards c
+		WHERE NOT EXISTS (
+			SELECT 1 FROM flashcard_states s
+			WHERE s.app_id = ? AND s.tenant_key = ? AND s.user_id = ? AND s.card_id = c.id
+		)`+deckFilter, args()...).Scan(&counts.New)
+	if err != nil {
+		return nil, fmt.Errorf("统计新卡片失败: %w", err)
+	}
+
+	err = s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM flashcard_states
+		WHERE app_id = ? AND tenant_key = ? AND user_id = ? AND first_reviewed_at >= ?
+	`, scope.AppID, scope.TenantKey, userID, flashcardTime(dayStart)).Scan(&counts.LearnedToday)
+	if err != nil {
+		return nil, fmt.Errorf("统计今天新学的卡片失败: %w", err)
+	}
+	return counts, nil
+}
+
+// SaveFlashcardReview 保存一次复习：更新（第一次复习时新建）复习状态并追加复习记录
+// prevReviews 为评分时看到的复习次数，与当前状态不一致时（例如重复点击评分按钮）不保存，recorded 为 false
+func (s *Storage) SaveFlashcardReview(ctx context.Context, st *FlashcardState, prevReviews int, grade string) (recorded bool, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("save_flashcard_review", start, err) }()
+
+	s.cardMu.Lock()
+	defer s.cardMu.Unlock()
+
+	st.DueAt = flashcardTime(st.DueAt)
+	st.FirstReviewedAt = flashcardTime(st.FirstReviewedAt)
+	st.LastReviewedAt = flashcardTime(st.LastReviewedAt)
+	scope := Scope{AppID: st.AppID, TenantKey: st.TenantKey}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	current, err := getFlashcardState(ctx, tx, scope, st.UserID, st.CardID)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		if prevReviews != 0 {
+			return false, nil
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO flashcard_states (`+flashcardStateColumns+`)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, st.AppID, st.TenantKey, st.UserID, st.CardID, st.Repetitions, st.IntervalDays, st.Ease, st.DueAt,
+			st.Reviews, st.Lapses, st.FirstReviewedAt, st.LastReviewedAt)
+	case err != nil:
+		return false, err
+	case current.Reviews != prevReviews:
+		return false, nil
+	default:
+		_, err = tx.ExecContext(ctx, `
+			UPDATE flashcard_states SET repetitions = ?, interval_days = ?, ease = ?, due_at = ?, reviews = ?, lapses = ?, last_reviewed_at = ?
+			WHERE app_id = ? AND tenant_key = ? AND user_id = ? AND card_id = ?
+		`, st.Repetitions, st.IntervalDays, st.Ease, st.DueAt, st.Reviews, st.Lapses, st.LastReviewedAt,
+			st.AppID, st.TenantKey, st.UserID, st.CardID)
+	}
+	if err != nil {
+		return false, fmt.Errorf("保存复习状态失败: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO flashcard_reviews (app_id, tenant_key, user_id, card_id, grade, interval_days, ease, reviewed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, st.AppID, st.TenantKey, st.UserID, st.CardID, grade, st.IntervalDays, st.Ease, st.LastReviewedAt); err != nil {
+		return false, fmt.Errorf("保存复习记录失败: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO flashcard_learners (app_id, tenant_key, user_id) VALUES (?, ?, ?)
+		ON CONFLICT(app_id, tenant_key, user_id) DO NOTHING
+	`, st.AppID, st.TenantKey, st.UserID); err != nil {
+		return false, fmt.Errorf("保存复习用户失败: %w", err)
+	}
+	return true, tx.Commit()
+}
+
+// GetFlashcardLearner 获取用户的每日提醒设置，没有复习过卡片时返回 ErrNotFound
+func (s *Storage) GetFlashcardLearner(ctx context.Context, scope Scope, userID string) (l *FlashcardLearner, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("get_flashcard_learner", start, err) }()
+
+	learners, err := s.queryFlashcardLearners(ctx, `WHERE app_id = ? AND tenant_key = ? AND user_id = ?`,
+		scope.AppID, scope.TenantKey, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(learners) == 0 {
+		return nil, ErrNotFound
+	}
+	return learners[0], nil
+}
+
+// ListFlashcardLearners 获取开启了每日提醒的用户（所有应用）
+func (s *Storage) ListFlashcardLearners(ctx context.Context) (learners []*FlashcardLearner, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("list_flashcard_learners", start, err) }()
+
+	return s.queryFlashcardLearners(ctx, `WHERE push_enabled = 1 ORDER BY app_id, tenant_key, user_id`)
+}
+
+// SetFlashcardPush 开启或关闭用户的每日提醒
+func (s *Storage) SetFlashcardPush(ctx context.Context, scope Scope, userID string, enabled bool) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("set_flashcard_push", start, err) }()
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO flashcard_learners (app_id, tenant_key, user_id, push_enabled) VALUES (?, ?, ?, ?)
+		ON CONFLICT(app_id, tenant_key, user_id) DO UPDATE SET push_enabled = excluded.push_enabled
+	`, scope.AppID, scope.TenantKey, userID, enabled)
+	if err != nil {
+		return fmt.Errorf("保存每日提醒设置失败: %w", err)
+	}
+	return nil
+}
+
+// MarkFlashcardPushed 记录每日提醒的发送时间
+func (s *Storage) MarkFlashcardPushed(ctx context.Context, scope Scope, userID string, at time.Time) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("mark_flashcard_pushed", start, err) }()
+
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE flashcard_learners SET last_pushed_at = ? WHERE app_id = ? AND tenant_key = ? AND user_id = ?
+	`, flashcardTime(at), scope.AppID, scope.TenantKey, userID)
+	if err != nil {
+		return fmt.Errorf("更新提醒发送时间失败: %w", err)
+	}
+	return checkAffected(result)
+}
+
+// queryFlashcardLearners 按条件查询复习用户
+func (s *Storage) queryFlashcardLearners(ctx context.Context, where string, args ...interface{}) ([]*FlashcardLearner, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT app_id, tenant_key, user_id, push_enabled, last_pushed_at FROM flashcard_learners `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询复习用户失败: %w", err)
+	}
+	defer rows.Close()
+
+	var learners []*FlashcardLearner
+	for rows.Next() {
+		l := &FlashcardLearner{}
+		var pushedAt sql.NullTime
+		if err := rows.Scan(&l.AppID, &l.TenantKey, &l.UserID, &l.PushEnabled, &pushedAt); err != nil {
+			return nil, fmt.Errorf("扫描复习用户失败: %w", err)
+		}
+		if pushedAt.Valid {
+			l.LastPushedAt = &pushedAt.Time
+		}
+		learners = append(learners, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历复习用户失败: %w", err)
+	}
+	return learners, nil
+}
+
+// getFlashcardState 查询用户一张卡片的复习状态，没有时返回 ErrNotFound
+func getFlashcardState(ctx context.Context, q queryer, scope Scope, userID string, cardID int64) (*FlashcardState, error) {
+	rows, err := q.QueryContext(ctx, `SELECT `+flashcardStateColumns+` FROM flashcard_states
+		WHERE app_id = ? AND tenant_key = ? AND user_id = ? AND card_id = ?`, scope.AppID, scope.TenantKey, userID, cardID)
+	if err != nil {
+		return nil, fmt.Errorf("查询复习状态失败: %w", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("查询复习状态失败: %w", err)
+		}
+		return nil, ErrNotFound
+	}
+	st := &FlashcardState{}
+	if err := rows.Scan(&st.AppID, &st.TenantKey, &st.UserID, &st.CardID, &st.Repetitions, &st.IntervalDays, &st.Ease,
+		&st.DueAt, &st.Reviews, &st.Lapses, &st.FirstReviewedAt, &st.LastReviewedAt); err != nil {
+		return nil, fmt.Errorf("扫描复习状态失败: %w", err)
+	}
+	return st, nil
+}
+
+// queryFlashcardCards 按条件查询卡片
+func queryFlashcardCards(ctx context.Context, q queryer, where string, args ...interface{}) ([]*FlashcardCard, error) {
+	rows, err := q.QueryContext(ctx, `SELECT `+flashcardCardPrefixed+` FROM flashcard_cards `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询卡片失败: %w", err)
+	}
+	defer rows.Close()
+
+	var cards []*FlashcardCard
+	for rows.Next() {
+		c := &FlashcardCard{}
+		if err := rows.Scan(&c.ID, &c.DeckID, &c.Key, &c.Position, &c.Front, &c.Back, &c.Tags, &c.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("扫描卡片失败: %w", err)
+		}
+		cards = append(cards, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历卡片失败: %w", err)
+	}
+	return cards, nil
+}
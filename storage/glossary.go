@@ -0,0 +1,241 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"fin_bot/metrics"
+)
+
+// GlossaryTerm 一个金融术语词条（由 CSV 文件导入，所有应用共用）
+// ID 在重新导入时保持不变（按中文术语匹配）
+type GlossaryTerm struct {
+	ID         int64     `json:"id"`
+	Source     string    `json:"source"`            // 导入时的文件（去掉顺序前缀和扩展名）
+	Term       string    `json:"term"`              // 中文术语，全局唯一
+	English    string    `json:"english,omitempty"` // 英文名称
+	Aliases    string    `json:"aliases,omitempty"` // 缩写和别名，| 分隔
+	Pinyin     string    `json:"pinyin,omitempty"`  // 中文术语的拼音，音节之间用空格分隔
+	Definition string    `json:"definition"`
+	Formula    string    `json:"formula,omitempty"`
+	Example    string    `json:"example,omitempty"`
+	Related    string    `json:"related,omitempty"` // 相关术语，| 分隔
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// GlossaryImportStats 导入术语的结果
+type GlossaryImportStats struct {
+	Added     int `json:"added"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Removed   int `json:"removed"` // 导入的文件中已删除的词条
+}
+
+// GlossarySubscription 订阅每日术语的会话
+type GlossarySubscription struct {
+	AppID      string     `json:"app_id"`
+	TenantKey  string     `json:"tenant_key"`
+	ChatID     string     `json:"chat_id"`
+	CreatedBy  string     `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSentAt *time.Time `json:"last_sent_at,omitempty"`
+}
+
+const glossaryTermColumns = `id, source, term, english, aliases, pinyin, definition, formula, example, related, updated_at`
+
+// ImportGlossary 在一个事务中导入术语：按中文术语新增或更新词条，删除 sources 中的文件里已不存在的词条
+// （其他文件导入的词条保持不变）；词条从一个文件移到另一个文件时按更新处理
+func (s *Storage) ImportGlossary(ctx context.Context, sources []string, terms []*GlossaryTerm) (stats GlossaryImportStats, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("import_glossary", start, err) }()
+
+	now := time.Now().UTC()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return stats, err
+	}
+	defer tx.Rollback()
+
+	existing, err := queryGlossaryTerms(ctx, tx, ``)
+	if err != nil {
+		return stats, err
+	}
+	byTerm := make(map[string]*GlossaryTerm, len(existing))
+	for _, t := range existing {
+		byTerm[t.Term] = t
+	}
+
+	imported := make(map[string]bool, len(terms))
+	for _, t := range terms {
+		imported[t.Term] = true
+		old, ok := byTerm[t.Term]
+		switch {
+		case !ok:
+			stats.Added++
+		case old.Source == t.Source && old.English == t.English && old.Aliases == t.Aliases && old.Pinyin == t.Pinyin &&
+			old.Definition == t.Definition && old.Formula == t.Formula && old.Example == t.Example && old.Related == t.Related:
+			t.ID, t.UpdatedAt = old.ID, old.UpdatedAt
+			stats.Unchanged++
+			continue
+		default:
+			stats.Updated++
+		}
+
+		t.UpdatedAt = now
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO glossary_terms (source, term, english, aliases, pinyin, definition, formula, example, related, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(term) DO UPDATE SET
+				source = excluded.source, english = excluded.english, aliases = excluded.aliases,
+				pinyin = excluded.pinyin, definition = excluded.definition, formula = excluded.formula,
+				example = excluded.example, related = excluded.related, updated_at = excluded.updated_at
+			RETURNING id
+		`, t.Source, t.Term, t.English, t.Aliases, t.Pinyin, t.Definition, t.Formula, t.Example, t.Related, now).Scan(&t.ID)
+		if err != nil {
+			return stats, fmt.Errorf("保存术语 %q 失败: %w", t.Term, err)
+		}
+	}
+
+	replaced := make(map[string]bool, len(sources))
+	for _, src := range sources {
+		replaced[src] = true
+	}
+	for _, t := range existing {
+		if !replaced[t.Source] || imported[t.Term] {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM glossary_terms WHERE id = ?`, t.ID); err != nil {
+			return stats, fmt.Errorf("删除术语 %q 失败: %w", t.Term, err)
+		}
+		stats.Removed++
+	}
+
+	return stats, tx.Commit()
+}
+
+// ListGlossaryTerms 获取所有术语（按导入顺序）
+func (s *Storage) ListGlossaryTerms(ctx context.Context) (terms []*GlossaryTerm, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("list_glossary_terms", start, err) }()
+
+	return queryGlossaryTerms(ctx, s.db, `ORDER BY id`)
+}
+
+// SubscribeGlossary 会话订阅每日术语，已订阅时保持原来的订阅人和发送记录
+func (s *Storage) SubscribeGlossary(ctx context.Context, sub *GlossarySubscription) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("subscribe_glossary", start, err) }()
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO glossary_subscriptions (app_id, tenant_key, chat_id, created_by, created_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(app_id, tenant_key, chat_id) DO NOTHING
+	`, sub.AppID, sub.TenantKey, sub.ChatID, sub.CreatedBy, sub.CreatedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("保存每日术语订阅失败: %w", err)
+	}
+	return nil
+}
+
+// UnsubscribeGlossary 取消会话的每日术语订阅，没有订阅时返回 ErrNotFound
+func (s *Storage) UnsubscribeGlossary(ctx context.Context, scope Scope, chatID string) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("unsubscribe_glossary", start, err) }()
+
+	result, err := s.db.ExecContext(ctx, `
+		DELETE FROM glossary_subscriptions WHERE app_id = ? AND tenant_key = ? AND chat_id = ?
+	`, scope.AppID, scope.TenantKey, chatID)
+	if err != nil {
+		return fmt.Errorf("取消每日术语订阅失败: %w", err)
+	}
+	return checkAffected(result)
+}
+
+// GetGlossarySubscription 获取会话的每日术语订阅，没有订阅时返回 ErrNotFound
+func (s *Storage) GetGlossarySubscription(ctx context.Context, scope Scope, chatID string) (sub *GlossarySubscription, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("get_glossary_subscription", start, err) }()
+
+	subs, err := s.queryGlossarySubscriptions(ctx, `WHERE app_id = ? AND tenant_key = ? AND chat_id = ?`,
+		scope.AppID, scope.TenantKey, chatID)
+	if err != nil {
+		return nil, err
+	}
+	if len(subs) == 0 {
+		return nil, ErrNotFound
+	}
+	return subs[0], nil
+}
+
+// ListGlossarySubscriptions 获取所有订阅了每日术语的会话（所有应用）
+func (s *Storage) ListGlossarySubscriptions(ctx context.Context) (subs []*GlossarySubscription, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("list_glossary_subscriptions", start, err) }()
+
+	return s.queryGlossarySubscriptions(ctx, `ORDER BY app_id, tenant_key, created_at`)
+}
+
+// MarkGlossarySent 记录每日术语的发送时间
+func (s *Storage) MarkGlossarySent(ctx context.Context, scope Scope, chatID string, at time.Time) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("mark_glossary_sent", start, err) }()
+
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE glossary_subscriptions SET last_sent_at = ? WHERE app_id = ? AND tenant_key = ? AND chat_id = ?
+	`, at.UTC().Truncate(time.Second), scope.AppID, scope.TenantKey, chatID)
+	if err != nil {
+		return fmt.Errorf("更新每日术语发送时间失败: %w", err)
+	}
+	return checkAffected(result)
+}
+
+// queryGlossarySubscriptions 按条件查询每日术语订阅
+func (s *Storage) queryGlossarySubscriptions(ctx context.Context, where string, args ...interface{}) ([]*GlossarySubscription, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT app_id, tenant_key, chat_id, created_by, created_at, last_sent_at FROM glossary_subscriptions `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询每日术语订阅失败: %w", err)
+	}
+	defer rows.Close()
+
+	var subs []*GlossarySubscription
+	for rows.Next() {
+		sub := &GlossarySubscription{}
+		var sentAt sql.NullTime
+		if err := rows.Scan(&sub.AppID, &sub.TenantKey, &sub.ChatID, &sub.CreatedBy, &sub.CreatedAt, &sentAt); err != nil {
+			return nil, fmt.Errorf("扫描每日术语订阅失败: %w", err)
+		}
+		if sentAt.Valid {
+			sub.LastSentAt = &sentAt.Time
+		}
+		subs = append(subs, sub)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历每日术语订阅失败: %w", err)
+	}
+	return subs, nil
+}
+
+// queryGlossaryTerms 按条件查询术语
+func queryGlossaryTerms(ctx context.Context, q queryer, where string, args ...interface{}) ([]*GlossaryTerm, error) {
+	rows, err := q.QueryContext(ctx, `SELECT `+glossaryTermColumns+` FROM glossary_terms `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询术语失败: %w", err)
+	}
+	defer rows.Close()
+
+	var terms []*GlossaryTerm
+	for rows.Next() {
+		t := &GlossaryTerm{}
+		if err := rows.Scan(&t.ID, &t.Source, &t.Term, &t.English, &t.Aliases, &t.Pinyin, &t.Definition,
+			&t.Formula, &t.Example, &t.Related, &t.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("扫描术语失败: %w", err)
+		}
+		terms = append(terms, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历术语失败: %w", err)
+	}
+	return terms, nil
+}
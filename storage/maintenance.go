@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"fin_bot/metrics"
+)
+
+// Vacuum 整理数据库文件，回收删除数据后的空闲空间
+func (s *Storage) Vacuum(ctx context.Context) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("vacuum", start, err) }()
+
+	if _, err = s.db.ExecContext(ctx, `VACUUM`); err != nil {
+		return fmt.Errorf("整理数据库失败: %w", err)
+	}
+	return nil
+}
+
+// BackupTo 使用 VACUUM INTO 生成数据库的一致性快照，备份期间不阻塞写入
+// dest 已存在时返回错误，避免覆盖之前的备份
+func (s *Storage) BackupTo(ctx context.Context, dest string) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("backup", start, err) }()
+
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("备份文件已存在: %s", dest)
+	}
+	if dir := filepath.Dir(dest); dir != "." && dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("创建备份目录失败: %w", err)
+		}
+	}
+
+	if _, err = s.db.ExecContext(ctx, `VACUUM INTO ?`, dest); err != nil {
+		return fmt.Errorf("备份数据库失败: %w", err)
+	}
+	return nil
+}
+
+// IntegrityCheck 执行 SQLite 完整性检查，发现问题时返回错误（最多列出前 10 项）
+func (s *Storage) IntegrityCheck(ctx context.Context) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("integrity_check", start, err) }()
+
+	rows, err := s.db.QueryContext(ctx, `PRAGMA integrity_check(10)`)
+	if err != nil {
+		return fmt.Errorf("完整性检查失败: %w", err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return fmt.Errorf("完整性检查失败: %w", err)
+		}
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("完整性检查失败: %w", err)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("数据库已损坏: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// pruneBatchSize 清理过期消息时每条 DELETE 语句删除的消息数
+const pruneBatchSize = 500
+
+// PruneMessages 按保留策略删除消息，返回删除的条数
+// perChat > 0 时每个会话只保留最新的 perChat 条；maxAge > 0 时删除早于 now-maxAge 的消息
+// 搜索索引由 messages_delete_terms 触发器同步删除
+func (s *Storage) PruneMessages(ctx context.Context, perChat int, maxAge time.Duration, now time.Time) (deleted int64, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("prune_messages", start, err) }()
+
+	if maxAge > 0 {
+		n, err := s.pruneMessagesBefore(ctx, now.Add(-maxAge))
+		deleted += n
+		if err != nil {
+			return deleted, err
+		}
+	}
+
+	if perChat > 0 {
+		res, err := s.db.ExecContext(ctx, `
+			DELETE FROM messages WHERE id IN (
+				SELECT id FROM (
+					SELECT id, ROW_NUMBER() OVER (
+						PARTITION BY app_id, tenant_key, chat_id ORDER BY created_at DESC, id DESC
+					) AS rn
+					FROM messages
+				) WHERE rn > ?
+			)`, perChat)
+		if err != nil {
+			return deleted, fmt.Errorf("清理超出保留条数的消息失败: %w", err)
+		}
+		n, _ := res.RowsAffected()
+		deleted += n
+	}
+	return deleted, nil
+}
+
+// pruneMessagesBefore 删除早于 cutoff 的消息
+// created_at 按写入时的时区以文本保存，SQL 中无法直接比较，这里读出后在 Go 中比较，再分批删除
+func (s *Storage) pruneMessagesBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, created_at FROM messages`)
+	if err != nil {
+		return 0, fmt.Errorf("清理过期消息失败: %w", err)
+	}
+	var ids []interface{}
+	for rows.Next() {
+		var id int64
+		var createdAt time.Time
+		if err := rows.Scan(&id, &createdAt); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("清理过期消息失败: %w", err)
+		}
+		if createdAt.Before(cutoff) {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("清理过期消息失败: %w", err)
+	}
+
+	var deleted int64
+	for len(ids) > 0 {
+		batch := ids
+		if len(batch) > pruneBatchSize {
+			batch = batch[:pruneBatchSize]
+		}
+		ids = ids[len(batch):]
+
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(batch)), ",")
+		res, err := s.db.ExecContext(ctx, `DELETE FROM messages WHERE id IN (`+placeholders+`)`, batch...)
+		if err != nil {
+			return deleted, fmt.Errorf("清理过期消息失败: %w", err)
+		}
+		n, _ := res.RowsAffected()
+		deleted += n
+	}
+	return deleted, nil
+}
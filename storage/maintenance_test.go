@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPruneMessages(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	scope := Scope{AppID: "cli_test", TenantKey: "tenant"}
+
+	tests := []struct {
+		name        string
+		perChat     int
+		maxAge      time.Duration
+		wantDeleted int64
+		wantLeft    map[string]int
+	}{
+		{"不限制", 0, 0, 0, map[string]int{"oc_a": 5, "oc_b": 2}},
+		{"按条数", 3, 0, 2, map[string]int{"oc_a": 3, "oc_b": 2}},
+		{"按时间", 0, 36 * time.Hour, 3, map[string]int{"oc_a": 2, "oc_b": 2}},
+		{"条数和时间", 1, 36 * time.Hour, 5, map[string]int{"oc_a": 1, "oc_b": 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestStorage(t)
+
+			// oc_a 每天一条，最早的在 4 天前；oc_b 两条都在最近一天内，使用非 UTC 时区写入
+			for i := 0; i < 5; i++ {
+				saveTestMessage(t, s, scope, "oc_a", fmt.Sprintf("a%d", i), now.Add(-time.Duration(4-i)*24*time.Hour))
+			}
+			shanghai := time.FixedZone("CST", 8*3600)
+			for i := 0; i < 2; i++ {
+				saveTestMessage(t, s, scope, "oc_b", fmt.Sprintf("b%d", i), now.Add(-time.Duration(i+1)*time.Hour).In(shanghai))
+			}
+
+			deleted, err := s.PruneMessages(ctx, tt.perChat, tt.maxAge, now)
+			if err != nil {
+				t.Fatalf("PruneMessages: %v", err)
+			}
+			if deleted != tt.wantDeleted {
+				t.Errorf("deleted = %d, want %d", deleted, tt.wantDeleted)
+			}
+			for chatID, want := range tt.wantLeft {
+				msgs, err := s.GetRecentMessagesByChatID(ctx, scope, chatID, 100)
+				if err != nil {
+					t.Fatalf("GetRecentMessagesByChatID: %v", err)
+				}
+				if len(msgs) != want {
+					t.Errorf("%s left %d messages, want %d", chatID, len(msgs), want)
+				}
+			}
+		})
+	}
+}
+
+func saveTestMessage(t *testing.T, s *Storage, scope Scope, chatID, messageID string, createdAt time.Time) {
+	t.Helper()
+	err := s.SaveMessage(context.Background(), &Message{
+		AppID:       scope.AppID,
+		TenantKey:   scope.TenantKey,
+		ChatID:      chatID,
+		MessageID:   messageID,
+		SenderID:    "ou_test",
+		SenderType:  "user",
+		Content:     "hello " + messageID,
+		MessageType: "text",
+		CreatedAt:   createdAt,
+	})
+	if err != nil {
+		t.Fatalf("SaveMessage: %v", err)
+	}
+}
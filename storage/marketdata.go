@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"fin_bot/metrics"
+)
+
+// MarketInstrument 本地行情数据中的一个证券（由 CSV 文件导入）
+type MarketInstrument struct {
+	Symbol    string    `json:"symbol"` // 规范化的代码，例如 600519.SH
+	Name      string    `json:"name"`
+	Market    string    `json:"market"`
+	Currency  string    `json:"currency"`
+	Aliases   string    `json:"aliases,omitempty"` // 可以搜索的其他名称，| 分隔
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// MarketBar 本地行情数据中一个证券一个交易日的日线
+type MarketBar struct {
+	Symbol string  `json:"symbol"`
+	Date   string  `json:"date"` // YYYY-MM-DD
+	Open   float64 `json:"open"`
+	High   float64 `json:"high"`
+	Low    float64 `json:"low"`
+	Close  float64 `json:"close"`
+	Volume int64   `json:"volume"`
+}
+
+// MarketImportStats 导入本地行情数据的结果
+type MarketImportStats struct {
+	Instruments int `json:"instruments"`
+	Bars        int `json:"bars"`
+	Removed     int `json:"removed"` // 导入的数据中已不存在的证券（连同日线一起删除）
+}
+
+// ImportMarketData 在一个事务中用导入的数据替换本地行情：新增或更新证券，替换导入证券的全部日线，
+// 删除导入数据中已不存在的证券和它们的日线
+func (s *Storage) ImportMarketData(ctx context.Context, instruments []*MarketInstrument, bars []*MarketBar) (stats MarketImportStats, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("import_market_data", start, err) }()
+
+	now := time.Now().UTC()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return stats, err
+	}
+	defer tx.Rollback()
+
+	existing, err := queryMarketInstruments(ctx, tx, ``)
+	if err != nil {
+		return stats, err
+	}
+	imported := make(map[string]bool, len(instruments))
+	for _, inst := range instruments {
+		imported[inst.Symbol] = true
+		inst.UpdatedAt = now
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO market_instruments (symbol, name, market, currency, aliases, updated_at) VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT(symbol) DO UPDATE SET
+				name = excluded.name, market = excluded.market, currency = excluded.currency,
+				aliases = excluded.aliases, updated_at = excluded.updated_at
+		`, inst.Symbol, inst.Name, inst.Market, inst.Currency, inst.Aliases, now)
+		if err != nil {
+			return stats, fmt.Errorf("保存证券 %s 失败: %w", inst.Symbol, err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM market_bars WHERE symbol = ?`, inst.Symbol); err != nil {
+			return stats, fmt.Errorf("删除证券 %s 的日线失败: %w", inst.Symbol, err)
+		}
+		stats.Instruments++
+	}
+	for _, inst := range existing {
+		if imported[inst.Symbol] {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM market_bars WHERE symbol = ?`, inst.Symbol); err != nil {
+			return stats, fmt.Errorf("删除证券 %s 的日线失败: %w", inst.Symbol, err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM market_instruments WHERE symbol = ?`, inst.Symbol); err != nil {
+			return stats, fmt.Errorf("删除证券 %s 失败: %w", inst.Symbol, err)
+		}
+		stats.Removed++
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO market_bars (symbol, date, open, high, low, close, volume) VALUES (?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return stats, err
+	}
+	defer stmt.Close()
+	for _, b := range bars {
+		if _, err := stmt.ExecContext(ctx, b.Symbol, b.Date, b.Open, b.High, b.Low, b.Close, b.Volume); err != nil {
+			return stats, fmt.Errorf("保存证券 %s %s 的日线失败: %w", b.Symbol, b.Date, err)
+		}
+		stats.Bars++
+	}
+
+	return stats, tx.Commit()
+}
+
+// GetMarketInstrument 获取证券，不存在时返回 ErrNotFound
+func (s *Storage) GetMarketInstrument(ctx context.Context, symbol string) (inst *MarketInstrument, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("get_market_instrument", start, err) }()
+
+	instruments, err := queryMarketInstruments(ctx, s.db, `WHERE symbol = ?`, symbol)
+	if err != nil {
+		return nil, err
+	}
+	if len(instruments) == 0 {
+		return nil, ErrNotFound
+	}
+	return instruments[0], nil
+}
+
+// ListMarketInstruments 获取所有证券（按代码排列）
+func (s *Storage) ListMarketInstruments(ctx context.Context) (instruments []*MarketInstrument, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("list_market_instruments", start, err) }()
+
+	return queryMarketInstruments(ctx, s.db, `ORDER BY symbol`)
+}
+
+// ListMarketBars 获取证券在 [from, to] 日期范围内的日线（按日期升序），日期为 YYYY-MM-DD
+func (s *Storage) ListMarketBars(ctx context.Context, symbol, from, to string) (bars []*MarketBar, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("list_market_bars", start, err) }()
+
+	return s.queryMarketBars(ctx, `WHERE symbol = ? AND date >= ? AND date <= ? ORDER BY date`, symbol, from, to)
+}
+
+// LatestMarketBars 获取证券在 to（含）之前最近的 n 个日线（按日期降序），日期为 YYYY-MM-DD
+func (s *Storage) LatestMarketBars(ctx context.Context, symbol, to string, n int) (bars []*MarketBar, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("latest_market_bars", start, err) }()
+
+	return s.queryMarketBars(ctx, `WHERE symbol = ? AND date <= ? ORDER BY date DESC LIMIT ?`, symbol, to, n)
+}
+
+// queryMarketBars 按条件查询日线
+func (s *Storage) queryMarketBars(ctx context.Context, where string, args ...interface{}) ([]*MarketBar, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT symbol, date, open, high, low, close, volume FROM market_bars `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询日线失败: %w", err)
+	}
+	defer rows.Close()
+
+	var bars []*MarketBar
+	for rows.Next() {
+		b := &MarketBar{}
+		if err := rows.Scan(&b.Symbol, &b.Date, &b.Open, &b.High, &b.Low, &b.Close, &b.Volume); err != nil {
+			return nil, fmt.Errorf("扫描日线失败: %w", err)
+		}
+		bars = append(bars, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历日线失败: %w", err)
+	}
+	return bars, nil
+}
+
+// queryMarketInstruments 按条件查询证券
+func queryMarketInstruments(ctx context.Context, q queryer, where string, args ...interface{}) ([]*MarketInstrument, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT symbol, name, market, currency, aliases, updated_at FROM market_instruments `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询证券失败: %w", err)
+	}
+	defer rows.Close()
+
+	var instruments []*MarketInstrument
+	for rows.Next() {
+		inst := &MarketInstrument{}
+		if err := rows.Scan(&inst.Symbol, &inst.Name, &inst.Market, &inst.Currency, &inst.Aliases, &inst.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("扫描证券失败: %w", err)
+		}
+		instruments = append(instruments, inst)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历证券失败: %w", err)
+	}
+	return instruments, nil
+}
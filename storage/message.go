@@ -6,28 +6,35 @@ import (
 	"fmt"
 	"log"
 	"time"
+
+	"fin_bot/metrics"
 )
 
 // Message 消息结构
 type Message struct {
-	ID          int64
-	ChatID      string
-	MessageID   string
-	SenderID    string
-	SenderType  string
-	Content     string
-	MessageType string
-	CreatedAt   time.Time
+	ID          int64     `json:"id"`
+	AppID       string    `json:"app_id"`
+	TenantKey   string    `json:"tenant_key"`
+	ChatID      string    `json:"chat_id"`
+	MessageID   string    `json:"message_id"`
+	SenderID    string    `json:"sender_id"`
+	SenderType  string    `json:"sender_type"`
+	Content     string    `json:"content"`
+	MessageType string    `json:"message_type"`
+	CreatedAt   time.Time `json:"created_at"`
 }
 
 // SaveMessage 保存消息到数据库
-func (s *Storage) SaveMessage(ctx context.Context, msg *Message) error {
+func (s *Storage) SaveMessage(ctx context.Context, msg *Message) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("save_message", start, err) }()
+
 	log.Printf("[Storage.SaveMessage] 开始保存: chat_id=%s, message_id=%s", msg.ChatID, msg.MessageID)
 	
 	// 先检查消息是否已存在
 	var existingID int64
-	checkQuery := `SELECT id FROM messages WHERE message_id = ?`
-	err := s.db.QueryRowContext(ctx, checkQuery, msg.MessageID).Scan(&existingID)
+	checkQuery := `SELECT id FROM messages WHERE app_id = ? AND message_id = ?`
+	err = s.db.QueryRowContext(ctx, checkQuery, msg.AppID, msg.MessageID).Scan(&existingID)
 	if err == nil {
 		log.Printf("[Storage.SaveMessage] 消息已存在: message_id=%s, existing_id=%d, 将执行更新", msg.MessageID, existingID)
 	} else if err != sql.ErrNoRows {
@@ -36,70 +43,99 @@ func (s *Storage) SaveMessage(ctx context.Context, msg *Message) error {
 		log.Printf("[Storage.SaveMessage] 消息不存在，将执行插入: message_id=%s", msg.MessageID)
 	}
 	
+	// 按设置加密消息内容（明文时 keyID 为空）
+	keyID, content, err := s.sealContent(msg.AppID, msg.MessageID, msg.Content)
+	if err != nil {
+		return err
+	}
+
 	query := `
-		INSERT INTO messages (chat_id, message_id, sender_id, sender_type, content, message_type, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-		ON CONFLICT(message_id) DO UPDATE SET
+		INSERT INTO messages (app_id, tenant_key, chat_id, message_id, sender_id, sender_type, content, key_id, message_type, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(app_id, message_id) DO UPDATE SET
 			content = excluded.content,
+			key_id = excluded.key_id,
 			created_at = excluded.created_at
+		RETURNING id
 	`
 
 	log.Printf("[Storage.SaveMessage] 执行SQL: chat_id=%s, message_id=%s, content_len=%d", 
 		msg.ChatID, msg.MessageID, len(msg.Content))
-	
-	result, err := s.db.ExecContext(ctx, query,
+
+	// 消息和搜索索引在同一个事务中写入
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("保存消息失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query,
+		msg.AppID,
+		msg.TenantKey,
 		msg.ChatID,
 		msg.MessageID,
 		msg.SenderID,
 		msg.SenderType,
-		msg.Content,
+		content,
+		keyID,
 		msg.MessageType,
 		msg.CreatedAt,
-	)
+	).Scan(&msg.ID)
 
 	if err != nil {
 		log.Printf("[Storage.SaveMessage] SQL执行失败: error=%v", err)
 		return fmt.Errorf("保存消息失败: %w", err)
 	}
 
-	// 获取影响的行数
-	rowsAffected, _ := result.RowsAffected()
-	log.Printf("[Storage.SaveMessage] SQL执行成功: rows_affected=%d, message_id=%s", rowsAffected, msg.MessageID)
+	if err := s.replaceTerms(ctx, tx, msg); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("保存消息失败: %w", err)
+	}
+
+	log.Printf("[Storage.SaveMessage] SQL执行成功: id=%d, message_id=%s, encrypted=%t", msg.ID, msg.MessageID, keyID != "")
+	metrics.MessagesStored.Inc()
 
 	return nil
 }
 
 // GetMessagesByChatID 根据 chat_id 获取消息历史（按时间倒序）
-func (s *Storage) GetMessagesByChatID(ctx context.Context, chatID string, limit int) ([]*Message, error) {
+func (s *Storage) GetMessagesByChatID(ctx context.Context, scope Scope, chatID string, limit int) (messages []*Message, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("get_messages_by_chat_id", start, err) }()
+
 	if limit <= 0 {
 		limit = 50 // 默认返回最近 50 条
 	}
 
 	query := `
-		SELECT id, chat_id, message_id, sender_id, sender_type, content, message_type, created_at
+		SELECT id, app_id, tenant_key, chat_id, message_id, sender_id, sender_type, content, key_id, message_type, created_at
 		FROM messages
-		WHERE chat_id = ?
+		WHERE app_id = ? AND tenant_key = ? AND chat_id = ?
 		ORDER BY created_at DESC
 		LIMIT ?
 	`
 
-	rows, err := s.db.QueryContext(ctx, query, chatID, limit)
+	rows, err := s.db.QueryContext(ctx, query, scope.AppID, scope.TenantKey, chatID, limit)
 	if err != nil {
 		return nil, fmt.Errorf("查询消息失败: %w", err)
 	}
 	defer rows.Close()
 
-	var messages []*Message
 	for rows.Next() {
 		var msg Message
-		var createdAtStr string
+		var keyID, createdAtStr string
 		err := rows.Scan(
 			&msg.ID,
+			&msg.AppID,
+			&msg.TenantKey,
 			&msg.ChatID,
 			&msg.MessageID,
 			&msg.SenderID,
 			&msg.SenderType,
 			&msg.Content,
+			&keyID,
 			&msg.MessageType,
 			&createdAtStr,
 		)
@@ -107,6 +143,11 @@ func (s *Storage) GetMessagesByChatID(ctx context.Context, chatID string, limit
 			return nil, fmt.Errorf("扫描消息失败: %w", err)
 		}
 
+		// 透明解密
+		if msg.Content, err = s.openContent(msg.AppID, msg.MessageID, keyID, msg.Content); err != nil {
+			return nil, err
+		}
+
 		// 解析时间
 		msg.CreatedAt, err = time.Parse("2006-01-02 15:04:05", createdAtStr)
 		if err != nil {
@@ -130,21 +171,24 @@ func (s *Storage) GetMessagesByChatID(ctx context.Context, chatID string, limit
 }
 
 // GetRecentMessagesByChatID 获取最近的消息（用于大模型上下文）
-func (s *Storage) GetRecentMessagesByChatID(ctx context.Context, chatID string, limit int) ([]*Message, error) {
-	return s.GetMessagesByChatID(ctx, chatID, limit)
+func (s *Storage) GetRecentMessagesByChatID(ctx context.Context, scope Scope, chatID string, limit int) ([]*Message, error) {
+	return s.GetMessagesByChatID(ctx, scope, chatID, limit)
 }
 
 // DeleteOldMessages 删除指定 chat_id 的旧消息，只保留最近的 N 条
-func (s *Storage) DeleteOldMessages(ctx context.Context, chatID string, keepCount int) error {
+func (s *Storage) DeleteOldMessages(ctx context.Context, scope Scope, chatID string, keepCount int) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("delete_old_messages", start, err) }()
+
 	// 先获取要保留的消息 ID
 	query := `
 		SELECT id FROM messages
-		WHERE chat_id = ?
+		WHERE app_id = ? AND tenant_key = ? AND chat_id = ?
 		ORDER BY created_at DESC
 		LIMIT ?
 	`
 
-	rows, err := s.db.QueryContext(ctx, query, chatID, keepCount)
+	rows, err := s.db.QueryContext(ctx, query, scope.AppID, scope.TenantKey, chatID, keepCount)
 	if err != nil {
 		return fmt.Errorf("查询要保留的消息失败: %w", err)
 	}
@@ -165,14 +209,14 @@ func (s *Storage) DeleteOldMessages(ctx context.Context, chatID string, keepCoun
 
 	// 如果没有需要保留的消息，删除所有
 	if len(keepIDs) == 0 {
-		deleteQuery := `DELETE FROM messages WHERE chat_id = ?`
-		_, err = s.db.ExecContext(ctx, deleteQuery, chatID)
+		deleteQuery := `DELETE FROM messages WHERE app_id = ? AND tenant_key = ? AND chat_id = ?`
+		_, err = s.db.ExecContext(ctx, deleteQuery, scope.AppID, scope.TenantKey, chatID)
 		return err
 	}
 
 	// 构建 IN 子句
 	placeholders := ""
-	args := []interface{}{chatID}
+	args := []interface{}{scope.AppID, scope.TenantKey, chatID}
 	for i, id := range keepIDs {
 		if i > 0 {
 			placeholders += ","
@@ -183,7 +227,7 @@ func (s *Storage) DeleteOldMessages(ctx context.Context, chatID string, keepCoun
 
 	deleteQuery := fmt.Sprintf(`
 		DELETE FROM messages
-		WHERE chat_id = ? AND id NOT IN (%s)
+		WHERE app_id = ? AND tenant_key = ? AND chat_id = ? AND id NOT IN (%s)
 	`, placeholders)
 
 	_, err = s.db.ExecContext(ctx, deleteQuery, args...)
@@ -195,3 +239,73 @@ func (s *Storage) DeleteOldMessages(ctx context.Context, chatID string, keepCoun
 }
 
 
+
+// MessageFilter 导出消息的筛选条件
+type MessageFilter struct {
+	Scope  *Scope    // 为 nil 时不限制应用和租户
+	ChatID string    // 为空时不限制会话
+	Since  time.Time // 为零值时不限制起始时间
+}
+
+// ExportMessages 按时间顺序逐条读取符合条件的消息（已解密）并交给 fn 处理，不会一次性加载到内存
+// fn 返回错误时停止遍历并返回该错误
+func (s *Storage) ExportMessages(ctx context.Context, filter MessageFilter, fn func(*Message) error) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("export_messages", start, err) }()
+
+	query := `
+		SELECT id, app_id, tenant_key, chat_id, message_id, sender_id, sender_type, content, key_id, message_type, created_at
+		FROM messages
+		WHERE 1 = 1`
+	var args []interface{}
+	if filter.Scope != nil {
+		query += ` AND app_id = ? AND tenant_key = ?`
+		args = append(args, filter.Scope.AppID, filter.Scope.TenantKey)
+	}
+	if filter.ChatID != "" {
+		query += ` AND chat_id = ?`
+		args = append(args, filter.ChatID)
+	}
+	if !filter.Since.IsZero() {
+		query += ` AND created_at >= ?`
+		args = append(args, filter.Since.UTC())
+	}
+	query += ` ORDER BY created_at, id`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("查询消息失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		msg, err := s.scanMessage(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(msg); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("遍历消息失败: %w", err)
+	}
+	return nil
+}
+
+// scanMessage 扫描一行消息（字段顺序同 ExportMessages 的查询）并解密内容
+func (s *Storage) scanMessage(row rowScanner) (*Message, error) {
+	var msg Message
+	var keyID string
+	if err := row.Scan(&msg.ID, &msg.AppID, &msg.TenantKey, &msg.ChatID, &msg.MessageID,
+		&msg.SenderID, &msg.SenderType, &msg.Content, &keyID, &msg.MessageType, &msg.CreatedAt); err != nil {
+		return nil, fmt.Errorf("扫描消息失败: %w", err)
+	}
+	content, err := s.openContent(msg.AppID, msg.MessageID, keyID, msg.Content)
+	if err != nil {
+		return nil, err
+	}
+	msg.Content = content
+	return &msg, nil
+}
@@ -0,0 +1,657 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// migration 一次数据库结构变更
+type migration struct {
+	version int
+	name    string
+	up      func(ctx context.Context, tx *sql.Tx) error
+}
+
+// migrations 按版本号递增排列，已发布的迁移不能修改，只能追加
+var migrations = []migration{
+	{version: 1, name: "initial_tables", up: migrateInitialTables},
+	{version: 2, name: "app_tenant_scope", up: migrateAppTenantScope},
+	{version: 3, name: "message_encryption", up: migrateMessageEncryption},
+	{version: 4, name: "rate_limit", up: migrateRateLimit},
+	{version: 5, name: "roles_and_audit", up: migrateRolesAndAudit},
+	{version: 6, name: "audit_hash_chain", up: migrateAuditHashChain},
+	{version: 7, name: "courses", up: migrateCourses},
+	{version: 8, name: "quizzes", up: migrateQuizzes},
+	{version: 9, name: "flashcards", up: migrateFlashcards},
+	{version: 10, name: "progress", up: migrateProgress},
+	{version: 11, name: "glossary", up: migrateGlossary},
+	{version: 12, name: "marketdata", up: migrateMarketData},
+	{version: 13, name: "watchlists", up: migrateWatchlists},
+	{version: 14, name: "alerts", up: migrateAlerts},
+}
+
+// Migrate 执行所有未执行的数据库迁移，每个迁移在独立事务中完成
+func (s *Storage) Migrate(ctx context.Context) error {
+	pending, err := s.pendingMigrations(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range pending {
+		if err := s.applyMigration(ctx, m); err != nil {
+			return fmt.Errorf("执行迁移 %d_%s 失败: %w", m.version, m.name, err)
+		}
+		log.Printf("[Storage] 已执行数据库迁移: %d_%s", m.version, m.name)
+	}
+	return nil
+}
+
+// LatestSchemaVersion 当前程序支持的最新数据库结构版本
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// PendingMigrations 获取尚未执行的迁移名称（格式为 版本_名称）
+func (s *Storage) PendingMigrations(ctx context.Context) ([]string, error) {
+	pending, err := s.pendingMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(pending))
+	for _, m := range pending {
+		names = append(names, fmt.Sprintf("%d_%s", m.version, m.name))
+	}
+	return names, nil
+}
+
+// pendingMigrations 确保迁移记录表存在，并返回版本号大于当前版本的迁移
+func (s *Storage) pendingMigrations(ctx context.Context) ([]migration, error) {
+	if _, err := s.db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at DATETIME NOT NULL
+		)
+	`); err != nil {
+		return nil, fmt.Errorf("创建迁移记录表失败: %w", err)
+	}
+
+	current, err := s.SchemaVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []migration
+	for _, m := range migrations {
+		if m.version > current {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// SchemaVersion 获取当前数据库结构版本（未执行过迁移时为 0）
+func (s *Storage) SchemaVersion(ctx context.Context) (int, error) {
+	var version sql.NullInt64
+	if err := s.db.QueryRowContext(ctx, `SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("查询数据库版本失败: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+// applyMigration 在事务中执行单个迁移并记录版本
+func (s *Storage) applyMigration(ctx context.Context, m migration) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := m.up(ctx, tx); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+		m.version, m.name, time.Now().UTC(),
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// execAll 依次执行多条 SQL
+func execAll(ctx context.Context, tx *sql.Tx, statements ...string) error {
+	for _, stmt := range statements {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateInitialTables 创建消息、健康检查和定时任务表
+// 使用 IF NOT EXISTS，兼容引入迁移机制之前创建的数据库
+func migrateInitialTables(ctx context.Context, tx *sql.Tx) error {
+	return execAll(ctx, tx,
+		`CREATE TABLE IF NOT EXISTS messages (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_id TEXT NOT NULL,
+			message_id TEXT NOT NULL UNIQUE,
+			sender_id TEXT,
+			sender_type TEXT,
+			content TEXT NOT NULL,
+			message_type TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		// 健康检查表（就绪检查时写入，用于确认数据库可写）
+		`CREATE TABLE IF NOT EXISTS health_check (
+			id INTEGER PRIMARY KEY,
+			checked_at DATETIME
+		)`,
+		`CREATE TABLE IF NOT EXISTS schedules (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			cron_expr TEXT NOT NULL,
+			timezone TEXT NOT NULL,
+			target_type TEXT NOT NULL,
+			targets TEXT NOT NULL,
+			content TEXT NOT NULL,
+			catch_up_policy TEXT NOT NULL DEFAULT 'skip',
+			enabled INTEGER NOT NULL DEFAULT 1,
+			created_by TEXT NOT NULL DEFAULT '',
+			last_run_at DATETIME,
+			next_run_at DATETIME,
+			created_at DATETIME NOT NULL,
+			updated_at DATETIME NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS schedule_runs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			schedule_id INTEGER NOT NULL,
+			scheduled_for DATETIME NOT NULL,
+			started_at DATETIME NOT NULL,
+			success INTEGER NOT NULL DEFAULT 0,
+			failed INTEGER NOT NULL DEFAULT 0,
+			error TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_chat_id ON messages(chat_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_created_at ON messages(created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_schedule_runs_schedule_id ON schedule_runs(schedule_id)`,
+	)
+}
+
+// migrateAppTenantScope 为消息和定时任务增加 app_id、tenant_key
+// 同一条群消息会推送给群里的每个机器人，因此消息唯一键改为 (app_id, message_id)，
+// SQLite 不支持修改约束，需要重建 messages 表
+// 迁移前的数据 app_id 为空，由 ClaimUnscoped 归属到默认应用
+func migrateAppTenantScope(ctx context.Context, tx *sql.Tx) error {
+	return execAll(ctx, tx,
+		`CREATE TABLE messages_new (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			app_id TEXT NOT NULL DEFAULT '',
+			tenant_key TEXT NOT NULL DEFAULT '',
+			chat_id TEXT NOT NULL,
+			message_id TEXT NOT NULL,
+			sender_id TEXT,
+			sender_type TEXT,
+			content TEXT NOT NULL,
+			message_type TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(app_id, message_id)
+		)`,
+		`INSERT INTO messages_new (id, chat_id, message_id, sender_id, sender_type, content, message_type, created_at)
+			SELECT id, chat_id, message_id, sender_id, sender_type, content, message_type, created_at FROM messages`,
+		`DROP TABLE messages`,
+		`ALTER TABLE messages_new RENAME TO messages`,
+		`CREATE INDEX idx_messages_scope_chat ON messages(app_id, tenant_key, chat_id, created_at)`,
+		`CREATE INDEX idx_created_at ON messages(created_at)`,
+
+		`ALTER TABLE schedules ADD COLUMN app_id TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE schedules ADD COLUMN tenant_key TEXT NOT NULL DEFAULT ''`,
+		`CREATE INDEX idx_schedules_scope ON schedules(app_id, tenant_key)`,
+	)
+}
+
+// migrateMessageEncryption 为消息增加加密密钥ID（为空表示明文），并创建搜索索引表
+// 消息内容加密后无法直接检索，搜索只使用 message_terms 中允许索引的词
+func migrateMessageEncryption(ctx context.Context, tx *sql.Tx) error {
+	return execAll(ctx, tx,
+		`ALTER TABLE messages ADD COLUMN key_id TEXT NOT NULL DEFAULT ''`,
+		`CREATE INDEX idx_messages_key_id ON messages(key_id)`,
+		`CREATE TABLE message_terms (
+			message_id INTEGER NOT NULL,
+			app_id TEXT NOT NULL,
+			tenant_key TEXT NOT NULL,
+			term TEXT NOT NULL,
+			PRIMARY KEY (message_id, term)
+		)`,
+		`CREATE INDEX idx_message_terms_scope_term ON message_terms(app_id, tenant_key, term)`,
+		// 删除消息时同时删除索引（保留策略、按会话清理等都通过 DELETE 完成）
+		`CREATE TRIGGER messages_delete_terms AFTER DELETE ON messages BEGIN
+			DELETE FROM message_terms WHERE message_id = old.id;
+		END`,
+	)
+}
+
+// migrateRateLimit 创建限流令牌桶状态和临时封禁表
+// 令牌桶只保存未满的桶（满桶与新建的桶相同），重启后从这里恢复
+func migrateRateLimit(ctx context.Context, tx *sql.Tx) error {
+	return execAll(ctx, tx,
+		`CREATE TABLE rate_limit_buckets (
+			key TEXT PRIMARY KEY,
+			tokens REAL NOT NULL,
+			updated_at DATETIME NOT NULL
+		)`,
+		`CREATE TABLE rate_limit_bans (
+			app_id TEXT NOT NULL,
+			tenant_key TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			reason TEXT NOT NULL DEFAULT '',
+			created_by TEXT NOT NULL DEFAULT '',
+			until DATETIME NOT NULL,
+			created_at DATETIME NOT NULL,
+			PRIMARY KEY (app_id, tenant_key, user_id)
+		)`,
+		`CREATE INDEX idx_rate_limit_bans_until ON rate_limit_bans(until)`,
+	)
+}
+
+// migrateRolesAndAudit 创建角色分配表和审计日志表
+// chat_id 为空的分配作用于整个租户，否则只在该会话中生效
+func migrateRolesAndAudit(ctx context.Context, tx *sql.Tx) error {
+	return execAll(ctx, tx,
+		`CREATE TABLE role_assignments (
+			app_id TEXT NOT NULL,
+			tenant_key TEXT NOT NULL,
+			chat_id TEXT NOT NULL DEFAULT '',
+			user_id TEXT NOT NULL,
+			role TEXT NOT NULL,
+			granted_by TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL,
+			PRIMARY KEY (app_id, tenant_key, chat_id, user_id)
+		)`,
+		`CREATE TABLE audit_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			app_id TEXT NOT NULL DEFAULT '',
+			tenant_key TEXT NOT NULL DEFAULT '',
+			actor TEXT NOT NULL,
+			action TEXT NOT NULL,
+			target TEXT NOT NULL DEFAULT '',
+			detail TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL
+		)`,
+		`CREATE INDEX idx_audit_log_scope_created ON audit_log(app_id, tenant_key, created_at)`,
+	)
+}
+
+// migrateAuditHashChain 为审计日志增加哈希链，并禁止修改和删除
+// 已有的记录按 id 顺序补算哈希
+func migrateAuditHashChain(ctx context.Context, tx *sql.Tx) error {
+	if err := execAll(ctx, tx,
+		`ALTER TABLE audit_log ADD COLUMN prev_hash TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE audit_log ADD COLUMN hash TEXT NOT NULL DEFAULT ''`,
+	); err != nil {
+		return err
+	}
+
+	// 列和扫描按版本 6 时的表结构内联，不使用会随之后的迁移变化的 auditColumns 和 scanAudit
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id, app_id, tenant_key, actor, action, target, detail, created_at FROM audit_log ORDER BY id`)
+	if err != nil {
+		return err
+	}
+	var entries []*AuditEntry
+	for rows.Next() {
+		e := &AuditEntry{}
+		if err := rows.Scan(&e.ID, &e.AppID, &e.TenantKey, &e.Actor, &e.Action, &e.Target, &e.Detail, &e.CreatedAt); err != nil {
+			rows.Close()
+			return err
+		}
+		e.CreatedAt = e.CreatedAt.UTC()
+		entries = append(entries, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	prev := ""
+	for _, e := range entries {
+		e.PrevHash, e.Hash = prev, auditHash(prev, e)
+		if _, err := tx.ExecContext(ctx, `UPDATE audit_log SET prev_hash = ?, hash = ? WHERE id = ?`, e.PrevHash, e.Hash, e.ID); err != nil {
+			return err
+		}
+		prev = e.Hash
+	}
+
+	return execAll(ctx, tx,
+		`CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log BEGIN
+			SELECT RAISE(ABORT, 'audit_log is append-only');
+		END`,
+		`CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log BEGIN
+			SELECT RAISE(ABORT, 'audit_log is append-only');
+		END`,
+	)
+}
+
+// migrateCourses 课程内容（由 Markdown 文件导入，所有应用共用）、报名和学习记录（按应用和租户隔离）
+func migrateCourses(ctx context.Context, tx *sql.Tx) error {
+	return execAll(ctx, tx,
+		`CREATE TABLE courses (
+			id TEXT PRIMARY KEY,
+			title TEXT NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			intro TEXT NOT NULL DEFAULT '',
+			position INTEGER NOT NULL DEFAULT 0,
+			source TEXT NOT NULL DEFAULT '',
+			updated_at DATETIME NOT NULL
+		)`,
+		`CREATE TABLE course_modules (
+			course_id TEXT NOT NULL,
+			id TEXT NOT NULL,
+			title TEXT NOT NULL,
+			position INTEGER NOT NULL,
+			PRIMARY KEY (course_id, id)
+		)`,
+		`CREATE TABLE lessons (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			course_id TEXT NOT NULL,
+			module_id TEXT NOT NULL DEFAULT '',
+			slug TEXT NOT NULL,
+			title TEXT NOT NULL,
+			position INTEGER NOT NULL,
+			minutes INTEGER NOT NULL DEFAULT 0,
+			body TEXT NOT NULL,
+			source TEXT NOT NULL DEFAULT '',
+			updated_at DATETIME NOT NULL,
+			UNIQUE (course_id, slug)
+		)`,
+		`CREATE INDEX idx_lessons_course_position ON lessons(course_id, position)`,
+		`CREATE TABLE course_enrollments (
+			app_id TEXT NOT NULL,
+			tenant_key TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			course_id TEXT NOT NULL,
+			enrolled_at DATETIME NOT NULL,
+			active_at DATETIME NOT NULL,
+			completed_at DATETIME,
+			PRIMARY KEY (app_id, tenant_key, user_id, course_id)
+		)`,
+		`CREATE TABLE lesson_views (
+			app_id TEXT NOT NULL,
+			tenant_key TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			lesson_id INTEGER NOT NULL,
+			viewed_at DATETIME NOT NULL,
+			PRIMARY KEY (app_id, tenant_key, user_id, lesson_id)
+		)`,
+		`CREATE INDEX idx_lesson_views_lesson ON lesson_views(lesson_id)`,
+	)
+}
+
+// migrateQuizzes 题库（由 JSON/CSV 文件导入，所有应用共用）、测验记录和每道题的作答（按应用和租户隔离）
+// 每个用户同时只有一次进行中的测验，由部分唯一索引保证
+func migrateQuizzes(ctx context.Context, tx *sql.Tx) error {
+	return execAll(ctx, tx,
+		`CREATE TABLE quiz_banks (
+			id TEXT PRIMARY KEY,
+			title TEXT NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			course_id TEXT NOT NULL DEFAULT '',
+			lesson_slug TEXT NOT NULL DEFAULT '',
+			shuffle_options INTEGER NOT NULL DEFAULT 1,
+			source TEXT NOT NULL DEFAULT '',
+			updated_at DATETIME NOT NULL
+		)`,
+		`CREATE INDEX idx_quiz_banks_lesson ON quiz_banks(course_id, lesson_slug)`,
+		`CREATE TABLE quiz_questions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			bank_id TEXT NOT NULL,
+			slug TEXT NOT NULL,
+			position INTEGER NOT NULL,
+			type TEXT NOT NULL,
+			prompt TEXT NOT NULL,
+			options TEXT NOT NULL DEFAULT '[]',
+			answer TEXT NOT NULL,
+			tolerance REAL NOT NULL DEFAULT 0,
+			explanation TEXT NOT NULL DEFAULT '',
+			updated_at DATETIME NOT NULL,
+			UNIQUE (bank_id, slug)
+		)`,
+		`CREATE TABLE quiz_attempts (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			app_id TEXT NOT NULL,
+			tenant_key TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			chat_id TEXT NOT NULL,
+			bank_id TEXT NOT NULL,
+			question_ids TEXT NOT NULL,
+			seed INTEGER NOT NULL,
+			total INTEGER NOT NULL,
+			answered INTEGER NOT NULL DEFAULT 0,
+			correct INTEGER NOT NULL DEFAULT 0,
+			status TEXT NOT NULL DEFAULT 'active',
+			started_at DATETIME NOT NULL,
+			finished_at DATETIME
+		)`,
+		`CREATE UNIQUE INDEX idx_quiz_attempts_active ON quiz_attempts(app_id, tenant_key, user_id) WHERE status = 'active'`,
+		`CREATE INDEX idx_quiz_attempts_user ON quiz_attempts(app_id, tenant_key, user_id, bank_id)`,
+		`CREATE TABLE quiz_answers (
+			attempt_id INTEGER NOT NULL,
+			question_id INTEGER NOT NULL,
+			position INTEGER NOT NULL,
+			answer TEXT NOT NULL,
+			correct INTEGER NOT NULL,
+			answered_at DATETIME NOT NULL,
+			PRIMARY KEY (attempt_id, question_id)
+		)`,
+	)
+}
+
+// migrateFlashcards 记忆卡片组（由 CSV/TSV 文件导入，所有应用共用）、每个用户每张卡片的复习状态、
+// 复习记录和每日提醒设置（按应用和租户隔离）
+func migrateFlashcards(ctx context.Context, tx *sql.Tx) error {
+	return execAll(ctx, tx,
+		`CREATE TABLE flashcard_decks (
+			id TEXT PRIMARY KEY,
+			title TEXT NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			source TEXT NOT NULL DEFAULT '',
+			updated_at DATETIME NOT NULL
+		)`,
+		`CREATE TABLE flashcard_cards (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			deck_id TEXT NOT NULL,
+			key TEXT NOT NULL,
+			position INTEGER NOT NULL,
+			front TEXT NOT NULL,
+			back TEXT NOT NULL,
+			tags TEXT NOT NULL DEFAULT '',
+			updated_at DATETIME NOT NULL,
+			UNIQUE (deck_id, key)
+		)`,
+		`CREATE TABLE flashcard_states (
+			app_id TEXT NOT NULL,
+			tenant_key TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			card_id INTEGER NOT NULL,
+			repetitions INTEGER NOT NULL DEFAULT 0,
+			interval_days INTEGER NOT NULL DEFAULT 0,
+			ease REAL NOT NULL,
+			due_at DATETIME NOT NULL,
+			reviews INTEGER NOT NULL DEFAULT 0,
+			lapses INTEGER NOT NULL DEFAULT 0,
+			first_reviewed_at DATETIME NOT NULL,
+			last_reviewed_at DATETIME NOT NULL,
+			PRIMARY KEY (app_id, tenant_key, user_id, card_id)
+		)`,
+		`CREATE INDEX idx_flashcard_states_due ON flashcard_states(app_id, tenant_key, user_id, due_at)`,
+		`CREATE TABLE flashcard_reviews (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			app_id TEXT NOT NULL,
+			tenant_key TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			card_id INTEGER NOT NULL,
+			grade TEXT NOT NULL,
+			interval_days INTEGER NOT NULL,
+			ease REAL NOT NULL,
+			reviewed_at DATETIME NOT NULL
+		)`,
+		`CREATE INDEX idx_flashcard_reviews_user ON flashcard_reviews(app_id, tenant_key, user_id, reviewed_at)`,
+		`CREATE TABLE flashcard_learners (
+			app_id TEXT NOT NULL,
+			tenant_key TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			push_enabled INTEGER NOT NULL DEFAULT 1,
+			last_pushed_at DATETIME,
+			PRIMARY KEY (app_id, tenant_key, user_id)
+		)`,
+	)
+}
+
+// migrateProgress 学习事件（学完课、学完课程、完成测验、复习卡片）和用户的时区设置
+// 同一来源的事件只记录一次，由 (kind, ref) 唯一索引保证，重建时可以重复写入
+func migrateProgress(ctx context.Context, tx *sql.Tx) error {
+	return execAll(ctx, tx,
+		`CREATE TABLE progress_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			app_id TEXT NOT NULL,
+			tenant_key TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			ref TEXT NOT NULL,
+			score INTEGER NOT NULL DEFAULT 0,
+			total INTEGER NOT NULL DEFAULT 0,
+			occurred_at DATETIME NOT NULL,
+			UNIQUE (app_id, tenant_key, user_id, kind, ref)
+		)`,
+		`CREATE INDEX idx_progress_events_user ON progress_events(app_id, tenant_key, user_id, occurred_at)`,
+		`CREATE INDEX idx_progress_events_time ON progress_events(app_id, tenant_key, occurred_at)`,
+		`CREATE TABLE progress_profiles (
+			app_id TEXT NOT NULL,
+			tenant_key TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			timezone TEXT NOT NULL DEFAULT '',
+			PRIMARY KEY (app_id, tenant_key, user_id)
+		)`,
+	)
+}
+
+// migrateGlossary 金融术语词条（由 CSV 文件导入，所有应用共用）和订阅每日术语的会话
+func migrateGlossary(ctx context.Context, tx *sql.Tx) error {
+	return execAll(ctx, tx,
+		`CREATE TABLE glossary_terms (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			source TEXT NOT NULL,
+			term TEXT NOT NULL UNIQUE,
+			english TEXT NOT NULL DEFAULT '',
+			aliases TEXT NOT NULL DEFAULT '',
+			pinyin TEXT NOT NULL DEFAULT '',
+			definition TEXT NOT NULL,
+			formula TEXT NOT NULL DEFAULT '',
+			example TEXT NOT NULL DEFAULT '',
+			related TEXT NOT NULL DEFAULT '',
+			updated_at DATETIME NOT NULL
+		)`,
+		`CREATE INDEX idx_glossary_terms_source ON glossary_terms(source)`,
+		`CREATE TABLE glossary_subscriptions (
+			app_id TEXT NOT NULL,
+			tenant_key TEXT NOT NULL,
+			chat_id TEXT NOT NULL,
+			created_by TEXT NOT NULL,
+			created_at DATETIME NOT NULL,
+			last_sent_at DATETIME,
+			PRIMARY KEY (app_id, tenant_key, chat_id)
+		)`,
+	)
+}
+
+// migrateMarketData 本地行情数据（由 CSV 文件导入，供离线和测试使用）：证券列表和日线
+// 日期以 YYYY-MM-DD 文本保存，按日期范围查询时可以直接比较
+func migrateMarketData(ctx context.Context, tx *sql.Tx) error {
+	return execAll(ctx, tx,
+		`CREATE TABLE market_instruments (
+			symbol TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			market TEXT NOT NULL,
+			currency TEXT NOT NULL,
+			aliases TEXT NOT NULL DEFAULT '',
+			updated_at DATETIME NOT NULL
+		)`,
+		`CREATE TABLE market_bars (
+			symbol TEXT NOT NULL,
+			date TEXT NOT NULL,
+			open REAL NOT NULL,
+			high REAL NOT NULL,
+			low REAL NOT NULL,
+			close REAL NOT NULL,
+			volume INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (symbol, date)
+		)`,
+	)
+}
+
+// migrateWatchlists 自选证券：单聊中属于用户，群聊中属于整个群，都按会话保存
+func migrateWatchlists(ctx context.Context, tx *sql.Tx) error {
+	return execAll(ctx, tx,
+		`CREATE TABLE watchlist_items (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			app_id TEXT NOT NULL,
+			tenant_key TEXT NOT NULL,
+			chat_id TEXT NOT NULL,
+			chat_type TEXT NOT NULL,
+			symbol TEXT NOT NULL,
+			added_by TEXT NOT NULL,
+			added_at DATETIME NOT NULL,
+			UNIQUE (app_id, tenant_key, chat_id, symbol)
+		)`,
+	)
+}
+
+// migrateAlerts 价格和指标提醒：规则属于创建的用户，触发记录保存每次触发时的值和投递结果
+// armed 为 0 表示重复提醒已触发、等待值回到阈值另一侧后再次生效
+func migrateAlerts(ctx context.Context, tx *sql.Tx) error {
+	return execAll(ctx, tx,
+		`CREATE TABLE alert_rules (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			app_id TEXT NOT NULL,
+			tenant_key TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			chat_id TEXT NOT NULL,
+			symbol TEXT NOT NULL,
+			metric TEXT NOT NULL,
+			period INTEGER NOT NULL DEFAULT 0,
+			op TEXT NOT NULL,
+			threshold REAL NOT NULL,
+			repeat INTEGER NOT NULL DEFAULT 0,
+			text TEXT NOT NULL DEFAULT '',
+			status TEXT NOT NULL,
+			armed INTEGER NOT NULL DEFAULT 1,
+			trigger_count INTEGER NOT NULL DEFAULT 0,
+			last_triggered_at DATETIME,
+			created_at DATETIME NOT NULL,
+			updated_at DATETIME NOT NULL
+		)`,
+		`CREATE INDEX idx_alert_rules_status ON alert_rules(status)`,
+		`CREATE INDEX idx_alert_rules_user ON alert_rules(app_id, tenant_key, user_id)`,
+		`CREATE TABLE alert_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			rule_id INTEGER NOT NULL,
+			app_id TEXT NOT NULL,
+			tenant_key TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			symbol TEXT NOT NULL,
+			metric TEXT NOT NULL,
+			condition TEXT NOT NULL,
+			value REAL NOT NULL,
+			message TEXT NOT NULL,
+			delivered INTEGER NOT NULL DEFAULT 0,
+			error TEXT NOT NULL DEFAULT '',
+			triggered_at DATETIME NOT NULL
+		)`,
+		`CREATE INDEX idx_alert_events_rule ON alert_events(rule_id)`,
+		`CREATE INDEX idx_alert_events_user ON alert_events(app_id, tenant_key, user_id)`,
+	)
+}
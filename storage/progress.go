@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"fin_bot/metrics"
+)
+
+// 学习事件的类型
+const (
+	ProgressLessonCompleted = "lesson.completed"   // 学完一节课，Ref 为课的 ID
+	ProgressCourseCompleted = "course.completed"   // 学完一门课程，Ref 为课程 ID
+	ProgressQuizFinished    = "quiz.finished"      // 完成一次测验，Ref 为测验 ID，Score/Total 为答对题数和总题数
+	ProgressCardReviewed    = "flashcard.reviewed" // 复习一张卡片，Ref 为“卡片 ID:第几次复习”
+)
+
+// ProgressEvent 一条学习事件，经验值、连续学习天数和徽章都由学习事件计算
+// 学习事件可以随时由课程、测验和记忆卡片的记录重建，见 RebuildProgressEvents
+type ProgressEvent struct {
+	ID         int64     `json:"id"`
+	AppID      string    `json:"app_id"`
+	TenantKey  string    `json:"tenant_key"`
+	UserID     string    `json:"user_id"`
+	Kind       string    `json:"kind"`
+	Ref        string    `json:"ref"` // 事件来源，同一用户同一类型的 Ref 不重复
+	Score      int       `json:"score,omitempty"`
+	Total      int       `json:"total,omitempty"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+const progressEventColumns = `id, app_id, tenant_key, user_id, kind, ref, score, total, occurred_at`
+
+// progressRebuildQueries 由课程、测验和记忆卡片的记录生成学习事件
+// 卡片复习的 Ref 中的序号与复习状态中的复习次数一致，和实时记录的事件相同
+var progressRebuildQueries = map[string]string{
+	ProgressLessonCompleted: `
+		SELECT app_id, tenant_key, user_id, '` + ProgressLessonCompleted + `', CAST(lesson_id AS TEXT), 0, 0, viewed_at
+		FROM lesson_views`,
+	ProgressCourseCompleted: `
+		SELECT app_id, tenant_key, user_id, '` + ProgressCourseCompleted + `', course_id, 0, 0, completed_at
+		FROM course_enrollments WHERE completed_at IS NOT NULL`,
+	ProgressQuizFinished: `
+		SELECT app_id, tenant_key, user_id, '` + ProgressQuizFinished + `', CAST(id AS TEXT), correct, total, finished_at
+		FROM quiz_attempts WHERE status = '` + QuizFinished + `'`,
+	ProgressCardReviewed: `
+		SELECT app_id, tenant_key, user_id, '` + ProgressCardReviewed + `',
+			card_id || ':' || ROW_NUMBER() OVER (PARTITION BY app_id, tenant_key, user_id, card_id ORDER BY id), 0, 0, reviewed_at
+		FROM flashcard_reviews`,
+}
+
+// ProgressKinds 学习事件类型的顺序（重建结果按这个顺序输出）
+var ProgressKinds = []string{ProgressLessonCompleted, ProgressCourseCompleted, ProgressQuizFinished, ProgressCardReviewed}
+
+// AppendProgressEvent 记录一条学习事件，同一来源的事件已经存在时不记录，recorded 为 false
+func (s *Storage) AppendProgressEvent(ctx context.Context, ev *ProgressEvent) (recorded bool, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("append_progress_event", start, err) }()
+
+	ev.OccurredAt = ev.OccurredAt.UTC()
+	result, err := s.db.ExecContext(ctx, `
+		INSERT OR IGNORE INTO progress_events (app_id, tenant_key, user_id, kind, ref, score, total, occurred_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, ev.AppID, ev.TenantKey, ev.UserID, ev.Kind, ev.Ref, ev.Score, ev.Total, ev.OccurredAt)
+	if err != nil {
+		return false, fmt.Errorf("保存学习事件失败: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil || n == 0 {
+		return false, err
+	}
+	ev.ID, err = result.LastInsertId()
+	return true, err
+}
+
+// ListProgressEvents 获取用户的所有学习事件（按时间顺序）
+func (s *Storage) ListProgressEvents(ctx context.Context, scope Scope, userID string) (events []*ProgressEvent, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("list_progress_events", start, err) }()
+
+	return s.queryProgressEvents(ctx, `WHERE app_id = ? AND tenant_key = ? AND user_id = ? ORDER BY occurred_at, id`,
+		scope.AppID, scope.TenantKey, userID)
+}
+
+// ListChatProgressEvents 获取群成员（在群里发过消息的用户）从 since 开始的学习事件，since 为零值时获取所有事件
+func (s *Storage) ListChatProgressEvents(ctx context.Context, scope Scope, chatID string, since time.Time) (events []*ProgressEvent, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("list_chat_progress_events", start, err) }()
+
+	where := `WHERE app_id = ? AND tenant_key = ? AND user_id IN (
+		SELECT DISTINCT sender_id FROM messages WHERE app_id = ? AND tenant_key = ? AND chat_id = ?)`
+	args := []interface{}{scope.AppID, scope.TenantKey, scope.AppID, scope.TenantKey, chatID}
+	if !since.IsZero() {
+		where += ` AND occurred_at >= ?`
+		args = append(args, since.UTC())
+	}
+	return s.queryProgressEvents(ctx, where+` ORDER BY occurred_at, id`, args...)
+}
+
+// RebuildProgressEvents 在一个事务中清空学习事件，由课程、测验和记忆卡片的记录重新生成，返回每种事件的数量
+func (s *Storage) RebuildProgressEvents(ctx context.Context) (counts map[string]int, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("rebuild_progress_events", start, err) }()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM progress_events`); err != nil {
+		return nil, fmt.Errorf("清空学习事件失败: %w", err)
+	}
+	counts = make(map[string]int, len(ProgressKinds))
+	for _, kind := range ProgressKinds {
+		result, err := tx.ExecContext(ctx, `
+			INSERT OR IGNORE INTO progress_events (app_id, tenant_key, user_id, kind, ref, score, total, occurred_at)
+		`+progressRebuildQueries[kind])
+		if err != nil {
+			return nil, fmt.Errorf("生成学习事件失败: kind=%s: %w", kind, err)
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		counts[kind] = int(n)
+	}
+	return counts, tx.Commit()
+}
+
+// GetProgressTimezone 获取用户设置的时区，没有设置时返回空字符串
+func (s *Storage) GetProgressTimezone(ctx context.Context, scope Scope, userID string) (tz string, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("get_progress_timezone", start, err) }()
+
+	err = s.db.QueryRowContext(ctx, `SELECT timezone FROM progress_profiles WHERE app_id = ? AND tenant_key = ? AND user_id = ?`,
+		scope.AppID, scope.TenantKey, userID).Scan(&tz)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("查询时区设置失败: %w", err)
+	}
+	return tz, nil
+}
+
+// SetProgressTimezone 设置用户计算连续学习天数使用的时区，为空时使用默认时区
+func (s *Storage) SetProgressTimezone(ctx context.Context, scope Scope, userID, tz string) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("set_progress_timezone", start, err) }()
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO progress_profiles (app_id, tenant_key, user_id, timezone) VALUES (?, ?, ?, ?)
+		ON CONFLICT(app_id, tenant_key, user_id) DO UPDATE SET timezone = excluded.timezone
+	`, scope.AppID, scope.TenantKey, userID, tz)
+	if err != nil {
+		return fmt.Errorf("保存时区设置失败: %w", err)
+	}
+	return nil
+}
+
+// queryProgressEvents 按条件查询学习事件
+func (s *Storage) queryProgressEvents(ctx context.Context, where string, args ...interface{}) ([]*ProgressEvent, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+progressEventColumns+` FROM progress_events `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询学习事件失败: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*ProgressEvent
+	for rows.Next() {
+		ev := &ProgressEvent{}
+		if err := rows.Scan(&ev.ID, &ev.AppID, &ev.TenantKey, &ev.UserID, &ev.Kind, &ev.Ref, &ev.Score, &ev.Total, &ev.OccurredAt); err != nil {
+			return nil, fmt.Errorf("扫描学习事件失败: %w", err)
+		}
+		events = append(events, ev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历学习事件失败: %w", err)
+	}
+	return events, nil
+}
@@ -0,0 +1,506 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"fin_bot/metrics"
+)
+
+// 测验状态
+const (
+	QuizActive    = "active"    // 进行中
+	QuizFinished  = "finished"  // 所有题目都已作答
+	QuizAbandoned = "abandoned" // 中途放弃或开始了新的测验
+)
+
+// QuizBank 题库（由 JSON 或 CSV 文件导入，所有应用共用）
+type QuizBank struct {
+	ID             string    `json:"id"`
+	Title          string    `json:"title"`
+	Description    string    `json:"description,omitempty"`
+	CourseID       string    `json:"course_id,omitempty"`   // 关联的课程，为空表示独立题库
+	LessonSlug     string    `json:"lesson_slug,omitempty"` // 关联的课（课程中的 slug），学完这节课后提示测验
+	ShuffleOptions bool      `json:"shuffle_options"`       // 是否打乱单选和多选题的选项顺序
+	Source         string    `json:"source,omitempty"`      // 导入时的文件
+	UpdatedAt      time.Time `json:"updated_at"`
+	Questions      int       `json:"questions"` // 题目数，查询时统计
+}
+
+// QuizQuestion 一道题
+// ID 在重新导入时保持不变（按题库和 slug 匹配），测验记录中保存的是 ID
+type QuizQuestion struct {
+	ID          int64     `json:"id"`
+	BankID      string    `json:"bank_id"`
+	Slug        string    `json:"slug"`
+	Position    int       `json:"position"`
+	Type        string    `json:"type"` // single、multiple、truefalse 或 numeric
+	Prompt      string    `json:"prompt"`
+	Options     []string  `json:"options,omitempty"`
+	Answer      string    `json:"answer"` // 标准化后的答案：选项下标（多选用逗号分隔）、true/false 或数值
+	Tolerance   float64   `json:"tolerance,omitempty"`
+	Explanation string    `json:"explanation,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// QuizBankImport 一个题库的完整内容
+type QuizBankImport struct {
+	Bank      *QuizBank
+	Questions []*QuizQuestion
+}
+
+// QuizImportStats 导入一个题库的结果
+type QuizImportStats struct {
+	Added     int `json:"added"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Removed   int `json:"removed"` // 文件中已删除的题，已有的作答记录保留
+}
+
+// QuizAttempt 用户的一次测验
+// 题目顺序在开始时确定并保存，选项顺序由 Seed 和题目 ID 计算，重新导入题库不影响进行中的测验
+type QuizAttempt struct {
+	ID          int64      `json:"id"`
+	AppID       string     `json:"app_id"`
+	TenantKey   string     `json:"tenant_key"`
+	UserID      string     `json:"user_id"`
+	ChatID      string     `json:"chat_id"` // 开始测验的会话，直接回复的答案只在这个会话中识别
+	BankID      string     `json:"bank_id"`
+	QuestionIDs []int64    `json:"question_ids"`
+	Seed        int64      `json:"seed"`
+	Answered    int        `json:"answered"` // 已作答的题数，也是当前题目的下标
+	Correct     int        `json:"correct"`
+	Status      string     `json:"status"`
+	StartedAt   time.Time  `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+// Total 测验的题数
+func (a *QuizAttempt) Total() int {
+	return len(a.QuestionIDs)
+}
+
+// CurrentQuestionID 当前要回答的题目，测验已结束时返回 0
+func (a *QuizAttempt) CurrentQuestionID() int64 {
+	if a.Status != QuizActive || a.Answered >= len(a.QuestionIDs) {
+		return 0
+	}
+	return a.QuestionIDs[a.Answered]
+}
+
+// QuizAnswer 一道题的作答
+type QuizAnswer struct {
+	AttemptID  int64     `json:"attempt_id"`
+	QuestionID int64     `json:"question_id"`
+	Position   int       `json:"position"` // 在测验中的序号（从 1 开始）
+	Answer     string    `json:"answer"`   // 用户的原始答案
+	Correct    bool      `json:"correct"`
+	AnsweredAt time.Time `json:"answered_at"`
+}
+
+const (
+	quizQuestionColumns = `id, bank_id, slug, position, type, prompt, options, answer, tolerance, explanation, updated_at`
+	quizAttemptColumns  = `id, app_id, tenant_key, user_id, chat_id, bank_id, question_ids, seed, answered, correct, status, started_at, finished_at`
+)
+
+// ImportQuizBank 在一个事务中导入一个题库：更新题库信息，按 slug 新增或更新题目，删除文件中已不存在的题目
+func (s *Storage) ImportQuizBank(ctx context.Context, imp *QuizBankImport) (stats QuizImportStats, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("import_quiz_bank", start, err) }()
+
+	now := time.Now().UTC()
+	b := imp.Bank
+	b.UpdatedAt = now
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return stats, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO quiz_banks (id, title, description, course_id, lesson_slug, shuffle_options, source, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			title = excluded.title, description = excluded.description, course_id = excluded.course_id,
+			lesson_slug = excluded.lesson_slug, shuffle_options = excluded.shuffle_options,
+			source = excluded.source, updated_at = excluded.updated_at
+	`, b.ID, b.Title, b.Description, b.CourseID, b.LessonSlug, b.ShuffleOptions, b.Source, now); err != nil {
+		return stats, fmt.Errorf("保存题库失败: %w", err)
+	}
+
+	existing, err := queryQuizQuestions(ctx, tx, `WHERE bank_id = ?`, b.ID)
+	if err != nil {
+		return stats, err
+	}
+	bySlug := make(map[string]*QuizQuestion, len(existing))
+	for _, q := range existing {
+		bySlug[q.Slug] = q
+	}
+
+	for _, q := range imp.Questions {
+		q.BankID = b.ID
+		old, ok := bySlug[q.Slug]
+		delete(bySlug, q.Slug)
+		switch {
+		case !ok:
+			stats.Added++
+		case old.Position == q.Position && old.Type == q.Type && old.Prompt == q.Prompt &&
+			slices.Equal(old.Options, q.Options) && old.Answer == q.Answer &&
+			old.Tolerance == q.Tolerance && old.Explanation == q.Explanation:
+			q.ID, q.UpdatedAt = old.ID, old.UpdatedAt
+			stats.Unchanged++
+			continue
+		default:
+			stats.Updated++
+		}
+
+		options, err := json.Marshal(q.Options)
+		if err != nil {
+			return stats, err
+		}
+		q.UpdatedAt = now
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO quiz_questions (bank_id, slug, position, type, prompt, options, answer, tolerance, explanation, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(bank_id, slug) DO UPDATE SET
+				position = excluded.position, type = excluded.type, prompt = excluded.prompt, options = excluded.options,
+				answer = excluded.answer, tolerance = excluded.tolerance, explanation = excluded.explanation,
+				updated_at = excluded.updated_at
+			RETURNING id
+		`, q.BankID, q.Slug, q.Position, q.Type, q.Prompt, string(options), q.Answer, q.Tolerance, q.Explanation, now).Scan(&q.ID)
+		if err != nil {
+			return stats, fmt.Errorf("保存题库 %s 的题目 %s 失败: %w", b.ID, q.Slug, err)
+		}
+	}
+
+	for _, q := range bySlug {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM quiz_questions WHERE id = ?`, q.ID); err != nil {
+			return stats, fmt.Errorf("删除题库 %s 的题目 %s 失败: %w", b.ID, q.Slug, err)
+		}
+		stats.Removed++
+	}
+
+	return stats, tx.Commit()
+}
+
+// ListQuizBanks 获取所有题库及其题目数
+func (s *Storage) ListQuizBanks(ctx context.Context) (banks []*QuizBank, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("list_quiz_banks", start, err) }()
+
+	return s.queryQuizBanks(ctx, `ORDER BY b.course_id, b.id`)
+}
+
+// ListLessonQuizBanks 获取关联到一节课的题库
+func (s *Storage) ListLessonQuizBanks(ctx context.Context, courseID, lessonSlug string) (banks []*QuizBank, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("list_lesson_quiz_banks", start, err) }()
+
+	return s.queryQuizBanks(ctx, `WHERE b.course_id = ? AND b.lesson_slug = ? ORDER BY b.id`, courseID, lessonSlug)
+}
+
+// GetQuizBank 根据 ID 获取题库
+func (s *Storage) GetQuizBank(ctx context.Context, id string) (b *QuizBank, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("get_quiz_bank", start, err) }()
+
+	banks, err := s.queryQuizBanks(ctx, `WHERE b.id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(banks) == 0 {
+		return nil, ErrNotFound
+	}
+	return banks[0], nil
+}
+
+// queryQuizBanks 按条件查询题库
+func (s *Storage) queryQuizBanks(ctx context.Context, where string, args ...interface{}) ([]*QuizBank, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT b.id, b.title, b.description, b.course_id, b.lesson_slug, b.shuffle_options, b.source, b.updated_at,
+			(SELECT COUNT(*) FROM quiz_questions q WHERE q.bank_id = b.id)
+		FROM quiz_banks b `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询题库失败: %w", err)
+	}
+	defer rows.Close()
+
+	var banks []*QuizBank
+	for rows.Next() {
+		b := &QuizBank{}
+		if err := rows.Scan(&b.ID, &b.Title, &b.Description, &b.CourseID, &b.LessonSlug, &b.ShuffleOptions, &b.Source, &b.UpdatedAt, &b.Questions); err != nil {
+			return nil, fmt.Errorf("扫描题库失败: %w", err)
+		}
+		banks = append(banks, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历题库失败: %w", err)
+	}
+	return banks, nil
+}
+
+// ListQuizQuestions 获取题库的所有题目（按顺序）
+func (s *Storage) ListQuizQuestions(ctx context.Context, bankID string) (questions []*QuizQuestion, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("list_quiz_questions", start, err) }()
+
+	return queryQuizQuestions(ctx, s.db, `WHERE bank_id = ? ORDER BY position`, bankID)
+}
+
+// GetQuizQuestion 根据 ID 获取题目
+func (s *Storage) GetQuizQuestion(ctx context.Context, id int64) (q *QuizQuestion, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("get_quiz_question", start, err) }()
+
+	questions, err := queryQuizQuestions(ctx, s.db, `WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(questions) == 0 {
+		return nil, ErrNotFound
+	}
+	return questions[0], nil
+}
+
+// StartQuizAttempt 开始一次测验，用户进行中的测验标记为放弃
+func (s *Storage) StartQuizAttempt(ctx context.Context, a *QuizAttempt) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("start_quiz_attempt", start, err) }()
+
+	questionIDs, err := json.Marshal(a.QuestionIDs)
+	if err != nil {
+		return err
+	}
+	a.Status = QuizActive
+	a.StartedAt = a.StartedAt.UTC()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE quiz_attempts SET status = ?, finished_at = ?
+		WHERE app_id = ? AND tenant_key = ? AND user_id = ? AND status = ?
+	`, QuizAbandoned, a.StartedAt, a.AppID, a.TenantKey, a.UserID, QuizActive); err != nil {
+		return fmt.Errorf("结束进行中的测验失败: %w", err)
+	}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO quiz_attempts (app_id, tenant_key, user_id, chat_id, bank_id, question_ids, seed, total, status, started_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`, a.AppID, a.TenantKey, a.UserID, a.ChatID, a.BankID, string(questionIDs), a.Seed, a.Total(), a.Status, a.StartedAt).Scan(&a.ID)
+	if err != nil {
+		return fmt.Errorf("保存测验失败: %w", err)
+	}
+	return tx.Commit()
+}
+
+// GetActiveQuizAttempt 获取用户进行中的测验，没有时返回 ErrNotFound
+func (s *Storage) GetActiveQuizAttempt(ctx context.Context, scope Scope, userID string) (a *QuizAttempt, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("get_active_quiz_attempt", start, err) }()
+
+	attempts, err := queryQuizAttempts(ctx, s.db, `WHERE app_id = ? AND tenant_key = ? AND user_id = ? AND status = ?`,
+		scope.AppID, scope.TenantKey, userID, QuizActive)
+	if err != nil {
+		return nil, err
+	}
+	if len(attempts) == 0 {
+		return nil, ErrNotFound
+	}
+	return attempts[0], nil
+}
+
+// GetQuizAttempt 根据 ID 获取测验
+func (s *Storage) GetQuizAttempt(ctx context.Context, id int64) (a *QuizAttempt, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("get_quiz_attempt", start, err) }()
+
+	attempts, err := queryQuizAttempts(ctx, s.db, `WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(attempts) == 0 {
+		return nil, ErrNotFound
+	}
+	return attempts[0], nil
+}
+
+// ListQuizAttempts 获取用户已完成的测验，bankID 为空时返回所有题库的，最近的排在最前
+func (s *Storage) ListQuizAttempts(ctx context.Context, scope Scope, userID, bankID string) (attempts []*QuizAttempt, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("list_quiz_attempts", start, err) }()
+
+	where := `WHERE app_id = ? AND tenant_key = ? AND user_id = ? AND status = ?`
+	args := []interface{}{scope.AppID, scope.TenantKey, userID, QuizFinished}
+	if bankID != "" {
+		where += ` AND bank_id = ?`
+		args = append(args, bankID)
+	}
+	return queryQuizAttempts(ctx, s.db, where+` ORDER BY finished_at DESC, id DESC`, args...)
+}
+
+// AnswerQuizQuestion 记录当前题目的作答并前进到下一题，回答完最后一题时测验结束
+// 同一道题只记录第一次作答（例如重复点击卡片按钮），recorded 为 false 表示测验已不在这道题上，
+// 此时返回测验的最新状态；测验不存在时返回 ErrNotFound
+func (s *Storage) AnswerQuizQuestion(ctx context.Context, ans *QuizAnswer) (a *QuizAttempt, recorded bool, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("answer_quiz_question", start, err) }()
+
+	s.quizMu.Lock()
+	defer s.quizMu.Unlock()
+
+	ans.AnsweredAt = ans.AnsweredAt.UTC()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	attempts, err := queryQuizAttempts(ctx, tx, `WHERE id = ?`, ans.AttemptID)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(attempts) == 0 {
+		return nil, false, ErrNotFound
+	}
+	a = attempts[0]
+	if a.CurrentQuestionID() != ans.QuestionID {
+		return a, false, nil
+	}
+
+	ans.Position = a.Answered + 1
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO quiz_answers (attempt_id, question_id, position, answer, correct, answered_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, ans.AttemptID, ans.QuestionID, ans.Position, ans.Answer, ans.Correct, ans.AnsweredAt); err != nil {
+		return nil, false, fmt.Errorf("保存作答失败: %w", err)
+	}
+
+	a.Answered++
+	if ans.Correct {
+		a.Correct++
+	}
+	var finishedAt sql.NullTime
+	if a.Answered >= a.Total() {
+		a.Status = QuizFinished
+		a.FinishedAt = &ans.AnsweredAt
+		finishedAt = sql.NullTime{Time: ans.AnsweredAt, Valid: true}
+	}
+	result, err := tx.ExecContext(ctx, `
+		UPDATE quiz_attempts SET answered = ?, correct = ?, status = ?, finished_at = ?
+		WHERE id = ? AND answered = ? AND status = ?
+	`, a.Answered, a.Correct, a.Status, finishedAt, a.ID, a.Answered-1, QuizActive)
+	if err != nil {
+		return nil, false, fmt.Errorf("更新测验失败: %w", err)
+	}
+	if err := checkAffected(result); err != nil {
+		return nil, false, err
+	}
+	return a, true, tx.Commit()
+}
+
+// AbandonQuizAttempt 放弃进行中的测验，测验已结束时返回 ErrNotFound
+func (s *Storage) AbandonQuizAttempt(ctx context.Context, id int64, at time.Time) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("abandon_quiz_attempt", start, err) }()
+
+	result, err := s.db.ExecContext(ctx, `UPDATE quiz_attempts SET status = ?, finished_at = ? WHERE id = ? AND status = ?`,
+		QuizAbandoned, at.UTC(), id, QuizActive)
+	if err != nil {
+		return fmt.Errorf("放弃测验失败: %w", err)
+	}
+	return checkAffected(result)
+}
+
+// ListQuizAnswers 获取一次测验的作答（按作答顺序）
+func (s *Storage) ListQuizAnswers(ctx context.Context, attemptID int64) (answers []*QuizAnswer, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("list_quiz_answers", start, err) }()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT attempt_id, question_id, position, answer, correct, answered_at
+		FROM quiz_answers WHERE attempt_id = ? ORDER BY position
+	`, attemptID)
+	if err != nil {
+		return nil, fmt.Errorf("查询作答记录失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		ans := &QuizAnswer{}
+		if err := rows.Scan(&ans.AttemptID, &ans.QuestionID, &ans.Position, &ans.Answer, &ans.Correct, &ans.AnsweredAt); err != nil {
+			return nil, fmt.Errorf("扫描作答记录失败: %w", err)
+		}
+		answers = append(answers, ans)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历作答记录失败: %w", err)
+	}
+	return answers, nil
+}
+
+// queryQuizQuestions 按条件查询题目
+func queryQuizQuestions(ctx context.Context, q queryer, where string, args ...interface{}) ([]*QuizQuestion, error) {
+	rows, err := q.QueryContext(ctx, `SELECT `+quizQuestionColumns+` FROM quiz_questions `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询题目失败: %w", err)
+	}
+	defer rows.Close()
+
+	var questions []*QuizQuestion
+	for rows.Next() {
+		qq := &QuizQuestion{}
+		var options string
+		if err := rows.Scan(&qq.ID, &qq.BankID, &qq.Slug, &qq.Position, &qq.Type, &qq.Prompt, &options,
+			&qq.Answer, &qq.Tolerance, &qq.Explanation, &qq.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("扫描题目失败: %w", err)
+		}
+		if err := json.Unmarshal([]byte(options), &qq.Options); err != nil {
+			return nil, fmt.Errorf("解析题目 %d 的选项失败: %w", qq.ID, err)
+		}
+		questions = append(questions, qq)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历题目失败: %w", err)
+	}
+	return questions, nil
+}
+
+// queryQuizAttempts 按条件查询测验
+func queryQuizAttempts(ctx context.Context, q queryer, where string, args ...interface{}) ([]*QuizAttempt, error) {
+	rows, err := q.QueryContext(ctx, `SELECT `+quizAttemptColumns+` FROM quiz_attempts `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询测验失败: %w", err)
+	}
+	defer rows.Close()
+
+	var attempts []*QuizAttempt
+	for rows.Next() {
+		a := &QuizAttempt{}
+		var questionIDs string
+		var finishedAt sql.NullTime
+		if err := rows.Scan(&a.ID, &a.AppID, &a.TenantKey, &a.UserID, &a.ChatID, &a.BankID, &questionIDs, &a.Seed,
+			&a.Answered, &a.Correct, &a.Status, &a.StartedAt, &finishedAt); err != nil {
+			return nil, fmt.Errorf("扫描测验失败: %w", err)
+		}
+		if err := json.Unmarshal([]byte(questionIDs), &a.QuestionIDs); err != nil {
+			return nil, fmt.Errorf("解析测验 %d 的题目失败: %w", a.ID, err)
+		}
+		if finishedAt.Valid {
+			a.FinishedAt = &finishedAt.Time
+		}
+		attempts = append(attempts, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历测验失败: %w", err)
+	}
+	return attempts, nil
+}
@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"fin_bot/metrics"
+)
+
+// RateBucket 持久化的限流令牌桶状态
+type RateBucket struct {
+	Key       string
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// Ban 临时禁止用户使用机器人
+type Ban struct {
+	AppID     string    `json:"app_id"`
+	TenantKey string    `json:"tenant_key"`
+	UserID    string    `json:"user_id"` // 被禁止用户的 open_id
+	Reason    string    `json:"reason,omitempty"`
+	CreatedBy string    `json:"created_by"`
+	Until     time.Time `json:"until"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Scope 封禁记录的归属范围
+func (b *Ban) Scope() Scope {
+	return Scope{AppID: b.AppID, TenantKey: b.TenantKey}
+}
+
+// LoadRateBuckets 获取保存的所有令牌桶状态
+func (s *Storage) LoadRateBuckets(ctx context.Context) (buckets []RateBucket, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("load_rate_buckets", start, err) }()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT key, tokens, updated_at FROM rate_limit_buckets`)
+	if err != nil {
+		return nil, fmt.Errorf("查询限流状态失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var b RateBucket
+		if err := rows.Scan(&b.Key, &b.Tokens, &b.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("扫描限流状态失败: %w", err)
+		}
+		buckets = append(buckets, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历限流状态失败: %w", err)
+	}
+	return buckets, nil
+}
+
+// SaveRateBuckets 用 buckets 替换保存的令牌桶状态（整体快照，在一个事务中完成）
+func (s *Storage) SaveRateBuckets(ctx context.Context, buckets []RateBucket) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("save_rate_buckets", start, err) }()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM rate_limit_buckets`); err != nil {
+		return fmt.Errorf("清理限流状态失败: %w", err)
+	}
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO rate_limit_buckets (key, tokens, updated_at) VALUES (?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, b := range buckets {
+		if _, err = stmt.ExecContext(ctx, b.Key, b.Tokens, b.UpdatedAt.UTC()); err != nil {
+			return fmt.Errorf("保存限流状态失败: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// SaveBan 新增或更新封禁记录（同一用户重复封禁时覆盖到期时间和原因）
+func (s *Storage) SaveBan(ctx context.Context, ban *Ban) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("save_ban", start, err) }()
+
+	if ban.CreatedAt.IsZero() {
+		ban.CreatedAt = time.Now().UTC()
+	}
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO rate_limit_bans (app_id, tenant_key, user_id, reason, created_by, until, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(app_id, tenant_key, user_id) DO UPDATE SET
+			reason = excluded.reason, created_by = excluded.created_by,
+			until = excluded.until, created_at = excluded.created_at
+	`, ban.AppID, ban.TenantKey, ban.UserID, ban.Reason, ban.CreatedBy, ban.Until.UTC(), ban.CreatedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("保存封禁记录失败: %w", err)
+	}
+	return nil
+}
+
+// DeleteBan 解除封禁，记录不存在时返回 ErrNotFound
+func (s *Storage) DeleteBan(ctx context.Context, scope Scope, userID string) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("delete_ban", start, err) }()
+
+	result, err := s.db.ExecContext(ctx,
+		`DELETE FROM rate_limit_bans WHERE app_id = ? AND tenant_key = ? AND user_id = ?`,
+		scope.AppID, scope.TenantKey, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("解除封禁失败: %w", err)
+	}
+	return checkAffected(result)
+}
+
+// ListActiveBans 获取在 now 时仍然有效的封禁记录（所有应用）
+func (s *Storage) ListActiveBans(ctx context.Context, now time.Time) (bans []*Ban, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("list_bans", start, err) }()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT app_id, tenant_key, user_id, reason, created_by, until, created_at
+		FROM rate_limit_bans WHERE until > ? ORDER BY until
+	`, now.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("查询封禁记录失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		b := &Ban{}
+		if err := rows.Scan(&b.AppID, &b.TenantKey, &b.UserID, &b.Reason, &b.CreatedBy, &b.Until, &b.CreatedAt); err != nil {
+			return nil, fmt.Errorf("扫描封禁记录失败: %w", err)
+		}
+		bans = append(bans, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历封禁记录失败: %w", err)
+	}
+	return bans, nil
+}
+
+// PurgeExpiredBans 删除在 now 之前已经到期的封禁记录
+func (s *Storage) PurgeExpiredBans(ctx context.Context, now time.Time) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("purge_bans", start, err) }()
+
+	if _, err = s.db.ExecContext(ctx, `DELETE FROM rate_limit_bans WHERE until <= ?`, now.UTC()); err != nil {
+		return fmt.Errorf("清理过期封禁记录失败: %w", err)
+	}
+	return nil
+}
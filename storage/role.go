@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"fin_bot/metrics"
+)
+
+// RoleAssignment 为用户分配的角色
+type RoleAssignment struct {
+	AppID     string    `json:"app_id"`
+	TenantKey string    `json:"tenant_key"`
+	ChatID    string    `json:"chat_id,omitempty"` // 为空时作用于整个租户
+	UserID    string    `json:"user_id"`
+	Role      string    `json:"role"`
+	GrantedBy string    `json:"granted_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Scope 角色分配的归属范围
+func (a *RoleAssignment) Scope() Scope {
+	return Scope{AppID: a.AppID, TenantKey: a.TenantKey}
+}
+
+// ListRoleAssignments 获取所有应用的角色分配
+func (s *Storage) ListRoleAssignments(ctx context.Context) (assignments []*RoleAssignment, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("list_roles", start, err) }()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT app_id, tenant_key, chat_id, user_id, role, granted_by, created_at
+		FROM role_assignments ORDER BY app_id, tenant_key, chat_id, user_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("查询角色分配失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		a := &RoleAssignment{}
+		if err := rows.Scan(&a.AppID, &a.TenantKey, &a.ChatID, &a.UserID, &a.Role, &a.GrantedBy, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("扫描角色分配失败: %w", err)
+		}
+		assignments = append(assignments, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历角色分配失败: %w", err)
+	}
+	return assignments, nil
+}
+
+// SaveRoleAssignment 新增或替换角色分配，并在同一事务中写入审计日志
+func (s *Storage) SaveRoleAssignment(ctx context.Context, a *RoleAssignment, audit *AuditEntry) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("save_role", start, err) }()
+
+	if a.CreatedAt.IsZero() {
+		a.CreatedAt = time.Now().UTC()
+	}
+
+	return s.withAuditTx(ctx, func(tx auditTx) error {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO role_assignments (app_id, tenant_key, chat_id, user_id, role, granted_by, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(app_id, tenant_key, chat_id, user_id) DO UPDATE SET
+				role = excluded.role, granted_by = excluded.granted_by, created_at = excluded.created_at
+		`, a.AppID, a.TenantKey, a.ChatID, a.UserID, a.Role, a.GrantedBy, a.CreatedAt.UTC())
+		if err != nil {
+			return fmt.Errorf("保存角色分配失败: %w", err)
+		}
+		return insertAudit(ctx, tx, audit)
+	})
+}
+
+// DeleteRoleAssignment 删除角色分配并写入审计日志，记录不存在时返回 ErrNotFound
+func (s *Storage) DeleteRoleAssignment(ctx context.Context, scope Scope, chatID, userID string, audit *AuditEntry) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("delete_role", start, err) }()
+
+	return s.withAuditTx(ctx, func(tx auditTx) error {
+		result, err := tx.ExecContext(ctx,
+			`DELETE FROM role_assignments WHERE app_id = ? AND tenant_key = ? AND chat_id = ? AND user_id = ?`,
+			scope.AppID, scope.TenantKey, chatID, userID,
+		)
+		if err != nil {
+			return fmt.Errorf("删除角色分配失败: %w", err)
+		}
+		if err := checkAffected(result); err != nil {
+			return err
+		}
+		return insertAudit(ctx, tx, audit)
+	})
+}
@@ -0,0 +1,332 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"fin_bot/metrics"
+)
+
+// ErrNotFound 记录不存在
+var ErrNotFound = errors.New("记录不存在")
+
+// 定时任务的投递目标类型
+const (
+	TargetTypeChat    = "chat"    // 指定群聊/会话（chat_id）
+	TargetTypeUser    = "user"    // 指定用户（open_id）
+	TargetTypeSegment = "segment" // 预定义的会话分组，例如 all_groups
+)
+
+// 错过执行时间后的补发策略
+const (
+	CatchUpSkip = "skip" // 跳过错过的执行，等待下一次
+	CatchUpOnce = "once" // 只补发一次
+	CatchUpAll  = "all"  // 每个错过的时间点都补发
+)
+
+// Schedule 定时消息任务
+type Schedule struct {
+	ID            int64      `json:"id"`
+	AppID         string     `json:"app_id"`
+	TenantKey     string     `json:"tenant_key"`
+	Name          string     `json:"name"`
+	CronExpr      string     `json:"cron_expr"`
+	Timezone      string     `json:"timezone"`
+	TargetType    string     `json:"target_type"`
+	Targets       []string   `json:"targets"`
+	Content       string     `json:"content"`
+	CatchUpPolicy string     `json:"catch_up_policy"`
+	Enabled       bool       `json:"enabled"`
+	CreatedBy     string     `json:"created_by"`
+	LastRunAt     *time.Time `json:"last_run_at,omitempty"`
+	NextRunAt     *time.Time `json:"next_run_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// Scope 定时任务的归属范围
+func (sch *Schedule) Scope() Scope {
+	return Scope{AppID: sch.AppID, TenantKey: sch.TenantKey}
+}
+
+// ScheduleRun 定时任务的一次执行记录
+type ScheduleRun struct {
+	ID           int64     `json:"id"`
+	ScheduleID   int64     `json:"schedule_id"`
+	ScheduledFor time.Time `json:"scheduled_for"`
+	StartedAt    time.Time `json:"started_at"`
+	Success      int       `json:"success"`
+	Failed       int       `json:"failed"`
+	Error        string    `json:"error,omitempty"`
+}
+
+const scheduleColumns = `id, app_id, tenant_key, name, cron_expr, timezone, target_type, targets, content, catch_up_policy,
+	enabled, created_by, last_run_at, next_run_at, created_at, updated_at`
+
+// CreateSchedule 创建定时任务，成功后回填 ID
+func (s *Storage) CreateSchedule(ctx context.Context, sch *Schedule) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("create_schedule", start, err) }()
+
+	targets, err := json.Marshal(sch.Targets)
+	if err != nil {
+		return fmt.Errorf("序列化投递目标失败: %w", err)
+	}
+
+	now := time.Now().UTC()
+	sch.CreatedAt, sch.UpdatedAt = now, now
+
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO schedules (app_id, tenant_key, name, cron_expr, timezone, target_type, targets, content,
+			catch_up_policy, enabled, created_by, next_run_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		sch.AppID, sch.TenantKey, sch.Name, sch.CronExpr, sch.Timezone, sch.TargetType, string(targets), sch.Content, sch.CatchUpPolicy,
+		sch.Enabled, sch.CreatedBy, nullTime(sch.NextRunAt), now, now,
+	)
+	if err != nil {
+		return fmt.Errorf("创建定时任务失败: %w", err)
+	}
+
+	sch.ID, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("获取定时任务ID失败: %w", err)
+	}
+	return nil
+}
+
+// GetSchedule 根据 ID 获取定时任务
+func (s *Storage) GetSchedule(ctx context.Context, id int64) (sch *Schedule, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("get_schedule", start, err) }()
+
+	row := s.db.QueryRowContext(ctx, `SELECT `+scheduleColumns+` FROM schedules WHERE id = ?`, id)
+	sch, err = scanSchedule(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询定时任务失败: %w", err)
+	}
+	return sch, nil
+}
+
+// ListSchedules 获取定时任务列表
+// scope 为 nil 时返回所有应用的任务（仅供调度器内部使用），onlyEnabled 为 true 时只返回启用的任务
+func (s *Storage) ListSchedules(ctx context.Context, scope *Scope, onlyEnabled bool) (schedules []*Schedule, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("list_schedules", start, err) }()
+
+	query := `SELECT ` + scheduleColumns + ` FROM schedules WHERE 1 = 1`
+	var args []interface{}
+	if scope != nil {
+		query += ` AND app_id = ? AND tenant_key = ?`
+		args = append(args, scope.AppID, scope.TenantKey)
+	}
+	if onlyEnabled {
+		query += ` AND enabled = 1`
+	}
+	query += ` ORDER BY id`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询定时任务失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		sch, err := scanSchedule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("扫描定时任务失败: %w", err)
+		}
+		schedules = append(schedules, sch)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历定时任务失败: %w", err)
+	}
+	return schedules, nil
+}
+
+// SetScheduleEnabled 启用或停用定时任务，同时更新下一次执行时间
+func (s *Storage) SetScheduleEnabled(ctx context.Context, id int64, enabled bool, nextRunAt *time.Time) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("set_schedule_enabled", start, err) }()
+
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE schedules SET enabled = ?, next_run_at = ?, updated_at = ? WHERE id = ?`,
+		enabled, nullTime(nextRunAt), time.Now().UTC(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("更新定时任务状态失败: %w", err)
+	}
+	return checkAffected(result)
+}
+
+// MarkScheduleRun 记录定时任务的执行时间和下一次执行时间
+// lastRunAt 为 nil 时保留原有的上次执行时间（例如错过的执行被跳过）
+func (s *Storage) MarkScheduleRun(ctx context.Context, id int64, lastRunAt, nextRunAt *time.Time) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("mark_schedule_run", start, err) }()
+
+	_, err = s.db.ExecContext(ctx,
+		`UPDATE schedules SET last_run_at = COALESCE(?, last_run_at), next_run_at = ?, updated_at = ? WHERE id = ?`,
+		nullTime(lastRunAt), nullTime(nextRunAt), time.Now().UTC(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("更新定时任务执行时间失败: %w", err)
+	}
+	return nil
+}
+
+// DeleteSchedule 删除定时任务及其执行记录
+func (s *Storage) DeleteSchedule(ctx context.Context, id int64) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("delete_schedule", start, err) }()
+
+	if _, err = s.db.ExecContext(ctx, `DELETE FROM schedule_runs WHERE schedule_id = ?`, id); err != nil {
+		return fmt.Errorf("删除定时任务执行记录失败: %w", err)
+	}
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM schedules WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("删除定时任务失败: %w", err)
+	}
+	return checkAffected(result)
+}
+
+// SaveScheduleRun 保存一次执行记录
+func (s *Storage) SaveScheduleRun(ctx context.Context, run *ScheduleRun) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("save_schedule_run", start, err) }()
+
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO schedule_runs (schedule_id, scheduled_for, started_at, success, failed, error)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, run.ScheduleID, run.ScheduledFor.UTC(), run.StartedAt.UTC(), run.Success, run.Failed, run.Error)
+	if err != nil {
+		return fmt.Errorf("保存执行记录失败: %w", err)
+	}
+
+	run.ID, _ = result.LastInsertId()
+	return nil
+}
+
+// ListScheduleRuns 获取定时任务最近的执行记录（按时间倒序）
+func (s *Storage) ListScheduleRuns(ctx context.Context, scheduleID int64, limit int) (runs []*ScheduleRun, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("list_schedule_runs", start, err) }()
+
+	if limit <= 0 {
+		limit = 20
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, schedule_id, scheduled_for, started_at, success, failed, error
+		FROM schedule_runs
+		WHERE schedule_id = ?
+		ORDER BY id DESC
+		LIMIT ?
+	`, scheduleID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("查询执行记录失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var run ScheduleRun
+		if err := rows.Scan(&run.ID, &run.ScheduleID, &run.ScheduledFor, &run.StartedAt,
+			&run.Success, &run.Failed, &run.Error); err != nil {
+			return nil, fmt.Errorf("扫描执行记录失败: %w", err)
+		}
+		runs = append(runs, &run)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历执行记录失败: %w", err)
+	}
+	return runs, nil
+}
+
+// ListActiveChatIDs 获取指定范围内 since 之后有过消息的会话ID
+func (s *Storage) ListActiveChatIDs(ctx context.Context, scope Scope, since time.Time) (chatIDs []string, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("list_active_chat_ids", start, err) }()
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT DISTINCT chat_id FROM messages
+		WHERE app_id = ? AND tenant_key = ? AND created_at >= ? ORDER BY chat_id`,
+		scope.AppID, scope.TenantKey, since.UTC(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("查询活跃会话失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var chatID string
+		if err := rows.Scan(&chatID); err != nil {
+			return nil, fmt.Errorf("扫描会话ID失败: %w", err)
+		}
+		chatIDs = append(chatIDs, chatID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历会话ID失败: %w", err)
+	}
+	return chatIDs, nil
+}
+
+// rowScanner 兼容 *sql.Row 和 *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanSchedule 扫描一行定时任务
+func scanSchedule(row rowScanner) (*Schedule, error) {
+	var sch Schedule
+	var targets string
+	var lastRunAt, nextRunAt sql.NullTime
+	err := row.Scan(
+		&sch.ID, &sch.AppID, &sch.TenantKey, &sch.Name, &sch.CronExpr, &sch.Timezone, &sch.TargetType, &targets, &sch.Content,
+		&sch.CatchUpPolicy, &sch.Enabled, &sch.CreatedBy, &lastRunAt, &nextRunAt, &sch.CreatedAt, &sch.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(targets), &sch.Targets); err != nil {
+		return nil, fmt.Errorf("解析投递目标失败: %w", err)
+	}
+	if lastRunAt.Valid {
+		sch.LastRunAt = &lastRunAt.Time
+	}
+	if nextRunAt.Valid {
+		sch.NextRunAt = &nextRunAt.Time
+	}
+	return &sch, nil
+}
+
+// nullTime 将可选时间转换为数据库参数（统一存储为 UTC）
+func nullTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC()
+}
+
+// checkAffected 检查更新/删除是否命中记录
+func checkAffected(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("获取影响行数失败: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"fin_bot/metrics"
+)
+
+// ErrTermNotIndexed 搜索词不在索引范围内（消息内容可能已加密，只有允许索引的词可以搜索）
+var ErrTermNotIndexed = errors.New("搜索词不在索引范围内")
+
+// SetIndexer 设置搜索索引的分词规则（需要在开始读写之前调用）
+// fn 返回文本中允许进入索引的词（已归一化），为 nil 时不建立索引；规则变化后需要 ReindexMessages
+func (s *Storage) SetIndexer(fn func(text string) []string) {
+	s.indexer = fn
+}
+
+// replaceTerms 在事务中重建单条消息的索引，msg.Content 为明文
+func (s *Storage) replaceTerms(ctx context.Context, tx *sql.Tx, msg *Message) error {
+	if s.indexer == nil {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM message_terms WHERE message_id = ?`, msg.ID); err != nil {
+		return fmt.Errorf("更新搜索索引失败: %w", err)
+	}
+	for _, term := range s.indexer(msg.Content) {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT OR IGNORE INTO message_terms (message_id, app_id, tenant_key, term) VALUES (?, ?, ?, ?)`,
+			msg.ID, msg.AppID, msg.TenantKey, term,
+		); err != nil {
+			return fmt.Errorf("更新搜索索引失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// SearchMessages 查找同时包含 query 中所有可索引词的消息（按时间倒序）
+// query 中不在索引范围内的词会被忽略，全部不在范围内时返回 ErrTermNotIndexed
+func (s *Storage) SearchMessages(ctx context.Context, scope Scope, query string, limit int) (messages []*Message, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("search_messages", start, err) }()
+
+	if s.indexer == nil {
+		return nil, ErrTermNotIndexed
+	}
+	terms := s.indexer(query)
+	if len(terms) == 0 {
+		return nil, ErrTermNotIndexed
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+
+	args := []interface{}{scope.AppID, scope.TenantKey}
+	for _, term := range terms {
+		args = append(args, term)
+	}
+	args = append(args, len(terms), limit)
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT m.id, m.app_id, m.tenant_key, m.chat_id, m.message_id, m.sender_id, m.sender_type, m.content, m.key_id, m.message_type, m.created_at
+		FROM messages m
+		JOIN (
+			SELECT message_id FROM message_terms
+			WHERE app_id = ? AND tenant_key = ? AND term IN (?`+strings.Repeat(", ?", len(terms)-1)+`)
+			GROUP BY message_id
+			HAVING COUNT(*) = ?
+		) t ON t.message_id = m.id
+		ORDER BY m.created_at DESC, m.id DESC
+		LIMIT ?`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("搜索消息失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		msg, err := s.scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历消息失败: %w", err)
+	}
+	return messages, nil
+}
+
+// ReindexMessages 按当前的分词规则重建所有消息的搜索索引，返回处理的消息数
+func (s *Storage) ReindexMessages(ctx context.Context) (n int, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("reindex_messages", start, err) }()
+
+	// 分批读取和写入，避免长时间持有写锁
+	const batch = 500
+	var lastID int64
+	for {
+		var messages []*Message
+		rows, err := s.db.QueryContext(ctx, `
+			SELECT id, app_id, tenant_key, chat_id, message_id, sender_id, sender_type, content, key_id, message_type, created_at
+			FROM messages WHERE id > ? ORDER BY id LIMIT ?`, lastID, batch)
+		if err != nil {
+			return n, fmt.Errorf("查询消息失败: %w", err)
+		}
+		for rows.Next() {
+			msg, err := s.scanMessage(rows)
+			if err != nil {
+				rows.Close()
+				return n, err
+			}
+			messages = append(messages, msg)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return n, fmt.Errorf("遍历消息失败: %w", err)
+		}
+		if len(messages) == 0 {
+			return n, nil
+		}
+
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return n, err
+		}
+		for _, msg := range messages {
+			if s.indexer == nil {
+				_, err = tx.ExecContext(ctx, `DELETE FROM message_terms WHERE message_id = ?`, msg.ID)
+			} else {
+				err = s.replaceTerms(ctx, tx, msg)
+			}
+			if err != nil {
+				tx.Rollback()
+				return n, err
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return n, fmt.Errorf("提交搜索索引失败: %w", err)
+		}
+		n += len(messages)
+		lastID = messages[len(messages)-1].ID
+	}
+}
@@ -1,22 +1,59 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	_ "modernc.org/sqlite"
 )
 
+// Scope 数据归属范围
+// 消息、定时任务等数据按应用和租户严格隔离，查询时必须同时匹配 app_id 和 tenant_key
+type Scope struct {
+	AppID     string `json:"app_id"`
+	TenantKey string `json:"tenant_key"`
+}
+
 // Storage 数据库存储接口
 type Storage struct {
 	db *sql.DB
+
+	// 以下设置需要在开始读写之前完成（见 SetCipher、SetIndexer）
+	cipher  FieldCipher                // 敏感字段加解密，为 nil 时无法读取已加密的数据
+	encrypt bool                       // 新写入的敏感字段是否加密
+	indexer func(text string) []string // 提取允许进入搜索索引的词，为 nil 时不建立索引
+
+	auditMu sync.Mutex // 串行写入审计日志，保证哈希链不分叉
+	quizMu  sync.Mutex // 串行记录测验作答，重复点击卡片按钮时同一道题只记录一次
+	cardMu  sync.Mutex // 串行记录记忆卡片的复习，重复点击评分按钮时只记录一次
 }
 
-// NewStorage 创建新的存储实例
+// NewStorage 创建新的存储实例，并执行未完成的数据库迁移
 func NewStorage(dbPath string) (*Storage, error) {
+	storage, err := Open(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// 执行数据库迁移
+	if err := storage.Migrate(context.Background()); err != nil {
+		storage.Close()
+		return nil, fmt.Errorf("初始化数据库表失败: %w", err)
+	}
+
+	log.Printf("数据库初始化成功: %s", dbPath)
+	return storage, nil
+}
+
+// Open 打开数据库但不执行迁移（用于查看迁移状态等运维操作）
+func Open(dbPath string) (*Storage, error) {
 	// 确保数据库目录存在
 	dir := filepath.Dir(dbPath)
 	if dir != "." && dir != "" {
@@ -26,7 +63,7 @@ func NewStorage(dbPath string) (*Storage, error) {
 	}
 
 	// 打开数据库连接
-	db, err := sql.Open("sqlite", dbPath)
+	db, err := sql.Open("sqlite", dsn(dbPath))
 	if err != nil {
 		return nil, fmt.Errorf("打开数据库失败: %w", err)
 	}
@@ -36,16 +73,19 @@ func NewStorage(dbPath string) (*Storage, error) {
 		return nil, fmt.Errorf("数据库连接测试失败: %w", err)
 	}
 
-	storage := &Storage{db: db}
+	return &Storage{db: db}, nil
+}
 
-	// 初始化数据库表
-	if err := storage.initTables(); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("初始化数据库表失败: %w", err)
+// dsn 数据库连接参数，事件处理和各个后台任务会同时写入：
+//   - busy_timeout 让写入等待其他连接释放写锁，而不是立即返回 SQLITE_BUSY
+//   - WAL 让读取不阻塞写入（备份使用 VACUUM INTO，不需要复制 -wal 文件）
+//   - _txlock=immediate 让写事务开始时就获取写锁，避免先读后写的事务在升级写锁时失败
+func dsn(dbPath string) string {
+	sep := "?"
+	if strings.Contains(dbPath, "?") {
+		sep = "&"
 	}
-
-	log.Printf("数据库初始化成功: %s", dbPath)
-	return storage, nil
+	return dbPath + sep + "_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate"
 }
 
 // Close 关闭数据库连接
@@ -61,40 +101,40 @@ func (s *Storage) GetDB() *sql.DB {
 	return s.db
 }
 
-// initTables 初始化数据库表
-func (s *Storage) initTables() error {
-	// 创建消息表
-	createMessagesTable := `
-	CREATE TABLE IF NOT EXISTS messages (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		chat_id TEXT NOT NULL,
-		message_id TEXT NOT NULL UNIQUE,
-		sender_id TEXT,
-		sender_type TEXT,
-		content TEXT NOT NULL,
-		message_type TEXT,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);
-	`
-
-	if _, err := s.db.Exec(createMessagesTable); err != nil {
-		return fmt.Errorf("创建消息表失败: %w", err)
-	}
-
-	// 创建索引（SQLite 需要单独创建索引）
-	createIndexes := []string{
-		`CREATE INDEX IF NOT EXISTS idx_chat_id ON messages(chat_id);`,
-		`CREATE INDEX IF NOT EXISTS idx_created_at ON messages(created_at);`,
-	}
+// Ping 检查数据库连接是否可用
+func (s *Storage) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
 
-	for _, indexSQL := range createIndexes {
-		if _, err := s.db.Exec(indexSQL); err != nil {
-			return fmt.Errorf("创建索引失败: %w", err)
+// ClaimUnscoped 将迁移前没有归属的数据（app_id 为空）归属到指定范围，通常是默认应用
+func (s *Storage) ClaimUnscoped(ctx context.Context, scope Scope) error {
+	var claimed int64
+	for _, table := range []string{"messages", "schedules"} {
+		result, err := s.db.ExecContext(ctx,
+			`UPDATE `+table+` SET app_id = ?, tenant_key = ? WHERE app_id = ''`,
+			scope.AppID, scope.TenantKey,
+		)
+		if err != nil {
+			return fmt.Errorf("归属历史数据失败（%s）: %w", table, err)
 		}
+		n, _ := result.RowsAffected()
+		claimed += n
+	}
+	if claimed > 0 {
+		log.Printf("[Storage] 已将 %d 条历史数据归属到应用 %s", claimed, scope.AppID)
 	}
-
-	log.Println("数据库表初始化完成")
 	return nil
 }
 
-
+// CheckWritable 检查数据库是否可写（例如数据库被锁或磁盘只读时会失败）
+func (s *Storage) CheckWritable(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO health_check (id, checked_at) VALUES (1, ?)
+		ON CONFLICT(id) DO UPDATE SET checked_at = excluded.checked_at`,
+		time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("数据库写入检查失败: %w", err)
+	}
+	return nil
+}
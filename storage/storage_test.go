@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestStorage 在临时目录中创建已完成迁移的数据库
+func newTestStorage(t *testing.T) *Storage {
+	t.Helper()
+	s, err := NewStorage(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// TestConcurrentWrites 事件处理和后台任务同时写入时等待锁而不是返回 SQLITE_BUSY
+func TestConcurrentWrites(t *testing.T) {
+	const (
+		writers   = 8
+		perWriter = 50
+	)
+	tests := []struct {
+		name  string
+		audit bool // 偶数编号的写入者写审计日志（BEGIN IMMEDIATE），其余保存消息
+	}{
+		{name: "只保存消息"},
+		{name: "保存消息和审计日志混合", audit: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStorage(t)
+			scope := Scope{AppID: "cli_a", TenantKey: "t1"}
+
+			var wg sync.WaitGroup
+			errs := make(chan error, writers*perWriter)
+			for w := 0; w < writers; w++ {
+				wg.Add(1)
+				go func(w int) {
+					defer wg.Done()
+					for i := 0; i < perWriter; i++ {
+						if tt.audit && w%2 == 0 {
+							errs <- s.AppendAudit(context.Background(), &AuditEntry{Actor: "system", Action: "test"})
+							continue
+						}
+						id := fmt.Sprintf("om_%d_%d", w, i)
+						errs <- s.SaveMessage(context.Background(), &Message{
+							AppID: scope.AppID, TenantKey: scope.TenantKey, ChatID: "oc_1", MessageID: id,
+							SenderID: "ou_test", SenderType: "user", Content: "hello " + id, MessageType: "text",
+							CreatedAt: time.Now(),
+						})
+					}
+				}(w)
+			}
+			wg.Wait()
+			close(errs)
+
+			failed := 0
+			var first error
+			for err := range errs {
+				if err != nil {
+					failed++
+					if first == nil {
+						first = err
+					}
+				}
+			}
+			if failed > 0 {
+				t.Fatalf("%d/%d 次写入失败，第一个错误: %v", failed, writers*perWriter, first)
+			}
+			if _, _, err := s.VerifyAudit(context.Background()); err != nil {
+				t.Errorf("VerifyAudit: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"fin_bot/metrics"
+)
+
+// WatchlistItem 自选列表中的一个证券，自选列表按会话保存：单聊中属于用户，群聊中属于整个群
+type WatchlistItem struct {
+	AppID     string    `json:"app_id"`
+	TenantKey string    `json:"tenant_key"`
+	ChatID    string    `json:"chat_id"`
+	ChatType  string    `json:"chat_type"` // p2p 或 group
+	Symbol    string    `json:"symbol"`    // 规范化的代码，例如 600519.SH
+	AddedBy   string    `json:"added_by"`
+	AddedAt   time.Time `json:"added_at"`
+}
+
+const watchlistColumns = `app_id, tenant_key, chat_id, chat_type, symbol, added_by, added_at`
+
+// AddWatchlistItem 把证券加入会话的自选列表，已经在列表中时返回 false
+func (s *Storage) AddWatchlistItem(ctx context.Context, item *WatchlistItem) (added bool, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("add_watchlist_item", start, err) }()
+
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO watchlist_items (`+watchlistColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(app_id, tenant_key, chat_id, symbol) DO NOTHING
+	`, item.AppID, item.TenantKey, item.ChatID, item.ChatType, item.Symbol, item.AddedBy, item.AddedAt.UTC())
+	if err != nil {
+		return false, fmt.Errorf("保存自选证券失败: %w", err)
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// RemoveWatchlistItem 从会话的自选列表中删除证券，不在列表中时返回 ErrNotFound
+func (s *Storage) RemoveWatchlistItem(ctx context.Context, scope Scope, chatID, symbol string) (err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("remove_watchlist_item", start, err) }()
+
+	result, err := s.db.ExecContext(ctx, `
+		DELETE FROM watchlist_items WHERE app_id = ? AND tenant_key = ? AND chat_id = ? AND symbol = ?
+	`, scope.AppID, scope.TenantKey, chatID, symbol)
+	if err != nil {
+		return fmt.Errorf("删除自选证券失败: %w", err)
+	}
+	return checkAffected(result)
+}
+
+// ListWatchlist 获取会话的自选列表（按加入顺序）
+func (s *Storage) ListWatchlist(ctx context.Context, scope Scope, chatID string) (items []*WatchlistItem, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("list_watchlist", start, err) }()
+
+	return s.queryWatchlist(ctx, `WHERE app_id = ? AND tenant_key = ? AND chat_id = ? ORDER BY id`,
+		scope.AppID, scope.TenantKey, chatID)
+}
+
+// ListAllWatchlists 获取所有会话的自选证券（所有应用，按会话分组、组内按加入顺序）
+func (s *Storage) ListAllWatchlists(ctx context.Context) (items []*WatchlistItem, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveDBQuery("list_all_watchlists", start, err) }()
+
+	return s.queryWatchlist(ctx, `ORDER BY app_id, tenant_key, chat_id, id`)
+}
+
+// queryWatchlist 按条件查询自选证券
+func (s *Storage) queryWatchlist(ctx context.Context, where string, args ...interface{}) ([]*WatchlistItem, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+watchlistColumns+` FROM watchlist_items `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询自选证券失败: %w", err)
+	}
+	defer rows.Close()
+
+	var items []*WatchlistItem
+	for rows.Next() {
+		item := &WatchlistItem{}
+		if err := rows.Scan(&item.AppID, &item.TenantKey, &item.ChatID, &item.ChatType, &item.Symbol,
+			&item.AddedBy, &item.AddedAt); err != nil {
+			return nil, fmt.Errorf("扫描自选证券失败: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历自选证券失败: %w", err)
+	}
+	return items, nil
+}
@@ -0,0 +1,184 @@
+package watchlist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"fin_bot/command"
+	"fin_bot/marketdata"
+	"fin_bot/quote"
+	"fin_bot/storage"
+)
+
+// Commands 返回自选列表的聊天命令
+func (s *Service) Commands() []*command.Command {
+	return []*command.Command{
+		{
+			Name:         "watch",
+			Usage:        "/watch add|remove|list [代码或名称...]",
+			Description:  "管理自选列表（单聊中是自己的列表，群聊中是全群共用的列表），可以一次加入或删除多个证券，例如 /watch add 600519 腾讯 AAPL",
+			ReplyHandler: s.commandWatch,
+		},
+		{
+			Name:         "watchlist",
+			Usage:        "/watchlist",
+			Description:  "查看自选列表中所有证券的最新价和涨跌幅",
+			ReplyHandler: s.commandWatchlist,
+		},
+	}
+}
+
+// commandWatch 处理 /watch 命令
+func (s *Service) commandWatch(ctx context.Context, req *command.Request) (*command.Reply, error) {
+	if len(req.Args) == 0 {
+		return nil, errors.New("缺少子命令，例如 /watch add 600519、/watch remove 600519、/watch list")
+	}
+	scope := storage.Scope{AppID: req.AppID, TenantKey: req.TenantKey}
+	queries := splitQueries(req.Args[1:])
+
+	switch strings.ToLower(req.Args[0]) {
+	case "add":
+		if len(queries) == 0 {
+			return nil, errors.New("缺少要加入的证券，例如 /watch add 600519 00700.HK AAPL")
+		}
+		return s.add(ctx, req, scope, queries)
+	case "remove", "rm", "del":
+		if len(queries) == 0 {
+			return nil, errors.New("缺少要删除的证券，例如 /watch remove 600519")
+		}
+		return s.remove(ctx, req, scope, queries)
+	case "list", "ls":
+		syms, err := s.List(ctx, scope, req.ChatID)
+		if err != nil {
+			return nil, err
+		}
+		if len(syms) == 0 {
+			return command.TextReply(emptyHint(req.ChatType)), nil
+		}
+		settings, _ := s.currentSettings()
+		return command.TextReply(fmt.Sprintf("%s（%d/%d）: %s\n发送 /watchlist 查看最新价格",
+			listName(req.ChatType), len(syms), settings.MaxSymbols, symbolList(syms))), nil
+	}
+	return nil, fmt.Errorf("无效的子命令: %s（应为 add、remove 或 list）", req.Args[0])
+}
+
+// add 处理 /watch add：按代码或名称查找证券后加入自选列表
+func (s *Service) add(ctx context.Context, req *command.Request, scope storage.Scope, queries []string) (*command.Reply, error) {
+	var syms []marketdata.Symbol
+	var lines []string
+	for _, query := range queries {
+		sym, err := s.quotes.Resolve(ctx, query)
+		var unknown *quote.UnknownError
+		switch {
+		case err == nil:
+			syms = append(syms, sym)
+		case errors.As(err, &unknown) && len(unknown.Suggestions) > 0:
+			names := make([]string, len(unknown.Suggestions))
+			for i, inst := range unknown.Suggestions {
+				names[i] = fmt.Sprintf("%s %s", inst.Name, inst.Symbol)
+			}
+			lines = append(lines, fmt.Sprintf("没有找到「%s」，你要找的是不是: %s", query, strings.Join(names, "、")))
+		case errors.As(err, &unknown), errors.Is(err, marketdata.ErrNotFound):
+			lines = append(lines, fmt.Sprintf("没有找到「%s」", query))
+		default:
+			return nil, err
+		}
+	}
+
+	if len(syms) > 0 {
+		result, err := s.Add(ctx, scope, req.ChatID, req.ChatType, req.SenderID, syms)
+		if err != nil {
+			return nil, err
+		}
+		var summary []string
+		if len(result.Added) > 0 {
+			summary = append(summary, fmt.Sprintf("已加入%s: %s", listName(req.ChatType), symbolList(result.Added)))
+		}
+		if len(result.Existing) > 0 {
+			summary = append(summary, "已经在列表中: "+symbolList(result.Existing))
+		}
+		if len(result.Skipped) > 0 {
+			settings, _ := s.currentSettings()
+			summary = append(summary, fmt.Sprintf("自选列表最多 %d 个证券，没有加入: %s", settings.MaxSymbols, symbolList(result.Skipped)))
+		}
+		lines = append(summary, lines...)
+	}
+	return command.TextReply(strings.Join(lines, "\n")), nil
+}
+
+// remove 处理 /watch remove：按代码删除，代码不在列表中时再按名称查找
+func (s *Service) remove(ctx context.Context, req *command.Request, scope storage.Scope, queries []string) (*command.Reply, error) {
+	var removed []marketdata.Symbol
+	var missing []string
+	for _, query := range queries {
+		err := storage.ErrNotFound
+		sym, perr := marketdata.ParseSymbol(query)
+		if perr == nil {
+			err = s.Remove(ctx, scope, req.ChatID, sym)
+		}
+		if errors.Is(err, storage.ErrNotFound) {
+			if resolved, rerr := s.quotes.Resolve(ctx, query); rerr == nil && resolved != sym {
+				sym = resolved
+				err = s.Remove(ctx, scope, req.ChatID, sym)
+			}
+		}
+		switch {
+		case err == nil:
+			removed = append(removed, sym)
+		case errors.Is(err, storage.ErrNotFound):
+			missing = append(missing, query)
+		default:
+			return nil, err
+		}
+	}
+
+	var lines []string
+	if len(removed) > 0 {
+		lines = append(lines, fmt.Sprintf("已从%s删除: %s", listName(req.ChatType), symbolList(removed)))
+	}
+	if len(missing) > 0 {
+		lines = append(lines, "不在列表中: "+strings.Join(missing, "、"))
+	}
+	return command.TextReply(strings.Join(lines, "\n")), nil
+}
+
+// commandWatchlist 处理 /watchlist 命令
+func (s *Service) commandWatchlist(ctx context.Context, req *command.Request) (*command.Reply, error) {
+	syms, err := s.List(ctx, storage.Scope{AppID: req.AppID, TenantKey: req.TenantKey}, req.ChatID)
+	if err != nil {
+		return nil, err
+	}
+	if len(syms) == 0 {
+		return command.TextReply(emptyHint(req.ChatType)), nil
+	}
+	entries := s.Quotes(ctx, syms, nil)
+	return command.CardReply(s.summaryCard("⭐ "+listName(req.ChatType), entries, "发送 /watch add|remove <代码或名称> 修改自选"))
+}
+
+// splitQueries 拆分命令参数中的证券，支持空格、逗号和顿号分隔
+func splitQueries(args []string) []string {
+	var queries []string
+	for _, arg := range args {
+		for _, q := range strings.FieldsFunc(arg, func(r rune) bool { return r == ',' || r == '，' || r == '、' }) {
+			if q = strings.TrimSpace(q); q != "" {
+				queries = append(queries, q)
+			}
+		}
+	}
+	return queries
+}
+
+// listName 自选列表的名称：单聊中是个人的列表，群聊中是全群共用的列表
+func listName(chatType string) string {
+	if chatType == "group" {
+		return "本群自选"
+	}
+	return "我的自选"
+}
+
+// emptyHint 自选列表为空时的提示
+func emptyHint(chatType string) string {
+	return listName(chatType) + "还是空的，发送 /watch add <代码或名称> 加入证券，例如 /watch add 600519 00700.HK AAPL"
+}
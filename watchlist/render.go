@@ -0,0 +1,47 @@
+package watchlist
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"fin_bot/card"
+	"fin_bot/marketdata"
+)
+
+// summaryCard 自选证券的价格汇总卡片：每个证券一行，包含最新价和涨跌幅，note 为底部的说明
+func (s *Service) summaryCard(title string, entries []*Entry, note string) *card.Card {
+	cd := card.New(title, card.ColorBlue)
+	lines := make([]string, len(entries))
+	var latest *marketdata.Quote
+	for i, e := range entries {
+		switch {
+		case e.Err == nil:
+			lines[i] = s.quotes.Brief(e.Quote)
+			if latest == nil || e.Quote.Time.After(latest.Time) {
+				latest = e.Quote
+			}
+		case errors.Is(e.Err, marketdata.ErrNotFound):
+			lines[i] = fmt.Sprintf("**%s**　没有找到该证券", e.Symbol)
+		case errors.Is(e.Err, marketdata.ErrNoData):
+			lines[i] = fmt.Sprintf("**%s**　暂时没有行情数据", e.Symbol)
+		default:
+			lines[i] = fmt.Sprintf("**%s**　行情查询失败", e.Symbol)
+		}
+	}
+	cd.Add(card.Markdown(strings.Join(lines, "\n")))
+
+	if latest != nil {
+		note = fmt.Sprintf("共 %d 个证券 · 数据时间 %s · %s", len(entries), s.quotes.AsOf(latest.Time), note)
+	}
+	return cd.Add(card.Note(note))
+}
+
+// symbolList 证券代码列表，用顿号分隔
+func symbolList(syms []marketdata.Symbol) string {
+	names := make([]string, len(syms))
+	for i, sym := range syms {
+		names[i] = sym.String()
+	}
+	return strings.Join(names, "、")
+}
@@ -0,0 +1,336 @@
+package watchlist
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"fin_bot/audit"
+	"fin_bot/card"
+	"fin_bot/marketdata"
+	"fin_bot/quote"
+	"fin_bot/service"
+	"fin_bot/storage"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Summary 定时汇总的类型
+type Summary string
+
+// 定时汇总
+const (
+	Morning Summary = "morning" // 早盘汇总
+	Evening Summary = "evening" // 收盘汇总
+)
+
+// Title 汇总卡片的标题
+func (k Summary) Title() string {
+	if k == Morning {
+		return "☀️ 自选早报"
+	}
+	return "🌙 自选收盘汇总"
+}
+
+// Settings 可以在运行中修改的自选列表设置
+type Settings struct {
+	MorningSchedule string // 早盘汇总的 cron 表达式，为空表示不发送
+	EveningSchedule string // 收盘汇总的 cron 表达式，为空表示不发送
+	Timezone        string // 定时汇总使用的时区
+	MaxSymbols      int    // 每个会话最多的自选证券数
+}
+
+// Service 自选列表：按会话保存自选证券（单聊中属于用户，群聊中属于整个群），
+// 通过 /watchlist 查看当前价格，并在早盘和收盘时向有自选证券的会话发送汇总
+type Service struct {
+	store  *storage.Storage
+	apps   *service.AppRegistry // 发送定时汇总
+	market marketdata.Provider
+	quotes *quote.Service // 按名称查找证券和生成报价摘要
+	now    func() time.Time
+
+	settingsMu sync.RWMutex // 保护 settings 和 loc，配置热加载时会被修改
+	settings   Settings
+	loc        *time.Location
+
+	pushMu   sync.Mutex // 串行化定时汇总的发送
+	cronMu   sync.Mutex
+	cron     *cron.Cron
+	entryIDs []cron.EntryID
+}
+
+// New 创建自选列表服务，market 为行情来源（通常是带缓存的 *marketdata.Cache）
+func New(store *storage.Storage, apps *service.AppRegistry, market marketdata.Provider, quotes *quote.Service, settings Settings) *Service {
+	s := &Service{store: store, apps: apps, market: market, quotes: quotes, now: time.Now}
+	s.setSettings(settings)
+	return s
+}
+
+// SetSettings 修改自选列表设置，定时汇总运行中时按新的 cron 表达式和时区重新调度
+func (s *Service) SetSettings(settings Settings) {
+	old, _ := s.currentSettings()
+	s.setSettings(settings)
+
+	if old.MorningSchedule != settings.MorningSchedule || old.EveningSchedule != settings.EveningSchedule ||
+		old.Timezone != settings.Timezone {
+		s.cronMu.Lock()
+		defer s.cronMu.Unlock()
+		if s.cron != nil {
+			if err := s.reschedule(); err != nil {
+				log.Printf("[watchlist] 更新定时汇总失败: %v", err)
+			}
+		}
+	}
+}
+
+// setSettings 保存设置，时区无效时使用 UTC（配置校验保证时区有效）
+func (s *Service) setSettings(settings Settings) {
+	loc, err := time.LoadLocation(settings.Timezone)
+	if err != nil {
+		log.Printf("[watchlist] 无效的时区 %q，使用 UTC: %v", settings.Timezone, err)
+		loc = time.UTC
+	}
+	s.settingsMu.Lock()
+	s.settings = settings
+	s.loc = loc
+	s.settingsMu.Unlock()
+}
+
+// currentSettings 获取当前的自选列表设置和时区
+func (s *Service) currentSettings() (Settings, *time.Location) {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return s.settings, s.loc
+}
+
+// AddResult 加入自选列表的结果
+type AddResult struct {
+	Added    []marketdata.Symbol
+	Existing []marketdata.Symbol // 已经在列表中的证券
+	Skipped  []marketdata.Symbol // 列表已满没有加入的证券
+}
+
+// Add 把证券加入会话的自选列表，超出上限的证券不加入
+func (s *Service) Add(ctx context.Context, scope storage.Scope, chatID, chatType, userID string, symbols []marketdata.Symbol) (*AddResult, error) {
+	items, err := s.store.ListWatchlist(ctx, scope, chatID)
+	if err != nil {
+		return nil, err
+	}
+	settings, _ := s.currentSettings()
+	count := len(items)
+	result := &AddResult{}
+	for _, sym := range symbols {
+		if count >= settings.MaxSymbols && !contains(items, sym) {
+			result.Skipped = append(result.Skipped, sym)
+			continue
+		}
+		added, err := s.store.AddWatchlistItem(ctx, &storage.WatchlistItem{
+			AppID: scope.AppID, TenantKey: scope.TenantKey, ChatID: chatID, ChatType: chatType,
+			Symbol: sym.String(), AddedBy: userID, AddedAt: s.now(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if added {
+			result.Added = append(result.Added, sym)
+			count++
+		} else {
+			result.Existing = append(result.Existing, sym)
+		}
+	}
+	return result, nil
+}
+
+// contains 自选列表中是否已有该证券
+func contains(items []*storage.WatchlistItem, sym marketdata.Symbol) bool {
+	for _, item := range items {
+		if item.Symbol == sym.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// Remove 从会话的自选列表中删除证券，不在列表中时返回 storage.ErrNotFound
+func (s *Service) Remove(ctx context.Context, scope storage.Scope, chatID string, sym marketdata.Symbol) error {
+	return s.store.RemoveWatchlistItem(ctx, scope, chatID, sym.String())
+}
+
+// List 获取会话的自选证券（按加入顺序）
+func (s *Service) List(ctx context.Context, scope storage.Scope, chatID string) ([]marketdata.Symbol, error) {
+	items, err := s.store.ListWatchlist(ctx, scope, chatID)
+	if err != nil {
+		return nil, err
+	}
+	return symbols(items), nil
+}
+
+// symbols 自选证券的代码，无法识别的代码（数据库被手动修改）只记录日志
+func symbols(items []*storage.WatchlistItem) []marketdata.Symbol {
+	syms := make([]marketdata.Symbol, 0, len(items))
+	for _, item := range items {
+		sym, err := marketdata.ParseSymbol(item.Symbol)
+		if err != nil {
+			log.Printf("[watchlist] 无效的自选证券 %q: %v", item.Symbol, err)
+			continue
+		}
+		syms = append(syms, sym)
+	}
+	return syms
+}
+
+// Entry 自选证券的报价，Err 不为空时没有报价
+type Entry struct {
+	Symbol marketdata.Symbol
+	Quote  *marketdata.Quote
+	Err    error
+}
+
+// Quotes 查询自选证券的最新报价，quotes 中已有的报价直接使用（同一次汇总中的多个会话共用）
+func (s *Service) Quotes(ctx context.Context, syms []marketdata.Symbol, quotes map[marketdata.Symbol]*Entry) []*Entry {
+	entries := make([]*Entry, len(syms))
+	for i, sym := range syms {
+		if e, ok := quotes[sym]; ok {
+			entries[i] = e
+			continue
+		}
+		e := &Entry{Symbol: sym}
+		e.Quote, e.Err = s.market.Quote(ctx, sym)
+		if e.Err != nil && !errors.Is(e.Err, marketdata.ErrNotFound) && !errors.Is(e.Err, marketdata.ErrNoData) {
+			log.Printf("[watchlist] 查询 %s 的报价失败: %v", sym, e.Err)
+		}
+		if quotes != nil {
+			quotes[sym] = e
+		}
+		entries[i] = e
+	}
+	return entries
+}
+
+// Start 启动定时汇总
+func (s *Service) Start(ctx context.Context) error {
+	s.cronMu.Lock()
+	defer s.cronMu.Unlock()
+
+	s.cron = cron.New()
+	if err := s.reschedule(); err != nil {
+		return err
+	}
+	s.cron.Start()
+	return nil
+}
+
+// Stop 停止定时汇总，等待正在发送的汇总完成
+func (s *Service) Stop(ctx context.Context) error {
+	s.cronMu.Lock()
+	c := s.cron
+	s.cron = nil
+	s.cronMu.Unlock()
+	if c == nil {
+		return nil
+	}
+
+	select {
+	case <-c.Stop().Done():
+		log.Println("[watchlist] 定时汇总已停止")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reschedule 按当前设置替换定时汇总任务，调用方需持有 cronMu
+func (s *Service) reschedule() error {
+	for _, id := range s.entryIDs {
+		s.cron.Remove(id)
+	}
+	s.entryIDs = nil
+
+	settings, loc := s.currentSettings()
+	for _, job := range []struct {
+		kind     Summary
+		schedule string
+	}{
+		{Morning, settings.MorningSchedule},
+		{Evening, settings.EveningSchedule},
+	} {
+		if job.schedule == "" {
+			continue
+		}
+		kind := job.kind
+		spec := "CRON_TZ=" + loc.String() + " " + job.schedule
+		id, err := s.cron.AddFunc(spec, func() { s.Push(context.Background(), kind) })
+		if err != nil {
+			return fmt.Errorf("无效的 %s 汇总表达式 %q: %w", kind, job.schedule, err)
+		}
+		s.entryIDs = append(s.entryIDs, id)
+	}
+	if len(s.entryIDs) == 0 {
+		log.Println("[watchlist] 未配置定时汇总")
+		return nil
+	}
+	log.Printf("[watchlist] 定时汇总已启用: morning=%q, evening=%q, timezone=%s",
+		settings.MorningSchedule, settings.EveningSchedule, loc)
+	return nil
+}
+
+// Push 向所有自选列表不为空的会话发送汇总，返回发送的会话数
+func (s *Service) Push(ctx context.Context, kind Summary) int {
+	s.pushMu.Lock()
+	defer s.pushMu.Unlock()
+
+	items, err := s.store.ListAllWatchlists(ctx)
+	if err != nil {
+		log.Printf("[watchlist] 加载自选列表失败: %v", err)
+		return 0
+	}
+
+	// 按会话分组（结果已按会话排序）
+	var chats [][]*storage.WatchlistItem
+	for i, item := range items {
+		if i == 0 || !sameChat(items[i-1], item) {
+			chats = append(chats, nil)
+		}
+		chats[len(chats)-1] = append(chats[len(chats)-1], item)
+	}
+
+	quotes := make(map[marketdata.Symbol]*Entry)
+	sent := 0
+	for _, chat := range chats {
+		if ctx.Err() != nil {
+			break
+		}
+		first := chat[0]
+		entries := s.Quotes(ctx, symbols(chat), quotes)
+		if err := s.push(ctx, first, s.summaryCard(kind.Title(), entries, "发送 /watchlist 查看最新价格，/watch add|remove <代码或名称> 修改自选")); err != nil {
+			log.Printf("[watchlist] 发送定时汇总失败: kind=%s, app=%s, tenant=%s, chat=%s, error=%v", kind, first.AppID, first.TenantKey, first.ChatID, err)
+			continue
+		}
+		sent++
+	}
+	log.Printf("[watchlist] 定时汇总已发送: kind=%s, chats=%d, sent=%d", kind, len(chats), sent)
+	return sent
+}
+
+// sameChat 两个自选证券是否属于同一个会话
+func sameChat(a, b *storage.WatchlistItem) bool {
+	return a.AppID == b.AppID && a.TenantKey == b.TenantKey && a.ChatID == b.ChatID
+}
+
+// push 向一个会话发送汇总卡片
+func (s *Service) push(ctx context.Context, item *storage.WatchlistItem, cd *card.Card) error {
+	content, err := json.Marshal(cd)
+	if err != nil {
+		return err
+	}
+	larkService, err := s.apps.LarkService(item.AppID)
+	if err != nil {
+		return err
+	}
+	ctx = audit.WithActor(ctx, audit.Actor{ID: "watchlist:summary", Via: audit.ViaSystem, TenantKey: item.TenantKey})
+	return larkService.SendCardMessage(ctx, item.ChatID, "chat_id", string(content))
+}
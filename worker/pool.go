@@ -0,0 +1,162 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"fin_bot/metrics"
+)
+
+var (
+	// ErrQueueFull 队列已满，任务被拒绝
+	ErrQueueFull = errors.New("任务队列已满")
+	// ErrPoolClosed 工作池已关闭，不再接收任务
+	ErrPoolClosed = errors.New("工作池已关闭")
+)
+
+// Task 工作池中执行的任务
+type Task func(ctx context.Context)
+
+// Submitter 任务提交接口，由 *Pool 和 Inline 实现
+type Submitter interface {
+	Submit(task Task) error
+}
+
+// Inline 在调用方 goroutine 中同步执行任务，用于回放等需要确定执行顺序的场景
+type Inline struct{}
+
+// Submit 立即执行任务，任务 panic 时记录日志并返回错误
+func (Inline) Submit(task Task) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[worker] 任务执行 panic: name=inline, error=%v", r)
+			err = fmt.Errorf("任务 panic: %v", r)
+		}
+	}()
+	task(context.Background())
+	return nil
+}
+
+// Pool 固定数量 worker 消费的有界任务队列
+type Pool struct {
+	name    string
+	workers int
+	tasks   chan Task
+
+	mu     sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+	cancel context.CancelFunc
+}
+
+// NewPool 创建新的工作池
+// name: 队列名（用于日志和指标）
+// workers: 并发 worker 数
+// queueSize: 队列容量，超出后 Submit 返回 ErrQueueFull
+func NewPool(name string, workers, queueSize int) *Pool {
+	if workers <= 0 {
+		workers = 1
+	}
+	if queueSize <= 0 {
+		queueSize = 1
+	}
+	return &Pool{
+		name:    name,
+		workers: workers,
+		tasks:   make(chan Task, queueSize),
+	}
+}
+
+// Start 启动 worker
+// 传给任务的 ctx 继承 ctx 的值但不随其取消，而是在 Stop 排空超时后才取消，
+// 这样收到退出信号时进行中的任务仍能把回复发出去
+func (p *Pool) Start(ctx context.Context) {
+	taskCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	p.cancel = cancel
+	for i := 0; i < p.workers; i++ {
+		p.wg.Add(1)
+		go p.run(taskCtx)
+	}
+	log.Printf("[worker] 工作池已启动: name=%s, workers=%d, capacity=%d", p.name, p.workers, cap(p.tasks))
+}
+
+// Submit 提交任务，队列满或已关闭时立即返回错误
+func (p *Pool) Submit(task Task) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrPoolClosed
+	}
+
+	select {
+	case p.tasks <- task:
+		metrics.QueueDepth.WithLabelValues(p.name).Set(float64(len(p.tasks)))
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Len 当前积压的任务数
+func (p *Pool) Len() int {
+	return len(p.tasks)
+}
+
+// Cap 队列容量
+func (p *Pool) Cap() int {
+	return cap(p.tasks)
+}
+
+// Stop 停止接收新任务，并等待已入队的任务执行完毕
+// 如果 ctx 先结束，取消进行中任务的 ctx 并返回 ctx 的错误
+func (p *Pool) Stop(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.tasks)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Printf("[worker] 工作池已停止: name=%s", p.name)
+		if p.cancel != nil {
+			p.cancel()
+		}
+		return nil
+	case <-ctx.Done():
+		log.Printf("[worker] 等待工作池排空超时: name=%s, remaining=%d", p.name, len(p.tasks))
+		if p.cancel != nil {
+			p.cancel()
+		}
+		return ctx.Err()
+	}
+}
+
+// run worker 主循环
+func (p *Pool) run(ctx context.Context) {
+	defer p.wg.Done()
+	for task := range p.tasks {
+		metrics.QueueDepth.WithLabelValues(p.name).Set(float64(len(p.tasks)))
+		p.execute(ctx, task)
+	}
+}
+
+// execute 执行单个任务，避免 panic 拖垮 worker
+func (p *Pool) execute(ctx context.Context, task Task) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[worker] 任务执行 panic: name=%s, error=%v", p.name, r)
+		}
+	}()
+	task(ctx)
+}